@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/approval"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+)
+
+// approvalOptions are the flags of the approval subcommands.
+type approvalOptions struct {
+	configPath, by, comment *string
+}
+
+// approvalFlagSet returns the flags of an approval subcommand.
+func approvalFlagSet(subcommand string) (*flag.FlagSet, approvalOptions) {
+	flags := flag.NewFlagSet("approval "+subcommand, flag.ExitOnError)
+	opts := approvalOptions{configPath: flags.String("config", config.Path(), "path to the configuration file")}
+	switch subcommand {
+	case "submit", "approve", "reject":
+		opts.by = flags.String("by", os.Getenv("USER"), "who "+subcommand+"s the report")
+		opts.comment = flags.String("comment", "", "comment recorded with the transition, e.g. what to change")
+	}
+	return flags, opts
+}
+
+// manageApprovals lists, shows and moves scheduled reports through the
+// draft, review and approved workflow. A running server delivers approved
+// reports at its next report check.
+func manageApprovals(args []string) {
+	if len(args) < 1 {
+		usageError("Error: approval subcommand required (list, show, submit, approve, reject)")
+	}
+
+	flags, opts := approvalFlagSet(args[0])
+	flags.Parse(args[1:])
+
+	switch args[0] {
+	case "list":
+		state, err := openStateStore(*opts.configPath).LoadApproval()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, report := range state.Sorted() {
+			status := string(report.Status)
+			if !report.DeliveredAt.IsZero() {
+				status = "delivered"
+			}
+			approvers := "-"
+			if len(report.Approvals) > 0 {
+				approvers = strings.Join(report.Approvers(), ",")
+			}
+			fmt.Printf("%-32s %-10s %-10s %s  %s\n", report.ID, report.Type, status, report.CreatedAt.Format(time.RFC3339), approvers)
+		}
+	case "show":
+		if flags.NArg() < 1 {
+			usageError("Error: report id required")
+		}
+		state, err := openStateStore(*opts.configPath).LoadApproval()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		report := state.Find(flags.Arg(0))
+		if report == nil {
+			fmt.Fprintf(os.Stderr, "Error: %v: %s\n", approval.ErrNotFound, flags.Arg(0))
+			os.Exit(1)
+		}
+		fmt.Printf("Report %s (%s, schedule %s): %s\n", report.ID, report.Type, report.Schedule, report.Status)
+		for _, transition := range report.History {
+			by := transition.By
+			if by == "" {
+				by = "secmetrics"
+			}
+			fmt.Printf("  %s  %-8s -> %-8s by %s", transition.At.Format(time.RFC3339), transition.From, transition.To, by)
+			if transition.Comment != "" {
+				fmt.Printf(": %s", transition.Comment)
+			}
+			fmt.Println()
+		}
+		for _, signoff := range report.Approvals {
+			fmt.Printf("  approved by %s at %s\n", signoff.By, signoff.At.Format(time.RFC3339))
+		}
+		if !report.DeliveredAt.IsZero() {
+			fmt.Printf("  delivered as %s at %s\n", report.Filename, report.DeliveredAt.Format(time.RFC3339))
+		}
+		fmt.Println()
+		os.Stdout.Write(report.Content)
+	case "submit", "approve", "reject":
+		checkWritable(*opts.configPath, "approval "+args[0])
+		if flags.NArg() < 1 {
+			usageError("Error: report id required")
+		}
+		cfg, err := config.LoadOrDefault(*opts.configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		id, now := flags.Arg(0), time.Now()
+		metricsStore := openStateStore(*opts.configPath)
+		state, err := metricsStore.LoadApproval()
+		var report *approval.Report
+		if err == nil {
+			switch args[0] {
+			case "submit":
+				report, err = state.Submit(id, *opts.by, *opts.comment, now)
+			case "approve":
+				report, err = state.Approve(cfg.Server.Approval, id, *opts.by, *opts.comment, now)
+			case "reject":
+				report, err = state.Reject(id, *opts.by, *opts.comment, now)
+			}
+		}
+		if err == nil {
+			err = metricsStore.SaveApproval(state)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Report %s is %s", report.ID, report.Status)
+		if report.Status == approval.StatusReview && len(report.Approvals) > 0 {
+			fmt.Printf(", approved by %s", strings.Join(report.Approvers(), ", "))
+		}
+		fmt.Println()
+	default:
+		usageError("Unknown approval subcommand: %s", args[0])
+	}
+}
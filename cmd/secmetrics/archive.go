@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/archive"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+	"github.com/hallucinaut/secmetrics/pkg/sources"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// addReportData adds the data only reportType shows to report, from
+// collector as of now. The ops report covers month (YYYY-MM, default the
+// current month) in the report's time zone; the gaps report and the
+// campaign status section read the inventory and campaigns of sourcesCfg.
+func addReportData(report *reporting.Report, collector *metrics.MetricsCollector, reportType, month string, sourcesCfg *sources.Config, now time.Time) error {
+	var err error
+	switch reportType {
+	case "ops":
+		start, end, err := parseMonth(month, report.Location)
+		if err != nil {
+			return err
+		}
+		report.Ops = opsData(collector, start, end)
+	case "technical":
+		addTechnicalData(report, collector, now)
+	case "targets":
+		report.Targets = targetsData(collector, now)
+	case "gaps":
+		if report.Gaps, err = gapData(sourcesCfg.Inventory); err != nil {
+			return err
+		}
+	}
+	report.Campaigns, err = campaignData(sourcesCfg.Campaigns, now)
+	return err
+}
+
+// checkOutputFormats returns an error when formats cannot be written to
+// output: several formats, and pdf, need a file.
+func checkOutputFormats(formats []string, output string) error {
+	if len(formats) > 1 && output == "" {
+		return errors.New("several formats require --output")
+	}
+	for _, f := range formats {
+		if f == "pdf" && output == "" {
+			return errors.New("pdf output requires --output")
+		}
+	}
+	return nil
+}
+
+// writeArtifacts writes each artifact to its file, or to stdout.
+func writeArtifacts(artifacts []reportArtifact) error {
+	for _, artifact := range artifacts {
+		if artifact.path == "" {
+			fmt.Println(artifact.content)
+			continue
+		}
+		if err := os.WriteFile(artifact.path, []byte(artifact.content), 0o644); err != nil {
+			return err
+		}
+		fmt.Println("Report written to", artifact.path)
+	}
+	return nil
+}
+
+// archiveStore returns the store reports are archived in, or nil when
+// reports are not archived: without a store, with demo data and in
+// read-only mode.
+func archiveStore(cfg *config.Config) *store.FileStore {
+	if demoMode || isReadOnly(cfg) {
+		return nil
+	}
+	return openStore(cfg)
+}
+
+// archiveReport archives version of report with its data snapshot and the
+// digests of the artifacts rendered from it.
+func archiveReport(metricsStore *store.FileStore, version *archive.Version, report *reporting.Report, artifacts []reportArtifact) error {
+	snapshot, err := reporting.MarshalReport(report)
+	if err != nil {
+		return err
+	}
+	version.Snapshot = snapshot
+	for _, artifact := range artifacts {
+		version.Record(artifact.ext, []byte(artifact.content))
+	}
+	return metricsStore.SaveReport(version)
+}
+
+// archiveOptions are the flags of the archive subcommands.
+type archiveOptions struct {
+	configPath, format, output, by, reason *string
+	deliver                                *bool
+}
+
+// archiveFlagSet returns the flags of an archive subcommand.
+func archiveFlagSet(subcommand string) (*flag.FlagSet, archiveOptions) {
+	flags := flag.NewFlagSet("archive "+subcommand, flag.ExitOnError)
+	opts := archiveOptions{configPath: flags.String("config", config.Path(), "path to the configuration file")}
+	switch subcommand {
+	case "regenerate", "correct":
+		opts.format = flags.String("format", "", "comma-separated output formats of the report type, as for the report command")
+		opts.output = flags.String("output", "", "write the report to this file instead of stdout")
+	}
+	if subcommand == "correct" {
+		opts.by = flags.String("by", os.Getenv("USER"), "who issues the correction")
+		opts.reason = flags.String("reason", "", "what the correction corrects (required)")
+		opts.deliver = flags.Bool("deliver", false, "deliver the correction to the targets configured in the config file")
+	}
+	return flags, opts
+}
+
+// manageArchive lists and shows the archived report versions, regenerates
+// a version from its snapshot, and issues corrections as new versions.
+func manageArchive(args []string) {
+	if len(args) < 1 {
+		usageError("Error: archive subcommand required (list, show, regenerate, correct)")
+	}
+
+	flags, opts := archiveFlagSet(args[0])
+	flags.Parse(args[1:])
+
+	switch args[0] {
+	case "list":
+		versions, err := openStateStore(*opts.configPath).ListReports()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, version := range archive.Sorted(versions) {
+			fmt.Printf("%-40s %-10s v%-3d %s", version.ID, version.Type, version.Version, version.CreatedAt.Format(time.RFC3339))
+			if version.Supersedes != "" {
+				fmt.Printf("  supersedes %s", version.Supersedes)
+			}
+			fmt.Println()
+		}
+	case "show":
+		if flags.NArg() < 1 {
+			usageError("Error: report id required")
+		}
+		metricsStore := openStateStore(*opts.configPath)
+		versions, err := metricsStore.ListReports()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		chain := archive.Chain(versions, flags.Arg(0))
+		if chain == nil {
+			fmt.Fprintf(os.Stderr, "Error: %v: %s\n", archive.ErrNotFound, flags.Arg(0))
+			os.Exit(1)
+		}
+		fmt.Printf("Report %s (%s), %d version(s):\n", chain[0].ID, chain[0].Type, len(chain))
+		for _, version := range chain {
+			marker := " "
+			if version.ID == flags.Arg(0) {
+				marker = "*"
+			}
+			fmt.Printf("%s v%-3d %-40s %s", marker, version.Version, version.ID, version.CreatedAt.Format(time.RFC3339))
+			if version.Reason != "" {
+				fmt.Printf("  by %s: %s", version.By, version.Reason)
+			}
+			fmt.Println()
+			exts := make([]string, 0, len(version.Digests))
+			for ext := range version.Digests {
+				exts = append(exts, ext)
+			}
+			sort.Strings(exts)
+			for _, ext := range exts {
+				fmt.Printf("        %-4s sha256:%s\n", ext, version.Digests[ext])
+			}
+		}
+	case "regenerate":
+		if flags.NArg() < 1 {
+			usageError("Error: report id required")
+		}
+		formats := parseFormats(*opts.format)
+		if err := checkOutputFormats(formats, *opts.output); err != nil {
+			usageError("Error: %v", err)
+		}
+		version, err := openStateStore(*opts.configPath).LoadReport(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		report, err := reporting.UnmarshalReport(version.Snapshot)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		artifacts, err := renderArtifacts(report, version.Type, formats, *opts.output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		// A rendering archived with the version must come out unchanged
+		var verified []string
+		for _, artifact := range artifacts {
+			ok, err := version.Verify(artifact.ext, []byte(artifact.content))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if ok {
+				verified = append(verified, artifact.ext)
+			}
+		}
+		if err := writeArtifacts(artifacts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(verified) > 0 {
+			fmt.Fprintf(os.Stderr, "Verified %s against the archived digests of %s\n", strings.Join(verified, ", "), version.ID)
+		}
+	case "correct":
+		checkWritable(*opts.configPath, "archive correct")
+		if flags.NArg() < 1 {
+			usageError("Error: report id required")
+		}
+		if *opts.reason == "" {
+			usageError("Error: --reason is required")
+		}
+		formats := parseFormats(*opts.format)
+		if err := checkOutputFormats(formats, *opts.output); err != nil {
+			usageError("Error: %v", err)
+		}
+		correctReport(*opts.configPath, flags.Arg(0), *opts.by, *opts.reason, formats, *opts.output, *opts.deliver)
+	default:
+		usageError("Unknown archive subcommand: %s", args[0])
+	}
+}
+
+// correctReport issues a correction of the archived version with id: the
+// report is rebuilt from the store as it is now, with the type, locale, time
+// zone, classification, branding and ops month of the version it
+// supersedes, and archived as the next version.
+func correctReport(configPath, id, by, reason string, formats []string, output string, deliver bool) {
+	cfg, err := config.LoadOrDefault(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	metricsStore := openStateStore(configPath)
+	versions, err := metricsStore.ListReports()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	version, err := archive.Correct(versions, id, by, reason, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	superseded, err := metricsStore.LoadReport(version.Supersedes)
+	var previous *reporting.Report
+	if err == nil {
+		previous, err = reporting.UnmarshalReport(superseded.Snapshot)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	collector := loadCollector(cfg)
+	report := buildReport(collector, previous.Locale)
+	report.ID = fmt.Sprintf("%s-v%d", strings.TrimSuffix(previous.ID, fmt.Sprintf("-v%d", superseded.Version)), version.Version)
+	report.Brand, report.Classification, report.Location = previous.Brand, previous.Classification, previous.Location
+	var month string
+	if previous.Ops != nil {
+		month = previous.Ops.Month.Format("2006-01")
+	}
+	err = addReportData(report, collector, version.Type, month, &cfg.Sources, time.Now())
+	if err == nil {
+		report.Narrative, err = narrativeData(metricsStore)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	artifacts, err := renderArtifacts(report, version.Type, formats, output)
+	if err == nil {
+		err = archiveReport(metricsStore, version, report, artifacts)
+	}
+	if err == nil {
+		err = writeArtifacts(artifacts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Archived %s, version %d of %s superseding %s\n", version.ID, version.Version, version.Original, version.Supersedes)
+
+	if deliver {
+		for _, artifact := range artifacts {
+			deliverReport(cfg, version.ID+"."+artifact.ext, report.Classification, []byte(artifact.content))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// benchCollector returns a collector with 100k metrics and a month of daily
+// history for every KPI in benchKPIs.
+func benchCollector() *metrics.MetricsCollector {
+	collector := metrics.NewMetricsCollector()
+	types := []metrics.MetricType{metrics.TypeVulnerability, metrics.TypeCompliance, metrics.TypeDetection, metrics.TypeRisk}
+	batch := make([]metrics.SecurityMetric, 100000)
+	for i := range batch {
+		batch[i] = metrics.SecurityMetric{ID: fmt.Sprintf("m-%d", i), Type: types[i%len(types)], Value: float64(i % 100), Target: 90}
+	}
+	now := time.Now()
+	var history []metrics.KPISample
+	for _, key := range benchKPIs {
+		for day := 30; day > 0; day-- {
+			history = append(history, metrics.KPISample{Key: key, Value: float64(day % 7), Timestamp: now.AddDate(0, 0, -day)})
+		}
+	}
+	collector.Restore(batch, nil, history)
+	for i, key := range benchKPIs {
+		collector.AddKPI(metrics.KPI{Key: key, Value: float64(10 * i), Target: 50})
+	}
+	return collector
+}
+
+var benchKPIs = []metrics.KPIKey{
+	metrics.KPI_MTTR, metrics.KPI_MTTC, metrics.KPI_MTTD, metrics.KPI_Coverage,
+	metrics.KPI_Compliance, metrics.KPI_RemediationRate, metrics.KPI_DetectionRate,
+	metrics.KPI_MFACoverage, metrics.KPI_DeviceCompliance, metrics.KPI_NetworkSegmentation,
+}
+
+func BenchmarkRenderCollectorReport(b *testing.B) {
+	collector := benchCollector()
+	for _, reportType := range []string{"executive", "technical", "markdown", "html", "onepager", "ops", "targets"} {
+		b.Run(reportType, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := renderCollectorReport(collector, reportType, "", nil, nil, "", nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/bundle"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// bundleOptions are the flags of the bundle subcommands.
+type bundleOptions struct {
+	configPath, output, reports, keyEnv *string
+	force                               *bool
+}
+
+// bundleFlagSet returns the flags of a bundle subcommand.
+func bundleFlagSet(subcommand string) (*flag.FlagSet, *bundleOptions) {
+	flags, configPath := configFlagSet("bundle " + subcommand)
+	opts := &bundleOptions{
+		configPath: configPath,
+		keyEnv:     flags.String("key-env", bundle.DefaultKeyEnv, "environment variable holding the base64 bundle key (see keygen)"),
+	}
+	switch subcommand {
+	case "export":
+		opts.output = flags.String("output", "secmetrics-bundle.smb", "bundle file to write")
+		opts.reports = flags.String("reports", "", "directory of reports to include")
+	case "import":
+		opts.reports = flags.String("reports", "reports", "directory to write the bundled reports to")
+		opts.force = flags.Bool("force", false, "overwrite an existing config file, store and reports")
+	}
+	return flags, opts
+}
+
+// manageBundle exports or imports an encrypted bundle of config, store and
+// reports.
+func manageBundle(args []string) {
+	if len(args) < 1 {
+		usageError("Error: bundle subcommand required (export, import)")
+	}
+
+	flags, opts := bundleFlagSet(args[0])
+	flags.Parse(args[1:])
+
+	switch args[0] {
+	case "export":
+		exportBundle(opts)
+	case "import":
+		if flags.NArg() < 1 {
+			usageError("Error: bundle file required")
+		}
+		importBundle(flags.Arg(0), opts)
+	default:
+		usageError("Unknown bundle subcommand: %s", args[0])
+	}
+}
+
+// bundleCipher creates the bundle cipher from the key in keyEnv.
+func bundleCipher(keyEnv string) *encryption.Cipher {
+	key, err := encryption.KeyFromEnv(keyEnv)
+	if err == nil {
+		var cipher *encryption.Cipher
+		if cipher, err = encryption.NewCipher(key); err == nil {
+			return cipher
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Error: bundle key: %v\n", err)
+	os.Exit(1)
+	return nil
+}
+
+func exportBundle(opts *bundleOptions) {
+	cipher := bundleCipher(*opts.keyEnv)
+	b := &bundle.Bundle{Version: version, CreatedAt: time.Now(), Reports: make(map[string][]byte)}
+
+	data, err := os.ReadFile(*opts.configPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	b.Config = data
+
+	cfg, err := config.LoadOrDefault(*opts.configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if metricsStore := openStore(cfg); metricsStore != nil {
+		if b.Snapshot, err = metricsStore.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *opts.reports != "" {
+		err := filepath.WalkDir(*opts.reports, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(*opts.reports, path)
+			if err != nil {
+				return err
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			b.Reports[filepath.ToSlash(rel)] = content
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: read reports: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	sealed, err := bundle.Seal(b, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := store.WriteFileAtomic(*opts.output, sealed, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Bundle written to", *opts.output)
+	printBundleContents(b)
+}
+
+func importBundle(path string, opts *bundleOptions) {
+	checkWritable(*opts.configPath, "bundle import")
+	cipher := bundleCipher(*opts.keyEnv)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	b, _, err := bundle.Open(data, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Resolve every destination and check for conflicts before writing
+	// anything, so a refused import changes nothing.
+	var cfg *config.Config
+	if b.Config != nil {
+		cfg, err = config.Parse(b.Config)
+	} else {
+		cfg, err = config.LoadOrDefault(*opts.configPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var metricsStore *store.FileStore
+	if b.Snapshot != nil {
+		if metricsStore = openStore(cfg); metricsStore == nil {
+			fmt.Fprintln(os.Stderr, "Error: the bundle contains a store but no store is configured (set store.path in the config file)")
+			os.Exit(1)
+		}
+	}
+	var targets []string
+	if b.Config != nil {
+		targets = append(targets, *opts.configPath)
+	}
+	if metricsStore != nil {
+		targets = append(targets, metricsStore.Path())
+	}
+	for _, name := range sortedReportNames(b.Reports) {
+		targets = append(targets, filepath.Join(*opts.reports, filepath.FromSlash(name)))
+	}
+	if !*opts.force {
+		for _, target := range targets {
+			if _, err := os.Stat(target); err == nil {
+				fmt.Fprintf(os.Stderr, "Error: %s already exists (use --force to overwrite)\n", target)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if b.Config != nil {
+		if err := store.WriteFileAtomic(*opts.configPath, b.Config, 0o600); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if metricsStore != nil {
+		if err := metricsStore.Save(b.Snapshot); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: save store: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, name := range sortedReportNames(b.Reports) {
+		target := filepath.Join(*opts.reports, filepath.FromSlash(name))
+		if err := store.WriteFileAtomic(target, b.Reports[name], 0o600); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Imported bundle created %s by secmetrics %s\n", b.CreatedAt.Format(time.RFC3339), b.Version)
+	printBundleContents(b)
+	for _, target := range targets {
+		fmt.Println("  wrote", target)
+	}
+}
+
+// printBundleContents summarizes what a bundle holds.
+func printBundleContents(b *bundle.Bundle) {
+	if b.Config != nil {
+		fmt.Println("  config")
+	}
+	if b.Snapshot != nil {
+		fmt.Printf("  store: %d metrics, %d KPIs, %d history samples, %d incidents, %d alerts\n",
+			len(b.Snapshot.Metrics), len(b.Snapshot.KPIs), len(b.Snapshot.History), len(b.Snapshot.Incidents), len(b.Snapshot.Alerts))
+	}
+	if len(b.Reports) > 0 {
+		fmt.Printf("  reports: %d files\n", len(b.Reports))
+	}
+}
+
+func sortedReportNames(reports map[string][]byte) []string {
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
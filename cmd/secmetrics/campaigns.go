@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/campaigns"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+	"github.com/hallucinaut/secmetrics/pkg/sources"
+)
+
+// campaignData builds the campaign status section from the remediation
+// campaigns configured in cfgs, as of now.
+func campaignData(cfgs []campaigns.Config, now time.Time) ([]reporting.CampaignData, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	statuses, err := sources.CampaignStatuses(cfgs, now)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]reporting.CampaignData, 0, len(statuses))
+	for _, status := range statuses {
+		campaign := reporting.CampaignData{
+			Name:      status.Name,
+			Owner:     status.Owner,
+			Start:     status.Start,
+			Deadline:  status.Deadline,
+			State:     status.State,
+			Total:     status.Total,
+			Open:      status.Open,
+			Resolved:  status.Resolved,
+			Progress:  status.Progress(),
+			Expected:  status.Expected,
+			Adherence: status.Adherence(),
+		}
+		for _, owner := range status.Owners {
+			campaign.Owners = append(campaign.Owners, reporting.CampaignOwnerData{
+				Owner:    owner.Owner,
+				Open:     owner.Open,
+				Resolved: owner.Resolved,
+				Overdue:  owner.Overdue,
+			})
+		}
+		for _, point := range status.BurnDown {
+			campaign.BurnDown = append(campaign.BurnDown, point.Open)
+		}
+		data = append(data, campaign)
+	}
+	return data, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+)
+
+// command describes a CLI command for the usage message and generated
+// documentation.
+type command struct {
+	Name string
+	// Args is the synopsis of the positional arguments, e.g. "<key>".
+	Args    string
+	Summary string
+	// Flags returns the command's flag set; nil for commands without flags.
+	Flags       func() *flag.FlagSet
+	Subcommands []command
+}
+
+// configFlagSet returns a flag set with only the --config flag.
+func configFlagSet(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	configPath := flags.String("config", config.Path(), "path to the configuration file")
+	return flags, configPath
+}
+
+// configFlags returns a Flags function for commands with only --config.
+func configFlags(name string) func() *flag.FlagSet {
+	return func() *flag.FlagSet {
+		flags, _ := configFlagSet(name)
+		return flags
+	}
+}
+
+func collectFlags() *flag.FlagSet {
+	flags, _, _ := collectFlagSet()
+	return flags
+}
+
+func reportFlags() *flag.FlagSet {
+	flags, _ := reportFlagSet()
+	return flags
+}
+
+// commands returns the command tree.
+func commands() []command {
+	reportTypes := []command{
+		{Name: "executive", Summary: "Executive summary report", Flags: reportFlags},
+		{Name: "technical", Summary: "Technical report", Flags: reportFlags},
+		{Name: "markdown", Summary: "Markdown report", Flags: reportFlags},
+		{Name: "html", Summary: "HTML report with KPI charts", Flags: reportFlags},
+		{Name: "onepager", Summary: "Executive one-pager (markdown, html or pdf)", Flags: reportFlags},
+		{Name: "ops", Summary: "Monthly operations report from the store", Flags: reportFlags},
+		{Name: "gaps", Summary: "Uncovered assets per control from the asset inventory", Flags: reportFlags},
+		{Name: "targets", Summary: "Every KPI against its target with gap, owner and forecast time to target", Flags: reportFlags},
+	}
+	kpiFlags := func(subcommand string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _ := kpiFlagSet(subcommand)
+			return flags
+		}
+	}
+	runsFlags := func(subcommand string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _, _ := runsFlagSet(subcommand)
+			return flags
+		}
+	}
+	metricFlags := func(subcommand string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _ := metricFlagSet(subcommand)
+			return flags
+		}
+	}
+	exportFlags := func(subject string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _, _, _ := exportFlagSet(subject)
+			return flags
+		}
+	}
+	importFlags := func(subject string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _, _, _ := importFlagSet(subject)
+			return flags
+		}
+	}
+	bundleFlags := func(subcommand string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _ := bundleFlagSet(subcommand)
+			return flags
+		}
+	}
+	silenceFlags := func(subcommand string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _ := silenceFlagSet(subcommand)
+			return flags
+		}
+	}
+	approvalFlags := func(subcommand string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _ := approvalFlagSet(subcommand)
+			return flags
+		}
+	}
+	archiveFlags := func(subcommand string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _ := archiveFlagSet(subcommand)
+			return flags
+		}
+	}
+	narrativeFlags := func(subcommand string) func() *flag.FlagSet {
+		return func() *flag.FlagSet {
+			flags, _ := narrativeFlagSet(subcommand)
+			return flags
+		}
+	}
+	serveFlags := func() *flag.FlagSet {
+		flags, _ := serveFlagSet()
+		return flags
+	}
+
+	return []command{
+		{Name: "collect", Summary: "Collect security metrics", Flags: collectFlags},
+		{Name: "kpis", Summary: "Show security KPIs", Flags: configFlags("kpis")},
+		{Name: "report", Summary: "Generate metrics report", Subcommands: reportTypes},
+		{Name: "summary", Summary: "Show metrics summary", Flags: configFlags("summary")},
+		{Name: "health", Summary: "Check security health status", Flags: configFlags("health")},
+		{Name: "explain", Args: "<key> | metric <id>", Summary: "Explain a KPI and surface likely root causes, or trace a metric to its sources", Flags: configFlags("explain")},
+		{Name: "kpi", Summary: "Manage stored KPIs (list, archive, remove, lifecycle)", Subcommands: []command{
+			{Name: "list", Summary: "List stored KPIs", Flags: kpiFlags("list")},
+			{Name: "archive", Args: "<key>", Summary: "Archive a KPI, keeping its history", Flags: kpiFlags("archive")},
+			{Name: "remove", Args: "<key>", Summary: "Remove a KPI and its history", Flags: kpiFlags("remove")},
+			{Name: "lifecycle", Args: "<key> <draft|active|deprecated>", Summary: "Pilot, activate or deprecate a KPI, recorded in the audit trail", Flags: kpiFlags("lifecycle")},
+		}},
+		{Name: "audit", Summary: "Show the audit trail of KPI lifecycle changes and attached evidence", Flags: configFlags("audit")},
+		{Name: "runs", Summary: "Show the history of collection runs and how each source fared (list, show)", Subcommands: []command{
+			{Name: "list", Summary: "List recent collection runs, newest first", Flags: runsFlags("list")},
+			{Name: "show", Args: "[<run>]", Summary: "Show a run's sources, records and errors; the latest run by default", Flags: runsFlags("show")},
+		}},
+		{Name: "recalculate", Summary: "Rebuild the stored score history under the configured score model", Flags: func() *flag.FlagSet {
+			flags, _, _ := recalculateFlagSet()
+			return flags
+		}},
+		{Name: "metric", Summary: "Manage stored metrics (list, remove, attach)", Subcommands: []command{
+			{Name: "list", Summary: "List stored metrics with their evidence", Flags: metricFlags("list")},
+			{Name: "remove", Args: "<id>", Summary: "Remove a metric", Flags: metricFlags("remove")},
+			{Name: "attach", Args: "<id>", Summary: "Attach evidence to the latest sample of a metric, recorded in the audit trail", Flags: metricFlags("attach")},
+		}},
+		{Name: "silence", Summary: "Mute alert notifications, e.g. for maintenance windows (create, list, expire)", Subcommands: []command{
+			{Name: "create", Summary: "Create a silence for all alerts or some KPIs or categories", Flags: silenceFlags("create")},
+			{Name: "list", Summary: "List active, pending and expired silences", Flags: silenceFlags("list")},
+			{Name: "expire", Args: "<id>", Summary: "End a silence now", Flags: silenceFlags("expire")},
+		}},
+		{Name: "approval", Summary: "Review scheduled reports before delivery (list, show, submit, approve, reject)", Subcommands: []command{
+			{Name: "list", Summary: "List reports in the approval workflow, newest first", Flags: approvalFlags("list")},
+			{Name: "show", Args: "<id>", Summary: "Show a report's history, approvals and content", Flags: approvalFlags("show")},
+			{Name: "submit", Args: "<id>", Summary: "Submit a draft report for review", Flags: approvalFlags("submit")},
+			{Name: "approve", Args: "<id>", Summary: "Approve a report in review; the daemon delivers approved reports", Flags: approvalFlags("approve")},
+			{Name: "reject", Args: "<id>", Summary: "Return a report in review to draft", Flags: approvalFlags("reject")},
+		}},
+		{Name: "archive", Summary: "Regenerate and correct archived reports (list, show, regenerate, correct)", Subcommands: []command{
+			{Name: "list", Summary: "List archived report versions, newest first", Flags: archiveFlags("list")},
+			{Name: "show", Args: "<id>", Summary: "Show the versions of a report and their digests", Flags: archiveFlags("show")},
+			{Name: "regenerate", Args: "<id>", Summary: "Render an archived version again from its snapshot, in any format of its type", Flags: archiveFlags("regenerate")},
+			{Name: "correct", Args: "<id>", Summary: "Issue a corrected version of a report from the current store", Flags: archiveFlags("correct")},
+		}},
+		{Name: "narrative", Summary: "Draft, review and approve quarterly report narratives (draft, show, approve)", Subcommands: []command{
+			{Name: "draft", Summary: "Draft a quarter's narrative through the summarization endpoint", Flags: narrativeFlags("draft")},
+			{Name: "show", Summary: "Show a quarter's narrative", Flags: narrativeFlags("show")},
+			{Name: "approve", Summary: "Approve a reviewed narrative for use in reports", Flags: narrativeFlags("approve")},
+		}},
+		{Name: "migrate", Summary: "Show or apply store schema migrations (status, up)", Subcommands: []command{
+			{Name: "status", Summary: "Show the store schema version and pending migrations", Flags: configFlags("migrate status")},
+			{Name: "up", Summary: "Apply pending migrations", Flags: configFlags("migrate up")},
+		}},
+		{Name: "export", Summary: "Export stored metrics, state, coverage, compliance or history (metrics --format openmetrics, state --format terraform-json, coverage --format attack-navigator, compliance --format oscal, history --format parquet)", Subcommands: []command{
+			{Name: "metrics", Summary: "Export stored metrics and KPIs", Flags: exportFlags("metrics")},
+			{Name: "state", Summary: "Export KPI definitions, targets and alert rules as stable JSON", Flags: exportFlags("state")},
+			{Name: "coverage", Summary: "Export detection coverage as an ATT&CK Navigator layer", Flags: exportFlags("coverage")},
+			{Name: "compliance", Summary: "Export compliance results as OSCAL assessment results", Flags: exportFlags("compliance")},
+			{Name: "history", Summary: "Export KPI history as Parquet for pandas, Spark or DuckDB", Flags: exportFlags("history")},
+		}},
+		{Name: "import", Summary: "Import metric samples, incidents or alerts (metrics|incidents|alerts <file>)", Subcommands: []command{
+			{Name: "metrics", Args: "<file>", Summary: "Import OpenMetrics samples (- for stdin)", Flags: importFlags("metrics")},
+			{Name: "incidents", Args: "<file>", Summary: "Import incidents as JSON or JSON lines (- for stdin)", Flags: importFlags("incidents")},
+			{Name: "alerts", Args: "<file>", Summary: "Import alerts as JSON or JSON lines (- for stdin)", Flags: importFlags("alerts")},
+		}},
+		{Name: "bundle", Summary: "Export or import an encrypted bundle of config, store and reports", Subcommands: []command{
+			{Name: "export", Summary: "Write config, store and reports to an encrypted bundle", Flags: bundleFlags("export")},
+			{Name: "import", Args: "<file>", Summary: "Restore config, store and reports from a bundle", Flags: bundleFlags("import")},
+		}},
+		{Name: "devtools", Summary: "Developer tools (generate)", Subcommands: []command{
+			{Name: "generate", Summary: "Write a store file with N KPIs x M days x K teams of synthetic history for load testing", Flags: devtoolsGenerateFlags},
+		}},
+		{Name: "serve", Summary: "Run the daemon: scheduled collection and HTTP API", Flags: serveFlags},
+		{Name: "service", Summary: "Install the daemon as a systemd unit or Windows service (install)", Subcommands: []command{
+			{Name: "install", Summary: "Register serve as a systemd unit or Windows service and start it", Flags: func() *flag.FlagSet {
+				flags, _ := serviceFlagSet()
+				return flags
+			}},
+		}},
+		{Name: "keygen", Summary: "Generate an encryption key for data at rest"},
+		{Name: "docs", Summary: "Generate man pages, a CLI spec, the API's OpenAPI document or the environment variable list (man, spec, openapi, env)", Subcommands: []command{
+			{Name: "man", Summary: "Write man pages for every command", Flags: docsManFlags},
+			{Name: "spec", Summary: "Print a machine-readable command and flag spec", Flags: docsSpecFlags},
+			{Name: "openapi", Summary: "Print the OpenAPI 3 document of the serve API", Flags: docsOpenAPIFlags},
+			{Name: "env", Summary: "List the environment variables that override configuration settings"},
+		}},
+		{Name: "update", Summary: "Update secmetrics to the latest signed release", Flags: func() *flag.FlagSet {
+			flags, _, _ := updateFlagSet()
+			return flags
+		}},
+		{Name: "version", Summary: "Show version information"},
+		{Name: "help", Summary: "Show this help message"},
+	}
+}
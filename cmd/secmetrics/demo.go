@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/demo"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// demoMode is set by the global --demo flag. Commands then show the
+// synthetic demo dataset instead of the store and never write the store.
+var demoMode bool
+
+// demoSource returns the collection source of the demo dataset, generated
+// once and loaded on every collection run.
+func demoSource() server.Source {
+	dataset := demo.Generate(demo.Options{Seed: demo.DefaultSeed, End: time.Now()})
+	return server.Source{
+		Name: "demo",
+		Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			return dataset.Load(collector)
+		},
+	}
+}
+
+// loadCollector returns a collector with the data commands report on: the
+// demo dataset with --demo, otherwise the contents of the configured
+// store, or nothing without one.
+func loadCollector(cfg *config.Config) *metrics.MetricsCollector {
+	collector := newCollector(cfg)
+	if demoMode {
+		if err := demoSource().Collect(context.Background(), collector); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return collector
+	}
+	if metricsStore := openStore(cfg); metricsStore != nil {
+		if err := metricsStore.LoadInto(collector); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	return collector
+}
+
+// noDataHint is printed by commands that found nothing to show.
+const noDataHint = "No KPIs collected yet. Run `secmetrics collect` with a store configured, or try `secmetrics --demo`."
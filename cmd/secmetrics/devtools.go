@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/demo"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// generateOptions are the flags of devtools generate.
+type generateOptions struct {
+	kpis, days, teams *int
+	seed              *int64
+	output            *string
+	force             *bool
+}
+
+// devtoolsGenerateFlagSet returns the flags of devtools generate.
+func devtoolsGenerateFlagSet() (*flag.FlagSet, *generateOptions) {
+	flags := flag.NewFlagSet("devtools generate", flag.ExitOnError)
+	opts := &generateOptions{
+		kpis:   flags.Int("kpis", 10, "KPIs per team"),
+		days:   flags.Int("days", 90, "days of daily history"),
+		teams:  flags.Int("teams", 1, "teams, each with its own KPIs, metrics and events"),
+		seed:   flags.Int64("seed", demo.DefaultSeed, "random seed; the same seed generates the same data"),
+		output: flags.String("output", "secmetrics-load.json", "store file to write"),
+		force:  flags.Bool("force", false, "overwrite an existing store file"),
+	}
+	return flags, opts
+}
+
+func devtoolsGenerateFlags() *flag.FlagSet {
+	flags, _ := devtoolsGenerateFlagSet()
+	return flags
+}
+
+// runDevtools runs the developer tools.
+func runDevtools(args []string) {
+	if len(args) < 1 {
+		usageError("Error: devtools subcommand required (generate)")
+	}
+
+	switch args[0] {
+	case "generate":
+		flags, opts := devtoolsGenerateFlagSet()
+		flags.Parse(args[1:])
+		generateLoadStore(opts)
+	default:
+		usageError("Unknown devtools subcommand: %s", args[0])
+	}
+}
+
+// generateLoadStore writes a store file with a synthetic load test
+// dataset.
+func generateLoadStore(opts *generateOptions) {
+	if *opts.kpis < 1 || *opts.days < 1 || *opts.teams < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --kpis, --days and --teams must be at least 1")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(*opts.output); err == nil && !*opts.force {
+		fmt.Fprintf(os.Stderr, "Error: %s exists (use --force to overwrite)\n", *opts.output)
+		os.Exit(1)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	dataset := demo.GenerateLoad(demo.LoadOptions{
+		Seed: *opts.seed, KPIs: *opts.kpis, Days: *opts.days, Teams: *opts.teams, End: time.Now().UTC(),
+	})
+	snapshot := &store.Snapshot{
+		SchemaVersion: store.CurrentSchemaVersion,
+		Metrics:       dataset.Metrics,
+		KPIs:          dataset.KPIs,
+		History:       dataset.History,
+		Incidents:     dataset.Incidents,
+		Alerts:        dataset.Alerts,
+	}
+	if err := store.NewFileStore(*opts.output, nil).Save(snapshot); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Wrote %s\n", *opts.output)
+	fmt.Printf("  KPIs:      %d\n", len(dataset.KPIs))
+	fmt.Printf("  Samples:   %d\n", len(dataset.History))
+	fmt.Printf("  Metrics:   %d\n", len(dataset.Metrics))
+	fmt.Printf("  Incidents: %d\n", len(dataset.Incidents))
+	fmt.Printf("  Alerts:    %d\n", len(dataset.Alerts))
+}
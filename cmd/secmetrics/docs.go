@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// cliSpec is the machine-readable description of the command tree.
+type cliSpec struct {
+	Name     string        `json:"name"`
+	Version  string        `json:"version"`
+	Commands []commandSpec `json:"commands"`
+}
+
+// commandSpec describes a command, its flags and its subcommands.
+type commandSpec struct {
+	Name        string        `json:"name"`
+	Usage       string        `json:"usage"`
+	Summary     string        `json:"summary"`
+	Args        string        `json:"args,omitempty"`
+	Flags       []flagSpec    `json:"flags,omitempty"`
+	Subcommands []commandSpec `json:"subcommands,omitempty"`
+}
+
+// flagSpec describes a flag.
+type flagSpec struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default"`
+	Usage   string `json:"usage"`
+}
+
+// docsManFlagSet returns the flags of docs man.
+func docsManFlagSet() (flags *flag.FlagSet, output *string) {
+	flags = flag.NewFlagSet("docs man", flag.ExitOnError)
+	output = flags.String("output", ".", "directory to write the man pages to")
+	return flags, output
+}
+
+// docsSpecFlagSet returns the flags of docs spec.
+func docsSpecFlagSet() (flags *flag.FlagSet, format, output *string) {
+	flags = flag.NewFlagSet("docs spec", flag.ExitOnError)
+	format = flags.String("format", "json", "spec format (json)")
+	output = flags.String("output", "", "write to this file instead of stdout")
+	return flags, format, output
+}
+
+// docsOpenAPIFlagSet returns the flags of docs openapi.
+func docsOpenAPIFlagSet() (flags *flag.FlagSet, configPath, output *string) {
+	flags, configPath = configFlagSet("docs openapi")
+	output = flags.String("output", "", "write to this file instead of stdout")
+	return flags, configPath, output
+}
+
+func docsManFlags() *flag.FlagSet {
+	flags, _ := docsManFlagSet()
+	return flags
+}
+
+func docsSpecFlags() *flag.FlagSet {
+	flags, _, _ := docsSpecFlagSet()
+	return flags
+}
+
+func docsOpenAPIFlags() *flag.FlagSet {
+	flags, _, _ := docsOpenAPIFlagSet()
+	return flags
+}
+
+// generateDocs writes man pages or the CLI spec generated from the command
+// tree, the OpenAPI document or the configuration environment variables.
+func generateDocs(args []string) {
+	if len(args) < 1 {
+		usageError("Error: docs subcommand required (man, spec, openapi, env)")
+	}
+
+	switch args[0] {
+	case "man":
+		flags, output := docsManFlagSet()
+		flags.Parse(args[1:])
+		if err := os.MkdirAll(*output, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		pages := manPages(commands(), manDate())
+		for _, name := range sortedPageNames(pages) {
+			if err := os.WriteFile(filepath.Join(*output, name), []byte(pages[name]), 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Wrote %d man pages to %s\n", len(pages), *output)
+	case "spec":
+		flags, format, output := docsSpecFlagSet()
+		flags.Parse(args[1:])
+		if *format != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unsupported spec format: %s\n", *format)
+			os.Exit(1)
+		}
+		writeJSONDoc(buildSpec(commands()), *output)
+	case "openapi":
+		flags, configPath, output := docsOpenAPIFlagSet()
+		flags.Parse(args[1:])
+		cfg, err := config.LoadOrDefault(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		writeJSONDoc(server.OpenAPI(version, cfg.Server), *output)
+	case "env":
+		for _, env := range config.EnvVars() {
+			fmt.Printf("%-56s %s\n", env.Name, env.Type)
+		}
+	default:
+		usageError("Unknown docs subcommand: %s", args[0])
+	}
+}
+
+// writeJSONDoc writes v as indented JSON to output, or stdout when output
+// is empty.
+func writeJSONDoc(v interface{}, output string) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// buildSpec describes the command tree.
+func buildSpec(cmds []command) cliSpec {
+	spec := cliSpec{Name: "secmetrics", Version: version}
+	for _, cmd := range cmds {
+		spec.Commands = append(spec.Commands, commandSpecFor("secmetrics", cmd))
+	}
+	return spec
+}
+
+func commandSpecFor(parent string, cmd command) commandSpec {
+	path := parent + " " + cmd.Name
+	spec := commandSpec{
+		Name:    cmd.Name,
+		Usage:   synopsis(path, cmd),
+		Summary: cmd.Summary,
+		Args:    cmd.Args,
+		Flags:   flagSpecs(cmd),
+	}
+	for _, sub := range cmd.Subcommands {
+		spec.Subcommands = append(spec.Subcommands, commandSpecFor(path, sub))
+	}
+	return spec
+}
+
+// synopsis returns the usage line of the command at path.
+func synopsis(path string, cmd command) string {
+	usage := path
+	if len(cmd.Subcommands) > 0 {
+		usage += " <command>"
+	}
+	if cmd.Flags != nil {
+		usage += " [flags]"
+	}
+	if cmd.Args != "" {
+		usage += " " + cmd.Args
+	}
+	return usage
+}
+
+// flagSpecs describes a command's flags in name order.
+func flagSpecs(cmd command) []flagSpec {
+	if cmd.Flags == nil {
+		return nil
+	}
+	var specs []flagSpec
+	cmd.Flags().VisitAll(func(f *flag.Flag) {
+		specs = append(specs, flagSpec{Name: f.Name, Type: flagType(f), Default: f.DefValue, Usage: f.Usage})
+	})
+	return specs
+}
+
+// flagType returns the value type of a flag, e.g. "string" or "bool".
+func flagType(f *flag.Flag) string {
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return "bool"
+	}
+	if name, _ := flag.UnquoteUsage(f); name != "" {
+		return name
+	}
+	return "value"
+}
+
+// manDate returns the date stamped on man pages, honouring
+// SOURCE_DATE_EPOCH for reproducible package builds.
+func manDate() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if seconds, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(seconds, 0).UTC()
+		}
+	}
+	return time.Now()
+}
+
+// manPages renders secmetrics(1) and a page per command, keyed by file
+// name. Subcommands are documented on their parent's page.
+func manPages(cmds []command, date time.Time) map[string]string {
+	header := func(title string) string {
+		return fmt.Sprintf(".TH %q 1 %q %q %q\n", strings.ToUpper(title), date.Format("January 2006"), "secmetrics "+version, "secmetrics Manual")
+	}
+	pages := make(map[string]string)
+
+	var b strings.Builder
+	b.WriteString(header("secmetrics"))
+	b.WriteString(".SH NAME\nsecmetrics \\- security metrics and KPI dashboard\n")
+	b.WriteString(".SH SYNOPSIS\n.B secmetrics\n[\\fB\\-\\-read\\-only\\fR] \\fIcommand\\fR [\\fIflags\\fR]\n")
+	b.WriteString(".SH COMMANDS\n")
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, ".TP\n.BR secmetrics\\-%s (1)\n%s\n", roffEscape(cmd.Name), roffEscape(cmd.Summary))
+	}
+	b.WriteString(".SH OPTIONS\n.TP\n.B \\-\\-read\\-only\nDisable every change to the store, config and binary.\n")
+	b.WriteString(".SH ENVIRONMENT\n.TP\n.B SECMETRICS_CONFIG\nPath of the configuration file used when \\fB\\-\\-config\\fR is not given.\n" +
+		".TP\n.B SECMETRICS_<SETTING>\nOverrides a configuration setting; see \\fBsecmetrics docs env\\fR for the names.\n")
+	pages["secmetrics.1"] = b.String()
+
+	for _, cmd := range cmds {
+		name := "secmetrics-" + cmd.Name
+		b.Reset()
+		b.WriteString(header(name))
+		fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", roffEscape(name), roffEscape(cmd.Summary))
+		b.WriteString(".SH SYNOPSIS\n")
+		leaves := []command{cmd}
+		path := "secmetrics " + cmd.Name
+		if len(cmd.Subcommands) > 0 {
+			leaves = cmd.Subcommands
+		}
+		for i, leaf := range leaves {
+			leafPath := path
+			if len(cmd.Subcommands) > 0 {
+				leafPath += " " + leaf.Name
+			}
+			if i > 0 {
+				b.WriteString(".br\n")
+			}
+			b.WriteString(roffEscape(synopsis(leafPath, leaf)) + "\n")
+		}
+		if len(cmd.Subcommands) > 0 {
+			b.WriteString(".SH COMMANDS\n")
+			for _, sub := range cmd.Subcommands {
+				fmt.Fprintf(&b, ".TP\n.B %s\n%s\n", roffEscape(sub.Name), roffEscape(sub.Summary))
+			}
+		}
+		if flags := manFlags(leaves); len(flags) > 0 {
+			b.WriteString(".SH OPTIONS\n")
+			for _, f := range flags {
+				fmt.Fprintf(&b, ".TP\n\\fB\\-\\-%s\\fR", roffEscape(f.Name))
+				if f.Type != "bool" {
+					fmt.Fprintf(&b, " \\fI%s\\fR", roffEscape(f.Type))
+				}
+				b.WriteString("\n" + roffEscape(f.Usage))
+				if f.Default != "" && f.Default != "false" {
+					fmt.Fprintf(&b, " (default %s)", roffEscape(f.Default))
+				}
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(".SH SEE ALSO\n.BR secmetrics (1)\n")
+		pages[name+".1"] = b.String()
+	}
+	return pages
+}
+
+// manFlags returns the flags of commands, without duplicates.
+func manFlags(cmds []command) []flagSpec {
+	seen := make(map[string]bool)
+	var flags []flagSpec
+	for _, cmd := range cmds {
+		for _, f := range flagSpecs(cmd) {
+			if !seen[f.Name] {
+				seen[f.Name] = true
+				flags = append(flags, f)
+			}
+		}
+	}
+	return flags
+}
+
+// roffEscape escapes text for a roff line.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// sortedPageNames returns the page file names in a stable order.
+func sortedPageNames(pages map[string]string) []string {
+	names := make([]string, 0, len(pages))
+	for name := range pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dispatchedCommands returns the commands main dispatches on, read from
+// the top-level switch in main.go.
+func dispatchedCommands(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "main" {
+			continue
+		}
+		for _, stmt := range fn.Body.List {
+			sw, ok := stmt.(*ast.SwitchStmt)
+			if !ok {
+				continue
+			}
+			for _, clause := range sw.Body.List {
+				for _, expr := range clause.(*ast.CaseClause).List {
+					if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						name, _ := strconv.Unquote(lit.Value)
+						names = append(names, name)
+					}
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		t.Fatal("found no commands dispatched in main")
+	}
+	return names
+}
+
+func TestCommandTreeCoversDispatchedCommands(t *testing.T) {
+	known := make(map[string]bool)
+	for _, cmd := range commands() {
+		known[cmd.Name] = true
+	}
+	for _, name := range dispatchedCommands(t) {
+		if strings.HasPrefix(name, "-") || name == "help" {
+			continue
+		}
+		if !known[name] {
+			t.Errorf("command %q is dispatched by main but missing from the command tree", name)
+		}
+	}
+}
+
+func TestDocsSpecCoversCommandTree(t *testing.T) {
+	var check func(path string, cmds []command, specs []commandSpec)
+	check = func(path string, cmds []command, specs []commandSpec) {
+		if len(specs) != len(cmds) {
+			t.Errorf("%s: spec has %d commands, want %d", path, len(specs), len(cmds))
+			return
+		}
+		for i, cmd := range cmds {
+			spec := specs[i]
+			if spec.Name != cmd.Name || spec.Summary != cmd.Summary || spec.Args != cmd.Args {
+				t.Errorf("%s: spec %+v describes %s", path, spec, cmd.Name)
+			}
+			if spec.Usage != synopsis(path+" "+cmd.Name, cmd) {
+				t.Errorf("%s %s: usage %q", path, cmd.Name, spec.Usage)
+			}
+			if cmd.Flags != nil {
+				var count int
+				cmd.Flags().VisitAll(func(f *flag.Flag) { count++ })
+				if len(spec.Flags) != count {
+					t.Errorf("%s %s: spec has %d flags, want %d", path, cmd.Name, len(spec.Flags), count)
+				}
+			}
+			check(path+" "+cmd.Name, cmd.Subcommands, spec.Subcommands)
+		}
+	}
+	spec := buildSpec(commands())
+	if spec.Name != "secmetrics" || spec.Version != version {
+		t.Errorf("spec of %s %s", spec.Name, spec.Version)
+	}
+	check("secmetrics", commands(), spec.Commands)
+}
+
+func TestDocsManCoversCommandTree(t *testing.T) {
+	date := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	pages := manPages(commands(), date)
+	if len(pages) != len(commands())+1 {
+		t.Errorf("%d man pages for %d commands", len(pages), len(commands()))
+	}
+	index := pages["secmetrics.1"]
+	for _, cmd := range commands() {
+		if !strings.Contains(index, ".BR secmetrics\\-"+roffEscape(cmd.Name)+" (1)\n") {
+			t.Errorf("secmetrics(1) does not list %s", cmd.Name)
+		}
+		name := "secmetrics-" + cmd.Name + ".1"
+		page, ok := pages[name]
+		if !ok {
+			t.Errorf("no man page for %s", cmd.Name)
+			continue
+		}
+		if !strings.HasPrefix(page, `.TH "SECMETRICS-`+strings.ToUpper(cmd.Name)+`" 1 "October 2026"`) {
+			t.Errorf("%s header: %q", name, page[:strings.Index(page, "\n")])
+		}
+
+		leaves := []command{cmd}
+		if len(cmd.Subcommands) > 0 {
+			leaves = cmd.Subcommands
+			for _, sub := range cmd.Subcommands {
+				if !strings.Contains(page, ".TP\n.B "+roffEscape(sub.Name)+"\n"+roffEscape(sub.Summary)+"\n") {
+					t.Errorf("%s does not describe %s %s", name, cmd.Name, sub.Name)
+				}
+				if !strings.Contains(page, roffEscape(synopsis("secmetrics "+cmd.Name+" "+sub.Name, sub))+"\n") {
+					t.Errorf("%s lacks the synopsis of %s %s", name, cmd.Name, sub.Name)
+				}
+			}
+		}
+		for _, f := range manFlags(leaves) {
+			if !strings.Contains(page, ".TP\n\\fB\\-\\-"+roffEscape(f.Name)+"\\fR") {
+				t.Errorf("%s does not document --%s", name, f.Name)
+			}
+		}
+	}
+}
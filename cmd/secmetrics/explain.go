@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+)
+
+func explainKPI(args []string) {
+	if len(args) < 1 {
+		usageError("Error: KPI key required")
+	}
+
+	flags, configPath := configFlagSet("explain")
+	flags.Parse(args[1:])
+	key := metrics.KPIKey(args[0])
+
+	collector := explainCollector(*configPath)
+	explanation, err := collector.ExplainKPI(key, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(collector.FormatExplanation(explanation))
+}
+
+// explainMetric traces the stored samples of a metric to the sources,
+// collection runs and raw records they came from.
+func explainMetric(args []string) {
+	if len(args) < 1 {
+		usageError("Error: metric id required")
+	}
+
+	flags, configPath := configFlagSet("explain metric")
+	flags.Parse(args[1:])
+
+	cfg, err := config.LoadOrDefault(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	explanation, err := loadCollector(cfg).ExplainMetric(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	location, _ := reporting.ParseTimeZone(cfg.Report.TimeZone)
+	fmt.Print(metrics.FormatMetricExplanation(explanation, location))
+}
+
+// explainCollector loads the stored KPIs, or the demo dataset with --demo.
+func explainCollector(configPath string) *metrics.MetricsCollector {
+	cfg, err := config.LoadOrDefault(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return loadCollector(cfg)
+}
+
+// rootCauseData converts an explanation's root-cause hints for reporting.
+func rootCauseData(explanation *metrics.KPIExplanation) []reporting.RootCauseData {
+	var result []reporting.RootCauseData
+	for _, hint := range explanation.RootCauses {
+		path := make([]string, len(hint.Path))
+		for i, key := range hint.Path {
+			path[i] = string(key)
+		}
+		result = append(result, reporting.RootCauseData{Name: hint.KPI.Name, Path: path, Reasons: hint.Reasons})
+	}
+	return result
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func FuzzDecodeRecords(f *testing.F) {
+	for _, name := range []string{"testdata/incidents.json", "testdata/alerts.jsonl"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		collector := metrics.NewMetricsCollector()
+		decodeRecords(bytes.NewReader(data), collector.AddIncident)
+		decodeRecords(bytes.NewReader(data), collector.AddAlert)
+		collector.GetSummary()
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+	"github.com/hallucinaut/secmetrics/pkg/sources"
+)
+
+// gapData builds the coverage gap analysis from the asset inventory and
+// control coverage exports configured in inventory.
+func gapData(inventory *sources.InventoryConfig) (*reporting.GapData, error) {
+	if inventory == nil {
+		return nil, fmt.Errorf("the gaps report requires an asset inventory (set sources.inventory in the config file)")
+	}
+	analysis, err := sources.AnalyzeGaps(*inventory)
+	if err != nil {
+		return nil, err
+	}
+	data := &reporting.GapData{Assets: len(analysis.Assets), Target: inventory.Target}
+	if data.Target == 0 {
+		data.Target = sources.DefaultCoverageTarget
+	}
+	for _, gap := range analysis.Controls {
+		control := reporting.ControlGapData{
+			Name:       gap.Control,
+			Applicable: gap.Applicable,
+			Covered:    gap.Covered,
+			Coverage:   gap.Coverage(),
+		}
+		for _, asset := range gap.Uncovered {
+			control.Uncovered = append(control.Uncovered, reporting.GapAssetData{
+				ID:          asset.ID,
+				Name:        asset.Name,
+				Type:        asset.Type,
+				Owner:       asset.Owner,
+				Criticality: asset.Criticality,
+			})
+		}
+		data.Controls = append(data.Controls, control)
+	}
+	return data, nil
+}
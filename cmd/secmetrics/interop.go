@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/attack"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/history"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/openmetrics"
+	"github.com/hallucinaut/secmetrics/pkg/oscal"
+	"github.com/hallucinaut/secmetrics/pkg/state"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// exportFlagSet returns the flags of an export subcommand.
+func exportFlagSet(subject string) (flags *flag.FlagSet, configPath, format, output *string) {
+	flags = flag.NewFlagSet("export "+subject, flag.ExitOnError)
+	configPath = flags.String("config", config.Path(), "path to the configuration file")
+	defaultFormat := "openmetrics"
+	switch subject {
+	case "state":
+		defaultFormat = "terraform-json"
+	case "coverage":
+		defaultFormat = "attack-navigator"
+	case "compliance":
+		defaultFormat = "oscal"
+	case "history":
+		defaultFormat = "parquet"
+	}
+	format = flags.String("format", defaultFormat, "output format (openmetrics for metrics, terraform-json for state, attack-navigator for coverage, oscal for compliance, parquet for history)")
+	output = flags.String("output", "", "write to this file instead of stdout")
+	return flags, configPath, format, output
+}
+
+// exportData writes stored state in an interoperable format.
+func exportData(args []string) {
+	if len(args) < 1 {
+		usageError("Error: export subject required (metrics, state, coverage, compliance, history)")
+	}
+
+	flags, configPath, format, output := exportFlagSet(args[0])
+	flags.Parse(args[1:])
+
+	var metricsStore *store.FileStore
+	var export func(io.Writer, *metrics.MetricsCollector) error
+	switch {
+	case args[0] == "metrics" && *format == "openmetrics":
+		export = openmetrics.Export
+	case args[0] == "state" && *format == "terraform-json":
+		export = state.Export
+	case args[0] == "coverage" && *format == "attack-navigator":
+		export = attack.Export
+	case args[0] == "compliance" && *format == "oscal":
+		export = func(w io.Writer, collector *metrics.MetricsCollector) error {
+			cfg, err := config.LoadOrDefault(*configPath)
+			if err != nil {
+				return err
+			}
+			return oscal.Export(w, collector, cfg.OSCAL, version, time.Now())
+		}
+	case args[0] == "history" && *format == "parquet":
+		export = func(w io.Writer, collector *metrics.MetricsCollector) error {
+			samples := collector.GetHistory()
+			// History older than the window is only in the history database.
+			if metricsStore.History() != nil {
+				older, err := metricsStore.History().Query(context.Background(), time.Unix(0, 0), time.Now().Add(time.Minute))
+				if err != nil {
+					return fmt.Errorf("history database: %w", err)
+				}
+				samples = append(older, samples...)
+			}
+			if len(samples) == 0 {
+				return fmt.Errorf("no KPI history in %s", metricsStore.Path())
+			}
+			return history.ExportParquet(w, collector.HistoryDimensions().Rows(samples), "secmetrics version "+version)
+		}
+	case args[0] == "metrics" || args[0] == "state" || args[0] == "coverage" || args[0] == "compliance" || args[0] == "history":
+		fmt.Fprintf(os.Stderr, "Error: unsupported export format: %s\n", *format)
+		os.Exit(1)
+	default:
+		usageError("Unknown export subject: %s", args[0])
+	}
+
+	metricsStore, collector := loadStoredCollector(*configPath)
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := export(w, collector); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// importFlagSet returns the flags of an import subcommand.
+func importFlagSet(subject string) (flags *flag.FlagSet, configPath, format, metricType *string) {
+	flags = flag.NewFlagSet("import "+subject, flag.ExitOnError)
+	configPath = flags.String("config", config.Path(), "path to the configuration file")
+	format = flags.String("format", "", "input format (openmetrics for metrics, json for incidents and alerts)")
+	metricType = flags.String("type", string(metrics.TypeDetection), "metric type for samples without a type label")
+	return flags, configPath, format, metricType
+}
+
+// importData reads samples in an interoperable format into the store.
+func importData(args []string) {
+	if len(args) < 1 {
+		usageError("Error: import subject required (metrics, incidents, alerts)")
+	}
+
+	flags, configPath, format, metricType := importFlagSet(args[0])
+	flags.Parse(args[1:])
+	checkWritable(*configPath, "import")
+
+	switch args[0] {
+	case "metrics":
+		if *format != "" && *format != "openmetrics" {
+			fmt.Fprintf(os.Stderr, "Error: unsupported import format: %s\n", *format)
+			os.Exit(1)
+		}
+		r := importInput(flags)
+		defer r.Close()
+		samples, err := openmetrics.Parse(r)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		metricsStore, collector := loadStoredCollector(*configPath)
+		collector.SetProvenance(metrics.Provenance{Source: "import", RunID: metrics.NewRunID(time.Now())})
+		n, err := openmetrics.Import(collector, samples, openmetrics.ImportOptions{Type: metrics.MetricType(*metricType)})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveStoredCollector(metricsStore, collector)
+		fmt.Printf("Imported %d samples into %s\n", n, metricsStore.Path())
+	case "incidents", "alerts":
+		if *format != "" && *format != "json" {
+			fmt.Fprintf(os.Stderr, "Error: unsupported import format: %s\n", *format)
+			os.Exit(1)
+		}
+		r := importInput(flags)
+		defer r.Close()
+
+		metricsStore, collector := loadStoredCollector(*configPath)
+		var n int
+		var err error
+		if args[0] == "incidents" {
+			n, err = decodeRecords(r, collector.AddIncident)
+		} else {
+			n, err = decodeRecords(r, collector.AddAlert)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveStoredCollector(metricsStore, collector)
+		fmt.Printf("Imported %d %s into %s\n", n, args[0], metricsStore.Path())
+	default:
+		usageError("Unknown import subject: %s", args[0])
+	}
+}
+
+// importInput opens the input file named by the first argument, or stdin
+// for "-".
+func importInput(flags *flag.FlagSet) io.ReadCloser {
+	if flags.NArg() < 1 {
+		usageError("Error: input file required (use - for stdin)")
+	}
+	path := flags.Arg(0)
+	if path == "-" {
+		return os.Stdin
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return f
+}
+
+// decodeRecords decodes a JSON array or JSON lines from r, passing each
+// record to add, and returns the number added.
+func decodeRecords[T any](r io.Reader, add func(T) error) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	var records []T
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return 0, fmt.Errorf("parse input: %w", err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var record T
+			if err := dec.Decode(&record); err == io.EOF {
+				break
+			} else if err != nil {
+				return 0, fmt.Errorf("parse input: %w", err)
+			}
+			records = append(records, record)
+		}
+	}
+
+	for _, record := range records {
+		if err := add(record); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), nil
+}
@@ -1,44 +1,124 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	// Report time zones resolve on hosts and images without zoneinfo
+	_ "time/tzdata"
 
+	"github.com/hallucinaut/secmetrics/pkg/archive"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/delivery"
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/httpclient"
 	"github.com/hallucinaut/secmetrics/pkg/metrics"
 	"github.com/hallucinaut/secmetrics/pkg/reporting"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+	"github.com/hallucinaut/secmetrics/pkg/store"
 )
 
 const version = "1.0.0"
 
 func main() {
-	if len(os.Args) < 2 {
+	args := os.Args[1:]
+	for len(args) > 0 && (args[0] == "--read-only" || args[0] == "--demo" || args[0] == "--profile" || strings.HasPrefix(args[0], "--profile=")) {
+		readOnly = readOnly || args[0] == "--read-only"
+		demoMode = demoMode || args[0] == "--demo"
+		if profile, ok := strings.CutPrefix(args[0], "--profile="); ok {
+			selectProfile(profile)
+		} else if args[0] == "--profile" {
+			if len(args) < 2 {
+				usageError("Error: --profile requires a profile name")
+			}
+			selectProfile(args[1])
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) < 1 {
 		printUsage()
 		return
 	}
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "collect":
-		collectMetrics()
+		collectMetrics(args[1:])
 	case "kpis":
-		showKPIS()
+		showKPIS(args[1:])
 	case "report":
-		if len(os.Args) < 3 {
-			fmt.Println("Error: report type required")
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, "Error: report type required")
 			printUsage()
-			return
+			os.Exit(exitUsage)
 		}
-		generateReport(os.Args[2])
+		generateReport(args[1], args[2:])
 	case "summary":
-		showSummary()
+		showSummary(args[1:])
 	case "health":
-		checkHealth()
+		checkHealth(args[1:])
+	case "explain":
+		if len(args) > 1 && args[1] == "metric" {
+			explainMetric(args[2:])
+		} else {
+			explainKPI(args[1:])
+		}
+	case "kpi":
+		manageKPIs(args[1:])
+	case "audit":
+		showAuditTrail(args[1:])
+	case "runs":
+		manageRuns(args[1:])
+	case "recalculate":
+		recalculateScores(args[1:])
+	case "metric":
+		manageMetrics(args[1:])
+	case "silence":
+		manageSilences(args[1:])
+	case "approval":
+		manageApprovals(args[1:])
+	case "archive":
+		manageArchive(args[1:])
+	case "narrative":
+		manageNarratives(args[1:])
+	case "migrate":
+		migrateStore(args[1:])
+	case "export":
+		exportData(args[1:])
+	case "import":
+		importData(args[1:])
+	case "serve":
+		serve(args[1:])
+	case "service":
+		manageService(args[1:])
+	case "keygen":
+		generateKey()
+	case "docs":
+		generateDocs(args[1:])
+	case "update":
+		selfUpdate(args[1:])
+	case "bundle":
+		manageBundle(args[1:])
+	case "devtools":
+		runDevtools(args[1:])
 	case "version":
 		fmt.Printf("secmetrics version %s\n", version)
 	case "help", "--help", "-h":
 		printUsage()
 	default:
-		fmt.Printf("Unknown command: %s\n", os.Args[1])
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", args[0])
 		printUsage()
+		os.Exit(exitUsage)
 	}
 }
 
@@ -46,129 +126,576 @@ func printUsage() {
 	fmt.Print(`secmetrics - Security Metrics & KPI Dashboard
 
 Usage:
-  secmetrics <command> [options]
+  secmetrics [--read-only] [--demo] [--profile <name>] <command> [options]
 
-Commands:
-  collect    Collect security metrics
-  kpis       Show security KPIs
-  report     Generate metrics report
-  summary    Show metrics summary
-  health     Check security health status
-  version    Show version information
-  help       Show this help message
+Global options:
+  --read-only       disable every change to the store
+  --demo            show a synthetic demo dataset instead of the store
+  --profile <name>  apply the named profile of the configuration file
 
+Commands:
+`)
+	for _, cmd := range commands() {
+		fmt.Printf("  %-10s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Print(`
 Examples:
   secmetrics collect
   secmetrics kpis
   secmetrics report executive
+  secmetrics report html > report.html
+  secmetrics report onepager --format pdf --output onepager.pdf
+  secmetrics report ops --month 2026-09
+  secmetrics report gaps --format html --output gaps.html
+  secmetrics report targets --format html --output targets.html
+  secmetrics report technical --locale de-DE
+  secmetrics report markdown --charts --output report.md
+  secmetrics report markdown --deliver --config secmetrics.yaml
+  secmetrics explain mttr
+  secmetrics explain metric cis-benchmark
+  secmetrics kpi archive response_time
+  secmetrics kpi lifecycle phishing_click_rate draft
+  secmetrics metric attach --url https://jira.example.com/browse/SEC-42 cis-benchmark
+  secmetrics recalculate --dry-run
+  secmetrics runs list
+  secmetrics runs show
+  secmetrics silence create --until 2h --category Vulnerability --comment "CHG-1234 patching"
+  secmetrics approval approve --by ciso weekly-20261001-090000
+  secmetrics archive regenerate --format pdf --output q3.pdf rpt-20261001090000-executive
+  secmetrics archive correct --reason "MTTR excluded INC-42" rpt-20261001090000-executive
+  secmetrics narrative draft --quarter 2026-Q3
+  secmetrics import metrics scrape.txt
+  secmetrics export state --format terraform-json --output secmetrics-state.json
+  secmetrics export coverage --output coverage-layer.json
+  secmetrics export compliance --format oscal --output assessment-results.json
+  secmetrics export history --format parquet --output kpi-history.parquet
+  secmetrics docs man --output man/
+  secmetrics docs spec --format json
+  secmetrics update --check
+  secmetrics bundle export --reports reports/ --output transfer.smb
+  secmetrics --read-only serve
+  secmetrics --profile staging collect
+  secmetrics serve --once --report executive
+  secmetrics serve --watch
+  secmetrics service install --user secmetrics --config /etc/secmetrics/secmetrics.yaml
+  secmetrics --demo report executive
+  secmetrics devtools generate --kpis 50 --days 365 --teams 20 --output load.json
   secmetrics summary
-`, "secmetrics")
+`)
 }
 
-func collectMetrics() {
-	fmt.Println("Security Metrics Collection")
-	fmt.Println("==========================")
-	fmt.Println()
+// selectProfile selects the configuration profile every command loads.
+// It is passed on through the environment, so plugin sources see it too.
+func selectProfile(name string) {
+	if name == "" {
+		usageError("Error: --profile requires a profile name")
+	}
+	os.Setenv(config.ProfileEnv, name)
+}
 
-	collector := metrics.NewMetricsCollector()
+// exitPartialFailure is the exit status of collect and serve --once when
+// some sources failed; 1 means every source failed.
+const exitPartialFailure = 3
 
-	// Add common KPIs
-	commonKPIS := metrics.GetCommonKPIs()
-	for _, kpi := range commonKPIS {
-		collector.AddKPI(kpi)
+// exitUsage is the exit status of command line usage errors, matching the
+// flag package.
+const exitUsage = 2
+
+// usageError prints a command line usage error to stderr and exits with
+// exitUsage.
+func usageError(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(exitUsage)
+}
+
+// runExitStatus returns the exit status of a collection run: 0 when it
+// succeeded, 1 when every source failed and exitPartialFailure otherwise.
+func runExitStatus(run *metrics.CollectionRun) int {
+	switch run.Status() {
+	case "failed":
+		return 1
+	case "partial":
+		return exitPartialFailure
 	}
+	return 0
+}
 
-	fmt.Println("Metrics Collected:")
-	fmt.Println("  ✓ Mean Time to Respond (MTTR)")
-	fmt.Println("  ✓ Mean Time to Contain (MTTC)")
-	fmt.Println("  ✓ Mean Time to Detect (MTTD)")
-	fmt.Println("  ✓ Security Coverage")
-	fmt.Println("  ✓ Compliance Score")
-	fmt.Println("  ✓ Vulnerability Remediation Rate")
-	fmt.Println()
+// collectFlagSet returns the collect command's flags.
+func collectFlagSet() (flags *flag.FlagSet, configPath *string, jsonOutput *bool) {
+	flags, configPath = configFlagSet("collect")
+	jsonOutput = flags.Bool("json", false, "print the run, KPIs and summary as JSON instead of text")
+	return flags, configPath, jsonOutput
+}
+
+// collectResult is the JSON output of the collect command.
+type collectResult struct {
+	// Status is the run status: ok, partial or failed.
+	Status  string
+	Run     metrics.CollectionRun
+	KPIs    []metrics.KPI
+	Summary *metrics.MetricsSummary
+}
+
+func collectMetrics(args []string) {
+	flags, configPath, jsonOutput := collectFlagSet()
+	flags.Parse(args)
+
+	cfg, err := config.LoadOrDefault(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// JSON output replaces the progress text
+	var out io.Writer = os.Stdout
+	if *jsonOutput {
+		out = io.Discard
+	}
+
+	fmt.Fprintln(out, "Security Metrics Collection")
+	fmt.Fprintln(out, "==========================")
+	fmt.Fprintln(out)
+
+	collector := newCollector(cfg)
+
+	// Restore history, archived KPIs, lifecycles, scores, incidents and alerts from the
+	// store so they persist across runs; demo data never touches the store
+	metricsStore := openStore(cfg)
+	if demoMode {
+		metricsStore = nil
+	}
+	var previousKPIs []metrics.KPI
+	if metricsStore != nil {
+		snapshot, err := metricsStore.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		collector.RestoreLifecycles(snapshot.Lifecycles, snapshot.Audit)
+		collector.RestoreScores(snapshot.Scores)
+		collector.RestoreRuns(snapshot.Runs)
+		collector.Restore(nil, snapshot.ArchivedKPIs(), snapshot.History)
+		collector.RestoreEvents(snapshot.Incidents, snapshot.Alerts)
+		previousKPIs = snapshot.KPIs
+	}
+
+	// Run the built-in and configured sources
+	fmt.Fprintln(out, "Sources:")
+	run := collector.StartRun()
+	for _, source := range collectionSources(cfg) {
+		if err := server.CollectSource(context.Background(), collector, run, source); err != nil {
+			fmt.Fprintf(out, "  ✗ %s: %v\n", source.Name, err)
+			continue
+		}
+		fmt.Fprintf(out, "  ✓ %s\n", source.Name)
+	}
+	// Failed sources keep their last values, marked stale
+	collector.RetainStaleKPIs(previousKPIs, run)
+	collector.FinishRun(run)
+	fmt.Fprintln(out)
 
 	// Show collected metrics
-	fmt.Println("Collected KPIs:")
+	fmt.Fprintln(out, "Collected KPIs:")
 	for i, kpi := range collector.GetKPIS() {
-		fmt.Printf("  [%d] %s: %.1f %s\n", i+1, kpi.Name, kpi.Value, kpi.Unit)
+		line := fmt.Sprintf("  [%d] %s: %.1f %s", i+1, kpi.Name, kpi.Value, kpi.Unit)
+		if kpi.Stale {
+			line += fmt.Sprintf("  (stale: %s failed, value from %s)", kpi.Source, kpi.LastUpdated.Local().Format("2006-01-02 15:04"))
+		}
+		fmt.Fprintln(out, line)
+	}
+	fmt.Fprintln(out)
+
+	if collected := collector.GetMetrics(); len(collected) > 0 {
+		fmt.Fprintln(out, "Collected Metrics:")
+		for i, metric := range collected {
+			fmt.Fprintf(out, "  [%d] %s: %.1f %s\n", i+1, metric.Name, metric.Value, metric.Unit)
+		}
+		fmt.Fprintln(out)
 	}
-	fmt.Println()
 
 	// Show summary
+	collector.RecordScores()
 	summary := collector.GetSummary()
-	fmt.Println("Summary:")
-	fmt.Printf("  Compliance Score: %.1f%%\n", summary.ComplianceScore)
-	fmt.Printf("  Risk Score: %.1f\n", summary.RiskScore)
-	fmt.Printf("  Overall Health: %s\n", summary.OverallHealth)
+	fmt.Fprintln(out, "Summary:")
+	fmt.Fprintf(out, "  Compliance Score: %s\n", metrics.FormatScore("%.1f%%", summary.ComplianceScore, summary.HasData.Compliance))
+	fmt.Fprintf(out, "  Risk Score: %s\n", metrics.FormatScore("%.1f", summary.RiskScore, summary.HasData.Risk))
+	fmt.Fprintf(out, "  Vulnerability Score: %s\n", metrics.FormatScore("%.1f", summary.VulnerabilityScore, summary.HasData.Vulnerability))
+	fmt.Fprintf(out, "  Overall Health: %s\n", summary.OverallHealth)
+	if len(summary.StaleKPIs) > 0 {
+		fmt.Fprintf(out, "  Degraded: stale KPIs left out of the scores: %s\n", strings.Join(kpiStrings(summary.StaleKPIs), ", "))
+	}
+
+	if demoMode {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Demo mode: not saved to store")
+	} else if metricsStore != nil && isReadOnly(cfg) {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Read-only mode: not saved to store")
+	} else if metricsStore != nil {
+		if err := metricsStore.SaveFrom(collector); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: save store: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Saved to store:", metricsStore.Path())
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(collectResult{Status: run.Status(), Run: *run, KPIs: collector.GetKPIS(), Summary: summary})
+	}
+
+	if status := runExitStatus(run); status != 0 {
+		os.Exit(status)
+	}
+}
+
+// newCollector creates a collector using the configured category
+// taxonomy, fiscal calendar and API version.
+func newCollector(cfg *config.Config) *metrics.MetricsCollector {
+	collector := metrics.NewMetricsCollector()
+	if err := collector.SetTaxonomy(cfg.Taxonomy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := collector.SetFiscalCalendar(cfg.Fiscal); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := collector.SetScoreModel(cfg.Scoring); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	version, _ := metrics.ParseAPIVersion(cfg.APIVersion)
+	collector.SetAPIVersion(version)
+	return collector
+}
+
+// openStore opens the configured metrics store, or returns nil when no
+// store is configured.
+func openStore(cfg *config.Config) *store.FileStore {
+	if cfg.Store.Path == "" {
+		return nil
+	}
+	cipher, err := encryption.New(cfg.Encryption, newHTTPClient(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	metricsStore := store.NewFileStore(cfg.Store.Path, cipher)
+	history, err := store.NewHistory(cfg.Store.History, newHTTPClient(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	metricsStore.SetHistory(history)
+	return metricsStore
+}
+
+// newHTTPClient builds the shared outbound HTTP client from configuration.
+func newHTTPClient(cfg *config.Config) *http.Client {
+	client, err := httpclient.New(cfg.HTTP)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return client
+}
+
+func generateKey() {
+	key, err := encryption.GenerateKey()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(key)
+}
+
+// loadCommandConfig parses the --config flag of the named command and
+// loads the configuration.
+func loadCommandConfig(name string, args []string) *config.Config {
+	flags, configPath := configFlagSet(name)
+	flags.Parse(args)
+	cfg, err := config.LoadOrDefault(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
 }
 
-func showKPIS() {
+func showKPIS(args []string) {
+	collector := loadCollector(loadCommandConfig("kpis", args))
+
 	fmt.Println("Security KPIs")
 	fmt.Println("=============")
 	fmt.Println()
 
-	commonKPIS := metrics.GetCommonKPIs()
+	kpis := collector.GetKPIS()
+	if len(kpis) == 0 {
+		fmt.Println(noDataHint)
+		return
+	}
 
 	fmt.Println("Key Performance Indicators:")
 	fmt.Println()
-	for i, kpi := range commonKPIS {
+	for i, kpi := range kpis {
 		fmt.Printf("[%d] %s\n", i+1, kpi.Name)
 		fmt.Printf("    Value: %.1f %s\n", kpi.Value, kpi.Unit)
+		for _, p := range kpi.Percentiles {
+			fmt.Printf("    %s: %.1f %s\n", p.Label, p.Value, kpi.Unit)
+		}
 		fmt.Printf("    Target: %.1f %s\n", kpi.Target, kpi.Unit)
 		fmt.Printf("    Status: %s\n", kpi.Status)
 		fmt.Printf("    Trend: %s\n", kpi.Trend)
-		fmt.Printf("    Category: %s\n\n", kpi.Category)
+		fmt.Printf("    Category: %s\n", kpi.Category)
+		if kpi.Stale {
+			fmt.Printf("    Stale: %s failed, value from %s\n", kpi.Source, kpi.LastUpdated.Local().Format("2006-01-02 15:04"))
+		}
+		fmt.Println()
 	}
 }
 
-func generateReport(reportType string) {
-	fmt.Printf("Generating %s Report\n", reportType)
-	fmt.Println()
+// reportOptions are the flags of the report command.
+type reportOptions struct {
+	deliver, charts                                                     *bool
+	configPath, format, output, month, locale, timeZone, classification *string
+}
 
-	// Create collector and add data
-	collector := metrics.NewMetricsCollector()
+// reportFlagSet returns the report command's flags.
+func reportFlagSet() (*flag.FlagSet, *reportOptions) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	opts := &reportOptions{
+		deliver:        flags.Bool("deliver", false, "deliver the report to the targets configured in the config file"),
+		configPath:     flags.String("config", config.Path(), "path to the configuration file"),
+		format:         flags.String("format", "", "comma-separated output formats: executive (text, markdown, html, pdf), onepager (markdown, html, pdf), ops, gaps and targets (markdown, html, text); several formats are rendered from the same data and need --output"),
+		output:         flags.String("output", "", "write the report to this file instead of stdout"),
+		month:          flags.String("month", "", "ops report month as YYYY-MM (default: current month)"),
+		locale:         flags.String("locale", "", "locale for numbers and dates, e.g. de-DE (overrides report.locale)"),
+		timeZone:       flags.String("timezone", "", "IANA time zone for report timestamps, e.g. Europe/Berlin (overrides report.time_zone)"),
+		classification: flags.String("classification", "", "Public, Internal, Confidential or Restricted (overrides report.classification)"),
+		charts:         flags.Bool("charts", false, "write PNG KPI charts alongside the markdown report and embed them (requires --output)"),
+	}
+	return flags, opts
+}
+
+// checkReportType returns an error for an unknown report type.
+func checkReportType(reportType string) error {
+	switch reportType {
+	case "executive", "technical", "markdown", "html", "onepager", "ops", "gaps", "targets":
+		return nil
+	}
+	return fmt.Errorf("unknown report type %q", reportType)
+}
+
+func generateReport(reportType string, args []string) {
+	flags, opts := reportFlagSet()
+	flags.Parse(args)
+	if err := checkReportType(reportType); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	deliver, configPath, format, output := opts.deliver, opts.configPath, opts.format, opts.output
+	month, locale, classification, charts := opts.month, opts.locale, opts.classification, opts.charts
+
+	cfg, err := config.LoadOrDefault(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *locale != "" {
+		cfg.Report.Locale = *locale
+	}
+	if *opts.timeZone != "" {
+		cfg.Report.TimeZone = *opts.timeZone
+	}
+	if *classification != "" {
+		cfg.Report.Classification = *classification
+	}
+	if *locale != "" || *opts.timeZone != "" || *classification != "" {
+		if err := cfg.Report.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	formats := parseFormats(*format)
+	if err := checkOutputFormats(formats, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *charts && (reportType != "markdown" || *output == "") {
+		fmt.Fprintln(os.Stderr, "Error: --charts requires the markdown report and --output")
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		fmt.Printf("Generating %s Report\n", reportType)
+		fmt.Println()
+	}
+
+	// The ops report covers recorded incidents, alerts and KPI history
+	if reportType == "ops" && !demoMode && cfg.Store.Path == "" {
+		fmt.Fprintln(os.Stderr, "Error: the ops report requires a store (set store.path in the config file)")
+		os.Exit(1)
+	}
+	collector := loadCollector(cfg)
+
+	report := buildReport(collector, cfg.Report.Locale)
+	report.Brand, err = cfg.Report.Branding.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	report.Classification, _ = reporting.ParseClassification(cfg.Report.Classification)
+	report.Location, _ = reporting.ParseTimeZone(cfg.Report.TimeZone)
+	if err := addReportData(report, collector, reportType, *month, &cfg.Sources, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	report.Narrative, err = narrativeData(openStore(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *charts {
+		if err := writeChartImages(report, *output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// Every format is rendered from the same report before any is written,
+	// so the artifacts of a run always show the same numbers
+	artifacts, err := renderArtifacts(report, reportType, formats, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// The data snapshot is archived with the report, so it can be
+	// regenerated in another format or corrected later
+	if metricsStore := archiveStore(cfg); metricsStore != nil {
+		version := &archive.Version{ID: report.ID + "-" + reportType, Type: reportType, Version: 1, CreatedAt: report.CreatedAt}
+		if err := archiveReport(metricsStore, version, report, artifacts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: archive report: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "Archived as", version.ID)
+	}
+	if err := writeArtifacts(artifacts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *deliver {
+		for _, artifact := range artifacts {
+			deliverReport(cfg, report.ID+"-"+reportType+"."+artifact.ext, report.Classification, []byte(artifact.content))
+		}
+	}
+}
+
+// parseFormats splits a comma-separated --format value, dropping blanks
+// and repeats. An empty value selects the report type's default format.
+func parseFormats(value string) []string {
+	var formats []string
+	seen := make(map[string]bool)
+	for _, format := range strings.Split(value, ",") {
+		format = strings.TrimSpace(format)
+		if format == "" || seen[format] {
+			continue
+		}
+		seen[format] = true
+		formats = append(formats, format)
+	}
+	if len(formats) == 0 {
+		return []string{""}
+	}
+	return formats
+}
+
+// reportArtifact is a report rendered in one format.
+type reportArtifact struct {
+	content, ext string
+	// path is the file to write the artifact to, or empty for stdout.
+	path string
+}
 
-	// Add common KPIs
-	commonKPIS := metrics.GetCommonKPIs()
-	for _, kpi := range commonKPIS {
-		collector.AddKPI(kpi)
+// renderArtifacts renders report in each of formats. With a single format
+// the artifact is written to output as given; with several, each is
+// written next to output with its format's extension, e.g. --output
+// reports/q3 writes reports/q3.md, reports/q3.html and reports/q3.pdf.
+func renderArtifacts(report *reporting.Report, reportType string, formats []string, output string) ([]reportArtifact, error) {
+	artifacts := make([]reportArtifact, 0, len(formats))
+	paths := make(map[string]string)
+	for _, format := range formats {
+		content, ext, err := renderReport(report, reportType, format)
+		if err != nil {
+			return nil, err
+		}
+		path := output
+		if len(formats) > 1 {
+			path = strings.TrimSuffix(output, filepath.Ext(output)) + "." + ext
+			if other, ok := paths[path]; ok {
+				return nil, fmt.Errorf("formats %s and %s would both write %s", other, format, path)
+			}
+			paths[path] = format
+		}
+		artifacts = append(artifacts, reportArtifact{content: content, ext: ext, path: path})
 	}
+	return artifacts, nil
+}
 
+// writeChartImages renders a PNG chart per KPI next to the report file at
+// output and records the relative image paths on the report.
+func writeChartImages(report *reporting.Report, output string) error {
+	dir := filepath.Dir(output)
+	base := strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+	report.ChartImages = make(map[string]string)
+	for _, kpi := range report.KPIS {
+		image, err := reporting.RenderKPIChartPNG(kpi)
+		if err != nil {
+			return err
+		}
+		name := reporting.ChartFilename(base, kpi)
+		if err := os.WriteFile(filepath.Join(dir, name), image, 0o644); err != nil {
+			return err
+		}
+		report.ChartImages[kpi.Key] = (&url.URL{Path: name}).String()
+	}
+	return nil
+}
+
+// buildReport assembles a report from the collector's current state,
+// formatted for locale.
+func buildReport(collector *metrics.MetricsCollector, locale string) *reporting.Report {
 	// Create report
 	generator := reporting.NewReportGenerator()
 	report := generator.GenerateReport("Security Metrics Report", "Comprehensive security metrics report", reporting.FormatMarkdown)
+	report.Locale = locale
 
 	// Set executive summary
-	report.Executive = reporting.ExecutiveSummary{
-		OverallHealth: collector.GetSummary().OverallHealth,
-		ComplianceScore: collector.GetSummary().ComplianceScore,
-		RiskScore: collector.GetSummary().RiskScore,
-		TopConcerns: []string{"Vulnerability remediation rate below target", "Security coverage needs improvement"},
-		TopAchievements: []string{"MTTD improved by 20%", "Compliance score at 92%"},
-		Recommendations: []string{"Increase security automation", "Expand security monitoring coverage"},
-		ActionItems: []string{"Address critical vulnerabilities", "Complete security training"},
-	}
-
-	// Set technical summary
-	report.Technical = reporting.TechnicalSummary{
-		MetricsCovered: 6,
-		KPIsTracked: 6,
-		AlertsActive: 12,
-		IncidentsLastMonth: 23,
-		VulnerabilitiesOpen: 45,
-		ComplianceStatus: "COMPLIANT",
-		DetectionRate: 95.0,
-		ResponseTime: 2.5,
-	}
-
-	// Add metrics
-	commonMetrics := reporting.GetCommonMetrics()
-	for _, metric := range commonMetrics {
-		generator.AddMetric(report.ID, metric)
-	}
+	report.Executive = executiveSummary(collector)
 
 	// Add KPIs
-	for _, kpi := range commonKPIS {
+	for _, kpi := range collector.GetKPIS() {
+		var percentiles []reporting.PercentileData
+		for _, p := range kpi.Percentiles {
+			percentiles = append(percentiles, reporting.PercentileData{Label: p.Label, Value: p.Value})
+		}
+		var direction string
+		var bands *reporting.TargetBands
+		if def, ok := collector.GetKPIDefinition(kpi.Key); ok {
+			direction = string(def.Direction)
+			if def.WarningThreshold != nil && def.CriticalThreshold != nil {
+				bands = &reporting.TargetBands{Warning: *def.WarningThreshold, Critical: *def.CriticalThreshold}
+			}
+		}
+		var rootCauses []reporting.RootCauseData
+		if explanation, err := collector.ExplainKPI(kpi.Key, time.Now()); err == nil {
+			rootCauses = rootCauseData(explanation)
+		}
 		generator.AddKPI(report.ID, reporting.KPIData{
 			Key:      string(kpi.Key),
 			Name:     kpi.Name,
@@ -177,60 +704,379 @@ func generateReport(reportType string) {
 			Status:   kpi.Status,
 			Trend:    kpi.Trend,
 			Unit:     kpi.Unit,
-			Category: kpi.Category,
+			Category: metrics.JoinCategory(collector.CategoryPath(kpi)),
+			Percentiles: percentiles,
+			Direction: direction,
+			Bands: bands,
+			RootCauses: rootCauses,
 		})
 	}
 
-	// Generate report based on type
+	// Add one-pager
+	report.OnePager = onePagerData(collector, locale)
+
+	// Add category breakdown
+	for _, category := range collector.GetSummary().Categories {
+		report.Categories = append(report.Categories, categoryData(category))
+	}
+
+	// Add zero trust scorecard
+	if scorecard := collector.GetZeroTrustScorecard(); scorecard != nil {
+		zeroTrust := &reporting.ZeroTrustData{Score: scorecard.Score, Maturity: scorecard.Maturity}
+		for _, pillar := range scorecard.Pillars {
+			data := reporting.PillarData{Name: pillar.Pillar.Name, Measured: pillar.Measured()}
+			if def, ok := collector.GetKPIDefinition(pillar.Pillar.Key); ok {
+				data.KPIName = def.Name
+			}
+			if pillar.Measured() {
+				data.KPIName = pillar.KPI.Name
+				data.Value = pillar.KPI.Value
+				data.Target = pillar.KPI.Target
+				data.Unit = pillar.KPI.Unit
+				data.Progress = pillar.Progress
+			}
+			zeroTrust.Pillars = append(zeroTrust.Pillars, data)
+		}
+		report.ZeroTrust = zeroTrust
+	}
+
+	return report
+}
+
+// executiveSummaryLimit bounds each list of the executive summary.
+const executiveSummaryLimit = 3
+
+// executiveSummary derives the executive summary from the collector: KPIs
+// furthest below target are concerns, KPIs on target achievements, and
+// categories in poor or fair health and open incidents and alerts call for
+// action.
+func executiveSummary(collector *metrics.MetricsCollector) reporting.ExecutiveSummary {
+	summary := collector.GetSummary()
+	executive := reporting.ExecutiveSummary{
+		OverallHealth:    summary.OverallHealth,
+		ComplianceScore:  summary.ComplianceScore,
+		RiskScore:        summary.RiskScore,
+		NoComplianceData: !summary.HasData.Compliance,
+		NoRiskData:       !summary.HasData.Risk,
+	}
+
+	var below []metrics.KPI
+	for _, kpi := range collector.GetKPIS() {
+		// Draft KPIs are piloted and not yet judged.
+		if collector.KPILifecycle(kpi.Key) == metrics.LifecycleDraft {
+			continue
+		}
+		if kpi.Status == "BELOW_TARGET" {
+			below = append(below, kpi)
+		} else if len(executive.TopAchievements) < executiveSummaryLimit {
+			executive.TopAchievements = append(executive.TopAchievements, fmt.Sprintf("%s on target at %.1f %s", kpi.Name, kpi.Value, kpi.Unit))
+		}
+	}
+	// Furthest from target first, relative to the target.
+	gap := func(kpi metrics.KPI) float64 {
+		return math.Abs(kpi.Value-kpi.Target) / math.Max(math.Abs(kpi.Target), 1)
+	}
+	sort.SliceStable(below, func(i, j int) bool { return gap(below[i]) > gap(below[j]) })
+	for _, kpi := range below[:min(len(below), executiveSummaryLimit)] {
+		executive.TopConcerns = append(executive.TopConcerns, fmt.Sprintf("%s below target at %.1f %s (target %g)", kpi.Name, kpi.Value, kpi.Unit, kpi.Target))
+	}
+
+	for _, category := range summary.Categories {
+		if category.Health == "POOR" || category.Health == "FAIR" {
+			executive.Recommendations = append(executive.Recommendations, fmt.Sprintf("Improve %s (%s, %.1f%%)", category.Category, category.Health, category.Score))
+		}
+	}
+	if !summary.HasData.Compliance {
+		executive.Recommendations = append(executive.Recommendations, "Collect compliance metrics to assess overall health")
+	}
+
+	var openIncidents, openAlerts int
+	for _, incident := range collector.GetIncidents() {
+		if incident.ResolvedAt.IsZero() {
+			openIncidents++
+		}
+	}
+	for _, alert := range collector.GetAlerts() {
+		if alert.AcknowledgedAt.IsZero() {
+			openAlerts++
+		}
+	}
+	if openIncidents > 0 {
+		executive.ActionItems = append(executive.ActionItems, fmt.Sprintf("Resolve open incidents (%d)", openIncidents))
+	}
+	if openAlerts > 0 {
+		executive.ActionItems = append(executive.ActionItems, fmt.Sprintf("Acknowledge open alerts (%d)", openAlerts))
+	}
+	for _, kpi := range below[:min(len(below), executiveSummaryLimit)] {
+		executive.ActionItems = append(executive.ActionItems, fmt.Sprintf("Bring %s to its target of %g %s", kpi.Name, kpi.Target, kpi.Unit))
+	}
+	return executive
+}
+
+// addTechnicalData adds the technical summary, the latest observation of
+// each metric and the fiscal quarter-over-quarter KPI comparisons to
+// report, which only the technical report shows.
+func addTechnicalData(report *reporting.Report, collector *metrics.MetricsCollector, now time.Time) {
+	for i := range report.KPIS {
+		comparison := collector.CompareQuarters(metrics.KPIKey(report.KPIS[i].Key), now)
+		if comparison.Current.Count == 0 || !comparison.HasPrevious() {
+			continue
+		}
+		report.KPIS[i].Comparison = &reporting.PeriodComparison{
+			Period:         comparison.Quarter.Label,
+			PreviousPeriod: comparison.PreviousQuarter.Label,
+			Current:        comparison.Current.Mean,
+			Previous:       comparison.Previous.Mean,
+			Delta:          comparison.Delta,
+			DeltaPercent:   comparison.DeltaPercent,
+		}
+	}
+	latest := collector.GetLatestMetrics()
+	report.Technical = technicalSummary(collector, latest, now)
+	report.Metrics = make([]reporting.MetricData, 0, len(latest))
+	for _, metric := range latest {
+		data := reporting.MetricData{
+			Name:   metric.Name,
+			Type:   metric.Unit,
+			Value:  metric.Value,
+			Target: metric.Target,
+			Status: metric.Status,
+		}
+		for _, evidence := range metric.Evidence {
+			data.Evidence = append(data.Evidence, reporting.EvidenceData{Kind: string(evidence.Kind), Ref: evidence.Ref, Note: evidence.Note})
+		}
+		report.Metrics = append(report.Metrics, data)
+	}
+}
+
+// technicalSummary derives the technical summary from the collector and
+// its latest metrics as of now. Compliance is COMPLIANT from a compliance
+// score of 90.
+func technicalSummary(collector *metrics.MetricsCollector, latest []metrics.SecurityMetric, now time.Time) reporting.TechnicalSummary {
+	summary := collector.GetSummary()
+	technical := reporting.TechnicalSummary{
+		MetricsCovered:      summary.TotalMetrics,
+		KPIsTracked:         summary.TotalKPIS,
+		ComplianceStatus:    metrics.InsufficientData,
+	}
+	for _, metric := range latest {
+		if metric.Type == metrics.TypeVulnerability {
+			technical.VulnerabilitiesOpen++
+		}
+	}
+	if summary.HasData.Compliance {
+		technical.ComplianceStatus = "NON_COMPLIANT"
+		if summary.ComplianceScore >= 90 {
+			technical.ComplianceStatus = "COMPLIANT"
+		}
+	}
+	for _, alert := range collector.GetAlerts() {
+		if alert.ResolvedAt.IsZero() {
+			technical.AlertsActive++
+		}
+	}
+	for _, incident := range collector.GetIncidents() {
+		if incident.DetectedAt.After(now.AddDate(0, -1, 0)) {
+			technical.IncidentsLastMonth++
+		}
+	}
+	if kpi := collector.GetKPI(metrics.KPI_DetectionRate); kpi != nil {
+		technical.DetectionRate = kpi.Value
+	}
+	if kpi := collector.GetKPI(metrics.KPI_ResponseTime); kpi != nil {
+		technical.ResponseTime = kpi.Value
+	} else if kpi := collector.GetKPI(metrics.KPI_MTTR); kpi != nil {
+		technical.ResponseTime = kpi.Value
+	}
+	return technical
+}
+
+// categoryData converts a category summary, with its subcategories, for
+// reporting.
+func categoryData(summary metrics.CategorySummary) reporting.CategoryData {
+	data := reporting.CategoryData{
+		Name:     summary.Category,
+		KPIs:     summary.KPIs,
+		OnTarget: summary.OnTarget,
+		Score:    summary.Score,
+		Health:   summary.Health,
+		NoData:   summary.Health == metrics.InsufficientData,
+	}
+	for _, sub := range summary.Subcategories {
+		data.Subcategories = append(data.Subcategories, categoryData(sub))
+	}
+	return data
+}
+
+// renderReport renders report as reportType, returning the content and
+// file extension. The format selects markdown, html or pdf output for the
+// onepager type and is ignored otherwise.
+func renderReport(report *reporting.Report, reportType, format string) (string, string, error) {
+	if reportType == "onepager" {
+		switch format {
+		case "", "markdown":
+			content, err := reporting.GenerateOnePagerMarkdown(report)
+			return content, "md", err
+		case "html":
+			content, err := reporting.GenerateOnePagerHTML(report)
+			return content, "html", err
+		case "pdf":
+			content, err := reporting.GenerateOnePagerPDF(report)
+			return string(content), "pdf", err
+		default:
+			return "", "", fmt.Errorf("unknown onepager format %q", format)
+		}
+	}
+	if reportType == "ops" {
+		switch format {
+		case "", "markdown":
+			content, err := reporting.GenerateOpsMarkdown(report)
+			return content, "md", err
+		case "html":
+			content, err := reporting.GenerateOpsHTML(report)
+			return content, "html", err
+		case "text":
+			content, err := reporting.GenerateOpsReport(report)
+			return content, "txt", err
+		default:
+			return "", "", fmt.Errorf("unknown ops format %q", format)
+		}
+	}
+	if reportType == "gaps" {
+		switch format {
+		case "", "markdown":
+			content, err := reporting.GenerateGapMarkdown(report)
+			return content, "md", err
+		case "html":
+			content, err := reporting.GenerateGapHTML(report)
+			return content, "html", err
+		case "text":
+			content, err := reporting.GenerateGapReport(report)
+			return content, "txt", err
+		default:
+			return "", "", fmt.Errorf("unknown gaps format %q", format)
+		}
+	}
+
+	if reportType == "targets" {
+		switch format {
+		case "", "markdown":
+			content, err := reporting.GenerateTargetsMarkdown(report)
+			return content, "md", err
+		case "html":
+			content, err := reporting.GenerateTargetsHTML(report)
+			return content, "html", err
+		case "text":
+			content, err := reporting.GenerateTargetsReport(report)
+			return content, "txt", err
+		default:
+			return "", "", fmt.Errorf("unknown targets format %q", format)
+		}
+	}
+	if reportType == "executive" {
+		switch format {
+		case "", "text":
+			return reporting.GenerateExecutiveReport(report), "txt", nil
+		case "markdown":
+			return reporting.GenerateExecutiveMarkdown(report), "md", nil
+		case "html":
+			return reporting.GenerateExecutiveHTML(report), "html", nil
+		case "pdf":
+			content, err := reporting.GenerateExecutivePDF(report)
+			return string(content), "pdf", err
+		default:
+			return "", "", fmt.Errorf("unknown executive format %q", format)
+		}
+	}
+
+	// The remaining report types each come in a single format
+	var content, ext, only string
 	switch reportType {
-	case "executive":
-		fmt.Println(reporting.GenerateExecutiveReport(report))
-	case "technical":
-		fmt.Println(reporting.GenerateTechnicalReport(report))
 	case "markdown":
-		fmt.Println(reporting.GenerateMarkdownReport(report))
+		content, ext, only = reporting.GenerateMarkdownReport(report), "md", "markdown"
+	case "html":
+		content, ext, only = reporting.GenerateHTMLReport(report), "html", "html"
 	default:
-		fmt.Println(reporting.GenerateTechnicalReport(report))
+		content, ext, only = reporting.GenerateTechnicalReport(report), "txt", "text"
 	}
+	if format != "" && format != only {
+		return "", "", fmt.Errorf("unknown %s format %q", reportType, format)
+	}
+	return content, ext, nil
 }
 
-func showSummary() {
-	fmt.Println("Security Metrics Summary")
-	fmt.Println("========================")
-	fmt.Println()
+func deliverReport(cfg *config.Config, filename string, classification reporting.Classification, content []byte) {
+	client := newHTTPClient(cfg)
+	cipher, err := encryption.New(cfg.Encryption, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	collector := metrics.NewMetricsCollector()
+	targets, err := delivery.NewTargets(cfg.Delivery, client, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no delivery targets configured")
+		os.Exit(1)
+	}
+
+	if err := delivery.DeliverAll(context.Background(), targets, filename, classification, content); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: delivery failed: %v\n", err)
+		os.Exit(1)
+	}
+	for _, target := range targets {
+		fmt.Fprintf(os.Stderr, "Delivered %s to %s\n", filename, target.Name())
+	}
 
-	// Add common KPIs
-	commonKPIS := metrics.GetCommonKPIs()
-	for _, kpi := range commonKPIS {
-		collector.AddKPI(kpi)
+	if err := delivery.PruneAll(context.Background(), targets, time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: retention cleanup failed: %v\n", err)
 	}
+}
+
+func showSummary(args []string) {
+	collector := loadCollector(loadCommandConfig("summary", args))
+
+	fmt.Println("Security Metrics Summary")
+	fmt.Println("========================")
+	fmt.Println()
 
 	summary := collector.GetSummary()
 
 	fmt.Println("Overall Health:", summary.OverallHealth)
-	fmt.Println("Compliance Score:", fmt.Sprintf("%.1f%%", summary.ComplianceScore))
-	fmt.Println("Risk Score:", fmt.Sprintf("%.1f", summary.RiskScore))
+	fmt.Println("Compliance Score:", metrics.FormatScore("%.1f%%", summary.ComplianceScore, summary.HasData.Compliance))
+	fmt.Println("Risk Score:", metrics.FormatScore("%.1f", summary.RiskScore, summary.HasData.Risk))
+	fmt.Println("Vulnerability Score:", metrics.FormatScore("%.1f", summary.VulnerabilityScore, summary.HasData.Vulnerability))
+	fmt.Println("Score Model:", summary.ScoreModel)
 	fmt.Println()
 
 	fmt.Println("KPIs Tracked:", summary.TotalKPIS)
 	fmt.Println("Metrics Collected:", summary.TotalMetrics)
+	if len(summary.StaleKPIs) > 0 {
+		fmt.Println("Degraded: stale KPIs left out of the scores:", strings.Join(kpiStrings(summary.StaleKPIs), ", "))
+	}
+
+	if len(summary.Categories) > 0 {
+		fmt.Println()
+		fmt.Println("Categories:")
+		for _, category := range summary.Categories {
+			fmt.Printf("  %-16s %-9s %5.1f%%  (%d/%d on target)\n", category.Category, category.Health, category.Score, category.OnTarget, category.KPIs)
+			for _, sub := range category.Subcategories {
+				fmt.Printf("    > %-12s %-9s %5.1f%%  (%d/%d on target)\n", sub.Category, sub.Health, sub.Score, sub.OnTarget, sub.KPIs)
+			}
+		}
+	}
 }
 
-func checkHealth() {
+func checkHealth(args []string) {
+	collector := loadCollector(loadCommandConfig("health", args))
+
 	fmt.Println("Security Health Check")
 	fmt.Println("=====================")
 	fmt.Println()
 
-	collector := metrics.NewMetricsCollector()
-
-	// Add common KPIs
-	commonKPIS := metrics.GetCommonKPIs()
-	for _, kpi := range commonKPIS {
-		collector.AddKPI(kpi)
-	}
-
 	summary := collector.GetSummary()
 
 	fmt.Println("Health Status:", summary.OverallHealth)
@@ -238,23 +1084,29 @@ func checkHealth() {
 
 	// Check each KPI
 	fmt.Println("KPI Status:")
-	for _, kpi := range commonKPIS {
+	if len(collector.GetKPIS()) == 0 {
+		fmt.Println("  " + noDataHint)
+	}
+	for _, kpi := range collector.GetKPIS() {
 		status := "✓"
 		if kpi.Status == "BELOW_TARGET" {
 			status = "⚠"
 		}
-		fmt.Printf("  %s %s: %.1f%%\n", status, kpi.Name, kpi.Value)
+		fmt.Printf("  %s %s: %.1f %s\n", status, kpi.Name, kpi.Value, kpi.Unit)
 	}
 	fmt.Println()
 
 	fmt.Println("Recommendations:")
-	if summary.ComplianceScore < 100 {
+	if summary.OverallHealth != metrics.InsufficientData && summary.ComplianceScore < 100 {
 		fmt.Println("  • Improve compliance score")
 	}
-	if summary.RiskScore > 50 {
+	if summary.HasData.Risk && summary.RiskScore > 50 {
 		fmt.Println("  • Reduce risk score")
 	}
 	if summary.OverallHealth == "POOR" || summary.OverallHealth == "FAIR" {
 		fmt.Println("  • Review security posture")
 	}
+	if summary.OverallHealth == metrics.InsufficientData {
+		fmt.Println("  • Collect compliance metrics to assess overall health")
+	}
 }
\ No newline at end of file
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// loadStoredCollector opens the configured store and restores its state.
+func loadStoredCollector(configPath string) (*store.FileStore, *metrics.MetricsCollector) {
+	cfg, err := config.LoadOrDefault(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	metricsStore := openStore(cfg)
+	if metricsStore == nil {
+		fmt.Fprintln(os.Stderr, "Error: no store configured (set store.path in the config file)")
+		os.Exit(1)
+	}
+
+	collector := newCollector(cfg)
+	if err := metricsStore.LoadInto(collector); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return metricsStore, collector
+}
+
+// saveStoredCollector persists collector, exiting on failure.
+func saveStoredCollector(metricsStore *store.FileStore, collector *metrics.MetricsCollector) {
+	if err := metricsStore.SaveFrom(collector); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: save store: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// kpiOptions are the flags of a kpi subcommand.
+type kpiOptions struct {
+	configPath, by *string
+	archived       *bool
+}
+
+// kpiFlagSet returns the flags of a kpi subcommand.
+func kpiFlagSet(subcommand string) (*flag.FlagSet, kpiOptions) {
+	flags := flag.NewFlagSet("kpi "+subcommand, flag.ExitOnError)
+	opts := kpiOptions{
+		configPath: flags.String("config", config.Path(), "path to the configuration file"),
+		archived:   flags.Bool("archived", false, "list archived KPIs instead of active ones"),
+	}
+	if subcommand == "lifecycle" {
+		opts.by = flags.String("by", os.Getenv("USER"), "who changes the lifecycle, recorded in the audit trail")
+	}
+	return flags, opts
+}
+
+func manageKPIs(args []string) {
+	if len(args) < 1 {
+		usageError("Error: kpi subcommand required (list, archive, remove, lifecycle)")
+	}
+
+	flags, opts := kpiFlagSet(args[0])
+	flags.Parse(args[1:])
+	configPath := opts.configPath
+
+	metricsStore, collector := loadStoredCollector(*configPath)
+
+	switch args[0] {
+	case "list":
+		kpis := collector.GetKPIS()
+		if *opts.archived {
+			kpis = collector.GetArchivedKPIs()
+		}
+		for _, kpi := range kpis {
+			line := fmt.Sprintf("%-20s %-40s %.1f %s", kpi.Key, kpi.Name, kpi.Value, kpi.Unit)
+			if kpi.IsArchived() {
+				line += "  (archived " + kpi.ArchivedAt.Format("2006-01-02") + ")"
+			} else if collector.KPILifecycle(kpi.Key) == metrics.LifecycleDraft {
+				line += "  (draft)"
+			}
+			fmt.Println(line)
+		}
+	case "lifecycle":
+		checkWritable(*configPath, "kpi lifecycle")
+		if flags.NArg() < 2 {
+			usageError("Error: kpi key and lifecycle (draft, active, deprecated) required")
+		}
+		key := metrics.KPIKey(flags.Arg(0))
+		lifecycle, err := metrics.ParseLifecycle(flags.Arg(1))
+		if err == nil {
+			err = collector.TransitionKPI(key, lifecycle, *opts.by)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveStoredCollector(metricsStore, collector)
+		fmt.Printf("KPI %s is now %s\n", key, lifecycle)
+	case "archive", "remove":
+		checkWritable(*configPath, "kpi "+args[0])
+		if flags.NArg() < 1 {
+			usageError("Error: kpi key required")
+		}
+		key := metrics.KPIKey(flags.Arg(0))
+		var ok bool
+		if args[0] == "archive" {
+			ok = collector.ArchiveKPI(key)
+		} else {
+			ok = collector.RemoveKPI(key)
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: kpi %s not found\n", key)
+			os.Exit(1)
+		}
+		if args[0] == "remove" {
+			if err := metricsStore.DeleteHistory(key); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		saveStoredCollector(metricsStore, collector)
+		if args[0] == "archive" {
+			fmt.Printf("Archived KPI %s (history retained)\n", key)
+		} else {
+			fmt.Printf("Removed KPI %s and its history\n", key)
+		}
+	default:
+		usageError("Unknown kpi subcommand: %s", args[0])
+	}
+}
+
+// showAuditTrail prints the audit trail of the store, oldest first.
+func showAuditTrail(args []string) {
+	flags, configPath := configFlagSet("audit")
+	flags.Parse(args)
+
+	_, collector := loadStoredCollector(*configPath)
+	for _, entry := range collector.GetAuditTrail() {
+		line := fmt.Sprintf("%s  %-12s %-16s %s", entry.Time.Local().Format("2006-01-02 15:04"), entry.Actor, entry.Action, entry.Subject)
+		if entry.From != "" {
+			line += ": " + entry.From + " -> " + entry.To
+		} else if entry.To != "" {
+			line += ": " + entry.To
+		}
+		fmt.Println(line)
+	}
+}
+
+// recalculateFlagSet returns the flags of the recalculate command.
+func recalculateFlagSet() (flags *flag.FlagSet, configPath *string, dryRun *bool) {
+	flags = flag.NewFlagSet("recalculate", flag.ExitOnError)
+	configPath = flags.String("config", config.Path(), "path to the configuration file")
+	dryRun = flags.Bool("dry-run", false, "show the recalculated scores without saving them")
+	return flags, configPath, dryRun
+}
+
+// recalculateScores rebuilds the stored score history under the configured
+// score model and prints the samples that change.
+func recalculateScores(args []string) {
+	flags, configPath, dryRun := recalculateFlagSet()
+	flags.Parse(args)
+	if !*dryRun {
+		checkWritable(*configPath, "recalculate")
+	}
+
+	metricsStore, collector := loadStoredCollector(*configPath)
+	before := collector.RecalculateScores()
+	after := collector.GetScoreHistory()
+	model := collector.GetSummary().ScoreModel
+
+	changed := 0
+	for i, old := range before {
+		now := after[i]
+		if old.ScoreModel == now.ScoreModel && old.RiskScore == now.RiskScore &&
+			old.VulnerabilityScore == now.VulnerabilityScore && old.OverallHealth == now.OverallHealth {
+			continue
+		}
+		changed++
+		fmt.Printf("%s  %s -> %s  risk %s -> %s  vulnerability %s -> %s  health %s -> %s\n",
+			now.Timestamp.Local().Format("2006-01-02"), old.ScoreModel, now.ScoreModel,
+			metrics.FormatScore("%.1f", old.RiskScore, old.HasData.Risk), metrics.FormatScore("%.1f", now.RiskScore, now.HasData.Risk),
+			metrics.FormatScore("%.1f", old.VulnerabilityScore, old.HasData.Vulnerability), metrics.FormatScore("%.1f", now.VulnerabilityScore, now.HasData.Vulnerability),
+			old.OverallHealth, now.OverallHealth)
+	}
+	fmt.Printf("%d of %d score samples changed under score model %s\n", changed, len(after), model)
+	if *dryRun || changed == 0 {
+		return
+	}
+	saveStoredCollector(metricsStore, collector)
+}
+
+// metricOptions are the flags of a metric subcommand.
+type metricOptions struct {
+	configPath, hash, url, path, note, by *string
+}
+
+// metricFlagSet returns the flags of a metric subcommand.
+func metricFlagSet(subcommand string) (*flag.FlagSet, metricOptions) {
+	flags := flag.NewFlagSet("metric "+subcommand, flag.ExitOnError)
+	opts := metricOptions{configPath: flags.String("config", config.Path(), "path to the configuration file")}
+	if subcommand == "attach" {
+		opts.hash = flags.String("hash", "", "digest of an artifact such as a scan file, e.g. sha256:<hex>")
+		opts.url = flags.String("url", "", "URL of a ticket or other web reference")
+		opts.path = flags.String("path", "", "path of a file such as a screenshot")
+		opts.note = flags.String("note", "", "description of the evidence")
+		opts.by = flags.String("by", os.Getenv("USER"), "who attaches the evidence, recorded in the audit trail")
+	}
+	return flags, opts
+}
+
+func manageMetrics(args []string) {
+	if len(args) < 1 {
+		usageError("Error: metric subcommand required (list, remove, attach)")
+	}
+
+	flags, opts := metricFlagSet(args[0])
+	flags.Parse(args[1:])
+	configPath := opts.configPath
+
+	metricsStore, collector := loadStoredCollector(*configPath)
+
+	switch args[0] {
+	case "list":
+		for _, metric := range collector.GetMetrics() {
+			fmt.Printf("%-20s %-12s %-40s %.1f %s\n", metric.ID, metric.Type, metric.Name, metric.Value, metric.Unit)
+			for _, evidence := range metric.Evidence {
+				line := fmt.Sprintf("  evidence: %s %s", evidence.Kind, evidence.Ref)
+				if evidence.Note != "" {
+					line += " (" + evidence.Note + ")"
+				}
+				fmt.Println(line)
+			}
+		}
+	case "remove":
+		checkWritable(*configPath, "metric remove")
+		if flags.NArg() < 1 {
+			usageError("Error: metric id required")
+		}
+		id := flags.Arg(0)
+		if !collector.RemoveMetric(id) {
+			fmt.Fprintf(os.Stderr, "Error: metric %s not found\n", id)
+			os.Exit(1)
+		}
+		saveStoredCollector(metricsStore, collector)
+		fmt.Printf("Removed metric %s\n", id)
+	case "attach":
+		checkWritable(*configPath, "metric attach")
+		if flags.NArg() < 1 {
+			usageError("Error: metric id required")
+		}
+		id := flags.Arg(0)
+		var evidence []metrics.Evidence
+		for _, ref := range []struct {
+			kind metrics.EvidenceKind
+			ref  string
+		}{{metrics.EvidenceHash, *opts.hash}, {metrics.EvidenceURL, *opts.url}, {metrics.EvidencePath, *opts.path}} {
+			if ref.ref != "" {
+				evidence = append(evidence, metrics.Evidence{Kind: ref.kind, Ref: ref.ref, Note: *opts.note})
+			}
+		}
+		if len(evidence) == 0 {
+			usageError("Error: evidence required (--hash, --url or --path)")
+		}
+		for _, e := range evidence {
+			if err := collector.AttachEvidence(id, e, *opts.by); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		saveStoredCollector(metricsStore, collector)
+		fmt.Printf("Attached %d evidence reference(s) to metric %s\n", len(evidence), id)
+	default:
+		usageError("Unknown metric subcommand: %s", args[0])
+	}
+}
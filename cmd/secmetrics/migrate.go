@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+func migrateStore(args []string) {
+	if len(args) < 1 {
+		usageError("Error: migrate subcommand required (status, up)")
+	}
+
+	flags, configPath := configFlagSet("migrate " + args[0])
+	flags.Parse(args[1:])
+
+	cfg, err := config.LoadOrDefault(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	metricsStore := openStore(cfg)
+	if metricsStore == nil {
+		fmt.Fprintln(os.Stderr, "Error: no store configured (set store.path in the config file)")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "status":
+		status, err := metricsStore.Status()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Store:", metricsStore.Path())
+		if !status.Exists {
+			fmt.Println("Status: not created yet")
+			fmt.Println("Latest Schema Version:", status.CurrentVersion)
+			return
+		}
+		fmt.Println("Schema Version:", status.Version)
+		fmt.Println("Latest Schema Version:", status.CurrentVersion)
+		if status.Version > status.CurrentVersion {
+			fmt.Println("Status: store is newer than this secmetrics build")
+			return
+		}
+		if len(status.Pending) == 0 {
+			fmt.Println("Status: up to date")
+			return
+		}
+		fmt.Println("Pending Migrations:")
+		for _, m := range status.Pending {
+			fmt.Printf("  [%d] %s\n", m.Version, m.Description)
+		}
+	case "up":
+		checkWritable(*configPath, "migrate up")
+		applied, err := metricsStore.Migrate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Store is up to date")
+			return
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied migration %d: %s\n", m.Version, m.Description)
+		}
+	default:
+		usageError("Unknown migrate subcommand: %s", args[0])
+	}
+}
+
+// migrateOnStartup applies pending store migrations, exiting on failure.
+func migrateOnStartup(metricsStore *store.FileStore) {
+	if metricsStore == nil {
+		return
+	}
+	applied, err := metricsStore.Migrate()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: migrate store: %v\n", err)
+		os.Exit(1)
+	}
+	for _, m := range applied {
+		fmt.Fprintf(os.Stderr, "Applied store migration %d: %s\n", m.Version, m.Description)
+	}
+}
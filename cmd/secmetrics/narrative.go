@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/narrative"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// narrativeOptions are the flags of the narrative subcommands.
+type narrativeOptions struct {
+	configPath, quarter, by *string
+	force                   *bool
+}
+
+// narrativeFlagSet returns the flags of a narrative subcommand.
+func narrativeFlagSet(subcommand string) (*flag.FlagSet, narrativeOptions) {
+	flags := flag.NewFlagSet("narrative "+subcommand, flag.ExitOnError)
+	opts := narrativeOptions{
+		configPath: flags.String("config", config.Path(), "path to the configuration file"),
+		quarter:    flags.String("quarter", "", "fiscal quarter as YYYY-QN, e.g. 2026-Q3 or FY2027-Q1 (default: current quarter)"),
+	}
+	switch subcommand {
+	case "draft":
+		opts.force = flags.Bool("force", false, "replace the quarter's narrative even if it was approved")
+	case "approve":
+		opts.by = flags.String("by", os.Getenv("USER"), "who reviewed and approves the narrative")
+	}
+	return flags, opts
+}
+
+// manageNarratives drafts, shows and approves quarterly report narratives.
+// Drafting is the only step that calls the summarization endpoint, and
+// only approved narratives appear in reports.
+func manageNarratives(args []string) {
+	if len(args) < 1 {
+		usageError("Error: narrative subcommand required (draft, show, approve)")
+	}
+
+	flags, opts := narrativeFlagSet(args[0])
+	flags.Parse(args[1:])
+	now := time.Now()
+	cfg, err := config.LoadOrDefault(*opts.configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	quarter, start, end, err := narrative.ParseQuarter(cfg.Fiscal, *opts.quarter, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	metricsStore := openStore(cfg)
+	if metricsStore == nil {
+		fmt.Fprintln(os.Stderr, "Error: narratives require a store (set store.path in the config file)")
+		os.Exit(1)
+	}
+	path := metricsStore.NarrativesPath()
+	drafts, err := narrative.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "draft":
+		checkWritable(*opts.configPath, "narrative draft")
+		cfg.Narrative.Client = newHTTPClient(cfg)
+		client, err := narrative.New(cfg.Narrative)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if client == nil {
+			fmt.Fprintln(os.Stderr, "Error: no summarization endpoint configured (set narrative.url in the config file)")
+			os.Exit(1)
+		}
+		existing := narrative.Find(drafts, quarter)
+		if existing != nil && existing.Status == narrative.StatusApproved && !*opts.force {
+			fmt.Fprintf(os.Stderr, "Error: the %s narrative is approved; use --force to replace it\n", quarter)
+			os.Exit(1)
+		}
+		draft, err := client.Draft(context.Background(), quarterInput(cfg, metricsStore, quarter, start, end), now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if existing != nil {
+			*existing = draft
+		} else {
+			drafts = append(drafts, draft)
+		}
+		if err := narrative.Save(path, drafts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Drafted the %s narrative in %s; edit it there and run narrative approve to use it in reports.\n\n", quarter, path)
+		fmt.Print(draft.Narrative)
+	case "show":
+		draft := narrative.Find(drafts, quarter)
+		if draft == nil {
+			fmt.Fprintf(os.Stderr, "Error: no narrative for %s in %s\n", quarter, path)
+			os.Exit(1)
+		}
+		fmt.Printf("Narrative %s (%s, generated %s", draft.Quarter, draft.Status, draft.GeneratedAt.Format(time.RFC3339))
+		if draft.Status == narrative.StatusApproved {
+			fmt.Printf(", approved by %s", draft.ApprovedBy)
+		}
+		fmt.Print(")\n\n" + draft.Narrative)
+	case "approve":
+		checkWritable(*opts.configPath, "narrative approve")
+		draft := narrative.Find(drafts, quarter)
+		if draft == nil {
+			fmt.Fprintf(os.Stderr, "Error: no narrative for %s in %s\n", quarter, path)
+			os.Exit(1)
+		}
+		draft.Status, draft.ApprovedBy, draft.ApprovedAt = narrative.StatusApproved, *opts.by, now
+		if err := narrative.Save(path, drafts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Approved the %s narrative\n", quarter)
+	default:
+		usageError("Unknown narrative subcommand: %s", args[0])
+	}
+}
+
+// quarterInput loads the store and collects the quarter's data for the
+// summarization endpoint.
+func quarterInput(cfg *config.Config, metricsStore *store.FileStore, quarter string, start, end time.Time) narrative.Input {
+	collector := newCollector(cfg)
+	if err := metricsStore.LoadInto(collector); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return narrative.Build(collector, quarter, start, end)
+}
+
+// narrativeData returns the approved narrative of the latest quarter for
+// reports, or nil when there is none or no store.
+func narrativeData(metricsStore *store.FileStore) (*reporting.NarrativeData, error) {
+	if metricsStore == nil {
+		return nil, nil
+	}
+	drafts, err := narrative.Load(metricsStore.NarrativesPath())
+	if err != nil {
+		return nil, err
+	}
+	approved := narrative.LatestApproved(drafts)
+	if approved == nil {
+		return nil, nil
+	}
+	return &reporting.NarrativeData{Quarter: approved.Quarter, Text: approved.Narrative, ApprovedBy: approved.ApprovedBy}, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+)
+
+// onePagerTrendKPIs are the headline KPIs shown as trend arrows on the
+// one-pager, topped up from the remaining KPIs when some are missing.
+var onePagerTrendKPIs = []metrics.KPIKey{metrics.KPI_MTTD, metrics.KPI_MTTR, metrics.KPI_Coverage}
+
+// onePagerData builds the executive one-pager from the collector. Risks
+// are the KPIs furthest from target; wins are KPIs on target, then KPIs
+// improving toward it. The renderers enforce the length limits.
+func onePagerData(collector *metrics.MetricsCollector, locale string) *reporting.OnePagerData {
+	score := collector.GetPostureScore()
+	onePager := &reporting.OnePagerData{Score: score, Health: metrics.ScoreHealth(score)}
+	if !collector.GetSummary().HasData.Posture {
+		onePager.Health, onePager.NoData = metrics.InsufficientData, true
+	}
+
+	kpis := collector.GetKPIS()
+	headline := make([]metrics.KPI, 0, reporting.OnePagerMaxTrends)
+	for _, key := range onePagerTrendKPIs {
+		if kpi := collector.GetKPI(key); kpi != nil {
+			headline = append(headline, *kpi)
+		}
+	}
+	for _, kpi := range kpis {
+		if len(headline) >= reporting.OnePagerMaxTrends {
+			break
+		}
+		if !containsKPI(headline, kpi.Key) {
+			headline = append(headline, kpi)
+		}
+	}
+	for _, kpi := range headline {
+		onePager.Trends = append(onePager.Trends, reporting.TrendData{
+			Name:  kpi.Name,
+			Value: kpi.Value,
+			Unit:  kpi.Unit,
+			Trend: kpiTrend(collector, kpi),
+		})
+	}
+
+	value := func(v float64) string { return reporting.FormatValue(locale, v) }
+	ranked := append([]metrics.KPI(nil), kpis...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return collector.KPIProgress(ranked[i]) < collector.KPIProgress(ranked[j])
+	})
+	var onTarget, improving []string
+	for _, kpi := range ranked {
+		def, _ := collector.GetKPIDefinition(kpi.Key)
+		if !def.MeetsTarget(kpi.Value, kpi.Target) {
+			onePager.Risks = append(onePager.Risks, fmt.Sprintf("%s at %s %s vs target %s %s", kpi.Name, value(kpi.Value), kpi.Unit, value(kpi.Target), kpi.Unit))
+			if kpiTrend(collector, kpi) == "IMPROVING" {
+				improving = append([]string{fmt.Sprintf("%s improving (now %s %s)", kpi.Name, value(kpi.Value), kpi.Unit)}, improving...)
+			}
+			continue
+		}
+		onTarget = append([]string{fmt.Sprintf("%s on target at %s %s", kpi.Name, value(kpi.Value), kpi.Unit)}, onTarget...)
+	}
+	onePager.Wins = append(onTarget, improving...)
+
+	return onePager
+}
+
+// kpiTrend returns IMPROVING, DECLINING or STABLE for a KPI from its 7-day
+// window comparison, falling back to the KPI's recorded trend.
+func kpiTrend(collector *metrics.MetricsCollector, kpi metrics.KPI) string {
+	comparison := collector.CompareWindows(kpi.Key, metrics.Window7Days, time.Now())
+	if !comparison.HasPrevious() || comparison.Current.Count == 0 {
+		if kpi.Trend == "" {
+			return "STABLE"
+		}
+		return kpi.Trend
+	}
+
+	delta := comparison.Delta
+	if def, ok := collector.GetKPIDefinition(kpi.Key); ok && def.Direction == metrics.LowerIsBetter {
+		delta = -delta
+	}
+	if delta > 0 {
+		return "IMPROVING"
+	} else if delta < 0 {
+		return "DECLINING"
+	}
+	return "STABLE"
+}
+
+func containsKPI(kpis []metrics.KPI, key metrics.KPIKey) bool {
+	for _, kpi := range kpis {
+		if kpi.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+)
+
+// parseMonth parses a YYYY-MM month in location, the local zone when nil,
+// defaulting to the current month.
+func parseMonth(month string, location *time.Location) (time.Time, time.Time, error) {
+	if location == nil {
+		location = time.Local
+	}
+	if month == "" {
+		start, end := metrics.MonthRange(time.Now().In(location))
+		return start, end, nil
+	}
+	t, err := time.ParseInLocation("2006-01", month, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q (want YYYY-MM)", month)
+	}
+	start, end := metrics.MonthRange(t)
+	return start, end, nil
+}
+
+// opsData builds the operations report for [start, end) from the collector.
+func opsData(collector *metrics.MetricsCollector, start, end time.Time) *reporting.OpsData {
+	summary := collector.GetOperationsSummary(start, end)
+	ops := &reporting.OpsData{
+		Month: start,
+		Alerts: reporting.AlertStatsData{
+			Total:                 summary.Alerts.Total,
+			Acknowledged:          summary.Alerts.Acknowledged,
+			Resolved:              summary.Alerts.Resolved,
+			Escalated:             summary.Alerts.Escalated,
+			MeanTimeToAcknowledge: summary.Alerts.MeanTimeToAcknowledge,
+			BySeverity:            countData(summary.Alerts.BySeverity),
+			BySource:              countData(summary.Alerts.BySource),
+		},
+	}
+
+	for _, incident := range summary.Incidents {
+		ops.Incidents = append(ops.Incidents, reporting.IncidentData{
+			ID:          incident.ID,
+			Title:       incident.Title,
+			Severity:    incident.Severity,
+			Status:      incident.Status,
+			DetectedAt:  incident.DetectedAt,
+			ContainedAt: incident.ContainedAt,
+			ResolvedAt:  incident.ResolvedAt,
+		})
+	}
+	for _, change := range summary.Changes {
+		ops.Changes = append(ops.Changes, change.Name+" "+change.Description)
+	}
+	for _, movement := range summary.Movements {
+		ops.Movements = append(ops.Movements, reporting.MovementData{
+			Name:  movement.Name,
+			Unit:  movement.Unit,
+			Start: movement.Start,
+			End:   movement.End,
+			Delta: movement.Delta,
+			Trend: movement.Trend,
+		})
+	}
+	return ops
+}
+
+// countData converts counts to labelled counts, largest first.
+func countData(counts map[string]int) []reporting.CountData {
+	var result []reporting.CountData
+	for label, count := range counts {
+		if label == "" {
+			label = "unknown"
+		}
+		result = append(result, reporting.CountData{Label: label, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Label < result[j].Label
+	})
+	return result
+}
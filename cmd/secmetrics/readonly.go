@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+)
+
+// readOnly is set by the global --read-only flag.
+var readOnly bool
+
+// isReadOnly reports whether mutations are disabled by --read-only or the
+// read_only setting.
+func isReadOnly(cfg *config.Config) bool {
+	return readOnly || cfg.ReadOnly
+}
+
+// checkWritable exits with an error when read-only mode is on for the
+// configuration at configPath.
+func checkWritable(configPath, action string) {
+	cfg, err := config.LoadOrDefault(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if isReadOnly(cfg) {
+		fmt.Fprintf(os.Stderr, "Error: %s is disabled in read-only mode\n", action)
+		os.Exit(1)
+	}
+}
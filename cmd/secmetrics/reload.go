@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+	"github.com/hallucinaut/secmetrics/pkg/sources"
+)
+
+// watchInterval is how often serve --watch checks the configuration file
+// for changes.
+const watchInterval = 5 * time.Second
+
+// reloader reloads the configuration of the running daemon on SIGHUP and,
+// with --watch, when the configuration file changes.
+type reloader struct {
+	path   string
+	opts   *serveOptions
+	srv    *server.Server
+	client *http.Client
+	logger *log.Logger
+	// running is the configuration as read from the file, with the
+	// settings that were reloaded since.
+	running config.Config
+	// sources is read by report rendering for the inventory and
+	// campaigns.
+	sources *atomic.Pointer[sources.Config]
+}
+
+// start reloads on SIGHUP, and on changes to the configuration file when
+// watch is set, until ctx is done. SIGHUP no longer terminates the process
+// once start returns.
+func (r *reloader) start(ctx context.Context, watch bool) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go r.run(ctx, hup, watch)
+}
+
+// run reloads on signals from hup and, when watch is set, changes to the
+// configuration file.
+
+func (r *reloader) run(ctx context.Context, hup chan os.Signal, watch bool) {
+	defer signal.Stop(hup)
+	var poll <-chan time.Time
+	last, _ := os.Stat(r.path)
+	if watch {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-poll:
+			if info, err := os.Stat(r.path); err != nil || last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+		}
+		last, _ = os.Stat(r.path)
+		if err := r.reload(); err != nil {
+			r.logger.Printf("configuration not reloaded: %v", err)
+		}
+	}
+}
+
+// reload reads the configuration file again and applies the collection
+// interval, the alerting policy and the sources to the daemon. Other
+// changes are logged as needing a restart. An invalid configuration
+// changes nothing.
+func (r *reloader) reload() error {
+	next, err := config.LoadOrDefault(r.path)
+	if err != nil {
+		return err
+	}
+	loaded := *next
+	applyServeConfig(next, r.opts, r.client)
+	collect := []server.Source{demoSource()}
+	if !demoMode {
+		sourcesCfg, err := sourcesConfig(next, r.client)
+		if err != nil {
+			return err
+		}
+		if collect, err = sources.New(sourcesCfg, r.client); err != nil {
+			return err
+		}
+	}
+	if err := r.srv.Reload(next.Server, collect); err != nil {
+		return err
+	}
+	r.sources.Store(&next.Sources)
+
+	if changed := restartSections(r.running, loaded); len(changed) > 0 {
+		r.logger.Printf("changes to %s apply on restart", strings.Join(changed, ", "))
+	}
+	r.running.Sources = loaded.Sources
+	r.running.Server.Interval = loaded.Server.Interval
+	r.running.Server.Alerting = loaded.Server.Alerting
+	return nil
+}
+
+// restartSections returns the top-level settings that differ between the
+// running and next configurations other than those a reload applies.
+func restartSections(running, next config.Config) []string {
+	for _, cfg := range []*config.Config{&running, &next} {
+		cfg.Sources = sources.Config{}
+		cfg.Server.Interval = ""
+		cfg.Server.Alerting = alerting.Config{}
+	}
+	var changed []string
+	a, b := reflect.ValueOf(running), reflect.ValueOf(next)
+	for i := 0; i < a.NumField(); i++ {
+		name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("yaml"), ",")
+		if name != "-" && !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// runsFlagSet returns the flags of a runs subcommand.
+func runsFlagSet(subcommand string) (flags *flag.FlagSet, configPath *string, limit *int) {
+	flags = flag.NewFlagSet("runs "+subcommand, flag.ExitOnError)
+	configPath = flags.String("config", config.Path(), "path to the configuration file")
+	if subcommand == "list" {
+		limit = flags.Int("limit", 20, "number of most recent runs to list (0 for all)")
+	}
+	return flags, configPath, limit
+}
+
+func manageRuns(args []string) {
+	if len(args) < 1 {
+		usageError("Error: runs subcommand required (list, show)")
+	}
+
+	flags, configPath, limit := runsFlagSet(args[0])
+	flags.Parse(args[1:])
+
+	_, collector := loadStoredCollector(*configPath)
+	runs := collector.GetRuns()
+
+	switch args[0] {
+	case "list":
+		if *limit > 0 && len(runs) > *limit {
+			runs = runs[len(runs)-*limit:]
+		}
+		if len(runs) == 0 {
+			fmt.Println("No collection runs recorded")
+			return
+		}
+		fmt.Printf("%-27s %-16s %9s  %-8s %7s  %s\n", "RUN", "STARTED", "DURATION", "STATUS", "SOURCES", "RECORDS")
+		for i := len(runs) - 1; i >= 0; i-- {
+			run := runs[i]
+			fmt.Printf("%-27s %-16s %9s  %-8s %7s  %d\n", run.ID, run.Start.Local().Format("2006-01-02 15:04"),
+				run.Duration.Round(time.Millisecond), run.Status(),
+				fmt.Sprintf("%d/%d", len(run.Sources)-len(run.Failed()), len(run.Sources)), run.Records())
+		}
+	case "show":
+		if len(runs) == 0 {
+			fmt.Fprintln(os.Stderr, "Error: no collection runs recorded")
+			os.Exit(1)
+		}
+		run := runs[len(runs)-1]
+		if flags.NArg() > 0 {
+			var err error
+			if run, err = collector.GetRun(flags.Arg(0)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		showRun(run)
+	default:
+		usageError("Unknown runs subcommand: %s", args[0])
+	}
+}
+
+// showRun prints a collection run with the outcome of each source.
+func showRun(run metrics.CollectionRun) {
+	fmt.Println("Run:", run.ID)
+	fmt.Println("Started:", run.Start.Local().Format("2006-01-02 15:04:05 MST"))
+	fmt.Println("Duration:", run.Duration.Round(time.Millisecond))
+	fmt.Println("Status:", run.Status())
+	fmt.Println("Records:", run.Records())
+	fmt.Println()
+	fmt.Println("Sources:")
+	for _, source := range run.Sources {
+		mark := "✓"
+		switch {
+		case source.Skipped():
+			mark = "-"
+		case source.Error != "":
+			mark = "✗"
+		}
+		line := fmt.Sprintf("  %s %-20s %9s  %d records", mark, source.Name, source.Duration.Round(time.Millisecond), source.Records)
+		if source.Attempts > 1 {
+			line += fmt.Sprintf(", %d attempts", source.Attempts)
+		}
+		if source.Breaker != "" {
+			line += ", breaker " + source.Breaker
+		}
+		fmt.Println(line)
+		if source.Error != "" {
+			fmt.Printf("      %s\n", source.Error)
+		}
+	}
+}
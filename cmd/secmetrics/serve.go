@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/archive"
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/delivery"
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+	"github.com/hallucinaut/secmetrics/pkg/service"
+	"github.com/hallucinaut/secmetrics/pkg/sources"
+)
+
+// serveOptions are the flags of the serve command.
+type serveOptions struct {
+	configPath, addr, interval, shutdownTimeout *string
+	readOnly, once, watch                       *bool
+	reports                                     *string
+}
+
+// serveFlagSet returns the serve command's flags.
+func serveFlagSet() (*flag.FlagSet, *serveOptions) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	opts := &serveOptions{
+		configPath:      flags.String("config", config.Path(), "path to the configuration file"),
+		addr:            flags.String("addr", "", "listen address (overrides server.addr)"),
+		interval:        flags.String("interval", "", "collection interval (overrides server.interval)"),
+		shutdownTimeout: flags.String("shutdown-timeout", "", "time to wait for in-flight work on shutdown (overrides server.shutdown_timeout)"),
+		readOnly:        flags.Bool("read-only", false, "serve the store without collecting, ingesting or writing (overrides read_only)"),
+		once:            flags.Bool("once", false, "run one collection, deliver --report and exit instead of serving, e.g. from a CronJob"),
+		reports:         flags.String("report", "", "comma-separated report types --once delivers to the delivery targets"),
+		watch:           flags.Bool("watch", false, "reload the configuration when the file changes, as on SIGHUP"),
+	}
+	return flags, opts
+}
+
+func serve(args []string) {
+	flags, opts := serveFlagSet()
+	flags.Parse(args)
+	cfg, err := config.LoadOrDefault(*opts.configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	loaded := *cfg
+	client := newHTTPClient(cfg)
+	applyServeConfig(cfg, opts, client)
+
+	reportTypes := splitList(*opts.reports)
+	for _, reportType := range reportTypes {
+		if err := checkReportType(reportType); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+	if len(reportTypes) > 0 && !*opts.once {
+		usageError("Error: --report requires --once")
+	}
+	if *opts.watch && *opts.once {
+		usageError("Error: --watch cannot be combined with --once")
+	}
+
+	metricsStore := openStore(cfg)
+	if demoMode {
+		// Demo data is served from memory and never persisted.
+		metricsStore = nil
+	}
+	if !cfg.Server.ReadOnly {
+		migrateOnStartup(metricsStore)
+	}
+
+	brand, err := cfg.Report.Branding.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	classification, _ := reporting.ParseClassification(cfg.Report.Classification)
+	timeZone, _ := reporting.ParseTimeZone(cfg.Report.TimeZone)
+	var reportSources atomic.Pointer[sources.Config]
+	reportSources.Store(&cfg.Sources)
+	render := func(collector *metrics.MetricsCollector, reportType string, location *time.Location) (string, error) {
+		if location == nil {
+			location = timeZone
+		}
+		return renderCollectorReport(collector, reportType, cfg.Report.Locale, location, brand, classification, reportSources.Load())
+	}
+	srv, err := server.New(cfg.Server, collectionSources(cfg), metricsStore, render)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	srv.SetDeliver(scheduledDelivery(cfg, classification, srv.Clock()))
+	if metricsStore != nil && !cfg.Server.ReadOnly {
+		// Scheduled reports are archived under their report ID, like the
+		// reports of the report command
+		srv.SetArchive(func(collector *metrics.MetricsCollector, reportType string, location *time.Location, id string) (string, error) {
+			if location == nil {
+				location = timeZone
+			}
+			report, err := collectorReport(collector, reportType, cfg.Report.Locale, location, brand, classification, reportSources.Load())
+			if err != nil {
+				return "", err
+			}
+			content, ext, err := renderReport(report, reportType, "")
+			if err != nil {
+				return "", err
+			}
+			version := &archive.Version{ID: id, Type: reportType, Version: 1, CreatedAt: report.CreatedAt}
+			return content, archiveReport(metricsStore, version, report, []reportArtifact{{content: content, ext: ext}})
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *opts.once {
+		serveOnce(ctx, srv, reportTypes)
+		return
+	}
+	reload := &reloader{
+		path:    *opts.configPath,
+		opts:    opts,
+		srv:     srv,
+		client:  client,
+		logger:  log.New(os.Stderr, "secmetrics: ", log.LstdFlags),
+		running: loaded,
+		sources: &reportSources,
+	}
+	reload.start(ctx, *opts.watch)
+	if err := service.Run(ctx, srv.Run); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// applyServeConfig sets the server settings of cfg from the flags in opts
+// and the rest of cfg, with client for the server's outbound requests.
+func applyServeConfig(cfg *config.Config, opts *serveOptions, client *http.Client) {
+	if *opts.addr != "" {
+		cfg.Server.Addr = *opts.addr
+	}
+	if *opts.interval != "" {
+		cfg.Server.Interval = *opts.interval
+	}
+	if *opts.shutdownTimeout != "" {
+		cfg.Server.ShutdownTimeout = *opts.shutdownTimeout
+	}
+	cfg.Server.Taxonomy = cfg.Taxonomy
+	cfg.Server.Fiscal = cfg.Fiscal
+	cfg.Server.Scoring = cfg.Scoring
+	cfg.Server.APIVersion, _ = metrics.ParseAPIVersion(cfg.APIVersion)
+	cfg.Server.ReadOnly = isReadOnly(cfg) || *opts.readOnly
+	cfg.Server.Auth.OIDC.Client = client
+	cfg.Server.EventBus.Client = cfg.Server.Auth.OIDC.Client
+	cfg.Server.GRCExport.Client = cfg.Server.Auth.OIDC.Client
+	cfg.Server.Ticketing.Client = cfg.Server.Auth.OIDC.Client
+	if transport, ok := cfg.Server.Auth.OIDC.Client.Transport.(*http.Transport); ok {
+		cfg.Server.Redis.TLSConfig = transport.TLSClientConfig
+		cfg.Server.EventBus.TLSConfig = transport.TLSClientConfig
+		cfg.Server.SIEM.TLSConfig = transport.TLSClientConfig
+	}
+	cfg.Server.Version = version
+}
+
+// serveOnce runs one collection and report cycle of srv and exits with the
+// status of collect, or 1 when a report was not delivered. The daemon has
+// logged the errors of failed sources.
+func serveOnce(ctx context.Context, srv *server.Server, reportTypes []string) {
+	run, err := srv.RunOnce(ctx, reportTypes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if run == nil {
+		return
+	}
+	fmt.Printf("Run %s: %s, %d records from %d sources in %s\n", run.ID, run.Status(), run.Records(), len(run.Sources), run.Duration.Round(time.Millisecond))
+	if status := runExitStatus(run); status != 0 {
+		os.Exit(status)
+	}
+}
+
+// scheduledDelivery returns how the daemon delivers scheduled reports, or
+// nil when no delivery targets are configured. Retention is pruned as of
+// clk, the server's clock.
+func scheduledDelivery(cfg *config.Config, classification reporting.Classification, clk clock.Clock) server.DeliverFunc {
+	client := newHTTPClient(cfg)
+	cipher, err := encryption.New(cfg.Encryption, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	targets, err := delivery.NewTargets(cfg.Delivery, client, cipher)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, filename string, content []byte) error {
+		if err := delivery.DeliverAll(ctx, targets, filename, classification, content); err != nil {
+			return err
+		}
+		return delivery.PruneAll(ctx, targets, clk.Now())
+	}
+}
+
+// collectionSources returns the external sources enabled in cfg, or only
+// the demo source with --demo.
+func collectionSources(cfg *config.Config) []server.Source {
+	if demoMode {
+		return []server.Source{demoSource()}
+	}
+	client := newHTTPClient(cfg)
+	sourcesCfg, err := sourcesConfig(cfg, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	external, err := sources.New(sourcesCfg, client)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return external
+}
+
+// sourcesConfig returns the sources section of cfg with the cipher that
+// encrypts cached API responses when encryption at rest is enabled. The
+// cipher is only set up when a source caches, as a KMS key costs a call.
+func sourcesConfig(cfg *config.Config, client *http.Client) (sources.Config, error) {
+	sourcesCfg := cfg.Sources
+	for _, policy := range sourcesCfg.Policies {
+		if policy.CacheTTL != "" {
+			cipher, err := encryption.New(cfg.Encryption, client)
+			sourcesCfg.Cipher = cipher
+			return sourcesCfg, err
+		}
+	}
+	return sourcesCfg, nil
+}
+
+// renderCollectorReport renders a report of reportType from collector,
+// formatted for locale with timestamps in location, styled with brand and
+// marked with classification.
+// The gaps report and the campaign status section read the inventory and
+// campaigns of sourcesCfg, if set.
+func renderCollectorReport(collector *metrics.MetricsCollector, reportType, locale string, location *time.Location, brand *reporting.Brand, classification reporting.Classification, sourcesCfg *sources.Config) (string, error) {
+	report, err := collectorReport(collector, reportType, locale, location, brand, classification, sourcesCfg)
+	if err != nil {
+		return "", err
+	}
+	content, _, err := renderReport(report, reportType, "")
+	return content, err
+}
+
+// collectorReport assembles the report renderCollectorReport renders.
+func collectorReport(collector *metrics.MetricsCollector, reportType, locale string, location *time.Location, brand *reporting.Brand, classification reporting.Classification, sourcesCfg *sources.Config) (*reporting.Report, error) {
+	if err := checkReportType(reportType); err != nil {
+		return nil, err
+	}
+	report := buildReport(collector, locale)
+	report.Brand = brand
+	report.Classification = classification
+	report.Location = location
+	if sourcesCfg == nil {
+		sourcesCfg = &sources.Config{}
+	}
+	if err := addReportData(report, collector, reportType, "", sourcesCfg, time.Now()); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/delivery"
+)
+
+func TestScheduledDeliveryPrunesByTheServerClock(t *testing.T) {
+	dir := t.TempDir()
+	archived := filepath.Join(dir, "2026", "08", "01", "weekly.md")
+	if err := os.MkdirAll(filepath.Dir(archived), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archived, []byte("# Weekly"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Delivery: delivery.Config{Local: &delivery.LocalConfig{Path: dir, RetentionDays: 30}}}
+
+	// Within retention on the server's clock, however late the wall clock
+	deliver := scheduledDelivery(cfg, "", clock.NewFake(time.Date(2026, 8, 15, 0, 0, 0, 0, time.UTC)))
+	if err := deliver(context.Background(), "daily.md", []byte("# Daily")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(archived); err != nil {
+		t.Errorf("report within retention of the server clock pruned: %v", err)
+	}
+
+	deliver = scheduledDelivery(cfg, "", clock.NewFake(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)))
+	if err := deliver(context.Background(), "daily.md", []byte("# Daily")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(archived); !os.IsNotExist(err) {
+		t.Errorf("report past retention kept: %v", err)
+	}
+}
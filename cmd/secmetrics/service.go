@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/service"
+)
+
+// serviceOptions are the flags of the service install command.
+type serviceOptions struct {
+	configPath, name, user, password, restart, unitDir *string
+	print, noStart                                     *bool
+}
+
+// serviceFlagSet returns the service install command's flags.
+func serviceFlagSet() (*flag.FlagSet, *serviceOptions) {
+	flags := flag.NewFlagSet("service install", flag.ExitOnError)
+	opts := &serviceOptions{
+		configPath: flags.String("config", config.Path(), "configuration file the service runs with"),
+		name:       flags.String("name", "", "name of the systemd unit or Windows service (default secmetrics, or secmetrics-<profile> with --profile)"),
+		user:       flags.String("user", "", "user the service runs as (default root, or LocalSystem on Windows)"),
+		password:   flags.String("password", "", "password of --user on Windows"),
+		restart:    flags.String("restart", service.RestartOnFailure, "restart policy: always, on-failure or no"),
+		unitDir:    flags.String("unit-dir", service.DefaultUnitDir, "directory the systemd unit is written to"),
+		print:      flags.Bool("print", false, "print the systemd unit instead of installing it"),
+		noStart:    flags.Bool("no-start", false, "install and enable the service without starting it"),
+	}
+	return flags, opts
+}
+
+func manageService(args []string) {
+	if len(args) < 1 {
+		usageError("Error: service subcommand required (install)")
+	}
+	switch args[0] {
+	case "install":
+		installService(args[1:])
+	default:
+		usageError("Unknown service subcommand: %s", args[0])
+	}
+}
+
+// installService registers serve as a systemd unit on Linux or a Windows
+// service on Windows.
+func installService(args []string) {
+	flags, opts := serviceFlagSet()
+	flags.Parse(args)
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: locate executable: %v\n", err)
+		os.Exit(1)
+	}
+	configPath, err := filepath.Abs(*opts.configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Each profile runs as a service of its own
+	profile := os.Getenv(config.ProfileEnv)
+	name := *opts.name
+	if name == "" {
+		name = service.DefaultName
+		if profile != "" {
+			name += "-" + profile
+		}
+	}
+	cfg := service.Config{Name: name, Executable: exe, ConfigPath: configPath, Profile: profile, User: *opts.user,
+		Password: *opts.password, Restart: *opts.restart}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *opts.print {
+		fmt.Print(service.SystemdUnit(cfg))
+		return
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; the service runs with the default configuration until it exists\n", err)
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		if cfg.User != "" {
+			if _, err := user.Lookup(cfg.User); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v; create it first, e.g. useradd --system --no-create-home %s\n", err, cfg.User)
+				os.Exit(1)
+			}
+		}
+		path, err := service.InstallSystemd(cfg, *opts.unitDir, !*opts.noStart, systemctl)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Installed", path)
+		fmt.Printf("Check it with: systemctl status %s\n", cfg.Name)
+	case "windows":
+		if err := service.InstallWindows(cfg, !*opts.noStart); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Installed Windows service", cfg.Name)
+		fmt.Printf("Check it with: sc.exe query %s\n", cfg.Name)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: service install supports Linux with systemd and Windows, not %s\n", runtime.GOOS)
+		os.Exit(1)
+	}
+}
+
+// systemctl runs systemctl with args, passing its output through.
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	return cmd.Run()
+}
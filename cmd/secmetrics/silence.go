@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// silenceOptions are the flags of silence create.
+type silenceOptions struct {
+	configPath, from, until, kpis, categories, comment, by *string
+}
+
+// silenceFlagSet returns the flags of a silence subcommand.
+func silenceFlagSet(subcommand string) (*flag.FlagSet, silenceOptions) {
+	flags := flag.NewFlagSet("silence "+subcommand, flag.ExitOnError)
+	opts := silenceOptions{configPath: flags.String("config", config.Path(), "path to the configuration file")}
+	if subcommand == "create" {
+		opts.from = flags.String("from", "", "start of the silence, RFC 3339 time or YYYY-MM-DD date (default now)")
+		opts.until = flags.String("until", "", "end of the silence: RFC 3339 time, YYYY-MM-DD date or duration from the start, e.g. 2h")
+		opts.kpis = flags.String("kpi", "", "comma-separated KPI keys to silence (default all)")
+		opts.categories = flags.String("category", "", "comma-separated KPI categories to silence (default all)")
+		opts.comment = flags.String("comment", "", "reason for the silence, e.g. the change ticket")
+		opts.by = flags.String("by", os.Getenv("USER"), "who creates the silence")
+	}
+	return flags, opts
+}
+
+// parseSilenceTime parses an RFC 3339 time or a YYYY-MM-DD date.
+func parseSilenceTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// openStateStore returns the store next to which the silence and approval
+// commands keep their state, exiting when none is configured.
+func openStateStore(configPath string) *store.FileStore {
+	cfg, err := config.LoadOrDefault(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	metricsStore := openStore(cfg)
+	if metricsStore == nil {
+		fmt.Fprintln(os.Stderr, "Error: no store configured (set store.path in the config file)")
+		os.Exit(1)
+	}
+	return metricsStore
+}
+
+// manageSilences creates, lists and expires the silences muting alert
+// notifications, e.g. for maintenance windows. A running server reads them
+// at each evaluation.
+func manageSilences(args []string) {
+	if len(args) < 1 {
+		usageError("Error: silence subcommand required (create, list, expire)")
+	}
+
+	flags, opts := silenceFlagSet(args[0])
+	flags.Parse(args[1:])
+	now := time.Now()
+
+	switch args[0] {
+	case "create":
+		checkWritable(*opts.configPath, "silence create")
+		if *opts.until == "" {
+			usageError("Error: --until is required")
+		}
+		silence := alerting.Silence{
+			ID:         alerting.NewSilenceID(),
+			Categories: splitList(*opts.categories),
+			StartsAt:   now,
+			Comment:    *opts.comment,
+			CreatedBy:  *opts.by,
+		}
+		for _, key := range splitList(*opts.kpis) {
+			silence.KPIs = append(silence.KPIs, metrics.KPIKey(key))
+		}
+		if *opts.from != "" {
+			start, err := parseSilenceTime(*opts.from)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: --from: want an RFC 3339 time or YYYY-MM-DD date, got %q\n", *opts.from)
+				os.Exit(1)
+			}
+			silence.StartsAt = start
+		}
+		if d, err := time.ParseDuration(*opts.until); err == nil {
+			silence.EndsAt = silence.StartsAt.Add(d)
+		} else if silence.EndsAt, err = parseSilenceTime(*opts.until); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --until: want an RFC 3339 time, YYYY-MM-DD date or duration, got %q\n", *opts.until)
+			os.Exit(1)
+		}
+
+		metricsStore := openStateStore(*opts.configPath)
+		state, err := metricsStore.LoadAlerting()
+		if err == nil {
+			err = state.AddSilence(silence)
+		}
+		if err == nil {
+			err = metricsStore.SaveAlerting(state)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Created silence %s until %s\n", silence.ID, silence.EndsAt.Format(time.RFC3339))
+	case "list":
+		state, err := openStateStore(*opts.configPath).LoadAlerting()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, silence := range state.Silences {
+			status := "active"
+			switch {
+			case !now.Before(silence.EndsAt):
+				status = "expired"
+			case now.Before(silence.StartsAt):
+				status = "pending"
+			}
+			scope := "all alerts"
+			if keys := append(append([]string(nil), silence.Categories...), kpiStrings(silence.KPIs)...); len(keys) > 0 {
+				scope = strings.Join(keys, ",")
+			}
+			fmt.Printf("%-16s %-8s %s - %s  %-30s %s\n", silence.ID, status,
+				silence.StartsAt.Format(time.RFC3339), silence.EndsAt.Format(time.RFC3339), scope, silence.Comment)
+		}
+	case "expire":
+		checkWritable(*opts.configPath, "silence expire")
+		if flags.NArg() < 1 {
+			usageError("Error: silence id required")
+		}
+		id := flags.Arg(0)
+		metricsStore := openStateStore(*opts.configPath)
+		state, err := metricsStore.LoadAlerting()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !state.ExpireSilence(id, now) {
+			fmt.Fprintf(os.Stderr, "Error: silence %s not found\n", id)
+			os.Exit(1)
+		}
+		if err := metricsStore.SaveAlerting(state); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Expired silence %s\n", id)
+	default:
+		usageError("Unknown silence subcommand: %s", args[0])
+	}
+}
+
+// kpiStrings converts KPI keys to strings.
+func kpiStrings(keys []metrics.KPIKey) []string {
+	strs := make([]string, len(keys))
+	for i, key := range keys {
+		strs[i] = string(key)
+	}
+	return strs
+}
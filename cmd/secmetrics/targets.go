@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+)
+
+// targetsData builds the targets matrix: every KPI against its target with
+// the forecast time to reach it as of now and the fiscal quarter that
+// falls in, largest gap first.
+func targetsData(collector *metrics.MetricsCollector, now time.Time) *reporting.TargetsData {
+	data := &reporting.TargetsData{}
+	calendar := collector.GetFiscalCalendar()
+	for _, kpi := range collector.GetKPIS() {
+		def, ok := collector.GetKPIDefinition(kpi.Key)
+		if !ok {
+			def = metrics.KPIDefinition{Direction: metrics.HigherIsBetter}
+		}
+		row := reporting.TargetData{
+			Name:      kpi.Name,
+			Owner:     kpi.Owner,
+			Value:     kpi.Value,
+			Target:    kpi.Target,
+			Unit:      kpi.Unit,
+			Progress:  collector.KPIProgress(kpi),
+			Direction: string(def.Direction),
+		}
+		if !def.MeetsTarget(kpi.Value, kpi.Target) {
+			row.Gap = kpi.Target - kpi.Value
+			if def.Direction == metrics.LowerIsBetter {
+				row.Gap = -row.Gap
+			}
+			// Rounded so float error does not show, e.g. 2.9299999999999997
+			row.Gap = math.Round(row.Gap*1e6) / 1e6
+		}
+
+		forecast := collector.ForecastKPI(kpi, now)
+		switch {
+		case forecast.OnTarget:
+			row.Forecast = reporting.ForecastOnTarget
+		case !forecast.Fitted:
+			row.Forecast = reporting.ForecastNoHistory
+		case forecast.Converging:
+			row.Forecast, row.TimeToTarget = reporting.ForecastConverging, forecast.TimeToTarget
+			// Estimates capped at the horizon fall in no particular quarter
+			if forecast.TimeToTarget < metrics.ForecastHorizon {
+				row.TargetQuarter = calendar.QuarterOf(now.Add(forecast.TimeToTarget)).Label
+			}
+		default:
+			row.Forecast = reporting.ForecastDiverging
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	sort.SliceStable(data.Rows, func(i, j int) bool {
+		if data.Rows[i].Progress != data.Rows[j].Progress {
+			return data.Rows[i].Progress < data.Rows[j].Progress
+		}
+		return data.Rows[i].Name < data.Rows[j].Name
+	})
+	return data
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/hallucinaut/secmetrics/pkg/config"
+	"github.com/hallucinaut/secmetrics/pkg/update"
+)
+
+// updateFlagSet returns the update command's flags.
+func updateFlagSet() (flags *flag.FlagSet, configPath *string, check *bool) {
+	flags, configPath = configFlagSet("update")
+	check = flags.Bool("check", false, "only report whether a newer release is available")
+	return flags, configPath, check
+}
+
+// selfUpdate replaces the running binary with the latest verified release.
+func selfUpdate(args []string) {
+	flags, configPath, check := updateFlagSet()
+	flags.Parse(args)
+
+	cfg, err := config.LoadOrDefault(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !*check && isReadOnly(cfg) {
+		fmt.Fprintln(os.Stderr, "Error: update is disabled in read-only mode (use --check)")
+		os.Exit(1)
+	}
+	updater, err := update.New(cfg.Update, newHTTPClient(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	release, err := updater.Check(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !update.Newer(release.Version, version) {
+		fmt.Printf("secmetrics %s is up to date\n", version)
+		return
+	}
+	fmt.Printf("secmetrics %s is available (installed: %s)\n", release.Version, version)
+	if *check {
+		return
+	}
+
+	binary, ok := release.Binary(runtime.GOOS, runtime.GOARCH)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: release %s has no build for %s/%s\n", release.Version, runtime.GOOS, runtime.GOARCH)
+		os.Exit(1)
+	}
+	data, err := updater.Download(ctx, binary)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: locate executable: %v\n", err)
+		os.Exit(1)
+	}
+	if err := update.Install(exe, data); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: install update: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated %s to secmetrics %s\n", exe, release.Version)
+}
@@ -2,4 +2,22 @@ module github.com/hallucinaut/secmetrics
 
 go 1.21
 
-require gopkg.in/yaml.v3 v3.0.1
\ No newline at end of file
+require (
+	github.com/tetratelabs/wazero v1.7.3
+	golang.org/x/sys v0.30.0
+	golang.org/x/text v0.22.0
+	gonum.org/v1/plot v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	git.sr.ht/~sbinet/gg v0.5.0 // indirect
+	github.com/ajstarks/svgo v0.0.0-20211024235047-1546f124cd8b // indirect
+	github.com/campoy/embedmd v1.0.0 // indirect
+	github.com/go-fonts/liberation v0.3.1 // indirect
+	github.com/go-latex/latex v0.0.0-20230307184459-12ec69307ad9 // indirect
+	github.com/go-pdf/fpdf v0.8.0 // indirect
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/image v0.11.0 // indirect
+)
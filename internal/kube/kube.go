@@ -0,0 +1,219 @@
+// Package kube is a minimal Kubernetes API client for coordination.k8s.io
+// Leases, enough for leader election between replicas.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ServiceAccountDir holds the credentials Kubernetes mounts into pods.
+const ServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// microTime is the layout of Kubernetes MicroTime values.
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+var (
+	// ErrNotFound is returned when the lease does not exist.
+	ErrNotFound = errors.New("lease not found")
+	// ErrConflict is returned when the lease changed since it was read.
+	ErrConflict = errors.New("lease was modified concurrently")
+)
+
+// Lease is a coordination.k8s.io/v1 Lease.
+type Lease struct {
+	Name      string
+	Namespace string
+	// ResourceVersion is set by the API server; updates fail with
+	// ErrConflict when it is stale.
+	ResourceVersion string
+	Holder          string
+	Duration        time.Duration
+	AcquireTime     time.Time
+	RenewTime       time.Time
+	Transitions     int
+}
+
+// Expired reports whether the holder's claim has lapsed at now.
+func (l *Lease) Expired(now time.Time) bool {
+	return l.Holder == "" || !now.Before(l.RenewTime.Add(l.Duration))
+}
+
+// Client calls the Kubernetes API.
+type Client struct {
+	base   string
+	client *http.Client
+	token  func() (string, error)
+}
+
+// NewClient creates a client for the API server at base authenticating
+// with a static bearer token; an empty token sends none.
+func NewClient(base string, client *http.Client, token string) *Client {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{base: strings.TrimSuffix(base, "/"), client: client, token: func() (string, error) { return token, nil }}
+}
+
+// InCluster creates a client from the pod's service account and returns
+// it with the pod's namespace. The token is reread on every request, as
+// Kubernetes rotates it.
+func InCluster() (*Client, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, "", fmt.Errorf("not running in a Kubernetes cluster (KUBERNETES_SERVICE_HOST is not set)")
+	}
+	ca, err := os.ReadFile(filepath.Join(ServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, "", fmt.Errorf("read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, "", fmt.Errorf("service account CA contains no certificates")
+	}
+	namespace, err := os.ReadFile(filepath.Join(ServiceAccountDir, "namespace"))
+	if err != nil {
+		return nil, "", fmt.Errorf("read service account namespace: %w", err)
+	}
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+	}
+	token := func() (string, error) {
+		data, err := os.ReadFile(filepath.Join(ServiceAccountDir, "token"))
+		if err != nil {
+			return "", fmt.Errorf("read service account token: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	c := &Client{base: "https://" + net.JoinHostPort(host, port), client: client, token: token}
+	return c, strings.TrimSpace(string(namespace)), nil
+}
+
+// leaseObject is the API representation of a Lease.
+type leaseObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+		LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+func toObject(l *Lease) leaseObject {
+	var o leaseObject
+	o.APIVersion, o.Kind = "coordination.k8s.io/v1", "Lease"
+	o.Metadata.Name, o.Metadata.Namespace, o.Metadata.ResourceVersion = l.Name, l.Namespace, l.ResourceVersion
+	o.Spec.HolderIdentity = l.Holder
+	o.Spec.LeaseDurationSeconds = int(l.Duration / time.Second)
+	if !l.AcquireTime.IsZero() {
+		o.Spec.AcquireTime = l.AcquireTime.UTC().Format(microTime)
+	}
+	if !l.RenewTime.IsZero() {
+		o.Spec.RenewTime = l.RenewTime.UTC().Format(microTime)
+	}
+	o.Spec.LeaseTransitions = l.Transitions
+	return o
+}
+
+func fromObject(o leaseObject) *Lease {
+	l := &Lease{
+		Name:            o.Metadata.Name,
+		Namespace:       o.Metadata.Namespace,
+		ResourceVersion: o.Metadata.ResourceVersion,
+		Holder:          o.Spec.HolderIdentity,
+		Duration:        time.Duration(o.Spec.LeaseDurationSeconds) * time.Second,
+		Transitions:     o.Spec.LeaseTransitions,
+	}
+	l.AcquireTime, _ = time.Parse(time.RFC3339Nano, o.Spec.AcquireTime)
+	l.RenewTime, _ = time.Parse(time.RFC3339Nano, o.Spec.RenewTime)
+	return l
+}
+
+func leasesPath(namespace string) string {
+	return "/apis/coordination.k8s.io/v1/namespaces/" + url.PathEscape(namespace) + "/leases"
+}
+
+// GetLease reads a lease, returning ErrNotFound when it does not exist.
+func (c *Client) GetLease(ctx context.Context, namespace, name string) (*Lease, error) {
+	return c.do(ctx, http.MethodGet, leasesPath(namespace)+"/"+url.PathEscape(name), nil)
+}
+
+// CreateLease creates a lease, returning ErrConflict when it already
+// exists.
+func (c *Client) CreateLease(ctx context.Context, lease *Lease) (*Lease, error) {
+	return c.do(ctx, http.MethodPost, leasesPath(lease.Namespace), lease)
+}
+
+// UpdateLease replaces a lease, returning ErrConflict when it changed
+// since lease was read.
+func (c *Client) UpdateLease(ctx context.Context, lease *Lease) (*Lease, error) {
+	return c.do(ctx, http.MethodPut, leasesPath(lease.Namespace)+"/"+url.PathEscape(lease.Name), lease)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, lease *Lease) (*Lease, error) {
+	var body io.Reader
+	if lease != nil {
+		data, err := json.Marshal(toObject(lease))
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if lease != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	token, err := c.token()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes %s lease: %w", strings.ToLower(method), err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode == http.StatusConflict:
+		return nil, ErrConflict
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("kubernetes %s lease: unexpected status %s: %s", strings.ToLower(method), resp.Status, strings.TrimSpace(string(data)))
+	}
+	var o leaseObject
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		return nil, fmt.Errorf("kubernetes lease: %w", err)
+	}
+	return fromObject(o), nil
+}
@@ -0,0 +1,82 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLeaseRoundTrip(t *testing.T) {
+	var stored map[string]interface{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		const collection = "/apis/coordination.k8s.io/v1/namespaces/ops/leases"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == collection+"/secmetrics":
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		case r.Method == http.MethodPost && r.URL.Path == collection:
+			json.NewDecoder(r.Body).Decode(&stored)
+			stored["metadata"].(map[string]interface{})["resourceVersion"] = "1"
+		case r.Method == http.MethodPut && r.URL.Path == collection+"/secmetrics":
+			var update map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&update)
+			if update["metadata"].(map[string]interface{})["resourceVersion"] != stored["metadata"].(map[string]interface{})["resourceVersion"] {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			stored = update
+			stored["metadata"].(map[string]interface{})["resourceVersion"] = "2"
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(stored)
+	}))
+	defer api.Close()
+
+	client := NewClient(api.URL, api.Client(), "token")
+	ctx := context.Background()
+	if _, err := client.GetLease(ctx, "ops", "secmetrics"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing lease: %v", err)
+	}
+
+	now := time.Date(2026, 10, 1, 9, 0, 0, 123456000, time.UTC)
+	created, err := client.CreateLease(ctx, &Lease{Name: "secmetrics", Namespace: "ops", Holder: "pod-a",
+		Duration: 15 * time.Second, AcquireTime: now, RenewTime: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec := stored["spec"].(map[string]interface{}); spec["renewTime"] != "2026-10-01T09:00:00.123456Z" || spec["leaseDurationSeconds"] != 15.0 {
+		t.Errorf("stored spec = %v", spec)
+	}
+
+	lease, err := client.GetLease(ctx, "ops", "secmetrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Holder != "pod-a" || !lease.RenewTime.Equal(now) || lease.ResourceVersion != created.ResourceVersion {
+		t.Errorf("lease = %+v", lease)
+	}
+	if lease.Expired(now.Add(10*time.Second)) || !lease.Expired(now.Add(15*time.Second)) {
+		t.Error("lease expiry does not follow its duration")
+	}
+
+	lease.RenewTime = now.Add(5 * time.Second)
+	if _, err := client.UpdateLease(ctx, lease); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.UpdateLease(ctx, lease); !errors.Is(err, ErrConflict) {
+		t.Errorf("update with a stale resource version: %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+// Package nats is a minimal NATS client, enough to publish messages and
+// confirm that the server received them.
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds connecting and each flush when the context has no
+// earlier deadline.
+const DefaultTimeout = 10 * time.Second
+
+// Options configures a connection.
+type Options struct {
+	// URL is nats://host:port, or tls://host:port to require TLS. User
+	// information in the URL is used as credentials.
+	URL string
+	// Token authenticates with a token; User and Password with a user.
+	Token    string
+	User     string
+	Password string
+	// Name identifies the client in server monitoring.
+	Name string
+	// TLS configures TLS when the URL or the server requires it.
+	TLS *tls.Config
+}
+
+// Message is a message to publish.
+type Message struct {
+	Subject string
+	Data    []byte
+}
+
+// conn is one connection to the server.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+	// maxPayload is the largest message the server accepts.
+	maxPayload int
+}
+
+// serverInfo is the part of the server's INFO message the client uses.
+type serverInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+// dial connects, authenticates and waits until the server has accepted
+// the connection.
+func dial(ctx context.Context, opts Options) (*conn, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("nats: invalid url %q", opts.URL)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil && opts.User == "" && opts.Token == "" {
+		if password, ok := u.User.Password(); ok {
+			opts.User, opts.Password = u.User.Username(), password
+		} else {
+			opts.Token = u.User.Username()
+		}
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+	nc, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	nc.SetDeadline(deadline)
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+
+	line, err := c.readLine()
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	payload, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		nc.Close()
+		return nil, fmt.Errorf("nats: expected INFO, got %q", line)
+	}
+	var info serverInfo
+	if err := json.Unmarshal([]byte(payload), &info); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("nats: parse INFO: %w", err)
+	}
+	c.maxPayload = info.MaxPayload
+
+	secure := u.Scheme == "tls"
+	if secure || info.TLSRequired {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if opts.TLS != nil {
+			config = opts.TLS.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = u.Hostname()
+		}
+		tc := tls.Client(nc, config)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("nats: %w", err)
+		}
+		c.Conn = tc
+		c.r = bufio.NewReader(tc)
+	}
+	c.w = bufio.NewWriter(c.Conn)
+
+	connect := map[string]interface{}{
+		"verbose": false, "pedantic": false, "lang": "go", "version": "secmetrics", "protocol": 1,
+		"name": opts.Name, "tls_required": secure,
+	}
+	switch {
+	case opts.Token != "":
+		connect["auth_token"] = opts.Token
+	case opts.User != "":
+		connect["user"], connect["pass"] = opts.User, opts.Password
+	}
+	data, _ := json.Marshal(connect)
+	fmt.Fprintf(c.w, "CONNECT %s\r\n", data)
+	if err := c.flush(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// publish sends messages and waits for the server to confirm them.
+func (c *conn) publish(ctx context.Context, messages []Message) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultTimeout)
+	}
+	for _, message := range messages {
+		if c.maxPayload > 0 && len(message.Data) > c.maxPayload {
+			return fmt.Errorf("nats: message to %s exceeds the server's maximum payload of %d bytes", message.Subject, c.maxPayload)
+		}
+	}
+	c.SetDeadline(deadline)
+	defer c.SetDeadline(time.Time{})
+	for _, message := range messages {
+		fmt.Fprintf(c.w, "PUB %s %d\r\n", message.Subject, len(message.Data))
+		c.w.Write(message.Data)
+		c.w.WriteString("\r\n")
+	}
+	return c.flush()
+}
+
+// flush sends a PING after the queued protocol messages and reads until
+// its PONG, answering the server's own PINGs on the way.
+func (c *conn) flush() error {
+	c.w.WriteString("PING\r\n")
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			c.w.WriteString("PONG\r\n")
+			if err := c.w.Flush(); err != nil {
+				return fmt.Errorf("nats: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: server error: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+		// +OK and further INFO messages need no answer.
+	}
+}
+
+// readLine reads one protocol line without its CRLF.
+func (c *conn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("nats: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Client publishes over one connection, redialing after failures. It is
+// safe for concurrent use; publishes are serialized.
+type Client struct {
+	opts Options
+
+	mu   sync.Mutex
+	conn *conn
+}
+
+// NewClient creates a client; it connects on the first publish.
+func NewClient(opts Options) *Client {
+	return &Client{opts: opts}
+}
+
+// Publish sends messages and returns once the server has processed them.
+// A server error, such as a permissions violation, fails the whole call.
+func (c *Client) Publish(ctx context.Context, messages ...Message) error {
+	for _, message := range messages {
+		if message.Subject == "" || strings.ContainsAny(message.Subject, " \t\r\n") {
+			return fmt.Errorf("nats: invalid subject %q", message.Subject)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		conn, err := dial(ctx, c.opts)
+		if err != nil {
+			return err
+		}
+		c.conn = conn
+	}
+	if err := c.conn.publish(ctx, messages); err != nil {
+		// The server closes the connection after most errors; start
+		// afresh next time.
+		c.conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
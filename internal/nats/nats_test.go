@@ -0,0 +1,130 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServer speaks enough of the NATS protocol for the client: INFO,
+// CONNECT with a token, PUB and PING. Publishing to "forbidden" is a
+// permissions violation.
+type fakeServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	received []Message
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeServer{listener: listener}
+	go f.serve()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeServer) serve() {
+	for {
+		nc, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(nc)
+	}
+}
+
+func (f *fakeServer) handle(nc net.Conn) {
+	defer nc.Close()
+	r, w := bufio.NewReader(nc), bufio.NewWriter(nc)
+	fmt.Fprint(w, "INFO {\"server_id\":\"fake\",\"auth_required\":true,\"max_payload\":64}\r\n")
+	w.Flush()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, args, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch verb {
+		case "CONNECT":
+			var connect struct {
+				Token string `json:"auth_token"`
+			}
+			json.Unmarshal([]byte(args), &connect)
+			if connect.Token != "secret" {
+				fmt.Fprint(w, "-ERR 'Authorization Violation'\r\n")
+				w.Flush()
+				return
+			}
+		case "PUB":
+			fields := strings.Fields(args)
+			n, _ := strconv.Atoi(fields[len(fields)-1])
+			data := make([]byte, n+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			if fields[0] == "forbidden" {
+				fmt.Fprint(w, "-ERR 'Permissions Violation for Publish to \"forbidden\"'\r\n")
+				continue
+			}
+			f.mu.Lock()
+			f.received = append(f.received, Message{Subject: fields[0], Data: data[:n]})
+			f.mu.Unlock()
+		case "PING":
+			// A server ping before the reply must be answered.
+			fmt.Fprint(w, "PING\r\nPONG\r\n")
+		}
+		w.Flush()
+	}
+}
+
+func TestPublish(t *testing.T) {
+	server := newFakeServer(t)
+	ctx := context.Background()
+
+	if err := NewClient(Options{URL: "nats://wrong@" + server.listener.Addr().String()}).Publish(ctx, Message{Subject: "a", Data: []byte("x")}); err == nil ||
+		err.Error() != "nats: server error: Authorization Violation" {
+		t.Errorf("wrong token: %v", err)
+	}
+
+	client := NewClient(Options{URL: "nats://" + server.listener.Addr().String(), Token: "secret"})
+	defer client.Close()
+	if err := client.Publish(ctx, Message{Subject: "secmetrics.alert.fired", Data: []byte("{\"id\":1}\r\n")},
+		Message{Subject: "secmetrics.report.generated", Data: nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Publish(ctx, Message{Subject: "bad subject"}); err == nil {
+		t.Error("subject with a space was accepted")
+	}
+	if err := client.Publish(ctx, Message{Subject: "big", Data: make([]byte, 65)}); err == nil || !strings.Contains(err.Error(), "maximum payload") {
+		t.Errorf("oversized message: %v", err)
+	}
+	if err := client.Publish(ctx, Message{Subject: "forbidden", Data: []byte("x")}); err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Errorf("forbidden subject: %v", err)
+	}
+	// The client reconnects after an error.
+	if err := client.Publish(ctx, Message{Subject: "secmetrics.metric.ingested", Data: []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	want := []string{"secmetrics.alert.fired {\"id\":1}\r\n", "secmetrics.report.generated ", "secmetrics.metric.ingested {}"}
+	if len(server.received) != len(want) {
+		t.Fatalf("received %d messages, want %d", len(server.received), len(want))
+	}
+	for i, message := range server.received {
+		if got := message.Subject + " " + string(message.Data); got != want[i] {
+			t.Errorf("message %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
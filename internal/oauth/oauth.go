@@ -0,0 +1,72 @@
+// Package oauth provides a cached OAuth2 token source for client
+// credentials and refresh token grants.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource fetches and caches OAuth2 access tokens from a token endpoint.
+type TokenSource struct {
+	client   *http.Client
+	tokenURL string
+	form     url.Values
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewTokenSource creates a token source posting form to tokenURL.
+func NewTokenSource(client *http.Client, tokenURL string, form url.Values) *TokenSource {
+	return &TokenSource{client: client, tokenURL: tokenURL, form: form}
+}
+
+// Token returns a cached access token, refreshing it shortly before expiry.
+func (s *TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(s.form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("token request: unexpected status %s: %s", resp.Status, string(body))
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("token response did not include an access token")
+	}
+
+	s.token = body.AccessToken
+	s.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
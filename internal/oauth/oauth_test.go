@@ -0,0 +1,56 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTokenSource(t *testing.T) {
+	var requests int
+	var status = http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if err := r.ParseForm(); err != nil || r.Method != http.MethodPost || r.PostForm.Get("client_secret") != "s3cret" {
+			t.Errorf("token request %s %v: %v", r.Method, r.PostForm, err)
+		}
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"access_token":"tok-1","expires_in":3600}`))
+		} else {
+			w.Write([]byte(`{"error":"invalid_client"}`))
+		}
+	}))
+	defer srv.Close()
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_secret": {"s3cret"}}
+	tokens := NewTokenSource(srv.Client(), srv.URL, form)
+	for i := 0; i < 2; i++ {
+		token, err := tokens.Token(context.Background())
+		if err != nil || token != "tok-1" {
+			t.Fatalf("Token = %q, %v", token, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("%d token requests, want the token cached", requests)
+	}
+
+	status = http.StatusUnauthorized
+	_, err := NewTokenSource(srv.Client(), srv.URL, form).Token(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("rejected token request: %v", err)
+	}
+}
+
+func TestTokenSourceRequiresAccessToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"expires_in":3600}`))
+	}))
+	defer srv.Close()
+	if _, err := NewTokenSource(srv.Client(), srv.URL, nil).Token(context.Background()); err == nil {
+		t.Error("accepted a token response without an access token")
+	}
+}
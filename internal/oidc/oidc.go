@@ -0,0 +1,385 @@
+// Package oidc implements the OpenID Connect authorization code flow with
+// PKCE and ID token verification against the provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512" // SHA-384 and SHA-512 for RS384, RS512, ES384 and ES512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxResponseSize bounds discovery, key set and token responses.
+const maxResponseSize = 1 << 20
+
+// Provider is an OpenID Connect provider and the client registered with it.
+type Provider struct {
+	client       *http.Client
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]crypto.PublicKey
+}
+
+// metadata is the part of the discovery document the flow uses.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProvider creates a provider for issuer. Discovery happens on first use.
+func NewProvider(client *http.Client, issuer, clientID, clientSecret, redirectURL string, scopes []string) *Provider {
+	return &Provider{
+		client:       client,
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+	}
+}
+
+// discover fetches and caches the provider's discovery document.
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+	var m metadata
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", &m); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(m.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", m.Issuer, p.issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: document lacks an authorization, token or jwks endpoint")
+	}
+	p.metadata = &m
+	return p.metadata, nil
+}
+
+// AuthCodeURL returns the URL to send the user to for login. The verifier
+// must be passed to Exchange with the code returned to the redirect URL.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientID},
+		"redirect_uri":          {p.redirectURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return m.AuthorizationEndpoint + sep + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"client_id":     {p.clientID},
+		"code_verifier": {verifier},
+	}
+	if p.clientSecret != "" {
+		form.Set("client_secret", p.clientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("oidc token request: unexpected status %s: %s", resp.Status, string(body))
+	}
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response did not include an id token")
+	}
+	return body.IDToken, nil
+}
+
+// Claims are the claims of a verified ID token.
+type Claims map[string]interface{}
+
+// String returns the string claim name, or "".
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim holding a string or a list of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Verify checks the ID token's signature, issuer, audience, expiry and
+// nonce and returns its claims.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string, now time.Time) (Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed id token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("oidc: id token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: id token signature: %w", err)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("oidc: id token claims: %w", err)
+	}
+	if strings.TrimSuffix(claims.String("iss"), "/") != p.issuer {
+		return nil, fmt.Errorf("oidc: id token issuer %q is not %q", claims.String("iss"), p.issuer)
+	}
+	audienceOK := false
+	for _, aud := range claims.Strings("aud") {
+		audienceOK = audienceOK || aud == p.clientID
+	}
+	if !audienceOK {
+		return nil, errors.New("oidc: id token was not issued for this client")
+	}
+	exp, _ := claims["exp"].(float64)
+	if now.After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("oidc: id token has expired")
+	}
+	if claims.String("nonce") != nonce {
+		return nil, errors.New("oidc: id token nonce does not match")
+	}
+	return claims, nil
+}
+
+// key returns the provider key kid, refetching the key set once when kid
+// is unknown, as providers rotate keys.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, m.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if public, err := k.publicKey(); err == nil {
+			keys[k.Kid] = public
+		}
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("oidc: no provider key %q", kid)
+}
+
+// jwk is a JSON Web Key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey decodes an RSA or EC key.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verifySignature checks a JWS signature made with alg.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("oidc: unsupported id token algorithm %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	if hash == 0 {
+		return fmt.Errorf("oidc: unsupported id token algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS"):
+		if rsaKey, ok := key.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature) == nil {
+			return nil
+		}
+	case strings.HasPrefix(alg, "ES"):
+		if ecKey, ok := key.(*ecdsa.PublicKey); ok && len(signature)%2 == 0 {
+			r := new(big.Int).SetBytes(signature[:len(signature)/2])
+			s := new(big.Int).SetBytes(signature[len(signature)/2:])
+			if ecdsa.Verify(ecKey, digest, r, s) {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("oidc: unsupported id token algorithm %q", alg)
+	}
+	return errors.New("oidc: id token signature is invalid")
+}
+
+// getJSON fetches url and decodes the JSON response into v.
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
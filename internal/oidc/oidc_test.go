@@ -0,0 +1,100 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProvider serves discovery and a P-256 key set, returning a function
+// that signs ID tokens with claims.
+func testProvider(t *testing.T) (*Provider, func(claims map[string]interface{}) string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer": srv.URL, "authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint": srv.URL + "/token", "jwks_uri": srv.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "EC", "kid": "ec1", "crv": "P-256",
+			"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	sign := func(claims map[string]interface{}) string {
+		if _, ok := claims["iss"]; !ok {
+			claims["iss"] = srv.URL
+		}
+		header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "ec1"})
+		payload, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+	return NewProvider(srv.Client(), srv.URL, "secmetrics", "", srv.URL+"/callback", []string{"openid"}), sign
+}
+
+func TestVerify(t *testing.T) {
+	provider, sign := testProvider(t)
+	now := time.Now()
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{"aud": "secmetrics", "exp": now.Add(time.Hour).Unix(), "nonce": "n1", "groups": []string{"secops"}}
+	}
+
+	claims, err := provider.Verify(context.Background(), sign(valid()), "n1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groups := claims.Strings("groups"); len(groups) != 1 || groups[0] != "secops" {
+		t.Errorf("groups = %v", groups)
+	}
+
+	tampered := strings.Split(sign(valid()), ".")
+	forged, _ := json.Marshal(map[string]interface{}{"iss": "x", "aud": "secmetrics", "exp": now.Add(time.Hour).Unix(), "nonce": "n1", "groups": []string{"admins"}})
+	tampered[1] = base64.RawURLEncoding.EncodeToString(forged)
+
+	with := func(key string, value interface{}) string {
+		claims := valid()
+		claims[key] = value
+		return sign(claims)
+	}
+	tests := []struct {
+		name, token, want string
+	}{
+		{"tampered", strings.Join(tampered, "."), "signature is invalid"},
+		{"wrong audience", with("aud", "other"), "not issued for this client"},
+		{"wrong issuer", with("iss", "https://evil.example.com"), "issuer"},
+		{"expired", with("exp", now.Add(-time.Minute).Unix()), "expired"},
+		{"replayed nonce", with("nonce", "n0"), "nonce"},
+	}
+	for _, tt := range tests {
+		if _, err := provider.Verify(context.Background(), tt.token, "n1", now); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Verify error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package parquet writes Apache Parquet files: one row group of flat
+// columns, PLAIN encoded and uncompressed, which pandas, Spark, DuckDB and
+// Arrow read directly.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Kind is the type of a column's values.
+type Kind int
+
+// Column kinds.
+const (
+	// Int64 columns hold Int64s.
+	Int64 Kind = iota
+	// Timestamp columns hold Int64s as milliseconds since the Unix epoch.
+	Timestamp
+	// Double columns hold Doubles.
+	Double
+	// String columns hold Strings as UTF-8.
+	String
+	// Boolean columns hold Booleans.
+	Boolean
+)
+
+// Column is a named column. Exactly the slice of its kind is set, with one
+// value per row; the values of null rows are ignored.
+type Column struct {
+	Name     string
+	Kind     Kind
+	Int64s   []int64
+	Doubles  []float64
+	Strings  []string
+	Booleans []bool
+	// Valid marks the non-null rows of an optional column; nil makes the
+	// column required.
+	Valid []bool
+}
+
+// len returns the number of rows of c.
+func (c Column) len() int {
+	switch c.Kind {
+	case Int64, Timestamp:
+		return len(c.Int64s)
+	case Double:
+		return len(c.Doubles)
+	case String:
+		return len(c.Strings)
+	case Boolean:
+		return len(c.Booleans)
+	}
+	return 0
+}
+
+// Parquet physical types, converted types, repetitions and encodings.
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	required = 0
+	optional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+)
+
+// magic starts and ends every Parquet file.
+const magic = "PAR1"
+
+// Write writes columns as a Parquet file with a single row group.
+// createdBy names the writing application in the file metadata.
+func Write(w io.Writer, columns []Column, createdBy string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("parquet: no columns")
+	}
+	rows := columns[0].len()
+	for _, c := range columns {
+		if c.len() != rows || (c.Valid != nil && len(c.Valid) != rows) {
+			return fmt.Errorf("parquet: column %s has %d values, want %d", c.Name, c.len(), rows)
+		}
+	}
+
+	var file bytes.Buffer
+	file.WriteString(magic)
+	chunks := make([]chunk, len(columns))
+	for i, c := range columns {
+		page := encodeValues(c)
+		if c.Valid != nil {
+			page = append(encodeLevels(c.Valid), page...)
+		}
+		var header bytes.Buffer
+		e := &encoder{w: &header}
+		e.beginStruct()
+		e.i32Field(1, 0) // DATA_PAGE
+		e.i32Field(2, int32(len(page)))
+		e.i32Field(3, int32(len(page)))
+		e.structField(5)
+		e.i32Field(1, int32(rows))
+		e.i32Field(2, encodingPlain)
+		e.i32Field(3, encodingRLE)
+		e.i32Field(4, encodingRLE)
+		e.endStruct()
+		e.endStruct()
+
+		// Both counts include nulls.
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.Len() + len(page)), values: int64(rows)}
+		file.Write(header.Bytes())
+		file.Write(page)
+	}
+
+	var footer bytes.Buffer
+	e := &encoder{w: &footer}
+	e.beginStruct()
+	e.i32Field(1, 1)
+	e.listField(2, compactStruct, len(columns)+1)
+	e.beginStruct()
+	e.stringField(4, "schema")
+	e.i32Field(5, int32(len(columns)))
+	e.endStruct()
+	for _, c := range columns {
+		e.beginStruct()
+		e.i32Field(1, physicalType(c.Kind))
+		repetition := int32(required)
+		if c.Valid != nil {
+			repetition = optional
+		}
+		e.i32Field(3, repetition)
+		e.stringField(4, c.Name)
+		switch c.Kind {
+		case String:
+			e.i32Field(6, convertedUTF8)
+		case Timestamp:
+			e.i32Field(6, convertedTimestampMillis)
+		}
+		e.endStruct()
+	}
+	e.i64Field(3, int64(rows))
+	e.listField(4, compactStruct, 1)
+	e.beginStruct()
+	e.listField(1, compactStruct, len(columns))
+	var total int64
+	for i, c := range columns {
+		ch := chunks[i]
+		total += ch.size
+		e.beginStruct()
+		e.i64Field(2, ch.offset)
+		e.structField(3)
+		e.i32Field(1, physicalType(c.Kind))
+		e.listField(2, compactI32, 2)
+		e.i32(encodingPlain)
+		e.i32(encodingRLE)
+		e.listField(3, compactBinary, 1)
+		e.string(c.Name)
+		e.i32Field(4, 0) // UNCOMPRESSED
+		e.i64Field(5, ch.values)
+		e.i64Field(6, ch.size)
+		e.i64Field(7, ch.size)
+		e.i64Field(9, ch.offset)
+		e.endStruct()
+		e.endStruct()
+	}
+	e.i64Field(2, total)
+	e.i64Field(3, int64(rows))
+	e.endStruct()
+	e.stringField(6, createdBy)
+	e.endStruct()
+
+	file.Write(footer.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(footer.Len()))
+	file.WriteString(magic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// chunk locates a column chunk in the file.
+type chunk struct {
+	offset, size, values int64
+}
+
+func physicalType(kind Kind) int32 {
+	switch kind {
+	case Int64, Timestamp:
+		return typeInt64
+	case Double:
+		return typeDouble
+	case String:
+		return typeByteArray
+	}
+	return typeBoolean
+}
+
+// encodeValues returns the PLAIN encoding of the non-null values of c.
+func encodeValues(c Column) []byte {
+	var values bytes.Buffer
+	var bits []bool
+	for row := 0; row < c.len(); row++ {
+		if c.Valid != nil && !c.Valid[row] {
+			continue
+		}
+		switch c.Kind {
+		case Int64, Timestamp:
+			binary.Write(&values, binary.LittleEndian, c.Int64s[row])
+		case Double:
+			binary.Write(&values, binary.LittleEndian, math.Float64bits(c.Doubles[row]))
+		case String:
+			binary.Write(&values, binary.LittleEndian, uint32(len(c.Strings[row])))
+			values.WriteString(c.Strings[row])
+		case Boolean:
+			bits = append(bits, c.Booleans[row])
+		}
+	}
+	// Booleans are bit-packed, least significant bit first.
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8 && i+j < len(bits); j++ {
+			if bits[i+j] {
+				b |= 1 << j
+			}
+		}
+		values.WriteByte(b)
+	}
+	return values.Bytes()
+}
+
+// encodeLevels returns the definition levels of valid: RLE runs of bit
+// width 1, preceded by their length.
+func encodeLevels(valid []bool) []byte {
+	var levels bytes.Buffer
+	for i := 0; i < len(valid); {
+		j := i
+		for j < len(valid) && valid[j] == valid[i] {
+			j++
+		}
+		levels.Write(binary.AppendUvarint(nil, uint64(j-i)<<1))
+		if valid[i] {
+			levels.WriteByte(1)
+		} else {
+			levels.WriteByte(0)
+		}
+		i = j
+	}
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(levels.Len())), levels.Bytes()...)
+}
+
+// Thrift compact protocol types.
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// encoder writes Thrift compact protocol structs.
+type encoder struct {
+	w *bytes.Buffer
+	// last holds the last field ID of each open struct.
+	last []int16
+}
+
+func (e *encoder) beginStruct() {
+	e.last = append(e.last, 0)
+}
+
+func (e *encoder) endStruct() {
+	e.w.WriteByte(0)
+	e.last = e.last[:len(e.last)-1]
+}
+
+func (e *encoder) fieldHeader(id int16, kind byte) {
+	last := &e.last[len(e.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		e.w.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		e.w.WriteByte(kind)
+		e.w.Write(binary.AppendVarint(nil, int64(id)))
+	}
+	*last = id
+}
+
+func (e *encoder) i32(v int32) {
+	e.w.Write(binary.AppendVarint(nil, int64(v)))
+}
+
+func (e *encoder) string(s string) {
+	e.w.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	e.w.WriteString(s)
+}
+
+func (e *encoder) i32Field(id int16, v int32) {
+	e.fieldHeader(id, compactI32)
+	e.i32(v)
+}
+
+func (e *encoder) i64Field(id int16, v int64) {
+	e.fieldHeader(id, compactI64)
+	e.w.Write(binary.AppendVarint(nil, v))
+}
+
+func (e *encoder) stringField(id int16, s string) {
+	e.fieldHeader(id, compactBinary)
+	e.string(s)
+}
+
+// structField opens a struct-typed field; close it with endStruct.
+func (e *encoder) structField(id int16) {
+	e.fieldHeader(id, compactStruct)
+	e.beginStruct()
+}
+
+// listField starts a list-typed field of n elements, which the caller
+// writes next; struct elements are opened with beginStruct.
+func (e *encoder) listField(id int16, elem byte, n int) {
+	e.fieldHeader(id, compactList)
+	if n < 15 {
+		e.w.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	e.w.WriteByte(0xf0 | elem)
+	e.w.Write(binary.AppendUvarint(nil, uint64(n)))
+}
@@ -0,0 +1,169 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// thriftReader decodes Thrift compact structs into maps of field ID to
+// value, enough to check the metadata Write produces.
+type thriftReader struct {
+	data []byte
+	pos  int
+	t    *testing.T
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(kind byte) interface{} {
+	switch kind {
+	case compactI32, compactI64:
+		return r.varint()
+	case compactBinary:
+		n := int(r.uvarint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case compactList:
+		header := r.byte()
+		n, elem := int(header>>4), header&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case compactStruct:
+		fields := make(map[int16]interface{})
+		var last int16
+		for {
+			header := r.byte()
+			if header == 0 {
+				return fields
+			}
+			id := last + int16(header>>4)
+			if header>>4 == 0 {
+				id = int16(r.varint())
+			}
+			fields[id] = r.value(header & 0x0f)
+			last = id
+		}
+	}
+	r.t.Fatalf("unexpected thrift type %d at %d", kind, r.pos)
+	return nil
+}
+
+func TestWrite(t *testing.T) {
+	columns := []Column{
+		{Name: "timestamp", Kind: Timestamp, Int64s: []int64{1790000000000, 1790000060000, 1790000120000}},
+		{Name: "kpi_key", Kind: String, Strings: []string{"mttr", "mttr", "mfa_coverage"}},
+		{Name: "value", Kind: Double, Doubles: []float64{1.5, 2.25, 97}},
+		{Name: "target", Kind: Double, Doubles: []float64{2, 0, 95}, Valid: []bool{true, false, true}},
+		{Name: "on_target", Kind: Boolean, Booleans: []bool{true, false, true}},
+	}
+	var b bytes.Buffer
+	if err := Write(&b, columns, "secmetrics test"); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	if string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		t.Fatalf("file is not framed by %s", magic)
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-footerLen : len(data)-8], t: t}
+	meta := footer.value(compactStruct).(map[int16]interface{})
+	if meta[1] != int64(1) || meta[3] != int64(3) || meta[6] != "secmetrics test" {
+		t.Errorf("file metadata = %v", meta)
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 6 || schema[0].(map[int16]interface{})[5] != int64(5) {
+		t.Fatalf("schema = %v", schema)
+	}
+	wantSchema := []struct {
+		name       string
+		physical   int64
+		repetition int64
+		converted  interface{}
+	}{
+		{"timestamp", typeInt64, required, int64(convertedTimestampMillis)},
+		{"kpi_key", typeByteArray, required, int64(convertedUTF8)},
+		{"value", typeDouble, required, nil},
+		{"target", typeDouble, optional, nil},
+		{"on_target", typeBoolean, required, nil},
+	}
+	for i, want := range wantSchema {
+		element := schema[i+1].(map[int16]interface{})
+		if element[4] != want.name || element[1] != want.physical || element[3] != want.repetition || element[6] != want.converted {
+			t.Errorf("schema element %d = %v, want %+v", i+1, element, want)
+		}
+	}
+
+	rowGroup := meta[4].([]interface{})[0].(map[int16]interface{})
+	chunks := rowGroup[1].([]interface{})
+	if rowGroup[3] != int64(3) || len(chunks) != len(columns) {
+		t.Fatalf("row group = %v", rowGroup)
+	}
+	pages := make([][]byte, len(chunks))
+	for i, c := range chunks {
+		column := c.(map[int16]interface{})[3].(map[int16]interface{})
+		offset := int(column[9].(int64))
+		page := &thriftReader{data: data, pos: offset, t: t}
+		header := page.value(compactStruct).(map[int16]interface{})
+		size := int(header[3].(int64))
+		if offset+int(column[7].(int64)) != page.pos+size || column[5] != int64(3) {
+			t.Errorf("column %d: chunk metadata %v does not match page header %v", i, column, header)
+		}
+		if header[5].(map[int16]interface{})[1] != int64(3) {
+			t.Errorf("column %d: page header = %v", i, header)
+		}
+		pages[i] = data[page.pos : page.pos+size]
+	}
+
+	if got := int64(binary.LittleEndian.Uint64(pages[0][8:])); got != 1790000060000 {
+		t.Errorf("second timestamp = %d", got)
+	}
+	if !bytes.Equal(pages[1][:8], []byte{4, 0, 0, 0, 'm', 't', 't', 'r'}) {
+		t.Errorf("first string = %q", pages[1][:8])
+	}
+	if got := math.Float64frombits(binary.LittleEndian.Uint64(pages[2][16:])); got != 97 {
+		t.Errorf("third value = %g", got)
+	}
+	// Definition levels: runs of 1 valid, 1 null, 1 valid, then the two
+	// non-null values.
+	if !bytes.Equal(pages[3][:10], []byte{6, 0, 0, 0, 2, 1, 2, 0, 2, 1}) || len(pages[3]) != 10+16 {
+		t.Errorf("optional column page = %v", pages[3])
+	}
+	if !bytes.Equal(pages[4], []byte{0b101}) {
+		t.Errorf("boolean page = %v", pages[4])
+	}
+}
+
+func TestWriteRejectsRaggedColumns(t *testing.T) {
+	err := Write(&bytes.Buffer{}, []Column{
+		{Name: "a", Kind: Int64, Int64s: []int64{1, 2}},
+		{Name: "b", Kind: String, Strings: []string{"x"}},
+	}, "")
+	if err == nil {
+		t.Error("ragged columns written")
+	}
+}
@@ -0,0 +1,289 @@
+// Package redis is a minimal Redis client speaking RESP2, enough for
+// caching and pub/sub.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNil is returned by Get when the key does not exist.
+var ErrNil = errors.New("redis: nil")
+
+// Error is an error reply from the server.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// DefaultTimeout bounds dialing and each command when the context has no
+// earlier deadline.
+const DefaultTimeout = 5 * time.Second
+
+// Options configures connections.
+type Options struct {
+	// Addr is host:port.
+	Addr     string
+	Username string
+	Password string
+	DB       int
+	// TLS, when set, wraps connections in TLS.
+	TLS *tls.Config
+}
+
+// conn is one connection to the server.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// dial connects, authenticates and selects the database.
+func dial(ctx context.Context, opts Options) (*conn, error) {
+	dialer := &net.Dialer{Timeout: DefaultTimeout}
+	var nc net.Conn
+	var err error
+	if opts.TLS != nil {
+		nc, err = (&tls.Dialer{NetDialer: dialer, Config: opts.TLS}).DialContext(ctx, "tcp", opts.Addr)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", opts.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	var setup [][]string
+	switch {
+	case opts.Password != "" && opts.Username != "":
+		setup = append(setup, []string{"AUTH", opts.Username, opts.Password})
+	case opts.Password != "":
+		setup = append(setup, []string{"AUTH", opts.Password})
+	}
+	if opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(opts.DB)})
+	}
+	for _, args := range setup {
+		if _, err := c.do(ctx, args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do sends a command and reads its reply.
+func (c *conn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultTimeout)
+	}
+	c.SetDeadline(deadline)
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// send writes a command as an array of bulk strings.
+func (c *conn) send(args ...string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return c.w.Flush()
+}
+
+// read reads one reply: a string, an int64, []byte or nil for bulk
+// strings, or []interface{} for arrays. Error replies return Error.
+func (c *conn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				var redisErr Error
+				if !errors.As(err, &redisErr) {
+					return nil, err
+				}
+				items[i] = redisErr
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
+
+// Client runs commands over one connection, redialing after failures.
+// It is safe for concurrent use; commands are serialized.
+type Client struct {
+	opts Options
+
+	mu   sync.Mutex
+	conn *conn
+}
+
+// NewClient creates a client; it connects on the first command.
+func NewClient(opts Options) *Client {
+	return &Client{opts: opts}
+}
+
+// Do runs a command and returns its reply.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		conn, err := dial(ctx, c.opts)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	reply, err := c.conn.do(ctx, args...)
+	var redisErr Error
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection state is unknown; start afresh next time.
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// Get returns the value of key, or ErrNil if it does not exist.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, nil
+}
+
+// Set sets key to value, expiring after ttl unless ttl is zero.
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.Do(ctx, args...)
+	return err
+}
+
+// Del deletes keys.
+func (c *Client) Del(ctx context.Context, keys ...string) error {
+	_, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Publish sends message to the subscribers of channel.
+func (c *Client) Publish(ctx context.Context, channel string, message []byte) error {
+	_, err := c.Do(ctx, "PUBLISH", channel, string(message))
+	return err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// Subscription receives the messages published to channels on a
+// dedicated connection.
+type Subscription struct {
+	conn *conn
+}
+
+// Subscribe opens a connection subscribed to channels.
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*Subscription, error) {
+	conn, err := dial(ctx, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.send(append([]string{"SUBSCRIBE"}, channels...)...); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Subscription{conn: conn}, nil
+}
+
+// Receive blocks until a message arrives and returns its channel and
+// payload. It fails once the subscription is closed or the connection
+// breaks; subscribe again to resume.
+func (s *Subscription) Receive() (channel string, message []byte, err error) {
+	s.conn.SetDeadline(time.Time{})
+	for {
+		reply, err := s.conn.read()
+		if err != nil {
+			return "", nil, err
+		}
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 3 {
+			continue
+		}
+		kind, _ := items[0].([]byte)
+		if string(kind) != "message" {
+			// Subscription confirmations.
+			continue
+		}
+		name, _ := items[1].([]byte)
+		payload, _ := items[2].([]byte)
+		return string(name), payload, nil
+	}
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer speaks enough RESP for the client: AUTH, GET, SET, DEL,
+// PUBLISH and SUBSCRIBE.
+type fakeServer struct {
+	listener    net.Listener
+	mu          sync.Mutex
+	data        map[string]string
+	subscribers map[string][]*conn
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeServer{listener: listener, data: make(map[string]string), subscribers: make(map[string][]*conn)}
+	go f.serve()
+	t.Cleanup(func() { listener.Close() })
+	return f
+}
+
+func (f *fakeServer) serve() {
+	for {
+		nc, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(&conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)})
+	}
+}
+
+func (f *fakeServer) handle(c *conn) {
+	defer c.Close()
+	authenticated := false
+	for {
+		reply, err := c.read()
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, item := range reply.([]interface{}) {
+			args = append(args, string(item.([]byte)))
+		}
+		f.mu.Lock()
+		switch {
+		case args[0] == "AUTH":
+			authenticated = args[len(args)-1] == "secret"
+			if authenticated {
+				fmt.Fprint(c.w, "+OK\r\n")
+			} else {
+				fmt.Fprint(c.w, "-WRONGPASS invalid password\r\n")
+			}
+		case !authenticated:
+			fmt.Fprint(c.w, "-NOAUTH Authentication required.\r\n")
+		case args[0] == "GET":
+			if value, ok := f.data[args[1]]; ok {
+				fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(value), value)
+			} else {
+				fmt.Fprint(c.w, "$-1\r\n")
+			}
+		case args[0] == "SET":
+			f.data[args[1]] = args[2]
+			fmt.Fprint(c.w, "+OK\r\n")
+		case args[0] == "DEL":
+			n := 0
+			for _, key := range args[1:] {
+				if _, ok := f.data[key]; ok {
+					delete(f.data, key)
+					n++
+				}
+			}
+			fmt.Fprintf(c.w, ":%d\r\n", n)
+		case args[0] == "PUBLISH":
+			subscribers := f.subscribers[args[1]]
+			for _, sub := range subscribers {
+				sub.send("message", args[1], args[2])
+			}
+			fmt.Fprintf(c.w, ":%d\r\n", len(subscribers))
+		case args[0] == "SUBSCRIBE":
+			for i, channel := range args[1:] {
+				f.subscribers[channel] = append(f.subscribers[channel], c)
+				fmt.Fprintf(c.w, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:%d\r\n", len(channel), channel, i+1)
+			}
+		default:
+			fmt.Fprintf(c.w, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		c.w.Flush()
+		f.mu.Unlock()
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeServer(t)
+	ctx := context.Background()
+
+	if _, err := NewClient(Options{Addr: server.listener.Addr().String(), Password: "wrong"}).Get(ctx, "k"); err == nil || err.Error() != "redis: WRONGPASS invalid password" {
+		t.Errorf("wrong password: %v", err)
+	}
+
+	client := NewClient(Options{Addr: server.listener.Addr().String(), Username: "secmetrics", Password: "secret"})
+	defer client.Close()
+	if _, err := client.Get(ctx, "summary"); !errors.Is(err, ErrNil) {
+		t.Fatalf("get missing key: %v", err)
+	}
+	if err := client.Set(ctx, "summary", []byte("{\"ok\":true}\r\n"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := client.Get(ctx, "summary"); err != nil || string(value) != "{\"ok\":true}\r\n" {
+		t.Fatalf("get = %q, %v", value, err)
+	}
+	if err := client.Del(ctx, "summary"); err != nil {
+		t.Fatal(err)
+	}
+	var redisErr Error
+	if _, err := client.Do(ctx, "FLUSHALL"); !errors.As(err, &redisErr) {
+		t.Errorf("unknown command: %v", err)
+	}
+	// An error reply leaves the connection usable.
+	if _, err := client.Get(ctx, "summary"); !errors.Is(err, ErrNil) {
+		t.Errorf("get after delete: %v", err)
+	}
+
+	subscription, err := client.Subscribe(ctx, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Close()
+	// Wait until the subscription is registered before publishing.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		reply, err := client.Do(ctx, "PUBLISH", "events", "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.(int64) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscription was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	channel, message, err := subscription.Receive()
+	if err != nil || channel != "events" || string(message) != "hello" {
+		t.Errorf("received %s %q, %v", channel, message, err)
+	}
+
+	subscription.Close()
+	if _, _, err := subscription.Receive(); err == nil {
+		t.Error("receive on a closed subscription succeeded")
+	}
+}
@@ -0,0 +1,50 @@
+// Package secretenv reads secrets from environment variables or from the
+// files they name, such as Docker and Kubernetes secrets mounted into a
+// container.
+package secretenv
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileSuffix ends the name of the variable naming the file that holds the
+// value of a variable, e.g. OPS_KEY_FILE=/run/secrets/ops-key for OPS_KEY.
+const FileSuffix = "_FILE"
+
+// Lookup returns the value of the environment variable name or, when it
+// is not set, the content of the file named by name+FileSuffix. It reports
+// whether either variable is set.
+func Lookup(name string) (string, bool, error) {
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true, nil
+	}
+	path, ok := os.LookupEnv(name + FileSuffix)
+	if !ok {
+		return "", false, nil
+	}
+	value, err := ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s%s: %w", name, FileSuffix, err)
+	}
+	return value, true, nil
+}
+
+// Get returns the value Lookup finds for name, or "" when neither variable
+// is set or the file cannot be read, which callers report as a missing
+// secret.
+func Get(name string) string {
+	value, _, _ := Lookup(name)
+	return value
+}
+
+// ReadFile returns the content of the secret file at path without its
+// trailing line break, which editors and "echo" add.
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,32 @@
+package secretenv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ops-key")
+	if err := os.WriteFile(path, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRETENV_TEST_KEY_FILE", path)
+	if value, ok, err := Lookup("SECRETENV_TEST_KEY"); value != "s3cret" || !ok || err != nil {
+		t.Errorf("Lookup = %q, %v, %v; want the file content", value, ok, err)
+	}
+
+	// The variable itself takes precedence over its file
+	t.Setenv("SECRETENV_TEST_KEY", "direct")
+	if value := Get("SECRETENV_TEST_KEY"); value != "direct" {
+		t.Errorf("Get = %q, want direct", value)
+	}
+
+	t.Setenv("SECRETENV_TEST_MISSING_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, ok, err := Lookup("SECRETENV_TEST_MISSING"); ok || err == nil {
+		t.Errorf("missing file: ok %v, err %v", ok, err)
+	}
+	if _, ok, err := Lookup("SECRETENV_TEST_UNSET"); ok || err != nil {
+		t.Errorf("unset variable: ok %v, err %v", ok, err)
+	}
+}
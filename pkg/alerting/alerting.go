@@ -0,0 +1,564 @@
+// Package alerting decides which alert notifications to send. It
+// suppresses duplicates of recent notifications, mutes alerts covered by a
+// silence such as a maintenance window, holds back acknowledged alerts,
+// repeats alerts still firing and groups related alerts into one
+// notification.
+package alerting
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/siem"
+)
+
+// Config configures alert notifications.
+type Config struct {
+	// DedupWindow suppresses a notification whose dedup key was notified
+	// within the window, e.g. a KPI flapping across a threshold (default
+	// "1h"; "0s" disables deduplication).
+	DedupWindow string `yaml:"dedup_window"`
+	// RepeatInterval re-sends alerts that are still firing and not
+	// acknowledged, e.g. "24h"; alerts are not repeated by default.
+	RepeatInterval string `yaml:"repeat_interval"`
+	// GroupBy sends the firing alerts of one evaluation that share a value
+	// as a single notification: "category" groups by KPI category. Alerts
+	// are not grouped by default.
+	GroupBy string `yaml:"group_by"`
+	// MTTATarget is the target of the alert_mtta KPI, e.g. "30m" (default
+	// "1h").
+	MTTATarget string `yaml:"mtta_target"`
+	// Composite lists alert rules combining several KPIs.
+	Composite []CompositeRule `yaml:"composite"`
+}
+
+// Defaults applied when a setting is not configured.
+const (
+	DefaultDedupWindow = time.Hour
+	DefaultMTTATarget  = time.Hour
+)
+
+// Suppression reasons, as reported in Decision.Suppressed.
+const (
+	ReasonDuplicate    = "duplicate"
+	ReasonSilenced     = "silenced"
+	ReasonAcknowledged = "acknowledged"
+)
+
+// Alert is a notification candidate.
+type Alert struct {
+	// Key deduplicates notifications and identifies the alert when it is
+	// acknowledged, e.g. "kpi_threshold/mttr/critical".
+	Key string
+	// KPI is the KPI the alert is about, if any; silences match it.
+	KPI metrics.KPIKey
+	// Rule is the composite rule the alert is about, if any.
+	Rule     string
+	Category string
+	// Severity is "warning" or "critical" for firing alerts.
+	Severity string
+	// Firing alerts stay active until an alert about the same KPI or rule
+	// replaces them, e.g. a recovery. Other alerts are one-off
+	// notifications.
+	Firing bool
+	Event  siem.Event
+}
+
+// subject identifies what the alert is about: its KPI or composite rule,
+// or "" for neither.
+func (a Alert) subject() string {
+	switch {
+	case a.KPI != "":
+		return "kpi:" + string(a.KPI)
+	case a.Rule != "":
+		return "rule:" + a.Rule
+	}
+	return ""
+}
+
+// Silence mutes notifications between StartsAt and EndsAt.
+type Silence struct {
+	ID string `json:"id"`
+	// KPIs and Categories limit the silence to alerts about these KPIs or
+	// in these categories; a silence limited to neither mutes every
+	// notification.
+	KPIs       []metrics.KPIKey `json:"kpis,omitempty"`
+	Categories []string         `json:"categories,omitempty"`
+	StartsAt   time.Time        `json:"starts_at"`
+	EndsAt     time.Time        `json:"ends_at"`
+	Comment    string           `json:"comment,omitempty"`
+	CreatedBy  string           `json:"created_by,omitempty"`
+}
+
+// NewSilenceID returns a random silence ID.
+func NewSilenceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Validate checks that the silence has an ID and ends after it starts.
+func (s Silence) Validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("silence requires an id")
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("silence %s must end after it starts", s.ID)
+	}
+	return nil
+}
+
+// Active reports whether the silence is in effect at now.
+func (s Silence) Active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// Matches reports whether the silence covers alert, regardless of time.
+func (s Silence) Matches(alert Alert) bool {
+	if len(s.KPIs) == 0 && len(s.Categories) == 0 {
+		return true
+	}
+	for _, key := range s.KPIs {
+		if key == alert.KPI && alert.KPI != "" {
+			return true
+		}
+	}
+	for _, category := range s.Categories {
+		if strings.EqualFold(category, alert.Category) && alert.Category != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Acknowledgment holds back further notifications of a firing alert until
+// it is resolved.
+type Acknowledgment struct {
+	Key     string    `json:"key"`
+	By      string    `json:"by,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// State is the silences and acknowledgments operators manage.
+type State struct {
+	Silences        []Silence        `json:"silences"`
+	Acknowledgments []Acknowledgment `json:"acknowledgments"`
+}
+
+// AddSilence adds silence, replacing any silence with the same ID.
+func (s *State) AddSilence(silence Silence) error {
+	if err := silence.Validate(); err != nil {
+		return err
+	}
+	for i, existing := range s.Silences {
+		if existing.ID == silence.ID {
+			s.Silences[i] = silence
+			return nil
+		}
+	}
+	s.Silences = append(s.Silences, silence)
+	return nil
+}
+
+// ExpireSilence ends the silence with the given ID at now, reporting
+// whether it exists.
+func (s *State) ExpireSilence(id string, now time.Time) bool {
+	for i, silence := range s.Silences {
+		if silence.ID == id {
+			if silence.EndsAt.After(now) {
+				s.Silences[i].EndsAt = now
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Acknowledge records ack, replacing any acknowledgment of the same key.
+func (s *State) Acknowledge(ack Acknowledgment) {
+	for i, existing := range s.Acknowledgments {
+		if existing.Key == ack.Key {
+			s.Acknowledgments[i] = ack
+			return
+		}
+	}
+	s.Acknowledgments = append(s.Acknowledgments, ack)
+}
+
+// Acknowledged returns the acknowledgment of key, if any.
+func (s *State) Acknowledged(key string) (Acknowledgment, bool) {
+	for _, ack := range s.Acknowledgments {
+		if ack.Key == key {
+			return ack, true
+		}
+	}
+	return Acknowledgment{}, false
+}
+
+// Resolve drops the acknowledgments of keys, reporting whether any was
+// dropped.
+func (s *State) Resolve(keys []string) bool {
+	resolved := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		resolved[key] = true
+	}
+	kept := s.Acknowledgments[:0]
+	for _, ack := range s.Acknowledgments {
+		if !resolved[ack.Key] {
+			kept = append(kept, ack)
+		}
+	}
+	changed := len(kept) != len(s.Acknowledgments)
+	s.Acknowledgments = kept
+	return changed
+}
+
+// silencedBy returns the active silence covering alert at now.
+func (s *State) silencedBy(alert Alert, now time.Time) (Silence, bool) {
+	for _, silence := range s.Silences {
+		if silence.Active(now) && silence.Matches(alert) {
+			return silence, true
+		}
+	}
+	return Silence{}, false
+}
+
+// FiringAlert is an alert that is firing.
+type FiringAlert struct {
+	Key      string         `json:"key"`
+	KPI      metrics.KPIKey `json:"kpi,omitempty"`
+	Rule     string         `json:"rule,omitempty"`
+	Category string         `json:"category,omitempty"`
+	Name     string         `json:"name"`
+	Severity int            `json:"severity"`
+	Since    time.Time      `json:"since"`
+	// LastNotified is zero while every notification was suppressed.
+	LastNotified time.Time       `json:"last_notified"`
+	Acknowledged *Acknowledgment `json:"acknowledged,omitempty"`
+	SilencedBy   string          `json:"silenced_by,omitempty"`
+}
+
+// Decision is the outcome of processing alerts.
+type Decision struct {
+	// Events are the notifications to send.
+	Events []siem.Event
+	// Suppressed counts the alerts not sent, by reason.
+	Suppressed map[string]int
+	// Fired lists the alerts that started firing.
+	Fired []Alert
+	// Resolved lists the keys of alerts that stopped firing, whose
+	// acknowledgments no longer apply.
+	Resolved []string
+}
+
+// firing tracks a firing alert.
+type firing struct {
+	alert        Alert
+	since        time.Time
+	lastNotified time.Time
+}
+
+// Engine applies the notification policy. It is safe for concurrent use.
+type Engine struct {
+	dedupWindow    time.Duration
+	repeatInterval time.Duration
+	groupBy        string
+	mttaTarget     time.Duration
+	composites     []*Composite
+
+	mu sync.Mutex
+	// firing holds the firing alerts by key.
+	firing map[string]*firing
+	// sent holds when each key was last notified, within the dedup window.
+	sent map[string]time.Time
+}
+
+// NewEngine validates cfg and returns an engine applying it.
+func NewEngine(cfg Config) (*Engine, error) {
+	e := &Engine{
+		dedupWindow: DefaultDedupWindow,
+		mttaTarget:  DefaultMTTATarget,
+		firing:      make(map[string]*firing),
+		sent:        make(map[string]time.Time),
+	}
+	if cfg.DedupWindow != "" {
+		window, err := time.ParseDuration(cfg.DedupWindow)
+		if err != nil || window < 0 {
+			return nil, fmt.Errorf("alerting dedup_window: invalid duration %q", cfg.DedupWindow)
+		}
+		e.dedupWindow = window
+	}
+	if cfg.RepeatInterval != "" {
+		interval, err := time.ParseDuration(cfg.RepeatInterval)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("alerting repeat_interval: invalid duration %q", cfg.RepeatInterval)
+		}
+		e.repeatInterval = interval
+	}
+	if cfg.MTTATarget != "" {
+		target, err := time.ParseDuration(cfg.MTTATarget)
+		if err != nil || target <= 0 {
+			return nil, fmt.Errorf("alerting mtta_target: invalid duration %q", cfg.MTTATarget)
+		}
+		e.mttaTarget = target
+	}
+	names := make(map[string]bool)
+	for _, rule := range cfg.Composite {
+		composite, err := newComposite(rule)
+		if err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("alerting composite %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+		e.composites = append(e.composites, composite)
+	}
+	switch cfg.GroupBy {
+	case "", "category":
+		e.groupBy = cfg.GroupBy
+	default:
+		return nil, fmt.Errorf("alerting group_by: unknown value %q (want category)", cfg.GroupBy)
+	}
+	return e, nil
+}
+
+// Reconfigure validates cfg and applies it in place of the engine's
+// configuration, keeping the firing alerts and the dedup state. The alerts
+// of composite rules that cfg no longer has stop firing; Reconfigure
+// returns their keys in order so the caller can resolve them.
+func (e *Engine) Reconfigure(cfg Config) ([]string, error) {
+	next, err := NewEngine(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rules := make(map[string]bool, len(next.composites))
+	for _, composite := range next.composites {
+		rules[composite.Name] = true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.dedupWindow, e.repeatInterval, e.groupBy = next.dedupWindow, next.repeatInterval, next.groupBy
+	e.mttaTarget, e.composites = next.mttaTarget, next.composites
+	var removed []string
+	for _, key := range e.firingKeys() {
+		if rule := e.firing[key].alert.Rule; rule != "" && !rules[rule] {
+			delete(e.firing, key)
+			removed = append(removed, key)
+		}
+	}
+	return removed, nil
+}
+
+// Process decides which of alerts, and of the alerts still firing, to
+// notify at now under state. An alert about a KPI or rule resolves its
+// other firing alerts.
+func (e *Engine) Process(alerts []Alert, state *State, now time.Time) Decision {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	decision := Decision{Suppressed: make(map[string]int)}
+	incoming := make(map[string]bool, len(alerts))
+	for _, alert := range alerts {
+		incoming[alert.Key] = true
+		if subject := alert.subject(); subject != "" {
+			for key, f := range e.firing {
+				if f.alert.subject() == subject && key != alert.Key {
+					delete(e.firing, key)
+					decision.Resolved = append(decision.Resolved, key)
+				}
+			}
+		}
+		if alert.Firing {
+			if f, ok := e.firing[alert.Key]; ok {
+				f.alert = alert
+			} else {
+				e.firing[alert.Key] = &firing{alert: alert, since: now}
+				decision.Fired = append(decision.Fired, alert)
+			}
+		}
+	}
+	sort.Strings(decision.Resolved)
+
+	type candidate struct {
+		alert  Alert
+		repeat bool
+	}
+	candidates := make([]candidate, 0, len(alerts))
+	for _, alert := range alerts {
+		candidates = append(candidates, candidate{alert: alert})
+	}
+	if e.repeatInterval > 0 {
+		for _, key := range e.firingKeys() {
+			f := e.firing[key]
+			if incoming[key] || f.lastNotified.IsZero() || now.Sub(f.lastNotified) < e.repeatInterval {
+				continue
+			}
+			repeat := f.alert
+			repeat.Event.Time = now
+			candidates = append(candidates, candidate{alert: repeat, repeat: true})
+		}
+	}
+
+	for key, at := range e.sent {
+		if now.Sub(at) >= e.dedupWindow {
+			delete(e.sent, key)
+		}
+	}
+	var notify []Alert
+	for _, c := range candidates {
+		_, acked := state.Acknowledged(c.alert.Key)
+		at, duplicate := e.sent[c.alert.Key]
+		duplicate = duplicate && !c.repeat && now.Sub(at) < e.dedupWindow
+		switch {
+		case c.alert.Firing && acked:
+			decision.Suppressed[ReasonAcknowledged]++
+			continue
+		case duplicate:
+			// A flapping alert counts as notified when it was last sent.
+			if f, ok := e.firing[c.alert.Key]; ok && f.lastNotified.IsZero() {
+				f.lastNotified = at
+			}
+			decision.Suppressed[ReasonDuplicate]++
+			continue
+		}
+		if _, silenced := state.silencedBy(c.alert, now); silenced {
+			decision.Suppressed[ReasonSilenced]++
+			continue
+		}
+		if e.dedupWindow > 0 {
+			e.sent[c.alert.Key] = now
+		}
+		if f, ok := e.firing[c.alert.Key]; ok {
+			f.lastNotified = now
+		}
+		notify = append(notify, c.alert)
+	}
+	decision.Events = e.group(notify, now)
+	return decision
+}
+
+// firingKeys returns the keys of the firing alerts in order.
+func (e *Engine) firingKeys() []string {
+	keys := make([]string, 0, len(e.firing))
+	for key := range e.firing {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// group returns the events of alerts, with firing alerts sharing a group
+// merged into one event in place of the first.
+func (e *Engine) group(alerts []Alert, now time.Time) []siem.Event {
+	members := make(map[string][]Alert)
+	if e.groupBy == "category" {
+		for _, alert := range alerts {
+			if alert.Firing && alert.Category != "" {
+				members[alert.Category] = append(members[alert.Category], alert)
+			}
+		}
+	}
+	var events []siem.Event
+	emitted := make(map[string]bool)
+	for _, alert := range alerts {
+		group := members[alert.Category]
+		if !alert.Firing || len(group) < 2 {
+			events = append(events, alert.Event)
+			continue
+		}
+		if emitted[alert.Category] {
+			continue
+		}
+		emitted[alert.Category] = true
+		events = append(events, groupEvent(alert.Category, group, now))
+	}
+	return events
+}
+
+// groupEvent summarizes the firing alerts of a category in one event at
+// the highest severity among them.
+func groupEvent(category string, alerts []Alert, now time.Time) siem.Event {
+	var names, kpis, keys []string
+	severity := 0
+	for _, alert := range alerts {
+		names = append(names, alert.Event.Name)
+		if alert.Rule != "" {
+			kpis = append(kpis, alert.Rule)
+		} else {
+			kpis = append(kpis, string(alert.KPI))
+		}
+		keys = append(keys, alert.Key)
+		if alert.Event.Severity > severity {
+			severity = alert.Event.Severity
+		}
+	}
+	return siem.Event{
+		Time:     now,
+		Type:     "kpi_threshold_group",
+		Name:     fmt.Sprintf("%d %s KPIs breached thresholds", len(alerts), category),
+		Severity: severity,
+		Category: category,
+		Message:  strings.Join(names, "; "),
+		Fields: []siem.Field{
+			{Name: "kpis", Value: strings.Join(kpis, ",")},
+			{Name: "count", Value: strconv.Itoa(len(alerts))},
+			{Name: "dedupKeys", Value: strings.Join(keys, ",")},
+		},
+	}
+}
+
+// Firing returns the firing alerts with their acknowledgment and silence
+// under state at now, in key order.
+func (e *Engine) Firing(state *State, now time.Time) []FiringAlert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]FiringAlert, 0, len(e.firing))
+	for _, key := range e.firingKeys() {
+		f := e.firing[key]
+		alert := FiringAlert{
+			Key: key, KPI: f.alert.KPI, Rule: f.alert.Rule, Category: f.alert.Category,
+			Name: f.alert.Event.Name, Severity: f.alert.Event.Severity,
+			Since: f.since, LastNotified: f.lastNotified,
+		}
+		if ack, ok := state.Acknowledged(key); ok {
+			alert.Acknowledged = &ack
+		}
+		if silence, ok := state.silencedBy(f.alert, now); ok {
+			alert.SilencedBy = silence.ID
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// IsFiring reports whether the alert with key is firing.
+func (e *Engine) IsFiring(key string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.firing[key]
+	return ok
+}
+
+// MTTATarget returns the target of the alert_mtta KPI.
+func (e *Engine) MTTATarget() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.mttaTarget
+}
+
+// Composites returns the composite rules.
+func (e *Engine) Composites() []*Composite {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.composites
+}
@@ -0,0 +1,157 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/siem"
+)
+
+func breach(key metrics.KPIKey, category, band string) Alert {
+	return Alert{
+		Key: "kpi_threshold/" + string(key) + "/" + band, KPI: key, Category: category, Firing: true,
+		Event: siem.Event{Type: "kpi_threshold", Name: string(key) + " breached " + band, Severity: 5},
+	}
+}
+
+func recovery(key metrics.KPIKey, category string) Alert {
+	return Alert{Key: "kpi_recovered/" + string(key), KPI: key, Category: category, Event: siem.Event{Type: "kpi_recovered"}}
+}
+
+func TestEngineDeduplicatesAndRepeats(t *testing.T) {
+	e, err := NewEngine(Config{RepeatInterval: "24h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &State{}
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	if d := e.Process([]Alert{breach(metrics.KPI_MTTR, "Response", "warning")}, state, now); len(d.Events) != 1 {
+		t.Fatalf("first breach sent %d events", len(d.Events))
+	}
+	// The KPI flaps back and forth within the dedup window.
+	d := e.Process([]Alert{recovery(metrics.KPI_MTTR, "Response")}, state, now.Add(10*time.Minute))
+	if len(d.Events) != 1 || len(d.Resolved) != 1 || d.Resolved[0] != "kpi_threshold/mttr/warning" {
+		t.Fatalf("recovery = %+v", d)
+	}
+	d = e.Process([]Alert{breach(metrics.KPI_MTTR, "Response", "warning")}, state, now.Add(20*time.Minute))
+	if len(d.Events) != 0 || d.Suppressed[ReasonDuplicate] != 1 {
+		t.Fatalf("flapping breach = %+v", d)
+	}
+
+	if d := e.Process(nil, state, now.Add(12*time.Hour)); len(d.Events) != 0 {
+		t.Fatalf("repeated before the interval: %+v", d.Events)
+	}
+	d = e.Process(nil, state, now.Add(25*time.Hour))
+	if len(d.Events) != 1 || !d.Events[0].Time.Equal(now.Add(25*time.Hour)) {
+		t.Fatalf("repeat = %+v", d.Events)
+	}
+
+	state.Acknowledge(Acknowledgment{Key: "kpi_threshold/mttr/warning", By: "alice"})
+	if d := e.Process(nil, state, now.Add(50*time.Hour)); len(d.Events) != 0 || d.Suppressed[ReasonAcknowledged] != 1 {
+		t.Fatalf("acknowledged repeat = %+v", d)
+	}
+	firing := e.Firing(state, now.Add(50*time.Hour))
+	if len(firing) != 1 || firing[0].Acknowledged == nil || firing[0].Acknowledged.By != "alice" {
+		t.Fatalf("firing = %+v", firing)
+	}
+
+	// Escalation resolves the acknowledged warning.
+	d = e.Process([]Alert{breach(metrics.KPI_MTTR, "Response", "critical")}, state, now.Add(51*time.Hour))
+	if len(d.Events) != 1 || len(d.Resolved) != 1 || !state.Resolve(d.Resolved) {
+		t.Fatalf("escalation = %+v", d)
+	}
+	if !e.IsFiring("kpi_threshold/mttr/critical") || e.IsFiring("kpi_threshold/mttr/warning") {
+		t.Error("escalation did not replace the firing alert")
+	}
+}
+
+func TestEngineSilences(t *testing.T) {
+	e, err := NewEngine(Config{DedupWindow: "0s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	state := &State{}
+	if err := state.AddSilence(Silence{ID: "patching", Categories: []string{"vulnerability"}, StartsAt: now, EndsAt: now.Add(2 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	alerts := []Alert{breach("patch_latency", "Vulnerability", "critical"), breach(metrics.KPI_MTTR, "Response", "warning")}
+	d := e.Process(alerts, state, now.Add(time.Hour))
+	if len(d.Events) != 1 || d.Events[0].Name != "mttr breached warning" || d.Suppressed[ReasonSilenced] != 1 {
+		t.Fatalf("silenced = %+v", d)
+	}
+	if firing := e.Firing(state, now.Add(time.Hour)); len(firing) != 2 || firing[1].SilencedBy != "patching" {
+		t.Errorf("firing = %+v", firing)
+	}
+	if d := e.Process(alerts[:1], state, now.Add(3*time.Hour)); len(d.Events) != 1 {
+		t.Errorf("after the silence = %+v", d)
+	}
+
+	if !state.ExpireSilence("patching", now) || state.ExpireSilence("unknown", now) {
+		t.Error("expire did not find the silence")
+	}
+	if err := state.AddSilence(Silence{ID: "backwards", StartsAt: now, EndsAt: now}); err == nil {
+		t.Error("silence ending at its start accepted")
+	}
+}
+
+func TestEngineGroupsByCategory(t *testing.T) {
+	e, err := NewEngine(Config{GroupBy: "category"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	critical := breach("mttd", "Response", "critical")
+	critical.Event.Severity = 8
+	d := e.Process([]Alert{breach(metrics.KPI_MTTR, "Response", "warning"), recovery("coverage", "Prevention"), critical}, &State{}, now)
+	if len(d.Events) != 2 {
+		t.Fatalf("events = %+v", d.Events)
+	}
+	group := d.Events[0]
+	if group.Type != "kpi_threshold_group" || group.Severity != 8 || group.Fields[0].Value != "mttr,mttd" || group.Fields[1].Value != "2" {
+		t.Errorf("group = %+v", group)
+	}
+	if d.Events[1].Type != "kpi_recovered" {
+		t.Errorf("second event = %+v", d.Events[1])
+	}
+
+	for _, cfg := range []Config{{DedupWindow: "soon"}, {RepeatInterval: "0s"}, {GroupBy: "severity"}} {
+		if _, err := NewEngine(cfg); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+}
+
+func TestEngineReconfigure(t *testing.T) {
+	e, err := NewEngine(Config{Composite: []CompositeRule{{Name: "exposed", Condition: "mttr > 4"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	composite := Alert{Key: "composite/exposed", Rule: "exposed", Firing: true, Event: siem.Event{Type: "composite", Name: "exposed"}}
+	e.Process([]Alert{composite, breach(metrics.KPI_MTTR, "Response", "warning")}, &State{}, now)
+
+	if _, err := e.Reconfigure(Config{GroupBy: "team"}); err == nil {
+		t.Error("invalid configuration accepted")
+	}
+	if len(e.Composites()) != 1 {
+		t.Fatal("invalid configuration applied")
+	}
+
+	removed, err := e.Reconfigure(Config{RepeatInterval: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != "composite/exposed" || len(e.Composites()) != 0 {
+		t.Errorf("removed = %q, composites = %d", removed, len(e.Composites()))
+	}
+	// The KPI alert keeps firing and repeats under the new interval
+	if !e.IsFiring("kpi_threshold/mttr/warning") {
+		t.Fatal("reconfiguring dropped a firing KPI alert")
+	}
+	if d := e.Process(nil, &State{}, now.Add(2*time.Hour)); len(d.Events) != 1 {
+		t.Errorf("repeat after reconfiguring = %+v", d.Events)
+	}
+}
@@ -0,0 +1,114 @@
+package alerting
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/expr"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// CompositeRule fires when a condition over several KPIs has held for a
+// period, e.g. remediation_rate < 80 and critical_open > 5 for 7 days.
+type CompositeRule struct {
+	Name string `yaml:"name"`
+	// Condition is an expression over KPI keys, in the syntax of derived
+	// KPIs; see package expr.
+	Condition string `yaml:"condition"`
+	// For is how long the condition must have held before the rule fires,
+	// e.g. "168h"; it fires as soon as the condition holds by default.
+	For string `yaml:"for"`
+	// Severity is "warning" (default) or "critical".
+	Severity string `yaml:"severity"`
+	// Category groups and silences the rule's alerts with the KPIs of a
+	// category.
+	Category    string `yaml:"category"`
+	Description string `yaml:"description"`
+}
+
+// Composite is a validated composite rule.
+type Composite struct {
+	CompositeRule
+	condition *expr.Expr
+	hold      time.Duration
+}
+
+// newComposite validates rule.
+func newComposite(rule CompositeRule) (*Composite, error) {
+	if rule.Name == "" || rule.Condition == "" {
+		return nil, fmt.Errorf("alerting composite: name and condition are required")
+	}
+	condition, err := expr.Parse(rule.Condition)
+	if err != nil {
+		return nil, fmt.Errorf("alerting composite %s: %w", rule.Name, err)
+	}
+	c := &Composite{CompositeRule: rule, condition: condition}
+	switch c.Severity {
+	case "":
+		c.Severity = "warning"
+	case "warning", "critical":
+	default:
+		return nil, fmt.Errorf("alerting composite %s: severity must be warning or critical, got %q", rule.Name, rule.Severity)
+	}
+	if rule.For != "" {
+		if c.hold, err = time.ParseDuration(rule.For); err != nil || c.hold < 0 {
+			return nil, fmt.Errorf("alerting composite %s for: invalid duration %q", rule.Name, rule.For)
+		}
+	}
+	return c, nil
+}
+
+// Hold returns how long the condition must have held.
+func (c *Composite) Hold() time.Duration {
+	return c.hold
+}
+
+// Holds reports whether the condition holds for the current KPIs of
+// collector and, judging by their history, held at every sample over the
+// hold period ending at now. A rule whose KPIs have no sample from before
+// the period does not hold yet.
+func (c *Composite) Holds(collector *metrics.MetricsCollector, now time.Time) bool {
+	if !c.condition.True(expr.KPIs(collector)) {
+		return false
+	}
+	if c.hold == 0 {
+		return true
+	}
+
+	start := now.Add(-c.hold)
+	histories := make(map[string][]metrics.KPISample)
+	times := []time.Time{start}
+	for _, key := range c.condition.Keys() {
+		samples := collector.GetKPIHistory(metrics.KPIKey(key))
+		if len(samples) == 0 || samples[0].Timestamp.After(start) {
+			return false
+		}
+		histories[key] = samples
+		for _, sample := range samples {
+			if sample.Timestamp.After(start) && !sample.Timestamp.After(now) {
+				times = append(times, sample.Timestamp)
+			}
+		}
+	}
+	for _, at := range times {
+		// Values as of at; targets are not kept in history, so the
+		// current ones apply.
+		env := func(key string) (float64, float64, bool) {
+			samples := histories[key]
+			i := sort.Search(len(samples), func(i int) bool { return samples[i].Timestamp.After(at) })
+			if i == 0 {
+				return 0, 0, false
+			}
+			var target float64
+			if kpi := collector.GetKPI(metrics.KPIKey(key)); kpi != nil {
+				target = kpi.Target
+			}
+			return samples[i-1].Value, target, true
+		}
+		if !c.condition.True(env) {
+			return false
+		}
+	}
+	return true
+}
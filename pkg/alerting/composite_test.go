@@ -0,0 +1,66 @@
+package alerting
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestCompositeHoldsForPeriod(t *testing.T) {
+	composite, err := newComposite(CompositeRule{Name: "stalled", Condition: "remediation_rate < 80 AND critical_open > 5", For: "168h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	collector := metrics.NewMetricsCollector()
+	collector.SetClock(clock.NewFake(now))
+	sample := func(key metrics.KPIKey, value float64, at time.Time) {
+		collector.AddKPISample(metrics.KPISample{Key: key, Value: value, Timestamp: at})
+	}
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_RemediationRate, Value: 70})
+	collector.AddKPI(metrics.KPI{Key: "critical_open", Value: 9})
+
+	// Only the current values are known: the period is not covered yet.
+	if composite.Holds(collector, now) {
+		t.Fatal("holds without history for the period")
+	}
+
+	sample(metrics.KPI_RemediationRate, 75, now.Add(-10*day))
+	sample("critical_open", 6, now.Add(-8*day))
+	sample(metrics.KPI_RemediationRate, 78, now.Add(-3*day))
+	if !composite.Holds(collector, now) {
+		t.Fatal("does not hold after 7 days")
+	}
+	if composite.Severity != "warning" {
+		t.Errorf("default severity = %q", composite.Severity)
+	}
+
+	// A dip within the period resets it.
+	sample("critical_open", 4, now.Add(-2*day))
+	sample("critical_open", 9, now.Add(-day))
+	if composite.Holds(collector, now) {
+		t.Error("holds despite a dip within the period")
+	}
+	if !composite.Holds(collector, now.Add(6*day+time.Hour)) {
+		t.Error("does not hold 7 days after the dip")
+	}
+}
+
+func TestCompositeValidation(t *testing.T) {
+	tests := map[string]Config{
+		"name and condition are required": {Composite: []CompositeRule{{Name: "x"}}},
+		"unexpected end of expression":    {Composite: []CompositeRule{{Name: "x", Condition: "mttr >"}}},
+		"severity must be":                {Composite: []CompositeRule{{Name: "x", Condition: "mttr > 1", Severity: "high"}}},
+		"invalid duration":                {Composite: []CompositeRule{{Name: "x", Condition: "mttr > 1", For: "7d"}}},
+		"duplicate name":                  {Composite: []CompositeRule{{Name: "x", Condition: "mttr > 1"}, {Name: "x", Condition: "mttd > 1"}}},
+	}
+	for want, cfg := range tests {
+		if _, err := NewEngine(cfg); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("NewEngine error = %v, want %q", err, want)
+		}
+	}
+}
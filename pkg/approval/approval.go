@@ -0,0 +1,248 @@
+// Package approval releases reports the way audit reports are released: a
+// report is drafted, submitted for review and approved by named approvers
+// before it goes to external recipients.
+package approval
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config configures the approval of scheduled reports.
+type Config struct {
+	// Required holds scheduled reports as drafts until they are approved,
+	// instead of delivering them as they are rendered.
+	Required bool `yaml:"required"`
+	// Approvers names who may approve reports; empty allows anyone but
+	// the submitter.
+	Approvers []string `yaml:"approvers"`
+	// MinApprovals is the number of distinct approvers a report needs
+	// (default 1).
+	MinApprovals int `yaml:"min_approvals"`
+}
+
+// Validate checks that the approvals required can be given.
+func (c Config) Validate() error {
+	if c.MinApprovals < 0 {
+		return fmt.Errorf("approval min_approvals: must not be negative")
+	}
+	if len(c.Approvers) > 0 && c.minApprovals() > len(c.Approvers) {
+		return fmt.Errorf("approval min_approvals: %d approvals required from %d approvers", c.minApprovals(), len(c.Approvers))
+	}
+	return nil
+}
+
+// minApprovals returns the number of approvals a report needs.
+func (c Config) minApprovals() int {
+	if c.MinApprovals == 0 {
+		return 1
+	}
+	return c.MinApprovals
+}
+
+// isApprover reports whether name may approve reports.
+func (c Config) isApprover(name string) bool {
+	if len(c.Approvers) == 0 {
+		return true
+	}
+	for _, approver := range c.Approvers {
+		if approver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNotFound is returned for an unknown report ID.
+var ErrNotFound = errors.New("report not found")
+
+// Status is the stage of a report in the workflow.
+type Status string
+
+const (
+	StatusDraft    Status = "draft"
+	StatusReview   Status = "review"
+	StatusApproved Status = "approved"
+)
+
+// Approval is the sign-off of an approver.
+type Approval struct {
+	By      string    `json:"by"`
+	At      time.Time `json:"at"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// Transition records a change of a report's status and who made it.
+type Transition struct {
+	At      time.Time `json:"at"`
+	By      string    `json:"by,omitempty"`
+	From    Status    `json:"from,omitempty"`
+	To      Status    `json:"to"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// Report is a rendered report held for approval.
+type Report struct {
+	// ID identifies the report, e.g. "weekly-20261001-090000".
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Schedule string `json:"schedule,omitempty"`
+	// Filename is the name the report is delivered under.
+	Filename    string    `json:"filename"`
+	Content     []byte    `json:"content,omitempty"`
+	Status      Status    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+	SubmittedBy string    `json:"submitted_by,omitempty"`
+	// Approvals are the sign-offs of the current review, in order.
+	Approvals   []Approval `json:"approvals,omitempty"`
+	DeliveredAt time.Time  `json:"delivered_at,omitempty"`
+	// History lists the transitions of the report, oldest first.
+	History []Transition `json:"history"`
+}
+
+// Approvers returns the names of the report's approvers.
+func (r *Report) Approvers() []string {
+	names := make([]string, len(r.Approvals))
+	for i, approval := range r.Approvals {
+		names[i] = approval.By
+	}
+	return names
+}
+
+// transition moves the report to status and records it in the history.
+func (r *Report) transition(to Status, by, comment string, at time.Time) {
+	r.History = append(r.History, Transition{At: at, By: by, From: r.Status, To: to, Comment: comment})
+	r.Status = to
+}
+
+// State holds the reports in the workflow, oldest first.
+type State struct {
+	Reports []Report `json:"reports"`
+}
+
+// Draft adds a rendered report as a draft.
+func (s *State) Draft(report Report) error {
+	if report.ID == "" {
+		return fmt.Errorf("report requires an id")
+	}
+	if s.Find(report.ID) != nil {
+		return fmt.Errorf("report %s already exists", report.ID)
+	}
+	report.Status, report.Approvals, report.History = "", nil, nil
+	report.transition(StatusDraft, "", "", report.CreatedAt)
+	s.Reports = append(s.Reports, report)
+	return nil
+}
+
+// Find returns the report with id, or nil.
+func (s *State) Find(id string) *Report {
+	for i := range s.Reports {
+		if s.Reports[i].ID == id {
+			return &s.Reports[i]
+		}
+	}
+	return nil
+}
+
+// get returns the report with id in status.
+func (s *State) get(id string, status Status) (*Report, error) {
+	report := s.Find(id)
+	if report == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if report.Status != status {
+		return nil, fmt.Errorf("report %s is %s, not %s", id, report.Status, status)
+	}
+	return report, nil
+}
+
+// Submit submits a draft for review by by.
+func (s *State) Submit(id, by, comment string, at time.Time) (*Report, error) {
+	if by == "" {
+		return nil, fmt.Errorf("submitting report %s requires a name", id)
+	}
+	report, err := s.get(id, StatusDraft)
+	if err != nil {
+		return nil, err
+	}
+	report.SubmittedBy, report.Approvals = by, nil
+	report.transition(StatusReview, by, comment, at)
+	return report, nil
+}
+
+// Approve records the approval of a report in review by by, who must be an
+// approver of cfg other than the submitter. The report is approved once it
+// has the approvals cfg requires.
+func (s *State) Approve(cfg Config, id, by, comment string, at time.Time) (*Report, error) {
+	if by == "" {
+		return nil, fmt.Errorf("approving report %s requires a name", id)
+	}
+	report, err := s.get(id, StatusReview)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !cfg.isApprover(by):
+		return nil, fmt.Errorf("%s is not an approver (%s)", by, strings.Join(cfg.Approvers, ", "))
+	case by == report.SubmittedBy:
+		return nil, fmt.Errorf("%s submitted report %s and cannot approve it", by, id)
+	}
+	for _, approval := range report.Approvals {
+		if approval.By == by {
+			return nil, fmt.Errorf("%s already approved report %s", by, id)
+		}
+	}
+	report.Approvals = append(report.Approvals, Approval{By: by, At: at, Comment: comment})
+	if len(report.Approvals) >= cfg.minApprovals() {
+		report.transition(StatusApproved, by, comment, at)
+	}
+	return report, nil
+}
+
+// Reject returns a report in review to draft, dropping its approvals; the
+// comment tells the submitter what to change.
+func (s *State) Reject(id, by, comment string, at time.Time) (*Report, error) {
+	if by == "" {
+		return nil, fmt.Errorf("rejecting report %s requires a name", id)
+	}
+	report, err := s.get(id, StatusReview)
+	if err != nil {
+		return nil, err
+	}
+	report.Approvals = nil
+	report.transition(StatusDraft, by, comment, at)
+	return report, nil
+}
+
+// Undelivered returns the IDs of the approved reports not yet delivered,
+// oldest first.
+func (s *State) Undelivered() []string {
+	var ids []string
+	for _, report := range s.Reports {
+		if report.Status == StatusApproved && report.DeliveredAt.IsZero() {
+			ids = append(ids, report.ID)
+		}
+	}
+	return ids
+}
+
+// MarkDelivered records that the approved report with id was delivered at.
+func (s *State) MarkDelivered(id string, at time.Time) {
+	if report := s.Find(id); report != nil && report.Status == StatusApproved {
+		report.DeliveredAt = at
+	}
+}
+
+// Sorted returns the reports newest first, without their content.
+func (s *State) Sorted() []Report {
+	reports := make([]Report, len(s.Reports))
+	for i, report := range s.Reports {
+		report.Content = nil
+		reports[i] = report
+	}
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].CreatedAt.After(reports[j].CreatedAt) })
+	return reports
+}
@@ -0,0 +1,90 @@
+package approval
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkflow(t *testing.T) {
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	cfg := Config{Approvers: []string{"ciso", "audit", "alice"}, MinApprovals: 2}
+	state := &State{}
+	if err := state.Draft(Report{ID: "weekly-20261001-090000", Filename: "weekly-20261001-090000.md", CreatedAt: at}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Draft(Report{ID: "weekly-20261001-090000"}); err == nil {
+		t.Error("drafted a report ID twice")
+	}
+	if _, err := state.Approve(cfg, "weekly-20261001-090000", "ciso", "", at); err == nil {
+		t.Error("approved a draft")
+	}
+	if _, err := state.Submit("missing", "alice", "", at); !errors.Is(err, ErrNotFound) {
+		t.Errorf("submit missing report: %v", err)
+	}
+	if _, err := state.Submit("weekly-20261001-090000", "alice", "", at); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, by := range []string{"alice", "mallory", ""} {
+		if _, err := state.Approve(cfg, "weekly-20261001-090000", by, "", at); err == nil {
+			t.Errorf("approval by %q accepted", by)
+		}
+	}
+	report, err := state.Approve(cfg, "weekly-20261001-090000", "ciso", "", at)
+	if err != nil || report.Status != StatusReview {
+		t.Fatalf("first of two approvals: %v, status %s", err, report.Status)
+	}
+	if _, err := state.Approve(cfg, "weekly-20261001-090000", "ciso", "", at); err == nil {
+		t.Error("approved twice by the same approver")
+	}
+
+	// A rejection drops the approvals given so far
+	if _, err := state.Reject("weekly-20261001-090000", "audit", "fix the MTTR chart", at); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Undelivered()) != 0 {
+		t.Error("rejected report is deliverable")
+	}
+	state.Submit("weekly-20261001-090000", "alice", "chart fixed", at)
+	state.Approve(cfg, "weekly-20261001-090000", "audit", "", at)
+	report, err = state.Approve(cfg, "weekly-20261001-090000", "ciso", "ok to release", at.Add(time.Hour))
+	if err != nil || report.Status != StatusApproved {
+		t.Fatalf("second approval: %v, status %s", err, report.Status)
+	}
+	if approvers := report.Approvers(); len(approvers) != 2 || approvers[0] != "audit" || approvers[1] != "ciso" {
+		t.Errorf("approvers = %v", approvers)
+	}
+	var path []Status
+	for _, transition := range report.History {
+		path = append(path, transition.To)
+	}
+	want := []Status{StatusDraft, StatusReview, StatusDraft, StatusReview, StatusApproved}
+	if len(path) != len(want) {
+		t.Fatalf("history = %v, want %v", path, want)
+	}
+	for i := range want {
+		if path[i] != want[i] {
+			t.Fatalf("history = %v, want %v", path, want)
+		}
+	}
+
+	if ids := state.Undelivered(); len(ids) != 1 || ids[0] != "weekly-20261001-090000" {
+		t.Errorf("undelivered = %v", ids)
+	}
+	state.MarkDelivered("weekly-20261001-090000", at.Add(2*time.Hour))
+	if ids := state.Undelivered(); len(ids) != 0 {
+		t.Errorf("undelivered after delivery = %v", ids)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, cfg := range []Config{{MinApprovals: -1}, {Approvers: []string{"ciso"}, MinApprovals: 2}} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%+v is valid", cfg)
+		}
+	}
+	if err := (Config{Required: true, MinApprovals: 2}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
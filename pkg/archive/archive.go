@@ -0,0 +1,145 @@
+// Package archive keeps the data snapshot each report was rendered from,
+// so a report can be regenerated byte-identically in another format later,
+// and corrections are issued as new versions linked to the original
+// instead of replacing it.
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for an unknown report ID.
+var ErrNotFound = errors.New("archived report not found")
+
+// Version is one archived version of a report.
+type Version struct {
+	// ID identifies the version, e.g. "rpt-20261001090000-executive" for
+	// an original and "rpt-20261001090000-executive-v2" for its first
+	// correction.
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Version numbers the versions of a report from 1, the original.
+	Version int `json:"version"`
+	// Original is the ID of the first version of a correction.
+	Original string `json:"original,omitempty"`
+	// Supersedes is the ID of the version a correction replaces.
+	Supersedes string    `json:"supersedes,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	By         string    `json:"by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Digests are the SHA-256 digests of the renderings delivered or
+	// written when the version was archived, by file extension.
+	Digests map[string]string `json:"digests,omitempty"`
+	// Snapshot is the report data the version renders from, as encoded by
+	// reporting.MarshalReport.
+	Snapshot json.RawMessage `json:"snapshot,omitempty"`
+}
+
+// Root returns the ID of the original version of v's report.
+func (v *Version) Root() string {
+	if v.Original != "" {
+		return v.Original
+	}
+	return v.ID
+}
+
+// Record records the digest of content rendered with file extension ext.
+func (v *Version) Record(ext string, content []byte) {
+	if v.Digests == nil {
+		v.Digests = make(map[string]string)
+	}
+	v.Digests[ext] = Digest(content)
+}
+
+// Verify checks content rendered with file extension ext against the
+// digest recorded for ext, if any, and reports whether one was recorded.
+func (v *Version) Verify(ext string, content []byte) (bool, error) {
+	digest, ok := v.Digests[ext]
+	if !ok {
+		return false, nil
+	}
+	if Digest(content) != digest {
+		return true, fmt.Errorf("regenerated %s of report %s does not match the archived digest", ext, v.ID)
+	}
+	return true, nil
+}
+
+// Digest returns the hex SHA-256 digest of content.
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Find returns the version with id, or nil.
+func Find(versions []Version, id string) *Version {
+	for i := range versions {
+		if versions[i].ID == id {
+			return &versions[i]
+		}
+	}
+	return nil
+}
+
+// Chain returns the versions of the report of the version with id, oldest
+// first.
+func Chain(versions []Version, id string) []Version {
+	version := Find(versions, id)
+	if version == nil {
+		return nil
+	}
+	var chain []Version
+	for _, other := range versions {
+		if other.Root() == version.Root() {
+			chain = append(chain, other)
+		}
+	}
+	sort.Slice(chain, func(i, j int) bool { return chain[i].Version < chain[j].Version })
+	return chain
+}
+
+// Correct returns the next version of the report of the version with id,
+// correcting it for reason. Only the latest version of a report can be
+// corrected, so the versions form a single line. The caller sets the
+// snapshot and digests of the correction.
+func Correct(versions []Version, id, by, reason string, at time.Time) (*Version, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("correcting report %s requires a reason", id)
+	}
+	chain := Chain(versions, id)
+	if chain == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	latest := chain[len(chain)-1]
+	if latest.ID != id {
+		return nil, fmt.Errorf("report %s is superseded by %s; correct the latest version", id, latest.ID)
+	}
+	next := latest.Version + 1
+	return &Version{
+		ID:         fmt.Sprintf("%s-v%d", latest.Root(), next),
+		Type:       latest.Type,
+		Version:    next,
+		Original:   latest.Root(),
+		Supersedes: latest.ID,
+		Reason:     reason,
+		By:         by,
+		CreatedAt:  at,
+	}, nil
+}
+
+// Sorted returns versions newest first, without their snapshots.
+func Sorted(versions []Version) []Version {
+	sorted := make([]Version, len(versions))
+	for i, version := range versions {
+		version.Snapshot = nil
+		sorted[i] = version
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.After(sorted[j].CreatedAt) })
+	return sorted
+}
@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCorrectionsFormALine(t *testing.T) {
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	original := Version{ID: "rpt-20261001090000-executive", Type: "executive", Version: 1, CreatedAt: at}
+	original.Record("txt", []byte("report"))
+	versions := []Version{original}
+
+	if _, err := Correct(versions, original.ID, "alice", " ", at); err == nil {
+		t.Error("corrected without a reason")
+	}
+	if _, err := Correct(versions, "missing", "alice", "wrong MTTR", at); !errors.Is(err, ErrNotFound) {
+		t.Errorf("correct missing report: %v", err)
+	}
+	v2, err := Correct(versions, original.ID, "alice", "wrong MTTR", at.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.ID != original.ID+"-v2" || v2.Version != 2 || v2.Original != original.ID || v2.Supersedes != original.ID || v2.Type != "executive" {
+		t.Errorf("correction %+v", v2)
+	}
+	versions = append(versions, *v2)
+	if _, err := Correct(versions, original.ID, "alice", "again", at); err == nil {
+		t.Error("corrected a superseded version")
+	}
+	v3, err := Correct(versions, v2.ID, "bob", "missing incident", at.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v3.ID != original.ID+"-v3" || v3.Original != original.ID || v3.Supersedes != v2.ID {
+		t.Errorf("second correction %+v", v3)
+	}
+	versions = append(versions, *v3)
+
+	chain := Chain([]Version{versions[2], versions[0], versions[1]}, v2.ID)
+	if len(chain) != 3 || chain[0].ID != original.ID || chain[2].ID != v3.ID {
+		t.Errorf("chain %+v", chain)
+	}
+	if sorted := Sorted(versions); sorted[0].ID != v3.ID {
+		t.Errorf("newest first: %s", sorted[0].ID)
+	}
+
+	if ok, err := original.Verify("txt", []byte("report")); !ok || err != nil {
+		t.Errorf("verify identical: %v, %v", ok, err)
+	}
+	if _, err := original.Verify("txt", []byte("changed")); err == nil {
+		t.Error("verified changed content")
+	}
+	if ok, _ := original.Verify("pdf", nil); ok {
+		t.Error("verified a format without a digest")
+	}
+}
@@ -0,0 +1,163 @@
+// Package attack exports detection coverage as a MITRE ATT&CK Navigator
+// layer, so coverage can be viewed in the standard Navigator UI.
+//
+// Coverage comes from detection metrics whose ID or name mentions an
+// ATT&CK technique ID, e.g. "T1059.001" or, for imported OpenMetrics
+// samples, detection_coverage{technique="T1566"}. The metric value is the
+// technique's coverage in percent; several metrics for one technique are
+// averaged.
+package attack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// Layer file versions the export follows.
+const (
+	LayerVersion     = "4.5"
+	NavigatorVersion = "5.1.0"
+)
+
+// techniqueID matches ATT&CK technique and sub-technique IDs.
+var techniqueID = regexp.MustCompile(`\bT\d{4}(?:\.\d{3})?\b`)
+
+// Layer is a Navigator layer document.
+type Layer struct {
+	Name        string       `json:"name"`
+	Versions    Versions     `json:"versions"`
+	Domain      string       `json:"domain"`
+	Description string       `json:"description"`
+	Gradient    Gradient     `json:"gradient"`
+	LegendItems []LegendItem `json:"legendItems"`
+	Techniques  []Technique  `json:"techniques"`
+}
+
+// Versions are the layer format and Navigator versions.
+type Versions struct {
+	Layer     string `json:"layer"`
+	Navigator string `json:"navigator"`
+}
+
+// Gradient colours techniques by score.
+type Gradient struct {
+	Colors   []string `json:"colors"`
+	MinValue float64  `json:"minValue"`
+	MaxValue float64  `json:"maxValue"`
+}
+
+// LegendItem explains a colour.
+type LegendItem struct {
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+// Technique is the score of one technique.
+type Technique struct {
+	TechniqueID       string     `json:"techniqueID"`
+	Score             float64    `json:"score"`
+	Comment           string     `json:"comment"`
+	Enabled           bool       `json:"enabled"`
+	ShowSubtechniques bool       `json:"showSubtechniques"`
+	Metadata          []Metadata `json:"metadata"`
+}
+
+// Metadata is a name/value pair shown with a technique.
+type Metadata struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Build returns the coverage layer of collector.
+func Build(collector *metrics.MetricsCollector) *Layer {
+	type coverage struct {
+		sum     float64
+		metrics []metrics.SecurityMetric
+	}
+	byTechnique := make(map[string]*coverage)
+	for _, metric := range collector.GetMetrics() {
+		if metric.Type != metrics.TypeDetection || math.IsNaN(metric.Value) {
+			continue
+		}
+		ids := techniqueID.FindAllString(metric.ID+" "+metric.Name, -1)
+		seen := make(map[string]bool)
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			c, ok := byTechnique[id]
+			if !ok {
+				c = &coverage{}
+				byTechnique[id] = c
+			}
+			c.sum += math.Max(0, math.Min(100, metric.Value))
+			c.metrics = append(c.metrics, metric)
+		}
+	}
+
+	ids := make([]string, 0, len(byTechnique))
+	for id := range byTechnique {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	layer := &Layer{
+		Name:        "secmetrics detection coverage",
+		Versions:    Versions{Layer: LayerVersion, Navigator: NavigatorVersion},
+		Domain:      "enterprise-attack",
+		Description: "Detection coverage per technique, in percent, from secmetrics detection metrics.",
+		Gradient:    Gradient{Colors: []string{"#ff6666ff", "#ffe766ff", "#8ec843ff"}, MinValue: 0, MaxValue: 100},
+		LegendItems: []LegendItem{
+			{Label: "No coverage", Color: "#ff6666ff"},
+			{Label: "Partial coverage", Color: "#ffe766ff"},
+			{Label: "Full coverage", Color: "#8ec843ff"},
+		},
+		Techniques: make([]Technique, 0, len(ids)),
+	}
+	for _, id := range ids {
+		c := byTechnique[id]
+		score := math.Round(c.sum/float64(len(c.metrics))*10) / 10
+		technique := Technique{TechniqueID: id, Score: score, Enabled: true, Metadata: []Metadata{}}
+		var names []string
+		for _, metric := range c.metrics {
+			name := metric.Name
+			if name == "" {
+				name = metric.ID
+			}
+			names = append(names, name)
+			technique.Metadata = append(technique.Metadata, Metadata{Name: name, Value: fmt.Sprintf("%g%%", metric.Value)})
+		}
+		technique.Comment = "Covered by " + strings.Join(names, ", ")
+		// Parents of scored sub-techniques show them expanded.
+		parent, _, sub := strings.Cut(id, ".")
+		if sub {
+			if i := sort.SearchStrings(ids, parent); i < len(ids) && ids[i] == parent {
+				layer.Techniques[i].ShowSubtechniques = true
+			}
+		}
+		layer.Techniques = append(layer.Techniques, technique)
+	}
+	return layer
+}
+
+// Export writes the coverage layer of collector as JSON. It fails when no
+// detection metric mentions a technique, since the layer would be empty.
+func Export(w io.Writer, collector *metrics.MetricsCollector) error {
+	layer := Build(collector)
+	if len(layer.Techniques) == 0 {
+		return fmt.Errorf("no detection metrics mention an ATT&CK technique ID (e.g. T1059)")
+	}
+	data, err := json.MarshalIndent(layer, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
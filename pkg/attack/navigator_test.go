@@ -0,0 +1,67 @@
+package attack
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestBuild(t *testing.T) {
+	collector := metrics.NewMetricsCollector()
+	collector.AddMetric(metrics.SecurityMetric{ID: "detection_coverage{technique=T1059.001}", Name: "detection_coverage", Type: metrics.TypeDetection, Value: 85})
+	collector.AddMetric(metrics.SecurityMetric{ID: "edr-T1059", Name: "EDR command interpreter rules", Type: metrics.TypeDetection, Value: 60})
+	collector.AddMetric(metrics.SecurityMetric{ID: "siem-T1059", Name: "SIEM T1059 correlation", Type: metrics.TypeDetection, Value: 140})
+	collector.AddMetric(metrics.SecurityMetric{ID: "phishing", Name: "Phishing T1566 detections", Type: metrics.TypeDetection, Value: 0})
+	// Only detection metrics describe coverage.
+	collector.AddMetric(metrics.SecurityMetric{ID: "T1190-patching", Type: metrics.TypeVulnerability, Value: 10})
+
+	layer := Build(collector)
+	if layer.Domain != "enterprise-attack" || layer.Versions.Layer != LayerVersion {
+		t.Errorf("layer header = %+v", layer)
+	}
+	want := []struct {
+		id    string
+		score float64
+		subs  bool
+		n     int
+	}{
+		{"T1059", 80, true, 2},
+		{"T1059.001", 85, false, 1},
+		{"T1566", 0, false, 1},
+	}
+	if len(layer.Techniques) != len(want) {
+		t.Fatalf("techniques = %+v", layer.Techniques)
+	}
+	for i, w := range want {
+		got := layer.Techniques[i]
+		if got.TechniqueID != w.id || got.Score != w.score || got.ShowSubtechniques != w.subs || len(got.Metadata) != w.n || !got.Enabled {
+			t.Errorf("technique %d = %+v, want %s scored %g", i, got, w.id, w.score)
+		}
+	}
+	if comment := layer.Techniques[0].Comment; comment != "Covered by EDR command interpreter rules, SIEM T1059 correlation" {
+		t.Errorf("comment = %q", comment)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var b bytes.Buffer
+	if err := Export(&b, metrics.NewMetricsCollector()); err == nil {
+		t.Error("empty layer exported")
+	}
+
+	collector := metrics.NewMetricsCollector()
+	collector.AddMetric(metrics.SecurityMetric{ID: "T1566", Type: metrics.TypeDetection, Value: 50})
+	if err := Export(&b, collector); err != nil {
+		t.Fatal(err)
+	}
+	var layer map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &layer); err != nil {
+		t.Fatal(err)
+	}
+	techniques := layer["techniques"].([]interface{})
+	if len(techniques) != 1 || techniques[0].(map[string]interface{})["techniqueID"] != "T1566" {
+		t.Errorf("techniques = %v", techniques)
+	}
+}
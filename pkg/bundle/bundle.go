@@ -0,0 +1,220 @@
+// Package bundle packs configuration, stored metrics and reports into a
+// single encrypted archive for transfer between air-gapped environments.
+//
+// A bundle is a gzipped tar archive encrypted with AES-256-GCM. It holds a
+// manifest, the configuration file, the store snapshot in plaintext JSON
+// (the bundle itself is the encryption boundary, so stores encrypted at
+// rest with an environment's own key can be moved) and report files.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// FormatVersion is the bundle layout version written by this build.
+const FormatVersion = 1
+
+// DefaultKeyEnv is the environment variable holding the base64 bundle key.
+const DefaultKeyEnv = "SECMETRICS_BUNDLE_KEY"
+
+// MaxSize bounds the unpacked size of a bundle.
+const MaxSize = 1 << 30
+
+// Archive entry names.
+const (
+	manifestEntry = "manifest.json"
+	configEntry   = "secmetrics.yaml"
+	storeEntry    = "store.json"
+	reportsDir    = "reports/"
+)
+
+// Bundle is the content of a bundle.
+type Bundle struct {
+	// Version is the secmetrics version that created the bundle.
+	Version   string
+	CreatedAt time.Time
+	// Config is the configuration file; nil when not included.
+	Config []byte
+	// Snapshot is the store content; nil when not included.
+	Snapshot *store.Snapshot
+	// Reports are report files keyed by slash-separated relative path.
+	Reports map[string][]byte
+}
+
+// Manifest describes a bundle's entries.
+type Manifest struct {
+	FormatVersion int         `json:"format_version"`
+	Version       string      `json:"version"`
+	CreatedAt     time.Time   `json:"created_at"`
+	Files         []FileEntry `json:"files"`
+}
+
+// FileEntry describes an entry of the bundle.
+type FileEntry struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Seal packs and encrypts b.
+func Seal(b *Bundle, cipher *encryption.Cipher) ([]byte, error) {
+	files := make(map[string][]byte)
+	var names []string
+	add := func(name string, data []byte) {
+		files[name] = data
+		names = append(names, name)
+	}
+	if b.Config != nil {
+		add(configEntry, b.Config)
+	}
+	if b.Snapshot != nil {
+		data, err := json.MarshalIndent(b.Snapshot, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		add(storeEntry, data)
+	}
+	for _, name := range sortedKeys(b.Reports) {
+		if err := checkReportName(name); err != nil {
+			return nil, err
+		}
+		add(reportsDir+name, b.Reports[name])
+	}
+
+	manifest := Manifest{FormatVersion: FormatVersion, Version: b.Version, CreatedAt: b.CreatedAt.UTC()}
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		manifest.Files = append(manifest.Files, FileEntry{Name: name, Size: len(files[name]), SHA256: hex.EncodeToString(sum[:])})
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, name := range append([]string{manifestEntry}, names...) {
+		data := manifestData
+		if name != manifestEntry {
+			data = files[name]
+		}
+		header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: manifest.CreatedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return cipher.Encrypt(buf.Bytes())
+}
+
+// Open decrypts and unpacks a bundle, verifying every entry against the
+// manifest.
+func Open(data []byte, cipher *encryption.Cipher) (*Bundle, *Manifest, error) {
+	if !encryption.IsEncrypted(data) {
+		return nil, nil, errors.New("bundle: not a secmetrics bundle")
+	}
+	archive, err := cipher.Decrypt(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bundle: %w (wrong key?)", err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, nil, fmt.Errorf("bundle: %w", err)
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(io.LimitReader(gz, MaxSize))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bundle: %w", err)
+		}
+		files[header.Name] = content
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(files[manifestEntry], &manifest); err != nil {
+		return nil, nil, fmt.Errorf("bundle: read manifest: %w", err)
+	}
+	if manifest.FormatVersion > FormatVersion {
+		return nil, nil, fmt.Errorf("bundle: format version %d is newer than this build supports (%d)", manifest.FormatVersion, FormatVersion)
+	}
+
+	b := &Bundle{Version: manifest.Version, CreatedAt: manifest.CreatedAt, Reports: make(map[string][]byte)}
+	for _, entry := range manifest.Files {
+		content, ok := files[entry.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("bundle: %s is missing", entry.Name)
+		}
+		if sum := sha256.Sum256(content); hex.EncodeToString(sum[:]) != entry.SHA256 {
+			return nil, nil, fmt.Errorf("bundle: %s does not match its checksum", entry.Name)
+		}
+		switch {
+		case entry.Name == configEntry:
+			b.Config = content
+		case entry.Name == storeEntry:
+			b.Snapshot = &store.Snapshot{}
+			if err := json.Unmarshal(content, b.Snapshot); err != nil {
+				return nil, nil, fmt.Errorf("bundle: parse store: %w", err)
+			}
+		case strings.HasPrefix(entry.Name, reportsDir):
+			name := strings.TrimPrefix(entry.Name, reportsDir)
+			if err := checkReportName(name); err != nil {
+				return nil, nil, err
+			}
+			b.Reports[name] = content
+		}
+	}
+	return b, &manifest, nil
+}
+
+// checkReportName rejects report paths that could escape the reports
+// directory on import.
+func checkReportName(name string) error {
+	if name == "" || path.IsAbs(name) || strings.Contains(name, `\`) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+		return fmt.Errorf("bundle: invalid report path %q", name)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+func testCipher(t *testing.T) *encryption.Cipher {
+	t.Helper()
+	cipher, err := encryption.NewCipher([]byte(strings.Repeat("k", encryption.KeySize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+func TestSealOpen(t *testing.T) {
+	cipher := testCipher(t)
+	in := &Bundle{
+		Version:   "1.0.0",
+		CreatedAt: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC),
+		Config:    []byte("store:\n  path: store.json\n"),
+		Snapshot:  &store.Snapshot{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Value: 2.5}}},
+		Reports:   map[string][]byte{"2026-09/ops.md": []byte("# Ops"), "summary.html": []byte("<html>")},
+	}
+	data, err := Seal(in, cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "store.json") {
+		t.Fatal("bundle is not encrypted")
+	}
+
+	out, manifest, err := Open(data, cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 4 {
+		t.Errorf("manifest lists %d files, want 4", len(manifest.Files))
+	}
+	if string(out.Config) != string(in.Config) || !out.CreatedAt.Equal(in.CreatedAt) || out.Version != "1.0.0" {
+		t.Errorf("bundle = %+v", out)
+	}
+	if out.Snapshot == nil || len(out.Snapshot.KPIs) != 1 || out.Snapshot.KPIs[0].Value != 2.5 {
+		t.Errorf("snapshot = %+v", out.Snapshot)
+	}
+	if string(out.Reports["2026-09/ops.md"]) != "# Ops" || len(out.Reports) != 2 {
+		t.Errorf("reports = %v", out.Reports)
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	data, err := Seal(&Bundle{Config: []byte("x")}, testCipher(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := encryption.NewCipher([]byte(strings.Repeat("o", encryption.KeySize)))
+	if _, _, err := Open(data, other); err == nil {
+		t.Fatal("opened a bundle with the wrong key")
+	}
+}
+
+func TestSealRejectsEscapingReportPaths(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "/abs.md", "a/../../b.md", `..\win.md`, ""} {
+		if _, err := Seal(&Bundle{Reports: map[string][]byte{name: nil}}, testCipher(t)); err == nil {
+			t.Errorf("sealed report path %q", name)
+		}
+	}
+}
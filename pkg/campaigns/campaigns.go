@@ -0,0 +1,234 @@
+// Package campaigns tracks time-boxed remediation campaigns, such as a
+// Log4j cleanup, through the findings in their scope: burn-down, progress
+// per owner and adherence to the deadline.
+package campaigns
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Campaign states, from the burn-down of the scoped findings.
+const (
+	StateNotStarted = "NOT_STARTED"
+	StateOnTrack    = "ON_TRACK"
+	StateAtRisk     = "AT_RISK"
+	StateOverdue    = "OVERDUE"
+	StateCompleted  = "COMPLETED"
+)
+
+// maxBurnDownDays bounds the burn-down of long-running campaigns.
+const maxBurnDownDays = 366
+
+// Config configures a remediation campaign.
+type Config struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Owner is accountable for the campaign and owns findings that name
+	// no owner of their own.
+	Owner string `yaml:"owner"`
+	// Start and Deadline are dates as YYYY-MM-DD; the deadline is the last
+	// day of the campaign.
+	Start    string `yaml:"start"`
+	Deadline string `yaml:"deadline"`
+	// Findings is an export (.csv, .json or .jsonl) of the findings in
+	// scope, with finding_id, title, asset, owner, opened_at and
+	// resolved_at columns.
+	Findings string `yaml:"findings"`
+}
+
+// Finding is a finding in the scope of a campaign. ResolvedAt is zero
+// while it is open; a zero OpenedAt counts from the campaign start.
+type Finding struct {
+	ID         string
+	Title      string
+	Asset      string
+	Owner      string
+	OpenedAt   time.Time
+	ResolvedAt time.Time
+}
+
+// Campaign is a validated campaign.
+type Campaign struct {
+	Config
+	start time.Time
+	// end is the end of the deadline day.
+	end time.Time
+}
+
+// New validates cfg.
+func New(cfg Config) (*Campaign, error) {
+	if cfg.Name == "" || cfg.Findings == "" {
+		return nil, fmt.Errorf("campaign: name and findings are required")
+	}
+	start, err := time.ParseInLocation(time.DateOnly, cfg.Start, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("campaign %s: invalid start %q (want YYYY-MM-DD)", cfg.Name, cfg.Start)
+	}
+	deadline, err := time.ParseInLocation(time.DateOnly, cfg.Deadline, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("campaign %s: invalid deadline %q (want YYYY-MM-DD)", cfg.Name, cfg.Deadline)
+	}
+	if deadline.Before(start) {
+		return nil, fmt.Errorf("campaign %s: deadline %s is before start %s", cfg.Name, cfg.Deadline, cfg.Start)
+	}
+	return &Campaign{Config: cfg, start: start, end: deadline.AddDate(0, 0, 1)}, nil
+}
+
+// BurnDownPoint is the number of findings open at the end of a day,
+// against the ideal straight line from all findings at the start to none
+// at the deadline.
+type BurnDownPoint struct {
+	Date  time.Time
+	Open  int
+	Ideal float64
+}
+
+// OwnerStatus is the progress of one owner's findings.
+type OwnerStatus struct {
+	Owner    string
+	Open     int
+	Resolved int
+	// Overdue counts findings still open after the deadline.
+	Overdue int
+}
+
+// Status is the state of a campaign at a point in time.
+type Status struct {
+	Name        string
+	Description string
+	Owner       string
+	Start       time.Time
+	Deadline    time.Time
+	State       string
+	Total       int
+	Open        int
+	Resolved    int
+	// ResolvedOnTime counts findings resolved by the end of the deadline
+	// day.
+	ResolvedOnTime int
+	// Expected is the percentage of findings the ideal burn-down has
+	// resolved at the time of evaluation.
+	Expected float64
+	BurnDown []BurnDownPoint
+	Owners   []OwnerStatus
+}
+
+// Progress returns the percentage of findings resolved, 100 for a
+// campaign without findings.
+func (s Status) Progress() float64 {
+	if s.Total == 0 {
+		return 100
+	}
+	return float64(s.Resolved) / float64(s.Total) * 100
+}
+
+// Adherence returns the percentage of findings resolved by the deadline,
+// 100 for a campaign without findings.
+func (s Status) Adherence() float64 {
+	if s.Total == 0 {
+		return 100
+	}
+	return float64(s.ResolvedOnTime) / float64(s.Total) * 100
+}
+
+// Expected returns the percentage of findings the ideal burn-down has
+// resolved at now.
+func (c *Campaign) Expected(now time.Time) float64 {
+	switch {
+	case !now.After(c.start):
+		return 0
+	case !now.Before(c.end):
+		return 100
+	}
+	return float64(now.Sub(c.start)) / float64(c.end.Sub(c.start)) * 100
+}
+
+// Evaluate returns the status of the campaign at now. Findings resolved
+// after now are open at now.
+func (c *Campaign) Evaluate(findings []Finding, now time.Time) Status {
+	status := Status{
+		Name:        c.Name,
+		Description: c.Description,
+		Owner:       c.Owner,
+		Start:       c.start,
+		Deadline:    c.end.AddDate(0, 0, -1),
+		Total:       len(findings),
+		Expected:    c.Expected(now),
+	}
+	resolved := func(f Finding, at time.Time) bool {
+		return !f.ResolvedAt.IsZero() && !f.ResolvedAt.After(at)
+	}
+
+	owners := make(map[string]*OwnerStatus)
+	for _, finding := range findings {
+		owner := finding.Owner
+		if owner == "" {
+			owner = c.Owner
+		}
+		if owners[owner] == nil {
+			owners[owner] = &OwnerStatus{Owner: owner}
+		}
+		if resolved(finding, now) {
+			status.Resolved++
+			owners[owner].Resolved++
+			if finding.ResolvedAt.Before(c.end) {
+				status.ResolvedOnTime++
+			}
+			continue
+		}
+		status.Open++
+		owners[owner].Open++
+		if !now.Before(c.end) {
+			owners[owner].Overdue++
+		}
+	}
+	for _, owner := range owners {
+		status.Owners = append(status.Owners, *owner)
+	}
+	// Owners with the most open findings first.
+	sort.Slice(status.Owners, func(i, j int) bool {
+		a, b := status.Owners[i], status.Owners[j]
+		if a.Open != b.Open {
+			return a.Open > b.Open
+		}
+		return a.Owner < b.Owner
+	})
+
+	last := now
+	if last.After(c.end) {
+		last = c.end
+	}
+	for day := c.start; day.Before(last) && len(status.BurnDown) < maxBurnDownDays; day = day.AddDate(0, 0, 1) {
+		at := day.AddDate(0, 0, 1)
+		if at.After(now) {
+			at = now
+		}
+		var open int
+		for _, finding := range findings {
+			if finding.OpenedAt.After(at) || resolved(finding, at) {
+				continue
+			}
+			open++
+		}
+		ideal := float64(status.Total) * (1 - c.Expected(at)/100)
+		status.BurnDown = append(status.BurnDown, BurnDownPoint{Date: day, Open: open, Ideal: ideal})
+	}
+
+	switch {
+	case status.Total > 0 && status.Open == 0:
+		status.State = StateCompleted
+	case now.Before(c.start):
+		status.State = StateNotStarted
+	case status.Open == 0:
+		status.State = StateOnTrack
+	case !now.Before(c.end):
+		status.State = StateOverdue
+	case status.Progress() < status.Expected:
+		status.State = StateAtRisk
+	default:
+		status.State = StateOnTrack
+	}
+	return status
+}
@@ -0,0 +1,74 @@
+package campaigns
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	c, err := New(Config{Name: "Log4j cleanup", Owner: "appsec", Start: "2026-10-01", Deadline: "2026-10-04", Findings: "log4j.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(d, h int) time.Time { return time.Date(2026, 10, d, h, 0, 0, 0, time.Local) }
+	findings := []Finding{
+		{ID: "F-1", Owner: "web", ResolvedAt: day(1, 12)},
+		{ID: "F-2", Owner: "web", ResolvedAt: day(3, 9)},
+		{ID: "F-3", Owner: "data", OpenedAt: day(2, 8), ResolvedAt: day(6, 10)},
+		{ID: "F-4"},
+	}
+
+	status := c.Evaluate(findings, day(3, 0))
+	if status.State != StateAtRisk || status.Open != 3 || status.Resolved != 1 {
+		t.Errorf("day 3: state %s, open %d, resolved %d; want AT_RISK, 3, 1", status.State, status.Open, status.Resolved)
+	}
+	var open []int
+	for _, point := range status.BurnDown {
+		open = append(open, point.Open)
+	}
+	if !reflect.DeepEqual(open, []int{2, 3}) {
+		t.Errorf("burn-down = %v, want [2 3]", open)
+	}
+	if ideal := status.BurnDown[1].Ideal; ideal != 2 {
+		t.Errorf("ideal open at the end of day 2 = %v, want 2", ideal)
+	}
+
+	status = c.Evaluate(findings, day(7, 0))
+	if status.State != StateOverdue || status.Resolved != 3 || status.ResolvedOnTime != 2 {
+		t.Errorf("after deadline: state %s, resolved %d, on time %d; want OVERDUE, 3, 2", status.State, status.Resolved, status.ResolvedOnTime)
+	}
+	if got := status.Adherence(); got != 50 {
+		t.Errorf("adherence = %v, want 50", got)
+	}
+	if len(status.BurnDown) != 4 {
+		t.Errorf("burn-down has %d days, want the 4 campaign days", len(status.BurnDown))
+	}
+	want := []OwnerStatus{{Owner: "appsec", Open: 1, Overdue: 1}, {Owner: "data", Resolved: 1}, {Owner: "web", Resolved: 2}}
+	if !reflect.DeepEqual(status.Owners, want) {
+		t.Errorf("owners = %+v, want %+v", status.Owners, want)
+	}
+
+	findings[3].ResolvedAt = day(6, 12)
+	if status := c.Evaluate(findings, day(7, 0)); status.State != StateCompleted {
+		t.Errorf("all resolved: state %s, want COMPLETED", status.State)
+	}
+	if status := c.Evaluate(findings, day(1, 0).Add(-time.Hour)); status.State != StateNotStarted {
+		t.Errorf("before start: state %s, want NOT_STARTED", status.State)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := map[string]Config{
+		"name and findings are required": {Start: "2026-10-01", Deadline: "2026-10-31"},
+		"invalid start":                  {Name: "x", Findings: "f.csv", Start: "10/01/2026", Deadline: "2026-10-31"},
+		"invalid deadline":               {Name: "x", Findings: "f.csv", Start: "2026-10-01"},
+		"is before start":                {Name: "x", Findings: "f.csv", Start: "2026-10-31", Deadline: "2026-10-01"},
+	}
+	for want, cfg := range tests {
+		if _, err := New(cfg); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("New(%+v) error = %v, want %q", cfg, err, want)
+		}
+	}
+}
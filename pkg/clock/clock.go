@@ -0,0 +1,145 @@
+// Package clock provides an injectable time source so history, reports and
+// scheduling can be driven deterministically in tests.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates tickers.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a ticker that fires every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks on a channel until stopped.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// System is the wall clock.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// Fake is a clock that only moves when advanced. It is safe for concurrent
+// use.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFake returns a fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the fake time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to t, firing tickers that fall due.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+	for _, ticker := range f.tickers {
+		ticker.fire(t)
+	}
+}
+
+// Advance moves the clock forward by d, firing tickers that fall due.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// NewTicker returns a ticker that fires when the clock is advanced past
+// each multiple of d. Like time.Ticker it drops ticks a slow receiver
+// misses.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ticker := &fakeTicker{clock: f, c: make(chan time.Time, 1), interval: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, ticker)
+	return ticker
+}
+
+// Tickers returns the number of running tickers, so a test can wait for
+// the code under test to start waiting before advancing the clock.
+func (f *Fake) Tickers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.tickers)
+}
+
+// removeTicker stops delivering ticks to ticker.
+func (f *Fake) removeTicker(ticker *fakeTicker) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, t := range f.tickers {
+		if t == ticker {
+			f.tickers = append(f.tickers[:i], f.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock    *Fake
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.removeTicker(t)
+}
+
+// fire delivers a tick if now has reached the next tick time. It is called
+// with the clock's lock held.
+func (t *fakeTicker) fire(now time.Time) {
+	if now.Before(t.next) {
+		return
+	}
+	select {
+	case t.c <- now:
+	default:
+	}
+	for !now.Before(t.next) {
+		t.next = t.next.Add(t.interval)
+	}
+}
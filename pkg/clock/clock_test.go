@@ -0,0 +1,70 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+func TestFakeAdvance(t *testing.T) {
+	clk := NewFake(epoch)
+	if got := clk.Now(); !got.Equal(epoch) {
+		t.Fatalf("Now() = %v, want %v", got, epoch)
+	}
+	clk.Advance(90 * time.Minute)
+	if got, want := clk.Now(), epoch.Add(90*time.Minute); !got.Equal(want) {
+		t.Fatalf("after Advance, Now() = %v, want %v", got, want)
+	}
+}
+
+// ticks drains the ticks currently pending on ticker.
+func ticks(ticker Ticker) []time.Time {
+	var got []time.Time
+	for {
+		select {
+		case tick := <-ticker.C():
+			got = append(got, tick)
+		default:
+			return got
+		}
+	}
+}
+
+func TestFakeTicker(t *testing.T) {
+	clk := NewFake(epoch)
+	ticker := clk.NewTicker(time.Hour)
+
+	clk.Advance(59 * time.Minute)
+	if got := ticks(ticker); len(got) != 0 {
+		t.Fatalf("ticked before the interval elapsed: %v", got)
+	}
+
+	clk.Advance(time.Minute)
+	if got := ticks(ticker); len(got) != 1 || !got[0].Equal(epoch.Add(time.Hour)) {
+		t.Fatalf("ticks at 1h = %v, want one tick at %v", got, epoch.Add(time.Hour))
+	}
+
+	// Like time.Ticker, ticks a receiver misses are dropped rather than
+	// queued, and the schedule keeps its phase.
+	clk.Advance(3*time.Hour + 30*time.Minute)
+	if got := ticks(ticker); len(got) != 1 {
+		t.Fatalf("ticks after skipping 3h = %d, want 1", len(got))
+	}
+	clk.Advance(30 * time.Minute)
+	if got := ticks(ticker); len(got) != 1 || !got[0].Equal(epoch.Add(5*time.Hour)) {
+		t.Fatalf("ticks at 5h = %v, want one tick at %v", got, epoch.Add(5*time.Hour))
+	}
+
+	if n := clk.Tickers(); n != 1 {
+		t.Fatalf("running tickers = %d, want 1", n)
+	}
+	ticker.Stop()
+	clk.Advance(time.Hour)
+	if got := ticks(ticker); len(got) != 0 {
+		t.Fatalf("stopped ticker ticked: %v", got)
+	}
+	if n := clk.Tickers(); n != 0 {
+		t.Fatalf("running tickers after Stop = %d, want 0", n)
+	}
+}
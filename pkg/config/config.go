@@ -0,0 +1,134 @@
+// Package config provides secmetrics configuration loading.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hallucinaut/secmetrics/pkg/delivery"
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/httpclient"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/narrative"
+	"github.com/hallucinaut/secmetrics/pkg/oscal"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+	"github.com/hallucinaut/secmetrics/pkg/sources"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+	"github.com/hallucinaut/secmetrics/pkg/update"
+)
+
+// DefaultPath is the configuration file used when none is given.
+const DefaultPath = "secmetrics.yaml"
+
+// Config represents the secmetrics configuration file.
+type Config struct {
+	HTTP       httpclient.Config `yaml:"http"`
+	Store      store.Config      `yaml:"store"`
+	Encryption encryption.Config `yaml:"encryption"`
+	Delivery   delivery.Config   `yaml:"delivery"`
+	Server     server.Config     `yaml:"server"`
+	Sources    sources.Config    `yaml:"sources"`
+	Taxonomy   metrics.Taxonomy  `yaml:"taxonomy"`
+	Report     reporting.Config  `yaml:"report"`
+	Update     update.Config     `yaml:"update"`
+	OSCAL      oscal.Config      `yaml:"oscal"`
+	// Narrative drafts quarterly report narratives through a
+	// summarization endpoint; disabled unless a URL is set.
+	Narrative narrative.Config `yaml:"narrative"`
+	// Fiscal is the fiscal calendar that quarters, quarter-over-quarter
+	// comparisons and targets follow; default the calendar year.
+	Fiscal metrics.FiscalCalendar `yaml:"fiscal"`
+	// Scoring is the score model: asset tier weights and health levels.
+	// Scores are tagged with its version; see metrics.ScoreModel.
+	Scoring metrics.ScoreModel `yaml:"scoring"`
+	// APIVersion is the collector API version, v1 (default) or v2; v2
+	// rejects invalid metrics and KPIs that v1 accepts. See
+	// metrics.APIVersion.
+	APIVersion string `yaml:"api_version"`
+	// ReadOnly disables every change to the store, config and binary, for
+	// instances exposed to broad audiences such as wallboards.
+	ReadOnly bool `yaml:"read_only"`
+	// Profile is the name of the applied profile, empty when none is
+	// selected. See ProfileEnv.
+	Profile string `yaml:"-"`
+}
+
+// LoadOrDefault reads configuration from path, returning the
+// configuration set by environment variables alone when the file does not
+// exist.
+func LoadOrDefault(path string) (*Config, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return parse(nil, os.LookupEnv)
+	}
+	return Load(path)
+}
+
+// Load reads configuration from path. Environment variables listed by
+// EnvVars override the file's settings.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	return parse(data, os.LookupEnv)
+}
+
+// Parse parses YAML configuration, applying the environment like Load.
+func Parse(data []byte) (*Config, error) {
+	return parse(data, os.LookupEnv)
+}
+
+// parse parses YAML configuration, interpolates the environment variables
+// lookup finds, applies the profile named by ProfileEnv and the variables
+// overriding settings, and validates the result.
+func parse(data []byte, lookup func(string) (string, bool)) (*Config, error) {
+	cfg := &Config{}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if err := interpolateConfig(&doc, lookup); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if doc.Kind != 0 {
+		if err := doc.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+	}
+	if profile, _ := lookup(ProfileEnv); profile != "" {
+		if err := cfg.applyProfile(&doc, profile, lookup); err != nil {
+			return nil, fmt.Errorf("parse config: %w", err)
+		}
+	}
+	if err := cfg.applyEnv(lookup); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if err := cfg.Taxonomy.Validate(); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if err := cfg.Fiscal.Validate(); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if err := cfg.Scoring.Validate(); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if err := cfg.Report.Validate(); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if _, err := metrics.ParseAPIVersion(cfg.APIVersion); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// Path returns the configuration path from SECMETRICS_CONFIG or DefaultPath.
+func Path() string {
+	if path := os.Getenv("SECMETRICS_CONFIG"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
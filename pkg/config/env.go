@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the names of the environment variables that override
+// configuration settings.
+const EnvPrefix = "SECMETRICS_"
+
+// EnvVar is an environment variable that overrides a setting. Its name is
+// EnvPrefix followed by the setting's YAML path in upper case, joined by
+// underscores, e.g. SECMETRICS_SERVER_RATE_LIMIT_PER_IP.
+type EnvVar struct {
+	Name string
+	// Type is the value syntax: "string", "bool", "integer", "number",
+	// "list" for comma-separated values, or "yaml" for lists of objects
+	// and maps, given as YAML or JSON.
+	Type string
+
+	index []int
+}
+
+// EnvVars returns every environment variable that overrides a setting, in
+// configuration file order.
+func EnvVars() []EnvVar {
+	return envVars(reflect.TypeOf(Config{}), strings.TrimSuffix(EnvPrefix, "_"), nil)
+}
+
+func envVars(t reflect.Type, name string, index []int) []EnvVar {
+	var vars []EnvVar
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if key == "-" || !field.IsExported() {
+			continue
+		}
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		fieldName := name + "_" + strings.ToUpper(key)
+		fieldIndex := append(append([]int{}, index...), i)
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct {
+			vars = append(vars, envVars(fieldType, fieldName, fieldIndex)...)
+			continue
+		}
+		vars = append(vars, EnvVar{Name: fieldName, Type: envType(fieldType), index: fieldIndex})
+	}
+	return vars
+}
+
+// envType describes the value syntax of a setting of type t.
+func envType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return "list"
+		}
+	}
+	return "yaml"
+}
+
+// applyEnv overrides the settings of c with the environment variables
+// lookup finds. A variable that is not set is read from the file named by
+// the variable with secretenv.FileSuffix, for secrets mounted into a
+// container. Setting any variable of an optional section, such as a
+// collection source, enables the section.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	root := reflect.ValueOf(c).Elem()
+	for _, env := range EnvVars() {
+		value, ok, err := lookupEnv(lookup, env.Name)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		if err := setEnvValue(fieldOf(root, env.index), env.Type, value); err != nil {
+			return fmt.Errorf("environment variable %s: %w", env.Name, err)
+		}
+	}
+	return nil
+}
+
+// fieldOf returns the field of v at index, allocating nil optional
+// sections on the way.
+func fieldOf(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// setEnvValue parses value as syntax kind into v.
+func setEnvValue(v reflect.Value, kind, value string) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		if err := setEnvValue(elem.Elem(), kind, value); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	switch kind {
+	case "string":
+		v.SetString(value)
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", value)
+		}
+		v.SetBool(b)
+	case "integer":
+		if v.CanUint() {
+			n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("invalid integer %q", value)
+			}
+			v.SetUint(n)
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case "number":
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(f)
+	case "list":
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = reflect.Append(list, reflect.ValueOf(item).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(list)
+	default:
+		parsed := reflect.New(v.Type())
+		if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+			return err
+		}
+		v.Set(parsed.Elem())
+	}
+	return nil
+}
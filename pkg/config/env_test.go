@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvOverridesFile(t *testing.T) {
+	env := map[string]string{
+		"SECMETRICS_SERVER_ADDR":                      ":8080",
+		"SECMETRICS_SERVER_RATE_LIMIT_PER_IP":         "300",
+		"SECMETRICS_SERVER_AUTH_KEYS":                 `[{"name": "ops", "key_env": "OPS_KEY", "role": "admin"}]`,
+		"SECMETRICS_SERVER_AUTH_OIDC_SCOPES":          "openid, email,groups",
+		"SECMETRICS_STORE_PATH":                       "/data/store.json",
+		"SECMETRICS_READ_ONLY":                        "true",
+		"SECMETRICS_SOURCES_DMARC_REPORTS_DIR":        "/data/dmarc",
+		"SECMETRICS_SERVER_LEADER_ELECTION_NAMESPACE": "ops",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	cfg, err := parse([]byte("server:\n  addr: \":9090\"\n  interval: 1h\n"), lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":8080" || cfg.Server.Interval != "1h" {
+		t.Errorf("server addr %q interval %q", cfg.Server.Addr, cfg.Server.Interval)
+	}
+	if cfg.Server.RateLimit.PerIP != 300 || !cfg.ReadOnly || cfg.Store.Path != "/data/store.json" {
+		t.Errorf("per_ip %d read_only %v store %q", cfg.Server.RateLimit.PerIP, cfg.ReadOnly, cfg.Store.Path)
+	}
+	if keys := cfg.Server.Auth.Keys; len(keys) != 1 || keys[0].KeyEnv != "OPS_KEY" || keys[0].Role != "admin" {
+		t.Errorf("auth keys = %+v", keys)
+	}
+	if scopes := strings.Join(cfg.Server.Auth.OIDC.Scopes, "|"); scopes != "openid|email|groups" {
+		t.Errorf("scopes = %q", scopes)
+	}
+	if cfg.Sources.DMARC == nil || cfg.Sources.DMARC.ReportsDir != "/data/dmarc" {
+		t.Errorf("dmarc source = %+v, want it enabled by its variable", cfg.Sources.DMARC)
+	}
+	if cfg.Sources.FleetDM != nil {
+		t.Error("fleetdm source enabled without variables")
+	}
+
+	env = map[string]string{"SECMETRICS_SERVER_RATE_LIMIT_PER_IP": "many"}
+	if _, err := parse(nil, lookup); err == nil || !strings.Contains(err.Error(), "SECMETRICS_SERVER_RATE_LIMIT_PER_IP") {
+		t.Errorf("invalid integer: %v", err)
+	}
+}
+
+func TestEnvFromSecretFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fleetdm-token")
+	if err := os.WriteFile(path, []byte("t0ken\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"SECMETRICS_SOURCES_FLEETDM_URL":            "https://fleet.example.com",
+		"SECMETRICS_SOURCES_FLEETDM_API_TOKEN_FILE": path,
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	cfg, err := parse(nil, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Sources.FleetDM == nil || cfg.Sources.FleetDM.APIToken != "t0ken" {
+		t.Errorf("fleetdm source = %+v, want the token from the file", cfg.Sources.FleetDM)
+	}
+
+	env["SECMETRICS_SOURCES_FLEETDM_API_TOKEN_FILE"] = filepath.Join(t.TempDir(), "missing")
+	if _, err := parse(nil, lookup); err == nil || !strings.Contains(err.Error(), "SECMETRICS_SOURCES_FLEETDM_API_TOKEN_FILE") {
+		t.Errorf("missing secret file: %v", err)
+	}
+}
+
+func TestEnvVarNamesAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, env := range EnvVars() {
+		if seen[env.Name] {
+			t.Errorf("%s names two settings", env.Name)
+		}
+		seen[env.Name] = true
+	}
+	if !seen["SECMETRICS_SERVER_GITOPS_REPO"] || !seen["SECMETRICS_ENCRYPTION_KMS_REGION"] {
+		t.Error("nested settings are missing")
+	}
+}
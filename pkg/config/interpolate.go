@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+)
+
+// reference matches "${NAME}" and "${NAME:-default}" references to
+// environment variables in setting values, and "$${" escaping a literal
+// "${".
+var reference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolate replaces the references to environment variables in the
+// values of node and its children with the values lookup finds; mapping
+// keys are left alone, as are aliases, whose anchored node is interpolated
+// where it is defined. A variable
+// that is not set is read from the file named by the variable with
+// secretenv.FileSuffix. A reference to a variable that is unset or empty
+// takes its default; without a default, an unset variable is an error.
+// Plain values are resolved again after interpolation, so "${PORT}" can set
+// a number.
+func interpolate(node *yaml.Node, lookup func(string) (string, bool)) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var err error
+		value := reference.ReplaceAllStringFunc(node.Value, func(match string) string {
+			if match == "$${" {
+				return "${"
+			}
+			groups := reference.FindStringSubmatch(match)
+			name, def, hasDefault := groups[1], groups[3], groups[2] != ""
+			value, ok, lookupErr := lookupEnv(lookup, name)
+			switch {
+			case lookupErr != nil:
+				if err == nil {
+					err = lookupErr
+				}
+			case ok && value != "":
+				return value
+			case hasDefault:
+				return def
+			case !ok && err == nil:
+				err = fmt.Errorf("line %d: environment variable %s is not set", node.Line, name)
+			}
+			return value
+		})
+		if err != nil {
+			return err
+		}
+		if value != node.Value {
+			node.Value = value
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolate(node.Content[i], lookup); err != nil {
+				return err
+			}
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := interpolate(child, lookup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupEnv returns the value lookup finds for the environment variable
+// name or, when it is not set, the content of the file named by the
+// variable with secretenv.FileSuffix.
+func lookupEnv(lookup func(string) (string, bool), name string) (string, bool, error) {
+	if value, ok := lookup(name); ok {
+		return value, true, nil
+	}
+	path, ok := lookup(name + secretenv.FileSuffix)
+	if !ok {
+		return "", false, nil
+	}
+	value, err := secretenv.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("environment variable %s%s: %w", name, secretenv.FileSuffix, err)
+	}
+	return value, true, nil
+}
+
+// interpolateConfig interpolates the configuration document doc, leaving
+// out the profiles, which applyProfile interpolates when one is selected,
+// so unselected profiles may refer to variables that are not set.
+func interpolateConfig(doc *yaml.Node, lookup func(string) (string, bool)) error {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return interpolate(doc, lookup)
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "profiles" {
+			continue
+		}
+		if err := interpolate(root.Content[i+1], lookup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
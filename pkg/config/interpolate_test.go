@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const templateConfig = `
+# Set ${FLEET_HOST} per environment
+server:
+  addr: ":${PORT:-9090}"
+  rate_limit:
+    per_ip: ${RATE_LIMIT}
+  interval: ${INTERVAL:-1h}
+sources:
+  fleetdm:
+    url: https://${FLEET_HOST}/api
+    api_token: "${FLEET_TOKEN}"
+report:
+  branding:
+    footer: "Pricing in $${CURRENCY}"
+profiles:
+  staging:
+    store:
+      path: ${STAGING_STORE}
+`
+
+func TestInterpolation(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "fleet-token")
+	if err := os.WriteFile(tokenPath, []byte("t0ken\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"RATE_LIMIT":       "300",
+		"INTERVAL":         "",
+		"FLEET_HOST":       "fleet.staging.example.com",
+		"FLEET_TOKEN_FILE": tokenPath,
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	cfg, err := parse([]byte(templateConfig), lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":9090" || cfg.Server.Interval != "1h" || cfg.Server.RateLimit.PerIP != 300 {
+		t.Errorf("addr %q interval %q per_ip %d", cfg.Server.Addr, cfg.Server.Interval, cfg.Server.RateLimit.PerIP)
+	}
+	if fleet := cfg.Sources.FleetDM; fleet.URL != "https://fleet.staging.example.com/api" || fleet.APIToken != "t0ken" {
+		t.Errorf("fleetdm = %+v", fleet)
+	}
+	if footer := cfg.Report.Branding.Footer; footer != "Pricing in ${CURRENCY}" {
+		t.Errorf("footer = %q, want the escaped reference", footer)
+	}
+
+	// Profiles are interpolated only when selected
+	env[ProfileEnv] = "staging"
+	if _, err := parse([]byte(templateConfig), lookup); err == nil || !strings.Contains(err.Error(), "STAGING_STORE is not set") {
+		t.Errorf("unset variable in the selected profile: %v", err)
+	}
+	delete(env, ProfileEnv)
+
+	delete(env, "FLEET_HOST")
+	if _, err := parse([]byte(templateConfig), lookup); err == nil || !strings.Contains(err.Error(), "line 10: environment variable FLEET_HOST is not set") {
+		t.Errorf("unset variable: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileEnv names the environment variable that selects a profile, as the
+// global --profile flag does.
+const ProfileEnv = "SECMETRICS_PROFILE"
+
+// profiles are the named profiles of a configuration file, e.g. one per
+// environment or client. Each is a configuration document of its own.
+type profiles struct {
+	Profiles map[string]yaml.Node `yaml:"profiles"`
+}
+
+// applyProfile applies the named profile of the configuration document doc
+// to c, interpolating the environment variables lookup finds. Every
+// top-level section the profile sets, such as sources or store, replaces
+// the section of the file; the others are shared by all profiles. A profile
+// that shares the file's store is rejected, so profiles never mix their
+// data, and source response caches default to a directory of the profile.
+func (c *Config) applyProfile(doc *yaml.Node, name string, lookup func(string) (string, bool)) error {
+	var file profiles
+	if doc.Kind != 0 {
+		if err := doc.Decode(&file); err != nil {
+			return err
+		}
+	}
+	node, ok := file.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(file.Profiles))
+		for name := range file.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("profile %q: the configuration defines no profiles", name)
+		}
+		return fmt.Errorf("profile %q not found (%s)", name, strings.Join(names, ", "))
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("profile %s: must be a mapping of settings", name)
+	}
+	if err := interpolate(&node, lookup); err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+	profile := &Config{}
+	if err := node.Decode(profile); err != nil {
+		return fmt.Errorf("profile %s: %w", name, err)
+	}
+
+	target, source := reflect.ValueOf(c).Elem(), reflect.ValueOf(profile).Elem()
+	sets := make(map[string]bool)
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		field, ok := sectionField(key)
+		if !ok {
+			return fmt.Errorf("profile %s: unknown setting %s", name, key)
+		}
+		target.Field(field).Set(source.Field(field))
+		sets[key] = true
+	}
+	if !sets["store"] && c.Store.Path != "" {
+		return fmt.Errorf("profile %s: set store, or the profile shares the store %s", name, c.Store.Path)
+	}
+	if c.Sources.CacheDir == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			c.Sources.CacheDir = filepath.Join(dir, "secmetrics", "profiles", name)
+		}
+	}
+	c.Profile = name
+	return nil
+}
+
+// sectionField returns the index of the Config field of the top-level
+// setting key.
+func sectionField(key string) (int, bool) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag == key && tag != "-" {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const profilesConfig = `
+report:
+  locale: de-DE
+store:
+  path: /data/shared.json
+sources:
+  dmarc:
+    reports_dir: /data/dmarc
+profiles:
+  acme:
+    store:
+      path: /data/acme.json
+    sources:
+      fleetdm:
+        url: https://fleet.acme.example.com
+        api_token: acme-token
+  globex:
+    store:
+      path: /data/globex.json
+  initech:
+    server:
+      addr: ":9091"
+`
+
+func TestProfiles(t *testing.T) {
+	env := map[string]string{ProfileEnv: "acme", "SECMETRICS_SERVER_ADDR": ":8080"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	cfg, err := parse([]byte(profilesConfig), lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Profile != "acme" || cfg.Store.Path != "/data/acme.json" || cfg.Report.Locale != "de-DE" || cfg.Server.Addr != ":8080" {
+		t.Errorf("profile %q store %q locale %q addr %q", cfg.Profile, cfg.Store.Path, cfg.Report.Locale, cfg.Server.Addr)
+	}
+	// The profile's sources replace the file's
+	if cfg.Sources.FleetDM == nil || cfg.Sources.DMARC != nil {
+		t.Errorf("sources = %+v, want only the profile's", cfg.Sources)
+	}
+	if !strings.Contains(cfg.Sources.CacheDir, "acme") {
+		t.Errorf("cache dir %q is not the profile's", cfg.Sources.CacheDir)
+	}
+
+	env[ProfileEnv] = "globex"
+	if cfg, err = parse([]byte(profilesConfig), lookup); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Store.Path != "/data/globex.json" || cfg.Sources.DMARC == nil {
+		t.Errorf("store %q dmarc %+v, want the file's sources", cfg.Store.Path, cfg.Sources.DMARC)
+	}
+
+	for profile, want := range map[string]string{
+		"initech":  "shares the store",
+		"umbrella": "not found (acme, globex, initech)",
+	} {
+		env[ProfileEnv] = profile
+		if _, err := parse([]byte(profilesConfig), lookup); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("profile %s: err = %v, want %q", profile, err, want)
+		}
+	}
+
+	// Without a selected profile the file applies as is
+	delete(env, ProfileEnv)
+	if cfg, err = parse([]byte(profilesConfig), lookup); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Store.Path != "/data/shared.json" || cfg.Profile != "" {
+		t.Errorf("without a profile: profile %q store %q", cfg.Profile, cfg.Store.Path)
+	}
+}
@@ -0,0 +1,154 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AzureBlobConfig configures report archival to an Azure Blob Storage
+// container authorized with a SAS token.
+type AzureBlobConfig struct {
+	// ContainerURL is e.g. https://account.blob.core.windows.net/reports.
+	ContainerURL  string `yaml:"container_url"`
+	SASToken      string `yaml:"sas_token"`
+	Prefix        string `yaml:"prefix"`
+	RetentionDays int    `yaml:"retention_days"`
+	// EncryptionScope selects a customer-managed encryption scope.
+	EncryptionScope string `yaml:"encryption_scope"`
+}
+
+// azureAPIVersion is the Blob service REST API version used.
+const azureAPIVersion = "2021-08-06"
+
+// AzureBlobTarget archives reports to Azure Blob Storage.
+type AzureBlobTarget struct {
+	config AzureBlobConfig
+	client *http.Client
+}
+
+// NewAzureBlobTarget creates an Azure Blob Storage archival target.
+func NewAzureBlobTarget(cfg AzureBlobConfig, client *http.Client) (*AzureBlobTarget, error) {
+	if cfg.ContainerURL == "" || cfg.SASToken == "" {
+		return nil, fmt.Errorf("azure_blob: container_url and sas_token are required")
+	}
+	cfg.ContainerURL = strings.TrimRight(cfg.ContainerURL, "/")
+	cfg.SASToken = strings.TrimPrefix(cfg.SASToken, "?")
+	return &AzureBlobTarget{config: cfg, client: client}, nil
+}
+
+// Name returns the target name.
+func (t *AzureBlobTarget) Name() string {
+	return "azure_blob"
+}
+
+// Deliver uploads the report as a block blob under prefix/YYYY/MM/DD/filename.
+func (t *AzureBlobTarget) Deliver(ctx context.Context, filename string, content []byte) error {
+	blob := DatedPath(strings.Trim(t.config.Prefix, "/"), time.Now().UTC(), filename)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.blobURL(blob), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	if t.config.EncryptionScope != "" {
+		req.Header.Set("x-ms-encryption-scope", t.config.EncryptionScope)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// Prune deletes archived reports older than the configured retention.
+func (t *AzureBlobTarget) Prune(ctx context.Context, now time.Time) error {
+	if t.config.RetentionDays <= 0 {
+		return nil
+	}
+	prefix := strings.Trim(t.config.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	cutoff := now.UTC().AddDate(0, 0, -t.config.RetentionDays)
+
+	blobs, err := t.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		if !expiredKey(blob, prefix, cutoff) {
+			continue
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.blobURL(blob), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("x-ms-version", azureAPIVersion)
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if err := checkResponse(resp); err != nil {
+			return fmt.Errorf("delete %s: %w", blob, err)
+		}
+	}
+	return nil
+}
+
+// list returns all blob names under prefix.
+func (t *AzureBlobTarget) list(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.config.ContainerURL+"?"+query.Encode()+"&"+t.config.SASToken, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("x-ms-version", azureAPIVersion)
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Blobs struct {
+				Blob []struct {
+					Name string `xml:"Name"`
+				} `xml:"Blob"`
+			} `xml:"Blobs"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		err = checkResponse(resp)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list blobs: %w", err)
+		}
+		for _, blob := range result.Blobs.Blob {
+			names = append(names, blob.Name)
+		}
+		if result.NextMarker == "" {
+			return names, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// blobURL returns the SAS-authorized URL of a blob.
+func (t *AzureBlobTarget) blobURL(blob string) string {
+	return t.config.ContainerURL + "/" + escapePath(blob) + "?" + t.config.SASToken
+}
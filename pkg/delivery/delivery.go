@@ -0,0 +1,180 @@
+// Package delivery provides report delivery targets.
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/grc"
+	"github.com/hallucinaut/secmetrics/pkg/plugin"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+)
+
+// Target represents a destination that generated reports are delivered to.
+type Target interface {
+	// Name returns a short name identifying the target.
+	Name() string
+	// Deliver uploads content under the given file name.
+	Deliver(ctx context.Context, filename string, content []byte) error
+}
+
+// Pruner is implemented by archival targets that enforce retention.
+type Pruner interface {
+	// Prune removes archived reports that are past retention at now.
+	Prune(ctx context.Context, now time.Time) error
+}
+
+// Screener is implemented by targets that refuse reports of some
+// classifications.
+type Screener interface {
+	// Screen returns an error if a report with the given classification
+	// must not be delivered to the target.
+	Screen(classification reporting.Classification) error
+}
+
+// Config configures report delivery targets.
+type Config struct {
+	SharePoint  *SharePointConfig  `yaml:"sharepoint"`
+	GoogleDrive *GoogleDriveConfig `yaml:"google_drive"`
+	S3          *S3Config          `yaml:"s3"`
+	GCS         *GCSConfig         `yaml:"gcs"`
+	AzureBlob   *AzureBlobConfig   `yaml:"azure_blob"`
+	Local       *LocalConfig       `yaml:"local"`
+	Email       *EmailConfig       `yaml:"email"`
+	GRC         *grc.Config        `yaml:"grc"`
+	// Plugins are external executables speaking the plugin protocol.
+	Plugins []plugin.Config `yaml:"plugins"`
+}
+
+// NewTargets creates the delivery targets enabled in cfg. The cipher, if
+// not nil, encrypts reports written to the local archive.
+func NewTargets(cfg Config, client *http.Client, cipher *encryption.Cipher) ([]Target, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+
+	var targets []Target
+	if cfg.SharePoint != nil {
+		target, err := NewSharePointTarget(*cfg.SharePoint, client)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	if cfg.GoogleDrive != nil {
+		target, err := NewGoogleDriveTarget(*cfg.GoogleDrive, client)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	if cfg.S3 != nil {
+		target, err := NewS3Target(*cfg.S3, client)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	if cfg.GCS != nil {
+		target, err := NewGCSTarget(*cfg.GCS, client)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	if cfg.AzureBlob != nil {
+		target, err := NewAzureBlobTarget(*cfg.AzureBlob, client)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	if cfg.Local != nil {
+		targets = append(targets, NewLocalTarget(*cfg.Local, cipher))
+	}
+	if cfg.Email != nil {
+		target, err := NewEmailTarget(*cfg.Email, client)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	if cfg.GRC != nil {
+		target, err := NewGRCTarget(*cfg.GRC, client)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	for _, pluginCfg := range cfg.Plugins {
+		target, err := NewPluginTarget(pluginCfg)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// DeliverAll delivers content with the given classification to every
+// target, skipping targets that refuse it, and returns the first error after
+// attempting all of them.
+func DeliverAll(ctx context.Context, targets []Target, filename string, classification reporting.Classification, content []byte) error {
+	var firstErr error
+	for _, target := range targets {
+		err := screen(target, classification)
+		if err == nil {
+			err = target.Deliver(ctx, filename, content)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", target.Name(), err)
+		}
+	}
+	return firstErr
+}
+
+// screen returns the target's refusal of classification, if any.
+func screen(target Target, classification reporting.Classification) error {
+	if screener, ok := target.(Screener); ok {
+		return screener.Screen(classification)
+	}
+	return nil
+}
+
+// PruneAll enforces retention on every target that supports it, returning
+// the first error after attempting all of them.
+func PruneAll(ctx context.Context, targets []Target, now time.Time) error {
+	var firstErr error
+	for _, target := range targets {
+		pruner, ok := target.(Pruner)
+		if !ok {
+			continue
+		}
+		if err := pruner.Prune(ctx, now); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", target.Name(), err)
+		}
+	}
+	return firstErr
+}
+
+// DatedPath prefixes filename with a YYYY/MM/DD folder under base.
+func DatedPath(base string, t time.Time, filename string) string {
+	path := t.Format("2006/01/02") + "/" + filename
+	if base != "" {
+		path = base + "/" + path
+	}
+	return path
+}
+
+// checkResponse returns an error for non-2xx responses.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, string(body))
+}
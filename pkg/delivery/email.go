@@ -0,0 +1,215 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/httpclient"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+)
+
+// EmailConfig configures delivery of reports as email attachments over
+// SMTP.
+type EmailConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	// InternalDomains lists the organization's email domains; recipients
+	// in any other domain (or its subdomains) are external.
+	InternalDomains []string `yaml:"internal_domains"`
+	// RefuseRestrictedExternal refuses to email Restricted reports when any
+	// recipient is external.
+	RefuseRestrictedExternal bool `yaml:"refuse_restricted_external"`
+}
+
+// EmailTarget emails reports to a fixed list of recipients.
+type EmailTarget struct {
+	config EmailConfig
+	client *http.Client
+}
+
+// NewEmailTarget creates an email delivery target that connects with the
+// connection settings of client: its connect and request timeouts, proxy
+// and TLS configuration.
+func NewEmailTarget(cfg EmailConfig, client *http.Client) (*EmailTarget, error) {
+	if cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email: host, from and to are required")
+	}
+	if cfg.RefuseRestrictedExternal && len(cfg.InternalDomains) == 0 {
+		return nil, fmt.Errorf("email: refuse_restricted_external requires internal_domains")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &EmailTarget{config: cfg, client: client}, nil
+}
+
+// Name returns the target name.
+func (t *EmailTarget) Name() string {
+	return "email"
+}
+
+// Screen refuses Restricted reports when refuse_restricted_external is set
+// and any recipient is outside the internal domains.
+func (t *EmailTarget) Screen(classification reporting.Classification) error {
+	if !t.config.RefuseRestrictedExternal || classification != reporting.ClassificationRestricted {
+		return nil
+	}
+	var external []string
+	for _, to := range t.config.To {
+		if !t.internal(to) {
+			external = append(external, to)
+		}
+	}
+	if len(external) > 0 {
+		return fmt.Errorf("refusing to email a Restricted report to external recipients: %s", strings.Join(external, ", "))
+	}
+	return nil
+}
+
+// internal reports whether address, with or without a display name, is in
+// an internal domain. Addresses that do not parse are external.
+func (t *EmailTarget) internal(address string) bool {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return false
+	}
+	at := strings.LastIndex(parsed.Address, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(strings.TrimSuffix(parsed.Address[at+1:], "."))
+	for _, internal := range t.config.InternalDomains {
+		internal = strings.ToLower(internal)
+		if domain == internal || strings.HasSuffix(domain, "."+internal) {
+			return true
+		}
+	}
+	return false
+}
+
+// Deliver emails the report as an attachment. The session upgrades to TLS
+// when the server offers STARTTLS, and ends when ctx is done or after the
+// client's request timeout.
+func (t *EmailTarget) Deliver(ctx context.Context, filename string, content []byte) error {
+	addr := net.JoinHostPort(t.config.Host, strconv.Itoa(t.config.Port))
+	conn, err := httpclient.DialContext(ctx, t.client, addr)
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	defer conn.Close()
+	if t.client.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(t.client.Timeout))
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := t.send(conn, t.message(filename, content)); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("email: %w", ctx.Err())
+		}
+		return fmt.Errorf("email: %w", err)
+	}
+	return nil
+}
+
+// send runs the SMTP session delivering msg on conn.
+func (t *EmailTarget) send(conn net.Conn, msg []byte) error {
+	c, err := smtp.NewClient(conn, t.config.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(httpclient.TLSConfig(t.client, t.config.Host)); err != nil {
+			return err
+		}
+	}
+	if t.config.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("server %s does not support authentication", t.config.Host)
+		}
+		if err := c.Auth(smtp.PlainAuth("", t.config.Username, t.config.Password, t.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(envelopeAddress(t.config.From)); err != nil {
+		return err
+	}
+	for _, to := range t.config.To {
+		if err := c.Rcpt(envelopeAddress(to)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// envelopeAddress returns the bare address of address, which may carry a
+// display name, for the SMTP envelope.
+func envelopeAddress(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		return parsed.Address
+	}
+	return address
+}
+
+// message builds a MIME message with content attached as filename.
+func (t *EmailTarget) message(filename string, content []byte) []byte {
+	const boundary = "secmetrics-report-boundary"
+	contentType := mime.TypeByExtension(path.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", t.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(t.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Security metrics report "+filename))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&msg, "--%s\r\n", boundary)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "The security metrics report %s is attached.\r\n\r\n", filename)
+
+	fmt.Fprintf(&msg, "--%s\r\n", boundary)
+	fmt.Fprintf(&msg, "Content-Type: %s\r\n", contentType)
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n")
+	fmt.Fprintf(&msg, "Content-Disposition: attachment; filename=%q\r\n\r\n", filename)
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+	return msg.Bytes()
+}
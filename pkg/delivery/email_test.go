@@ -0,0 +1,241 @@
+package delivery
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/httpclient"
+	"github.com/hallucinaut/secmetrics/pkg/reporting"
+)
+
+func TestEmailScreen(t *testing.T) {
+	tests := []struct {
+		name           string
+		to             []string
+		classification reporting.Classification
+		refused        string
+	}{
+		{"internal recipient", []string{"ciso@corp.com"}, reporting.ClassificationRestricted, ""},
+		{"internal domain in capitals", []string{"CISO@Corp.COM"}, reporting.ClassificationRestricted, ""},
+		{"subdomain", []string{"soc@eu.corp.com"}, reporting.ClassificationRestricted, ""},
+		{"display name", []string{"Chief Security Officer <ciso@corp.com>"}, reporting.ClassificationRestricted, ""},
+		{"external recipient", []string{"ciso@corp.com", "auditor@audit.example"}, reporting.ClassificationRestricted, "auditor@audit.example"},
+		{"lookalike domain", []string{"ciso@corp.com.evil.com"}, reporting.ClassificationRestricted, "ciso@corp.com.evil.com"},
+		{"lookalike suffix", []string{"ciso@evilcorp.com"}, reporting.ClassificationRestricted, "ciso@evilcorp.com"},
+		{"internal address as display name", []string{`"ciso@corp.com" <mallory@evil.com>`}, reporting.ClassificationRestricted, "mallory@evil.com"},
+		{"unparseable address", []string{"ciso at corp.com"}, reporting.ClassificationRestricted, "ciso at corp.com"},
+		{"confidential report", []string{"auditor@audit.example"}, reporting.ClassificationConfidential, ""},
+		{"unclassified report", []string{"auditor@audit.example"}, "", ""},
+	}
+	for _, tc := range tests {
+		target, err := NewEmailTarget(EmailConfig{
+			Host:                     "smtp.corp.com",
+			From:                     "secmetrics@corp.com",
+			To:                       tc.to,
+			InternalDomains:          []string{"corp.com"},
+			RefuseRestrictedExternal: true,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = target.Screen(tc.classification)
+		switch {
+		case tc.refused == "" && err != nil:
+			t.Errorf("%s: refused: %v", tc.name, err)
+		case tc.refused != "" && (err == nil || !strings.Contains(err.Error(), tc.refused)):
+			t.Errorf("%s: error %v, want a refusal naming %s", tc.name, err, tc.refused)
+		}
+	}
+}
+
+func TestEmailScreenWithoutRefusal(t *testing.T) {
+	target, err := NewEmailTarget(EmailConfig{
+		Host:            "smtp.corp.com",
+		From:            "secmetrics@corp.com",
+		To:              []string{"auditor@audit.example"},
+		InternalDomains: []string{"corp.com"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Screen(reporting.ClassificationRestricted); err != nil {
+		t.Errorf("refused without refuse_restricted_external: %v", err)
+	}
+	if _, err := NewEmailTarget(EmailConfig{Host: "smtp.corp.com", From: "secmetrics@corp.com", To: []string{"ciso@corp.com"}, RefuseRestrictedExternal: true}, nil); err == nil {
+		t.Error("refuse_restricted_external accepted without internal_domains")
+	}
+}
+
+// smtpServer is a minimal SMTP server accepting one session. With a TLS
+// certificate it offers STARTTLS and requires it before MAIL.
+type smtpServer struct {
+	listener net.Listener
+	tls      *tls.Config
+	// received is the transcript of the session's commands and message.
+	received chan string
+}
+
+func newSMTPServer(t *testing.T, config *tls.Config) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	s := &smtpServer{listener: listener, tls: config, received: make(chan string, 1)}
+	go s.serve()
+	return s
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var transcript strings.Builder
+	defer func() { s.received <- transcript.String() }()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 smtp.corp.com ready")
+	secure := false
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, _, _ := strings.Cut(line, " ")
+		transcript.WriteString(line + "\n")
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			if s.tls != nil && !secure {
+				text.PrintfLine("250-smtp.corp.com\r\n250 STARTTLS")
+			} else {
+				text.PrintfLine("250 smtp.corp.com")
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 go ahead")
+			tlsConn := tls.Server(conn, s.tls)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, text, secure = tlsConn, textproto.NewConn(tlsConn), true
+		case "MAIL":
+			if s.tls != nil && !secure {
+				text.PrintfLine("530 must issue STARTTLS first")
+				continue
+			}
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			msg, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			transcript.Write(msg)
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestEmailDeliverUpgradesToTLS(t *testing.T) {
+	// The certificate of an httptest TLS server is valid for 127.0.0.1
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	config := https.TLS.Clone()
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: https.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	https.Close()
+
+	server := newSMTPServer(t, config)
+	client, err := httpclient.New(httpclient.Config{CABundle: bundle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := NewEmailTarget(EmailConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "secmetrics@corp.com",
+		To:   []string{"Chief Security Officer <ciso@corp.com>"},
+	}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Deliver(context.Background(), "q3.md", []byte("# Q3 report")); err != nil {
+		t.Fatal(err)
+	}
+	transcript := <-server.received
+	for _, want := range []string{"STARTTLS\n", "MAIL FROM:<secmetrics@corp.com>", "RCPT TO:<ciso@corp.com>", `filename="q3.md"`} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("session does not contain %q:\n%s", want, transcript)
+		}
+	}
+}
+
+func TestEmailDeliverRejectsUntrustedCertificates(t *testing.T) {
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	config := https.TLS.Clone()
+	https.Close()
+
+	// Without the CA bundle the server's certificate is not trusted
+	server := newSMTPServer(t, config)
+	target, err := NewEmailTarget(EmailConfig{Host: "127.0.0.1", Port: server.port(), From: "secmetrics@corp.com", To: []string{"ciso@corp.com"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Deliver(context.Background(), "q3.md", []byte("# Q3 report")); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("delivered to an untrusted server: %v", err)
+	}
+}
+
+func TestEmailDeliverStopsWithTheContext(t *testing.T) {
+	// A server that accepts the connection but never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Minute)
+		}
+	}()
+
+	target, err := NewEmailTarget(EmailConfig{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, From: "secmetrics@corp.com", To: []string{"ciso@corp.com"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := target.Deliver(ctx, "q3.md", []byte("# Q3 report")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deliver to a silent server: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("deliver stopped after %s", elapsed)
+	}
+}
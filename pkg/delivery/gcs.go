@@ -0,0 +1,44 @@
+package delivery
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// GCSConfig configures report archival to Google Cloud Storage through its
+// S3-interoperable XML API using HMAC keys.
+type GCSConfig struct {
+	Bucket        string `yaml:"bucket"`
+	AccessKeyID   string `yaml:"access_key_id"`
+	Secret        string `yaml:"secret"`
+	Prefix        string `yaml:"prefix"`
+	RetentionDays int    `yaml:"retention_days"`
+	// KMSKeyName encrypts objects with a customer-managed Cloud KMS key.
+	KMSKeyName string `yaml:"kms_key_name"`
+}
+
+// NewGCSTarget creates a Google Cloud Storage archival target.
+func NewGCSTarget(cfg GCSConfig, client *http.Client) (*S3Target, error) {
+	if cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.Secret == "" {
+		return nil, fmt.Errorf("gcs: bucket, access_key_id and secret are required")
+	}
+
+	target, err := NewS3Target(S3Config{
+		Bucket:          cfg.Bucket,
+		Region:          "auto",
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.Secret,
+		Endpoint:        "https://storage.googleapis.com",
+		PathStyle:       true,
+		Prefix:          cfg.Prefix,
+		RetentionDays:   cfg.RetentionDays,
+	}, client)
+	if err != nil {
+		return nil, err
+	}
+	target.name = "gcs"
+	if cfg.KMSKeyName != "" {
+		target.headers["x-goog-encryption-kms-key-name"] = cfg.KMSKeyName
+	}
+	return target, nil
+}
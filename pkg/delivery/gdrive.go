@@ -0,0 +1,109 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/oauth"
+)
+
+// GoogleDriveConfig configures delivery to a Google Drive folder using an
+// OAuth2 refresh token.
+type GoogleDriveConfig struct {
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	RefreshToken string `yaml:"refresh_token"`
+	// FolderID is the Drive folder reports are uploaded into.
+	FolderID string `yaml:"folder_id"`
+}
+
+// Google endpoints, overridable for tests.
+var (
+	googleTokenURL  = "https://oauth2.googleapis.com/token"
+	googleUploadURL = "https://www.googleapis.com/upload/drive/v3/files?uploadType=multipart&supportsAllDrives=true"
+)
+
+// GoogleDriveTarget uploads reports to Google Drive.
+type GoogleDriveTarget struct {
+	config GoogleDriveConfig
+	client *http.Client
+	tokens *oauth.TokenSource
+}
+
+// NewGoogleDriveTarget creates a Google Drive delivery target.
+func NewGoogleDriveTarget(cfg GoogleDriveConfig, client *http.Client) (*GoogleDriveTarget, error) {
+	if cfg.ClientID == "" || cfg.ClientSecret == "" || cfg.RefreshToken == "" {
+		return nil, fmt.Errorf("google_drive: client_id, client_secret and refresh_token are required")
+	}
+	if cfg.FolderID == "" {
+		return nil, fmt.Errorf("google_drive: folder_id is required")
+	}
+
+	return &GoogleDriveTarget{
+		config: cfg,
+		client: client,
+		tokens: oauth.NewTokenSource(client, googleTokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {cfg.ClientID},
+			"client_secret": {cfg.ClientSecret},
+			"refresh_token": {cfg.RefreshToken},
+		}),
+	}, nil
+}
+
+// Name returns the target name.
+func (t *GoogleDriveTarget) Name() string {
+	return "google_drive"
+}
+
+// Deliver uploads the report into the configured folder. Drive has no
+// nested paths, so the date is prefixed to the file name instead.
+func (t *GoogleDriveTarget) Deliver(ctx context.Context, filename string, content []byte) error {
+	token, err := t.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := json.Marshal(map[string]interface{}{
+		"name":    time.Now().Format("2006-01-02") + "-" + filename,
+		"parents": []string{t.config.FolderID},
+	})
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=UTF-8"}})
+	if err != nil {
+		return err
+	}
+	part.Write(metadata)
+	part, err = writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return err
+	}
+	part.Write(content)
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleUploadURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "multipart/related; boundary="+writer.Boundary())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
@@ -0,0 +1,45 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hallucinaut/secmetrics/pkg/grc"
+)
+
+// GRCTarget uploads reports to Vanta or Drata as evidence, so the KPI
+// values they carry back the platform's controls.
+type GRCTarget struct {
+	platforms []grc.Platform
+}
+
+// NewGRCTarget creates a GRC platform delivery target.
+func NewGRCTarget(cfg grc.Config, client *http.Client) (*GRCTarget, error) {
+	if cfg.Vanta != nil && cfg.Vanta.DocumentID == "" {
+		return nil, fmt.Errorf("grc: vanta document_id is required for delivery")
+	}
+	if cfg.Drata != nil && len(cfg.Drata.ControlIDs) == 0 {
+		return nil, fmt.Errorf("grc: drata control_ids are required for delivery")
+	}
+	platforms, err := grc.New(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	return &GRCTarget{platforms: platforms}, nil
+}
+
+// Name returns the target name.
+func (t *GRCTarget) Name() string {
+	return "grc"
+}
+
+// Deliver uploads the report as evidence to every configured platform.
+func (t *GRCTarget) Deliver(ctx context.Context, filename string, content []byte) error {
+	for _, platform := range t.platforms {
+		if err := platform.UploadEvidence(ctx, filename, content); err != nil {
+			return fmt.Errorf("%s: %w", platform.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+)
+
+// LocalConfig configures the local report archive directory.
+type LocalConfig struct {
+	Path          string `yaml:"path"`
+	RetentionDays int    `yaml:"retention_days"`
+}
+
+// LocalTarget archives reports to a local directory, encrypting them when
+// a cipher is configured.
+type LocalTarget struct {
+	config LocalConfig
+	cipher *encryption.Cipher
+}
+
+// NewLocalTarget creates a local archive target.
+func NewLocalTarget(cfg LocalConfig, cipher *encryption.Cipher) *LocalTarget {
+	if cfg.Path == "" {
+		cfg.Path = "reports"
+	}
+	return &LocalTarget{config: cfg, cipher: cipher}
+}
+
+// Name returns the target name.
+func (t *LocalTarget) Name() string {
+	return "local"
+}
+
+// Deliver writes the report to path/YYYY/MM/DD/filename. Encrypted reports
+// get an ".enc" suffix.
+func (t *LocalTarget) Deliver(ctx context.Context, filename string, content []byte) error {
+	path := filepath.Join(t.config.Path, filepath.FromSlash(DatedPath("", time.Now(), filename)))
+	if t.cipher != nil {
+		encrypted, err := t.cipher.Encrypt(content)
+		if err != nil {
+			return err
+		}
+		content = encrypted
+		path += ".enc"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o600)
+}
+
+// Prune deletes archived reports older than the configured retention.
+func (t *LocalTarget) Prune(ctx context.Context, now time.Time) error {
+	if t.config.RetentionDays <= 0 {
+		return nil
+	}
+	cutoff := now.AddDate(0, 0, -t.config.RetentionDays)
+	root := filepath.Clean(t.config.Path)
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if expiredKey(filepath.ToSlash(rel), "", cutoff) {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// ReadArchived reads an archived report, decrypting it if needed.
+func ReadArchived(path string, cipher *encryption.Cipher) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".enc") && !encryption.IsEncrypted(data) {
+		return data, nil
+	}
+	if cipher == nil {
+		return nil, fmt.Errorf("%s is encrypted but no encryption key is configured", path)
+	}
+	return cipher.Decrypt(data)
+}
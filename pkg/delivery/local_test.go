@@ -0,0 +1,66 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+)
+
+func TestLocalArchiveEncrypts(t *testing.T) {
+	cipher, err := encryption.NewCipher(bytes.Repeat([]byte{3}, encryption.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := NewLocalTarget(LocalConfig{Path: dir}, cipher).Deliver(context.Background(), "q3.md", []byte("# Q3 report")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, filepath.FromSlash(DatedPath("", time.Now(), "q3.md.enc")))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !encryption.IsEncrypted(data) || bytes.Contains(data, []byte("Q3 report")) {
+		t.Errorf("archived plaintext: %q", data)
+	}
+	if content, err := ReadArchived(path, cipher); err != nil || string(content) != "# Q3 report" {
+		t.Errorf("ReadArchived = %q, %v", content, err)
+	}
+	if _, err := ReadArchived(path, nil); err == nil {
+		t.Error("read an encrypted report without a key")
+	}
+
+	plain := t.TempDir()
+	if err := NewLocalTarget(LocalConfig{Path: plain}, nil).Deliver(context.Background(), "q3.md", []byte("# Q3 report")); err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(plain, filepath.FromSlash(DatedPath("", time.Now(), "q3.md")))
+	if content, err := ReadArchived(path, nil); err != nil || string(content) != "# Q3 report" {
+		t.Errorf("plaintext ReadArchived = %q, %v", content, err)
+	}
+}
+
+func TestLocalPrune(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2026/01/15/old.md", "2026/09/30/new.md", "notes.txt"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o700)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := NewLocalTarget(LocalConfig{Path: dir, RetentionDays: 90}, nil)
+	if err := target.Prune(context.Background(), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"2026/01/15/old.md": false, "2026/09/30/new.md": true, "notes.txt": true} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); (err == nil) != want {
+			t.Errorf("%s kept = %v, want %v", name, err == nil, want)
+		}
+	}
+}
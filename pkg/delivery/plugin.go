@@ -0,0 +1,36 @@
+package delivery
+
+import (
+	"context"
+
+	"github.com/hallucinaut/secmetrics/pkg/plugin"
+)
+
+// PluginTarget hands reports to an external plugin executable, e.g. to
+// post them to a chat or ticketing system.
+type PluginTarget struct {
+	plugin *plugin.Plugin
+}
+
+// NewPluginTarget creates a delivery target backed by a plugin.
+func NewPluginTarget(cfg plugin.Config) (*PluginTarget, error) {
+	p, err := plugin.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &PluginTarget{plugin: p}, nil
+}
+
+// Name returns the target name.
+func (t *PluginTarget) Name() string {
+	return "plugin:" + t.plugin.Name()
+}
+
+// Deliver sends the report to the plugin.
+func (t *PluginTarget) Deliver(ctx context.Context, filename string, content []byte) error {
+	return t.plugin.Call(ctx, plugin.Request{
+		Action:   plugin.ActionDeliver,
+		Filename: filename,
+		Content:  content,
+	}, nil)
+}
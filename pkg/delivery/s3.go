@@ -0,0 +1,207 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/awsv4"
+)
+
+// S3Config configures report archival to an S3 bucket or any
+// S3-compatible object store.
+type S3Config struct {
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	SessionToken    string `yaml:"session_token"`
+	// Endpoint overrides the AWS endpoint for S3-compatible stores.
+	Endpoint string `yaml:"endpoint"`
+	// PathStyle addresses the bucket in the path rather than the host.
+	PathStyle bool `yaml:"path_style"`
+	// Prefix is prepended to the dated object key.
+	Prefix string `yaml:"prefix"`
+	// RetentionDays prunes archived reports older than this; 0 keeps all.
+	RetentionDays int `yaml:"retention_days"`
+	// ServerSideEncryption is "AES256" or "aws:kms".
+	ServerSideEncryption string `yaml:"server_side_encryption"`
+	KMSKeyID             string `yaml:"kms_key_id"`
+}
+
+// S3Target archives reports to S3.
+type S3Target struct {
+	name    string
+	config  S3Config
+	client  *http.Client
+	headers map[string]string
+}
+
+// NewS3Target creates an S3 archival target.
+func NewS3Target(cfg S3Config, client *http.Client) (*S3Target, error) {
+	if cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("s3: bucket, access_key_id and secret_access_key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+
+	headers := make(map[string]string)
+	switch cfg.ServerSideEncryption {
+	case "":
+	case "AES256":
+		headers["x-amz-server-side-encryption"] = "AES256"
+	case "aws:kms":
+		headers["x-amz-server-side-encryption"] = "aws:kms"
+		if cfg.KMSKeyID != "" {
+			headers["x-amz-server-side-encryption-aws-kms-key-id"] = cfg.KMSKeyID
+		}
+	default:
+		return nil, fmt.Errorf("s3: unknown server_side_encryption %q", cfg.ServerSideEncryption)
+	}
+
+	return &S3Target{name: "s3", config: cfg, client: client, headers: headers}, nil
+}
+
+// Name returns the target name.
+func (t *S3Target) Name() string {
+	return t.name
+}
+
+// Deliver uploads the report under prefix/YYYY/MM/DD/filename.
+func (t *S3Target) Deliver(ctx context.Context, filename string, content []byte) error {
+	key := DatedPath(strings.Trim(t.config.Prefix, "/"), time.Now().UTC(), filename)
+	resp, err := t.do(ctx, http.MethodPut, key, nil, content, t.headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// Prune deletes archived reports older than the configured retention.
+func (t *S3Target) Prune(ctx context.Context, now time.Time) error {
+	if t.config.RetentionDays <= 0 {
+		return nil
+	}
+	prefix := strings.Trim(t.config.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	cutoff := now.UTC().AddDate(0, 0, -t.config.RetentionDays)
+
+	keys, err := t.list(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !expiredKey(key, prefix, cutoff) {
+			continue
+		}
+		resp, err := t.do(ctx, http.MethodDelete, key, nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if err := checkResponse(resp); err != nil {
+			return fmt.Errorf("delete %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// list returns all object keys under prefix.
+func (t *S3Target) list(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := t.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = checkResponse(resp)
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("list objects: %w", err)
+		}
+		for _, obj := range result.Contents {
+			keys = append(keys, obj.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a SigV4-signed request for key.
+func (t *S3Target) do(ctx context.Context, method, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	endpoint, err := url.Parse(t.config.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("s3: invalid endpoint: %w", err)
+	}
+
+	path := "/" + key
+	if t.config.PathStyle {
+		path = "/" + t.config.Bucket + path
+	} else {
+		endpoint.Host = t.config.Bucket + "." + endpoint.Host
+	}
+	// RawPath carries the SigV4 escaping, which the signature covers
+	endpoint.Path = path
+	endpoint.RawPath = awsv4.EscapePath(path)
+	endpoint.RawQuery = awsv4.CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	creds := awsv4.Credentials{
+		AccessKeyID:     t.config.AccessKeyID,
+		SecretAccessKey: t.config.SecretAccessKey,
+		SessionToken:    t.config.SessionToken,
+	}
+	awsv4.Sign(req, body, creds, t.config.Region, "s3", time.Now())
+
+	return t.client.Do(req)
+}
+
+// expiredKey reports whether a key laid out as prefix/YYYY/MM/DD/name was
+// archived before cutoff. Keys not matching the layout are never expired.
+func expiredKey(key, prefix string, cutoff time.Time) bool {
+	rest := strings.TrimPrefix(key, prefix)
+	parts := strings.SplitN(rest, "/", 4)
+	if len(parts) < 4 {
+		return false
+	}
+	archived, err := time.Parse("2006/01/02", strings.Join(parts[:3], "/"))
+	if err != nil {
+		return false
+	}
+	return archived.Before(cutoff.Truncate(24 * time.Hour))
+}
@@ -0,0 +1,198 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// objectStore is a fake S3 and Azure Blob endpoint recording requests.
+type objectStore struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	keys     []string
+	status   int
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		w.Write([]byte("<Error><Code>AccessDenied</Code></Error>"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		return
+	}
+	if r.URL.Query().Get("comp") == "list" {
+		fmt.Fprint(w, "<EnumerationResults><Blobs>")
+		for _, key := range s.keys {
+			fmt.Fprintf(w, "<Blob><Name>%s</Name></Blob>", key)
+		}
+		fmt.Fprint(w, "</Blobs><NextMarker/></EnumerationResults>")
+		return
+	}
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, key := range s.keys {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+	}
+	fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+}
+
+// calls returns the method and escaped path of each request.
+func (s *objectStore) calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []string
+	for _, r := range s.requests {
+		calls = append(calls, r.Method+" "+r.URL.EscapedPath())
+	}
+	return calls
+}
+
+func TestS3Deliver(t *testing.T) {
+	store := &objectStore{}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+	target, err := NewS3Target(S3Config{
+		Bucket: "reports", Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret",
+		Endpoint: srv.URL, PathStyle: true, Prefix: "/secmetrics/", ServerSideEncryption: "aws:kms", KMSKeyID: "key-1",
+	}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Now().UTC().Format("2006/01/02")
+	if err := target.Deliver(context.Background(), "q3 report.md", []byte("report")); err != nil {
+		t.Fatal(err)
+	}
+	if calls := store.calls(); len(calls) != 1 || calls[0] != "PUT /reports/secmetrics/"+day+"/q3%20report.md" {
+		t.Errorf("calls = %q", calls)
+	}
+	req := store.requests[0]
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") ||
+		!strings.Contains(auth, "x-amz-server-side-encryption;x-amz-server-side-encryption-aws-kms-key-id") {
+		t.Errorf("Authorization = %s", auth)
+	}
+	if req.Header.Get("x-amz-server-side-encryption-aws-kms-key-id") != "key-1" || store.bodies[0] != "report" {
+		t.Errorf("headers %v, body %q", req.Header, store.bodies[0])
+	}
+
+	store.status = http.StatusForbidden
+	if err := target.Deliver(context.Background(), "q3.md", nil); err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("denied upload: %v", err)
+	}
+}
+
+func TestS3VirtualHostedURL(t *testing.T) {
+	var hosts []string
+	client := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		hosts = append(hosts, r.URL.Host+r.URL.EscapedPath())
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	target, err := NewS3Target(S3Config{Bucket: "reports", AccessKeyID: "AKID", SecretAccessKey: "secret"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Deliver(context.Background(), "q3.md", nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "reports.s3.us-east-1.amazonaws.com/" + time.Now().UTC().Format("2006/01/02") + "/q3.md"; len(hosts) != 1 || hosts[0] != want {
+		t.Errorf("requested %q, want %s", hosts, want)
+	}
+	if _, err := NewS3Target(S3Config{Bucket: "reports", AccessKeyID: "AKID", SecretAccessKey: "secret", ServerSideEncryption: "rot13"}, client); err == nil {
+		t.Error("accepted an unknown server-side encryption")
+	}
+}
+
+// roundTripper adapts a function to http.RoundTripper.
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestS3Prune(t *testing.T) {
+	store := &objectStore{keys: []string{"secmetrics/2026/01/15/old.md", "secmetrics/2026/09/30/new.md", "secmetrics/notes.txt"}}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+	target, err := NewS3Target(S3Config{Bucket: "reports", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: srv.URL, PathStyle: true, Prefix: "secmetrics", RetentionDays: 90}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Prune(context.Background(), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	calls := store.calls()
+	if len(calls) != 2 || calls[0] != "GET /reports/" || calls[1] != "DELETE /reports/secmetrics/2026/01/15/old.md" {
+		t.Errorf("calls = %q", calls)
+	}
+	if query := store.requests[0].URL.RawQuery; query != "list-type=2&prefix=secmetrics%2F" {
+		t.Errorf("list query %s", query)
+	}
+}
+
+func TestGCSDeliver(t *testing.T) {
+	var requests []*http.Request
+	client := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	target, err := NewGCSTarget(GCSConfig{Bucket: "reports", AccessKeyID: "GOOG1", Secret: "secret", KMSKeyName: "projects/p/keys/k"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Name() != "gcs" {
+		t.Errorf("name %s", target.Name())
+	}
+	if err := target.Deliver(context.Background(), "q3.md", nil); err != nil {
+		t.Fatal(err)
+	}
+	r := requests[0]
+	if r.URL.Host != "storage.googleapis.com" || !strings.HasPrefix(r.URL.Path, "/reports/") ||
+		r.Header.Get("x-goog-encryption-kms-key-name") != "projects/p/keys/k" || !strings.Contains(r.Header.Get("Authorization"), "/auto/s3/aws4_request") {
+		t.Errorf("request %s %v", r.URL, r.Header)
+	}
+}
+
+func TestAzureBlob(t *testing.T) {
+	store := &objectStore{keys: []string{"secmetrics/2026/01/15/old.md", "secmetrics/2026/09/30/new.md"}}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+	target, err := NewAzureBlobTarget(AzureBlobConfig{ContainerURL: srv.URL + "/reports/", SASToken: "?sv=2021&sig=abc", Prefix: "secmetrics", RetentionDays: 90, EncryptionScope: "cmk"}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Now().UTC().Format("2006/01/02")
+	if err := target.Deliver(context.Background(), "q3 report.md", []byte("report")); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Prune(context.Background(), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"PUT /reports/secmetrics/" + day + "/q3%20report.md", "GET /reports", "DELETE /reports/secmetrics/2026/01/15/old.md"}
+	if calls := store.calls(); strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	put := store.requests[0]
+	if put.URL.RawQuery != "sv=2021&sig=abc" || put.Header.Get("x-ms-blob-type") != "BlockBlob" || put.Header.Get("x-ms-encryption-scope") != "cmk" {
+		t.Errorf("upload %s %v", put.URL, put.Header)
+	}
+	if list := store.requests[1].URL.Query(); list.Get("sig") != "abc" || list.Get("prefix") != "secmetrics/" {
+		t.Errorf("list query %v", list)
+	}
+
+	store.status = http.StatusForbidden
+	if err := target.Deliver(context.Background(), "q3.md", nil); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("denied upload: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/oauth"
+)
+
+// SharePointConfig configures delivery to a SharePoint or OneDrive
+// document library through Microsoft Graph using client credentials.
+type SharePointConfig struct {
+	TenantID     string `yaml:"tenant_id"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// DriveID identifies the document library or OneDrive.
+	DriveID string `yaml:"drive_id"`
+	// Folder is the folder path reports are archived under.
+	Folder string `yaml:"folder"`
+}
+
+// Microsoft endpoints, overridable for sovereign clouds and tests.
+var (
+	microsoftLoginURL = "https://login.microsoftonline.com"
+	microsoftGraphURL = "https://graph.microsoft.com/v1.0"
+)
+
+// SharePointTarget uploads reports to SharePoint or OneDrive.
+type SharePointTarget struct {
+	config SharePointConfig
+	client *http.Client
+	tokens *oauth.TokenSource
+}
+
+// NewSharePointTarget creates a SharePoint/OneDrive delivery target.
+func NewSharePointTarget(cfg SharePointConfig, client *http.Client) (*SharePointTarget, error) {
+	if cfg.TenantID == "" || cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, fmt.Errorf("sharepoint: tenant_id, client_id and client_secret are required")
+	}
+	if cfg.DriveID == "" {
+		return nil, fmt.Errorf("sharepoint: drive_id is required")
+	}
+
+	return &SharePointTarget{
+		config: cfg,
+		client: client,
+		tokens: oauth.NewTokenSource(client, microsoftLoginURL+"/"+url.PathEscape(cfg.TenantID)+"/oauth2/v2.0/token", url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {cfg.ClientID},
+			"client_secret": {cfg.ClientSecret},
+			"scope":         {"https://graph.microsoft.com/.default"},
+		}),
+	}, nil
+}
+
+// Name returns the target name.
+func (t *SharePointTarget) Name() string {
+	return "sharepoint"
+}
+
+// Deliver uploads the report into a dated folder of the configured drive.
+func (t *SharePointTarget) Deliver(ctx context.Context, filename string, content []byte) error {
+	token, err := t.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	path := DatedPath(strings.Trim(t.config.Folder, "/"), time.Now(), filename)
+	endpoint := microsoftGraphURL + "/drives/" + url.PathEscape(t.config.DriveID) + "/root:/" + escapePath(path) + ":/content"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// escapePath escapes each segment of a slash-separated path.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,136 @@
+package delivery
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDatedPathAndEscaping(t *testing.T) {
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	if got := DatedPath("Security/Reports", at, "q3.md"); got != "Security/Reports/2026/10/01/q3.md" {
+		t.Errorf("DatedPath = %s", got)
+	}
+	if got := DatedPath("", at, "q3.md"); got != "2026/10/01/q3.md" {
+		t.Errorf("DatedPath without base = %s", got)
+	}
+	if got := escapePath("Security Reports/2026/10/01/q3 #1?.md"); got != "Security%20Reports/2026/10/01/q3%20%231%3F.md" {
+		t.Errorf("escapePath = %s", got)
+	}
+}
+
+func TestSharePointDeliver(t *testing.T) {
+	var tokens int
+	var uploads []string
+	var uploadStatus = http.StatusCreated
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tenant-1/oauth2/v2.0/token":
+			tokens++
+			r.ParseForm()
+			if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_id") != "app" {
+				t.Errorf("token form %v", r.PostForm)
+			}
+			w.Write([]byte(`{"access_token":"graph-token","expires_in":3600}`))
+		case strings.HasPrefix(r.URL.Path, "/drives/"):
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer graph-token" || string(body) != "report" {
+				t.Errorf("upload %s with %q: %q", r.Method, r.Header.Get("Authorization"), body)
+			}
+			uploads = append(uploads, r.URL.EscapedPath())
+			w.WriteHeader(uploadStatus)
+			w.Write([]byte(`{"error":{"code":"accessDenied"}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	defer func(login, graph string) { microsoftLoginURL, microsoftGraphURL = login, graph }(microsoftLoginURL, microsoftGraphURL)
+	microsoftLoginURL, microsoftGraphURL = srv.URL, srv.URL
+
+	target, err := NewSharePointTarget(SharePointConfig{TenantID: "tenant-1", ClientID: "app", ClientSecret: "secret", DriveID: "b!drive", Folder: "/Security Reports/"}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Now().Format("2006/01/02")
+	if err := target.Deliver(context.Background(), "q3 #1.md", []byte("report")); err != nil {
+		t.Fatal(err)
+	}
+	want := "/drives/b%21drive/root:/Security%20Reports/" + day + "/q3%20%231.md:/content"
+	if len(uploads) != 1 || uploads[0] != want {
+		t.Errorf("uploaded to %q, want %q", uploads, want)
+	}
+
+	uploadStatus = http.StatusForbidden
+	err = target.Deliver(context.Background(), "q3.md", []byte("report"))
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "accessDenied") {
+		t.Errorf("forbidden upload: %v", err)
+	}
+	if tokens != 1 {
+		t.Errorf("%d token requests, want the token reused", tokens)
+	}
+}
+
+func TestGoogleDriveDeliver(t *testing.T) {
+	var uploadStatus = http.StatusOK
+	var names []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "1//refresh" {
+				t.Errorf("token form %v", r.PostForm)
+			}
+			w.Write([]byte(`{"access_token":"drive-token","expires_in":3600}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer drive-token" || r.URL.Query().Get("uploadType") != "multipart" {
+			t.Errorf("upload %s with %q", r.URL, r.Header.Get("Authorization"))
+		}
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := multipart.NewReader(r.Body, params["boundary"])
+		metadata, err := parts.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(metadata)
+		names = append(names, string(data))
+		content, err := parts.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := io.ReadAll(content); string(data) != "report" {
+			t.Errorf("uploaded %q", data)
+		}
+		w.WriteHeader(uploadStatus)
+	}))
+	defer srv.Close()
+	defer func(token, upload string) { googleTokenURL, googleUploadURL = token, upload }(googleTokenURL, googleUploadURL)
+	googleTokenURL, googleUploadURL = srv.URL+"/token", srv.URL+"/upload?uploadType=multipart"
+
+	target, err := NewGoogleDriveTarget(GoogleDriveConfig{ClientID: "app", ClientSecret: "secret", RefreshToken: "1//refresh", FolderID: "folder-1"}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Now().Format("2006-01-02")
+	if err := target.Deliver(context.Background(), "q3.md", []byte("report")); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || !strings.Contains(names[0], `"name":"`+day+`-q3.md"`) || !strings.Contains(names[0], `"parents":["folder-1"]`) {
+		t.Errorf("metadata %q", names)
+	}
+
+	uploadStatus = http.StatusInternalServerError
+	if err := target.Deliver(context.Background(), "q3.md", []byte("report")); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("failed upload: %v", err)
+	}
+}
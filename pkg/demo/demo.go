@@ -0,0 +1,307 @@
+// Package demo generates the synthetic reference dataset behind --demo:
+// months of daily KPI history that trends toward the targets, compliance,
+// risk and vulnerability metrics, and the incidents and alerts behind
+// them. A dataset depends only on its seed and end time, so demos and
+// tests are reproducible, and it is never mixed with collected data.
+package demo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// Defaults used by --demo.
+const (
+	DefaultSeed   = 1
+	DefaultMonths = 6
+)
+
+// Source is the source recorded on demo incidents and alerts.
+const Source = "demo"
+
+// trendDays is how far back the current value is compared to for a KPI's
+// trend.
+const trendDays = 30
+
+// Options configure a dataset.
+type Options struct {
+	Seed int64
+	// Months is the length of the daily history before End, DefaultMonths
+	// if zero.
+	Months int
+	// End is when the current values are measured.
+	End time.Time
+}
+
+// Dataset is a synthetic set of security data. History holds one sample
+// per KPI and day before the current values in KPIs.
+type Dataset struct {
+	KPIs      []metrics.KPI
+	History   []metrics.KPISample
+	Metrics   []metrics.SecurityMetric
+	Incidents []metrics.Incident
+	Alerts    []metrics.Alert
+}
+
+// profile describes how a KPI moves over the history: from start to end,
+// each jittered by the seed, with relative day-to-day noise.
+type profile struct {
+	key         metrics.KPIKey
+	description string
+	start, end  float64
+	target      float64
+	noise       float64
+}
+
+var profiles = []profile{
+	{metrics.KPI_MTTR, "Average time to respond to security incidents", 4.0, 2.5, 1.0, 0.15},
+	{metrics.KPI_MTTC, "Average time to contain security incidents", 6.0, 4.0, 2.0, 0.12},
+	{metrics.KPI_MTTD, "Average time to detect security incidents", 0.9, 0.5, 0.25, 0.15},
+	{metrics.KPI_Coverage, "Percentage of assets with security controls", 74, 85, 100, 0.02},
+	{metrics.KPI_Compliance, "Overall compliance with security policies", 88, 92, 100, 0.01},
+	{metrics.KPI_RemediationRate, "Percentage of vulnerabilities remediated within SLA", 65, 78, 95, 0.04},
+	{metrics.KPI_DetectionRate, "Percentage of simulated attacks detected", 88, 97, 95, 0.01},
+	{metrics.KPI_MFACoverage, "Percentage of accounts enrolled in MFA", 85, 99, 98, 0.005},
+	{metrics.KPI_DeviceCompliance, "Percentage of managed devices meeting policy", 79, 88, 95, 0.02},
+	{metrics.KPI_NetworkSegmentation, "Percentage of workloads in segmented networks", 55, 68, 80, 0.02},
+}
+
+// owners are the demo owners of each KPI category.
+var owners = map[string]string{
+	"Response":    "Security Operations",
+	"Detection":   "Detection Engineering",
+	"Prevention":  "Security Engineering",
+	"Compliance":  "GRC",
+	"Remediation": "Vulnerability Management",
+	"Zero Trust":  "Identity & Access",
+}
+
+// asset is a demo asset carrying risk and vulnerability metrics.
+type asset struct {
+	name        string
+	criticality metrics.Criticality
+}
+
+var assets = []asset{
+	{"payments-db", metrics.CriticalityCrownJewel},
+	{"customer-portal", metrics.CriticalityHigh},
+	{"build-servers", metrics.CriticalityHigh},
+	{"office-laptops", metrics.CriticalityStandard},
+}
+
+var frameworks = []string{"SOC 2", "ISO 27001", "PCI DSS", "CIS Benchmarks"}
+
+var incidentTitles = []string{
+	"Phishing credential compromise", "Malware on endpoint", "Exposed storage bucket",
+	"Suspicious admin login", "Web application attack", "Data exfiltration attempt",
+}
+
+var alertNames = []string{
+	"Impossible travel", "EDR malware detection", "Brute force against VPN",
+	"New admin role assignment", "WAF SQL injection", "Anomalous data transfer",
+}
+
+// severities are weighted toward the lower severities.
+var severities = []string{"low", "low", "medium", "medium", "medium", "high", "high", "critical"}
+
+// Generate generates the dataset for opts.
+func Generate(opts Options) Dataset {
+	months := opts.Months
+	if months <= 0 {
+		months = DefaultMonths
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	start := opts.End.AddDate(0, -months, 0)
+	days := int(opts.End.Sub(start).Hours() / 24)
+	defs := metrics.NewMetricsCollector()
+
+	var d Dataset
+	for _, p := range profiles {
+		def, _ := defs.GetKPIDefinition(p.key)
+		d.addSeries(rng, p, def, metrics.KPI{Key: p.key, Name: def.Name, Category: def.Category, Owner: owners[def.Category]}, start, days)
+	}
+
+	for _, framework := range frameworks {
+		d.Metrics = append(d.Metrics, metrics.SecurityMetric{
+			ID: "demo-compliance-" + slug(framework), Name: framework + " controls passing", Type: metrics.TypeCompliance,
+			Value: round(70 + rng.Float64()*28), Unit: "%", Target: 100, Timestamp: opts.End, Category: "Compliance",
+		})
+	}
+	for _, a := range assets {
+		d.Metrics = append(d.Metrics,
+			metrics.SecurityMetric{
+				ID: "demo-risk-" + a.name, Name: a.name + " risk", Type: metrics.TypeRisk, Value: round(15 + rng.Float64()*50),
+				Timestamp: opts.End, Category: "Risk", Asset: a.name, Criticality: a.criticality,
+			},
+			metrics.SecurityMetric{
+				ID: "demo-vulnerability-" + a.name, Name: a.name + " vulnerability exposure", Type: metrics.TypeVulnerability,
+				Value: round(10 + rng.Float64()*50), Timestamp: opts.End, Category: "Vulnerability", Asset: a.name, Criticality: a.criticality,
+			},
+		)
+	}
+
+	for day := 0; day < days; day++ {
+		d.addEvents(rng, start.AddDate(0, 0, day), opts.End, "")
+	}
+	return d
+}
+
+// addSeries adds kpi, which names the KPI, moving as p describes: a daily
+// sample for days days from start and the current value on the day after.
+func (d *Dataset) addSeries(rng *rand.Rand, p profile, def metrics.KPIDefinition, kpi metrics.KPI, start time.Time, days int) {
+	from := p.start * jitter(rng, 0.1)
+	to := p.end * jitter(rng, 0.1)
+	values := make([]float64, days+1)
+	for day := range values {
+		base := from + (to-from)*float64(day)/float64(max(days, 1))
+		values[day] = bounded(def, base*(1+rng.NormFloat64()*p.noise))
+		if day < days {
+			d.History = append(d.History, metrics.KPISample{Key: kpi.Key, Value: values[day], Timestamp: start.AddDate(0, 0, day)})
+		}
+	}
+
+	current := values[days]
+	kpi.Description = p.description
+	kpi.Value = current
+	kpi.Target = p.target
+	kpi.Unit = def.Unit
+	kpi.Status = def.Status(current, p.target)
+	kpi.Trend = trend(def, values[max(0, days-trendDays)], current)
+	kpi.LastUpdated = start.AddDate(0, 0, days)
+	if def.Unit == "hours" {
+		// Incident times are skewed: most are quick, a few take long.
+		times := make([]float64, 50)
+		for i := range times {
+			times[i] = current * rng.ExpFloat64()
+		}
+		for i, v := range metrics.CalculatePercentiles(times, metrics.DefaultPercentiles) {
+			kpi.Percentiles = append(kpi.Percentiles, metrics.PercentileValue{Label: metrics.PercentileLabel(metrics.DefaultPercentiles[i]), Value: round(v)})
+		}
+	}
+	d.KPIs = append(d.KPIs, kpi)
+}
+
+// addEvents adds the incidents and alerts of the day starting at date,
+// about three incidents a week and up to three alerts a day. Their IDs
+// carry prefix; times after end are left zero.
+func (d *Dataset) addEvents(rng *rand.Rand, date, end time.Time, prefix string) {
+	if rng.Float64() < 3.0/7 {
+		detected := date.Add(time.Duration(rng.Float64() * float64(24*time.Hour)))
+		incident := metrics.Incident{
+			ID:         fmt.Sprintf("DEMO-INC-%s%04d", strings.ToUpper(prefix), len(d.Incidents)+1),
+			Title:      incidentTitles[rng.Intn(len(incidentTitles))],
+			Severity:   severities[rng.Intn(len(severities))],
+			Source:     Source,
+			DetectedAt: detected,
+		}
+		contained := detected.Add(hours(rng.ExpFloat64() * 4))
+		resolved := contained.Add(hours(rng.ExpFloat64() * 24))
+		if !contained.After(end) {
+			incident.ContainedAt = contained
+			if !resolved.After(end) {
+				incident.ResolvedAt = resolved
+			}
+		}
+		d.Incidents = append(d.Incidents, incident)
+	}
+	for i := rng.Intn(4); i > 0; i-- {
+		fired := date.Add(time.Duration(rng.Float64() * float64(24*time.Hour)))
+		alert := metrics.Alert{
+			ID:       fmt.Sprintf("demo-alert-%s%05d", prefix, len(d.Alerts)+1),
+			Name:     alertNames[rng.Intn(len(alertNames))],
+			Severity: severities[rng.Intn(len(severities))],
+			Source:   Source,
+			FiredAt:  fired,
+		}
+		if acknowledged := fired.Add(hours(rng.ExpFloat64() * 0.5)); !acknowledged.After(end) {
+			alert.AcknowledgedAt = acknowledged
+			if resolved := acknowledged.Add(hours(rng.ExpFloat64() * 4)); !resolved.After(end) {
+				alert.ResolvedAt = resolved
+			}
+		}
+		d.Alerts = append(d.Alerts, alert)
+	}
+}
+
+// Load adds the dataset to collector. History is only added for KPIs
+// without any, so the dataset can be loaded on every collection run.
+func (d Dataset) Load(collector *metrics.MetricsCollector) error {
+	hasHistory := make(map[metrics.KPIKey]bool)
+	for _, kpi := range d.KPIs {
+		hasHistory[kpi.Key] = len(collector.GetKPIHistory(kpi.Key)) > 0
+	}
+	for _, sample := range d.History {
+		if !hasHistory[sample.Key] {
+			collector.AddKPISample(sample)
+		}
+	}
+	for _, incident := range d.Incidents {
+		if err := collector.AddIncident(incident); err != nil {
+			return err
+		}
+	}
+	for _, alert := range d.Alerts {
+		if err := collector.AddAlert(alert); err != nil {
+			return err
+		}
+	}
+	for _, metric := range d.Metrics {
+		collector.AddMetric(metric)
+	}
+	for _, kpi := range d.KPIs {
+		collector.AddKPI(kpi)
+	}
+	return nil
+}
+
+// jitter returns a random factor within spread of 1.
+func jitter(rng *rand.Rand, spread float64) float64 {
+	return 1 + (rng.Float64()*2-1)*spread
+}
+
+// bounded rounds v and keeps it within the bounds of def.
+func bounded(def metrics.KPIDefinition, v float64) float64 {
+	if def.Min != nil {
+		v = math.Max(v, *def.Min)
+	}
+	if def.Max != nil {
+		v = math.Min(v, *def.Max)
+	}
+	return round(v)
+}
+
+// trend returns IMPROVING, DECLINING or STABLE for a move from previous to
+// current, ignoring moves within 2%.
+func trend(def metrics.KPIDefinition, previous, current float64) string {
+	change := (current - previous) / math.Max(math.Abs(previous), 1e-9)
+	if def.Direction == metrics.LowerIsBetter {
+		change = -change
+	}
+	switch {
+	case change > 0.02:
+		return "IMPROVING"
+	case change < -0.02:
+		return "DECLINING"
+	}
+	return "STABLE"
+}
+
+// round rounds v to two decimals.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// hours converts a number of hours to a duration.
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
+
+// slug lower-cases name and replaces spaces with dashes.
+func slug(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), " ", "-")
+}
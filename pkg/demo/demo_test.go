@@ -0,0 +1,124 @@
+package demo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+var end = time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+func TestGenerateIsReproducible(t *testing.T) {
+	a := Generate(Options{Seed: 7, End: end})
+	if b := Generate(Options{Seed: 7, End: end}); !reflect.DeepEqual(a, b) {
+		t.Error("the same seed generated different datasets")
+	}
+	if c := Generate(Options{Seed: 8, End: end}); reflect.DeepEqual(a.KPIs, c.KPIs) {
+		t.Error("different seeds generated the same KPIs")
+	}
+}
+
+func TestGenerateReferenceDataset(t *testing.T) {
+	d := Generate(Options{Seed: DefaultSeed, Months: 3, End: end})
+	defs := metrics.NewMetricsCollector()
+	start := end.AddDate(0, -3, 0)
+	days := int(end.Sub(start).Hours() / 24)
+
+	if len(d.KPIs) != len(profiles) || len(d.History) != len(profiles)*days {
+		t.Fatalf("%d KPIs with %d samples, want %d with %d", len(d.KPIs), len(d.History), len(profiles), len(profiles)*days)
+	}
+	for _, sample := range d.History {
+		if sample.Timestamp.Before(start) || !sample.Timestamp.Before(end) {
+			t.Errorf("sample of %s at %v is outside the history", sample.Key, sample.Timestamp)
+		}
+		def, _ := defs.GetKPIDefinition(sample.Key)
+		if err := def.Validate(sample.Value); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, kpi := range d.KPIs {
+		def, ok := defs.GetKPIDefinition(kpi.Key)
+		if !ok || kpi.Name != def.Name || kpi.Unit != def.Unit {
+			t.Errorf("%s does not match its definition: %+v", kpi.Key, kpi)
+		}
+		if err := def.Validate(kpi.Value); err != nil {
+			t.Error(err)
+		}
+		if kpi.Status != def.Status(kpi.Value, kpi.Target) || kpi.Trend == "" {
+			t.Errorf("%s: status %q, trend %q", kpi.Key, kpi.Status, kpi.Trend)
+		}
+		if (kpi.Unit == "hours") != (len(kpi.Percentiles) == len(metrics.DefaultPercentiles)) {
+			t.Errorf("%s has percentiles %v", kpi.Key, kpi.Percentiles)
+		}
+	}
+	if len(d.Incidents) == 0 || len(d.Alerts) == 0 {
+		t.Fatalf("%d incidents and %d alerts", len(d.Incidents), len(d.Alerts))
+	}
+	for _, incident := range d.Incidents {
+		if incident.ResolvedAt.After(end) || (!incident.ResolvedAt.IsZero() && incident.ResolvedAt.Before(incident.ContainedAt)) {
+			t.Errorf("incident %s detected %v, contained %v, resolved %v", incident.ID, incident.DetectedAt, incident.ContainedAt, incident.ResolvedAt)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	d := Generate(Options{Seed: DefaultSeed, End: end})
+	collector := metrics.NewMetricsCollector()
+	for i := 0; i < 2; i++ {
+		if err := d.Load(collector); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summary := collector.GetSummary()
+	if want := (metrics.ScoreData{Compliance: true, Risk: true, Vulnerability: true, Posture: true}); summary.HasData != want {
+		t.Errorf("HasData = %+v, want every score", summary.HasData)
+	}
+	if summary.OverallHealth == metrics.InsufficientData {
+		t.Error("overall health has insufficient data")
+	}
+	// History is only added once; each load records the current value.
+	if got, want := len(collector.GetKPIHistory(metrics.KPI_MTTR)), len(d.History)/len(profiles)+2; got != want {
+		t.Errorf("%d MTTR samples after loading twice, want %d", got, want)
+	}
+	if got := len(collector.GetIncidents()); got != len(d.Incidents) {
+		t.Errorf("%d incidents after loading twice, want %d", got, len(d.Incidents))
+	}
+}
+
+func TestGenerateLoad(t *testing.T) {
+	opts := LoadOptions{Seed: 3, KPIs: 25, Days: 40, Teams: 4, End: end}
+	d := GenerateLoad(opts)
+	if !reflect.DeepEqual(d, GenerateLoad(opts)) {
+		t.Error("the same seed generated different datasets")
+	}
+
+	if len(d.KPIs) != 25*4 || len(d.History) != 25*4*40 {
+		t.Fatalf("%d KPIs with %d samples, want %d with %d", len(d.KPIs), len(d.History), 25*4, 25*4*40)
+	}
+	if want := 4 * 41 * 3; len(d.Metrics) != want {
+		t.Errorf("%d metrics, want %d", len(d.Metrics), want)
+	}
+	keys := make(map[metrics.KPIKey]bool)
+	for _, kpi := range d.KPIs {
+		if keys[kpi.Key] {
+			t.Errorf("duplicate KPI %s", kpi.Key)
+		}
+		keys[kpi.Key] = true
+	}
+	ids := make(map[string]bool)
+	for _, incident := range d.Incidents {
+		if ids[incident.ID] {
+			t.Errorf("duplicate incident %s", incident.ID)
+		}
+		ids[incident.ID] = true
+	}
+
+	collector := metrics.NewMetricsCollector()
+	collector.Restore(d.Metrics, d.KPIs, d.History)
+	if categories := collector.GetCategorySummaries(); len(categories) == 0 || len(categories[0].Subcategories) != 4 {
+		t.Errorf("categories = %+v, want 4 teams in each", categories)
+	}
+}
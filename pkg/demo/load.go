@@ -0,0 +1,83 @@
+package demo
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// LoadOptions configure a load test dataset of KPIs × Days × Teams.
+type LoadOptions struct {
+	Seed int64
+	// KPIs is the number of KPIs per team. They cycle through the demo
+	// KPIs, numbered after the first round.
+	KPIs int
+	// Days is the length of the daily history before End.
+	Days int
+	// Teams is the number of teams, each a subcategory of every KPI's
+	// category with its own metrics, incidents and alerts.
+	Teams int
+	// End is when the current values are measured.
+	End time.Time
+}
+
+// GenerateLoad generates a dataset with opts.KPIs × opts.Teams KPIs, each
+// with opts.Days daily samples, and per team and day a compliance, risk
+// and vulnerability metric, for load testing the store, API and reports.
+func GenerateLoad(opts LoadOptions) Dataset {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	start := opts.End.AddDate(0, 0, -opts.Days)
+	defs := metrics.NewMetricsCollector()
+
+	var d Dataset
+	for t := 1; t <= opts.Teams; t++ {
+		team := fmt.Sprintf("team-%03d", t)
+		for i := 0; i < opts.KPIs; i++ {
+			p := profiles[i%len(profiles)]
+			def, _ := defs.GetKPIDefinition(p.key)
+			key := string(p.key)
+			if n := i / len(profiles); n > 0 {
+				key = fmt.Sprintf("%s_%d", key, n+1)
+			}
+			d.addSeries(rng, p, def, metrics.KPI{
+				Key:      metrics.KPIKey(fmt.Sprintf("%s_t%03d", key, t)),
+				Name:     def.Name + " (" + team + ")",
+				Category: def.Category + metrics.CategorySeparator + team,
+				Owner:    team,
+			}, start, opts.Days)
+		}
+
+		for day := 0; day <= opts.Days; day++ {
+			date := start.AddDate(0, 0, day)
+			for _, m := range []struct {
+				typ      metrics.MetricType
+				category string
+				base     float64
+				unit     string
+				target   float64
+			}{
+				{metrics.TypeCompliance, "Compliance", 70, "%", 100},
+				{metrics.TypeRisk, "Risk", 15, "", 0},
+				{metrics.TypeVulnerability, "Vulnerability", 10, "", 0},
+			} {
+				d.Metrics = append(d.Metrics, metrics.SecurityMetric{
+					ID:        fmt.Sprintf("load-%s-%s-%s", slug(m.category), team, date.Format("20060102")),
+					Name:      team + " " + m.category,
+					Type:      m.typ,
+					Value:     round(m.base + rng.Float64()*28),
+					Unit:      m.unit,
+					Target:    m.target,
+					Timestamp: date,
+					Category:  m.category + metrics.CategorySeparator + team,
+					Asset:     team,
+				})
+			}
+			if day < opts.Days {
+				d.addEvents(rng, date, opts.End, fmt.Sprintf("t%03d-", t))
+			}
+		}
+	}
+	return d
+}
@@ -0,0 +1,139 @@
+// Package encryption provides AES-GCM encryption of data at rest.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+)
+
+// magic prefixes every encrypted payload so encrypted and plaintext files
+// can be told apart.
+var magic = []byte("SMENC1")
+
+// KeySize is the required key length in bytes (AES-256).
+const KeySize = 32
+
+// DefaultKeyEnv is the environment variable holding the base64 key.
+const DefaultKeyEnv = "SECMETRICS_ENCRYPTION_KEY"
+
+// ErrNotEncrypted is returned when decrypting data without the header.
+var ErrNotEncrypted = errors.New("data is not encrypted")
+
+// Config configures encryption at rest. Exactly one key source is used:
+// a base64 key in an environment variable, or an AWS KMS encrypted data key.
+type Config struct {
+	Enabled bool       `yaml:"enabled"`
+	KeyEnv  string     `yaml:"key_env"`
+	KMS     *KMSConfig `yaml:"kms"`
+}
+
+// Cipher encrypts and decrypts data with AES-256-GCM.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a 32-byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// New creates a cipher from configuration. It returns nil when encryption
+// is disabled. The client is used to reach KMS.
+func New(cfg Config, client *http.Client) (*Cipher, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.KMS != nil {
+		key, err := DecryptDataKey(*cfg.KMS, client)
+		if err != nil {
+			return nil, err
+		}
+		return NewCipher(key)
+	}
+
+	env := cfg.KeyEnv
+	if env == "" {
+		env = DefaultKeyEnv
+	}
+	key, err := KeyFromEnv(env)
+	if err != nil {
+		return nil, err
+	}
+	return NewCipher(key)
+}
+
+// KeyFromEnv reads a base64-encoded key from an environment variable.
+func KeyFromEnv(name string) ([]byte, error) {
+	value := strings.TrimSpace(secretenv.Get(name))
+	if value == "" {
+		return nil, fmt.Errorf("encryption key environment variable %s is not set", name)
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", name, err)
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new random base64-encoded key.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Encrypt encrypts plaintext, returning header || nonce || ciphertext.
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(magic)+len(nonce)+len(plaintext)+c.aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, plaintext, magic), nil
+}
+
+// Decrypt decrypts data produced by Encrypt.
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, ErrNotEncrypted
+	}
+	data = data[len(magic):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, magic)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether data carries the encryption header.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
@@ -0,0 +1,114 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testCipher(t *testing.T, fill byte) *Cipher {
+	t.Helper()
+	c, err := NewCipher(bytes.Repeat([]byte{fill}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	c := testCipher(t, 1)
+	plaintext := []byte(`{"kpis":[]}`)
+	data, err := c.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(data) || bytes.Contains(data, plaintext) {
+		t.Fatalf("ciphertext %q", data)
+	}
+	again, _ := c.Encrypt(plaintext)
+	if bytes.Equal(data, again) {
+		t.Error("nonce reused: two encryptions are identical")
+	}
+	got, err := c.Decrypt(data)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+
+	if _, err := testCipher(t, 2).Decrypt(data); err == nil {
+		t.Error("decrypted with the wrong key")
+	}
+	tampered := append([]byte(nil), data...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := c.Decrypt(tampered); err == nil {
+		t.Error("decrypted tampered ciphertext")
+	}
+	if _, err := c.Decrypt(data[:len(magic)+4]); err == nil {
+		t.Error("decrypted truncated ciphertext")
+	}
+	if _, err := c.Decrypt(plaintext); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("decrypt plaintext: %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if c, err := New(Config{}, nil); c != nil || err != nil {
+		t.Errorf("disabled: %v, %v", c, err)
+	}
+	if _, err := NewCipher(make([]byte, 16)); err == nil {
+		t.Error("accepted a 128-bit key")
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECMETRICS_TEST_KEY", key)
+	c, err := New(Config{Enabled: true, KeyEnv: "SECMETRICS_TEST_KEY"}, nil)
+	if err != nil || c == nil {
+		t.Fatalf("New from environment: %v", err)
+	}
+	t.Setenv("SECMETRICS_TEST_KEY", "not base64!")
+	if _, err := New(Config{Enabled: true, KeyEnv: "SECMETRICS_TEST_KEY"}, nil); err == nil {
+		t.Error("accepted an invalid key")
+	}
+	if _, err := New(Config{Enabled: true, KeyEnv: "SECMETRICS_TEST_UNSET_KEY"}, nil); err == nil {
+		t.Error("enabled without a key")
+	}
+}
+
+func TestKMSDataKey(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, KeySize)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("X-Amz-Target") != "TrentService.Decrypt" || body["CiphertextBlob"] != "blob" ||
+			!strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/kms/aws4_request") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Plaintext": base64.StdEncoding.EncodeToString(dataKey)})
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	cfg := Config{Enabled: true, KMS: &KMSConfig{Region: "eu-west-1", EncryptedKey: "blob", Endpoint: srv.URL}}
+	c, err := New(cfg, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := c.Encrypt([]byte("report"))
+	if got, err := testCipher(t, 7).Decrypt(data); err != nil || string(got) != "report" {
+		t.Errorf("KMS data key not used: %q, %v", got, err)
+	}
+
+	cfg.KMS.EncryptedKey = "other"
+	if _, err := New(cfg, srv.Client()); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("rejected decrypt: %v", err)
+	}
+}
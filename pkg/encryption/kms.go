@@ -0,0 +1,79 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/awsv4"
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+)
+
+// KMSConfig configures an AWS KMS encrypted data key. The data key is
+// generated once (aws kms generate-data-key --key-spec AES_256) and its
+// CiphertextBlob stored in configuration; KMS decrypts it at startup.
+type KMSConfig struct {
+	Region string `yaml:"region"`
+	// EncryptedKey is the base64 CiphertextBlob of the data key.
+	EncryptedKey string `yaml:"encrypted_key"`
+	// Endpoint overrides the regional KMS endpoint.
+	Endpoint string `yaml:"endpoint"`
+}
+
+// DecryptDataKey decrypts the configured data key with AWS KMS. Credentials
+// are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN.
+func DecryptDataKey(cfg KMSConfig, client *http.Client) ([]byte, error) {
+	if cfg.Region == "" || cfg.EncryptedKey == "" {
+		return nil, fmt.Errorf("kms: region and encrypted_key are required")
+	}
+	creds := awsv4.Credentials{
+		AccessKeyID:     secretenv.Get("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: secretenv.Get("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    secretenv.Get("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("kms: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + cfg.Region + ".amazonaws.com/"
+	}
+
+	body, err := json.Marshal(map[string]string{"CiphertextBlob": cfg.EncryptedKey})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+	awsv4.Sign(req, body, creds, cfg.Region, "kms", time.Now())
+
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kms: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("kms: unexpected status %s: %s", resp.Status, string(msg))
+	}
+
+	var result struct {
+		Plaintext string `json:"Plaintext"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("kms: decode response: %w", err)
+	}
+	return base64.StdEncoding.DecodeString(result.Plaintext)
+}
@@ -0,0 +1,473 @@
+// Package expr parses and evaluates arithmetic and boolean expressions over
+// KPI values, as used by derived KPIs and composite alert rules, e.g.
+//
+//	remediation_rate < 80 and critical_open > 5
+//	(mttd + mttc) / 2
+//	coverage >= target(coverage)
+//
+// Identifiers are KPI keys and evaluate to the KPI's value. Operators, by
+// increasing precedence, are or (||), and (&&), not (!), the comparisons
+// < <= > >= == !=, + and -, * and /, and unary minus. The functions are
+// min(x, ...), max(x, ...), abs(x) and target(kpi), the target of a KPI.
+//
+// Every expression evaluates to a float64. Comparisons and logical
+// operators yield 1 for true and 0 for false, and treat non-zero values as
+// true. A KPI without a value evaluates to NaN, which propagates through
+// arithmetic and makes comparisons false, like the inputs of WebAssembly
+// derived KPIs.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// Env resolves a KPI key to the KPI's value and target; ok is false when
+// the KPI has no value.
+type Env func(key string) (value, target float64, ok bool)
+
+// KPIs returns an Env over the current KPIs of collector.
+func KPIs(collector *metrics.MetricsCollector) Env {
+	return func(key string) (float64, float64, bool) {
+		kpi := collector.GetKPI(metrics.KPIKey(key))
+		if kpi == nil {
+			return 0, 0, false
+		}
+		return kpi.Value, kpi.Target, true
+	}
+}
+
+// Expr is a parsed expression.
+type Expr struct {
+	source string
+	root   node
+	keys   []string
+}
+
+// Parse parses an expression.
+func Parse(source string) (*Expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+	p := &parser{tokens: tokens, keys: make(map[string]bool)}
+	root, err := p.or()
+	if err == nil && p.peek().kind != tokEOF {
+		tok := p.peek()
+		err = fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", source, err)
+	}
+	keys := make([]string, 0, len(p.keys))
+	for key := range p.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return &Expr{source: source, root: root, keys: keys}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Keys returns the KPI keys the expression refers to, sorted.
+func (e *Expr) Keys() []string {
+	return append([]string(nil), e.keys...)
+}
+
+// Eval evaluates the expression in env.
+func (e *Expr) Eval(env Env) float64 {
+	return e.root.eval(env)
+}
+
+// True evaluates the expression in env as a condition.
+func (e *Expr) True(env Env) bool {
+	return truth(e.Eval(env))
+}
+
+// truth reports whether v counts as true: non-zero and not NaN.
+func truth(v float64) bool {
+	return v != 0 && !math.IsNaN(v)
+}
+
+// boolean returns 1 for true and 0 for false.
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type node interface {
+	eval(env Env) float64
+}
+
+type number float64
+
+func (n number) eval(Env) float64 { return float64(n) }
+
+// kpi evaluates to the value of a KPI, or its target.
+type kpi struct {
+	key    string
+	target bool
+}
+
+func (k kpi) eval(env Env) float64 {
+	value, target, ok := env(k.key)
+	switch {
+	case !ok:
+		return math.NaN()
+	case k.target:
+		return target
+	}
+	return value
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (u unary) eval(env Env) float64 {
+	v := u.operand.eval(env)
+	if u.op == "not" {
+		return boolean(!truth(v))
+	}
+	return -v
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (b binary) eval(env Env) float64 {
+	// The logical operators short-circuit.
+	switch b.op {
+	case "and":
+		return boolean(truth(b.left.eval(env)) && truth(b.right.eval(env)))
+	case "or":
+		return boolean(truth(b.left.eval(env)) || truth(b.right.eval(env)))
+	}
+	l, r := b.left.eval(env), b.right.eval(env)
+	switch b.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		return l / r
+	case "<":
+		return boolean(l < r)
+	case "<=":
+		return boolean(l <= r)
+	case ">":
+		return boolean(l > r)
+	case ">=":
+		return boolean(l >= r)
+	case "==":
+		return boolean(l == r)
+	}
+	return boolean(l != r && !math.IsNaN(l) && !math.IsNaN(r))
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+func (c call) eval(env Env) float64 {
+	result := c.args[0].eval(env)
+	for _, arg := range c.args[1:] {
+		v := arg.eval(env)
+		switch {
+		case math.IsNaN(v):
+			return v
+		case c.name == "min":
+			result = math.Min(result, v)
+		default:
+			result = math.Max(result, v)
+		}
+	}
+	if c.name == "abs" {
+		return math.Abs(result)
+	}
+	return result
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators lists the symbolic operators, longest first, with the
+// keyword they stand for.
+var operators = []struct{ symbol, op string }{
+	{"&&", "and"}, {"||", "or"}, {"<=", "<="}, {">=", ">="}, {"==", "=="}, {"!=", "!="},
+	{"<", "<"}, {">", ">"}, {"!", "not"}, {"+", "+"}, {"-", "-"}, {"*", "*"}, {"/", "/"},
+	{"(", "("}, {")", ")"}, {",", ","},
+}
+
+// tokenize splits source into tokens. The keywords and, or and not are
+// returned as operators.
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	isIdent := func(r rune, first bool) bool {
+		return r == '_' || unicode.IsLetter(r) || !first && (unicode.IsDigit(r) || r == '.')
+	}
+	for i := 0; i < len(source); {
+		r := rune(source[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.' ||
+				source[i] == 'e' || source[i] == 'E' ||
+				(source[i] == '-' || source[i] == '+') && (source[i-1] == 'e' || source[i-1] == 'E')) {
+				i++
+			}
+			if _, err := strconv.ParseFloat(source[start:i], 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q at offset %d", source[start:i], start)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: source[start:i], pos: start})
+		case isIdent(r, true):
+			start := i
+			for i < len(source) && isIdent(rune(source[i]), false) {
+				i++
+			}
+			text := source[start:i]
+			switch lower := strings.ToLower(text); lower {
+			case "and", "or", "not":
+				tokens = append(tokens, token{kind: tokOp, text: lower, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokIdent, text: text, pos: start})
+			}
+		default:
+			matched := false
+			for _, o := range operators {
+				if strings.HasPrefix(source[i:], o.symbol) {
+					tokens = append(tokens, token{kind: tokOp, text: o.op, pos: i})
+					i += len(o.symbol)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", source[i], i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(source)}), nil
+}
+
+// parser is a recursive-descent parser over tokens, one method per
+// precedence level.
+type parser struct {
+	tokens []token
+	keys   map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[0]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[0]
+	if tok.kind != tokEOF {
+		p.tokens = p.tokens[1:]
+	}
+	return tok
+}
+
+// accept consumes the next token if it is one of the operators ops.
+func (p *parser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.next()
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		tok := p.peek()
+		return fmt.Errorf("expected %q, got %s at offset %d", op, tok, tok.pos)
+	}
+	return nil
+}
+
+// binaryLevel parses operand (op operand)*, left-associative.
+func (p *parser) binaryLevel(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) or() (node, error) {
+	return p.binaryLevel(p.and, "or")
+}
+
+func (p *parser) and() (node, error) {
+	return p.binaryLevel(p.not, "and")
+}
+
+func (p *parser) not() (node, error) {
+	if _, ok := p.accept("not"); ok {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: "not", operand: operand}, nil
+	}
+	return p.comparison()
+}
+
+// comparisons are the comparison operators.
+var comparisons = []string{"<", "<=", ">", ">=", "==", "!="}
+
+// comparison parses at most one comparison; chains such as a < b < c are
+// rejected rather than read as (a < b) < c.
+func (p *parser) comparison() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept(comparisons...)
+	if !ok {
+		return left, nil
+	}
+	right, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind == tokOp {
+		for _, cmp := range comparisons {
+			if tok.text == cmp {
+				return nil, fmt.Errorf("comparisons cannot be chained at offset %d; combine them with and", tok.pos)
+			}
+		}
+	}
+	return binary{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) sum() (node, error) {
+	return p.binaryLevel(p.term, "+", "-")
+}
+
+func (p *parser) term() (node, error) {
+	return p.binaryLevel(p.unary, "*", "/")
+}
+
+func (p *parser) unary() (node, error) {
+	if _, ok := p.accept("-"); ok {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: "-", operand: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokNumber:
+		v, _ := strconv.ParseFloat(tok.text, 64)
+		return number(v), nil
+	case tok.kind == tokIdent:
+		if _, ok := p.accept("("); ok {
+			return p.call(tok)
+		}
+		p.keys[tok.text] = true
+		return kpi{key: tok.text}, nil
+	case tok.kind == tokOp && tok.text == "(":
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+	return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+}
+
+// call parses the arguments of a function call after its opening
+// parenthesis.
+func (p *parser) call(name token) (node, error) {
+	if name.text == "target" {
+		arg := p.next()
+		if arg.kind != tokIdent {
+			return nil, fmt.Errorf("target takes a KPI key, got %s at offset %d", arg, arg.pos)
+		}
+		p.keys[arg.text] = true
+		return kpi{key: arg.text, target: true}, p.expect(")")
+	}
+
+	var args []node
+	for {
+		arg, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if _, ok := p.accept(","); !ok {
+			break
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	switch {
+	case name.text != "min" && name.text != "max" && name.text != "abs":
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	case name.text == "abs" && len(args) != 1:
+		return nil, fmt.Errorf("abs takes one argument, got %d", len(args))
+	}
+	return call{name: name.text, args: args}, nil
+}
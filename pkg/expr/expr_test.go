@@ -0,0 +1,80 @@
+package expr
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	values := map[string][2]float64{
+		"remediation_rate": {75, 90},
+		"critical_open":    {7, 0},
+		"mttd":             {1, 0.5},
+		"mttc":             {3, 4},
+	}
+	env := func(key string) (float64, float64, bool) {
+		v, ok := values[key]
+		return v[0], v[1], ok
+	}
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"remediation_rate < 80 AND critical_open > 5", 1},
+		{"remediation_rate < 80 && critical_open > 10", 0},
+		{"remediation_rate >= target(remediation_rate) or critical_open == 7", 1},
+		{"not (mttd > 2) and !(mttc > 5)", 1},
+		{"(mttd + mttc) / 2", 2},
+		{"-mttd * 2 + mttc", 1},
+		{"1 + 2 * 3 - 4 / 2", 5},
+		{"min(mttd, mttc, 0.5) + max(mttd, mttc) + abs(-2)", 5.5},
+		{"2.5e1 > 24", 1},
+		{"unknown > 1 or unknown <= 1 or unknown != 1", 0},
+		{"not unknown > 1", 1},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.source)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.source, err)
+		}
+		if got := e.Eval(env); got != tt.want {
+			t.Errorf("%q = %g, want %g", tt.source, got, tt.want)
+		}
+	}
+
+	e, _ := Parse("unknown + 1")
+	if got := e.Eval(env); !math.IsNaN(got) {
+		t.Errorf("missing KPI evaluates to %g, want NaN", got)
+	}
+	if e.True(env) {
+		t.Error("NaN counts as true")
+	}
+	e, _ = Parse("coverage < target(mfa_coverage) and coverage > 1")
+	if keys := e.Keys(); !reflect.DeepEqual(keys, []string{"coverage", "mfa_coverage"}) {
+		t.Errorf("Keys = %v", keys)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                       "unexpected end of expression",
+		"mttr >":                 "unexpected end of expression",
+		"(mttr > 1":              `expected ")"`,
+		"mttr > 1 2":             `unexpected "2"`,
+		"1 < mttr < 3":           "cannot be chained",
+		"mttr ^ 2":               "unexpected character",
+		"sqrt(mttr)":             `unknown function "sqrt"`,
+		"abs(mttr, mttc)":        "abs takes one argument",
+		"target(1)":              "target takes a KPI key",
+		"1.2.3 > mttr":           "invalid number",
+		"mttr > 1 and or mttc>1": `unexpected "or"`,
+	}
+	for source, want := range tests {
+		_, err := Parse(source)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want %q", source, err, want)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package grc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// ExportConfig configures pushing KPI values and risk scores to a GRC
+// system of record.
+type ExportConfig struct {
+	ServiceNow *ServiceNowConfig `yaml:"servicenow"`
+	Archer     *ArcherConfig     `yaml:"archer"`
+	// Interval between pushes, e.g. "24h" (default).
+	Interval string `yaml:"interval"`
+	// Client carries the proxy and trusted CAs of the top-level http
+	// section; it is set by the caller rather than from the config file.
+	Client *http.Client `yaml:"-"`
+}
+
+// DefaultExportInterval is the push interval when none is configured.
+const DefaultExportInterval = 24 * time.Hour
+
+// ServiceNowConfig configures pushing records into ServiceNow GRC
+// indicator results through the Table API.
+type ServiceNowConfig struct {
+	// URL is the instance, e.g. https://acme.service-now.com.
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	// PasswordEnv names the environment variable holding the password.
+	PasswordEnv string `yaml:"password_env"`
+	// Table receives one row per record (default sn_grc_indicator_result).
+	Table string `yaml:"table"`
+	// Indicators maps record keys to the sys_id of their indicator;
+	// records without an indicator are not pushed.
+	Indicators map[string]string `yaml:"indicators"`
+}
+
+// ArcherConfig configures pushing records into an RSA Archer application
+// through the Archer REST API.
+type ArcherConfig struct {
+	// URL is the Archer base URL, e.g. https://archer.acme.example/RSAarcher.
+	URL        string `yaml:"url"`
+	Instance   string `yaml:"instance"`
+	Username   string `yaml:"username"`
+	UserDomain string `yaml:"user_domain"`
+	// PasswordEnv names the environment variable holding the password.
+	PasswordEnv string `yaml:"password_env"`
+	// LevelID is the level of the metrics application records are created
+	// in.
+	LevelID int `yaml:"level_id"`
+	// Fields are the IDs of the application fields records fill; fields
+	// left at 0 are not set.
+	Fields ArcherFields `yaml:"fields"`
+}
+
+// ArcherFields are the Archer field IDs of record values.
+type ArcherFields struct {
+	Key    int `yaml:"key"`
+	Name   int `yaml:"name"`
+	Value  int `yaml:"value"`
+	Target int `yaml:"target"`
+	Status int `yaml:"status"`
+	Date   int `yaml:"date"`
+}
+
+// Record is a KPI value or risk score pushed to a GRC system.
+type Record struct {
+	Key      string
+	Name     string
+	Category string
+	Value    float64
+	Target   float64
+	Unit     string
+	// Passed reports whether the value meets its target.
+	Passed bool
+	Time   time.Time
+}
+
+// healthyRisk is the highest risk score of healthy overall health.
+const healthyRisk = 30
+
+// Records returns the KPIs of collector ordered by key, followed by the
+// overall risk score and the risk metrics ordered by ID.
+func Records(collector *metrics.MetricsCollector, now time.Time) []Record {
+	kpis := collector.GetKPIS()
+	sort.Slice(kpis, func(i, j int) bool { return kpis[i].Key < kpis[j].Key })
+	var records []Record
+	for _, kpi := range kpis {
+		at := kpi.LastUpdated
+		if at.IsZero() {
+			at = now
+		}
+		name := kpi.Name
+		if name == "" {
+			name = string(kpi.Key)
+		}
+		records = append(records, Record{Key: string(kpi.Key), Name: name, Category: kpi.Category,
+			Value: kpi.Value, Target: kpi.Target, Unit: kpi.Unit, Passed: kpi.Status != "BELOW_TARGET", Time: at})
+	}
+
+	risk := collector.GetRiskScore()
+	records = append(records, Record{Key: "risk_score", Name: "Risk Score", Category: "Risk",
+		Value: risk, Target: healthyRisk, Passed: risk <= healthyRisk, Time: now})
+	var risks []metrics.SecurityMetric
+	for _, metric := range collector.GetMetrics() {
+		if metric.Type == metrics.TypeRisk {
+			risks = append(risks, metric)
+		}
+	}
+	sort.Slice(risks, func(i, j int) bool { return risks[i].ID < risks[j].ID })
+	for _, metric := range risks {
+		at := metric.Timestamp
+		if at.IsZero() {
+			at = now
+		}
+		name := metric.Name
+		if name == "" {
+			name = metric.ID
+		}
+		records = append(records, Record{Key: metric.ID, Name: name, Category: "Risk", Value: metric.Value,
+			Target: metric.Target, Unit: metric.Unit, Passed: metric.Target == 0 || metric.Value <= metric.Target, Time: at})
+	}
+	return records
+}
+
+// Exporter pushes records to the configured systems of record.
+type Exporter struct {
+	interval   time.Duration
+	serviceNow *serviceNow
+	archer     *archer
+}
+
+// NewExporter creates the exporter configured in cfg. It returns nil when
+// neither ServiceNow nor Archer is configured.
+func NewExporter(cfg ExportConfig) (*Exporter, error) {
+	if cfg.ServiceNow == nil && cfg.Archer == nil {
+		return nil, nil
+	}
+	e := &Exporter{interval: DefaultExportInterval}
+	if cfg.Interval != "" {
+		interval, err := time.ParseDuration(cfg.Interval)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("grc_export: invalid interval %q", cfg.Interval)
+		}
+		e.interval = interval
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	if sn := cfg.ServiceNow; sn != nil {
+		if sn.URL == "" || sn.Username == "" || sn.PasswordEnv == "" {
+			return nil, fmt.Errorf("grc_export servicenow: url, username and password_env are required")
+		}
+		if len(sn.Indicators) == 0 {
+			return nil, fmt.Errorf("grc_export servicenow: at least one indicator is required")
+		}
+		password := secretenv.Get(sn.PasswordEnv)
+		if password == "" {
+			return nil, fmt.Errorf("grc_export servicenow: environment variable %s is not set", sn.PasswordEnv)
+		}
+		table := sn.Table
+		if table == "" {
+			table = "sn_grc_indicator_result"
+		}
+		e.serviceNow = &serviceNow{config: *sn, client: client, password: password,
+			tableURL: strings.TrimRight(sn.URL, "/") + "/api/now/table/" + url.PathEscape(table)}
+	}
+	if a := cfg.Archer; a != nil {
+		if a.URL == "" || a.Instance == "" || a.Username == "" || a.PasswordEnv == "" {
+			return nil, fmt.Errorf("grc_export archer: url, instance, username and password_env are required")
+		}
+		if a.LevelID == 0 || a.Fields.Key == 0 || a.Fields.Value == 0 {
+			return nil, fmt.Errorf("grc_export archer: level_id and the key and value fields are required")
+		}
+		password := secretenv.Get(a.PasswordEnv)
+		if password == "" {
+			return nil, fmt.Errorf("grc_export archer: environment variable %s is not set", a.PasswordEnv)
+		}
+		e.archer = &archer{config: *a, client: client, password: password, apiURL: strings.TrimRight(a.URL, "/") + "/platformapi/core"}
+	}
+	return e, nil
+}
+
+// Interval returns the time between pushes.
+func (e *Exporter) Interval() time.Duration {
+	return e.interval
+}
+
+// PushResult is the outcome of pushing records to one system.
+type PushResult struct {
+	// System is "servicenow" or "archer".
+	System string
+	// Records is the number of records the system accepted.
+	Records int
+	Err     error
+}
+
+// Push sends records to every configured system.
+func (e *Exporter) Push(ctx context.Context, records []Record) []PushResult {
+	var results []PushResult
+	if e.serviceNow != nil {
+		n, err := e.serviceNow.push(ctx, records)
+		results = append(results, PushResult{System: "servicenow", Records: n, Err: err})
+	}
+	if e.archer != nil {
+		n, err := e.archer.push(ctx, records)
+		results = append(results, PushResult{System: "archer", Records: n, Err: err})
+	}
+	return results
+}
+
+// serviceNow inserts indicator results through the Table API.
+type serviceNow struct {
+	config   ServiceNowConfig
+	client   *http.Client
+	password string
+	tableURL string
+}
+
+func (s *serviceNow) push(ctx context.Context, records []Record) (int, error) {
+	n := 0
+	for _, record := range records {
+		indicator, ok := s.config.Indicators[record.Key]
+		if !ok {
+			continue
+		}
+		supporting, err := json.Marshal(map[string]interface{}{
+			"key": record.Key, "name": record.Name, "target": record.Target,
+			"unit": record.Unit, "collected": record.Time.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return n, err
+		}
+		row := map[string]string{
+			"indicator":       indicator,
+			"value":           strconv.FormatFloat(record.Value, 'f', -1, 64),
+			"passed":          strconv.FormatBool(record.Passed),
+			"supporting_data": string(supporting),
+		}
+		if err := s.post(ctx, row); err != nil {
+			return n, fmt.Errorf("%s: %w", record.Key, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *serviceNow) post(ctx context.Context, row map[string]string) error {
+	body, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tableURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.config.Username, s.password)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// archer creates content records through the Archer REST API, logging in
+// for each push.
+type archer struct {
+	config   ArcherConfig
+	client   *http.Client
+	password string
+	apiURL   string
+}
+
+// Archer field value types.
+const (
+	archerText    = 1
+	archerNumeric = 2
+	archerDate    = 3
+)
+
+func (a *archer) push(ctx context.Context, records []Record) (int, error) {
+	token, err := a.login(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("login: %w", err)
+	}
+	defer a.logout(token)
+
+	n := 0
+	for _, record := range records {
+		fields := make(map[string]interface{})
+		set := func(id, kind int, value interface{}) {
+			if id != 0 {
+				fields[strconv.Itoa(id)] = map[string]interface{}{"Type": kind, "Value": value, "FieldId": id}
+			}
+		}
+		status := "Below Target"
+		if record.Passed {
+			status = "On Target"
+		}
+		set(a.config.Fields.Key, archerText, record.Key)
+		set(a.config.Fields.Name, archerText, record.Name)
+		set(a.config.Fields.Value, archerNumeric, record.Value)
+		set(a.config.Fields.Target, archerNumeric, record.Target)
+		set(a.config.Fields.Status, archerText, status)
+		set(a.config.Fields.Date, archerDate, record.Time.UTC().Format("01/02/2006"))
+
+		var result archerResult
+		content := map[string]interface{}{"Content": map[string]interface{}{"LevelId": a.config.LevelID, "FieldContents": fields}}
+		if err := a.call(ctx, http.MethodPost, "/content", token, content, &result); err != nil {
+			return n, fmt.Errorf("%s: %w", record.Key, err)
+		}
+		if !result.IsSuccessful {
+			return n, fmt.Errorf("%s: %s", record.Key, result.message())
+		}
+		n++
+	}
+	return n, nil
+}
+
+// archerResult is the envelope of Archer API responses.
+type archerResult struct {
+	IsSuccessful    bool `json:"IsSuccessful"`
+	RequestedObject struct {
+		SessionToken string `json:"SessionToken"`
+	} `json:"RequestedObject"`
+	ValidationMessages []struct {
+		Description string `json:"Description"`
+	} `json:"ValidationMessages"`
+}
+
+func (r archerResult) message() string {
+	var messages []string
+	for _, m := range r.ValidationMessages {
+		messages = append(messages, m.Description)
+	}
+	if len(messages) == 0 {
+		return "request was not successful"
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (a *archer) login(ctx context.Context) (string, error) {
+	var result archerResult
+	err := a.call(ctx, http.MethodPost, "/security/login", "", map[string]string{
+		"InstanceName": a.config.Instance,
+		"Username":     a.config.Username,
+		"UserDomain":   a.config.UserDomain,
+		"Password":     a.password,
+	}, &result)
+	if err != nil {
+		return "", err
+	}
+	if !result.IsSuccessful || result.RequestedObject.SessionToken == "" {
+		return "", fmt.Errorf("%s", result.message())
+	}
+	return result.RequestedObject.SessionToken, nil
+}
+
+// logout ends the session; a failure only leaves it to expire.
+func (a *archer) logout(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	a.call(ctx, http.MethodPost, "/security/logout", token, map[string]string{"Value": token}, nil)
+}
+
+func (a *archer) call(ctx context.Context, method, path, token string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json,text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	if token != "" {
+		req.Header.Set("Authorization", `Archer session-id="`+token+`"`)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,147 @@
+package grc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestRecords(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	collector := metrics.NewMetricsCollector()
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Name: "MTTR", Value: 5, Target: 2, Unit: "hours"})
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_Coverage, Name: "Coverage", Value: 97, Target: 95, Unit: "%"})
+	collector.AddMetric(metrics.SecurityMetric{ID: "vendor-risk", Name: "Vendor Risk", Type: metrics.TypeRisk, Value: 40, Target: 50})
+
+	records := Records(collector, now)
+	want := []struct {
+		key    string
+		passed bool
+	}{{"coverage", true}, {"mttr", false}, {"risk_score", false}, {"vendor-risk", true}}
+	if len(records) != len(want) {
+		t.Fatalf("records = %+v", records)
+	}
+	for i, w := range want {
+		if records[i].Key != w.key || records[i].Passed != w.passed {
+			t.Errorf("record %d = %+v, want %s passed=%v", i, records[i], w.key, w.passed)
+		}
+	}
+	if records[2].Value != 40 || records[2].Target != healthyRisk {
+		t.Errorf("risk score = %+v", records[2])
+	}
+}
+
+func TestExporterServiceNow(t *testing.T) {
+	var rows []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if r.URL.Path != "/api/now/table/sn_grc_indicator_result" || user != "secmetrics" || password != "pw" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		var row map[string]string
+		json.NewDecoder(r.Body).Decode(&row)
+		rows = append(rows, row)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	t.Setenv("SN_PASSWORD", "pw")
+
+	exporter, err := NewExporter(ExportConfig{Client: srv.Client(), ServiceNow: &ServiceNowConfig{
+		URL: srv.URL, Username: "secmetrics", PasswordEnv: "SN_PASSWORD",
+		Indicators: map[string]string{"mttr": "ind-1", "risk_score": "ind-2"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exporter.Interval() != DefaultExportInterval {
+		t.Errorf("interval = %s", exporter.Interval())
+	}
+	results := exporter.Push(context.Background(), []Record{
+		{Key: "mttr", Value: 2.5, Target: 2},
+		{Key: "coverage", Value: 97, Passed: true},
+		{Key: "risk_score", Value: 20, Passed: true},
+	})
+	if len(results) != 1 || results[0].Err != nil || results[0].Records != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+	if rows[0]["indicator"] != "ind-1" || rows[0]["value"] != "2.5" || rows[0]["passed"] != "false" ||
+		rows[1]["indicator"] != "ind-2" || rows[1]["passed"] != "true" {
+		t.Errorf("rows = %v", rows)
+	}
+}
+
+func TestExporterArcher(t *testing.T) {
+	var contents []map[string]map[string]interface{}
+	loggedOut := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/RSAarcher/platformapi/core/security/login":
+			var login map[string]string
+			json.NewDecoder(r.Body).Decode(&login)
+			if login["Password"] != "pw" || login["InstanceName"] != "prod" {
+				w.Write([]byte(`{"IsSuccessful": false, "ValidationMessages": [{"Description": "invalid credentials"}]}`))
+				return
+			}
+			w.Write([]byte(`{"IsSuccessful": true, "RequestedObject": {"SessionToken": "sess"}}`))
+		case "/RSAarcher/platformapi/core/content":
+			if r.Header.Get("Authorization") != `Archer session-id="sess"` {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			var body map[string]map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			contents = append(contents, body)
+			w.Write([]byte(`{"IsSuccessful": true, "RequestedObject": {"Id": 1}}`))
+		case "/RSAarcher/platformapi/core/security/logout":
+			loggedOut = true
+			w.Write([]byte(`{"IsSuccessful": true}`))
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("ARCHER_PASSWORD", "pw")
+
+	cfg := ExportConfig{Interval: "6h", Client: srv.Client(), Archer: &ArcherConfig{
+		URL: srv.URL + "/RSAarcher/", Instance: "prod", Username: "svc", PasswordEnv: "ARCHER_PASSWORD",
+		LevelID: 42, Fields: ArcherFields{Key: 101, Value: 102, Status: 103},
+	}}
+	exporter, err := NewExporter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := exporter.Push(context.Background(), []Record{{Key: "mttr", Value: 2.5, Passed: true}})
+	if len(results) != 1 || results[0].Err != nil || results[0].Records != 1 || !loggedOut {
+		t.Fatalf("results = %+v, logged out %v", results, loggedOut)
+	}
+	content := contents[0]["Content"]
+	fields := content["FieldContents"].(map[string]interface{})
+	if content["LevelId"] != 42.0 || len(fields) != 3 {
+		t.Fatalf("content = %v", content)
+	}
+	if value := fields["102"].(map[string]interface{}); value["Type"] != 2.0 || value["Value"] != 2.5 {
+		t.Errorf("value field = %v", value)
+	}
+	if status := fields["103"].(map[string]interface{}); status["Value"] != "On Target" {
+		t.Errorf("status field = %v", status)
+	}
+
+	t.Setenv("ARCHER_PASSWORD", "wrong")
+	exporter, _ = NewExporter(cfg)
+	if results := exporter.Push(context.Background(), nil); results[0].Err == nil {
+		t.Error("push succeeded with invalid credentials")
+	}
+}
+
+func TestNewExporter(t *testing.T) {
+	if exporter, err := NewExporter(ExportConfig{}); exporter != nil || err != nil {
+		t.Errorf("unconfigured exporter = %v, %v", exporter, err)
+	}
+	if _, err := NewExporter(ExportConfig{ServiceNow: &ServiceNowConfig{URL: "https://x", Username: "u", PasswordEnv: "SECMETRICS_TEST_UNSET", Indicators: map[string]string{"mttr": "i"}}}); err == nil {
+		t.Error("missing password accepted")
+	}
+}
@@ -0,0 +1,323 @@
+// Package grc integrates with GRC platforms: it reads control status from
+// Vanta and Drata, uploads secmetrics reports to them as evidence, and
+// pushes KPI values and risk scores into ServiceNow GRC or RSA Archer.
+package grc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/internal/oauth"
+)
+
+// Config configures the GRC platforms.
+type Config struct {
+	Vanta *VantaConfig `yaml:"vanta"`
+	Drata *DrataConfig `yaml:"drata"`
+}
+
+// VantaConfig configures access to Vanta through an OAuth application
+// with client credentials.
+type VantaConfig struct {
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// URL overrides https://api.vanta.com.
+	URL string `yaml:"url"`
+	// DocumentID is the document evidence is uploaded to; evidence is not
+	// uploaded to Vanta without it.
+	DocumentID string `yaml:"document_id"`
+}
+
+// DrataConfig configures access to the Drata public API.
+type DrataConfig struct {
+	APIKey string `yaml:"api_key"`
+	// URL overrides https://public-api.drata.com.
+	URL string `yaml:"url"`
+	// ControlIDs lists the controls evidence is attached to as external
+	// evidence; evidence is not uploaded to Drata without them.
+	ControlIDs []int `yaml:"control_ids"`
+}
+
+// Control is the status of one control on a platform.
+type Control struct {
+	ID   string
+	Code string
+	Name string
+	// Monitored reports whether the platform tests the control; the
+	// status of unmonitored controls is unknown.
+	Monitored bool
+	Passing   bool
+}
+
+// Platform is a GRC platform.
+type Platform interface {
+	// Name returns "vanta" or "drata".
+	Name() string
+	// Controls returns the status of every control.
+	Controls(ctx context.Context) ([]Control, error)
+	// UploadEvidence uploads content as evidence under filename.
+	UploadEvidence(ctx context.Context, filename string, content []byte) error
+}
+
+// New creates the platforms configured in cfg.
+func New(cfg Config, client *http.Client) ([]Platform, error) {
+	var platforms []Platform
+	if cfg.Vanta != nil {
+		if cfg.Vanta.ClientID == "" || cfg.Vanta.ClientSecret == "" {
+			return nil, fmt.Errorf("grc: vanta client_id and client_secret are required")
+		}
+		platforms = append(platforms, newVanta(*cfg.Vanta, client))
+	}
+	if cfg.Drata != nil {
+		if cfg.Drata.APIKey == "" {
+			return nil, fmt.Errorf("grc: drata api_key is required")
+		}
+		platforms = append(platforms, newDrata(*cfg.Drata, client))
+	}
+	if len(platforms) == 0 {
+		return nil, fmt.Errorf("grc: vanta or drata is required")
+	}
+	return platforms, nil
+}
+
+// vanta implements Platform for the Vanta API.
+type vanta struct {
+	config VantaConfig
+	client *http.Client
+	apiURL string
+	tokens *oauth.TokenSource
+}
+
+func newVanta(cfg VantaConfig, client *http.Client) *vanta {
+	apiURL := cfg.URL
+	if apiURL == "" {
+		apiURL = "https://api.vanta.com"
+	}
+	apiURL = strings.TrimRight(apiURL, "/")
+	return &vanta{
+		config: cfg,
+		client: client,
+		apiURL: apiURL,
+		tokens: oauth.NewTokenSource(client, apiURL+"/oauth/token", url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {cfg.ClientID},
+			"client_secret": {cfg.ClientSecret},
+			"scope":         {"vanta-api.all:read vanta-api.all:write"},
+		}),
+	}
+}
+
+func (v *vanta) Name() string { return "vanta" }
+
+// vantaPage is a page of a Vanta list endpoint.
+type vantaPage[T any] struct {
+	Results struct {
+		Data     []T `json:"data"`
+		PageInfo struct {
+			EndCursor   string `json:"endCursor"`
+			HasNextPage bool   `json:"hasNextPage"`
+		} `json:"pageInfo"`
+	} `json:"results"`
+}
+
+// vantaList fetches every item of a cursor-paginated list endpoint.
+func vantaList[T any](ctx context.Context, v *vanta, path string) ([]T, error) {
+	var items []T
+	cursor := ""
+	for {
+		query := url.Values{"pageSize": {"100"}}
+		if cursor != "" {
+			query.Set("pageCursor", cursor)
+		}
+		var page vantaPage[T]
+		if err := v.get(ctx, path+"?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Results.Data...)
+		if !page.Results.PageInfo.HasNextPage || page.Results.PageInfo.EndCursor == "" {
+			return items, nil
+		}
+		cursor = page.Results.PageInfo.EndCursor
+	}
+}
+
+// Controls returns the controls with the status of their tests: a control
+// passes when none of its active tests needs attention.
+func (v *vanta) Controls(ctx context.Context) ([]Control, error) {
+	type vantaControl struct {
+		ID         string `json:"id"`
+		ExternalID string `json:"externalId"`
+		Name       string `json:"name"`
+	}
+	type vantaTest struct {
+		Status string `json:"status"`
+	}
+	list, err := vantaList[vantaControl](ctx, v, "/v1/controls")
+	if err != nil {
+		return nil, err
+	}
+	controls := make([]Control, 0, len(list))
+	for _, c := range list {
+		tests, err := vantaList[vantaTest](ctx, v, "/v1/controls/"+url.PathEscape(c.ID)+"/tests")
+		if err != nil {
+			return nil, fmt.Errorf("control %s: %w", c.ID, err)
+		}
+		control := Control{ID: c.ID, Code: c.ExternalID, Name: c.Name, Passing: true}
+		for _, test := range tests {
+			switch test.Status {
+			case "DEACTIVATED", "NOT_APPLICABLE":
+				continue
+			case "NEEDS_ATTENTION":
+				control.Passing = false
+			}
+			control.Monitored = true
+		}
+		controls = append(controls, control)
+	}
+	return controls, nil
+}
+
+// UploadEvidence uploads content to the configured document.
+func (v *vanta) UploadEvidence(ctx context.Context, filename string, content []byte) error {
+	if v.config.DocumentID == "" {
+		return nil
+	}
+	token, err := v.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	return upload(ctx, v.client, v.apiURL+"/v1/documents/"+url.PathEscape(v.config.DocumentID)+"/uploads",
+		"Bearer "+token, filename, content, map[string]string{"description": "secmetrics " + filename})
+}
+
+func (v *vanta) get(ctx context.Context, path string, out interface{}) error {
+	token, err := v.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	return getJSON(ctx, v.client, v.apiURL+path, "Bearer "+token, out)
+}
+
+// drata implements Platform for the Drata public API.
+type drata struct {
+	config DrataConfig
+	client *http.Client
+	apiURL string
+}
+
+func newDrata(cfg DrataConfig, client *http.Client) *drata {
+	apiURL := cfg.URL
+	if apiURL == "" {
+		apiURL = "https://public-api.drata.com"
+	}
+	return &drata{config: cfg, client: client, apiURL: strings.TrimRight(apiURL, "/")}
+}
+
+func (d *drata) Name() string { return "drata" }
+
+// Controls returns the controls; a control passes when Drata reports it
+// ready, i.e. its tests pass and its evidence is current.
+func (d *drata) Controls(ctx context.Context) ([]Control, error) {
+	var controls []Control
+	for page := 1; ; page++ {
+		var body struct {
+			Data []struct {
+				ID      int    `json:"id"`
+				Code    string `json:"code"`
+				Name    string `json:"name"`
+				IsReady bool   `json:"isReady"`
+			} `json:"data"`
+			Total int `json:"total"`
+		}
+		query := url.Values{"page": {strconv.Itoa(page)}, "limit": {"100"}}
+		if err := getJSON(ctx, d.client, d.apiURL+"/public/controls?"+query.Encode(), "Bearer "+d.config.APIKey, &body); err != nil {
+			return nil, err
+		}
+		for _, c := range body.Data {
+			controls = append(controls, Control{ID: strconv.Itoa(c.ID), Code: c.Code, Name: c.Name, Monitored: true, Passing: c.IsReady})
+		}
+		if len(body.Data) == 0 || len(controls) >= body.Total {
+			return controls, nil
+		}
+	}
+}
+
+// UploadEvidence attaches content as external evidence to each configured
+// control.
+func (d *drata) UploadEvidence(ctx context.Context, filename string, content []byte) error {
+	for _, id := range d.config.ControlIDs {
+		err := upload(ctx, d.client, d.apiURL+"/public/controls/"+strconv.Itoa(id)+"/external-evidence",
+			"Bearer "+d.config.APIKey, filename, content, map[string]string{
+				"name":        filename,
+				"description": "Uploaded by secmetrics",
+			})
+		if err != nil {
+			return fmt.Errorf("control %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// getJSON performs an authorized GET and decodes the JSON response into out.
+func getJSON(ctx context.Context, client *http.Client, url, authorization string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// upload posts content as the "file" part of a multipart form with fields.
+func upload(ctx context.Context, client *http.Client, url, authorization, filename string, content []byte, fields map[string]string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		writer.WriteField(name, value)
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	part.Write(content)
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// checkResponse returns an error for non-2xx responses.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, string(body))
+}
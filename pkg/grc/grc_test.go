@@ -0,0 +1,117 @@
+package grc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVanta(t *testing.T) {
+	var uploaded string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" && r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/oauth/token":
+			fmt.Fprint(w, `{"access_token": "tok", "expires_in": 3600}`)
+		case "/v1/controls":
+			if r.URL.Query().Get("pageCursor") == "" {
+				fmt.Fprint(w, `{"results": {"data": [{"id": "c1", "externalId": "AC-1", "name": "Access reviews"}], "pageInfo": {"endCursor": "p2", "hasNextPage": true}}}`)
+				return
+			}
+			fmt.Fprint(w, `{"results": {"data": [{"id": "c2", "externalId": "CM-6", "name": "Baselines"}, {"id": "c3", "name": "Policy"}], "pageInfo": {"hasNextPage": false}}}`)
+		case "/v1/controls/c1/tests":
+			fmt.Fprint(w, `{"results": {"data": [{"status": "OK"}, {"status": "NEEDS_ATTENTION"}]}}`)
+		case "/v1/controls/c2/tests":
+			fmt.Fprint(w, `{"results": {"data": [{"status": "OK"}, {"status": "DEACTIVATED"}]}}`)
+		case "/v1/controls/c3/tests":
+			fmt.Fprint(w, `{"results": {"data": [{"status": "DEACTIVATED"}]}}`)
+		case "/v1/documents/doc-1/uploads":
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			uploaded = header.Filename + ":" + string(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	platforms, err := New(Config{Vanta: &VantaConfig{ClientID: "id", ClientSecret: "secret", URL: srv.URL, DocumentID: "doc-1"}}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	controls, err := platforms[0].Controls(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Control{
+		{ID: "c1", Code: "AC-1", Name: "Access reviews", Monitored: true, Passing: false},
+		{ID: "c2", Code: "CM-6", Name: "Baselines", Monitored: true, Passing: true},
+		{ID: "c3", Name: "Policy", Monitored: false, Passing: true},
+	}
+	if fmt.Sprint(controls) != fmt.Sprint(want) {
+		t.Errorf("controls = %+v, want %+v", controls, want)
+	}
+	if err := platforms[0].UploadEvidence(context.Background(), "report.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded != "report.json:{}" {
+		t.Errorf("uploaded %q", uploaded)
+	}
+}
+
+func TestDrata(t *testing.T) {
+	var uploads []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/public/controls" && r.URL.Query().Get("page") == "1":
+			fmt.Fprint(w, `{"data": [{"id": 7, "code": "DCF-7", "name": "MFA", "isReady": true}], "total": 2}`)
+		case r.URL.Path == "/public/controls" && r.URL.Query().Get("page") == "2":
+			fmt.Fprint(w, `{"data": [{"id": 9, "code": "DCF-9", "name": "Logging", "isReady": false}], "total": 2}`)
+		case r.Method == http.MethodPost:
+			uploads = append(uploads, r.URL.Path+":"+r.FormValue("name"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	platforms, err := New(Config{Drata: &DrataConfig{APIKey: "key", URL: srv.URL, ControlIDs: []int{7, 9}}}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	controls, err := platforms[0].Controls(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(controls) != 2 || !controls[0].Passing || controls[1].Passing || controls[1].Code != "DCF-9" {
+		t.Errorf("controls = %+v", controls)
+	}
+	if err := platforms[0].UploadEvidence(context.Background(), "report.md", []byte("# KPIs")); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(uploads) != "[/public/controls/7/external-evidence:report.md /public/controls/9/external-evidence:report.md]" {
+		t.Errorf("uploads = %v", uploads)
+	}
+}
+
+func TestNewRequiresCredentials(t *testing.T) {
+	for _, cfg := range []Config{{}, {Vanta: &VantaConfig{ClientID: "id"}}, {Drata: &DrataConfig{}}} {
+		if _, err := New(cfg, http.DefaultClient); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
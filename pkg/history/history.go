@@ -0,0 +1,49 @@
+// Package history exports KPI history as Apache Parquet, one row per
+// sample with the dimensions of its KPI, for analysis in pandas, Spark or
+// DuckDB without scraping the API.
+package history
+
+import (
+	"io"
+
+	"github.com/hallucinaut/secmetrics/internal/parquet"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// Columns are the Parquet columns, in order. target and on_target are null
+// for samples of KPIs that no longer exist.
+var Columns = []string{
+	"timestamp", "kpi_key", "kpi_name", "category", "subcategory", "unit",
+	"direction", "value", "target", "on_target", "archived",
+}
+
+// ExportParquet writes rows as a Parquet file. createdBy names the writing
+// application in the file metadata.
+func ExportParquet(w io.Writer, rows []metrics.HistoryRow, createdBy string) error {
+	n := len(rows)
+	timestamps := make([]int64, n)
+	keys, names, categories, subcategories, units, directions := make([]string, n), make([]string, n), make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	values, targets := make([]float64, n), make([]float64, n)
+	onTarget, hasTarget, archived := make([]bool, n), make([]bool, n), make([]bool, n)
+	for i, row := range rows {
+		timestamps[i] = row.Timestamp.UnixMilli()
+		keys[i], names[i] = string(row.Key), row.Name
+		categories[i], subcategories[i] = row.Category, row.Subcategory
+		units[i], directions[i] = row.Unit, string(row.Direction)
+		values[i], targets[i] = row.Value, row.Target
+		onTarget[i], hasTarget[i], archived[i] = row.OnTarget, row.HasTarget, row.Archived
+	}
+	return parquet.Write(w, []parquet.Column{
+		{Name: Columns[0], Kind: parquet.Timestamp, Int64s: timestamps},
+		{Name: Columns[1], Kind: parquet.String, Strings: keys},
+		{Name: Columns[2], Kind: parquet.String, Strings: names},
+		{Name: Columns[3], Kind: parquet.String, Strings: categories},
+		{Name: Columns[4], Kind: parquet.String, Strings: subcategories},
+		{Name: Columns[5], Kind: parquet.String, Strings: units},
+		{Name: Columns[6], Kind: parquet.String, Strings: directions},
+		{Name: Columns[7], Kind: parquet.Double, Doubles: values},
+		{Name: Columns[8], Kind: parquet.Double, Doubles: targets, Valid: hasTarget},
+		{Name: Columns[9], Kind: parquet.Boolean, Booleans: onTarget, Valid: hasTarget},
+		{Name: Columns[10], Kind: parquet.Boolean, Booleans: archived},
+	}, createdBy)
+}
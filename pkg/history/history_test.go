@@ -0,0 +1,45 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestExportParquet(t *testing.T) {
+	collector := metrics.NewMetricsCollector()
+	day := time.Date(2026, 9, 1, 8, 0, 0, 0, time.UTC)
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 3, Target: 2})
+	collector.AddKPISample(metrics.KPISample{Key: metrics.KPI_MTTR, Value: 1.5, Timestamp: day})
+	collector.AddKPISample(metrics.KPISample{Key: "retired", Value: 7, Timestamp: day})
+
+	samples := append(collector.GetHistory(), metrics.KPISample{Key: metrics.KPI_MTTR, Value: 1.5, Timestamp: day.In(time.FixedZone("CEST", 7200))})
+	rows := collector.HistoryDimensions().Rows(samples)
+	if len(rows) != 3 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if rows[0].Key != metrics.KPI_MTTR || !rows[0].OnTarget || !rows[0].HasTarget || rows[0].Direction != metrics.LowerIsBetter || rows[0].Category != "Response" {
+		t.Errorf("first row = %+v", rows[0])
+	}
+	if rows[1].Key != "retired" || rows[1].HasTarget || rows[1].Name != "retired" || rows[1].Category != metrics.Uncategorized {
+		t.Errorf("row of a removed KPI = %+v", rows[1])
+	}
+
+	var b bytes.Buffer
+	if err := ExportParquet(&b, rows, "secmetrics test"); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("output is not a Parquet file")
+	}
+	footer := data[len(data)-8-int(binary.LittleEndian.Uint32(data[len(data)-8:])) : len(data)-8]
+	for _, column := range Columns {
+		if !bytes.Contains(footer, []byte(column)) {
+			t.Errorf("footer lacks column %s", column)
+		}
+	}
+}
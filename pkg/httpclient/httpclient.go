@@ -0,0 +1,211 @@
+// Package httpclient builds the HTTP client shared by all outbound
+// integrations.
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Config configures outbound HTTP connections.
+type Config struct {
+	// ProxyURL routes requests through an HTTP(S) proxy. When empty the
+	// standard HTTPS_PROXY/HTTP_PROXY/NO_PROXY variables apply.
+	ProxyURL string `yaml:"proxy_url"`
+	// CABundle is a PEM file of additional trusted root certificates.
+	CABundle string `yaml:"ca_bundle"`
+	// TLSMinVersion is "1.2" or "1.3".
+	TLSMinVersion string `yaml:"tls_min_version"`
+	// Timeout bounds each request, e.g. "30s".
+	Timeout string `yaml:"timeout"`
+	// ConnectTimeout bounds establishing a connection, e.g. "10s".
+	ConnectTimeout string `yaml:"connect_timeout"`
+}
+
+// Defaults applied when a setting is not configured.
+const (
+	DefaultTimeout        = 60 * time.Second
+	DefaultConnectTimeout = 10 * time.Second
+)
+
+// New creates an HTTP client from cfg.
+func New(cfg Config) (*http.Client, error) {
+	timeout, err := parseDuration(cfg.Timeout, DefaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("http timeout: %w", err)
+	}
+	connectTimeout, err := parseDuration(cfg.ConnectTimeout, DefaultConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("http connect_timeout: %w", err)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	switch cfg.TLSMinVersion {
+	case "", "1.2":
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported tls_min_version %q", cfg.TLSMinVersion)
+	}
+
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_bundle %s contains no certificates", cfg.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// parseDuration parses s, returning def when s is empty.
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// TLSConfig returns a copy of the TLS configuration of client, a client
+// created by New, for connecting to serverName; for other clients, the
+// defaults of New.
+func TLSConfig(client *http.Client, serverName string) *tls.Config {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if transport, ok := client.Transport.(*http.Transport); ok && transport.TLSClientConfig != nil {
+		config = transport.TLSClientConfig.Clone()
+	}
+	config.ServerName = serverName
+	return config
+}
+
+// DialContext connects to addr, a host and port, like client, a client
+// created by New, connects for HTTPS requests: within its connect timeout
+// and through the proxy it selects for the host, over an HTTP CONNECT
+// tunnel. It is for protocols other than HTTP, such as SMTP.
+func DialContext(ctx context.Context, client *http.Client, addr string) (net.Conn, error) {
+	dial := (&net.Dialer{Timeout: DefaultConnectTimeout}).DialContext
+	var proxy func(*http.Request) (*url.URL, error)
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		if transport.DialContext != nil {
+			dial = transport.DialContext
+		}
+		proxy = transport.Proxy
+	}
+
+	var proxyURL *url.URL
+	if proxy != nil {
+		var err error
+		if proxyURL, err = proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}}); err != nil {
+			return nil, fmt.Errorf("proxy for %s: %w", addr, err)
+		}
+	}
+	if proxyURL == nil {
+		return dial(ctx, "tcp", addr)
+	}
+
+	conn, err := dial(ctx, "tcp", canonicalAddr(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+	if err := connect(ctx, conn, proxyURL, addr); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", proxyURL.Host, err)
+	}
+	return conn, nil
+}
+
+// connect opens a tunnel to addr through the proxy connected on conn.
+func connect(ctx context.Context, conn net.Conn, proxyURL *url.URL, addr string) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	// The tunnel starts right after the response, so it is read byte by
+	// byte rather than through a buffer that could hold tunnelled data.
+	// The body of the response is the tunnel, so it is not read or closed.
+	resp, err := http.ReadResponse(bufio.NewReaderSize(byteReader{conn}, 16), req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	return nil
+}
+
+// byteReader reads one byte at a time.
+type byteReader struct{ r io.Reader }
+
+func (b byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return b.r.Read(p)
+}
+
+// canonicalAddr returns the host and port of a proxy URL, with the default
+// port of its scheme when it has none.
+func canonicalAddr(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
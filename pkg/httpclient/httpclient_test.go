@@ -0,0 +1,260 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// transportOf returns the transport of a client created by New.
+func transportOf(t *testing.T, client *http.Client) *http.Transport {
+	t.Helper()
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport %T", client.Transport)
+	}
+	return transport
+}
+
+func TestNewTimeouts(t *testing.T) {
+	tests := []struct {
+		cfg              Config
+		timeout, connect time.Duration
+	}{
+		{Config{}, DefaultTimeout, DefaultConnectTimeout},
+		{Config{Timeout: "30s", ConnectTimeout: "5s"}, 30 * time.Second, 5 * time.Second},
+	}
+	for _, tc := range tests {
+		client, err := New(tc.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.Timeout != tc.timeout {
+			t.Errorf("%+v: timeout %s, want %s", tc.cfg, client.Timeout, tc.timeout)
+		}
+		if got := transportOf(t, client).TLSHandshakeTimeout; got != tc.connect {
+			t.Errorf("%+v: handshake timeout %s, want %s", tc.cfg, got, tc.connect)
+		}
+	}
+
+	for _, cfg := range []Config{{Timeout: "soon"}, {ConnectTimeout: "10"}} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestNewTLSMinVersion(t *testing.T) {
+	for version, want := range map[string]uint16{"": tls.VersionTLS12, "1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13} {
+		client, err := New(Config{TLSMinVersion: version})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := transportOf(t, client).TLSClientConfig.MinVersion; got != want {
+			t.Errorf("tls_min_version %q: minimum %x, want %x", version, got, want)
+		}
+		if got := TLSConfig(client, "smtp.corp.com"); got.MinVersion != want || got.ServerName != "smtp.corp.com" {
+			t.Errorf("tls_min_version %q: TLSConfig = %x for %q", version, got.MinVersion, got.ServerName)
+		}
+	}
+	if _, err := New(Config{TLSMinVersion: "1.1"}); err == nil {
+		t.Error("accepted tls_min_version 1.1")
+	}
+
+	// A server that only speaks TLS 1.2 is refused under a 1.3 minimum
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+	client, err := New(Config{TLSMinVersion: "1.3", CABundle: writeBundle(t, server)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "protocol version") {
+		t.Errorf("TLS 1.2 server under a 1.3 minimum: %v", err)
+	}
+}
+
+// writeBundle writes the certificate of server to a PEM file.
+func writeBundle(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	untrusted, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := untrusted.Get(server.URL); err == nil {
+		t.Error("trusted the server without the CA bundle")
+	}
+
+	client, err := New(Config{CABundle: writeBundle(t, server)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	bad := filepath.Join(t.TempDir(), "bad.pem")
+	if err := os.WriteFile(bad, []byte("-----BEGIN CERTIFICATE-----\nnot base64\n-----END CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Config{CABundle: bad}); err == nil || !strings.Contains(err.Error(), "contains no certificates") {
+		t.Errorf("bad PEM bundle: %v", err)
+	}
+	if _, err := New(Config{CABundle: filepath.Join(t.TempDir(), "missing.pem")}); err == nil || !strings.Contains(err.Error(), "read ca_bundle") {
+		t.Errorf("missing bundle: %v", err)
+	}
+}
+
+func TestNewProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	client, err := New(Config{ProxyURL: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("http://api.corp.invalid/hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "via proxy" || len(proxied) != 1 || proxied[0] != "http://api.corp.invalid/hosts" {
+		t.Errorf("proxy saw %v, client got %q", proxied, body)
+	}
+
+	if _, err := New(Config{ProxyURL: "http://[::1"}); err == nil {
+		t.Error("accepted an invalid proxy_url")
+	}
+}
+
+// connectProxy returns a proxy that tunnels CONNECT requests and records
+// their Proxy-Authorization headers.
+func connectProxy(t *testing.T, auth *[]string) *httptest.Server {
+	t.Helper()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*auth = append(*auth, r.Header.Get("Proxy-Authorization"))
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, buffered, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			io.Copy(upstream, buffered)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestDialContext(t *testing.T) {
+	// A server that greets first, as SMTP servers do
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("220 ready\r\n"))
+			conn.Close()
+		}
+	}()
+	greeting := func(client *http.Client) string {
+		t.Helper()
+		conn, err := DialContext(context.Background(), client, listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return line
+	}
+
+	direct, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := greeting(direct); got != "220 ready\r\n" {
+		t.Errorf("direct greeting %q", got)
+	}
+
+	var auth []string
+	proxy := connectProxy(t, &auth)
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("svc", "secret")
+	tunnelled, err := New(Config{ProxyURL: proxyURL.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := greeting(tunnelled); got != "220 ready\r\n" {
+		t.Errorf("tunnelled greeting %q", got)
+	}
+	if len(auth) != 1 || auth[0] != "Basic c3ZjOnNlY3JldA==" {
+		t.Errorf("proxy authorization %q", auth)
+	}
+
+	// A proxy that refuses the tunnel fails the dial
+	refusing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer refusing.Close()
+	refused, err := New(Config{ProxyURL: refusing.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DialContext(context.Background(), refused, listener.Addr().String()); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("dial through a refusing proxy: %v", err)
+	}
+}
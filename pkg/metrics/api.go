@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+)
+
+// APIVersion selects how strictly the collector treats its input.
+//
+// Version 1 is the original API: AddMetric, AddKPI and AddKPISample store
+// whatever they are given and cannot fail. The context-aware,
+// error-returning counterparts RecordMetric, RecordKPI and RecordKPISample
+// reject invalid input under either version: unnamed metrics, NaN or
+// infinite values, negative counts or unknown units. A collector set to
+// APIv2 also has them reject undefined KPIs and values out of a KPI's
+// range. The Add methods keep their behavior either way, so input that
+// must be checked goes through the Record methods.
+type APIVersion string
+
+const (
+	// APIv1 is the default compatibility mode: the Record methods check
+	// input with Validate, and KPIs against a strict taxonomy, but accept
+	// KPIs without a definition.
+	APIv1 APIVersion = "v1"
+	// APIv2 also validates KPIs against their definitions.
+	APIv2 APIVersion = "v2"
+)
+
+// ParseAPIVersion parses an API version; an empty version is APIv1.
+func ParseAPIVersion(version string) (APIVersion, error) {
+	switch APIVersion(version) {
+	case "", APIv1:
+		return APIv1, nil
+	case APIv2:
+		return APIv2, nil
+	}
+	return "", fmt.Errorf("invalid api version %q (want v1 or v2)", version)
+}
+
+// SetAPIVersion sets the API version the collector's Record methods and
+// validation follow; collectors follow APIv1 by default.
+func (c *MetricsCollector) SetAPIVersion(version APIVersion) {
+	c.api = version
+}
+
+// strict reports whether the collector follows APIv2.
+func (c *MetricsCollector) strict() bool {
+	return c.api == APIv2
+}
+
+// ValidateMetric checks a metric like SecurityMetric.Validate, also
+// accepting the collector's registered units.
+func (c *MetricsCollector) ValidateMetric(metric SecurityMetric) error {
+	return metric.validate(c.knownUnit)
+}
+
+// CheckMetric checks a metric as RecordMetric does, with ValidateMetric
+// under either API version.
+func (c *MetricsCollector) CheckMetric(metric SecurityMetric) error {
+	return c.ValidateMetric(metric)
+}
+
+// CheckKPI checks a KPI as RecordKPI does: with ValidateKPI under APIv2.
+// Under APIv1 the KPI need not be defined; it is checked like
+// KPI.Validate, with the name, unit and category of its definition if it
+// has one, and against a strict taxonomy. Only a definition with a
+// negative minimum allows negative values.
+func (c *MetricsCollector) CheckKPI(kpi KPI) error {
+	if c.strict() {
+		return c.ValidateKPI(kpi)
+	}
+	def, defined := c.definitions[kpi.Key]
+	c.applyDefinition(&kpi)
+	signed := defined && def.Min != nil && *def.Min < 0
+	if err := kpi.validate(c.knownUnit, !signed); err != nil {
+		return err
+	}
+	return c.ValidateCategory(kpi)
+}
+
+// RecordMetric adds a metric like AddMetric once ctx and CheckMetric
+// pass.
+func (c *MetricsCollector) RecordMetric(ctx context.Context, metric SecurityMetric) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.CheckMetric(metric); err != nil {
+		return err
+	}
+	c.AddMetric(metric)
+	return nil
+}
+
+// RecordKPI adds a KPI like AddKPI once ctx and CheckKPI pass.
+func (c *MetricsCollector) RecordKPI(ctx context.Context, kpi KPI) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.CheckKPI(kpi); err != nil {
+		return err
+	}
+	c.AddKPI(kpi)
+	return nil
+}
+
+// RecordKPISample records a sample like AddKPISample once ctx is checked
+// and its value is a finite number and, under APIv2, within the range of
+// its definition.
+func (c *MetricsCollector) RecordKPISample(ctx context.Context, sample KPISample) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !finite(sample.Value) {
+		return fmt.Errorf("kpi %s: value %v is not a finite number", sample.Key, sample.Value)
+	}
+	if c.strict() {
+		def, ok := c.definitions[sample.Key]
+		if !ok {
+			return fmt.Errorf("kpi %s is not defined", sample.Key)
+		}
+		if err := def.Validate(sample.Value); err != nil {
+			return err
+		}
+	}
+	c.AddKPISample(sample)
+	return nil
+}
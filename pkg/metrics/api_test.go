@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAPIVersions(t *testing.T) {
+	ctx := context.Background()
+	// Invalid under either version
+	invalid := []func(c *MetricsCollector) error{
+		func(c *MetricsCollector) error { return c.RecordMetric(ctx, SecurityMetric{ID: "unnamed", Value: 1}) },
+		func(c *MetricsCollector) error {
+			return c.RecordMetric(ctx, SecurityMetric{Name: "Open findings", Value: math.NaN()})
+		},
+		func(c *MetricsCollector) error {
+			return c.RecordMetric(ctx, SecurityMetric{Name: "Open findings", Value: -2, Unit: "findings"})
+		},
+		func(c *MetricsCollector) error { return c.RecordKPI(ctx, KPI{Key: "undefined", Value: 1}) },
+		func(c *MetricsCollector) error { return c.RecordKPI(ctx, KPI{Key: KPI_MTTR, Value: -3}) },
+		func(c *MetricsCollector) error {
+			return c.RecordKPISample(ctx, KPISample{Key: KPI_MTTR, Value: math.Inf(1)})
+		},
+	}
+	// Invalid under APIv2 only
+	undefined := []func(c *MetricsCollector) error{
+		func(c *MetricsCollector) error {
+			return c.RecordKPI(ctx, KPI{Key: "undefined", Name: "Undefined", Value: 1})
+		},
+		func(c *MetricsCollector) error { return c.RecordKPI(ctx, KPI{Key: KPI_Coverage, Value: 140}) },
+		func(c *MetricsCollector) error { return c.RecordKPISample(ctx, KPISample{Key: "undefined", Value: 1}) },
+	}
+
+	compat := NewMetricsCollector()
+	for i, record := range invalid {
+		if err := record(compat); err == nil {
+			t.Errorf("v1 record %d accepted invalid input", i)
+		}
+	}
+	for i, record := range undefined {
+		if err := record(compat); err != nil {
+			t.Errorf("v1 record %d = %v, want accepted", i, err)
+		}
+	}
+	if len(compat.GetMetrics()) != 0 || len(compat.GetKPIS()) != 2 {
+		t.Errorf("v1 stored %d metrics and %d KPIs, want 0 and 2", len(compat.GetMetrics()), len(compat.GetKPIS()))
+	}
+	// The Add methods store whatever they are given
+	compat.AddMetric(SecurityMetric{ID: "unnamed", Value: 1})
+	if len(compat.GetMetrics()) != 1 {
+		t.Error("v1 AddMetric rejected input")
+	}
+
+	strict := NewMetricsCollector()
+	strict.SetAPIVersion(APIv2)
+	for i, record := range append(invalid, undefined...) {
+		if err := record(strict); err == nil {
+			t.Errorf("v2 record %d accepted invalid input", i)
+		}
+	}
+	if len(strict.GetMetrics()) != 0 || len(strict.GetKPIS()) != 0 || len(strict.history) != 0 {
+		t.Error("v2 stored rejected input")
+	}
+	if err := strict.RecordKPI(ctx, KPI{Key: KPI_Coverage, Value: 92, Target: 95}); err != nil {
+		t.Errorf("v2 RecordKPI = %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := compat.RecordKPI(canceled, KPI{Key: KPI_MTTR, Value: 4}); !errors.Is(err, context.Canceled) {
+		t.Errorf("RecordKPI with a canceled context = %v", err)
+	}
+}
+
+func TestParseAPIVersion(t *testing.T) {
+	for in, want := range map[string]APIVersion{"": APIv1, "v1": APIv1, "v2": APIv2} {
+		if got, err := ParseAPIVersion(in); err != nil || got != want {
+			t.Errorf("ParseAPIVersion(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseAPIVersion("v3"); err == nil {
+		t.Error("ParseAPIVersion(v3) succeeded")
+	}
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchMetrics returns n metrics spread over the metric types.
+func benchMetrics(n int) []SecurityMetric {
+	types := []MetricType{TypeVulnerability, TypeIncident, TypeCompliance, TypeDetection, TypeResponse, TypeRisk}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics := make([]SecurityMetric, n)
+	for i := range metrics {
+		metrics[i] = SecurityMetric{
+			ID:        fmt.Sprintf("m-%d", i),
+			Name:      fmt.Sprintf("metric %d", i%500),
+			Type:      types[i%len(types)],
+			Value:     float64(i % 120),
+			Target:    90,
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	return metrics
+}
+
+// benchCollector returns a collector holding n metrics and a value for each
+// of scoreKeys, categorised by scoreTaxonomy.
+func benchCollector(n int) *MetricsCollector {
+	c := NewMetricsCollector()
+	if err := c.SetTaxonomy(scoreTaxonomy); err != nil {
+		panic(err)
+	}
+	c.Restore(benchMetrics(n), nil, nil)
+	for i, key := range scoreKeys {
+		c.AddKPI(KPI{Key: key, Value: float64(10 * i), Target: 50})
+	}
+	return c
+}
+
+func BenchmarkAddMetric(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		metrics := benchMetrics(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c := NewMetricsCollector()
+				for _, metric := range metrics {
+					c.AddMetric(metric)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRestore(b *testing.B) {
+	metrics := benchMetrics(100000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewMetricsCollector().Restore(metrics, nil, nil)
+	}
+}
+
+func BenchmarkSummary(b *testing.B) {
+	c := benchCollector(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.updateSummary()
+		scores(c)
+	}
+}
@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fiscal year naming conventions.
+const (
+	// FiscalYearEnd names a fiscal year after the calendar year it ends
+	// in, e.g. July 2026 to June 2027 is FY2027.
+	FiscalYearEnd = "end"
+	// FiscalYearStart names a fiscal year after the calendar year it
+	// starts in.
+	FiscalYearStart = "start"
+)
+
+// defaultQuarters are the quarter lengths in months of a calendar without
+// quarter definitions.
+var defaultQuarters = []int{3, 3, 3, 3}
+
+// FiscalCalendar is an organization's fiscal calendar: the month its
+// fiscal year starts and how the year divides into quarters. The zero
+// value is the calendar year with three-month quarters.
+type FiscalCalendar struct {
+	// StartMonth is the first month of the fiscal year, 1 (January,
+	// default) to 12.
+	StartMonth int `yaml:"start_month"`
+	// Quarters are the lengths of the four quarters in months, summing to
+	// 12, e.g. [4, 4, 2, 2]; default 3 months each.
+	Quarters []int `yaml:"quarters"`
+	// YearNaming is end (default) or start, see FiscalYearEnd.
+	YearNaming string `yaml:"year_naming"`
+}
+
+// FiscalQuarter is a quarter of a fiscal calendar, [Start, End) in local
+// time of the calendar's location.
+type FiscalQuarter struct {
+	Year    int
+	Quarter int
+	Start   time.Time
+	End     time.Time
+	// Label is e.g. 2026-Q3 on the calendar year and FY2027-Q1 on a
+	// fiscal year.
+	Label string
+}
+
+// Validate checks the start month, quarter lengths and year naming.
+func (c FiscalCalendar) Validate() error {
+	if c.StartMonth < 0 || c.StartMonth > 12 {
+		return fmt.Errorf("fiscal: start_month %d is not a month from 1 to 12", c.StartMonth)
+	}
+	if len(c.Quarters) > 0 {
+		if len(c.Quarters) != 4 {
+			return fmt.Errorf("fiscal: quarters defines %d quarters, want 4", len(c.Quarters))
+		}
+		total := 0
+		for i, months := range c.Quarters {
+			if months < 1 {
+				return fmt.Errorf("fiscal: quarter %d must be at least 1 month", i+1)
+			}
+			total += months
+		}
+		if total != 12 {
+			return fmt.Errorf("fiscal: quarters add up to %d months, want 12", total)
+		}
+	}
+	switch c.YearNaming {
+	case "", FiscalYearEnd, FiscalYearStart:
+	default:
+		return fmt.Errorf("fiscal: unknown year_naming %q (want end or start)", c.YearNaming)
+	}
+	return nil
+}
+
+// IsCalendarYear reports whether the fiscal calendar is the calendar year
+// with three-month quarters.
+func (c FiscalCalendar) IsCalendarYear() bool {
+	for i, months := range c.quarters() {
+		if months != defaultQuarters[i] {
+			return false
+		}
+	}
+	return c.startMonth() == time.January
+}
+
+func (c FiscalCalendar) startMonth() time.Month {
+	if c.StartMonth == 0 {
+		return time.January
+	}
+	return time.Month(c.StartMonth)
+}
+
+func (c FiscalCalendar) quarters() []int {
+	if len(c.Quarters) == 0 {
+		return defaultQuarters
+	}
+	return c.Quarters
+}
+
+// yearName returns the name of the fiscal year starting in calendar year
+// startYear.
+func (c FiscalCalendar) yearName(startYear int) int {
+	if c.YearNaming == FiscalYearStart || c.startMonth() == time.January {
+		return startYear
+	}
+	return startYear + 1
+}
+
+// quarter returns quarter q, 1 to 4, of the fiscal year starting in
+// calendar year startYear.
+func (c FiscalCalendar) quarter(startYear, q int, loc *time.Location) FiscalQuarter {
+	quarters := c.quarters()
+	offset := 0
+	for _, months := range quarters[:q-1] {
+		offset += months
+	}
+	start := time.Date(startYear, c.startMonth(), 1, 0, 0, 0, 0, loc).AddDate(0, offset, 0)
+	quarter := FiscalQuarter{
+		Year:    c.yearName(startYear),
+		Quarter: q,
+		Start:   start,
+		End:     start.AddDate(0, quarters[q-1], 0),
+	}
+	quarter.Label = fmt.Sprintf("%d-Q%d", quarter.Year, quarter.Quarter)
+	if !c.IsCalendarYear() {
+		quarter.Label = "FY" + quarter.Label
+	}
+	return quarter
+}
+
+// QuarterOf returns the fiscal quarter containing t, in t's location.
+func (c FiscalCalendar) QuarterOf(t time.Time) FiscalQuarter {
+	startYear := t.Year()
+	if t.Month() < c.startMonth() {
+		startYear--
+	}
+	for q := 1; q < 4; q++ {
+		if quarter := c.quarter(startYear, q, t.Location()); t.Before(quarter.End) {
+			return quarter
+		}
+	}
+	return c.quarter(startYear, 4, t.Location())
+}
+
+// Previous returns the fiscal quarter before quarter.
+func (c FiscalCalendar) Previous(quarter FiscalQuarter) FiscalQuarter {
+	return c.QuarterOf(quarter.Start.AddDate(0, 0, -1))
+}
+
+// ParseQuarter parses a fiscal quarter such as 2026-Q3 or FY2027-Q1 in
+// now's location. An empty quarter is the quarter of now.
+func (c FiscalCalendar) ParseQuarter(quarter string, now time.Time) (FiscalQuarter, error) {
+	if quarter == "" {
+		return c.QuarterOf(now), nil
+	}
+	var year, q int
+	value := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(quarter)), "FY")
+	if n, err := fmt.Sscanf(value, "%d-Q%d", &year, &q); err != nil || n != 2 || q < 1 || q > 4 {
+		return FiscalQuarter{}, fmt.Errorf("invalid quarter %q (want YYYY-QN, e.g. 2026-Q3)", quarter)
+	}
+	startYear := year
+	if c.yearName(year) != year {
+		startYear = year - 1
+	}
+	return c.quarter(startYear, q, now.Location()), nil
+}
+
+// QuarterComparison compares a KPI's mean over its fiscal quarter to date
+// with the mean over the whole previous fiscal quarter.
+type QuarterComparison struct {
+	Key             KPIKey
+	Quarter         FiscalQuarter
+	PreviousQuarter FiscalQuarter
+	Current         WindowAggregate
+	Previous        WindowAggregate
+	Delta           float64
+	DeltaPercent    float64
+}
+
+// HasPrevious reports whether the previous quarter contained samples.
+func (q QuarterComparison) HasPrevious() bool {
+	return q.Previous.Count > 0
+}
+
+// SetFiscalCalendar sets the fiscal calendar quarters follow.
+func (c *MetricsCollector) SetFiscalCalendar(calendar FiscalCalendar) error {
+	if err := calendar.Validate(); err != nil {
+		return err
+	}
+	c.fiscal = calendar
+	return nil
+}
+
+// GetFiscalCalendar returns the collector's fiscal calendar.
+func (c *MetricsCollector) GetFiscalCalendar() FiscalCalendar {
+	return c.fiscal
+}
+
+// CompareQuarters compares a KPI's mean over the fiscal quarter containing
+// now, up to now, with its mean over the previous fiscal quarter.
+func (c *MetricsCollector) CompareQuarters(key KPIKey, now time.Time) QuarterComparison {
+	samples := c.GetKPIHistory(key)
+	quarter := c.fiscal.QuarterOf(now)
+	previous := c.fiscal.Previous(quarter)
+	comparison := QuarterComparison{
+		Key:             key,
+		Quarter:         quarter,
+		PreviousQuarter: previous,
+		Current:         AggregateWindow(samples, quarter.Start, now.Add(time.Nanosecond)),
+		Previous:        AggregateWindow(samples, previous.Start, previous.End),
+	}
+	comparison.Delta, comparison.DeltaPercent = compareAggregates(comparison.Current, comparison.Previous)
+	return comparison
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFiscalCalendarQuarterOf(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	for _, tc := range []struct {
+		calendar   FiscalCalendar
+		t          time.Time
+		label      string
+		start, end time.Time
+	}{
+		{FiscalCalendar{}, date(2026, 10, 16), "2026-Q4", date(2026, 10, 1), date(2027, 1, 1)},
+		{FiscalCalendar{StartMonth: 7}, date(2026, 10, 16), "FY2027-Q2", date(2026, 10, 1), date(2027, 1, 1)},
+		{FiscalCalendar{StartMonth: 7}, date(2026, 6, 30), "FY2026-Q4", date(2026, 4, 1), date(2026, 7, 1)},
+		{FiscalCalendar{StartMonth: 2, YearNaming: FiscalYearStart}, date(2027, 1, 31), "FY2026-Q4", date(2026, 11, 1), date(2027, 2, 1)},
+		{FiscalCalendar{Quarters: []int{4, 4, 2, 2}}, date(2026, 8, 31), "FY2026-Q2", date(2026, 5, 1), date(2026, 9, 1)},
+		{FiscalCalendar{Quarters: []int{4, 4, 2, 2}}, date(2026, 12, 1), "FY2026-Q4", date(2026, 11, 1), date(2027, 1, 1)},
+	} {
+		quarter := tc.calendar.QuarterOf(tc.t)
+		if quarter.Label != tc.label || !quarter.Start.Equal(tc.start) || !quarter.End.Equal(tc.end) {
+			t.Errorf("%+v QuarterOf(%s) = %s [%s, %s), want %s [%s, %s)", tc.calendar, tc.t.Format("2006-01-02"),
+				quarter.Label, quarter.Start, quarter.End, tc.label, tc.start, tc.end)
+			continue
+		}
+		parsed, err := tc.calendar.ParseQuarter(tc.label, tc.t)
+		if err != nil || parsed != quarter {
+			t.Errorf("%+v ParseQuarter(%s) = %+v, %v; want %+v", tc.calendar, tc.label, parsed, err, quarter)
+		}
+	}
+
+	calendar := FiscalCalendar{StartMonth: 7}
+	if previous := calendar.Previous(calendar.QuarterOf(date(2026, 7, 1))); previous.Label != "FY2026-Q4" {
+		t.Errorf("Previous = %s, want FY2026-Q4", previous.Label)
+	}
+	for _, bad := range []string{"2026-Q5", "Q3", "2026-09"} {
+		if _, err := calendar.ParseQuarter(bad, time.Now()); err == nil {
+			t.Errorf("ParseQuarter(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFiscalCalendarValidate(t *testing.T) {
+	for _, calendar := range []FiscalCalendar{
+		{StartMonth: 13},
+		{Quarters: []int{6, 6}},
+		{Quarters: []int{3, 3, 3, 4}},
+		{Quarters: []int{0, 4, 4, 4}},
+		{YearNaming: "middle"},
+	} {
+		if err := calendar.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", calendar)
+		}
+	}
+	if err := (FiscalCalendar{StartMonth: 4, Quarters: []int{3, 3, 3, 3}, YearNaming: FiscalYearEnd}).Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestCompareQuarters(t *testing.T) {
+	collector := NewMetricsCollector()
+	if err := collector.SetFiscalCalendar(FiscalCalendar{StartMonth: 7}); err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range []KPISample{
+		{Key: KPI_MTTR, Value: 10, Timestamp: time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{Key: KPI_MTTR, Value: 6, Timestamp: time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)},
+		{Key: KPI_MTTR, Value: 4, Timestamp: time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)},
+		{Key: KPI_MTTR, Value: 99, Timestamp: time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+	} {
+		collector.AddKPISample(sample)
+	}
+
+	comparison := collector.CompareQuarters(KPI_MTTR, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC))
+	if comparison.Quarter.Label != "FY2027-Q2" || comparison.PreviousQuarter.Label != "FY2027-Q1" {
+		t.Fatalf("quarters = %s, %s", comparison.Quarter.Label, comparison.PreviousQuarter.Label)
+	}
+	if !comparison.HasPrevious() || comparison.Current.Mean != 4 || comparison.Previous.Mean != 8 || comparison.Delta != -4 || comparison.DeltaPercent != -50 {
+		t.Errorf("comparison = %+v", comparison)
+	}
+}
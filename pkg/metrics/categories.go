@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+)
+
+// Uncategorized is the category of KPIs that do not declare one.
+const Uncategorized = "Uncategorized"
+
+// CategorySummary summarizes the KPIs in one category.
+type CategorySummary struct {
+	Category string
+	KPIs     int
+	OnTarget int
+	// Score combines the progress of the category's KPIs toward their
+	// targets, from 0 to 100, using the category's aggregation rule.
+	Score         float64
+	Health        string
+	Subcategories []CategorySummary
+}
+
+// categoryProgress is one KPI's contribution to a category score.
+type categoryProgress struct {
+	key      KPIKey
+	progress float64
+	// scored is false when the KPI's progress cannot be measured; see
+	// targetProgress.
+	scored   bool
+	onTarget bool
+}
+
+// GetCategorySummaries returns a summary per KPI category with nested
+// subcategory summaries. Categories follow the taxonomy order when one is
+// set, and are otherwise ordered by name; Uncategorized comes last.
+func (c *MetricsCollector) GetCategorySummaries() []CategorySummary {
+	byCategory := make(map[string][]categoryProgress)
+	bySub := make(map[string]map[string][]categoryProgress)
+	var names []string
+
+	for _, kpi := range c.kpis {
+		if !c.isScored(kpi) {
+			continue
+		}
+		category, sub := c.CategoryPath(kpi)
+		// KPIs without a definition are treated as higher-is-better
+		def := c.definitions[kpi.Key]
+		item := categoryProgress{key: kpi.Key, onTarget: def.MeetsTarget(kpi.Value, kpi.Target)}
+		item.progress, item.scored = targetProgress(kpi.Value, kpi.Target, def.Direction)
+		if _, ok := byCategory[category]; !ok {
+			names = append(names, category)
+			bySub[category] = make(map[string][]categoryProgress)
+		}
+		byCategory[category] = append(byCategory[category], item)
+		if sub != "" {
+			bySub[category][sub] = append(bySub[category][sub], item)
+		}
+	}
+
+	c.sortCategories(names)
+	summaries := make([]CategorySummary, 0, len(names))
+	for _, name := range names {
+		rule, _ := c.taxonomy.category(name)
+		summary := summarizeCategory(name, byCategory[name], rule)
+
+		subNames := make([]string, 0, len(bySub[name]))
+		for sub := range bySub[name] {
+			subNames = append(subNames, sub)
+		}
+		sortSubcategories(subNames, rule)
+		for _, sub := range subNames {
+			summary.Subcategories = append(summary.Subcategories, summarizeCategory(sub, bySub[name][sub], rule))
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// summarizeCategory aggregates items into a summary using rule's
+// aggregation. Items that cannot be scored, or weigh nothing, count toward
+// the KPIs but not the score; a category without any scored item has
+// insufficient data and a score of 0.
+func summarizeCategory(name string, items []categoryProgress, rule TaxonomyCategory) CategorySummary {
+	summary := CategorySummary{Category: name, KPIs: len(items)}
+	var total, weights float64
+	lowest := math.Inf(1)
+
+	for _, item := range items {
+		if item.onTarget {
+			summary.OnTarget++
+		}
+		weight := 1.0
+		if rule.Aggregation == AggregateWeighted {
+			if w, ok := rule.Weights[item.key]; ok {
+				weight = w
+			}
+		}
+		if !item.scored || !finite(weight) || weight <= 0 {
+			continue
+		}
+		total += item.progress * weight
+		weights += weight
+		lowest = math.Min(lowest, item.progress)
+	}
+
+	switch {
+	case weights == 0:
+		summary.Health = InsufficientData
+		return summary
+	case rule.Aggregation == AggregateMin:
+		summary.Score = lowest
+	default:
+		summary.Score = total / weights
+	}
+	summary.Health = ScoreHealth(summary.Score)
+	return summary
+}
+
+// sortCategories orders category names by taxonomy position, or by name
+// without a taxonomy, with Uncategorized last.
+func (c *MetricsCollector) sortCategories(names []string) {
+	position := make(map[string]int)
+	for i, category := range c.taxonomy.Categories {
+		position[category.Name] = i
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == Uncategorized) != (names[j] == Uncategorized) {
+			return names[j] == Uncategorized
+		}
+		pi, iok := position[names[i]]
+		pj, jok := position[names[j]]
+		if iok && jok {
+			return pi < pj
+		}
+		return names[i] < names[j]
+	})
+}
+
+// sortSubcategories orders subcategory names by their position in rule,
+// falling back to name order.
+func sortSubcategories(names []string, rule TaxonomyCategory) {
+	position := make(map[string]int)
+	for i, sub := range rule.Subcategories {
+		position[sub.Name] = i
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, iok := position[names[i]]
+		pj, jok := position[names[j]]
+		if iok && jok {
+			return pi < pj
+		}
+		return names[i] < names[j]
+	})
+}
+
+// KPIProgress returns how close a KPI is to its target as a percentage,
+// capped at 100, or 0 when its progress cannot be measured.
+func (c *MetricsCollector) KPIProgress(kpi KPI) float64 {
+	progress, _ := targetProgress(kpi.Value, kpi.Target, c.definitions[kpi.Key].Direction)
+	return progress
+}
+
+// GetPostureScore returns the mean progress of all active KPIs toward
+// their targets, from 0 to 100, skipping drafts and KPIs whose progress
+// cannot be measured, or 0 when no KPI can be.
+func (c *MetricsCollector) GetPostureScore() float64 {
+	score, _ := c.postureScore()
+	return score
+}
+
+// postureScore returns the posture score and whether any KPI could be
+// scored.
+func (c *MetricsCollector) postureScore() (float64, bool) {
+	var progress runningMean
+	for _, kpi := range c.kpis {
+		if !c.isScored(kpi) {
+			continue
+		}
+		if p, ok := targetProgress(kpi.Value, kpi.Target, c.definitions[kpi.Key].Direction); ok {
+			progress.add(p, 1)
+		}
+	}
+	return progress.mean(), !progress.empty()
+}
+
+// targetProgress returns how close value is to target as a percentage,
+// capped at 100, taking the KPI direction into account. Any value meets a
+// target of zero or less where higher is better, and no value above it
+// makes progress where lower is better. ok is false, and the progress 0,
+// when value or target is not a finite number.
+func targetProgress(value, target float64, direction Direction) (progress float64, ok bool) {
+	if !finite(value) || !finite(target) {
+		return 0, false
+	}
+	if direction != LowerIsBetter {
+		return pillarProgress(value, target), true
+	}
+	if value <= target {
+		return 100, true
+	}
+	if target <= 0 {
+		return 0, true
+	}
+	return target / value * 100, true
+}
+
+// InsufficientData is the health of a score without data to compute it
+// from, e.g. a category whose KPIs all lack a finite value.
+const InsufficientData = "INSUFFICIENT_DATA"
+
+// ScoreHealth maps a 0-100 category or posture score to a health tier, or
+// InsufficientData for a score that is not a finite number.
+func ScoreHealth(score float64) string {
+	if !finite(score) {
+		return InsufficientData
+	}
+	if score >= 90 {
+		return "HEALTHY"
+	} else if score >= 70 {
+		return "GOOD"
+	} else if score >= 50 {
+		return "FAIR"
+	}
+	return "POOR"
+}
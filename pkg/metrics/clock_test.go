@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestCollectorUsesClock(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	collector := NewMetricsCollector()
+	collector.SetClock(clk)
+
+	collector.AddKPI(KPI{Key: KPI_MTTR, Value: 3, Target: 1})
+	clk.Advance(24 * time.Hour)
+	collector.AddKPI(KPI{Key: KPI_MTTR, Value: 2, Target: 1})
+	collector.AddMetric(SecurityMetric{Name: "Open Alerts", Type: TypeDetection, Value: 4})
+
+	history := collector.GetKPIHistory(KPI_MTTR)
+	if len(history) != 2 {
+		t.Fatalf("history has %d samples, want 2", len(history))
+	}
+	if !history[0].Timestamp.Equal(start) || !history[1].Timestamp.Equal(start.Add(24*time.Hour)) {
+		t.Errorf("sample timestamps = %v, %v; want %v, %v", history[0].Timestamp, history[1].Timestamp, start, start.Add(24*time.Hour))
+	}
+	if got := collector.GetMetrics()[0].Timestamp; !got.Equal(clk.Now()) {
+		t.Errorf("metric timestamp = %v, want %v", got, clk.Now())
+	}
+	if got := collector.GetSummary().LastUpdated; !got.Equal(clk.Now()) {
+		t.Errorf("summary LastUpdated = %v, want %v", got, clk.Now())
+	}
+
+	clk.Advance(time.Hour)
+	if !collector.ArchiveKPI(KPI_MTTR) {
+		t.Fatal("ArchiveKPI returned false")
+	}
+	if got := collector.GetArchivedKPIs()[0].ArchivedAt; !got.Equal(clk.Now()) {
+		t.Errorf("ArchivedAt = %v, want %v", got, clk.Now())
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import "strings"
+
+// Criticality is the business criticality tier of an asset. Scores weight
+// findings by the tier of the affected asset, so a gap on a crown jewel
+// counts for more than the same gap on a standard asset.
+type Criticality string
+
+const (
+	CriticalityCrownJewel Criticality = "crown_jewel"
+	CriticalityHigh       Criticality = "high"
+	CriticalityStandard   Criticality = "standard"
+)
+
+// criticalityTiers lists the tiers from most to least critical.
+var criticalityTiers = []Criticality{CriticalityCrownJewel, CriticalityHigh, CriticalityStandard}
+
+// criticalityWeights are the default score weights of the tiers.
+var criticalityWeights = map[Criticality]float64{
+	CriticalityCrownJewel: 4,
+	CriticalityHigh:       2,
+	CriticalityStandard:   1,
+}
+
+// ParseCriticality returns the tier named by s. "crown jewel", "crown-jewel"
+// and "critical" name the crown jewel tier; anything other than a tier,
+// including an empty string, is standard.
+func ParseCriticality(s string) Criticality {
+	switch strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(s))) {
+	case "crown_jewel", "crown_jewels", "critical":
+		return CriticalityCrownJewel
+	case "high":
+		return CriticalityHigh
+	default:
+		return CriticalityStandard
+	}
+}
+
+// Weight returns the score weight of the tier; unknown tiers weigh as
+// standard.
+func (c Criticality) Weight() float64 {
+	return criticalityWeights[ParseCriticality(string(c))]
+}
+
+// CalculateWeightedCoverage calculates security coverage with each asset
+// counted by the weight of its tier, from the summed weights of covered and
+// all assets, or 0 when the total is not a positive finite number.
+func CalculateWeightedCoverage(covered, total float64) float64 {
+	if !finite(covered) || !finite(total) || total <= 0 {
+		return 0.0
+	}
+	return covered / total * 100.0
+}
@@ -0,0 +1,204 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Direction represents which way a KPI value should move to improve.
+type Direction string
+
+const (
+	HigherIsBetter Direction = "higher_is_better"
+	LowerIsBetter  Direction = "lower_is_better"
+)
+
+// KPIDefinition describes a KPI key and how its values are interpreted.
+type KPIDefinition struct {
+	Key         KPIKey
+	Name        string
+	Description string
+	Unit        string
+	Category    string
+	Direction   Direction
+	// Owner is the default owner of KPIs without one.
+	Owner string
+	// Min and Max bound accepted values; nil means unbounded.
+	Min *float64
+	Max *float64
+	// Validator performs additional validation of a value, if set.
+	Validator func(value float64) error
+	// Percentiles lists the percentiles reported for time-based KPIs.
+	Percentiles []float64
+	// WarningThreshold and CriticalThreshold mark the acceptable bands
+	// shown on KPI charts; nil means no band.
+	WarningThreshold  *float64
+	CriticalThreshold *float64
+	// Target overrides the target of added KPIs; nil keeps their own.
+	Target *float64
+	// Lifecycle is the declared lifecycle; empty means active. Transitions
+	// made with TransitionKPI take precedence.
+	Lifecycle Lifecycle
+}
+
+// Validate checks a value against the definition.
+func (d KPIDefinition) Validate(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("kpi %s: value must be a finite number", d.Key)
+	}
+	if d.Min != nil && value < *d.Min {
+		return fmt.Errorf("kpi %s: value %.2f is below minimum %.2f", d.Key, value, *d.Min)
+	}
+	if d.Max != nil && value > *d.Max {
+		return fmt.Errorf("kpi %s: value %.2f is above maximum %.2f", d.Key, value, *d.Max)
+	}
+	if d.Validator != nil {
+		if err := d.Validator(value); err != nil {
+			return fmt.Errorf("kpi %s: %w", d.Key, err)
+		}
+	}
+	return nil
+}
+
+// MeetsTarget reports whether value meets target for the definition's direction.
+func (d KPIDefinition) MeetsTarget(value, target float64) bool {
+	if d.Direction == LowerIsBetter {
+		return value <= target
+	}
+	return value >= target
+}
+
+// Status returns the KPI status for value against target.
+func (d KPIDefinition) Status(value, target float64) string {
+	if d.MeetsTarget(value, target) {
+		return "ON_TARGET"
+	}
+	return "BELOW_TARGET"
+}
+
+// Bound returns a pointer to v for use as a Min or Max bound.
+func Bound(v float64) *float64 {
+	return &v
+}
+
+// builtinDefinitions returns definitions for the built-in KPI keys.
+func builtinDefinitions() []KPIDefinition {
+	return []KPIDefinition{
+		{Key: KPI_MTTR, Name: "Mean Time to Respond (MTTR)", Unit: "hours", Category: "Response", Direction: LowerIsBetter, Min: Bound(0), WarningThreshold: Bound(2), CriticalThreshold: Bound(4)},
+		{Key: KPI_MTTC, Name: "Mean Time to Contain (MTTC)", Unit: "hours", Category: "Response", Direction: LowerIsBetter, Min: Bound(0), WarningThreshold: Bound(4), CriticalThreshold: Bound(8)},
+		{Key: KPI_MTTD, Name: "Mean Time to Detect (MTTD)", Unit: "hours", Category: "Detection", Direction: LowerIsBetter, Min: Bound(0), WarningThreshold: Bound(0.5), CriticalThreshold: Bound(1)},
+		{Key: KPI_Coverage, Name: "Security Coverage", Unit: "%", Category: "Prevention", Direction: HigherIsBetter, Min: Bound(0), Max: Bound(100), WarningThreshold: Bound(90), CriticalThreshold: Bound(75)},
+		{Key: KPI_Compliance, Name: "Compliance Score", Unit: "%", Category: "Compliance", Direction: HigherIsBetter, Min: Bound(0), Max: Bound(100), WarningThreshold: Bound(90), CriticalThreshold: Bound(75)},
+		{Key: KPI_RemediationRate, Name: "Vulnerability Remediation Rate", Unit: "%", Category: "Remediation", Direction: HigherIsBetter, Min: Bound(0), Max: Bound(100), WarningThreshold: Bound(85), CriticalThreshold: Bound(70)},
+		{Key: KPI_DetectionRate, Name: "Detection Rate", Unit: "%", Category: "Detection", Direction: HigherIsBetter, Min: Bound(0), Max: Bound(100), WarningThreshold: Bound(90), CriticalThreshold: Bound(75)},
+		{Key: KPI_AlertMTTA, Name: "Mean Time to Acknowledge (Alerts)", Unit: "hours", Category: "Response", Direction: LowerIsBetter, Min: Bound(0)},
+		{Key: KPI_ResponseTime, Name: "Response Time", Unit: "hours", Category: "Response", Direction: LowerIsBetter, Min: Bound(0), WarningThreshold: Bound(2), CriticalThreshold: Bound(4)},
+		{Key: KPI_DeviceCompliance, Name: "Device Compliance", Unit: "%", Category: "Zero Trust", Direction: HigherIsBetter, Min: Bound(0), Max: Bound(100), WarningThreshold: Bound(90), CriticalThreshold: Bound(75)},
+		{Key: KPI_MFACoverage, Name: "MFA Coverage", Unit: "%", Category: "Zero Trust", Direction: HigherIsBetter, Min: Bound(0), Max: Bound(100), WarningThreshold: Bound(95), CriticalThreshold: Bound(85)},
+		{Key: KPI_NetworkSegmentation, Name: "Network Segmentation", Unit: "%", Category: "Zero Trust", Direction: HigherIsBetter, Min: Bound(0), Max: Bound(100), WarningThreshold: Bound(80), CriticalThreshold: Bound(60)},
+		{Key: KPI_LeastPrivilege, Name: "Least-Privilege Access", Unit: "%", Category: "Zero Trust", Direction: HigherIsBetter, Min: Bound(0), Max: Bound(100), WarningThreshold: Bound(85), CriticalThreshold: Bound(70)},
+	}
+}
+
+// RegisterKPIDefinition registers a new KPI definition with the collector.
+func (c *MetricsCollector) RegisterKPIDefinition(def KPIDefinition) error {
+	if def.Key == "" {
+		return fmt.Errorf("kpi definition requires a key")
+	}
+	if _, exists := c.definitions[def.Key]; exists {
+		return fmt.Errorf("kpi %s is already defined", def.Key)
+	}
+	return c.SetKPIDefinition(def)
+}
+
+// SetKPIDefinition registers def, replacing any existing definition of its
+// key.
+func (c *MetricsCollector) SetKPIDefinition(def KPIDefinition) error {
+	if def.Key == "" {
+		return fmt.Errorf("kpi definition requires a key")
+	}
+	if def.Direction == "" {
+		def.Direction = HigherIsBetter
+	}
+	if def.Direction != HigherIsBetter && def.Direction != LowerIsBetter {
+		return fmt.Errorf("kpi %s: unknown direction %q", def.Key, def.Direction)
+	}
+	if def.Min != nil && def.Max != nil && *def.Min > *def.Max {
+		return fmt.Errorf("kpi %s: minimum exceeds maximum", def.Key)
+	}
+	if _, err := ParseLifecycle(string(def.Lifecycle)); err != nil {
+		return fmt.Errorf("kpi %s: %w", def.Key, err)
+	}
+	c.definitions[def.Key] = def
+	return nil
+}
+
+// GetKPIDefinition retrieves a KPI definition by key.
+func (c *MetricsCollector) GetKPIDefinition(key KPIKey) (KPIDefinition, bool) {
+	def, ok := c.definitions[key]
+	return def, ok
+}
+
+// GetKPIDefinitions returns all registered KPI definitions sorted by key.
+func (c *MetricsCollector) GetKPIDefinitions() []KPIDefinition {
+	defs := make([]KPIDefinition, 0, len(c.definitions))
+	for _, def := range c.definitions {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Key < defs[j].Key })
+	return defs
+}
+
+// ValidateKPI validates a KPI value against its registered definition,
+// the KPI with the definition applied like KPI.Validate, and its category
+// against a strict taxonomy. Negative values are accepted when the
+// definition sets a minimum, and a KPI where higher is better needs a
+// positive target to measure progress toward.
+func (c *MetricsCollector) ValidateKPI(kpi KPI) error {
+	def, ok := c.definitions[kpi.Key]
+	if !ok {
+		return fmt.Errorf("kpi %s is not defined", kpi.Key)
+	}
+	if err := def.Validate(kpi.Value); err != nil {
+		return err
+	}
+	c.applyDefinition(&kpi)
+	if err := kpi.validate(c.knownUnit, def.Min == nil); err != nil {
+		return err
+	}
+	if def.Direction != LowerIsBetter && kpi.Target <= 0 {
+		return fmt.Errorf("kpi %s: target %.2f must be positive, since progress is measured as a share of it", kpi.Key, kpi.Target)
+	}
+	return c.ValidateCategory(kpi)
+}
+
+// applyDefinition fills empty KPI fields from its registered definition.
+func (c *MetricsCollector) applyDefinition(kpi *KPI) {
+	def, ok := c.definitions[kpi.Key]
+	if !ok {
+		return
+	}
+	if kpi.Name == "" {
+		kpi.Name = def.Name
+	}
+	if kpi.Description == "" {
+		kpi.Description = def.Description
+	}
+	if kpi.Unit == "" {
+		kpi.Unit = def.Unit
+	}
+	if kpi.Category == "" {
+		kpi.Category = def.Category
+	}
+	if kpi.Owner == "" {
+		kpi.Owner = def.Owner
+	}
+	if def.Target != nil && *def.Target != kpi.Target {
+		kpi.Target = *def.Target
+		kpi.Status = ""
+	}
+	if kpi.Status == "" {
+		kpi.Status = def.Status(kpi.Value, kpi.Target)
+	}
+}
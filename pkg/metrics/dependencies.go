@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KPIDependency records that an upstream KPI influences a downstream KPI.
+type KPIDependency struct {
+	Upstream   KPIKey
+	Downstream KPIKey
+	// Reason explains how the upstream KPI affects the downstream one.
+	Reason string
+}
+
+// builtinDependencies returns the relationships between the built-in KPIs.
+func builtinDependencies() []KPIDependency {
+	return []KPIDependency{
+		{Upstream: KPI_Coverage, Downstream: KPI_DetectionRate, Reason: "assets without controls produce no detections"},
+		{Upstream: KPI_Coverage, Downstream: KPI_MTTD, Reason: "coverage gaps delay detection"},
+		{Upstream: KPI_DetectionRate, Downstream: KPI_MTTD, Reason: "missed detections are found late"},
+		{Upstream: KPI_MTTD, Downstream: KPI_MTTR, Reason: "response starts only once an incident is detected"},
+		{Upstream: KPI_MTTD, Downstream: KPI_MTTC, Reason: "containment starts only once an incident is detected"},
+		{Upstream: KPI_ResponseTime, Downstream: KPI_MTTR, Reason: "slow triage delays response"},
+		{Upstream: KPI_MTTR, Downstream: KPI_MTTC, Reason: "containment follows response"},
+		{Upstream: KPI_RemediationRate, Downstream: KPI_Compliance, Reason: "unremediated vulnerabilities fail compliance controls"},
+		{Upstream: KPI_DeviceCompliance, Downstream: KPI_Compliance, Reason: "non-compliant devices fail compliance controls"},
+		{Upstream: KPI_LeastPrivilege, Downstream: KPI_Compliance, Reason: "excess privileges fail access control requirements"},
+	}
+}
+
+// AddKPIDependency records that dep.Upstream influences dep.Downstream.
+// Dependencies that would create a cycle are rejected.
+func (c *MetricsCollector) AddKPIDependency(dep KPIDependency) error {
+	if dep.Upstream == "" || dep.Downstream == "" {
+		return fmt.Errorf("kpi dependency: upstream and downstream keys are required")
+	}
+	if dep.Upstream == dep.Downstream {
+		return fmt.Errorf("kpi dependency: %s cannot depend on itself", dep.Upstream)
+	}
+	for _, existing := range c.dependencies {
+		if existing.Upstream == dep.Upstream && existing.Downstream == dep.Downstream {
+			return nil
+		}
+	}
+	if _, ok := c.upstreamPaths(dep.Upstream)[dep.Downstream]; ok {
+		return fmt.Errorf("kpi dependency: %s -> %s would create a cycle", dep.Upstream, dep.Downstream)
+	}
+	c.dependencies = append(c.dependencies, dep)
+	return nil
+}
+
+// GetKPIDependencies returns the KPI dependency graph edges.
+func (c *MetricsCollector) GetKPIDependencies() []KPIDependency {
+	return c.dependencies
+}
+
+// upstreamPaths returns every KPI upstream of key, mapped to the shortest
+// path from that KPI down to key.
+func (c *MetricsCollector) upstreamPaths(key KPIKey) map[KPIKey][]KPIKey {
+	paths := map[KPIKey][]KPIKey{key: {key}}
+	queue := []KPIKey{key}
+
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, dep := range c.dependencies {
+			if _, seen := paths[dep.Upstream]; seen || dep.Downstream != next {
+				continue
+			}
+			paths[dep.Upstream] = append([]KPIKey{dep.Upstream}, paths[next]...)
+			queue = append(queue, dep.Upstream)
+		}
+	}
+	delete(paths, key)
+	return paths
+}
+
+// RootCauseHint identifies a degraded upstream KPI that likely contributed
+// to another KPI's degradation.
+type RootCauseHint struct {
+	KPI KPI
+	// Path runs from the upstream KPI down to the explained KPI.
+	Path    []KPIKey
+	Reasons []string
+}
+
+// KPIExplanation describes a KPI's state and its place in the dependency graph.
+type KPIExplanation struct {
+	KPI        KPI
+	Degraded   bool
+	Reasons    []string
+	Upstream   []KPIDependency
+	Downstream []KPIDependency
+	// RootCauses lists degraded upstream KPIs, nearest first; it is only
+	// populated when the KPI itself is degraded.
+	RootCauses []RootCauseHint
+}
+
+// ExplainKPI explains a collected KPI, surfacing degraded upstream KPIs as
+// root-cause hints when the KPI is degraded. Degradation is judged against
+// the KPI's target and its 7-day window comparison as of now.
+func (c *MetricsCollector) ExplainKPI(key KPIKey, now time.Time) (*KPIExplanation, error) {
+	kpi := c.GetKPI(key)
+	if kpi == nil {
+		return nil, fmt.Errorf("kpi %s has not been collected", key)
+	}
+
+	explanation := &KPIExplanation{KPI: *kpi, Reasons: c.degradation(*kpi, now)}
+	explanation.Degraded = len(explanation.Reasons) > 0
+	for _, dep := range c.dependencies {
+		if dep.Downstream == key {
+			explanation.Upstream = append(explanation.Upstream, dep)
+		}
+		if dep.Upstream == key {
+			explanation.Downstream = append(explanation.Downstream, dep)
+		}
+	}
+	if !explanation.Degraded {
+		return explanation, nil
+	}
+
+	paths := c.upstreamPaths(key)
+	for _, upstreamKey := range orderedByDistance(paths) {
+		upstream := c.GetKPI(upstreamKey)
+		if upstream == nil {
+			continue
+		}
+		if reasons := c.degradation(*upstream, now); len(reasons) > 0 {
+			explanation.RootCauses = append(explanation.RootCauses, RootCauseHint{
+				KPI:     *upstream,
+				Path:    paths[upstreamKey],
+				Reasons: reasons,
+			})
+		}
+	}
+	return explanation, nil
+}
+
+// degradation returns the reasons a KPI is degraded, if any.
+func (c *MetricsCollector) degradation(kpi KPI, now time.Time) []string {
+	var reasons []string
+	def, ok := c.GetKPIDefinition(kpi.Key)
+
+	belowTarget := kpi.Status == "BELOW_TARGET"
+	if ok {
+		belowTarget = !def.MeetsTarget(kpi.Value, kpi.Target)
+	}
+	if belowTarget {
+		reasons = append(reasons, fmt.Sprintf("below target (%g vs %g %s)", kpi.Value, kpi.Target, kpi.Unit))
+	}
+
+	comparison := c.CompareWindows(kpi.Key, Window7Days, now)
+	if comparison.HasPrevious() && comparison.Current.Count > 0 {
+		worse := comparison.Delta < 0
+		if ok && def.Direction == LowerIsBetter {
+			worse = comparison.Delta > 0
+		}
+		if worse {
+			reasons = append(reasons, fmt.Sprintf("worsened over the last %s (%+.1f, %+.1f%%)", comparison.Window.Name, comparison.Delta, comparison.DeltaPercent))
+		}
+	}
+	return reasons
+}
+
+// orderedByDistance returns the keys of paths ordered by path length,
+// breaking ties by key.
+func orderedByDistance(paths map[KPIKey][]KPIKey) []KPIKey {
+	keys := make([]KPIKey, 0, len(paths))
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(paths[keys[i]]) != len(paths[keys[j]]) {
+			return len(paths[keys[i]]) < len(paths[keys[j]])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// FormatExplanation formats an explanation as the explain command prints
+// it, naming related KPIs as collected.
+func (c *MetricsCollector) FormatExplanation(explanation *KPIExplanation) string {
+	kpi := explanation.KPI
+	var b strings.Builder
+
+	title := "KPI Explanation: " + kpi.Name
+	fmt.Fprintln(&b, title)
+	fmt.Fprintln(&b, strings.Repeat("=", len(title)))
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "Value: %.1f %s (target %.1f %s)\n", kpi.Value, kpi.Unit, kpi.Target, kpi.Unit)
+	fmt.Fprintf(&b, "Status: %s\n", kpi.Status)
+	if explanation.Degraded {
+		fmt.Fprintln(&b, "Degraded:")
+		for _, reason := range explanation.Reasons {
+			fmt.Fprintf(&b, "  • %s\n", reason)
+		}
+	} else {
+		fmt.Fprintln(&b, "Degraded: no")
+	}
+	fmt.Fprintln(&b)
+
+	if len(explanation.Upstream) > 0 {
+		fmt.Fprintln(&b, "Depends on:")
+		for _, dep := range explanation.Upstream {
+			fmt.Fprintf(&b, "  • %s: %s\n", c.kpiName(dep.Upstream), dep.Reason)
+		}
+		fmt.Fprintln(&b)
+	}
+
+	if explanation.Degraded {
+		if len(explanation.RootCauses) > 0 {
+			fmt.Fprintln(&b, "Likely contributors:")
+			for i, hint := range explanation.RootCauses {
+				fmt.Fprintf(&b, "  [%d] %s: %s\n", i+1, hint.KPI.Name, strings.Join(hint.Reasons, "; "))
+				fmt.Fprintf(&b, "      via %s\n", joinKeys(hint.Path))
+			}
+		} else {
+			fmt.Fprintln(&b, "Likely contributors: none of the upstream KPIs are degraded")
+		}
+		fmt.Fprintln(&b)
+	}
+
+	if len(explanation.Downstream) > 0 {
+		fmt.Fprintln(&b, "Affects:")
+		for _, dep := range explanation.Downstream {
+			fmt.Fprintf(&b, "  • %s: %s\n", c.kpiName(dep.Downstream), dep.Reason)
+		}
+	}
+	return b.String()
+}
+
+// kpiName returns the display name of key, falling back to the key itself.
+func (c *MetricsCollector) kpiName(key KPIKey) string {
+	if kpi := c.GetKPI(key); kpi != nil {
+		return kpi.Name
+	}
+	if def, ok := c.GetKPIDefinition(key); ok {
+		return def.Name
+	}
+	return string(key)
+}
+
+// joinKeys formats a dependency path as "a -> b -> c".
+func joinKeys(keys []KPIKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = string(key)
+	}
+	return strings.Join(parts, " -> ")
+}
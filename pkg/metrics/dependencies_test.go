@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestAddKPIDependencyRejectsCycles(t *testing.T) {
+	collector := NewMetricsCollector()
+	edges := len(collector.GetKPIDependencies())
+
+	for _, dep := range []KPIDependency{
+		{Upstream: KPI_MTTR, Downstream: KPI_MTTR},
+		// mttd -> mttr is built in
+		{Upstream: KPI_MTTR, Downstream: KPI_MTTD},
+		{Upstream: KPI_MTTR},
+	} {
+		if err := collector.AddKPIDependency(dep); err == nil {
+			t.Errorf("added %s -> %s", dep.Upstream, dep.Downstream)
+		}
+	}
+	// coverage -> mttd -> mttc
+	if err := collector.AddKPIDependency(KPIDependency{Upstream: KPI_MTTC, Downstream: KPI_Coverage}); err == nil || !strings.Contains(err.Error(), "would create a cycle") {
+		t.Errorf("transitive cycle: %v", err)
+	}
+	if got := len(collector.GetKPIDependencies()); got != edges {
+		t.Fatalf("rejected dependencies changed the graph to %d edges, want %d", got, edges)
+	}
+
+	// A known edge is accepted once, and a new one extends the graph
+	if err := collector.AddKPIDependency(KPIDependency{Upstream: KPI_MTTD, Downstream: KPI_MTTR}); err != nil {
+		t.Fatal(err)
+	}
+	if err := collector.AddKPIDependency(KPIDependency{Upstream: KPI_Compliance, Downstream: KPI_Coverage, Reason: "audits find gaps"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(collector.GetKPIDependencies()); got != edges+1 {
+		t.Errorf("graph has %d edges, want %d", got, edges+1)
+	}
+	// which now closes remediation_rate -> compliance -> coverage
+	if err := collector.AddKPIDependency(KPIDependency{Upstream: KPI_Coverage, Downstream: KPI_RemediationRate}); err == nil {
+		t.Error("added coverage -> remediation_rate")
+	}
+}
+
+func TestExplainKPIOrdersRootCauses(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	collector := NewMetricsCollector()
+	collector.SetClock(clock.NewFake(now))
+
+	// Response time meets its target but doubled from the previous week
+	collector.AddKPISample(KPISample{Key: KPI_ResponseTime, Value: 0.75, Timestamp: now.Add(-10 * 24 * time.Hour)})
+	for _, kpi := range []KPI{
+		{Key: KPI_MTTC, Value: 10, Target: 4},
+		{Key: KPI_MTTR, Value: 6, Target: 4},
+		{Key: KPI_MTTD, Value: 8, Target: 2},
+		{Key: KPI_ResponseTime, Value: 1.5, Target: 2},
+		{Key: KPI_DetectionRate, Value: 96, Target: 95},
+		{Key: KPI_Coverage, Value: 85, Target: 95},
+		{Key: KPI_Compliance, Value: 98, Target: 95},
+		{Key: KPI_RemediationRate, Value: 50, Target: 90},
+	} {
+		collector.AddKPI(kpi)
+	}
+
+	explanation, err := collector.ExplainKPI(KPI_MTTC, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nearest first, ties by key; detection_rate is on target
+	want := []struct {
+		key  KPIKey
+		path string
+	}{
+		{KPI_MTTD, "mttd -> mttc"},
+		{KPI_MTTR, "mttr -> mttc"},
+		{KPI_Coverage, "coverage -> mttd -> mttc"},
+		{KPI_ResponseTime, "response_time -> mttr -> mttc"},
+	}
+	if !explanation.Degraded || len(explanation.RootCauses) != len(want) {
+		t.Fatalf("root causes of mttc: %+v", explanation.RootCauses)
+	}
+	for i, hint := range explanation.RootCauses {
+		if hint.KPI.Key != want[i].key || joinKeys(hint.Path) != want[i].path {
+			t.Errorf("hint %d = %s via %s, want %s via %s", i+1, hint.KPI.Key, joinKeys(hint.Path), want[i].key, want[i].path)
+		}
+	}
+	if reasons := explanation.RootCauses[3].Reasons; len(reasons) != 1 || !strings.HasPrefix(reasons[0], "worsened over the last 7 days (+0.8, +100.0%)") {
+		t.Errorf("response time reasons %q", reasons)
+	}
+
+	// A KPI on target gets no hints, even with degraded upstream KPIs
+	if explanation, err := collector.ExplainKPI(KPI_Compliance, now); err != nil || explanation.Degraded || explanation.RootCauses != nil || len(explanation.Upstream) != 3 {
+		t.Errorf("compliance explanation %+v, %v", explanation, err)
+	}
+	if _, err := collector.ExplainKPI(KPI_LeastPrivilege, now); err == nil {
+		t.Error("explained a KPI that was not collected")
+	}
+}
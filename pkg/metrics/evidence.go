@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// EvidenceKind is the kind of reference an Evidence holds.
+type EvidenceKind string
+
+const (
+	// EvidenceHash is the digest of an artifact such as a scan file, as
+	// "sha256:<hex>".
+	EvidenceHash EvidenceKind = "hash"
+	// EvidenceURL is a web reference such as a ticket.
+	EvidenceURL EvidenceKind = "url"
+	// EvidencePath is the path of a file such as a screenshot.
+	EvidencePath EvidenceKind = "path"
+)
+
+// AuditMetricEvidence is the audit action of attaching evidence to a metric.
+const AuditMetricEvidence = "metric.evidence"
+
+// digestLengths are the hex lengths of the digests hash evidence may use.
+var digestLengths = map[string]int{"sha1": 40, "sha256": 64, "sha384": 96, "sha512": 128}
+
+// Evidence references an artifact backing a metric value, so the value can
+// be traced to its source. Only the reference is stored, not the artifact.
+type Evidence struct {
+	Kind EvidenceKind
+	Ref  string
+	// Note describes the artifact, e.g. "Q3 external scan".
+	Note string
+}
+
+// Validate checks that the reference suits its kind: a hash is a known
+// digest in hex, a URL is absolute http or https, and a path is not empty.
+func (e Evidence) Validate() error {
+	switch e.Kind {
+	case EvidenceHash:
+		algorithm, digest, _ := strings.Cut(e.Ref, ":")
+		length, ok := digestLengths[algorithm]
+		if _, err := hex.DecodeString(digest); !ok || err != nil || len(digest) != length {
+			return fmt.Errorf("evidence hash %q: want <algorithm>:<hex digest> with sha1, sha256, sha384 or sha512", e.Ref)
+		}
+	case EvidenceURL:
+		u, err := url.Parse(e.Ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("evidence url %q: want an absolute http or https URL", e.Ref)
+		}
+	case EvidencePath:
+		if e.Ref == "" {
+			return fmt.Errorf("evidence path is empty")
+		}
+	default:
+		return fmt.Errorf("unknown evidence kind %q (want hash, url or path)", e.Kind)
+	}
+	return nil
+}
+
+// AttachEvidence attaches evidence to the latest sample of the metric with
+// id and records the attachment in the audit trail as made by actor.
+// Evidence already attached to the sample is not attached twice.
+func (c *MetricsCollector) AttachEvidence(id string, evidence Evidence, actor string) error {
+	if err := evidence.Validate(); err != nil {
+		return fmt.Errorf("metric %s: %w", id, err)
+	}
+	latest := -1
+	for i := range c.metrics {
+		if c.metrics[i].ID == id && (latest < 0 || !c.metrics[i].Timestamp.Before(c.metrics[latest].Timestamp)) {
+			latest = i
+		}
+	}
+	if latest < 0 {
+		return fmt.Errorf("metric %s not found", id)
+	}
+	metric := &c.metrics[latest]
+	for _, attached := range metric.Evidence {
+		if attached.Kind == evidence.Kind && attached.Ref == evidence.Ref {
+			return nil
+		}
+	}
+	// The sample may share its evidence with copies handed out earlier.
+	metric.Evidence = append(metric.Evidence[:len(metric.Evidence):len(metric.Evidence)], evidence)
+	c.audit = append(c.audit, AuditEntry{
+		Time:    c.now(),
+		Actor:   actor,
+		Action:  AuditMetricEvidence,
+		Subject: id,
+		To:      string(evidence.Kind) + " " + evidence.Ref,
+	})
+	return nil
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestEvidenceValidate(t *testing.T) {
+	for _, evidence := range []Evidence{
+		{Kind: EvidenceHash, Ref: "sha256:" + strings.Repeat("0f", 32)},
+		{Kind: EvidenceURL, Ref: "https://jira.example.com/browse/SEC-42"},
+		{Kind: EvidencePath, Ref: "evidence/mfa-policy.png"},
+	} {
+		if err := evidence.Validate(); err != nil {
+			t.Errorf("%+v: %v", evidence, err)
+		}
+	}
+	for _, evidence := range []Evidence{
+		{Kind: EvidenceHash, Ref: "sha256:abc"},
+		{Kind: EvidenceHash, Ref: "md5:" + strings.Repeat("0f", 16)},
+		{Kind: EvidenceURL, Ref: "jira.example.com/browse/SEC-42"},
+		{Kind: EvidencePath},
+		{Kind: "screenshot", Ref: "mfa-policy.png"},
+	} {
+		if err := evidence.Validate(); err == nil {
+			t.Errorf("%+v accepted", evidence)
+		}
+	}
+	metric := SecurityMetric{Name: "CIS pass rate", Evidence: []Evidence{{Kind: EvidenceURL, Ref: "SEC-42"}}}
+	if err := metric.Validate(); err == nil {
+		t.Error("metric with invalid evidence accepted")
+	}
+}
+
+func TestAttachEvidence(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	collector := NewMetricsCollector()
+	collector.SetClock(clk)
+	collector.AddMetric(SecurityMetric{ID: "cis", Name: "CIS pass rate", Type: TypeCompliance, Value: 88, Target: 90})
+	clk.Advance(time.Hour)
+	collector.AddMetric(SecurityMetric{ID: "cis", Name: "CIS pass rate", Type: TypeCompliance, Value: 92, Target: 90})
+	before := collector.GetMetrics()[1]
+
+	ticket := Evidence{Kind: EvidenceURL, Ref: "https://jira.example.com/browse/SEC-42", Note: "Remediation ticket"}
+	if err := collector.AttachEvidence("cis", ticket, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	// Attaching the same reference again changes nothing
+	if err := collector.AttachEvidence("cis", ticket, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	samples := collector.GetMetrics()
+	if len(samples[0].Evidence) != 0 || len(samples[1].Evidence) != 1 || samples[1].Evidence[0] != ticket {
+		t.Errorf("evidence attached to %+v, want the latest sample only", samples)
+	}
+	if len(before.Evidence) != 0 {
+		t.Error("evidence changed an earlier copy of the sample")
+	}
+	audit := collector.GetAuditTrail()
+	if len(audit) != 1 || audit[0].Action != AuditMetricEvidence || audit[0].Subject != "cis" || audit[0].Actor != "alice" {
+		t.Errorf("audit trail = %+v", audit)
+	}
+
+	if err := collector.AttachEvidence("unknown", ticket, "alice"); err == nil {
+		t.Error("evidence attached to an unknown metric")
+	}
+	if err := collector.AttachEvidence("cis", Evidence{Kind: EvidenceHash, Ref: "deadbeef"}, "alice"); err == nil {
+		t.Error("invalid evidence attached")
+	}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import "time"
+
+// ForecastWindow is how much recent history a KPI forecast is fitted to.
+const ForecastWindow = 90 * 24 * time.Hour
+
+// ForecastHorizon caps the estimated time to target.
+const ForecastHorizon = 5 * 365 * 24 * time.Hour
+
+// minForecastSamples is the fewest samples a forecast is fitted to.
+const minForecastSamples = 3
+
+// KPIForecast is a linear forecast of a KPI, fitted by least squares to
+// its samples within ForecastWindow.
+type KPIForecast struct {
+	Key KPIKey
+	// Samples is the number of samples within the window. Fitted reports
+	// whether they allowed a fit: at least three, at different times.
+	Samples int
+	Fitted  bool
+	// Slope is the fitted change of the value per day.
+	Slope float64
+	// OnTarget reports that the current value already meets the target.
+	OnTarget bool
+	// Converging reports that the value is moving toward its target.
+	// TimeToTarget is then the estimated time until it meets it at the
+	// fitted rate, at most ForecastHorizon.
+	Converging   bool
+	TimeToTarget time.Duration
+}
+
+// ForecastKPI forecasts when kpi meets its target from its history up to
+// now.
+func (c *MetricsCollector) ForecastKPI(kpi KPI, now time.Time) KPIForecast {
+	def, ok := c.definitions[kpi.Key]
+	if !ok {
+		def = KPIDefinition{Key: kpi.Key, Direction: HigherIsBetter}
+	}
+	forecast := KPIForecast{Key: kpi.Key, OnTarget: def.MeetsTarget(kpi.Value, kpi.Target)}
+
+	// Least squares fit of value over days since the window start
+	start := now.Add(-ForecastWindow)
+	var n, sumX, sumY, sumXX, sumXY float64
+	for _, sample := range c.GetKPIHistory(kpi.Key) {
+		if sample.Timestamp.Before(start) || sample.Timestamp.After(now) || !finite(sample.Value) {
+			continue
+		}
+		x := sample.Timestamp.Sub(start).Hours() / 24
+		n++
+		sumX += x
+		sumY += sample.Value
+		sumXX += x * x
+		sumXY += x * sample.Value
+	}
+	forecast.Samples = int(n)
+	denominator := n*sumXX - sumX*sumX
+	if forecast.Samples < minForecastSamples || denominator == 0 {
+		return forecast
+	}
+	forecast.Fitted = true
+	forecast.Slope = (n*sumXY - sumX*sumY) / denominator
+	if forecast.OnTarget || forecast.Slope == 0 {
+		return forecast
+	}
+
+	days := (kpi.Target - kpi.Value) / forecast.Slope
+	if days > 0 && finite(days) {
+		forecast.Converging = true
+		forecast.TimeToTarget = ForecastHorizon
+		if days*24 < ForecastHorizon.Hours() {
+			forecast.TimeToTarget = time.Duration(days * float64(24*time.Hour))
+		}
+	}
+	return forecast
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestForecastKPI(t *testing.T) {
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	collector := NewMetricsCollector()
+	// MTTR falls by 0.1 hours a day; coverage rises by 0.5 points a day
+	for day := 10; day >= 0; day-- {
+		at := now.AddDate(0, 0, -day)
+		collector.AddKPISample(KPISample{Key: KPI_MTTR, Value: 4 + 0.1*float64(day), Timestamp: at})
+		collector.AddKPISample(KPISample{Key: KPI_Coverage, Value: 80 + 0.5*float64(day), Timestamp: at})
+	}
+	collector.AddKPISample(KPISample{Key: KPI_MTTD, Value: 2, Timestamp: now})
+
+	forecast := collector.ForecastKPI(KPI{Key: KPI_MTTR, Value: 4, Target: 1}, now)
+	if !forecast.Fitted || !forecast.Converging || forecast.Samples != 11 {
+		t.Fatalf("MTTR forecast = %+v, want a converging fit of 11 samples", forecast)
+	}
+	if got := forecast.TimeToTarget.Hours() / 24; got < 29.9 || got > 30.1 {
+		t.Errorf("MTTR time to target = %.2f days, want 30", got)
+	}
+
+	// Coverage falls while higher is better
+	if forecast := collector.ForecastKPI(KPI{Key: KPI_Coverage, Value: 80, Target: 95}, now); !forecast.Fitted || forecast.Converging {
+		t.Errorf("coverage forecast = %+v, want a diverging fit", forecast)
+	}
+	if forecast := collector.ForecastKPI(KPI{Key: KPI_Coverage, Value: 96, Target: 95}, now); !forecast.OnTarget {
+		t.Errorf("coverage forecast = %+v, want on target", forecast)
+	}
+	if forecast := collector.ForecastKPI(KPI{Key: KPI_MTTD, Value: 2, Target: 1}, now); forecast.Fitted {
+		t.Errorf("MTTD forecast = %+v, want no fit from one sample", forecast)
+	}
+
+	// Very slow progress is capped at the horizon
+	slow := NewMetricsCollector()
+	for day := 0; day < 3; day++ {
+		slow.AddKPISample(KPISample{Key: KPI_Coverage, Value: 50 + 0.0001*float64(day), Timestamp: now.AddDate(0, 0, day-2)})
+	}
+	if forecast := slow.ForecastKPI(KPI{Key: KPI_Coverage, Value: 50, Target: 100}, now); forecast.TimeToTarget != ForecastHorizon {
+		t.Errorf("slow forecast = %+v, want the horizon", forecast)
+	}
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// KPISample represents a single historical observation of a KPI.
+type KPISample struct {
+	Key       KPIKey
+	Value     float64
+	Timestamp time.Time
+}
+
+// Window represents a rolling aggregation window.
+type Window struct {
+	Name   string
+	Length time.Duration
+}
+
+// The rolling windows KPIs are compared over. Window91Days spans about a
+// quarter but does not follow calendar or fiscal quarters; see
+// FiscalCalendar for those.
+var (
+	Window7Days  = Window{Name: "7 days", Length: 7 * 24 * time.Hour}
+	Window30Days = Window{Name: "30 days", Length: 30 * 24 * time.Hour}
+	Window91Days = Window{Name: "91 days", Length: 91 * 24 * time.Hour}
+)
+
+// WindowAggregate summarizes KPI samples within [Start, End).
+type WindowAggregate struct {
+	Start time.Time
+	End   time.Time
+	Count int
+	Mean  float64
+	Min   float64
+	Max   float64
+	Last  float64
+}
+
+// WindowComparison compares the current window with the previous one.
+type WindowComparison struct {
+	Key          KPIKey
+	Window       Window
+	Current      WindowAggregate
+	Previous     WindowAggregate
+	Delta        float64
+	DeltaPercent float64
+}
+
+// HasPrevious reports whether the previous window contained samples.
+func (w WindowComparison) HasPrevious() bool {
+	return w.Previous.Count > 0
+}
+
+// AddKPISample records a historical KPI sample.
+func (c *MetricsCollector) AddKPISample(sample KPISample) {
+	if sample.Timestamp.IsZero() {
+		sample.Timestamp = c.now()
+	}
+	c.history = append(c.history, sample)
+}
+
+// GetKPIHistory returns the samples for a KPI ordered by time.
+func (c *MetricsCollector) GetKPIHistory(key KPIKey) []KPISample {
+	var result []KPISample
+	for _, sample := range c.history {
+		if sample.Key == key {
+			result = append(result, sample)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result
+}
+
+// AggregateWindow aggregates samples with timestamps in [start, end).
+func AggregateWindow(samples []KPISample, start, end time.Time) WindowAggregate {
+	agg := WindowAggregate{Start: start, End: end}
+	var total float64
+	var last time.Time
+
+	for _, sample := range samples {
+		if sample.Timestamp.Before(start) || !sample.Timestamp.Before(end) {
+			continue
+		}
+		if agg.Count == 0 || sample.Value < agg.Min {
+			agg.Min = sample.Value
+		}
+		if agg.Count == 0 || sample.Value > agg.Max {
+			agg.Max = sample.Value
+		}
+		if agg.Count == 0 || !sample.Timestamp.Before(last) {
+			agg.Last = sample.Value
+			last = sample.Timestamp
+		}
+		total += sample.Value
+		agg.Count++
+	}
+
+	if agg.Count > 0 {
+		agg.Mean = total / float64(agg.Count)
+	}
+	return agg
+}
+
+// CompareWindows compares a KPI's mean over the window ending at now with
+// the mean over the window immediately before it.
+func (c *MetricsCollector) CompareWindows(key KPIKey, window Window, now time.Time) WindowComparison {
+	samples := c.GetKPIHistory(key)
+	currentStart := now.Add(-window.Length)
+	comparison := WindowComparison{
+		Key:      key,
+		Window:   window,
+		Current:  AggregateWindow(samples, currentStart, now.Add(time.Nanosecond)),
+		Previous: AggregateWindow(samples, currentStart.Add(-window.Length), currentStart),
+	}
+
+	comparison.Delta, comparison.DeltaPercent = compareAggregates(comparison.Current, comparison.Previous)
+	return comparison
+}
+
+// compareAggregates returns the change of the mean from previous to
+// current, absolute and in percent; zero unless both have samples.
+func compareAggregates(current, previous WindowAggregate) (float64, float64) {
+	if current.Count == 0 || previous.Count == 0 {
+		return 0, 0
+	}
+	delta := current.Mean - previous.Mean
+	if previous.Mean == 0 {
+		return delta, 0
+	}
+	return delta, delta / previous.Mean * 100.0
+}
+
+// GetHistory returns all recorded KPI samples.
+func (c *MetricsCollector) GetHistory() []KPISample {
+	return c.history
+}
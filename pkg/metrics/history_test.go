@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAggregateWindowBoundaries(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(7 * 24 * time.Hour)
+	samples := []KPISample{
+		{Key: KPI_MTTR, Value: 100, Timestamp: start.Add(-time.Nanosecond)},
+		// Samples out of order; the latest one is Last
+		{Key: KPI_MTTR, Value: 6, Timestamp: start.Add(48 * time.Hour)},
+		{Key: KPI_MTTR, Value: 2, Timestamp: start},
+		{Key: KPI_MTTR, Value: 4, Timestamp: end.Add(-time.Nanosecond)},
+		{Key: KPI_MTTR, Value: 200, Timestamp: end},
+	}
+
+	agg := AggregateWindow(samples, start, end)
+	want := WindowAggregate{Start: start, End: end, Count: 3, Mean: 4, Min: 2, Max: 6, Last: 4}
+	if agg != want {
+		t.Errorf("AggregateWindow = %+v, want %+v", agg, want)
+	}
+
+	if empty := AggregateWindow(samples, end.Add(time.Hour), end.Add(2*time.Hour)); empty.Count != 0 || empty.Mean != 0 {
+		t.Errorf("empty window = %+v", empty)
+	}
+}
+
+func TestCompareWindowsWithThePreviousWindow(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	c := NewMetricsCollector()
+	for _, sample := range []KPISample{
+		// Previous window: [now-14d, now-7d)
+		{Value: 100, Timestamp: now.Add(-14*24*time.Hour - time.Nanosecond)},
+		{Value: 4, Timestamp: now.Add(-14 * 24 * time.Hour)},
+		{Value: 6, Timestamp: now.Add(-10 * 24 * time.Hour)},
+		// Current window: [now-7d, now], including now
+		{Value: 2, Timestamp: now.Add(-7 * 24 * time.Hour)},
+		{Value: 4, Timestamp: now},
+		{Value: 200, Timestamp: now.Add(time.Nanosecond)},
+	} {
+		sample.Key = KPI_MTTR
+		c.AddKPISample(sample)
+	}
+
+	comparison := c.CompareWindows(KPI_MTTR, Window7Days, now)
+	if comparison.Current.Count != 2 || comparison.Current.Mean != 3 {
+		t.Errorf("current window = %+v", comparison.Current)
+	}
+	if comparison.Previous.Count != 2 || comparison.Previous.Mean != 5 || !comparison.HasPrevious() {
+		t.Errorf("previous window = %+v", comparison.Previous)
+	}
+	if comparison.Delta != -2 || math.Abs(comparison.DeltaPercent+40) > 1e-9 {
+		t.Errorf("delta = %v (%v%%), want -2 (-40%%)", comparison.Delta, comparison.DeltaPercent)
+	}
+
+	// Without a previous window there is no delta
+	if comparison := c.CompareWindows(KPI_MTTR, Window91Days, now.Add(-60*24*time.Hour)); comparison.HasPrevious() || comparison.Delta != 0 {
+		t.Errorf("comparison without history = %+v", comparison)
+	}
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// HistoryRow is one KPI sample joined with the dimensions of its KPI, as a
+// row of a flat history table for BI and analysis tools.
+type HistoryRow struct {
+	Timestamp   time.Time
+	Key         KPIKey
+	Name        string
+	Category    string
+	Subcategory string
+	Unit        string
+	Direction   Direction
+	Value       float64
+	// Target and OnTarget are only meaningful when HasTarget is set, i.e.
+	// the KPI is current or archived.
+	Target    float64
+	OnTarget  bool
+	HasTarget bool
+	Archived  bool
+}
+
+// HistoryDimensions captures the dimensions of every current and archived
+// KPI, to build history rows from samples read without holding the
+// collector.
+type HistoryDimensions struct {
+	kpis        map[KPIKey]KPI
+	definitions map[KPIKey]KPIDefinition
+	paths       map[KPIKey][2]string
+}
+
+// HistoryDimensions returns the dimensions of the collector's KPIs.
+func (c *MetricsCollector) HistoryDimensions() HistoryDimensions {
+	dims := HistoryDimensions{
+		kpis:        make(map[KPIKey]KPI),
+		definitions: make(map[KPIKey]KPIDefinition),
+		paths:       make(map[KPIKey][2]string),
+	}
+	for _, kpi := range c.GetArchivedKPIs() {
+		dims.kpis[kpi.Key] = kpi
+	}
+	for _, kpi := range c.GetKPIS() {
+		dims.kpis[kpi.Key] = kpi
+	}
+	for key, kpi := range dims.kpis {
+		if def, ok := c.GetKPIDefinition(key); ok {
+			dims.definitions[key] = def
+		}
+		category, sub := c.CategoryPath(kpi)
+		dims.paths[key] = [2]string{category, sub}
+	}
+	return dims
+}
+
+// Rows joins samples with their KPI's dimensions, dropping duplicate
+// samples of a KPI at the same time, and sorts them by time then key.
+// Samples of KPIs that no longer exist keep their key as name and are
+// uncategorized.
+func (d HistoryDimensions) Rows(samples []KPISample) []HistoryRow {
+	type sampleID struct {
+		key KPIKey
+		at  int64
+	}
+	seen := make(map[sampleID]bool)
+	rows := make([]HistoryRow, 0, len(samples))
+	for _, sample := range samples {
+		id := sampleID{sample.Key, sample.Timestamp.UnixNano()}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		kpi, known := d.kpis[sample.Key]
+		def, defined := d.definitions[sample.Key]
+		row := HistoryRow{
+			Timestamp: sample.Timestamp.UTC(),
+			Key:       sample.Key,
+			Name:      kpi.Name,
+			Unit:      kpi.Unit,
+			Direction: HigherIsBetter,
+			Value:     sample.Value,
+			Archived:  kpi.IsArchived(),
+		}
+		if defined {
+			row.Direction = def.Direction
+			if row.Name == "" {
+				row.Name = def.Name
+			}
+			if row.Unit == "" {
+				row.Unit = def.Unit
+			}
+		}
+		if row.Name == "" {
+			row.Name = string(sample.Key)
+		}
+		row.Category = Uncategorized
+		if known {
+			path := d.paths[sample.Key]
+			row.Category, row.Subcategory = path[0], path[1]
+			row.Target = kpi.Target
+			row.OnTarget = KPIDefinition{Direction: row.Direction}.MeetsTarget(sample.Value, kpi.Target)
+			row.HasTarget = true
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Timestamp.Equal(rows[j].Timestamp) {
+			return rows[i].Timestamp.Before(rows[j].Timestamp)
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
+}
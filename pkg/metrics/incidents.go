@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Incident represents a security incident and its lifecycle timestamps.
+// ContainedAt and ResolvedAt are zero until reached.
+type Incident struct {
+	ID          string
+	Title       string
+	Severity    string
+	Status      string
+	Source      string
+	DetectedAt  time.Time
+	ContainedAt time.Time
+	ResolvedAt  time.Time
+}
+
+// Alert represents a security alert. AcknowledgedAt and ResolvedAt are
+// zero until reached; IncidentID is set when the alert was escalated and
+// TicketID when a tracking ticket was opened for it, with TicketClosedAt
+// set once that ticket is closed.
+type Alert struct {
+	ID             string
+	Name           string
+	Severity       string
+	Source         string
+	FiredAt        time.Time
+	AcknowledgedAt time.Time
+	ResolvedAt     time.Time
+	IncidentID     string
+	TicketID       string
+	TicketClosedAt time.Time
+}
+
+// AddIncident adds an incident, replacing any incident with the same ID.
+func (c *MetricsCollector) AddIncident(incident Incident) error {
+	if incident.ID == "" {
+		return fmt.Errorf("incident requires an id")
+	}
+	if incident.DetectedAt.IsZero() {
+		return fmt.Errorf("incident %s requires a detection time", incident.ID)
+	}
+	for i, existing := range c.incidents {
+		if existing.ID == incident.ID {
+			c.incidents[i] = incident
+			return nil
+		}
+	}
+	c.incidents = append(c.incidents, incident)
+	return nil
+}
+
+// AddAlert adds an alert, replacing any alert with the same ID.
+func (c *MetricsCollector) AddAlert(alert Alert) error {
+	if alert.ID == "" {
+		return fmt.Errorf("alert requires an id")
+	}
+	if alert.FiredAt.IsZero() {
+		return fmt.Errorf("alert %s requires a fired time", alert.ID)
+	}
+	for i, existing := range c.alerts {
+		if existing.ID == alert.ID {
+			c.alerts[i] = alert
+			return nil
+		}
+	}
+	c.alerts = append(c.alerts, alert)
+	return nil
+}
+
+// GetIncidents returns all incidents ordered by detection time.
+func (c *MetricsCollector) GetIncidents() []Incident {
+	incidents := append([]Incident(nil), c.incidents...)
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].DetectedAt.Before(incidents[j].DetectedAt)
+	})
+	return incidents
+}
+
+// GetAlerts returns all alerts ordered by fired time.
+func (c *MetricsCollector) GetAlerts() []Alert {
+	alerts := append([]Alert(nil), c.alerts...)
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].FiredAt.Before(alerts[j].FiredAt)
+	})
+	return alerts
+}
+
+// RestoreEvents replaces the collector's incidents and alerts with
+// previously saved ones.
+func (c *MetricsCollector) RestoreEvents(incidents []Incident, alerts []Alert) {
+	c.incidents = append(make([]Incident, 0, len(incidents)), incidents...)
+	c.alerts = append(make([]Alert, 0, len(alerts)), alerts...)
+}
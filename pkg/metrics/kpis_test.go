@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestAddKPIReplacesByKey(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	collector := NewMetricsCollector()
+	collector.SetClock(clk)
+
+	// Three collection runs report the same KPIs again
+	for run, mttr := range []float64{6, 5, 3} {
+		collector.AddKPI(KPI{Key: KPI_MTTR, Value: mttr, Target: 4})
+		collector.AddKPI(KPI{Key: KPI_Coverage, Value: 90 + float64(run), Target: 95})
+		clk.Advance(time.Hour)
+	}
+
+	if kpis := collector.GetKPIS(); len(kpis) != 2 || kpis[0].Key != KPI_MTTR || kpis[1].Key != KPI_Coverage {
+		t.Fatalf("KPIs = %+v, want mttr and coverage once each", kpis)
+	}
+	if kpi := collector.GetKPI(KPI_MTTR); kpi.Value != 3 || kpi.Status != "ON_TARGET" || !kpi.LastUpdated.Equal(time.Date(2026, 10, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("mttr = %+v, want the latest run", kpi)
+	}
+	if summary := collector.GetSummary(); summary.TotalKPIS != 2 {
+		t.Errorf("TotalKPIS = %d, want 2", summary.TotalKPIS)
+	}
+	if history := collector.GetKPIHistory(KPI_MTTR); len(history) != 3 || history[0].Value != 6 || history[2].Value != 3 {
+		t.Errorf("mttr history = %+v, want every run", history)
+	}
+
+	// A backfilled sample goes to history without changing the current value
+	collector.AddKPISample(KPISample{Key: KPI_MTTR, Value: 9, Timestamp: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)})
+	if kpi := collector.GetKPI(KPI_MTTR); kpi.Value != 3 || len(collector.GetKPIHistory(KPI_MTTR)) != 4 {
+		t.Errorf("after AddKPISample mttr = %v with %d samples", kpi.Value, len(collector.GetKPIHistory(KPI_MTTR)))
+	}
+}
+
+func TestGetKPISReturnsACopy(t *testing.T) {
+	collector := NewMetricsCollector()
+	collector.AddKPI(KPI{Key: KPI_MTTR, Value: 5, Target: 4})
+	kpis := collector.GetKPIS()
+
+	// AddKPI updates the KPI in place, which must not show in the copy
+	collector.AddKPI(KPI{Key: KPI_MTTR, Value: 3, Target: 4})
+	if kpis[0].Value != 5 {
+		t.Errorf("copied mttr = %v, want 5", kpis[0].Value)
+	}
+	kpis[0].Value = 100
+	if kpi := collector.GetKPI(KPI_MTTR); kpi.Value != 3 {
+		t.Errorf("mttr = %v after changing the copy, want 3", kpi.Value)
+	}
+}
+
+func TestRestoreKeepsLatestDuplicateKPI(t *testing.T) {
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	collector := NewMetricsCollector()
+	collector.Restore(nil, []KPI{
+		{Key: KPI_MTTR, Value: 6, Target: 4, LastUpdated: at},
+		{Key: KPI_Coverage, Value: 90, Target: 95, LastUpdated: at},
+		{Key: KPI_MTTR, Value: 3, Target: 4, LastUpdated: at.Add(time.Hour)},
+		{Key: KPI_Coverage, Value: 80, Target: 95, LastUpdated: at.Add(-time.Hour)},
+	}, nil)
+
+	kpis := collector.GetKPIS()
+	if len(kpis) != 2 || kpis[0].Key != KPI_MTTR || kpis[0].Value != 3 || kpis[1].Value != 90 {
+		t.Errorf("restored KPIs = %+v, want the latest mttr and coverage", kpis)
+	}
+}
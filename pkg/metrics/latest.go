@@ -0,0 +1,77 @@
+package metrics
+
+import "sync"
+
+// metricName identifies the observations of one metric.
+type metricName struct {
+	typ  MetricType
+	name string
+}
+
+// latestMetrics tracks the position of the latest observation of each
+// metric name, in the order the names were first seen. It is built on
+// first use, so restoring a store costs nothing extra, and then kept up to
+// date as metrics are added, so reports need not rescan every metric. mu
+// guards the build against concurrent readers.
+type latestMetrics struct {
+	mu        sync.Mutex
+	built     bool
+	positions []int
+	index     map[metricName]int
+}
+
+// reset discards the tracked positions after metrics were replaced or
+// removed.
+func (l *latestMetrics) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.built, l.positions, l.index = false, nil, nil
+}
+
+// add accounts for the metric at position i of metrics, once built.
+func (l *latestMetrics) add(metrics []SecurityMetric, i int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.built {
+		l.track(metrics, i)
+	}
+}
+
+// track accounts for the metric at position i of metrics.
+func (l *latestMetrics) track(metrics []SecurityMetric, i int) {
+	key := metricName{metrics[i].Type, metrics[i].Name}
+	n, ok := l.index[key]
+	if !ok {
+		l.index[key] = len(l.positions)
+		l.positions = append(l.positions, i)
+		return
+	}
+	if !metrics[i].Timestamp.Before(metrics[l.positions[n]].Timestamp) {
+		l.positions[n] = i
+	}
+}
+
+// of returns the latest observations among metrics, building the
+// positions if needed.
+func (l *latestMetrics) of(metrics []SecurityMetric) []SecurityMetric {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.built {
+		l.index = make(map[metricName]int)
+		for i := range metrics {
+			l.track(metrics, i)
+		}
+		l.built = true
+	}
+	latest := make([]SecurityMetric, 0, len(l.positions))
+	for _, i := range l.positions {
+		latest = append(latest, metrics[i])
+	}
+	return latest
+}
+
+// GetLatestMetrics returns the latest observation of each metric, by type
+// and name, in the order the metrics were first collected.
+func (c *MetricsCollector) GetLatestMetrics() []SecurityMetric {
+	return c.latest.of(c.metrics)
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetLatestMetrics(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	collector := NewMetricsCollector()
+	collector.Restore([]SecurityMetric{
+		{ID: "a1", Name: "Open findings", Type: TypeVulnerability, Value: 10, Timestamp: start},
+		{ID: "b1", Name: "SOC 2", Type: TypeCompliance, Value: 80, Target: 100, Timestamp: start},
+		{ID: "a2", Name: "Open findings", Type: TypeVulnerability, Value: 8, Timestamp: start.Add(time.Hour)},
+	}, nil, nil)
+	// A late-arriving older observation does not replace the latest
+	collector.AddMetric(SecurityMetric{ID: "a0", Name: "Open findings", Type: TypeVulnerability, Value: 12, Timestamp: start.Add(-time.Hour)})
+
+	ids := func() []string {
+		var ids []string
+		for _, metric := range collector.GetLatestMetrics() {
+			ids = append(ids, metric.ID)
+		}
+		return ids
+	}
+	if got := ids(); len(got) != 2 || got[0] != "a2" || got[1] != "b1" {
+		t.Fatalf("latest = %v, want [a2 b1]", got)
+	}
+
+	collector.AddMetric(SecurityMetric{ID: "b2", Name: "SOC 2", Type: TypeCompliance, Value: 85, Target: 100, Timestamp: start.Add(2 * time.Hour)})
+	collector.AddMetric(SecurityMetric{ID: "c1", Name: "Open findings", Type: TypeRisk, Value: 30, Timestamp: start})
+	if got := ids(); len(got) != 3 || got[0] != "a2" || got[1] != "b2" || got[2] != "c1" {
+		t.Errorf("latest = %v, want [a2 b2 c1]", got)
+	}
+
+	collector.RemoveMetric("a2")
+	if got := ids(); len(got) != 3 || got[0] != "a1" {
+		t.Errorf("latest after removal = %v, want a1 first", got)
+	}
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Lifecycle is the stage of a KPI: draft KPIs are piloted without counting
+// toward scores, active KPIs count, and deprecated KPIs are hidden from
+// reports while their history is retained.
+type Lifecycle string
+
+const (
+	LifecycleDraft      Lifecycle = "draft"
+	LifecycleActive     Lifecycle = "active"
+	LifecycleDeprecated Lifecycle = "deprecated"
+)
+
+// ParseLifecycle parses a lifecycle name; empty means active.
+func ParseLifecycle(name string) (Lifecycle, error) {
+	switch Lifecycle(name) {
+	case "", LifecycleActive:
+		return LifecycleActive, nil
+	case LifecycleDraft, LifecycleDeprecated:
+		return Lifecycle(name), nil
+	}
+	return "", fmt.Errorf("unknown lifecycle %q (want draft, active or deprecated)", name)
+}
+
+// AuditKPILifecycle is the audit action of a KPI lifecycle transition.
+const AuditKPILifecycle = "kpi.lifecycle"
+
+// AuditEntry records a change made to the collector, such as a KPI
+// lifecycle transition, and who made it.
+type AuditEntry struct {
+	Time    time.Time
+	Actor   string
+	Action  string
+	Subject string
+	From    string
+	To      string
+}
+
+// KPILifecycle returns the lifecycle of key: the last transition made with
+// TransitionKPI, or else the one its definition declares, or active.
+func (c *MetricsCollector) KPILifecycle(key KPIKey) Lifecycle {
+	if lifecycle, ok := c.lifecycles[key]; ok {
+		return lifecycle
+	}
+	if lifecycle, err := ParseLifecycle(string(c.definitions[key].Lifecycle)); err == nil {
+		return lifecycle
+	}
+	return LifecycleActive
+}
+
+// isScored reports whether kpi counts toward scores; drafts and stale KPIs
+// do not.
+func (c *MetricsCollector) isScored(kpi KPI) bool {
+	return !kpi.Stale && c.KPILifecycle(kpi.Key) != LifecycleDraft
+}
+
+// TransitionKPI moves a known KPI to next and records the transition in the
+// audit trail as made by actor. Deprecating a KPI removes it from the active
+// set; values added while it is deprecated are recorded in history only.
+// Transitioning to the current lifecycle does nothing.
+func (c *MetricsCollector) TransitionKPI(key KPIKey, next Lifecycle, actor string) error {
+	if next != LifecycleDraft && next != LifecycleActive && next != LifecycleDeprecated {
+		return fmt.Errorf("kpi %s: unknown lifecycle %q", key, next)
+	}
+	_, defined := c.definitions[key]
+	if !defined && c.GetKPI(key) == nil && !c.isArchived(key) && len(c.GetKPIHistory(key)) == 0 {
+		return fmt.Errorf("kpi %s not found", key)
+	}
+	current := c.KPILifecycle(key)
+	if current == next {
+		return nil
+	}
+
+	c.lifecycles[key] = next
+	c.audit = append(c.audit, AuditEntry{
+		Time:    c.now(),
+		Actor:   actor,
+		Action:  AuditKPILifecycle,
+		Subject: string(key),
+		From:    string(current),
+		To:      string(next),
+	})
+	if next == LifecycleDeprecated {
+		for i := range c.kpis {
+			if c.kpis[i].Key == key {
+				c.kpis = append(c.kpis[:i], c.kpis[i+1:]...)
+				break
+			}
+		}
+	}
+	c.updateSummary()
+	return nil
+}
+
+// GetKPILifecycles returns the lifecycles set with TransitionKPI.
+func (c *MetricsCollector) GetKPILifecycles() map[KPIKey]Lifecycle {
+	return c.lifecycles
+}
+
+// GetAuditTrail returns the audit trail ordered by time.
+func (c *MetricsCollector) GetAuditTrail() []AuditEntry {
+	return c.audit
+}
+
+// RestoreLifecycles replaces the lifecycles set with TransitionKPI and the
+// audit trail with previously saved ones. Restore them before the KPIs, so
+// that deprecated KPIs stay hidden.
+func (c *MetricsCollector) RestoreLifecycles(lifecycles map[KPIKey]Lifecycle, audit []AuditEntry) {
+	c.lifecycles = make(map[KPIKey]Lifecycle, len(lifecycles))
+	for key, lifecycle := range lifecycles {
+		c.lifecycles[key] = lifecycle
+	}
+	c.audit = append(make([]AuditEntry, 0, len(audit)), audit...)
+	sort.SliceStable(c.audit, func(i, j int) bool {
+		return c.audit[i].Time.Before(c.audit[j].Time)
+	})
+	c.updateSummary()
+}
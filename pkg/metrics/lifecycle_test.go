@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestKPILifecycle(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	collector := NewMetricsCollector()
+	collector.SetClock(clk)
+	collector.AddKPI(KPI{Key: KPI_Coverage, Value: 100, Target: 95})
+	collector.AddKPI(KPI{Key: KPI_MTTR, Value: 8, Target: 4})
+
+	// A piloted KPI far from target does not drag the scores down
+	if err := collector.TransitionKPI(KPI_MTTR, LifecycleDraft, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if score := collector.GetPostureScore(); score != 100 {
+		t.Errorf("posture score with a draft = %v, want 100", score)
+	}
+	for _, category := range collector.GetCategorySummaries() {
+		if category.Category == "Response" {
+			t.Errorf("draft KPI scored in %+v", category)
+		}
+	}
+	if collector.GetKPI(KPI_MTTR) == nil {
+		t.Error("draft KPI hidden")
+	}
+
+	clk.Advance(time.Hour)
+	if err := collector.TransitionKPI(KPI_MTTR, LifecycleActive, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if score := collector.GetPostureScore(); score == 100 {
+		t.Error("activated KPI not scored")
+	}
+
+	// Deprecated KPIs leave reports but keep collecting history
+	clk.Advance(time.Hour)
+	if err := collector.TransitionKPI(KPI_MTTR, LifecycleDeprecated, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	collector.AddKPI(KPI{Key: KPI_MTTR, Value: 2, Target: 4})
+	if collector.GetKPI(KPI_MTTR) != nil {
+		t.Error("deprecated KPI still reported")
+	}
+	if history := collector.GetKPIHistory(KPI_MTTR); len(history) != 2 {
+		t.Errorf("deprecated KPI history = %+v, want both values", history)
+	}
+
+	audit := collector.GetAuditTrail()
+	if len(audit) != 3 {
+		t.Fatalf("audit trail = %+v, want three transitions", audit)
+	}
+	if entry := audit[2]; entry.Actor != "bob" || entry.Action != AuditKPILifecycle || entry.Subject != "mttr" ||
+		entry.From != "active" || entry.To != "deprecated" || !entry.Time.Equal(time.Date(2026, 10, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("last audit entry = %+v", entry)
+	}
+
+	// Repeating the current lifecycle is not a transition
+	if err := collector.TransitionKPI(KPI_MTTR, LifecycleDeprecated, "bob"); err != nil || len(collector.GetAuditTrail()) != 3 {
+		t.Errorf("repeated transition: %v, %d entries", err, len(collector.GetAuditTrail()))
+	}
+	if err := collector.TransitionKPI("unknown", LifecycleDraft, "bob"); err == nil {
+		t.Error("unknown KPI transitioned")
+	}
+	if err := collector.TransitionKPI(KPI_MTTR, "retired", "bob"); err == nil {
+		t.Error("unknown lifecycle accepted")
+	}
+
+	// Restoring keeps deprecated KPIs hidden
+	restored := NewMetricsCollector()
+	restored.RestoreLifecycles(collector.GetKPILifecycles(), collector.GetAuditTrail())
+	restored.Restore(nil, []KPI{{Key: KPI_MTTR, Value: 2, Target: 4}, {Key: KPI_Coverage, Value: 100, Target: 95}}, nil)
+	if restored.GetKPI(KPI_MTTR) != nil || len(restored.GetKPIS()) != 1 || len(restored.GetAuditTrail()) != 3 {
+		t.Errorf("restored KPIs %+v with %d audit entries", restored.GetKPIS(), len(restored.GetAuditTrail()))
+	}
+}
+
+func TestDefinitionDeclaresLifecycle(t *testing.T) {
+	collector := NewMetricsCollector()
+	if err := collector.SetKPIDefinition(KPIDefinition{Key: "phishing_click_rate", Direction: LowerIsBetter, Lifecycle: LifecycleDraft}); err != nil {
+		t.Fatal(err)
+	}
+	if lifecycle := collector.KPILifecycle("phishing_click_rate"); lifecycle != LifecycleDraft {
+		t.Errorf("lifecycle = %s, want the declared draft", lifecycle)
+	}
+	if lifecycle := collector.KPILifecycle(KPI_MTTR); lifecycle != LifecycleActive {
+		t.Errorf("built-in lifecycle = %s, want active", lifecycle)
+	}
+	if err := collector.SetKPIDefinition(KPIDefinition{Key: "x", Lifecycle: "retired"}); err == nil {
+		t.Error("unknown lifecycle accepted")
+	}
+}
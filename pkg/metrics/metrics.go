@@ -4,6 +4,8 @@ package metrics
 import (
 	"fmt"
 	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
 )
 
 // MetricType represents a type of security metric.
@@ -18,6 +20,7 @@ const (
 	TypePrevention      MetricType = "prevention"
 	TypeTraining        MetricType = "training"
 	TypeRisk            MetricType = "risk"
+	TypeInsiderRisk     MetricType = "insider_risk"
 )
 
 // SecurityMetric represents a security metric.
@@ -32,6 +35,16 @@ type SecurityMetric struct {
 	Timestamp   time.Time
 	Description string
 	Category    string
+	// Asset is the affected asset, if any. Scores weight the metric by the
+	// Criticality tier of the asset, standard when unset.
+	Asset       string
+	Criticality Criticality
+	// Evidence references the artifacts the value is based on, such as
+	// scan file hashes, ticket URLs or screenshot paths.
+	Evidence []Evidence
+	// Provenance records the source, collection run and raw record the
+	// value came from.
+	Provenance Provenance
 }
 
 // KPIKey represents a key performance indicator key.
@@ -60,6 +73,22 @@ type KPI struct {
 	Trend         string
 	LastUpdated   time.Time
 	Category      string
+	// Owner is the team or person accountable for the KPI.
+	Owner         string
+	Percentiles   []PercentileValue
+	ArchivedAt    time.Time
+	// Source is the collection source that set the KPI, empty for KPIs
+	// pushed, imported or set by hand.
+	Source        string
+	// Stale is set on a KPI kept from an earlier collection because its
+	// source failed; see RetainStaleKPIs. Stale KPIs do not count toward
+	// scores.
+	Stale         bool
+}
+
+// IsArchived reports whether the KPI has been archived.
+func (k KPI) IsArchived() bool {
+	return !k.ArchivedAt.IsZero()
 }
 
 // MetricsCollector collects security metrics.
@@ -67,6 +96,26 @@ type MetricsCollector struct {
 	metrics  []SecurityMetric
 	kpis     []KPI
 	summary  *MetricsSummary
+	definitions map[KPIKey]KPIDefinition
+	history     []KPISample
+	archived    []KPI
+	lifecycles  map[KPIKey]Lifecycle
+	audit       []AuditEntry
+	dependencies []KPIDependency
+	taxonomy     Taxonomy
+	fiscal       FiscalCalendar
+	incidents    []Incident
+	alerts       []Alert
+	clock        clock.Clock
+	totals       ScoreInputs
+	model        ScoreModel
+	modelVersion string
+	scores       []ScoreSample
+	latest       latestMetrics
+	api          APIVersion
+	units        map[string]bool
+	provenance   Provenance
+	runs         []CollectionRun
 }
 
 // MetricsSummary represents a metrics summary.
@@ -75,30 +124,146 @@ type MetricsSummary struct {
 	TotalKPIS         int
 	ComplianceScore   float64
 	RiskScore         float64
+	VulnerabilityScore float64
 	OverallHealth     string
 	LastUpdated       time.Time
+	Categories        []CategorySummary
+	// HasData reports which scores were computed from data; the others
+	// are 0 for lack of it.
+	HasData           ScoreData
+	// ScoreModel is the version of the score model the scores follow.
+	ScoreModel        string
+	// StaleKPIs lists the KPIs left out of the scores because their
+	// source failed; the scores are degraded while any are listed.
+	StaleKPIs         []KPIKey
+}
+
+// ScoreData reports, per score, whether any data could be scored. A score
+// without data is 0, which is not a measurement and is shown as NoScore.
+type ScoreData struct {
+	Compliance    bool
+	Risk          bool
+	Vulnerability bool
+	// Posture covers the posture score, see GetPostureScore.
+	Posture bool
+}
+
+// NoScore is shown in place of a score without data to compute it from.
+const NoScore = "n/a"
+
+// FormatScore formats score with format, e.g. "%.1f%%", or returns NoScore
+// when hasData is false.
+func FormatScore(format string, score float64, hasData bool) string {
+	if !hasData {
+		return NoScore
+	}
+	return fmt.Sprintf(format, score)
 }
 
 // NewMetricsCollector creates a new metrics collector.
 func NewMetricsCollector() *MetricsCollector {
-	return &MetricsCollector{
-		metrics: make([]SecurityMetric, 0),
-		kpis:    make([]KPI, 0),
-		summary: &MetricsSummary{},
+	c := &MetricsCollector{
+		metrics:     make([]SecurityMetric, 0),
+		kpis:        make([]KPI, 0),
+		summary:     &MetricsSummary{OverallHealth: InsufficientData},
+		definitions: make(map[KPIKey]KPIDefinition),
+		lifecycles:  make(map[KPIKey]Lifecycle),
+		modelVersion: DefaultScoreModel,
+		dependencies: builtinDependencies(),
+		clock:       clock.System,
 	}
+	for _, def := range builtinDefinitions() {
+		c.definitions[def.Key] = def
+	}
+	return c
+}
+
+// SetClock sets the time source used to stamp metrics, KPIs, samples and
+// archival; collectors use the system clock by default.
+func (c *MetricsCollector) SetClock(clk clock.Clock) {
+	c.clock = clk
 }
 
-// AddMetric adds a security metric.
+// Clock returns the collector's time source.
+func (c *MetricsCollector) Clock() clock.Clock {
+	return c.clock
+}
+
+// now returns the current time of the collector's clock in UTC, the zone
+// every timestamp the collector stamps is in.
+func (c *MetricsCollector) now() time.Time {
+	return c.clock.Now().UTC()
+}
+
+// AddMetric adds a security metric, stamping it with the current time
+// unless it already carries a timestamp, and with the provenance set with
+// SetProvenance where it carries none of its own.
 func (c *MetricsCollector) AddMetric(metric SecurityMetric) {
-	metric.Timestamp = time.Now()
+	if metric.Timestamp.IsZero() {
+		metric.Timestamp = c.now()
+	}
+	metric.Provenance = metric.Provenance.WithDefaults(c.provenance)
 	c.metrics = append(c.metrics, metric)
+	c.totals.add(metric)
+	c.latest.add(c.metrics, len(c.metrics)-1)
 	c.updateSummary()
 }
 
-// AddKPI adds a KPI.
+// AddKPI sets the current value of a KPI, replacing any KPI with the same
+// key, and records the value in history. Use AddKPISample to record past
+// values without changing the current one. Values for archived or
+// deprecated KPIs are recorded in history only and do not reactivate the
+// KPI. The KPI is attributed to the source set with SetProvenance unless
+// it names its own.
 func (c *MetricsCollector) AddKPI(kpi KPI) {
-	kpi.LastUpdated = time.Now()
-	c.kpis = append(c.kpis, kpi)
+	kpi.LastUpdated = c.now()
+	kpi.Stale = false
+	if kpi.Source == "" {
+		kpi.Source = c.provenance.Source
+	}
+	c.applyDefinition(&kpi)
+	c.AddKPISample(KPISample{Key: kpi.Key, Value: kpi.Value, Timestamp: kpi.LastUpdated})
+	if c.isArchived(kpi.Key) || c.KPILifecycle(kpi.Key) == LifecycleDeprecated {
+		return
+	}
+	if current := c.GetKPI(kpi.Key); current != nil {
+		*current = kpi
+	} else {
+		c.kpis = append(c.kpis, kpi)
+	}
+	c.updateSummary()
+}
+
+// Restore replaces the collector state with previously saved metrics,
+// KPIs and history, preserving their timestamps. Archived KPIs in kpis are
+// restored to the archived set and deprecated ones are dropped. Of several
+// KPIs with the same key, as stores saved before AddKPI replaced KPIs may
+// hold, the most recently updated is kept.
+func (c *MetricsCollector) Restore(metrics []SecurityMetric, kpis []KPI, history []KPISample) {
+	c.metrics = append(make([]SecurityMetric, 0, len(metrics)), metrics...)
+	c.totals = totalsOf(c.metrics)
+	c.latest.reset()
+	c.kpis = make([]KPI, 0, len(kpis))
+	c.archived = make([]KPI, 0)
+	positions := make(map[KPIKey]int, len(kpis))
+	for _, kpi := range kpis {
+		if kpi.IsArchived() {
+			c.archived = append(c.archived, kpi)
+			continue
+		}
+		if c.KPILifecycle(kpi.Key) == LifecycleDeprecated {
+			continue
+		}
+		if i, ok := positions[kpi.Key]; ok {
+			if !kpi.LastUpdated.Before(c.kpis[i].LastUpdated) {
+				c.kpis[i] = kpi
+			}
+			continue
+		}
+		positions[kpi.Key] = len(c.kpis)
+		c.kpis = append(c.kpis, kpi)
+	}
+	c.history = append(make([]KPISample, 0, len(history)), history...)
 	c.updateSummary()
 }
 
@@ -107,9 +272,11 @@ func (c *MetricsCollector) GetMetrics() []SecurityMetric {
 	return c.metrics
 }
 
-// GetKPIS returns all KPIs.
+// GetKPIS returns a copy of all KPIs. AddKPI updates KPIs in place, so
+// the copy stays unchanged by later updates and can be read once the
+// caller releases whatever lock guards the collector.
 func (c *MetricsCollector) GetKPIS() []KPI {
-	return c.kpis
+	return append([]KPI(nil), c.kpis...)
 }
 
 // GetKPI retrieves a KPI by key.
@@ -133,80 +300,56 @@ func (c *MetricsCollector) GetMetricByType(metricType MetricType) []SecurityMetr
 	return result
 }
 
-// GetComplianceScore calculates compliance score.
+// GetComplianceScore calculates compliance score, the mean progress of
+// compliance metrics toward their targets, from 0 to 100.
 func (c *MetricsCollector) GetComplianceScore() float64 {
-	var total float64
-	var weighted float64
-
-	for _, metric := range c.GetMetricByType(TypeCompliance) {
-		total += 1.0
-		weighted += metric.Value / metric.Target * 100.0
-	}
-
-	if total == 0 {
-		return 0.0
-	}
-
-	return weighted / total
+	score, _ := c.totals.compliance()
+	return score
 }
 
-// GetRiskScore calculates risk score.
+// GetRiskScore calculates risk score, the mean of risk metric values
+// clamped to 0-100, weighted by the criticality of the affected assets.
 func (c *MetricsCollector) GetRiskScore() float64 {
-	var total float64
-	var weighted float64
-
-	for _, metric := range c.GetMetricByType(TypeRisk) {
-		total += 1.0
-		weighted += metric.Value
-	}
-
-	if total == 0 {
-		return 0.0
-	}
+	score, _ := weightedMean(c.totals.Risk, c.model)
+	return score
+}
 
-	return weighted / total
+// GetVulnerabilityScore calculates vulnerability score, the mean of
+// vulnerability metric values clamped to 0-100, weighted by the
+// criticality of the affected assets.
+func (c *MetricsCollector) GetVulnerabilityScore() float64 {
+	score, _ := weightedMean(c.totals.Vulnerability, c.model)
+	return score
 }
 
-// CalculateMTTR calculates mean time to respond.
+// CalculateMTTR calculates mean time to respond, skipping times that are
+// not finite numbers, or 0 without any.
 func CalculateMTTR(responseTimes []float64) float64 {
-	if len(responseTimes) == 0 {
-		return 0.0
-	}
-
-	var total float64
+	var mean runningMean
 	for _, time := range responseTimes {
-		total += time
+		mean.add(time, 1)
 	}
-
-	return total / float64(len(responseTimes))
+	return mean.mean()
 }
 
-// CalculateMTTD calculates mean time to detect.
+// CalculateMTTD calculates mean time to detect, skipping times that are
+// not finite numbers, or 0 without any.
 func CalculateMTTD(detectionTimes []float64) float64 {
-	if len(detectionTimes) == 0 {
-		return 0.0
-	}
-
-	var total float64
+	var mean runningMean
 	for _, time := range detectionTimes {
-		total += time
+		mean.add(time, 1)
 	}
-
-	return total / float64(len(detectionTimes))
+	return mean.mean()
 }
 
-// CalculateMTTC calculates mean time to contain.
+// CalculateMTTC calculates mean time to contain, skipping times that are
+// not finite numbers, or 0 without any.
 func CalculateMTTC(containmentTimes []float64) float64 {
-	if len(containmentTimes) == 0 {
-		return 0.0
-	}
-
-	var total float64
+	var mean runningMean
 	for _, time := range containmentTimes {
-		total += time
+		mean.add(time, 1)
 	}
-
-	return total / float64(len(containmentTimes))
+	return mean.mean()
 }
 
 // CalculateCoverage calculates security coverage.
@@ -237,22 +380,17 @@ func CalculateDetectionRate(detected, total int) float64 {
 func (c *MetricsCollector) updateSummary() {
 	c.summary.TotalMetrics = len(c.metrics)
 	c.summary.TotalKPIS = len(c.kpis)
-	c.summary.ComplianceScore = c.GetComplianceScore()
-	c.summary.RiskScore = c.GetRiskScore()
-	c.summary.OverallHealth = determineHealth(c.summary.ComplianceScore, c.summary.RiskScore)
-	c.summary.LastUpdated = time.Now()
-}
-
-// determineHealth determines overall health.
-func determineHealth(compliance, risk float64) string {
-	if compliance >= 90 && risk <= 30 {
-		return "HEALTHY"
-	} else if compliance >= 70 && risk <= 50 {
-		return "GOOD"
-	} else if compliance >= 50 && risk <= 70 {
-		return "FAIR"
-	}
-	return "POOR"
+	scores := scoresOf(c.totals, c.model)
+	c.summary.ComplianceScore = scores.ComplianceScore
+	c.summary.RiskScore = scores.RiskScore
+	c.summary.VulnerabilityScore = scores.VulnerabilityScore
+	c.summary.OverallHealth = scores.OverallHealth
+	c.summary.HasData = scores.HasData
+	c.summary.ScoreModel = c.modelVersion
+	_, c.summary.HasData.Posture = c.postureScore()
+	c.summary.Categories = c.GetCategorySummaries()
+	c.summary.StaleKPIs = c.staleKPIs()
+	c.summary.LastUpdated = c.now()
 }
 
 // GetSummary returns metrics summary.
@@ -269,8 +407,9 @@ func (c *MetricsCollector) GenerateReport() string {
 	// Summary
 	summary := c.GetSummary()
 	report += "Overall Health: " + summary.OverallHealth + "\n"
-	report += "Compliance Score: " + fmt.Sprintf("%.1f%%", summary.ComplianceScore) + "\n"
-	report += "Risk Score: " + fmt.Sprintf("%.1f", summary.RiskScore) + "\n"
+	report += "Compliance Score: " + FormatScore("%.1f%%", summary.ComplianceScore, summary.HasData.Compliance) + "\n"
+	report += "Risk Score: " + FormatScore("%.1f", summary.RiskScore, summary.HasData.Risk) + "\n"
+	report += "Vulnerability Score: " + FormatScore("%.1f", summary.VulnerabilityScore, summary.HasData.Vulnerability) + "\n"
 	report += "Total Metrics: " + fmt.Sprintf("%d", summary.TotalMetrics) + "\n"
 	report += "Total KPIs: " + fmt.Sprintf("%d", summary.TotalKPIS) + "\n\n"
 
@@ -280,6 +419,9 @@ func (c *MetricsCollector) GenerateReport() string {
 		for i, kpi := range c.kpis {
 			report += "  [" + fmt.Sprintf("%d", i+1) + "] " + kpi.Name + "\n"
 			report += "      Value: " + fmt.Sprintf("%.1f", kpi.Value) + " " + kpi.Unit + "\n"
+			for _, p := range kpi.Percentiles {
+				report += "      " + p.Label + ": " + fmt.Sprintf("%.1f", p.Value) + " " + kpi.Unit + "\n"
+			}
 			report += "      Target: " + fmt.Sprintf("%.1f", kpi.Target) + " " + kpi.Unit + "\n"
 			report += "      Status: " + kpi.Status + "\n"
 			report += "      Trend: " + kpi.Trend + "\n\n"
@@ -299,6 +441,9 @@ func (c *MetricsCollector) GenerateReport() string {
 }
 
 // GetCommonKPIs returns common security KPIs.
+//
+// Deprecated: the values are fixed sample numbers, not measurements. Use
+// demo.Generate for sample data, or collect real KPIs.
 func GetCommonKPIs() []KPI {
 	return []KPI{
 		{
@@ -311,6 +456,7 @@ func GetCommonKPIs() []KPI {
 			Status:        "BELOW_TARGET",
 			Trend:         "IMPROVING",
 			Category:      "Response",
+			Percentiles:   []PercentileValue{{"P50", 1.8}, {"P90", 5.2}, {"P95", 7.5}},
 		},
 		{
 			Key:           KPI_MTTC,
@@ -322,6 +468,7 @@ func GetCommonKPIs() []KPI {
 			Status:        "BELOW_TARGET",
 			Trend:         "STABLE",
 			Category:      "Response",
+			Percentiles:   []PercentileValue{{"P50", 3.0}, {"P90", 8.5}, {"P95", 11.0}},
 		},
 		{
 			Key:           KPI_MTTD,
@@ -333,6 +480,7 @@ func GetCommonKPIs() []KPI {
 			Status:        "BELOW_TARGET",
 			Trend:         "IMPROVING",
 			Category:      "Detection",
+			Percentiles:   []PercentileValue{{"P50", 0.4}, {"P90", 1.1}, {"P95", 1.6}},
 		},
 		{
 			Key:           KPI_Coverage,
@@ -373,4 +521,108 @@ func GetCommonKPIs() []KPI {
 // GetKPI returns KPI.
 func GetKPI(collector *MetricsCollector, key KPIKey) *KPI {
 	return collector.GetKPI(key)
-}
\ No newline at end of file
+}
+
+// RemoveMetric deletes the metric with the given ID.
+func (c *MetricsCollector) RemoveMetric(id string) bool {
+	for i := range c.metrics {
+		if c.metrics[i].ID == id {
+			c.metrics = append(c.metrics[:i], c.metrics[i+1:]...)
+			c.totals = totalsOf(c.metrics)
+			c.latest.reset()
+			c.updateSummary()
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveMetrics deletes every metric with one of the given IDs in a single
+// pass and returns the number deleted.
+func (c *MetricsCollector) RemoveMetrics(ids ...string) int {
+	if len(ids) == 0 {
+		return 0
+	}
+	remove := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	kept := make([]SecurityMetric, 0, len(c.metrics))
+	for _, metric := range c.metrics {
+		if !remove[metric.ID] {
+			kept = append(kept, metric)
+		}
+	}
+	removed := len(c.metrics) - len(kept)
+	if removed == 0 {
+		return 0
+	}
+	c.metrics = kept
+	c.totals = totalsOf(c.metrics)
+	c.latest.reset()
+	c.updateSummary()
+	return removed
+}
+
+// RemoveKPI deletes a KPI, active or archived, together with its history.
+func (c *MetricsCollector) RemoveKPI(key KPIKey) bool {
+	removed := false
+	for i := range c.kpis {
+		if c.kpis[i].Key == key {
+			c.kpis = append(c.kpis[:i], c.kpis[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	for i := range c.archived {
+		if c.archived[i].Key == key {
+			c.archived = append(c.archived[:i], c.archived[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	if !removed {
+		return false
+	}
+
+	history := c.history[:0]
+	for _, sample := range c.history {
+		if sample.Key != key {
+			history = append(history, sample)
+		}
+	}
+	c.history = history
+	c.updateSummary()
+	return true
+}
+
+// ArchiveKPI moves a KPI out of the active set. Archived KPIs are excluded
+// from summaries and reports but their history remains queryable.
+func (c *MetricsCollector) ArchiveKPI(key KPIKey) bool {
+	for i := range c.kpis {
+		if c.kpis[i].Key == key {
+			kpi := c.kpis[i]
+			kpi.ArchivedAt = c.now()
+			c.kpis = append(c.kpis[:i], c.kpis[i+1:]...)
+			c.archived = append(c.archived, kpi)
+			c.updateSummary()
+			return true
+		}
+	}
+	return false
+}
+
+// isArchived reports whether key belongs to an archived KPI.
+func (c *MetricsCollector) isArchived(key KPIKey) bool {
+	for _, kpi := range c.archived {
+		if kpi.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// GetArchivedKPIs returns all archived KPIs.
+func (c *MetricsCollector) GetArchivedKPIs() []KPI {
+	return c.archived
+}
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// KPI_AlertMTTA is the mean time to acknowledge alerts, in hours.
+const KPI_AlertMTTA KPIKey = "alert_mtta"
+
+// OperationsSummary summarizes security operations over [Start, End).
+type OperationsSummary struct {
+	Start     time.Time
+	End       time.Time
+	Incidents []Incident
+	Alerts    AlertStats
+	Changes   []KPIChange
+	Movements []KPIMovement
+}
+
+// AlertStats summarizes the alerts fired in a period.
+type AlertStats struct {
+	Total        int
+	Acknowledged int
+	Resolved     int
+	Escalated    int
+	// MeanTimeToAcknowledge is in hours over acknowledged alerts.
+	MeanTimeToAcknowledge float64
+	BySeverity            map[string]int
+	BySource              map[string]int
+}
+
+// KPIChange describes a notable change to a KPI in a period: it was
+// added, archived, met its target or fell below it.
+type KPIChange struct {
+	Key         KPIKey
+	Name        string
+	Description string
+}
+
+// KPIMovement describes how a KPI value moved over a period. Trend is
+// IMPROVING, DECLINING or STABLE according to the KPI's direction.
+type KPIMovement struct {
+	Key   KPIKey
+	Name  string
+	Unit  string
+	Start float64
+	End   float64
+	Delta float64
+	Trend string
+}
+
+// MonthRange returns the start and end of the calendar month containing t.
+func MonthRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// GetOperationsSummary summarizes incidents detected, alerts fired and KPI
+// changes and movements in [start, end).
+func (c *MetricsCollector) GetOperationsSummary(start, end time.Time) *OperationsSummary {
+	summary := &OperationsSummary{
+		Start:  start,
+		End:    end,
+		Alerts: AlertStats{BySeverity: make(map[string]int), BySource: make(map[string]int)},
+	}
+	inPeriod := func(t time.Time) bool {
+		return !t.Before(start) && t.Before(end)
+	}
+
+	for _, incident := range c.GetIncidents() {
+		if inPeriod(incident.DetectedAt) {
+			summary.Incidents = append(summary.Incidents, incident)
+		}
+	}
+
+	var ackHours float64
+	for _, alert := range c.alerts {
+		if !inPeriod(alert.FiredAt) {
+			continue
+		}
+		stats := &summary.Alerts
+		stats.Total++
+		stats.BySeverity[alert.Severity]++
+		stats.BySource[alert.Source]++
+		if !alert.AcknowledgedAt.IsZero() {
+			stats.Acknowledged++
+			ackHours += alert.AcknowledgedAt.Sub(alert.FiredAt).Hours()
+		}
+		if !alert.ResolvedAt.IsZero() {
+			stats.Resolved++
+		}
+		if alert.IncidentID != "" {
+			stats.Escalated++
+		}
+	}
+	if summary.Alerts.Acknowledged > 0 {
+		summary.Alerts.MeanTimeToAcknowledge = ackHours / float64(summary.Alerts.Acknowledged)
+	}
+
+	kpis := append(append([]KPI(nil), c.kpis...), c.archived...)
+	sort.SliceStable(kpis, func(i, j int) bool { return kpis[i].Key < kpis[j].Key })
+	for _, kpi := range kpis {
+		c.summarizeKPIPeriod(summary, kpi, start, end)
+	}
+	return summary
+}
+
+// MeanTimeToAcknowledge returns the mean hours from firing to
+// acknowledgment of the alerts from source fired in [start, end), and how
+// many were acknowledged. An empty source matches every alert.
+func (c *MetricsCollector) MeanTimeToAcknowledge(source string, start, end time.Time) (float64, int) {
+	var hours float64
+	var acknowledged int
+	for _, alert := range c.alerts {
+		if source != "" && alert.Source != source {
+			continue
+		}
+		if alert.FiredAt.Before(start) || !alert.FiredAt.Before(end) || alert.AcknowledgedAt.IsZero() {
+			continue
+		}
+		hours += alert.AcknowledgedAt.Sub(alert.FiredAt).Hours()
+		acknowledged++
+	}
+	if acknowledged == 0 {
+		return 0, 0
+	}
+	return hours / float64(acknowledged), acknowledged
+}
+
+// summarizeKPIPeriod adds a KPI's changes and movement in [start, end) to
+// summary.
+func (c *MetricsCollector) summarizeKPIPeriod(summary *OperationsSummary, kpi KPI, start, end time.Time) {
+	history := c.GetKPIHistory(kpi.Key)
+	var before, during []KPISample
+	for _, sample := range history {
+		if sample.Timestamp.Before(start) {
+			before = append(before, sample)
+		} else if sample.Timestamp.Before(end) {
+			during = append(during, sample)
+		}
+	}
+
+	if kpi.IsArchived() && !kpi.ArchivedAt.Before(start) && kpi.ArchivedAt.Before(end) {
+		summary.Changes = append(summary.Changes, KPIChange{Key: kpi.Key, Name: kpi.Name, Description: "archived"})
+	}
+	if len(during) == 0 {
+		return
+	}
+
+	def := c.definitions[kpi.Key]
+	opening := during[0]
+	if len(before) > 0 {
+		opening = before[len(before)-1]
+	} else {
+		summary.Changes = append(summary.Changes, KPIChange{Key: kpi.Key, Name: kpi.Name, Description: "added"})
+	}
+	closing := during[len(during)-1]
+
+	if len(before) > 0 {
+		wasMet := def.MeetsTarget(opening.Value, kpi.Target)
+		isMet := def.MeetsTarget(closing.Value, kpi.Target)
+		if !wasMet && isMet {
+			summary.Changes = append(summary.Changes, KPIChange{Key: kpi.Key, Name: kpi.Name, Description: "now meets its target"})
+		} else if wasMet && !isMet {
+			summary.Changes = append(summary.Changes, KPIChange{Key: kpi.Key, Name: kpi.Name, Description: "fell below its target"})
+		}
+	}
+
+	movement := KPIMovement{
+		Key:   kpi.Key,
+		Name:  kpi.Name,
+		Unit:  kpi.Unit,
+		Start: opening.Value,
+		End:   closing.Value,
+		Delta: closing.Value - opening.Value,
+		Trend: "STABLE",
+	}
+	improvement := movement.Delta
+	if def.Direction == LowerIsBetter {
+		improvement = -improvement
+	}
+	if improvement > 0 {
+		movement.Trend = "IMPROVING"
+	} else if improvement < 0 {
+		movement.Trend = "DECLINING"
+	}
+	summary.Movements = append(summary.Movements, movement)
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultPercentiles is the percentile set reported for time-based KPIs.
+var DefaultPercentiles = []float64{50, 90, 95}
+
+// CalculatePercentiles calculates the requested percentiles of values.
+// Results are returned in the same order as percentiles and use linear
+// interpolation between closest ranks. NaN and infinite values are
+// skipped.
+func CalculatePercentiles(values []float64, percentiles []float64) []float64 {
+	result := make([]float64, len(percentiles))
+	sorted := finiteValues(values)
+	if len(sorted) == 0 {
+		return result
+	}
+	sort.Float64s(sorted)
+
+	for i, p := range percentiles {
+		if p <= 0 {
+			result[i] = sorted[0]
+			continue
+		}
+		if p >= 100 {
+			result[i] = sorted[len(sorted)-1]
+			continue
+		}
+		rank := p / 100.0 * float64(len(sorted)-1)
+		lower := int(rank)
+		frac := rank - float64(lower)
+		if lower+1 < len(sorted) {
+			result[i] = sorted[lower] + frac*(sorted[lower+1]-sorted[lower])
+		} else {
+			result[i] = sorted[lower]
+		}
+	}
+
+	return result
+}
+
+// finiteValues returns a copy of values without NaN and infinite values.
+func finiteValues(values []float64) []float64 {
+	kept := make([]float64, 0, len(values))
+	for _, v := range values {
+		if finite(v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+// PercentileLabel returns the display label for a percentile, e.g. "P95".
+func PercentileLabel(p float64) string {
+	if p == float64(int(p)) {
+		return fmt.Sprintf("P%d", int(p))
+	}
+	return fmt.Sprintf("P%g", p)
+}
+
+// PercentileValue is a labelled percentile of a KPI's underlying samples.
+type PercentileValue struct {
+	Label string
+	Value float64
+}
+
+// AddDurationKPI adds a time-based KPI from raw samples. The KPI value is
+// the mean of times and the percentiles configured on the KPI definition
+// (DefaultPercentiles if none) are reported alongside it. NaN and infinite
+// times are skipped.
+func (c *MetricsCollector) AddDurationKPI(key KPIKey, times []float64, target float64) {
+	percentiles := DefaultPercentiles
+	if def, ok := c.definitions[key]; ok && len(def.Percentiles) > 0 {
+		percentiles = def.Percentiles
+	}
+	times = finiteValues(times)
+
+	var mean float64
+	if len(times) > 0 {
+		var total float64
+		for _, t := range times {
+			total += t
+		}
+		mean = total / float64(len(times))
+	}
+
+	kpi := KPI{
+		Key:    key,
+		Value:  mean,
+		Target: target,
+	}
+	for i, v := range CalculatePercentiles(times, percentiles) {
+		kpi.Percentiles = append(kpi.Percentiles, PercentileValue{Label: PercentileLabel(percentiles[i]), Value: v})
+	}
+
+	c.AddKPI(kpi)
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculatePercentiles(t *testing.T) {
+	got := CalculatePercentiles([]float64{4, 1, 3, 2}, []float64{0, 50, 90, 100})
+	want := []float64{1, 2.5, 3.7, 4}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Errorf("percentiles = %v, want %v", got, want)
+			break
+		}
+	}
+	if got := CalculatePercentiles(nil, []float64{50}); got[0] != 0 {
+		t.Errorf("percentile of no values = %v", got[0])
+	}
+}
+
+func TestPercentilesSkipNonFiniteValues(t *testing.T) {
+	values := []float64{1, math.NaN(), 3, math.Inf(1), math.Inf(-1)}
+	if got := CalculatePercentiles(values, []float64{0, 50, 100}); got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("percentiles = %v, want [1 2 3]", got)
+	}
+	if got := CalculatePercentiles([]float64{math.NaN()}, []float64{50}); got[0] != 0 {
+		t.Errorf("percentile of NaN only = %v, want 0", got[0])
+	}
+
+	c := NewMetricsCollector()
+	c.AddDurationKPI(KPI_MTTR, values, 2)
+	kpi := c.GetKPI(KPI_MTTR)
+	if kpi == nil || kpi.Value != 2 {
+		t.Fatalf("mean = %+v, want 2", kpi)
+	}
+	if p50 := kpi.Percentiles[0]; p50.Label != "P50" || p50.Value != 2 {
+		t.Errorf("P50 = %+v, want 2", p50)
+	}
+}
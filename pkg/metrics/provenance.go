@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Provenance records where a metric sample came from, so a disputed value
+// can be traced back to the record it was derived from.
+type Provenance struct {
+	// Source names the source that collected the sample, e.g. fleetdm,
+	// or ingest and import for pushed and imported samples.
+	Source string
+	// RunID identifies the collection run, ingest batch or import.
+	RunID string
+	// Record references the raw record the value derives from, such as
+	// a scanner API URL, a control ID or an input file.
+	Record string
+}
+
+// WithDefaults returns p with its empty fields taken from defaults.
+func (p Provenance) WithDefaults(defaults Provenance) Provenance {
+	if p.Source == "" {
+		p.Source = defaults.Source
+	}
+	if p.RunID == "" {
+		p.RunID = defaults.RunID
+	}
+	if p.Record == "" {
+		p.Record = defaults.Record
+	}
+	return p
+}
+
+// NewRunID returns a new collection run ID: the UTC start time followed by
+// a random suffix, so run IDs sort by time.
+func NewRunID(start time.Time) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return start.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// SetProvenance sets the provenance AddMetric records on metrics, filling
+// in the fields a metric leaves empty, until it is set again. Collectors
+// set the source name and run ID before running each source; sources set
+// the record reference on the metrics themselves. The zero value records
+// nothing.
+func (c *MetricsCollector) SetProvenance(provenance Provenance) {
+	c.provenance = provenance
+}
+
+// MetricExplanation lists the samples of one metric with their provenance
+// and evidence, newest first.
+type MetricExplanation struct {
+	ID      string
+	Samples []SecurityMetric
+}
+
+// ExplainMetric returns the samples of the metric with id.
+func (c *MetricsCollector) ExplainMetric(id string) (*MetricExplanation, error) {
+	explanation := &MetricExplanation{ID: id}
+	for _, metric := range c.metrics {
+		if metric.ID == id {
+			explanation.Samples = append(explanation.Samples, metric)
+		}
+	}
+	if len(explanation.Samples) == 0 {
+		return nil, fmt.Errorf("metric %s has not been collected", id)
+	}
+	sort.SliceStable(explanation.Samples, func(i, j int) bool {
+		return explanation.Samples[i].Timestamp.After(explanation.Samples[j].Timestamp)
+	})
+	return explanation, nil
+}
+
+// FormatMetricExplanation formats an explanation as the explain metric
+// command prints it, with timestamps in location, or the local zone when
+// nil.
+func FormatMetricExplanation(explanation *MetricExplanation, location *time.Location) string {
+	if location == nil {
+		location = time.Local
+	}
+	latest := explanation.Samples[0]
+	var b strings.Builder
+
+	title := "Metric Explanation: " + latest.Name
+	fmt.Fprintln(&b, title)
+	fmt.Fprintln(&b, strings.Repeat("=", len(title)))
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "ID: %s\n", explanation.ID)
+	fmt.Fprintf(&b, "Type: %s\n", latest.Type)
+	if latest.Description != "" {
+		fmt.Fprintf(&b, "Description: %s\n", latest.Description)
+	}
+	fmt.Fprintln(&b)
+
+	fmt.Fprintln(&b, "Samples:")
+	for i, sample := range explanation.Samples {
+		fmt.Fprintf(&b, "  [%d] %.1f %s at %s\n", i+1, sample.Value, sample.Unit, sample.Timestamp.In(location).Format("2006-01-02 15:04:05 MST"))
+		fmt.Fprintf(&b, "      Source: %s\n", orUnknown(sample.Provenance.Source))
+		fmt.Fprintf(&b, "      Run: %s\n", orUnknown(sample.Provenance.RunID))
+		fmt.Fprintf(&b, "      Record: %s\n", orUnknown(sample.Provenance.Record))
+		for _, evidence := range sample.Evidence {
+			line := fmt.Sprintf("      Evidence: %s %s", evidence.Kind, evidence.Ref)
+			if evidence.Note != "" {
+				line += " (" + evidence.Note + ")"
+			}
+			fmt.Fprintln(&b, line)
+		}
+	}
+	return b.String()
+}
+
+// orUnknown returns s, or "unknown" when s is empty.
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
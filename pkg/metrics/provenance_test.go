@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestProvenance(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	collector := NewMetricsCollector()
+	collector.SetClock(clk)
+
+	runID := NewRunID(clk.Now())
+	if !regexp.MustCompile(`^20261001T090000Z-[0-9a-f]{8}$`).MatchString(runID) {
+		t.Errorf("run id = %s", runID)
+	}
+	collector.SetProvenance(Provenance{Source: "tls", RunID: runID})
+	collector.AddMetric(SecurityMetric{ID: "tls-expiry-example.com", Name: "Certificate Expiry", Value: 40, Unit: "days",
+		Provenance: Provenance{Record: "example.com:443"}})
+	// A metric naming its own source keeps it
+	collector.AddMetric(SecurityMetric{ID: "relayed", Name: "Relayed", Provenance: Provenance{Source: "qualys"}})
+	collector.SetProvenance(Provenance{})
+	clk.Advance(24 * time.Hour)
+	collector.AddMetric(SecurityMetric{ID: "tls-expiry-example.com", Name: "Certificate Expiry", Value: 39, Unit: "days"})
+
+	samples := collector.GetMetrics()
+	if want := (Provenance{Source: "tls", RunID: runID, Record: "example.com:443"}); samples[0].Provenance != want {
+		t.Errorf("provenance = %+v, want %+v", samples[0].Provenance, want)
+	}
+	if want := (Provenance{Source: "qualys", RunID: runID}); samples[1].Provenance != want {
+		t.Errorf("provenance = %+v, want %+v", samples[1].Provenance, want)
+	}
+	if samples[2].Provenance != (Provenance{}) {
+		t.Errorf("provenance after reset = %+v", samples[2].Provenance)
+	}
+
+	explanation, err := collector.ExplainMetric("tls-expiry-example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(explanation.Samples) != 2 || explanation.Samples[0].Value != 39 {
+		t.Fatalf("samples = %+v, want newest first", explanation.Samples)
+	}
+	text := FormatMetricExplanation(explanation, time.UTC)
+	for _, want := range []string{
+		"[1] 39.0 days at 2026-10-02 09:00:00 UTC\n      Source: unknown\n",
+		"[2] 40.0 days at 2026-10-01 09:00:00 UTC\n      Source: tls\n      Run: " + runID + "\n      Record: example.com:443\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("explanation lacks %q:\n%s", want, text)
+		}
+	}
+	if _, err := collector.ExplainMetric("unknown"); err == nil {
+		t.Error("unknown metric explained")
+	}
+}
@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxRuns is the number of collection runs the collector keeps; older runs
+// are dropped.
+const MaxRuns = 1000
+
+// CollectionRun records one collection: when it started, how long it took
+// and how each source fared.
+type CollectionRun struct {
+	ID       string
+	Start    time.Time
+	Duration time.Duration
+	Sources  []SourceRun
+
+	started time.Time
+}
+
+// SourceRun records one source's part in a collection run.
+type SourceRun struct {
+	Name     string
+	Duration time.Duration
+	// Records is the number of metric samples and KPI values the source
+	// added.
+	Records int
+	// Error is the error the source failed with, empty on success.
+	Error string
+	// Attempts is the number of times the source was tried, 0 when its
+	// circuit breaker skipped it.
+	Attempts int
+	// Breaker is the state of the source's circuit breaker after the run:
+	// closed, open or half-open; empty when the source has none.
+	Breaker string
+}
+
+// Circuit breaker states of a source.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Skipped reports whether the source's open circuit breaker kept the run
+// from trying it.
+func (s SourceRun) Skipped() bool {
+	return s.Breaker == BreakerOpen && s.Attempts == 0
+}
+
+// Failed returns the sources of the run that failed.
+func (r CollectionRun) Failed() []SourceRun {
+	var failed []SourceRun
+	for _, source := range r.Sources {
+		if source.Error != "" {
+			failed = append(failed, source)
+		}
+	}
+	return failed
+}
+
+// Records returns the number of records all sources of the run added.
+func (r CollectionRun) Records() int {
+	records := 0
+	for _, source := range r.Sources {
+		records += source.Records
+	}
+	return records
+}
+
+// Status is "ok" when every source succeeded, "failed" when every source
+// failed and "partial" otherwise.
+func (r CollectionRun) Status() string {
+	switch failed := len(r.Failed()); {
+	case failed == 0:
+		return "ok"
+	case failed == len(r.Sources):
+		return "failed"
+	}
+	return "partial"
+}
+
+// StartRun starts a collection run with a new run ID.
+func (c *MetricsCollector) StartRun() *CollectionRun {
+	start := c.now()
+	return &CollectionRun{ID: NewRunID(start), Start: start, started: time.Now()}
+}
+
+// CollectSource runs collect as the source name of run, recording the
+// source's provenance on the metrics it adds, and adds the source's
+// duration, records and error to the run. It returns the error of collect.
+func (c *MetricsCollector) CollectSource(run *CollectionRun, name string, collect func() error) error {
+	c.SetProvenance(Provenance{Source: name, RunID: run.ID})
+	defer c.SetProvenance(Provenance{})
+
+	start, before := time.Now(), len(c.metrics)+len(c.history)
+	err := collect()
+	source := SourceRun{Name: name, Duration: time.Since(start), Records: len(c.metrics) + len(c.history) - before, Attempts: 1}
+	if err != nil {
+		source.Error = err.Error()
+	}
+	run.Sources = append(run.Sources, source)
+	return err
+}
+
+// FinishRun ends run and adds it to the run history.
+func (c *MetricsCollector) FinishRun(run *CollectionRun) {
+	run.Duration = time.Since(run.started)
+	c.runs = append(c.runs, *run)
+	if len(c.runs) > MaxRuns {
+		c.runs = append([]CollectionRun(nil), c.runs[len(c.runs)-MaxRuns:]...)
+	}
+}
+
+// GetRuns returns the collection runs ordered by start time.
+func (c *MetricsCollector) GetRuns() []CollectionRun {
+	return c.runs
+}
+
+// GetRun returns the run whose ID is or starts with id, failing when no run
+// or several runs match.
+func (c *MetricsCollector) GetRun(id string) (CollectionRun, error) {
+	var matches []CollectionRun
+	for _, run := range c.runs {
+		if run.ID == id {
+			return run, nil
+		}
+		if strings.HasPrefix(run.ID, id) {
+			matches = append(matches, run)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return CollectionRun{}, fmt.Errorf("collection run %s not found", id)
+	case 1:
+		return matches[0], nil
+	}
+	return CollectionRun{}, fmt.Errorf("collection run %s is ambiguous: %d runs match", id, len(matches))
+}
+
+// SourceFailures returns the number of consecutive runs, counting back
+// from the latest, in which the source named name was tried and failed,
+// and the start of the latest of them. Runs that skipped the source or did
+// not include it are passed over.
+func (c *MetricsCollector) SourceFailures(name string) (failures int, last time.Time) {
+	for i := len(c.runs) - 1; i >= 0; i-- {
+		for _, source := range c.runs[i].Sources {
+			if source.Name != name || source.Skipped() {
+				continue
+			}
+			if source.Error == "" {
+				return failures, last
+			}
+			if failures == 0 {
+				last = c.runs[i].Start
+			}
+			failures++
+		}
+	}
+	return failures, last
+}
+
+// RestoreRuns replaces the run history with previously saved runs.
+func (c *MetricsCollector) RestoreRuns(runs []CollectionRun) {
+	c.runs = append(make([]CollectionRun, 0, len(runs)), runs...)
+	sort.SliceStable(c.runs, func(i, j int) bool {
+		return c.runs[i].Start.Before(c.runs[j].Start)
+	})
+	if len(c.runs) > MaxRuns {
+		c.runs = c.runs[len(c.runs)-MaxRuns:]
+	}
+}
+
+// RetainStaleKPIs keeps the KPIs of previous, the current KPIs of an
+// earlier collection, whose source failed in run and which the run did not
+// set again. They keep their value and last update and are marked stale,
+// which leaves them out of the scores until their source succeeds again.
+func (c *MetricsCollector) RetainStaleKPIs(previous []KPI, run *CollectionRun) {
+	failed := make(map[string]bool)
+	for _, source := range run.Failed() {
+		failed[source.Name] = true
+	}
+	if len(failed) == 0 {
+		return
+	}
+	for _, kpi := range previous {
+		if !failed[kpi.Source] || kpi.IsArchived() || c.isArchived(kpi.Key) || c.GetKPI(kpi.Key) != nil ||
+			c.KPILifecycle(kpi.Key) == LifecycleDeprecated {
+			continue
+		}
+		kpi.Stale = true
+		c.kpis = append(c.kpis, kpi)
+	}
+	c.updateSummary()
+}
+
+// GetStaleKPIs returns the KPIs kept from an earlier collection because
+// their source failed.
+func (c *MetricsCollector) GetStaleKPIs() []KPI {
+	var stale []KPI
+	for _, kpi := range c.kpis {
+		if kpi.Stale {
+			stale = append(stale, kpi)
+		}
+	}
+	return stale
+}
+
+// staleKPIs returns the keys of the stale KPIs.
+func (c *MetricsCollector) staleKPIs() []KPIKey {
+	var keys []KPIKey
+	for _, kpi := range c.kpis {
+		if kpi.Stale {
+			keys = append(keys, kpi.Key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestCollectionRuns(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC))
+	collector := NewMetricsCollector()
+	collector.SetClock(clk)
+
+	run := collector.StartRun()
+	if !run.Start.Equal(clk.Now()) {
+		t.Errorf("run start = %v", run.Start)
+	}
+	collector.CollectSource(run, "tls", func() error {
+		collector.AddMetric(SecurityMetric{ID: "tls-expiry-example.com", Name: "Certificate Expiry", Value: 40})
+		collector.AddMetric(SecurityMetric{ID: "tls-expiry-example.org", Name: "Certificate Expiry", Value: 12})
+		return nil
+	})
+	if err := collector.CollectSource(run, "fleetdm", func() error {
+		return errors.New("fleetdm: 503 Service Unavailable")
+	}); err == nil {
+		t.Error("source error not returned")
+	}
+	collector.FinishRun(run)
+
+	if samples := collector.GetMetrics(); samples[0].Provenance.Source != "tls" || samples[0].Provenance.RunID != run.ID {
+		t.Errorf("provenance = %+v", samples[0].Provenance)
+	}
+	runs := collector.GetRuns()
+	if len(runs) != 1 {
+		t.Fatalf("runs = %+v", runs)
+	}
+	if got := runs[0]; got.Records() != 2 || got.Status() != "partial" || len(got.Failed()) != 1 || got.Failed()[0].Name != "fleetdm" {
+		t.Errorf("run = %+v, status %s", got, got.Status())
+	}
+
+	clk.Advance(24 * time.Hour)
+	run = collector.StartRun()
+	collector.CollectSource(run, "tls", func() error { return nil })
+	collector.FinishRun(run)
+	if status := collector.GetRuns()[1].Status(); status != "ok" {
+		t.Errorf("status = %s, want ok", status)
+	}
+	if status := (CollectionRun{Sources: []SourceRun{{Name: "tls", Error: "timeout"}}}).Status(); status != "failed" {
+		t.Errorf("status = %s, want failed", status)
+	}
+
+	if got, err := collector.GetRun(run.ID[:len("20261002T")]); err != nil || got.ID != run.ID {
+		t.Errorf("GetRun by prefix = %+v, %v", got, err)
+	}
+	if _, err := collector.GetRun("2026"); err == nil {
+		t.Error("ambiguous prefix matched")
+	}
+	if _, err := collector.GetRun("unknown"); err == nil {
+		t.Error("unknown run found")
+	}
+
+	restored := NewMetricsCollector()
+	saved := collector.GetRuns()
+	restored.RestoreRuns([]CollectionRun{saved[1], saved[0]})
+	if runs := restored.GetRuns(); len(runs) != 2 || runs[0].ID != saved[0].ID {
+		t.Errorf("restored runs = %+v, want ordered by start", runs)
+	}
+}
+
+func TestRetainStaleKPIs(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC))
+	previous := NewMetricsCollector()
+	previous.SetClock(clk)
+	run := previous.StartRun()
+	previous.CollectSource(run, "fleetdm", func() error {
+		previous.AddKPI(KPI{Key: "endpoint_coverage", Value: 40, Target: 95})
+		return nil
+	})
+	previous.CollectSource(run, "tls", func() error {
+		previous.AddKPI(KPI{Key: "tls_hsts_coverage", Value: 100, Target: 90})
+		return nil
+	})
+	if kpi := previous.GetKPI("endpoint_coverage"); kpi.Source != "fleetdm" {
+		t.Errorf("kpi source = %q, want fleetdm", kpi.Source)
+	}
+
+	clk.Advance(24 * time.Hour)
+	collector := NewMetricsCollector()
+	collector.SetClock(clk)
+	run = collector.StartRun()
+	collector.CollectSource(run, "fleetdm", func() error { return errors.New("503 Service Unavailable") })
+	collector.CollectSource(run, "tls", func() error {
+		collector.AddKPI(KPI{Key: "tls_hsts_coverage", Value: 100, Target: 90})
+		return nil
+	})
+	collector.RetainStaleKPIs(previous.GetKPIS(), run)
+
+	stale := collector.GetStaleKPIs()
+	if len(stale) != 1 || stale[0].Key != "endpoint_coverage" || stale[0].Value != 40 || !stale[0].LastUpdated.Equal(clk.Now().Add(-24*time.Hour)) {
+		t.Fatalf("stale KPIs = %+v", stale)
+	}
+	summary := collector.GetSummary()
+	if len(summary.StaleKPIs) != 1 || summary.StaleKPIs[0] != "endpoint_coverage" {
+		t.Errorf("summary stale KPIs = %v", summary.StaleKPIs)
+	}
+	// The stale KPI is left out: only the on-target TLS KPI is scored
+	if score := collector.GetPostureScore(); score != 100 {
+		t.Errorf("posture score = %v, want 100", score)
+	}
+
+	// A new value from the source clears the staleness
+	collector.AddKPI(KPI{Key: "endpoint_coverage", Value: 60, Target: 95, Source: "fleetdm"})
+	if len(collector.GetStaleKPIs()) != 0 || len(collector.GetSummary().StaleKPIs) != 0 {
+		t.Error("KPI still stale after a new value")
+	}
+}
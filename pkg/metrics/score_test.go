@@ -0,0 +1,341 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// scoreKeys mixes higher- and lower-is-better KPIs, zero trust pillars and
+// a key without a definition.
+var scoreKeys = []KPIKey{
+	KPI_MTTR, KPI_MTTD, KPI_Coverage, KPI_Compliance, KPI_RemediationRate,
+	KPI_MFACoverage, KPI_DeviceCompliance, KPI_NetworkSegmentation, "custom_score",
+}
+
+// scoreTaxonomy exercises every aggregation rule.
+var scoreTaxonomy = Taxonomy{Categories: []TaxonomyCategory{
+	{Name: "Response", Aggregation: AggregateWeighted, Weights: map[KPIKey]float64{KPI_MTTR: 3}},
+	{Name: "Detection", Aggregation: AggregateMin},
+	{Name: "Zero Trust", Subcategories: []TaxonomySubcategory{
+		{Name: "Identity", KPIs: []KPIKey{KPI_MFACoverage}},
+		{Name: "Devices", KPIs: []KPIKey{KPI_DeviceCompliance}},
+	}},
+}}
+
+// scoreInput is a random set of KPIs and compliance and risk metrics,
+// including values beyond their targets, zero targets, negative values and
+// every asset criticality tier.
+type scoreInput struct {
+	KPIs       []KPI
+	Compliance []SecurityMetric
+	Risk       []SecurityMetric
+	// Taxonomy selects whether scoreTaxonomy is applied.
+	Taxonomy bool
+}
+
+// Generate implements quick.Generator.
+func (scoreInput) Generate(r *rand.Rand, size int) reflect.Value {
+	amount := func(max float64) float64 {
+		switch r.Intn(6) {
+		case 0:
+			return 0
+		case 1:
+			return -r.Float64() * max / 4
+		default:
+			return r.Float64() * max
+		}
+	}
+	in := scoreInput{Taxonomy: r.Intn(2) == 0}
+	for i := r.Intn(10) + 1; i > 0; i-- {
+		in.KPIs = append(in.KPIs, KPI{Key: scoreKeys[r.Intn(len(scoreKeys))], Value: math.Abs(amount(200)), Target: math.Abs(amount(100))})
+	}
+	for i := r.Intn(5); i > 0; i-- {
+		in.Compliance = append(in.Compliance, SecurityMetric{Type: TypeCompliance, Value: amount(200), Target: amount(100)})
+	}
+	tiers := []Criticality{"", CriticalityStandard, CriticalityHigh, CriticalityCrownJewel}
+	for i := r.Intn(5); i > 0; i-- {
+		in.Risk = append(in.Risk, SecurityMetric{Type: TypeRisk, Value: amount(200), Criticality: tiers[r.Intn(len(tiers))]})
+	}
+	return reflect.ValueOf(in)
+}
+
+// collector returns a collector holding the input.
+func (in scoreInput) collector() *MetricsCollector {
+	c := NewMetricsCollector()
+	if in.Taxonomy {
+		if err := c.SetTaxonomy(scoreTaxonomy); err != nil {
+			panic(err)
+		}
+	}
+	for _, kpi := range in.KPIs {
+		c.AddKPI(kpi)
+	}
+	for _, metric := range append(append([]SecurityMetric(nil), in.Compliance...), in.Risk...) {
+		c.AddMetric(metric)
+	}
+	return c
+}
+
+// scores returns every score the collector computes, keyed by name.
+func scores(c *MetricsCollector) map[string]float64 {
+	all := map[string]float64{
+		"compliance":    c.GetComplianceScore(),
+		"risk":          c.GetRiskScore(),
+		"vulnerability": c.GetVulnerabilityScore(),
+		"posture":       c.GetPostureScore(),
+	}
+	for _, category := range c.GetCategorySummaries() {
+		all["category "+category.Category] = category.Score
+		for _, sub := range category.Subcategories {
+			all["category "+category.Category+" > "+sub.Category] = sub.Score
+		}
+	}
+	if scorecard := c.GetZeroTrustScorecard(); scorecard != nil {
+		all["zero trust"] = scorecard.Score
+	}
+	return all
+}
+
+var quickConfig = &quick.Config{MaxCount: 500}
+
+func TestScoresWithinBounds(t *testing.T) {
+	property := func(in scoreInput) bool {
+		c := in.collector()
+		for name, score := range scores(c) {
+			if math.IsNaN(score) || score < 0 || score > 100 {
+				t.Logf("%s score %v out of [0, 100] for %+v", name, score, in)
+				return false
+			}
+		}
+		for _, kpi := range c.GetKPIS() {
+			if progress := c.KPIProgress(kpi); progress < 0 || progress > 100 {
+				t.Logf("progress %v out of [0, 100] for %+v", progress, kpi)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScoresMonotonicWhenKPIImproves(t *testing.T) {
+	property := func(in scoreInput, pick uint8, step uint16) bool {
+		i := int(pick) % len(in.KPIs)
+		delta := float64(step%1000) / 10
+
+		improved := in
+		improved.KPIs = append([]KPI(nil), in.KPIs...)
+		def, _ := NewMetricsCollector().GetKPIDefinition(improved.KPIs[i].Key)
+		if def.Direction == LowerIsBetter {
+			improved.KPIs[i].Value = math.Max(0, improved.KPIs[i].Value-delta)
+		} else {
+			improved.KPIs[i].Value += delta
+		}
+
+		before, after := scores(in.collector()), scores(improved.collector())
+		for name, score := range before {
+			if after[name] < score-1e-9 {
+				t.Logf("%s score fell from %v to %v when %s improved by %v", name, score, after[name], in.KPIs[i].Key, delta)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestComplianceAndRiskMonotonic(t *testing.T) {
+	property := func(in scoreInput, pick uint8, step uint16) bool {
+		delta := float64(step%1000) / 10
+		raised := in
+		raised.Compliance = append([]SecurityMetric(nil), in.Compliance...)
+		raised.Risk = append([]SecurityMetric(nil), in.Risk...)
+		if len(raised.Compliance) > 0 {
+			raised.Compliance[int(pick)%len(raised.Compliance)].Value += delta
+		}
+		if len(raised.Risk) > 0 {
+			raised.Risk[int(pick)%len(raised.Risk)].Value += delta
+		}
+
+		before, after := in.collector(), raised.collector()
+		if after.GetComplianceScore() < before.GetComplianceScore()-1e-9 {
+			t.Logf("compliance fell from %v to %v", before.GetComplianceScore(), after.GetComplianceScore())
+			return false
+		}
+		// Higher risk metric values mean more risk.
+		if after.GetRiskScore() < before.GetRiskScore()-1e-9 {
+			t.Logf("risk fell from %v to %v", before.GetRiskScore(), after.GetRiskScore())
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+// windowSamples returns samples of values spread over the window ending
+// at end.
+func windowSamples(values []uint16, end time.Time, window Window) []KPISample {
+	samples := make([]KPISample, len(values))
+	step := window.Length / time.Duration(len(values)+1)
+	for i, v := range values {
+		samples[i] = KPISample{Key: KPI_MTTR, Value: float64(v) / 100, Timestamp: end.Add(-time.Duration(i+1) * step)}
+	}
+	return samples
+}
+
+// sameValues reports whether two aggregates match apart from their window
+// bounds.
+func sameValues(a, b WindowAggregate) bool {
+	a.Start, a.End, b.Start, b.End = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	return a == b
+}
+
+func TestScoresWeightedByCriticality(t *testing.T) {
+	c := NewMetricsCollector()
+	c.AddMetric(SecurityMetric{Type: TypeRisk, Value: 80, Asset: "payments-db", Criticality: CriticalityCrownJewel})
+	c.AddMetric(SecurityMetric{Type: TypeRisk, Value: 20, Asset: "kiosk-7"})
+	c.AddMetric(SecurityMetric{Type: TypeVulnerability, Value: 50, Asset: "web01", Criticality: CriticalityHigh})
+	c.AddMetric(SecurityMetric{Type: TypeVulnerability, Value: 20, Asset: "laptop-3", Criticality: CriticalityStandard})
+
+	// (80*4 + 20*1) / 5 and (50*2 + 20*1) / 3.
+	if got := c.GetRiskScore(); math.Abs(got-68) > 1e-9 {
+		t.Errorf("risk score = %v, want 68", got)
+	}
+	if got := c.GetSummary().VulnerabilityScore; math.Abs(got-40) > 1e-9 {
+		t.Errorf("vulnerability score = %v, want 40", got)
+	}
+
+	tiers := map[string]Criticality{
+		"Crown Jewel": CriticalityCrownJewel, "crown-jewel": CriticalityCrownJewel, "critical": CriticalityCrownJewel,
+		"HIGH": CriticalityHigh, "medium": CriticalityStandard, "": CriticalityStandard,
+	}
+	for s, want := range tiers {
+		if got := ParseCriticality(s); got != want {
+			t.Errorf("ParseCriticality(%q) = %q, want %q", s, got, want)
+		}
+	}
+	if got := CalculateWeightedCoverage(CriticalityHigh.Weight(), CriticalityHigh.Weight()+CriticalityCrownJewel.Weight()); math.Abs(got-100.0/3) > 1e-9 {
+		t.Errorf("weighted coverage = %v, want 33.3", got)
+	}
+}
+
+func TestWindowDiffSymmetric(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	window := Window7Days
+	compare := func(current, previous []uint16) WindowComparison {
+		c := NewMetricsCollector()
+		for _, sample := range windowSamples(current, now, window) {
+			c.AddKPISample(sample)
+		}
+		for _, sample := range windowSamples(previous, now.Add(-window.Length), window) {
+			c.AddKPISample(sample)
+		}
+		return c.CompareWindows(KPI_MTTR, window, now)
+	}
+
+	property := func(a, b []uint16) bool {
+		if len(a) == 0 || len(b) == 0 {
+			return true
+		}
+		forward, backward := compare(a, b), compare(b, a)
+		if !sameValues(forward.Current, backward.Previous) || !sameValues(forward.Previous, backward.Current) {
+			t.Logf("swapping windows did not swap aggregates: %+v vs %+v", forward, backward)
+			return false
+		}
+		if math.Abs(forward.Delta+backward.Delta) > 1e-9 {
+			t.Logf("delta %v is not the negation of %v", forward.Delta, backward.Delta)
+			return false
+		}
+		if self := compare(a, a); self.Delta != 0 {
+			t.Logf("delta of identical windows = %v", self.Delta)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestScoresSkipUnscorableData(t *testing.T) {
+	c := NewMetricsCollector()
+	if health := c.GetSummary().OverallHealth; health != InsufficientData {
+		t.Errorf("empty collector health = %s, want %s", health, InsufficientData)
+	}
+
+	c.AddKPI(KPI{Key: KPI_MTTR, Value: 3, Target: 2})
+	c.AddKPI(KPI{Key: KPI_Coverage, Value: math.NaN(), Target: 95})
+	c.AddKPI(KPI{Key: KPI_MFACoverage, Value: 80, Target: math.Inf(1)})
+	c.AddMetric(SecurityMetric{Name: "CIS", Type: TypeCompliance, Value: 80, Target: 0})
+	c.AddMetric(SecurityMetric{Name: "Drift", Type: TypeRisk, Value: math.NaN()})
+	c.AddMetric(SecurityMetric{Name: "Exposure", Type: TypeRisk, Value: 40})
+
+	for name, score := range scores(c) {
+		if math.IsNaN(score) || math.IsInf(score, 0) {
+			t.Errorf("%s score = %v", name, score)
+		}
+	}
+	if risk := c.GetRiskScore(); risk != 40 {
+		t.Errorf("risk score = %v, want 40 without the NaN metric", risk)
+	}
+	if health := c.GetSummary().OverallHealth; health != InsufficientData {
+		t.Errorf("health without a scorable compliance metric = %s", health)
+	}
+	for _, category := range c.GetCategorySummaries() {
+		switch category.Category {
+		case "Prevention", "Zero Trust":
+			// Coverage has no finite value, MFA coverage no finite target.
+			if category.Health != InsufficientData || category.Score != 0 || category.KPIs != 1 {
+				t.Errorf("%s = %+v, want insufficient data", category.Category, category)
+			}
+		}
+	}
+	if scorecard := c.GetZeroTrustScorecard(); scorecard != nil {
+		t.Errorf("zero trust scorecard from an infinite target = %+v", scorecard)
+	}
+	if posture := c.GetPostureScore(); math.Abs(posture-200.0/3) > 1e-9 {
+		t.Errorf("posture = %v, want MTTR's progress alone", posture)
+	}
+
+	c.AddMetric(SecurityMetric{Name: "SOC 2", Type: TypeCompliance, Value: 95, Target: 100})
+	if health := c.GetSummary().OverallHealth; health != "GOOD" {
+		t.Errorf("health = %s, want GOOD", health)
+	}
+	if mttr := CalculateMTTR([]float64{2, math.NaN(), 4}); mttr != 3 {
+		t.Errorf("CalculateMTTR = %v, want 3", mttr)
+	}
+}
+
+func TestSummaryReportsScoresWithoutData(t *testing.T) {
+	c := NewMetricsCollector()
+	c.AddMetric(SecurityMetric{Name: "Exposure", Type: TypeRisk, Value: 40})
+	c.AddKPI(KPI{Key: KPI_Coverage, Value: math.NaN(), Target: 95})
+
+	summary := c.GetSummary()
+	if want := (ScoreData{Risk: true}); summary.HasData != want {
+		t.Errorf("HasData = %+v, want %+v", summary.HasData, want)
+	}
+	report := c.GenerateReport()
+	for _, line := range []string{"Overall Health: INSUFFICIENT_DATA", "Compliance Score: n/a", "Risk Score: 40.0", "Vulnerability Score: n/a"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("report lacks %q:\n%s", line, report)
+		}
+	}
+
+	c.AddKPI(KPI{Key: KPI_MTTR, Value: 3, Target: 2})
+	c.AddMetric(SecurityMetric{Name: "SOC 2", Type: TypeCompliance, Value: 95, Target: 100})
+	if want := (ScoreData{Compliance: true, Risk: true, Posture: true}); c.GetSummary().HasData != want {
+		t.Errorf("HasData = %+v, want %+v", c.GetSummary().HasData, want)
+	}
+}
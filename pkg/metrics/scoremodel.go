@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultScoreModel is the version of the built-in score model.
+const DefaultScoreModel = "default"
+
+// ScoreModel configures how risk and vulnerability findings are weighted
+// by asset criticality and how the compliance and risk scores map to
+// overall health. The zero value is the built-in model.
+type ScoreModel struct {
+	// CriticalityWeights override the weights of asset tiers (crown_jewel,
+	// high, standard); unlisted tiers keep their built-in weight.
+	CriticalityWeights map[Criticality]float64 `yaml:"criticality_weights"`
+	// Health lists the health levels from best to worst; the first level
+	// whose minimum compliance and maximum risk the scores meet applies,
+	// and POOR when none does. Empty keeps the built-in levels.
+	Health []HealthLevel `yaml:"health"`
+}
+
+// HealthLevel is an overall health level and the scores it requires.
+type HealthLevel struct {
+	Name          string  `yaml:"name"`
+	MinCompliance float64 `yaml:"min_compliance"`
+	MaxRisk       float64 `yaml:"max_risk"`
+}
+
+// defaultHealth are the built-in health levels.
+var defaultHealth = []HealthLevel{
+	{Name: "HEALTHY", MinCompliance: 90, MaxRisk: 30},
+	{Name: "GOOD", MinCompliance: 70, MaxRisk: 50},
+	{Name: "FAIR", MinCompliance: 50, MaxRisk: 70},
+}
+
+// Validate checks that weights are positive and belong to known tiers, and
+// that health levels are named and within 0-100.
+func (m ScoreModel) Validate() error {
+	for tier, weight := range m.CriticalityWeights {
+		if ParseCriticality(string(tier)) != tier {
+			return fmt.Errorf("scoring: unknown criticality tier %q (want crown_jewel, high or standard)", tier)
+		}
+		if !finite(weight) || weight <= 0 {
+			return fmt.Errorf("scoring: weight of %s must be a positive number", tier)
+		}
+	}
+	for i, level := range m.Health {
+		if level.Name == "" {
+			return fmt.Errorf("scoring: health level %d has no name", i+1)
+		}
+		if level.MinCompliance < 0 || level.MinCompliance > 100 || level.MaxRisk < 0 || level.MaxRisk > 100 {
+			return fmt.Errorf("scoring: health level %s: scores must be within 0-100", level.Name)
+		}
+	}
+	return nil
+}
+
+// weight returns the score weight of tier, one of criticalityTiers, under
+// the model.
+func (m ScoreModel) weight(tier Criticality) float64 {
+	if weight, ok := m.CriticalityWeights[tier]; ok {
+		return weight
+	}
+	return criticalityWeights[tier]
+}
+
+// levels returns the model's health levels.
+func (m ScoreModel) levels() []HealthLevel {
+	if len(m.Health) == 0 {
+		return defaultHealth
+	}
+	return m.Health
+}
+
+// health returns the overall health for the compliance and risk scores.
+func (m ScoreModel) health(compliance, risk float64) string {
+	for _, level := range m.levels() {
+		if compliance >= level.MinCompliance && risk <= level.MaxRisk {
+			return level.Name
+		}
+	}
+	return "POOR"
+}
+
+// Version identifies the model's effective parameters: DefaultScoreModel
+// for the built-in model, and otherwise a fingerprint that changes
+// whenever a weight or health level does. Scores computed under different
+// versions are not comparable.
+func (m ScoreModel) Version() string {
+	params := m.params()
+	if params == (ScoreModel{}).params() {
+		return DefaultScoreModel
+	}
+	sum := sha256.Sum256([]byte(params))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// params describes the model's effective weights and health levels.
+func (m ScoreModel) params() string {
+	var b strings.Builder
+	for _, tier := range criticalityTiers {
+		fmt.Fprintf(&b, "%s=%g\n", tier, m.weight(tier))
+	}
+	for _, level := range m.levels() {
+		fmt.Fprintf(&b, "%s:%g:%g\n", level.Name, level.MinCompliance, level.MaxRisk)
+	}
+	return b.String()
+}
+
+// ScoreSample records the summary scores at one time, tagged with the
+// version of the score model that computed them.
+type ScoreSample struct {
+	Timestamp          time.Time
+	ScoreModel         string
+	ComplianceScore    float64
+	RiskScore          float64
+	VulnerabilityScore float64
+	PostureScore       float64
+	OverallHealth      string
+	HasData            ScoreData
+	// Inputs are the totals the scores were computed from, so that
+	// RecalculateScores can recompute them under another model.
+	Inputs ScoreInputs
+}
+
+// scoresOf computes the model's scores from inputs. The posture score does
+// not depend on the model and, like the timestamp and model version, is
+// left for the caller to set.
+func scoresOf(inputs ScoreInputs, model ScoreModel) ScoreSample {
+	sample := ScoreSample{Inputs: inputs}
+	sample.ComplianceScore, sample.HasData.Compliance = inputs.compliance()
+	sample.RiskScore, sample.HasData.Risk = weightedMean(inputs.Risk, model)
+	sample.VulnerabilityScore, sample.HasData.Vulnerability = weightedMean(inputs.Vulnerability, model)
+	sample.OverallHealth = model.health(sample.ComplianceScore, sample.RiskScore)
+	if !sample.HasData.Compliance {
+		// Health is judged on compliance first; without any compliance
+		// metric to score, a zero score would read as POOR.
+		sample.OverallHealth = InsufficientData
+	}
+	return sample
+}
+
+// SetScoreModel sets the score model and recomputes the summary under it.
+func (c *MetricsCollector) SetScoreModel(model ScoreModel) error {
+	if err := model.Validate(); err != nil {
+		return err
+	}
+	c.model, c.modelVersion = model, model.Version()
+	c.updateSummary()
+	return nil
+}
+
+// GetScoreModel returns the score model.
+func (c *MetricsCollector) GetScoreModel() ScoreModel {
+	return c.model
+}
+
+// RecordScores adds the current summary scores to the score history,
+// replacing any sample recorded earlier the same day (UTC), so the history
+// keeps one sample per day.
+func (c *MetricsCollector) RecordScores() {
+	sample := scoresOf(c.totals, c.model)
+	sample.Timestamp, sample.ScoreModel = c.now(), c.modelVersion
+	sample.PostureScore, sample.HasData.Posture = c.postureScore()
+	// Inputs are copied, since the collector keeps adding to its own.
+	sample.Inputs.Risk = copyTiers(c.totals.Risk)
+	sample.Inputs.Vulnerability = copyTiers(c.totals.Vulnerability)
+
+	if n := len(c.scores); n > 0 && sameDay(c.scores[n-1].Timestamp, sample.Timestamp) {
+		c.scores[n-1] = sample
+		return
+	}
+	c.scores = append(c.scores, sample)
+}
+
+// copyTiers returns a copy of totals.
+func copyTiers(totals map[Criticality]TierTotal) map[Criticality]TierTotal {
+	if totals == nil {
+		return nil
+	}
+	copied := make(map[Criticality]TierTotal, len(totals))
+	for tier, total := range totals {
+		copied[tier] = total
+	}
+	return copied
+}
+
+// sameDay reports whether a and b fall on the same UTC day.
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.UTC().Date()
+	by, bm, bd := b.UTC().Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// GetScoreHistory returns the recorded score samples ordered by time.
+func (c *MetricsCollector) GetScoreHistory() []ScoreSample {
+	return c.scores
+}
+
+// RestoreScores replaces the score history with previously saved samples.
+func (c *MetricsCollector) RestoreScores(samples []ScoreSample) {
+	c.scores = append(make([]ScoreSample, 0, len(samples)), samples...)
+	sort.SliceStable(c.scores, func(i, j int) bool {
+		return c.scores[i].Timestamp.Before(c.scores[j].Timestamp)
+	})
+}
+
+// RecalculateScores recomputes every sample of the score history from its
+// inputs under the current score model and returns the samples as they
+// were before, for comparison. Posture scores do not depend on the model
+// and are kept.
+func (c *MetricsCollector) RecalculateScores() []ScoreSample {
+	before := append([]ScoreSample(nil), c.scores...)
+	for i, sample := range c.scores {
+		recalculated := scoresOf(sample.Inputs, c.model)
+		recalculated.Timestamp, recalculated.ScoreModel = sample.Timestamp, c.modelVersion
+		recalculated.PostureScore, recalculated.HasData.Posture = sample.PostureScore, sample.HasData.Posture
+		c.scores[i] = recalculated
+	}
+	return before
+}
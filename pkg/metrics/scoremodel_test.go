@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestScoreModelVersion(t *testing.T) {
+	if version := (ScoreModel{}).Version(); version != DefaultScoreModel {
+		t.Errorf("zero model version = %s", version)
+	}
+	// Spelling out the built-in parameters is still the built-in model
+	explicit := ScoreModel{CriticalityWeights: map[Criticality]float64{CriticalityHigh: 2}, Health: defaultHealth}
+	if version := explicit.Version(); version != DefaultScoreModel {
+		t.Errorf("explicit default model version = %s", version)
+	}
+	heavier := ScoreModel{CriticalityWeights: map[Criticality]float64{CriticalityCrownJewel: 10}}
+	stricter := ScoreModel{Health: []HealthLevel{{Name: "HEALTHY", MinCompliance: 95, MaxRisk: 20}}}
+	if !strings.HasPrefix(heavier.Version(), "sha256:") || heavier.Version() == stricter.Version() {
+		t.Errorf("versions %s and %s", heavier.Version(), stricter.Version())
+	}
+
+	for _, model := range []ScoreModel{
+		{CriticalityWeights: map[Criticality]float64{"critical": 4}},
+		{CriticalityWeights: map[Criticality]float64{CriticalityHigh: 0}},
+		{Health: []HealthLevel{{MinCompliance: 90}}},
+		{Health: []HealthLevel{{Name: "OK", MaxRisk: 120}}},
+	} {
+		if err := model.Validate(); err == nil {
+			t.Errorf("model %+v accepted", model)
+		}
+	}
+}
+
+func TestRecalculateScores(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 9, 1, 9, 0, 0, 0, time.UTC))
+	collector := NewMetricsCollector()
+	collector.SetClock(clk)
+	collector.AddMetric(SecurityMetric{Type: TypeCompliance, Value: 80, Target: 100})
+	collector.AddMetric(SecurityMetric{Type: TypeRisk, Value: 80, Criticality: CriticalityCrownJewel})
+	collector.AddMetric(SecurityMetric{Type: TypeRisk, Value: 20})
+	collector.RecordScores()
+	// A second collection the same day replaces the day's sample
+	clk.Advance(time.Hour)
+	collector.RecordScores()
+	clk.Advance(24 * time.Hour)
+	collector.AddMetric(SecurityMetric{Type: TypeRisk, Value: 20})
+	collector.RecordScores()
+
+	history := collector.GetScoreHistory()
+	if len(history) != 2 {
+		t.Fatalf("score history = %+v, want one sample per day", history)
+	}
+	// (4*80 + 20) / 5
+	if first := history[0]; first.ScoreModel != DefaultScoreModel || first.RiskScore != 68 || first.OverallHealth != "FAIR" {
+		t.Errorf("first sample = %+v", first)
+	}
+
+	model := ScoreModel{
+		CriticalityWeights: map[Criticality]float64{CriticalityCrownJewel: 1},
+		Health:             []HealthLevel{{Name: "GOOD", MinCompliance: 75, MaxRisk: 50}},
+	}
+	if err := collector.SetScoreModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if summary := collector.GetSummary(); summary.ScoreModel != model.Version() || summary.RiskScore != 40 || summary.OverallHealth != "GOOD" {
+		t.Errorf("summary under the new model = %+v", summary)
+	}
+
+	before := collector.RecalculateScores()
+	after := collector.GetScoreHistory()
+	if before[0].RiskScore != 68 || before[0].ScoreModel != DefaultScoreModel {
+		t.Errorf("samples before recalculation = %+v", before)
+	}
+	// (80 + 20) / 2 and (80 + 20 + 20) / 3
+	if after[0].RiskScore != 50 || after[0].OverallHealth != "GOOD" || after[1].RiskScore != 40 || after[0].ScoreModel != model.Version() {
+		t.Errorf("recalculated samples = %+v", after)
+	}
+	if !after[0].Timestamp.Equal(history[0].Timestamp) || !after[0].HasData.Compliance {
+		t.Errorf("recalculation lost the sample's time or data: %+v", after[0])
+	}
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import "math"
+
+// runningMean accumulates weighted values for a mean.
+type runningMean struct {
+	sum    float64
+	weight float64
+}
+
+// add accounts for value with weight. Non-finite values and weights that
+// are not positive are skipped, so one bad metric cannot turn a score into
+// NaN.
+func (m *runningMean) add(value, weight float64) {
+	if !finite(value) || !finite(weight) || weight <= 0 {
+		return
+	}
+	m.sum += value * weight
+	m.weight += weight
+}
+
+// mean returns the weighted mean of the values added, or 0 if there are
+// none.
+func (m runningMean) mean() float64 {
+	if m.empty() {
+		return 0.0
+	}
+	return m.sum / m.weight
+}
+
+// empty reports whether no value has been added.
+func (m runningMean) empty() bool {
+	return m.weight == 0
+}
+
+// TierTotal is a sum of finding scores and how many were summed.
+type TierTotal struct {
+	Sum   float64
+	Count int
+}
+
+// ScoreInputs are the totals the compliance, risk and vulnerability scores
+// are computed from, kept up to date as metrics are added so adding a
+// metric does not rescan every metric collected. Risk and vulnerability
+// findings are totaled per asset criticality tier, so the scores can be
+// recomputed under another score model's tier weights.
+type ScoreInputs struct {
+	Compliance    TierTotal
+	Risk          map[Criticality]TierTotal
+	Vulnerability map[Criticality]TierTotal
+}
+
+// add accounts for a new metric. Metrics with a non-finite value, and
+// compliance metrics without a positive target to measure progress
+// against, are skipped.
+func (t *ScoreInputs) add(metric SecurityMetric) {
+	switch metric.Type {
+	case TypeCompliance:
+		if !finite(metric.Target) || metric.Target <= 0 {
+			return
+		}
+		if progress := pillarProgress(metric.Value, metric.Target); finite(progress) {
+			t.Compliance.Sum += progress
+			t.Compliance.Count++
+		}
+	case TypeRisk:
+		t.Risk = addToTier(t.Risk, metric)
+	case TypeVulnerability:
+		t.Vulnerability = addToTier(t.Vulnerability, metric)
+	}
+}
+
+// addToTier adds metric's value, clamped to 0-100, to the total of its
+// asset's tier, allocating totals on first use.
+func addToTier(totals map[Criticality]TierTotal, metric SecurityMetric) map[Criticality]TierTotal {
+	value := math.Max(0, math.Min(100, metric.Value))
+	if !finite(value) {
+		return totals
+	}
+	if totals == nil {
+		totals = make(map[Criticality]TierTotal, len(criticalityTiers))
+	}
+	tier := ParseCriticality(string(metric.Criticality))
+	total := totals[tier]
+	total.Sum += value
+	total.Count++
+	totals[tier] = total
+	return totals
+}
+
+// compliance returns the mean progress of compliance metrics and whether
+// there were any.
+func (t ScoreInputs) compliance() (float64, bool) {
+	if t.Compliance.Count == 0 {
+		return 0, false
+	}
+	return t.Compliance.Sum / float64(t.Compliance.Count), true
+}
+
+// weightedMean returns the mean of the totals with each tier weighted by
+// model, and whether there were any values.
+func weightedMean(totals map[Criticality]TierTotal, model ScoreModel) (float64, bool) {
+	var mean runningMean
+	for _, tier := range criticalityTiers {
+		if total := totals[tier]; total.Count > 0 {
+			mean.add(total.Sum/float64(total.Count), model.weight(tier)*float64(total.Count))
+		}
+	}
+	return math.Min(100, mean.mean()), !mean.empty()
+}
+
+// totalsOf computes the totals for metrics from scratch.
+func totalsOf(metrics []SecurityMetric) ScoreInputs {
+	var t ScoreInputs
+	for _, metric := range metrics {
+		t.add(metric)
+	}
+	return t
+}
+
+// finite reports whether v is neither NaN nor infinite.
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
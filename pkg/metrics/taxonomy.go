@@ -0,0 +1,249 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Aggregation rules for combining KPI progress into a category score.
+const (
+	AggregateMean     = "mean"
+	AggregateMin      = "min"
+	AggregateWeighted = "weighted"
+)
+
+// CategorySeparator separates a category from its subcategory in KPI
+// category strings such as "Detect > Continuous Monitoring".
+const CategorySeparator = " > "
+
+// Taxonomy is an organization-specific hierarchy of KPI categories, such
+// as the NIST CSF functions and categories.
+type Taxonomy struct {
+	// Strict rejects KPIs whose category cannot be resolved; otherwise they
+	// are grouped under Uncategorized.
+	Strict     bool               `yaml:"strict"`
+	Categories []TaxonomyCategory `yaml:"categories"`
+}
+
+// TaxonomyCategory is a top-level category.
+type TaxonomyCategory struct {
+	Name string `yaml:"name"`
+	// Aggregation is mean (default), min or weighted.
+	Aggregation string `yaml:"aggregation"`
+	// Weights are per-KPI weights for weighted aggregation; unlisted KPIs
+	// weigh 1.
+	Weights map[KPIKey]float64 `yaml:"weights"`
+	// KPIs and Aliases map KPI keys and free-form category names to this
+	// category.
+	KPIs          []KPIKey              `yaml:"kpis"`
+	Aliases       []string              `yaml:"aliases"`
+	Subcategories []TaxonomySubcategory `yaml:"subcategories"`
+}
+
+// TaxonomySubcategory is a subcategory of a TaxonomyCategory.
+type TaxonomySubcategory struct {
+	Name    string   `yaml:"name"`
+	KPIs    []KPIKey `yaml:"kpis"`
+	Aliases []string `yaml:"aliases"`
+}
+
+// IsZero reports whether the taxonomy defines no categories.
+func (t Taxonomy) IsZero() bool {
+	return len(t.Categories) == 0
+}
+
+// Validate checks that names, aliases and KPI mappings are unambiguous and
+// that aggregation rules are known.
+func (t Taxonomy) Validate() error {
+	names := make(map[string]string)
+	claim := func(name, owner string) error {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("taxonomy: %s has an empty name or alias", owner)
+		}
+		if strings.Contains(name, strings.TrimSpace(CategorySeparator)) {
+			return fmt.Errorf("taxonomy: %q must not contain %q", name, strings.TrimSpace(CategorySeparator))
+		}
+		if previous, ok := names[strings.ToLower(name)]; ok {
+			return fmt.Errorf("taxonomy: %q is used by both %s and %s", name, previous, owner)
+		}
+		names[strings.ToLower(name)] = owner
+		return nil
+	}
+	keys := make(map[KPIKey]string)
+	claimKeys := func(kpis []KPIKey, owner string) error {
+		for _, key := range kpis {
+			if previous, ok := keys[key]; ok {
+				return fmt.Errorf("taxonomy: kpi %s is mapped to both %s and %s", key, previous, owner)
+			}
+			keys[key] = owner
+		}
+		return nil
+	}
+
+	for _, category := range t.Categories {
+		owner := "category " + category.Name
+		if err := claim(category.Name, owner); err != nil {
+			return err
+		}
+		switch category.Aggregation {
+		case "", AggregateMean, AggregateMin, AggregateWeighted:
+		default:
+			return fmt.Errorf("taxonomy: %s: unknown aggregation %q", owner, category.Aggregation)
+		}
+		for key, weight := range category.Weights {
+			if weight <= 0 {
+				return fmt.Errorf("taxonomy: %s: weight for %s must be positive", owner, key)
+			}
+		}
+		for _, alias := range category.Aliases {
+			if err := claim(alias, owner); err != nil {
+				return err
+			}
+		}
+		if err := claimKeys(category.KPIs, owner); err != nil {
+			return err
+		}
+
+		for _, sub := range category.Subcategories {
+			subOwner := "subcategory " + category.Name + CategorySeparator + sub.Name
+			if err := claim(sub.Name, subOwner); err != nil {
+				return err
+			}
+			for _, alias := range sub.Aliases {
+				if err := claim(alias, subOwner); err != nil {
+					return err
+				}
+			}
+			if err := claimKeys(sub.KPIs, subOwner); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// category returns the top-level category called name.
+func (t Taxonomy) category(name string) (TaxonomyCategory, bool) {
+	for _, category := range t.Categories {
+		if category.Name == name {
+			return category, true
+		}
+	}
+	return TaxonomyCategory{}, false
+}
+
+// resolve maps a KPI to its category and subcategory in the taxonomy.
+func (t Taxonomy) resolve(kpi KPI) (string, string, bool) {
+	for _, category := range t.Categories {
+		for _, sub := range category.Subcategories {
+			if containsKey(sub.KPIs, kpi.Key) {
+				return category.Name, sub.Name, true
+			}
+		}
+		if containsKey(category.KPIs, kpi.Key) {
+			return category.Name, "", true
+		}
+	}
+
+	parent, child := splitCategory(kpi.Category)
+	for _, category := range t.Categories {
+		if child != "" && !matchesName(parent, category.Name, category.Aliases) {
+			continue
+		}
+		for _, sub := range category.Subcategories {
+			name := parent
+			if child != "" {
+				name = child
+			}
+			if matchesName(name, sub.Name, sub.Aliases) {
+				return category.Name, sub.Name, true
+			}
+		}
+		if child == "" && matchesName(parent, category.Name, category.Aliases) {
+			return category.Name, "", true
+		}
+	}
+	return "", "", false
+}
+
+// SetTaxonomy validates and applies a category taxonomy; a zero taxonomy
+// restores the free-form KPI categories.
+func (c *MetricsCollector) SetTaxonomy(taxonomy Taxonomy) error {
+	if err := taxonomy.Validate(); err != nil {
+		return err
+	}
+	c.taxonomy = taxonomy
+	c.updateSummary()
+	return nil
+}
+
+// GetTaxonomy returns the collector's category taxonomy.
+func (c *MetricsCollector) GetTaxonomy() Taxonomy {
+	return c.taxonomy
+}
+
+// CategoryPath returns the category and subcategory a KPI is grouped
+// under. Without a taxonomy they come from the KPI's own category string.
+func (c *MetricsCollector) CategoryPath(kpi KPI) (string, string) {
+	if c.taxonomy.IsZero() {
+		category, sub := splitCategory(kpi.Category)
+		if category == "" {
+			return Uncategorized, ""
+		}
+		return category, sub
+	}
+	if category, sub, ok := c.taxonomy.resolve(kpi); ok {
+		return category, sub
+	}
+	return Uncategorized, ""
+}
+
+// ValidateCategory checks a KPI's category, or its definition's category
+// when unset, against a strict taxonomy.
+func (c *MetricsCollector) ValidateCategory(kpi KPI) error {
+	if c.taxonomy.IsZero() || !c.taxonomy.Strict {
+		return nil
+	}
+	if def, ok := c.definitions[kpi.Key]; ok && kpi.Category == "" {
+		kpi.Category = def.Category
+	}
+	if _, _, ok := c.taxonomy.resolve(kpi); !ok {
+		return fmt.Errorf("kpi %s: category %q is not in the taxonomy", kpi.Key, kpi.Category)
+	}
+	return nil
+}
+
+// JoinCategory formats a category path as "Category > Subcategory".
+func JoinCategory(category, sub string) string {
+	if sub == "" {
+		return category
+	}
+	return category + CategorySeparator + sub
+}
+
+// splitCategory splits "Category > Subcategory" into its parts.
+func splitCategory(value string) (string, string) {
+	parent, child, _ := strings.Cut(value, strings.TrimSpace(CategorySeparator))
+	return strings.TrimSpace(parent), strings.TrimSpace(child)
+}
+
+func matchesName(value, name string, aliases []string) bool {
+	if strings.EqualFold(value, name) {
+		return true
+	}
+	for _, alias := range aliases {
+		if strings.EqualFold(value, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsKey(keys []KPIKey, key KPIKey) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
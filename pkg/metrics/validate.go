@@ -0,0 +1,126 @@
+package metrics
+
+import "fmt"
+
+// builtinUnits are the units metrics and KPIs may be measured in without
+// registering them: percentages, durations, scores and the things counted
+// by the built-in sources.
+var builtinUnits = map[string]bool{
+	"%": true, "score": true,
+	"ms": true, "seconds": true, "minutes": true, "hours": true, "days": true,
+	"accounts": true, "alerts": true, "assets": true, "certs": true, "commits": true,
+	"controls": true, "credentials": true, "endpoints": true, "events": true,
+	"findings": true, "incidents": true, "messages": true, "repos": true,
+	"requests": true, "users": true, "visits": true, "vulnerabilities": true,
+}
+
+// IsBuiltinUnit reports whether unit is known without registering it.
+// The empty unit, for unitless values, is always known.
+func IsBuiltinUnit(unit string) bool {
+	return unit == "" || builtinUnits[unit]
+}
+
+// RegisterUnit makes unit known to the collector's validation, e.g. for
+// metrics counted in "clicks". Units of registered KPI definitions are
+// known already.
+func (c *MetricsCollector) RegisterUnit(unit string) {
+	if c.units == nil {
+		c.units = make(map[string]bool)
+	}
+	c.units[unit] = true
+}
+
+// knownUnit reports whether unit is built in, registered or the unit of a
+// KPI definition.
+func (c *MetricsCollector) knownUnit(unit string) bool {
+	if IsBuiltinUnit(unit) || c.units[unit] {
+		return true
+	}
+	for _, def := range c.definitions {
+		if def.Unit == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that a metric is named, its value and target are finite
+// numbers that are not negative in any unit, its unit is built in, a
+// compliance metric, whose score divides by the target, has a positive
+// target and its evidence is valid.
+func (m SecurityMetric) Validate() error {
+	return m.validate(IsBuiltinUnit)
+}
+
+func (m SecurityMetric) validate(known func(string) bool) error {
+	if m.Name == "" {
+		return fmt.Errorf("metric %q requires a name", m.ID)
+	}
+	if err := validateValues("metric "+m.Name, m.Unit, m.Value, m.Target, true, known); err != nil {
+		return err
+	}
+	if m.Type == TypeCompliance && m.Target <= 0 {
+		return fmt.Errorf("metric %s: compliance metrics require a positive target, since the compliance score is the value as a share of it", m.Name)
+	}
+	for _, evidence := range m.Evidence {
+		if err := evidence.Validate(); err != nil {
+			return fmt.Errorf("metric %s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks that a KPI has a key and a name, its value and target
+// are finite numbers that are not negative in any unit and its unit is
+// built in. Progress toward a target of zero cannot be measured for KPIs
+// where higher is better; the collector's ValidateKPI checks that too, as
+// it knows the KPI's direction.
+func (k KPI) Validate() error {
+	return k.validate(IsBuiltinUnit, true)
+}
+
+// validate checks k, rejecting negative values only when unsigned.
+func (k KPI) validate(known func(string) bool, unsigned bool) error {
+	if k.Key == "" {
+		return fmt.Errorf("kpi %q requires a key", k.Name)
+	}
+	if k.Name == "" {
+		return fmt.Errorf("kpi %s requires a name", k.Key)
+	}
+	return validateValues("kpi "+string(k.Key), k.Unit, k.Value, k.Target, unsigned, known)
+}
+
+// validateValues checks the value, target and unit of what.
+func validateValues(what, unit string, value, target float64, unsigned bool, known func(string) bool) error {
+	values := []struct {
+		name  string
+		value float64
+	}{{"value", value}, {"target", target}}
+	for _, v := range values {
+		if !finite(v.value) {
+			return fmt.Errorf("%s: %s %v is not a finite number", what, v.name, v.value)
+		}
+	}
+	if !known(unit) {
+		return fmt.Errorf("%s: unknown unit %q", what, unit)
+	}
+	for _, v := range values {
+		if unsigned && unit != "" && v.value < 0 {
+			return fmt.Errorf("%s: %s %.2f is negative, but %s cannot be", what, v.name, v.value, unitNoun(unit))
+		}
+	}
+	return nil
+}
+
+// unitNoun names the quantities measured in unit for error messages.
+func unitNoun(unit string) string {
+	switch unit {
+	case "%":
+		return "percentages"
+	case "score":
+		return "scores"
+	case "ms", "seconds", "minutes", "hours", "days":
+		return "durations"
+	}
+	return "counts of " + unit
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"valid metric", SecurityMetric{Name: "Open findings", Value: 3, Unit: "findings"}.Validate(), ""},
+		{"unitless negative", SecurityMetric{Name: "Drift", Value: -2}.Validate(), ""},
+		{"unnamed metric", SecurityMetric{ID: "m1", Value: 3}.Validate(), `metric "m1" requires a name`},
+		{"NaN value", SecurityMetric{Name: "Open findings", Value: math.NaN()}.Validate(), "value NaN is not a finite number"},
+		{"infinite target", SecurityMetric{Name: "Open findings", Target: math.Inf(1)}.Validate(), "target +Inf is not a finite number"},
+		{"negative count", SecurityMetric{Name: "Open findings", Value: -1, Unit: "findings"}.Validate(), "counts of findings cannot be"},
+		{"unknown unit", SecurityMetric{Name: "Open findings", Unit: "widgets"}.Validate(), `unknown unit "widgets"`},
+		{"compliance without target", SecurityMetric{Name: "CIS", Type: TypeCompliance, Value: 80, Unit: "%"}.Validate(), "require a positive target"},
+		{"valid KPI", KPI{Key: KPI_MTTR, Name: "MTTR", Value: 3, Target: 2, Unit: "hours"}.Validate(), ""},
+		{"KPI without key", KPI{Name: "MTTR"}.Validate(), `kpi "MTTR" requires a key`},
+		{"KPI without name", KPI{Key: KPI_MTTR}.Validate(), "kpi mttr requires a name"},
+		{"negative duration", KPI{Key: KPI_MTTR, Name: "MTTR", Value: -3, Unit: "hours"}.Validate(), "durations cannot be"},
+	} {
+		switch {
+		case tc.want == "" && tc.err != nil:
+			t.Errorf("%s: %v", tc.name, tc.err)
+		case tc.want != "" && (tc.err == nil || !strings.Contains(tc.err.Error(), tc.want)):
+			t.Errorf("%s: error = %v, want %q", tc.name, tc.err, tc.want)
+		}
+	}
+}
+
+func TestCollectorValidation(t *testing.T) {
+	c := NewMetricsCollector()
+	if err := c.ValidateMetric(SecurityMetric{Name: "Phishing", Value: 4, Unit: "clicks"}); err == nil {
+		t.Error("unregistered unit accepted")
+	}
+	c.RegisterUnit("clicks")
+	if err := c.ValidateMetric(SecurityMetric{Name: "Phishing", Value: 4, Unit: "clicks"}); err != nil {
+		t.Errorf("registered unit: %v", err)
+	}
+
+	if err := c.RegisterKPIDefinition(KPIDefinition{Key: "net_promoter", Name: "Net Promoter", Unit: "points", Min: Bound(-100), Max: Bound(100)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ValidateKPI(KPI{Key: "net_promoter", Value: -20, Target: 30}); err != nil {
+		t.Errorf("definition unit and minimum: %v", err)
+	}
+	if err := c.ValidateKPI(KPI{Key: KPI_Coverage, Value: 80}); err == nil || !strings.Contains(err.Error(), "target 0.00 must be positive") {
+		t.Errorf("zero coverage target: %v", err)
+	}
+	if err := c.ValidateKPI(KPI{Key: KPI_MTTR, Value: 3}); err != nil {
+		t.Errorf("zero MTTR target, where lower is better: %v", err)
+	}
+}
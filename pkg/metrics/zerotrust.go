@@ -0,0 +1,100 @@
+package metrics
+
+// Zero trust pillar KPI keys.
+const (
+	KPI_DeviceCompliance    KPIKey = "device_compliance"
+	KPI_MFACoverage         KPIKey = "mfa_coverage"
+	KPI_NetworkSegmentation KPIKey = "network_segmentation"
+	KPI_LeastPrivilege      KPIKey = "least_privilege"
+)
+
+// ZeroTrustPillar maps a zero trust pillar to the KPI measuring it.
+type ZeroTrustPillar struct {
+	Name   string
+	Key    KPIKey
+	Weight float64
+}
+
+// ZeroTrustPillars are the pillars making up the zero trust scorecard.
+var ZeroTrustPillars = []ZeroTrustPillar{
+	{Name: "Identity", Key: KPI_MFACoverage, Weight: 1},
+	{Name: "Devices", Key: KPI_DeviceCompliance, Weight: 1},
+	{Name: "Networks", Key: KPI_NetworkSegmentation, Weight: 1},
+	{Name: "Access", Key: KPI_LeastPrivilege, Weight: 1},
+}
+
+// PillarScore represents progress on one zero trust pillar.
+type PillarScore struct {
+	Pillar ZeroTrustPillar
+	KPI    *KPI
+	// Progress is the KPI value as a percentage of its target, capped at 100.
+	Progress float64
+}
+
+// Measured reports whether the pillar's KPI has been collected.
+func (p PillarScore) Measured() bool {
+	return p.KPI != nil
+}
+
+// ZeroTrustScorecard represents composite zero trust adoption.
+type ZeroTrustScorecard struct {
+	// Score is the weighted mean progress of measured pillars.
+	Score    float64
+	Maturity string
+	Pillars  []PillarScore
+}
+
+// GetZeroTrustScorecard builds the zero trust adoption scorecard from the
+// pillar KPIs, or returns nil when no pillar has been measured.
+func (c *MetricsCollector) GetZeroTrustScorecard() *ZeroTrustScorecard {
+	scorecard := &ZeroTrustScorecard{}
+	var weighted, weights float64
+
+	for _, pillar := range ZeroTrustPillars {
+		score := PillarScore{Pillar: pillar}
+		// A pillar KPI without a finite value or target, or still a
+		// draft, counts as not measured.
+		if kpi := c.GetKPI(pillar.Key); kpi != nil && finite(kpi.Value) && finite(kpi.Target) && c.isScored(*kpi) {
+			kpiCopy := *kpi
+			score.KPI = &kpiCopy
+			score.Progress = pillarProgress(kpi.Value, kpi.Target)
+			weighted += score.Progress * pillar.Weight
+			weights += pillar.Weight
+		}
+		scorecard.Pillars = append(scorecard.Pillars, score)
+	}
+
+	if weights == 0 {
+		return nil
+	}
+	scorecard.Score = weighted / weights
+	scorecard.Maturity = zeroTrustMaturity(scorecard.Score)
+	return scorecard
+}
+
+// pillarProgress returns value as a percentage of target, capped at 100.
+func pillarProgress(value, target float64) float64 {
+	if target <= 0 {
+		return 100
+	}
+	progress := value / target * 100
+	if progress > 100 {
+		return 100
+	}
+	if progress < 0 {
+		return 0
+	}
+	return progress
+}
+
+// zeroTrustMaturity maps a score to a CISA Zero Trust Maturity Model stage.
+func zeroTrustMaturity(score float64) string {
+	if score >= 90 {
+		return "OPTIMAL"
+	} else if score >= 70 {
+		return "ADVANCED"
+	} else if score >= 40 {
+		return "INITIAL"
+	}
+	return "TRADITIONAL"
+}
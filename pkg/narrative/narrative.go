@@ -0,0 +1,335 @@
+// Package narrative drafts the narrative of a quarter's security metrics
+// through a summarization endpoint, such as an LLM gateway, and keeps the
+// drafts for analysts to edit and approve before they appear in reports.
+package narrative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// DefaultTimeout bounds a summarization request, which may take a while
+// for a model to answer.
+const DefaultTimeout = 2 * time.Minute
+
+// DefaultInstructions ask the endpoint for a narrative when none are
+// configured.
+const DefaultInstructions = "Write a draft narrative of this quarter's security metrics for a " +
+	"quarterly business review: overall posture, the KPIs that improved or declined and why it " +
+	"matters, notable incidents and alerts, and where to focus next quarter. Use only the data " +
+	"provided, in three to five short paragraphs of plain text."
+
+// Draft states.
+const (
+	StatusDraft    = "draft"
+	StatusApproved = "approved"
+)
+
+// Config configures the summarization endpoint. Drafting is disabled
+// unless a URL is set.
+type Config struct {
+	// URL receives the quarter's data as JSON and answers with a
+	// narrative; see Request and Response.
+	URL string `yaml:"url"`
+	// TokenEnv names the environment variable holding a bearer token for
+	// the endpoint.
+	TokenEnv string `yaml:"token_env"`
+	// Model is passed to the endpoint as is.
+	Model string `yaml:"model"`
+	// Instructions replace DefaultInstructions.
+	Instructions string `yaml:"instructions"`
+	// Timeout bounds a request, e.g. "2m" (default).
+	Timeout string `yaml:"timeout"`
+	// Client carries the proxy and trusted CAs of the top-level http
+	// section; it is set by the caller rather than from the config file.
+	Client *http.Client `yaml:"-"`
+}
+
+// Request is the body posted to the endpoint.
+type Request struct {
+	Model        string `json:"model,omitempty"`
+	Instructions string `json:"instructions"`
+	Data         Input  `json:"data"`
+}
+
+// Response is the endpoint's answer.
+type Response struct {
+	Narrative string `json:"narrative"`
+}
+
+// Input is the quarter's KPI data the narrative is drafted from.
+// MissingScores names the scores without data to compute them from, which
+// are 0 and must not be read as measurements.
+type Input struct {
+	Quarter            string         `json:"quarter"`
+	Start              time.Time      `json:"start"`
+	End                time.Time      `json:"end"`
+	OverallHealth      string         `json:"overall_health"`
+	ComplianceScore    float64        `json:"compliance_score"`
+	RiskScore          float64        `json:"risk_score"`
+	VulnerabilityScore float64        `json:"vulnerability_score"`
+	MissingScores      []string       `json:"missing_scores,omitempty"`
+	KPIs               []KPIInput     `json:"kpis"`
+	Changes            []string       `json:"changes"`
+	Incidents          IncidentsInput `json:"incidents"`
+	Alerts             AlertsInput    `json:"alerts"`
+}
+
+// KPIInput is how a KPI moved over the quarter.
+type KPIInput struct {
+	Key      string  `json:"key"`
+	Name     string  `json:"name"`
+	Category string  `json:"category,omitempty"`
+	Unit     string  `json:"unit,omitempty"`
+	Target   float64 `json:"target"`
+	Status   string  `json:"status"`
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+	Delta    float64 `json:"delta"`
+	// Trend is IMPROVING, DECLINING or STABLE according to the KPI's
+	// direction.
+	Trend string `json:"trend"`
+}
+
+// IncidentsInput summarizes the quarter's incidents.
+type IncidentsInput struct {
+	Total      int            `json:"total"`
+	Resolved   int            `json:"resolved"`
+	BySeverity map[string]int `json:"by_severity"`
+}
+
+// AlertsInput summarizes the quarter's alerts.
+type AlertsInput struct {
+	Total        int `json:"total"`
+	Acknowledged int `json:"acknowledged"`
+	Resolved     int `json:"resolved"`
+	Escalated    int `json:"escalated"`
+	// MeanTimeToAcknowledge is in hours.
+	MeanTimeToAcknowledge float64 `json:"mean_time_to_acknowledge"`
+}
+
+// Draft is a narrative kept for review. Analysts edit Narrative in the
+// drafts file; only approved drafts appear in reports.
+type Draft struct {
+	Quarter     string    `yaml:"quarter"`
+	Status      string    `yaml:"status"`
+	GeneratedAt time.Time `yaml:"generated_at"`
+	Model       string    `yaml:"model,omitempty"`
+	Narrative   string    `yaml:"narrative"`
+	ApprovedBy  string    `yaml:"approved_by,omitempty"`
+	ApprovedAt  time.Time `yaml:"approved_at,omitempty"`
+}
+
+// missingScores returns the names of the scores without data, as named in
+// Input.
+func missingScores(hasData metrics.ScoreData) []string {
+	var missing []string
+	if !hasData.Compliance {
+		missing = append(missing, "compliance_score")
+	}
+	if !hasData.Risk {
+		missing = append(missing, "risk_score")
+	}
+	if !hasData.Vulnerability {
+		missing = append(missing, "vulnerability_score")
+	}
+	return missing
+}
+
+// ParseQuarter parses a quarter of calendar such as 2026-Q3 or FY2027-Q1
+// and returns its label and local start and end. An empty quarter is the
+// quarter of now.
+func ParseQuarter(calendar metrics.FiscalCalendar, quarter string, now time.Time) (string, time.Time, time.Time, error) {
+	q, err := calendar.ParseQuarter(quarter, now.In(time.Local))
+	if err != nil {
+		return "", time.Time{}, time.Time{}, err
+	}
+	return q.Label, q.Start, q.End, nil
+}
+
+// Build collects the data of the quarter [start, end) from collector.
+func Build(collector *metrics.MetricsCollector, quarter string, start, end time.Time) Input {
+	summary := collector.GetOperationsSummary(start, end)
+	current := collector.GetSummary()
+	input := Input{
+		Quarter:            quarter,
+		Start:              start,
+		End:                end,
+		OverallHealth:      current.OverallHealth,
+		ComplianceScore:    current.ComplianceScore,
+		RiskScore:          current.RiskScore,
+		VulnerabilityScore: current.VulnerabilityScore,
+		MissingScores:      missingScores(current.HasData),
+		Changes:            []string{},
+		Incidents:          IncidentsInput{Total: len(summary.Incidents), BySeverity: make(map[string]int)},
+		Alerts: AlertsInput{
+			Total:                 summary.Alerts.Total,
+			Acknowledged:          summary.Alerts.Acknowledged,
+			Resolved:              summary.Alerts.Resolved,
+			Escalated:             summary.Alerts.Escalated,
+			MeanTimeToAcknowledge: summary.Alerts.MeanTimeToAcknowledge,
+		},
+	}
+	for _, movement := range summary.Movements {
+		kpi := KPIInput{Key: string(movement.Key), Name: movement.Name, Unit: movement.Unit,
+			Start: movement.Start, End: movement.End, Delta: movement.Delta, Trend: movement.Trend}
+		if current := collector.GetKPI(movement.Key); current != nil {
+			kpi.Category, kpi.Target, kpi.Status = current.Category, current.Target, current.Status
+		}
+		input.KPIs = append(input.KPIs, kpi)
+	}
+	for _, change := range summary.Changes {
+		input.Changes = append(input.Changes, change.Name+" "+change.Description)
+	}
+	for _, incident := range summary.Incidents {
+		if !incident.ResolvedAt.IsZero() {
+			input.Incidents.Resolved++
+		}
+		input.Incidents.BySeverity[incident.Severity]++
+	}
+	return input
+}
+
+// Client drafts narratives through the configured endpoint.
+type Client struct {
+	url          string
+	token        string
+	model        string
+	instructions string
+	timeout      time.Duration
+	client       *http.Client
+}
+
+// New creates the client configured in cfg. It returns nil when no
+// endpoint is configured.
+func New(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, nil
+	}
+	c := &Client{url: cfg.URL, model: cfg.Model, instructions: cfg.Instructions, timeout: DefaultTimeout, client: cfg.Client}
+	if c.instructions == "" {
+		c.instructions = DefaultInstructions
+	}
+	if cfg.Timeout != "" {
+		timeout, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("narrative: invalid timeout %q", cfg.Timeout)
+		}
+		c.timeout = timeout
+	}
+	if cfg.TokenEnv != "" {
+		if c.token = secretenv.Get(cfg.TokenEnv); c.token == "" {
+			return nil, fmt.Errorf("narrative: environment variable %s is not set", cfg.TokenEnv)
+		}
+	}
+	if c.client == nil {
+		c.client = &http.Client{Timeout: c.timeout}
+	}
+	return c, nil
+}
+
+// Draft asks the endpoint for a narrative of input and returns it as a
+// draft generated at now.
+func (c *Client) Draft(ctx context.Context, input Input, now time.Time) (Draft, error) {
+	body, err := json.Marshal(Request{Model: c.model, Instructions: c.instructions, Data: input})
+	if err != nil {
+		return Draft{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return Draft{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Draft{}, fmt.Errorf("narrative: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Draft{}, fmt.Errorf("narrative: unexpected status %s: %s", resp.Status, string(data))
+	}
+	var answer Response
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return Draft{}, fmt.Errorf("narrative: invalid response: %w", err)
+	}
+	text := strings.TrimSpace(answer.Narrative)
+	if text == "" {
+		return Draft{}, errors.New("narrative: the endpoint returned an empty narrative")
+	}
+	return Draft{Quarter: input.Quarter, Status: StatusDraft, GeneratedAt: now, Model: c.model, Narrative: text + "\n"}, nil
+}
+
+// Load reads the drafts kept at path, ordered by quarter. A missing file
+// yields no drafts.
+func Load(path string) ([]Draft, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read narratives: %w", err)
+	}
+	var drafts []Draft
+	if err := yaml.Unmarshal(data, &drafts); err != nil {
+		return nil, fmt.Errorf("parse narratives %s: %w", path, err)
+	}
+	for _, draft := range drafts {
+		if draft.Status != StatusDraft && draft.Status != StatusApproved {
+			return nil, fmt.Errorf("narratives %s: quarter %s: invalid status %q (want draft or approved)", path, draft.Quarter, draft.Status)
+		}
+	}
+	sort.SliceStable(drafts, func(i, j int) bool { return drafts[i].Quarter < drafts[j].Quarter })
+	return drafts, nil
+}
+
+// Save writes drafts to path atomically, as YAML for analysts to edit.
+func Save(path string, drafts []Draft) error {
+	data, err := yaml.Marshal(drafts)
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(path, data, 0o600)
+}
+
+// Find returns the draft of quarter, or nil.
+func Find(drafts []Draft, quarter string) *Draft {
+	for i := range drafts {
+		if drafts[i].Quarter == quarter {
+			return &drafts[i]
+		}
+	}
+	return nil
+}
+
+// LatestApproved returns the approved draft of the latest quarter, or nil.
+func LatestApproved(drafts []Draft) *Draft {
+	var latest *Draft
+	for i := range drafts {
+		if drafts[i].Status == StatusApproved && (latest == nil || drafts[i].Quarter > latest.Quarter) {
+			latest = &drafts[i]
+		}
+	}
+	return latest
+}
@@ -0,0 +1,120 @@
+package narrative
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestParseQuarter(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	for _, tc := range []struct {
+		in, label  string
+		start, end time.Time
+	}{
+		{"", "2026-Q4", time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local), time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"2026-q3", "2026-Q3", time.Date(2026, 7, 1, 0, 0, 0, 0, time.Local), time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)},
+	} {
+		label, start, end, err := ParseQuarter(metrics.FiscalCalendar{}, tc.in, now)
+		if err != nil || label != tc.label || !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("ParseQuarter(%q) = %s %s %s %v", tc.in, label, start, end, err)
+		}
+	}
+	for _, bad := range []string{"2026-Q5", "Q3", "2026-09"} {
+		if _, _, _, err := ParseQuarter(metrics.FiscalCalendar{}, bad, now); err == nil {
+			t.Errorf("ParseQuarter(%q) succeeded", bad)
+		}
+	}
+
+	fiscal := metrics.FiscalCalendar{StartMonth: 7}
+	label, start, end, err := ParseQuarter(fiscal, "", now)
+	if err != nil || label != "FY2027-Q2" || !start.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)) || !end.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("fiscal ParseQuarter(\"\") = %s %s %s %v", label, start, end, err)
+	}
+}
+
+func TestDraft(t *testing.T) {
+	var got Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			http.Error(w, "denied", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"narrative":"  MTTR improved to 4 hours.\n\nCoverage still lags.  "}`))
+	}))
+	defer srv.Close()
+	t.Setenv("LLM_TOKEN", "tok")
+
+	if client, err := New(Config{}); client != nil || err != nil {
+		t.Fatalf("New without URL = %v, %v; want disabled", client, err)
+	}
+	client, err := New(Config{URL: srv.URL, TokenEnv: "LLM_TOKEN", Model: "m1", Client: srv.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, start, end, _ := ParseQuarter(metrics.FiscalCalendar{}, "2026-Q3", time.Now())
+	collector := metrics.NewMetricsCollector()
+	collector.AddKPISample(metrics.KPISample{Key: metrics.KPI_MTTR, Value: 8, Timestamp: start.Add(24 * time.Hour)})
+	collector.AddKPISample(metrics.KPISample{Key: metrics.KPI_MTTR, Value: 4, Timestamp: end.Add(-24 * time.Hour)})
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Name: "MTTR", Value: 4, Target: 2, Unit: "hours", LastUpdated: end.Add(-24 * time.Hour)})
+	input := Build(collector, "2026-Q3", start, end)
+
+	now := time.Date(2026, 10, 2, 9, 0, 0, 0, time.UTC)
+	draft, err := client.Draft(context.Background(), input, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Draft{Quarter: "2026-Q3", Status: StatusDraft, GeneratedAt: now, Model: "m1", Narrative: "MTTR improved to 4 hours.\n\nCoverage still lags.\n"}
+	if draft != want {
+		t.Errorf("draft = %+v, want %+v", draft, want)
+	}
+	if got.Model != "m1" || got.Instructions != DefaultInstructions || got.Data.Quarter != "2026-Q3" {
+		t.Errorf("request = %+v", got)
+	}
+	if len(got.Data.KPIs) != 1 || got.Data.KPIs[0].Start != 8 || got.Data.KPIs[0].End != 4 {
+		t.Errorf("request KPIs = %+v", got.Data.KPIs)
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.narratives.yaml")
+	if drafts, err := Load(path); drafts != nil || err != nil {
+		t.Fatalf("Load missing = %v, %v", drafts, err)
+	}
+	at := time.Date(2026, 10, 2, 9, 0, 0, 0, time.UTC)
+	drafts := []Draft{
+		{Quarter: "2026-Q3", Status: StatusDraft, GeneratedAt: at, Narrative: "Q3 draft\n"},
+		{Quarter: "2026-Q1", Status: StatusApproved, GeneratedAt: at, Narrative: "Q1\n", ApprovedBy: "alice", ApprovedAt: at},
+		{Quarter: "2026-Q2", Status: StatusApproved, GeneratedAt: at, Narrative: "Q2\n", ApprovedBy: "bob", ApprovedAt: at},
+	}
+	if err := Save(path, drafts); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 3 || loaded[0].Quarter != "2026-Q1" || loaded[2].Narrative != "Q3 draft\n" {
+		t.Errorf("loaded = %+v", loaded)
+	}
+	if latest := LatestApproved(loaded); latest == nil || latest.Quarter != "2026-Q2" || latest.ApprovedBy != "bob" {
+		t.Errorf("LatestApproved = %+v, want 2026-Q2", latest)
+	}
+	if Find(loaded, "2026-Q4") != nil {
+		t.Error("Find found a missing quarter")
+	}
+
+	loaded[0].Status = "published"
+	Save(path, loaded)
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted an invalid status")
+	}
+}
@@ -0,0 +1,30 @@
+package openmetrics
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func FuzzParse(f *testing.F) {
+	scrape, err := os.ReadFile("testdata/scrape.txt")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(scrape)
+	f.Add([]byte(`up{job="a",instance="b\\"c"} NaN -1.5`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		samples, err := Parse(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, sample := range samples {
+			if sample.Name == "" {
+				t.Fatalf("parsed a sample without a name from %q", data)
+			}
+		}
+		Import(metrics.NewMetricsCollector(), samples, ImportOptions{Type: metrics.TypeDetection})
+	})
+}
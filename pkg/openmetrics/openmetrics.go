@@ -0,0 +1,376 @@
+// Package openmetrics provides OpenMetrics text import and export.
+package openmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// ContentType is the OpenMetrics text exposition media type.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Sample represents a single parsed OpenMetrics sample.
+type Sample struct {
+	Name      string
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// Export writes the collector's KPIs and the latest value of each of its
+// metrics as OpenMetrics text.
+func Export(w io.Writer, collector *metrics.MetricsCollector) error {
+	var b strings.Builder
+
+	kpis := collector.GetKPIS()
+	b.WriteString("# TYPE secmetrics_kpi_value gauge\n")
+	b.WriteString("# HELP secmetrics_kpi_value Current KPI value.\n")
+	for _, kpi := range kpis {
+		writeSample(&b, "secmetrics_kpi_value", []string{"key", string(kpi.Key), "category", kpi.Category, "unit", kpi.Unit}, kpi.Value, kpi.LastUpdated)
+	}
+	b.WriteString("# TYPE secmetrics_kpi_target gauge\n")
+	b.WriteString("# HELP secmetrics_kpi_target KPI target value.\n")
+	for _, kpi := range kpis {
+		writeSample(&b, "secmetrics_kpi_target", []string{"key", string(kpi.Key), "category", kpi.Category, "unit", kpi.Unit}, kpi.Target, kpi.LastUpdated)
+	}
+
+	b.WriteString("# TYPE secmetrics_metric_value gauge\n")
+	b.WriteString("# HELP secmetrics_metric_value Current security metric value.\n")
+	for _, metric := range collector.GetLatestMetrics() {
+		writeSample(&b, "secmetrics_metric_value", []string{"id", metric.ID, "name", metric.Name, "type", string(metric.Type), "category", metric.Category, "unit", metric.Unit, "asset", metric.Asset, "criticality", string(metric.Criticality)}, metric.Value, metric.Timestamp)
+	}
+
+	b.WriteString("# EOF\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSample writes one sample line; labels are name/value pairs.
+func writeSample(b *strings.Builder, name string, labels []string, value float64, ts time.Time) {
+	b.WriteString(name)
+	b.WriteString("{")
+	first := true
+	for i := 0; i+1 < len(labels); i += 2 {
+		if labels[i+1] == "" {
+			continue
+		}
+		if !first {
+			b.WriteString(",")
+		}
+		first = false
+		b.WriteString(labels[i] + "=\"" + escapeLabel(labels[i+1]) + "\"")
+	}
+	b.WriteString("} ")
+	b.WriteString(formatValue(value))
+	if !ts.IsZero() {
+		b.WriteString(" " + strconv.FormatFloat(float64(ts.UnixMilli())/1000.0, 'f', -1, 64))
+	}
+	b.WriteString("\n")
+}
+
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Parse parses OpenMetrics or Prometheus text format samples. Input ending
+// in "# EOF" is OpenMetrics, with timestamps in seconds; other input is
+// read as the Prometheus text format, with timestamps in milliseconds.
+func Parse(r io.Reader) ([]Sample, error) {
+	return ParseContentType(r, "")
+}
+
+// ParseContentType parses samples like Parse, reading timestamps in
+// seconds also when contentType is the OpenMetrics media type.
+func ParseContentType(r io.Reader, contentType string) ([]Sample, error) {
+	var samples []Sample
+	var stamps []float64
+	openMetrics := isOpenMetrics(contentType)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			if line == "# EOF" {
+				openMetrics = true
+				break
+			}
+			continue
+		}
+		sample, stamp, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		samples = append(samples, sample)
+		stamps = append(stamps, stamp)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// The format is only known at the end, so timestamps are set last.
+	unit := time.Millisecond
+	if openMetrics {
+		unit = time.Second
+	}
+	for i, stamp := range stamps {
+		if !math.IsNaN(stamp) {
+			samples[i].Timestamp = timestamp(stamp, unit)
+		}
+	}
+	return samples, nil
+}
+
+// isOpenMetrics reports whether contentType is the OpenMetrics media type.
+func isOpenMetrics(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "application/openmetrics-text")
+}
+
+// timestamp returns the time value units after the Unix epoch.
+func timestamp(value float64, unit time.Duration) time.Time {
+	whole, frac := math.Modf(value)
+	perSecond := int64(time.Second / unit)
+	n := int64(whole)
+	return time.Unix(n/perSecond, n%perSecond*int64(unit)+int64(frac*float64(unit)))
+}
+
+// parseLine parses `name{labels} value [timestamp]`. The timestamp is
+// returned as written, in the unit of the format, or NaN if there is none.
+func parseLine(line string) (Sample, float64, error) {
+	sample := Sample{Labels: make(map[string]string)}
+	stamp := math.NaN()
+
+	nameEnd := strings.IndexAny(line, "{ ")
+	if nameEnd <= 0 {
+		return sample, stamp, fmt.Errorf("missing metric name or value")
+	}
+	sample.Name = line[:nameEnd]
+	rest := line[nameEnd:]
+
+	if strings.HasPrefix(rest, "{") {
+		labels, remaining, err := parseLabels(rest[1:])
+		if err != nil {
+			return sample, stamp, err
+		}
+		sample.Labels = labels
+		rest = remaining
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return sample, stamp, fmt.Errorf("expected value and optional timestamp")
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, stamp, fmt.Errorf("invalid value %q", fields[0])
+	}
+	sample.Value = value
+
+	if len(fields) == 2 {
+		parsed, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return sample, stamp, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+		stamp = parsed
+	}
+	return sample, stamp, nil
+}
+
+// parseLabels parses label pairs up to the closing brace and returns the
+// remainder of the line.
+func parseLabels(s string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	i := 0
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("unterminated label set")
+		}
+		if s[i] == '}' {
+			return labels, s[i+1:], nil
+		}
+
+		eq := strings.IndexByte(s[i:], '=')
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("invalid label")
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+		if i >= len(s) || s[i] != '"' {
+			return nil, "", fmt.Errorf("label %s: value must be quoted", name)
+		}
+		i++
+
+		var value strings.Builder
+		closed := false
+		for i < len(s) {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				switch s[i+1] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(s[i+1])
+				}
+				i += 2
+				continue
+			}
+			if c == '"' {
+				closed = true
+				i++
+				break
+			}
+			value.WriteByte(c)
+			i++
+		}
+		if !closed {
+			return nil, "", fmt.Errorf("label %s: unterminated value", name)
+		}
+		labels[name] = value.String()
+	}
+}
+
+// ImportOptions controls how samples are mapped to security metrics.
+type ImportOptions struct {
+	// Type is assigned to imported metrics without a "type" label.
+	Type metrics.MetricType
+}
+
+// Import adds parsed samples to collector. Samples previously exported as
+// secmetrics_kpi_value are recorded as KPI history; all other samples
+// become security metrics identified by their name and labels, replacing
+// any existing metric with the same ID, with the series as their record
+// reference. Exported secmetrics_metric_value samples without an id label
+// are skipped, as they cannot replace the metric they were exported from.
+// Every sample is checked first, metrics with the collector's CheckMetric
+// and KPI values for being finite numbers; if one fails, Import returns
+// its error and imports none of them. Otherwise it returns the number of
+// samples imported.
+func Import(collector *metrics.MetricsCollector, samples []Sample, opts ImportOptions) (int, error) {
+	if opts.Type == "" {
+		opts.Type = metrics.TypeDetection
+	}
+
+	var kpiSamples []metrics.KPISample
+	var imported []metrics.SecurityMetric
+	// positions holds the index in imported of each metric ID, so a series
+	// repeated in the input keeps its last sample.
+	positions := make(map[string]int)
+	count := 0
+	for _, sample := range samples {
+		switch sample.Name {
+		case "secmetrics_kpi_target":
+			continue
+		case "secmetrics_kpi_value":
+			if sample.Labels["key"] == "" {
+				continue
+			}
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				return 0, fmt.Errorf("sample %s: value %v is not a finite number", sampleID(sample), sample.Value)
+			}
+			kpiSamples = append(kpiSamples, metrics.KPISample{
+				Key:       metrics.KPIKey(sample.Labels["key"]),
+				Value:     sample.Value,
+				Timestamp: sample.Timestamp,
+			})
+			count++
+			continue
+		case "secmetrics_metric_value":
+			if sample.Labels["id"] == "" {
+				continue
+			}
+		}
+
+		metric := metrics.SecurityMetric{
+			ID:        sampleID(sample),
+			Name:      sample.Name,
+			Type:      opts.Type,
+			Value:     sample.Value,
+			Unit:      sample.Labels["unit"],
+			Category:  sample.Labels["category"],
+			Asset:     sample.Labels["asset"],
+			Timestamp: sample.Timestamp,
+			// The series is the raw record the value was read from.
+			Provenance: metrics.Provenance{Record: sampleID(sample)},
+		}
+		if sample.Name == "secmetrics_metric_value" {
+			metric.ID = sample.Labels["id"]
+			metric.Name = sample.Labels["name"]
+		}
+		if t := sample.Labels["type"]; t != "" {
+			metric.Type = metrics.MetricType(t)
+		}
+		if criticality := sample.Labels["criticality"]; criticality != "" {
+			metric.Criticality = metrics.ParseCriticality(criticality)
+		}
+		if err := collector.CheckMetric(metric); err != nil {
+			return 0, fmt.Errorf("sample %s: %w", sampleID(sample), err)
+		}
+		if i, ok := positions[metric.ID]; ok {
+			imported[i] = metric
+		} else {
+			positions[metric.ID] = len(imported)
+			imported = append(imported, metric)
+		}
+		count++
+	}
+
+	for _, sample := range kpiSamples {
+		collector.AddKPISample(sample)
+	}
+	// Replace the previous values so repeated imports do not duplicate.
+	ids := make([]string, 0, len(imported))
+	for _, metric := range imported {
+		ids = append(ids, metric.ID)
+	}
+	collector.RemoveMetrics(ids...)
+	for _, metric := range imported {
+		collector.AddMetric(metric)
+	}
+	return count, nil
+}
+
+// sampleID builds a stable ID from the sample name and sorted labels.
+func sampleID(sample Sample) string {
+	if len(sample.Labels) == 0 {
+		return sample.Name
+	}
+	names := make([]string, 0, len(sample.Labels))
+	for name := range sample.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+sample.Labels[name])
+	}
+	return sample.Name + "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,125 @@
+package openmetrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestImportRejectsInvalidSamples(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"NaN metric", "edr_coverage 97\nopen_findings NaN\n", "not a finite number"},
+		{"infinite KPI", "edr_coverage 97\nsecmetrics_kpi_value{key=\"mttr\"} +Inf\n", "not a finite number"},
+		{"negative count", "edr_coverage 97\nopen_findings{unit=\"findings\"} -3\n", "is negative"},
+		{"unknown unit", "edr_coverage 97\nopen_findings{unit=\"clicks\"} 3\n", "unknown unit"},
+	}
+	for _, tc := range tests {
+		samples, err := Parse(strings.NewReader(tc.input))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		collector := metrics.NewMetricsCollector()
+		n, err := Import(collector, samples, ImportOptions{})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: import error %v, want %q", tc.name, err, tc.want)
+		}
+		if n != 0 || len(collector.GetMetrics()) != 0 {
+			t.Errorf("%s: imported %d samples, stored %+v", tc.name, n, collector.GetMetrics())
+		}
+	}
+}
+
+func TestParseTimestampsByFormat(t *testing.T) {
+	want := time.Date(2026, 9, 29, 12, 0, 0, 123e6, time.UTC)
+	tests := []struct {
+		name, input, contentType string
+	}{
+		{"prometheus text in milliseconds", "edr_coverage 97 1790683200123\n", ""},
+		{"openmetrics in seconds", "edr_coverage 97 1790683200.123\n# EOF\n", ""},
+		{"openmetrics media type in seconds", "edr_coverage 97 1790683200.123\n", ContentType},
+	}
+	for _, tc := range tests {
+		samples, err := ParseContentType(strings.NewReader(tc.input), tc.contentType)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(samples) != 1 {
+			t.Fatalf("%s: parsed %+v", tc.name, samples)
+		}
+		if got := samples[0].Timestamp; got.Sub(want).Abs() > time.Microsecond {
+			t.Errorf("%s: timestamp %s, want %s", tc.name, got.UTC(), want)
+		}
+	}
+
+	samples, err := Parse(strings.NewReader("edr_coverage 97\n"))
+	if err != nil || len(samples) != 1 || !samples[0].Timestamp.IsZero() {
+		t.Errorf("sample without a timestamp: %+v, %v", samples, err)
+	}
+}
+
+func TestExportWritesLatestValues(t *testing.T) {
+	collector := metrics.NewMetricsCollector()
+	first := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	collector.AddMetric(metrics.SecurityMetric{ID: "edr-1", Name: "EDR coverage", Type: metrics.TypeDetection, Value: 90, Unit: "%", Timestamp: first})
+	collector.AddMetric(metrics.SecurityMetric{ID: "edr-2", Name: "EDR coverage", Type: metrics.TypeDetection, Value: 97, Unit: "%", Timestamp: first.Add(time.Hour)})
+
+	var b strings.Builder
+	if err := Export(&b, collector); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if strings.Count(out, "secmetrics_metric_value{") != 1 || !strings.Contains(out, `id="edr-2"`) {
+		t.Errorf("export does not hold only the latest value:\n%s", out)
+	}
+
+	// The export reads back with its timestamps in seconds
+	samples, err := Parse(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range samples {
+		if sample.Name == "secmetrics_metric_value" && !sample.Timestamp.Equal(first.Add(time.Hour)) {
+			t.Errorf("exported timestamp reads back as %s", sample.Timestamp.UTC())
+		}
+	}
+}
+
+func TestImportReplacesSeries(t *testing.T) {
+	collector := metrics.NewMetricsCollector()
+	input := `trivy_vulnerabilities{severity="CRITICAL"} 5
+trivy_vulnerabilities{severity="HIGH"} 12
+trivy_vulnerabilities{severity="CRITICAL"} 3
+secmetrics_metric_value{name="Unidentified"} 1
+`
+	for run := 0; run < 2; run++ {
+		samples, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := Import(collector, samples, ImportOptions{})
+		if err != nil || n != 3 {
+			t.Fatalf("run %d: imported %d samples, %v; want 3", run, n, err)
+		}
+	}
+
+	got := make(map[string]float64)
+	for _, metric := range collector.GetMetrics() {
+		got[metric.ID] = metric.Value
+	}
+	want := map[string]float64{
+		`trivy_vulnerabilities{severity=CRITICAL}`: 3,
+		`trivy_vulnerabilities{severity=HIGH}`:     12,
+	}
+	if len(got) != len(want) || len(collector.GetMetrics()) != len(want) {
+		t.Fatalf("stored %v, want %v", got, want)
+	}
+	for id, value := range want {
+		if got[id] != value {
+			t.Errorf("%s = %v, want %v", id, got[id], value)
+		}
+	}
+}
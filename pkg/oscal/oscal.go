@@ -0,0 +1,377 @@
+// Package oscal exports compliance results as an OSCAL assessment-results
+// document (OSCAL 1.1.2, JSON), for NIST OSCAL tooling.
+//
+// Every compliance metric and every KPI in the Compliance category becomes
+// an observation, with the evidence attached to the metric as relevant
+// evidence. Config maps metric IDs and KPI keys to the controls they
+// measure; each mapped control becomes a finding, satisfied when all of its
+// observations meet their targets.
+package oscal
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// Version is the OSCAL version of exported documents.
+const Version = "1.1.2"
+
+// Namespace qualifies the secmetrics-specific properties of observations.
+const Namespace = "https://github.com/hallucinaut/secmetrics/ns/oscal"
+
+// Config configures the OSCAL export.
+type Config struct {
+	// ImportAP is the href of the assessment plan the results answer,
+	// which OSCAL requires; "#" when not set.
+	ImportAP string `yaml:"import_ap"`
+	// Controls maps metric IDs and KPI keys to the IDs of the controls or
+	// control objectives they measure, e.g. mfa_coverage: [ia-2.1, ia-2.2].
+	Controls map[string][]string `yaml:"controls"`
+}
+
+// namespaceUUID is the UUIDv5 namespace of secmetrics OSCAL identifiers.
+var namespaceUUID = [16]byte{0x6b, 0x1e, 0x42, 0x0d, 0x8f, 0x3a, 0x4c, 0x59, 0x9a, 0x52, 0x1f, 0x7e, 0x33, 0x0c, 0xd4, 0x88}
+
+// uuid5 returns the name-based (SHA-1) UUID of name. Identifiers derive
+// from the export time and the object they name, so they are unique per
+// export and stable within one.
+func uuid5(name string) string {
+	h := sha1.New()
+	h.Write(namespaceUUID[:])
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Document is the root of an assessment-results file.
+type Document struct {
+	AssessmentResults AssessmentResults `json:"assessment-results"`
+}
+
+// AssessmentResults holds the results of assessing the controls of an
+// assessment plan.
+type AssessmentResults struct {
+	UUID     string   `json:"uuid"`
+	Metadata Metadata `json:"metadata"`
+	ImportAP Link     `json:"import-ap"`
+	Results  []Result `json:"results"`
+}
+
+// Metadata describes the document.
+type Metadata struct {
+	Title        string    `json:"title"`
+	LastModified time.Time `json:"last-modified"`
+	Version      string    `json:"version"`
+	OSCALVersion string    `json:"oscal-version"`
+}
+
+// Link references another document.
+type Link struct {
+	Href string `json:"href"`
+}
+
+// Result is one assessment: what was reviewed, observed and found.
+type Result struct {
+	UUID             string           `json:"uuid"`
+	Title            string           `json:"title"`
+	Description      string           `json:"description"`
+	Start            time.Time        `json:"start"`
+	End              time.Time        `json:"end"`
+	ReviewedControls ReviewedControls `json:"reviewed-controls"`
+	Observations     []Observation    `json:"observations,omitempty"`
+	Findings         []Finding        `json:"findings,omitempty"`
+}
+
+// ReviewedControls selects the controls the result covers.
+type ReviewedControls struct {
+	ControlSelections []ControlSelection `json:"control-selections"`
+}
+
+// ControlSelection includes all controls or the listed ones.
+type ControlSelection struct {
+	IncludeAll      *struct{}   `json:"include-all,omitempty"`
+	IncludeControls []ControlID `json:"include-controls,omitempty"`
+}
+
+// ControlID names a control.
+type ControlID struct {
+	ControlID string `json:"control-id"`
+}
+
+// Observation is a measured value.
+type Observation struct {
+	UUID             string             `json:"uuid"`
+	Title            string             `json:"title"`
+	Description      string             `json:"description"`
+	Props            []Prop             `json:"props"`
+	Methods          []string           `json:"methods"`
+	RelevantEvidence []RelevantEvidence `json:"relevant-evidence,omitempty"`
+	Collected        time.Time          `json:"collected"`
+}
+
+// RelevantEvidence references evidence supporting an observation: URLs and
+// paths as href, hashes as a digest property.
+type RelevantEvidence struct {
+	Href        string `json:"href,omitempty"`
+	Description string `json:"description"`
+	Props       []Prop `json:"props,omitempty"`
+}
+
+// Prop is a namespaced name/value property.
+type Prop struct {
+	Name  string `json:"name"`
+	NS    string `json:"ns"`
+	Value string `json:"value"`
+}
+
+// Finding is the conclusion about one control.
+type Finding struct {
+	UUID                string               `json:"uuid"`
+	Title               string               `json:"title"`
+	Description         string               `json:"description"`
+	Target              FindingTarget        `json:"target"`
+	RelatedObservations []RelatedObservation `json:"related-observations"`
+}
+
+// FindingTarget is the control objective a finding is about.
+type FindingTarget struct {
+	Type     string        `json:"type"`
+	TargetID string        `json:"target-id"`
+	Status   FindingStatus `json:"status"`
+}
+
+// FindingStatus is "satisfied" or "not-satisfied".
+type FindingStatus struct {
+	State string `json:"state"`
+}
+
+// RelatedObservation references an observation.
+type RelatedObservation struct {
+	ObservationUUID string `json:"observation-uuid"`
+}
+
+// measurement is a compliance value with its target.
+type measurement struct {
+	id, title, unit string
+	value, target   float64
+	met             bool
+	at              time.Time
+	evidence        []metrics.Evidence
+}
+
+// Build returns the assessment results of collector at now; version is the
+// secmetrics version recorded as the document version.
+func Build(collector *metrics.MetricsCollector, cfg Config, version string, now time.Time) (*Document, error) {
+	var measurements []measurement
+	seen := make(map[string]bool)
+	for _, metric := range collector.GetMetrics() {
+		if metric.Type != metrics.TypeCompliance && cfg.Controls[metric.ID] == nil {
+			continue
+		}
+		title := metric.Name
+		if title == "" {
+			title = metric.ID
+		}
+		seen[metric.ID] = true
+		measurements = append(measurements, measurement{id: metric.ID, title: title, unit: metric.Unit,
+			value: metric.Value, target: metric.Target, met: metric.Value >= metric.Target, at: metric.Timestamp, evidence: metric.Evidence})
+	}
+	for _, kpi := range collector.GetKPIS() {
+		key := string(kpi.Key)
+		if seen[key] || (kpi.Category != "Compliance" && cfg.Controls[key] == nil) {
+			continue
+		}
+		title := kpi.Name
+		if title == "" {
+			title = key
+		}
+		seen[key] = true
+		measurements = append(measurements, measurement{id: key, title: title, unit: kpi.Unit,
+			value: kpi.Value, target: kpi.Target, met: kpi.Status != "BELOW_TARGET", at: kpi.LastUpdated})
+	}
+	if len(measurements) == 0 {
+		return nil, fmt.Errorf("no compliance metrics, Compliance KPIs or mapped controls to export")
+	}
+	sort.Slice(measurements, func(i, j int) bool { return measurements[i].id < measurements[j].id })
+
+	stamp := now.UTC().Format(time.RFC3339Nano)
+	result := Result{
+		UUID:        uuid5(stamp + "/result"),
+		Title:       "secmetrics compliance measurements",
+		Description: "Automated measurements of compliance metrics and KPIs against their targets.",
+		End:         now.UTC(),
+	}
+	byID := make(map[string]string)
+	for _, m := range measurements {
+		at := m.at
+		if at.IsZero() || at.After(now) {
+			at = now
+		}
+		at = at.UTC()
+		if result.Start.IsZero() || at.Before(result.Start) {
+			result.Start = at
+		}
+		state := "not met"
+		if m.met {
+			state = "met"
+		}
+		observation := Observation{
+			UUID:        uuid5(stamp + "/observation/" + m.id),
+			Title:       m.title,
+			Description: fmt.Sprintf("%s measured %s against a target of %s: target %s.", m.title, format(m.value, m.unit), format(m.target, m.unit), state),
+			Props: []Prop{
+				{Name: "measurement-id", NS: Namespace, Value: m.id},
+				{Name: "value", NS: Namespace, Value: strconv.FormatFloat(m.value, 'g', -1, 64)},
+				{Name: "target", NS: Namespace, Value: strconv.FormatFloat(m.target, 'g', -1, 64)},
+			},
+			Methods:   []string{"TEST"},
+			Collected: at,
+		}
+		if m.unit != "" {
+			observation.Props = append(observation.Props, Prop{Name: "unit", NS: Namespace, Value: m.unit})
+		}
+		for _, e := range m.evidence {
+			observation.RelevantEvidence = append(observation.RelevantEvidence, relevantEvidence(e))
+		}
+		byID[m.id] = observation.UUID
+		result.Observations = append(result.Observations, observation)
+	}
+
+	// Findings for mapped controls, in control order.
+	measuredBy := make(map[string][]string)
+	for id, controls := range cfg.Controls {
+		for _, control := range controls {
+			measuredBy[control] = append(measuredBy[control], id)
+		}
+	}
+	controls := make([]string, 0, len(measuredBy))
+	for control := range measuredBy {
+		controls = append(controls, control)
+	}
+	sort.Strings(controls)
+	met := make(map[string]bool)
+	for _, m := range measurements {
+		met[m.id] = m.met
+	}
+	var selection ControlSelection
+	for _, control := range controls {
+		ids := measuredBy[control]
+		sort.Strings(ids)
+		finding := Finding{
+			UUID:   uuid5(stamp + "/finding/" + control),
+			Title:  "Control " + control,
+			Target: FindingTarget{Type: "objective-id", TargetID: control, Status: FindingStatus{State: "satisfied"}},
+		}
+		var failing, missing []string
+		for _, id := range ids {
+			observation, ok := byID[id]
+			if !ok {
+				missing = append(missing, id)
+				continue
+			}
+			finding.RelatedObservations = append(finding.RelatedObservations, RelatedObservation{ObservationUUID: observation})
+			if !met[id] {
+				failing = append(failing, id)
+			}
+		}
+		switch {
+		case len(finding.RelatedObservations) == 0:
+			// Nothing measured the control; there is nothing to conclude.
+			continue
+		case len(failing) > 0:
+			finding.Target.Status.State = "not-satisfied"
+			finding.Description = fmt.Sprintf("%s below target: %s.", plural(len(failing), "measurement is", "measurements are"), strings.Join(failing, ", "))
+		default:
+			finding.Description = fmt.Sprintf("All %s on target.", plural(len(finding.RelatedObservations), "measurement is", "measurements are"))
+		}
+		if len(missing) > 0 {
+			finding.Description += fmt.Sprintf(" Not measured: %s.", strings.Join(missing, ", "))
+		}
+		selection.IncludeControls = append(selection.IncludeControls, ControlID{ControlID: control})
+		result.Findings = append(result.Findings, finding)
+	}
+	if len(selection.IncludeControls) == 0 {
+		selection.IncludeAll = &struct{}{}
+	}
+	result.ReviewedControls.ControlSelections = []ControlSelection{selection}
+
+	importAP := cfg.ImportAP
+	if importAP == "" {
+		importAP = "#"
+	}
+	if version == "" {
+		version = "dev"
+	}
+	return &Document{AssessmentResults: AssessmentResults{
+		UUID: uuid5(stamp + "/assessment-results"),
+		Metadata: Metadata{
+			Title:        "secmetrics compliance assessment results",
+			LastModified: now.UTC(),
+			Version:      version,
+			OSCALVersion: Version,
+		},
+		ImportAP: Link{Href: importAP},
+		Results:  []Result{result},
+	}}, nil
+}
+
+// Export writes the assessment results of collector at now as JSON.
+func Export(w io.Writer, collector *metrics.MetricsCollector, cfg Config, version string, now time.Time) error {
+	doc, err := Build(collector, cfg, version, now)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// relevantEvidence returns the OSCAL reference to e.
+func relevantEvidence(e metrics.Evidence) RelevantEvidence {
+	evidence := RelevantEvidence{
+		Description: e.Note,
+		Props:       []Prop{{Name: "evidence-kind", NS: Namespace, Value: string(e.Kind)}},
+	}
+	if e.Kind == metrics.EvidenceHash {
+		evidence.Props = append(evidence.Props, Prop{Name: "digest", NS: Namespace, Value: e.Ref})
+	} else {
+		evidence.Href = e.Ref
+	}
+	if evidence.Description == "" {
+		evidence.Description = string(e.Kind) + " " + e.Ref
+	}
+	return evidence
+}
+
+// format returns value with its unit.
+func format(value float64, unit string) string {
+	s := strconv.FormatFloat(value, 'g', -1, 64)
+	switch unit {
+	case "":
+		return s
+	case "%":
+		return s + "%"
+	}
+	return s + " " + unit
+}
+
+// plural returns "1 <one>" or "n <many>".
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return strconv.Itoa(n) + " " + many
+}
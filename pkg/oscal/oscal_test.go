@@ -0,0 +1,103 @@
+package oscal
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	collector := metrics.NewMetricsCollector()
+	collector.AddMetric(metrics.SecurityMetric{ID: "cis-benchmark", Name: "CIS benchmark pass rate", Type: metrics.TypeCompliance,
+		Value: 92, Target: 90, Unit: "%", Timestamp: now.Add(-time.Hour), Evidence: []metrics.Evidence{
+			{Kind: metrics.EvidenceHash, Ref: "sha256:" + strings.Repeat("ab", 32), Note: "CIS-CAT scan"},
+			{Kind: metrics.EvidenceURL, Ref: "https://tickets.example.com/SEC-42"},
+		}})
+	collector.AddMetric(metrics.SecurityMetric{ID: "waf-blocks", Type: metrics.TypeDetection, Value: 10})
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_MFACoverage, Value: 80, Target: 95})
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 1, Target: 2})
+
+	cfg := Config{ImportAP: "plans/quarterly-ap.json", Controls: map[string][]string{
+		"cis-benchmark":  {"cm-6"},
+		"mfa_coverage":   {"ia-2.1"},
+		"password_rules": {"ia-5"},
+	}}
+	doc, err := Build(collector, cfg, "1.4.0", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar := doc.AssessmentResults
+	if ar.ImportAP.Href != "plans/quarterly-ap.json" || ar.Metadata.OSCALVersion != Version || ar.Metadata.Version != "1.4.0" {
+		t.Errorf("header = %+v", ar)
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuid.MatchString(ar.UUID) {
+		t.Errorf("uuid %q is not a version 5 UUID", ar.UUID)
+	}
+
+	result := ar.Results[0]
+	// The compliance metric and the mapped KPI; detection metrics and
+	// unmapped KPIs outside the Compliance category are not observations.
+	if len(result.Observations) != 2 || result.Observations[0].Title != "CIS benchmark pass rate" || result.Observations[1].Title != "MFA Coverage" {
+		t.Fatalf("observations = %+v", result.Observations)
+	}
+	evidence := result.Observations[0].RelevantEvidence
+	if len(evidence) != 2 || evidence[0].Href != "" || evidence[0].Description != "CIS-CAT scan" || evidence[0].Props[1].Value != "sha256:"+strings.Repeat("ab", 32) ||
+		evidence[1].Href != "https://tickets.example.com/SEC-42" || evidence[1].Description != "url https://tickets.example.com/SEC-42" {
+		t.Errorf("relevant evidence = %+v", evidence)
+	}
+	if !result.Start.Equal(now.Add(-time.Hour)) || !result.End.Equal(now) {
+		t.Errorf("result spans %s to %s", result.Start, result.End)
+	}
+	if len(result.Findings) != 2 {
+		t.Fatalf("findings = %+v", result.Findings)
+	}
+	cm6, ia2 := result.Findings[0], result.Findings[1]
+	if cm6.Target.TargetID != "cm-6" || cm6.Target.Status.State != "satisfied" ||
+		cm6.RelatedObservations[0].ObservationUUID != result.Observations[0].UUID {
+		t.Errorf("cm-6 finding = %+v", cm6)
+	}
+	if ia2.Target.TargetID != "ia-2.1" || ia2.Target.Status.State != "not-satisfied" || ia2.Description != "1 measurement is below target: mfa_coverage." {
+		t.Errorf("ia-2.1 finding = %+v", ia2)
+	}
+	selected := result.ReviewedControls.ControlSelections[0]
+	if selected.IncludeAll != nil || len(selected.IncludeControls) != 2 {
+		t.Errorf("reviewed controls = %+v", selected)
+	}
+}
+
+func TestExport(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var b bytes.Buffer
+	if err := Export(&b, metrics.NewMetricsCollector(), Config{}, "", now); err == nil {
+		t.Error("empty results exported")
+	}
+
+	collector := metrics.NewMetricsCollector()
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_Compliance, Category: "Compliance", Value: 97, Target: 95})
+	if err := Export(&b, collector, Config{}, "", now); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	ar := doc["assessment-results"]
+	if ar["import-ap"].(map[string]interface{})["href"] != "#" {
+		t.Errorf("import-ap = %v", ar["import-ap"])
+	}
+	result := ar["results"].([]interface{})[0].(map[string]interface{})
+	selection := result["reviewed-controls"].(map[string]interface{})["control-selections"].([]interface{})[0].(map[string]interface{})
+	if _, ok := selection["include-all"]; !ok {
+		t.Errorf("without mapped controls, all controls are reviewed: %v", selection)
+	}
+	if _, ok := result["findings"]; ok {
+		t.Errorf("findings without mapped controls: %v", result["findings"])
+	}
+}
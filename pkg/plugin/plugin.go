@@ -0,0 +1,167 @@
+// Package plugin runs external provider executables that speak a JSON
+// protocol over stdin and stdout, so third-party integrations can act as
+// metric sources or delivery channels without recompiling secmetrics.
+//
+// For each call secmetrics starts the executable, writes one JSON request
+// to its stdin and closes it, then reads one JSON response from its stdout.
+// A response with a non-empty "error" field, a non-zero exit status or a
+// timeout fails the call. Anything the plugin writes to stderr is included
+// in the error.
+//
+// Plugins do not inherit the environment of secmetrics, which holds the
+// encryption key and credentials of other integrations: they get PATH,
+// HOME, TMPDIR and, on Windows, SYSTEMROOT, and the entries configured in
+// Env.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ProtocolVersion is sent with every request so plugins can reject
+// protocols they do not understand.
+const ProtocolVersion = 1
+
+// DefaultTimeout bounds a plugin call when no timeout is configured.
+const DefaultTimeout = time.Minute
+
+// Plugin actions.
+const (
+	// ActionCollect asks a source plugin for metrics. The response has
+	// the shape of a POST /ingest body: "metrics", "kpis", "incidents" and
+	// "alerts".
+	ActionCollect = "collect"
+	// ActionDeliver hands a rendered report to a delivery plugin.
+	ActionDeliver = "deliver"
+)
+
+// Config configures an external plugin executable.
+type Config struct {
+	Name    string   `yaml:"name"`
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+	// Env lists KEY=value entries added to the plugin's environment, such
+	// as the credentials it needs.
+	Env []string `yaml:"env"`
+	// Timeout bounds each call, e.g. "30s" (default 1m).
+	Timeout string `yaml:"timeout"`
+}
+
+// Request is written to the plugin's stdin.
+type Request struct {
+	Protocol int    `json:"protocol"`
+	Action   string `json:"action"`
+	// Filename and Content are set for ActionDeliver; Content is
+	// base64-encoded in JSON.
+	Filename string `json:"filename,omitempty"`
+	Content  []byte `json:"content,omitempty"`
+}
+
+// inheritedEnv are the variables of the secmetrics environment passed on
+// to plugins.
+var inheritedEnv = []string{"PATH", "HOME", "TMPDIR", "SYSTEMROOT"}
+
+// Plugin is a configured plugin executable.
+type Plugin struct {
+	config  Config
+	timeout time.Duration
+}
+
+// New creates a plugin from cfg.
+func New(cfg Config) (*Plugin, error) {
+	if cfg.Name == "" || cfg.Command == "" {
+		return nil, fmt.Errorf("plugin: name and command are required")
+	}
+	for _, env := range cfg.Env {
+		if !strings.Contains(env, "=") {
+			return nil, fmt.Errorf("plugin %s: env entry %q is not KEY=value", cfg.Name, env)
+		}
+	}
+	timeout := DefaultTimeout
+	if cfg.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s timeout: %w", cfg.Name, err)
+		}
+	}
+	return &Plugin{config: cfg, timeout: timeout}, nil
+}
+
+// Name returns the configured plugin name.
+func (p *Plugin) Name() string {
+	return p.config.Name
+}
+
+// environment returns the environment of the plugin process.
+func (p *Plugin) environment() []string {
+	var env []string
+	for _, name := range inheritedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, p.config.Env...)
+}
+
+// Call sends req to the plugin and decodes its response into resp, which
+// may be nil when only success matters.
+func (p *Plugin) Call(ctx context.Context, req Request, resp interface{}) error {
+	req.Protocol = ProtocolVersion
+	input, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.config.Command, p.config.Args...)
+	cmd.Env = p.environment()
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", p.timeout)
+		}
+		return p.failure(err, stderr.String())
+	}
+
+	var status struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &status); err != nil {
+		return p.failure(fmt.Errorf("invalid response: %w", err), stderr.String())
+	}
+	if status.Error != "" {
+		return p.failure(errors.New(status.Error), stderr.String())
+	}
+	if resp != nil {
+		if err := json.Unmarshal(stdout.Bytes(), resp); err != nil {
+			return p.failure(fmt.Errorf("invalid response: %w", err), stderr.String())
+		}
+	}
+	return nil
+}
+
+// failure wraps a call error with the tail of the plugin's stderr.
+func (p *Plugin) failure(err error, stderr string) error {
+	stderr = strings.TrimSpace(stderr)
+	if len(stderr) > 512 {
+		stderr = "..." + stderr[len(stderr)-512:]
+	}
+	if stderr != "" {
+		return fmt.Errorf("plugin %s: %w (stderr: %s)", p.config.Name, err, stderr)
+	}
+	return fmt.Errorf("plugin %s: %w", p.config.Name, err)
+}
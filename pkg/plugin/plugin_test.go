@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// helperPlugin returns a plugin that runs this test binary as the plugin
+// in mode; see TestHelperProcess.
+func helperPlugin(t *testing.T, mode, timeout string, env ...string) *Plugin {
+	t.Helper()
+	p, err := New(Config{
+		Name:    "helper",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestHelperProcess"},
+		Env:     append([]string{"SECMETRICS_PLUGIN_HELPER=" + mode}, env...),
+		Timeout: timeout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestHelperProcess is the plugin run by helperPlugin, not a test.
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv("SECMETRICS_PLUGIN_HELPER")
+	if mode == "" {
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, "decode request:", err)
+		os.Exit(2)
+	}
+	switch mode {
+	case "echo":
+		// Report the request and the environment the plugin got
+		json.NewEncoder(os.Stdout).Encode(map[string]interface{}{"request": req, "env": os.Environ()})
+	case "error":
+		fmt.Fprintln(os.Stderr, "looking up the backlog")
+		fmt.Println(`{"error": "backlog unavailable"}`)
+	case "crash":
+		fmt.Fprint(os.Stderr, strings.Repeat("x", 1000)+"panic: boom")
+		os.Exit(1)
+	case "hang":
+		time.Sleep(time.Minute)
+	case "garbage":
+		fmt.Println("not json")
+	}
+	os.Exit(0)
+}
+
+func TestCallProtocol(t *testing.T) {
+	t.Setenv("SECMETRICS_ENCRYPTION_KEY", "secret")
+	p := helperPlugin(t, "echo", "", "TICKETS_TOKEN=token")
+
+	var resp struct {
+		Request Request  `json:"request"`
+		Env     []string `json:"env"`
+	}
+	req := Request{Action: ActionDeliver, Filename: "q3.md", Content: []byte("# Q3")}
+	if err := p.Call(context.Background(), req, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Request.Protocol != ProtocolVersion || resp.Request.Action != ActionDeliver || resp.Request.Filename != "q3.md" || string(resp.Request.Content) != "# Q3" {
+		t.Errorf("plugin received %+v", resp.Request)
+	}
+	env := strings.Join(resp.Env, "\n")
+	if !strings.Contains(env, "TICKETS_TOKEN=token") {
+		t.Errorf("configured env missing from %q", env)
+	}
+	if strings.Contains(env, "SECMETRICS_ENCRYPTION_KEY") {
+		t.Errorf("plugin inherited the encryption key: %q", env)
+	}
+	if path, ok := os.LookupEnv("PATH"); ok && !strings.Contains(env, "PATH="+path) {
+		t.Errorf("PATH missing from %q", env)
+	}
+
+	// A nil response only checks for success
+	if err := p.Call(context.Background(), Request{Action: ActionCollect}, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCallFailures(t *testing.T) {
+	tests := []struct {
+		mode, timeout string
+		want          []string
+	}{
+		{mode: "error", want: []string{"plugin helper: backlog unavailable", "stderr: looking up the backlog"}},
+		{mode: "garbage", want: []string{"invalid response"}},
+		{mode: "hang", timeout: "200ms", want: []string{"timed out after 200ms"}},
+	}
+	for _, tc := range tests {
+		err := helperPlugin(t, tc.mode, tc.timeout).Call(context.Background(), Request{Action: ActionCollect}, nil)
+		if err == nil {
+			t.Errorf("%s: call succeeded", tc.mode)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not contain %q", tc.mode, err, want)
+			}
+		}
+	}
+}
+
+func TestCallKeepsStderrTail(t *testing.T) {
+	err := helperPlugin(t, "crash", "").Call(context.Background(), Request{Action: ActionCollect}, nil)
+	if err == nil {
+		t.Fatal("crashed plugin succeeded")
+	}
+	_, stderr, ok := strings.Cut(err.Error(), "(stderr: ...")
+	if !ok {
+		t.Fatalf("error %q has no truncated stderr", err)
+	}
+	// The tail holds the end of the output, where the cause usually is
+	if stderr = strings.TrimSuffix(stderr, ")"); len(stderr) != 512 || !strings.HasSuffix(stderr, "panic: boom") {
+		t.Errorf("stderr tail of %d bytes: %q", len(stderr), stderr)
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	for _, cfg := range []Config{
+		{Command: "plugin"},
+		{Name: "helper"},
+		{Name: "helper", Command: "plugin", Env: []string{"TOKEN"}},
+		{Name: "helper", Command: "plugin", Timeout: "soon"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
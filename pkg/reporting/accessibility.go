@@ -0,0 +1,62 @@
+package reporting
+
+import (
+	"html"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// htmlLang returns the lang attribute value for a report locale.
+func htmlLang(locale string) string {
+	tag, err := language.Parse(locale)
+	if locale == "" || err != nil {
+		return "en"
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
+
+// htmlDocument returns the opening of an HTML document up to and including
+// the title, with the document language set for screen readers.
+func htmlDocument(locale, title string) string {
+	return "<!DOCTYPE html>\n<html lang=\"" + htmlLang(locale) + "\">\n<head>\n<meta charset=\"utf-8\">\n<title>" + html.EscapeString(title) + "</title>\n"
+}
+
+// htmlTable returns the opening of a data table with a caption and column
+// headers. Close it with htmlTableEnd.
+func htmlTable(caption string, headers ...string) string {
+	table := "<table>\n<caption>" + html.EscapeString(caption) + "</caption>\n<thead>\n<tr>"
+	for _, header := range headers {
+		table += "<th scope=\"col\">" + html.EscapeString(header) + "</th>"
+	}
+	return table + "</tr>\n</thead>\n<tbody>\n"
+}
+
+// htmlTableEnd closes a table opened with htmlTable.
+const htmlTableEnd = "</tbody>\n</table>\n"
+
+// htmlRowHeader returns a row header cell.
+func htmlRowHeader(s string) string {
+	return "<th scope=\"row\">" + html.EscapeString(s) + "</th>"
+}
+
+// htmlTrend returns the trend arrow labelled for screen readers, followed
+// by the trend in words so it is not conveyed by the icon alone.
+func htmlTrend(trend string) string {
+	return htmlTrendIcon(trend) + " " + html.EscapeString(trend)
+}
+
+// htmlTrendIcon returns the trend arrow labelled for screen readers.
+func htmlTrendIcon(trend string) string {
+	return "<span role=\"img\" aria-label=\"" + html.EscapeString(strings.ToLower(trendName(trend))) + "\">" + trendArrow(trend) + "</span>"
+}
+
+// trendName returns the trend in words, defaulting to STABLE.
+func trendName(trend string) string {
+	switch trend {
+	case "IMPROVING", "DECLINING":
+		return trend
+	}
+	return "STABLE"
+}
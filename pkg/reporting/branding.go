@@ -0,0 +1,187 @@
+package reporting
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"image"
+	_ "image/jpeg" // logo formats
+	_ "image/png"
+	"os"
+	"strconv"
+)
+
+// Report themes.
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// DefaultPrimaryColor is used for branded reports without a primary color.
+const DefaultPrimaryColor = "#1f4e79"
+
+// Branding configures how HTML and PDF reports are branded.
+type Branding struct {
+	// Logo is a PNG or JPEG file shown in the report header.
+	Logo string `yaml:"logo"`
+	// PrimaryColor is a #RRGGBB color for headings and banners.
+	PrimaryColor string `yaml:"primary_color"`
+	Footer       string `yaml:"footer"`
+	// Banner is a classification banner such as "CONFIDENTIAL", shown at
+	// the top and bottom of every report.
+	Banner string `yaml:"banner"`
+	// Theme is light (the default) or dark.
+	Theme string `yaml:"theme"`
+}
+
+// IsZero reports whether no branding is configured.
+func (b Branding) IsZero() bool {
+	return b == Branding{}
+}
+
+// Validate checks the theme and primary color.
+func (b Branding) Validate() error {
+	switch b.Theme {
+	case "", ThemeLight, ThemeDark:
+	default:
+		return fmt.Errorf("branding theme %q: must be %s or %s", b.Theme, ThemeLight, ThemeDark)
+	}
+	if b.PrimaryColor != "" {
+		if _, _, _, err := parseHexColor(b.PrimaryColor); err != nil {
+			return fmt.Errorf("branding primary color: %w", err)
+		}
+	}
+	return nil
+}
+
+// Load resolves the branding for rendering, reading and decoding the logo.
+// It returns nil when no branding is configured.
+func (b Branding) Load() (*Brand, error) {
+	if b.IsZero() {
+		return nil, nil
+	}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	brand := &Brand{
+		PrimaryColor: b.PrimaryColor,
+		Footer:       b.Footer,
+		Banner:       b.Banner,
+		Theme:        b.Theme,
+	}
+	if brand.PrimaryColor == "" {
+		brand.PrimaryColor = DefaultPrimaryColor
+	}
+	if brand.Theme == "" {
+		brand.Theme = ThemeLight
+	}
+	if b.Logo != "" {
+		data, err := os.ReadFile(b.Logo)
+		if err != nil {
+			return nil, fmt.Errorf("read logo: %w", err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decode logo %s: %w", b.Logo, err)
+		}
+		brand.Logo = data
+		brand.LogoType = "image/" + format
+		brand.logoImage = img
+	}
+	return brand, nil
+}
+
+// Brand is branding resolved for rendering. A nil Brand renders reports
+// unbranded.
+type Brand struct {
+	Logo         []byte
+	LogoType     string
+	PrimaryColor string
+	Footer       string
+	Banner       string
+	Theme        string
+	logoImage    image.Image
+}
+
+// themeColors returns the background and text colors for the theme.
+func (b *Brand) themeColors() (background, text string) {
+	if b.Theme == ThemeDark {
+		return "#1e1e1e", "#e6e6e6"
+	}
+	return "#ffffff", "#1a1a1a"
+}
+
+// pdfColors returns the background, text and heading colors for PDF
+// output, defaulting to black on white for a nil Brand.
+func (b *Brand) pdfColors() (background, text, heading [3]float64) {
+	if b == nil {
+		return [3]float64{1, 1, 1}, [3]float64{0, 0, 0}, [3]float64{0, 0, 0}
+	}
+	bg, fg := b.themeColors()
+	return hexRGB(bg), hexRGB(fg), hexRGB(b.PrimaryColor)
+}
+
+// htmlStyle returns a style element applying the theme and primary color.
+func (b *Brand) htmlStyle() string {
+	if b == nil {
+		return ""
+	}
+	background, text := b.themeColors()
+	style := "<style>\n"
+	style += "body { background: " + background + "; color: " + text + "; }\n"
+	style += "h1, h2, h3, th { color: " + b.PrimaryColor + "; }\n"
+	style += ".banner { background: " + b.PrimaryColor + "; color: #ffffff; text-align: center; font-weight: bold; letter-spacing: 0.1em; padding: 4px; }\n"
+	style += ".logo { max-height: 60px; }\n"
+	style += "footer { border-top: 2px solid " + b.PrimaryColor + "; margin-top: 2em; padding-top: 0.5em; font-size: 0.9em; }\n"
+	style += "</style>\n"
+	return style
+}
+
+// htmlHeader returns the top banner and logo.
+func (b *Brand) htmlHeader() string {
+	if b == nil {
+		return ""
+	}
+	var header string
+	if b.Banner != "" {
+		header += "<div class=\"banner\" role=\"note\">" + html.EscapeString(b.Banner) + "</div>\n"
+	}
+	if len(b.Logo) > 0 {
+		header += "<img class=\"logo\" src=\"data:" + b.LogoType + ";base64," + base64.StdEncoding.EncodeToString(b.Logo) + "\" alt=\"Logo\">\n"
+	}
+	return header
+}
+
+// htmlFooter returns the footer text and bottom banner.
+func (b *Brand) htmlFooter() string {
+	if b == nil {
+		return ""
+	}
+	var footer string
+	if b.Footer != "" {
+		footer += "<footer>" + html.EscapeString(b.Footer) + "</footer>\n"
+	}
+	if b.Banner != "" {
+		footer += "<div class=\"banner\" role=\"note\">" + html.EscapeString(b.Banner) + "</div>\n"
+	}
+	return footer
+}
+
+// parseHexColor parses a #RRGGBB color into components between 0 and 1.
+func parseHexColor(s string) (r, g, b float64, err error) {
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0, fmt.Errorf("invalid color %q (want #RRGGBB)", s)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid color %q (want #RRGGBB)", s)
+	}
+	return float64(v>>16&0xff) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255, nil
+}
+
+// hexRGB returns the components of a validated #RRGGBB color.
+func hexRGB(s string) [3]float64 {
+	r, g, b, _ := parseHexColor(s)
+	return [3]float64{r, g, b}
+}
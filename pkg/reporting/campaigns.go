@@ -0,0 +1,142 @@
+package reporting
+
+import (
+	"html"
+	"time"
+)
+
+// CampaignData represents the status of a remediation campaign.
+type CampaignData struct {
+	Name     string
+	Owner    string
+	Start    time.Time
+	Deadline time.Time
+	State    string
+	Total    int
+	Open     int
+	Resolved int
+	// Progress is the percentage of findings resolved, Expected the
+	// percentage the ideal burn-down has resolved by now and Adherence the
+	// percentage resolved by the deadline.
+	Progress  float64
+	Expected  float64
+	Adherence float64
+	Owners    []CampaignOwnerData
+	// BurnDown is the number of open findings at the end of each day.
+	BurnDown []int
+}
+
+// CampaignOwnerData represents one owner's findings in a campaign.
+type CampaignOwnerData struct {
+	Owner    string
+	Open     int
+	Resolved int
+	Overdue  int
+}
+
+// date formats t as a date.
+func (f formatter) date(t time.Time) string {
+	return t.Format(f.dates.date)
+}
+
+// campaignPeriod formats the dates of a campaign, e.g. "2026-10-01 to
+// 2026-10-31".
+func campaignPeriod(f formatter, campaign CampaignData) string {
+	return f.date(campaign.Start) + " to " + f.date(campaign.Deadline)
+}
+
+// formatBurnDown formats a burn-down as "12 → 9 → 4", keeping at most
+// eight points so long campaigns stay on one line. HTML separates points
+// with commas, as arrows there are labelled trend icons.
+func formatBurnDown(f formatter, burnDown []int, separator string) string {
+	if len(burnDown) == 0 {
+		return "not started"
+	}
+	step := (len(burnDown) + 7) / 8
+	var s string
+	for i := 0; i < len(burnDown); i += step {
+		if i+step >= len(burnDown) {
+			i = len(burnDown) - 1
+		}
+		if s != "" {
+			s += separator
+		}
+		s += f.integer(burnDown[i])
+	}
+	return s
+}
+
+// formatCampaigns formats the campaign status section of text reports.
+func formatCampaigns(f formatter, campaigns []CampaignData) string {
+	var reportStr string
+
+	reportStr += "Remediation Campaigns\n"
+	reportStr += "=====================\n\n"
+	for _, campaign := range campaigns {
+		reportStr += "  " + campaign.Name + " [" + campaign.State + "] " + campaignPeriod(f, campaign) + ", owner " + orNone(campaign.Owner) + "\n"
+		reportStr += "      Resolved: " + f.integer(campaign.Resolved) + "/" + f.integer(campaign.Total) + " (" + f.percent(campaign.Progress, 1) + ", expected " + f.percent(campaign.Expected, 1) + ")\n"
+		reportStr += "      Deadline Adherence: " + f.percent(campaign.Adherence, 1) + "\n"
+		reportStr += "      Burn-down: " + formatBurnDown(f, campaign.BurnDown, " → ") + "\n"
+		for _, owner := range campaign.Owners {
+			reportStr += "      " + orNone(owner.Owner) + ": " + f.integer(owner.Open) + " open, " + f.integer(owner.Resolved) + " resolved, " + f.integer(owner.Overdue) + " overdue\n"
+		}
+		reportStr += "\n"
+	}
+
+	return reportStr
+}
+
+// formatCampaignsMarkdown formats the campaign status section of Markdown
+// reports.
+func formatCampaignsMarkdown(f formatter, campaigns []CampaignData) string {
+	var reportStr string
+
+	reportStr += "## Remediation Campaigns\n\n"
+	reportStr += "| Campaign | State | Period | Resolved | Expected | Deadline Adherence | Burn-down |\n"
+	reportStr += "|----------|-------|--------|----------|----------|--------------------|-----------|\n"
+	for _, campaign := range campaigns {
+		reportStr += "| " + campaign.Name + " | " + campaign.State + " | " + campaignPeriod(f, campaign) + " | " + f.integer(campaign.Resolved) + "/" + f.integer(campaign.Total) + " (" + f.percent(campaign.Progress, 1) + ") | " + f.percent(campaign.Expected, 1) + " | " + f.percent(campaign.Adherence, 1) + " | " + formatBurnDown(f, campaign.BurnDown, " → ") + " |\n"
+	}
+	reportStr += "\n"
+
+	for _, campaign := range campaigns {
+		if len(campaign.Owners) == 0 {
+			continue
+		}
+		reportStr += "### " + campaign.Name + " by Owner\n\n"
+		reportStr += "| Owner | Open | Resolved | Overdue |\n"
+		reportStr += "|-------|------|----------|---------|\n"
+		for _, owner := range campaign.Owners {
+			reportStr += "| " + orNone(owner.Owner) + " | " + f.integer(owner.Open) + " | " + f.integer(owner.Resolved) + " | " + f.integer(owner.Overdue) + " |\n"
+		}
+		reportStr += "\n"
+	}
+
+	return reportStr
+}
+
+// formatCampaignsHTML formats the campaign status section of HTML reports.
+func formatCampaignsHTML(f formatter, campaigns []CampaignData) string {
+	var reportStr string
+
+	reportStr += "<h2>Remediation Campaigns</h2>\n"
+	reportStr += htmlTable("Remediation campaigns", "Campaign", "State", "Period", "Resolved", "Expected", "Deadline Adherence", "Burn-down")
+	for _, campaign := range campaigns {
+		reportStr += "<tr>" + htmlRowHeader(campaign.Name) + "<td>" + html.EscapeString(campaign.State) + "</td><td>" + campaignPeriod(f, campaign) + "</td><td>" + f.integer(campaign.Resolved) + "/" + f.integer(campaign.Total) + " (" + f.percent(campaign.Progress, 1) + ")</td><td>" + f.percent(campaign.Expected, 1) + "</td><td>" + f.percent(campaign.Adherence, 1) + "</td><td>" + formatBurnDown(f, campaign.BurnDown, ", ") + "</td></tr>\n"
+	}
+	reportStr += htmlTableEnd
+
+	for _, campaign := range campaigns {
+		if len(campaign.Owners) == 0 {
+			continue
+		}
+		reportStr += "<h3>" + html.EscapeString(campaign.Name) + " by Owner</h3>\n"
+		reportStr += htmlTable(campaign.Name+" findings by owner", "Owner", "Open", "Resolved", "Overdue")
+		for _, owner := range campaign.Owners {
+			reportStr += "<tr>" + htmlRowHeader(orNone(owner.Owner)) + "<td>" + f.integer(owner.Open) + "</td><td>" + f.integer(owner.Resolved) + "</td><td>" + f.integer(owner.Overdue) + "</td></tr>\n"
+		}
+		reportStr += htmlTableEnd
+	}
+
+	return reportStr
+}
@@ -0,0 +1,81 @@
+package reporting
+
+import (
+	"fmt"
+	"html"
+)
+
+// CategoryData represents the summary of one KPI category for reporting.
+type CategoryData struct {
+	Name          string
+	KPIs          int
+	OnTarget      int
+	Score         float64
+	Health        string
+	Subcategories []CategoryData
+	// NoData marks a category without a KPI to score, whose score is
+	// shown as n/a.
+	NoData bool
+}
+
+// formatCategories formats the category breakdown section of text reports.
+func formatCategories(f formatter, categories []CategoryData) string {
+	var reportStr string
+
+	width := len("Category")
+	for _, category := range categories {
+		width = max(width, len(category.Name))
+		for _, sub := range category.Subcategories {
+			width = max(width, len(sub.Name)+2)
+		}
+	}
+
+	reportStr += "Category Breakdown\n"
+	reportStr += "==================\n\n"
+	reportStr += fmt.Sprintf("  %-*s %-9s %6s  %s\n", width, "Category", "Health", "Score", "On Target")
+	for _, category := range categories {
+		reportStr += fmt.Sprintf("  %-*s %-9s %6s  %d/%d\n", width, category.Name, category.Health, scoreOrNoData(f.percent(category.Score, 1), category.NoData), category.OnTarget, category.KPIs)
+		for _, sub := range category.Subcategories {
+			reportStr += fmt.Sprintf("  %-*s %-9s %6s  %d/%d\n", width, "> "+sub.Name, sub.Health, scoreOrNoData(f.percent(sub.Score, 1), sub.NoData), sub.OnTarget, sub.KPIs)
+		}
+	}
+	reportStr += "\n"
+
+	return reportStr
+}
+
+// formatCategoriesMarkdown formats the category breakdown section of
+// Markdown reports.
+func formatCategoriesMarkdown(f formatter, categories []CategoryData) string {
+	var reportStr string
+
+	reportStr += "## Category Breakdown\n\n"
+	reportStr += "| Category | Health | Score | On Target |\n"
+	reportStr += "|----------|--------|-------|-----------|\n"
+	for _, category := range categories {
+		reportStr += "| " + category.Name + " | " + category.Health + " | " + scoreOrNoData(f.percent(category.Score, 1), category.NoData) + " | " + fmt.Sprintf("%d/%d", category.OnTarget, category.KPIs) + " |\n"
+		for _, sub := range category.Subcategories {
+			reportStr += "| " + category.Name + " > " + sub.Name + " | " + sub.Health + " | " + scoreOrNoData(f.percent(sub.Score, 1), sub.NoData) + " | " + fmt.Sprintf("%d/%d", sub.OnTarget, sub.KPIs) + " |\n"
+		}
+	}
+	reportStr += "\n"
+
+	return reportStr
+}
+
+// formatCategoriesHTML formats the category breakdown section of HTML reports.
+func formatCategoriesHTML(f formatter, categories []CategoryData) string {
+	var reportStr string
+
+	reportStr += "<h2>Category Breakdown</h2>\n"
+	reportStr += htmlTable("Category breakdown", "Category", "Health", "Score", "On Target")
+	for _, category := range categories {
+		reportStr += "<tr>" + htmlRowHeader(category.Name) + "<td>" + html.EscapeString(category.Health) + "</td><td>" + scoreOrNoData(f.percent(category.Score, 1), category.NoData) + "</td><td>" + fmt.Sprintf("%d/%d", category.OnTarget, category.KPIs) + "</td></tr>\n"
+		for _, sub := range category.Subcategories {
+			reportStr += "<tr><th scope=\"row\" style=\"padding-left: 1.5em;\">" + html.EscapeString(sub.Name) + "</th><td>" + html.EscapeString(sub.Health) + "</td><td>" + scoreOrNoData(f.percent(sub.Score, 1), sub.NoData) + "</td><td>" + fmt.Sprintf("%d/%d", sub.OnTarget, sub.KPIs) + "</td></tr>\n"
+		}
+	}
+	reportStr += htmlTableEnd
+
+	return reportStr
+}
@@ -0,0 +1,139 @@
+package reporting
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// PNG chart dimensions.
+const (
+	chartPNGWidth  = 6 * vg.Inch
+	chartPNGHeight = 2 * vg.Inch
+)
+
+// RenderKPIChartPNG renders a KPI as a PNG chart for reports that cannot
+// embed SVG, such as Markdown posted to chat tools. It draws the same
+// series, target line and bands as RenderKPIChartSVG.
+func RenderKPIChartPNG(kpi KPIData) ([]byte, error) {
+	values := kpi.History
+	if len(values) == 0 {
+		values = []float64{kpi.Value}
+	}
+
+	maxValue := kpi.Target
+	for _, v := range values {
+		maxValue = math.Max(maxValue, v)
+	}
+	if kpi.Bands != nil {
+		maxValue = math.Max(maxValue, math.Max(kpi.Bands.Warning, kpi.Bands.Critical))
+	}
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+	maxValue *= 1.1
+
+	// Samples are spread over x in [0, 1]; a single value is drawn as a
+	// centered bar.
+	xMax := 1.0
+	if len(kpi.History) > 1 {
+		xMax = float64(len(values) - 1)
+	}
+
+	p := plot.New()
+	p.Title.Text = kpi.Name
+	p.Y.Label.Text = kpi.Unit
+	p.Y.Min, p.Y.Max = 0, maxValue
+	p.X.Min, p.X.Max = 0, xMax
+	p.HideX()
+	p.Legend.Top = true
+
+	if kpi.Bands != nil {
+		if kpi.Direction == "lower_is_better" {
+			if err := addBand(p, xMax, kpi.Bands.Critical, maxValue, colorCriticalBand); err != nil {
+				return nil, err
+			}
+			if err := addBand(p, xMax, kpi.Bands.Warning, kpi.Bands.Critical, colorWarningBand); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := addBand(p, xMax, 0, kpi.Bands.Critical, colorCriticalBand); err != nil {
+				return nil, err
+			}
+			if err := addBand(p, xMax, kpi.Bands.Critical, kpi.Bands.Warning, colorWarningBand); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(kpi.History) > 1 {
+		points := make(plotter.XYs, len(values))
+		for i, v := range values {
+			points[i] = plotter.XY{X: float64(i), Y: v}
+		}
+		series, err := plotter.NewLine(points)
+		if err != nil {
+			return nil, fmt.Errorf("chart %s: %w", kpi.Name, err)
+		}
+		series.Color = chartColor(colorSeries)
+		series.Width = vg.Points(2)
+		p.Add(series)
+	} else {
+		bar, err := plotter.NewPolygon(plotter.XYs{{X: 1.0 / 3, Y: 0}, {X: 2.0 / 3, Y: 0}, {X: 2.0 / 3, Y: values[0]}, {X: 1.0 / 3, Y: values[0]}})
+		if err != nil {
+			return nil, fmt.Errorf("chart %s: %w", kpi.Name, err)
+		}
+		bar.Color = chartColor(colorSeries)
+		bar.LineStyle.Width = 0
+		p.Add(bar)
+	}
+
+	target, err := plotter.NewLine(plotter.XYs{{X: 0, Y: kpi.Target}, {X: xMax, Y: kpi.Target}})
+	if err != nil {
+		return nil, fmt.Errorf("chart %s: %w", kpi.Name, err)
+	}
+	target.Color = chartColor(colorTargetLine)
+	target.Width = vg.Points(2)
+	target.Dashes = []vg.Length{vg.Points(6), vg.Points(4)}
+	p.Add(target)
+	p.Legend.Add(fmt.Sprintf("target %g %s", kpi.Target, kpi.Unit), target)
+
+	w, err := p.WriterTo(chartPNGWidth, chartPNGHeight, "png")
+	if err != nil {
+		return nil, fmt.Errorf("chart %s: %w", kpi.Name, err)
+	}
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("chart %s: %w", kpi.Name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ChartFilename returns the file name for a KPI's PNG chart written
+// alongside a report named base (without extension).
+func ChartFilename(base string, kpi KPIData) string {
+	return base + "-" + chartID(kpi) + ".png"
+}
+
+// addBand adds a filled horizontal band between two values.
+func addBand(p *plot.Plot, xMax, from, to float64, hex string) error {
+	band, err := plotter.NewPolygon(plotter.XYs{{X: 0, Y: from}, {X: xMax, Y: from}, {X: xMax, Y: to}, {X: 0, Y: to}})
+	if err != nil {
+		return fmt.Errorf("chart band: %w", err)
+	}
+	band.Color = chartColor(hex)
+	band.LineStyle.Width = 0
+	p.Add(band)
+	return nil
+}
+
+// chartColor converts a #RRGGBB chart color.
+func chartColor(hex string) color.Color {
+	rgb := hexRGB(hex)
+	return color.RGBA{R: uint8(math.Round(rgb[0] * 255)), G: uint8(math.Round(rgb[1] * 255)), B: uint8(math.Round(rgb[2] * 255)), A: 255}
+}
@@ -0,0 +1,151 @@
+package reporting
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// TargetBands defines the warning and critical zones of a KPI chart.
+// For higher-is-better KPIs values below the thresholds fall into the
+// zones; for lower-is-better KPIs values above them do.
+type TargetBands struct {
+	Warning  float64
+	Critical float64
+}
+
+// Chart dimensions in pixels.
+const (
+	chartWidth   = 480
+	chartHeight  = 160
+	chartPadding = 24
+)
+
+// Band fill colors.
+const (
+	colorWarningBand  = "#fff4ce"
+	colorCriticalBand = "#fde7e9"
+	colorTargetLine   = "#107c10"
+	colorSeries       = "#0063b1"
+)
+
+// RenderKPIChartSVG renders a KPI as an inline SVG chart with its target
+// line and, when configured, warning and critical bands. The KPI history
+// is drawn as a line; without history the current value is drawn as a bar.
+func RenderKPIChartSVG(kpi KPIData) string {
+	values := kpi.History
+	if len(values) == 0 {
+		values = []float64{kpi.Value}
+	}
+
+	maxValue := kpi.Target
+	for _, v := range values {
+		if v > maxValue {
+			maxValue = v
+		}
+	}
+	if kpi.Bands != nil {
+		if kpi.Bands.Warning > maxValue {
+			maxValue = kpi.Bands.Warning
+		}
+		if kpi.Bands.Critical > maxValue {
+			maxValue = kpi.Bands.Critical
+		}
+	}
+	if maxValue <= 0 {
+		maxValue = 1
+	}
+	maxValue *= 1.1
+
+	plotHeight := float64(chartHeight - 2*chartPadding)
+	plotWidth := float64(chartWidth - 2*chartPadding)
+	y := func(v float64) float64 {
+		return chartPadding + plotHeight - v/maxValue*plotHeight
+	}
+
+	id := chartID(kpi)
+	svg := fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" role=\"img\" aria-labelledby=\"%s-title %s-desc\">\n", chartWidth, chartHeight, chartWidth, chartHeight, id, id)
+	svg += "<title id=\"" + id + "-title\">" + html.EscapeString(kpi.Name) + "</title>\n"
+	svg += "<desc id=\"" + id + "-desc\">" + html.EscapeString(chartDescription(kpi)) + "</desc>\n"
+
+	if kpi.Bands != nil {
+		if kpi.Direction == "lower_is_better" {
+			svg += bandRect(y(maxValue), y(kpi.Bands.Critical), colorCriticalBand, "critical")
+			svg += bandRect(y(kpi.Bands.Critical), y(kpi.Bands.Warning), colorWarningBand, "warning")
+		} else {
+			svg += bandRect(y(kpi.Bands.Critical), y(0), colorCriticalBand, "critical")
+			svg += bandRect(y(kpi.Bands.Warning), y(kpi.Bands.Critical), colorWarningBand, "warning")
+		}
+	}
+
+	if len(kpi.History) > 1 {
+		step := plotWidth / float64(len(values)-1)
+		points := ""
+		for i, v := range values {
+			if i > 0 {
+				points += " "
+			}
+			points += fmt.Sprintf("%.1f,%.1f", chartPadding+float64(i)*step, y(v))
+		}
+		svg += "<polyline class=\"series\" fill=\"none\" stroke=\"" + colorSeries + "\" stroke-width=\"2\" points=\"" + points + "\"/>\n"
+	} else {
+		barWidth := plotWidth / 3
+		svg += fmt.Sprintf("<rect class=\"series\" x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"%s\"/>\n",
+			chartPadding+plotWidth/2-barWidth/2, y(values[0]), barWidth, y(0)-y(values[0]), colorSeries)
+	}
+
+	svg += fmt.Sprintf("<line class=\"target\" x1=\"%d\" y1=\"%.1f\" x2=\"%d\" y2=\"%.1f\" stroke=\"%s\" stroke-width=\"2\" stroke-dasharray=\"6 4\"/>\n",
+		chartPadding, y(kpi.Target), chartWidth-chartPadding, y(kpi.Target), colorTargetLine)
+	svg += fmt.Sprintf("<text x=\"%d\" y=\"%.1f\" font-size=\"11\" fill=\"%s\">target %.1f %s</text>\n",
+		chartWidth-chartPadding-90, y(kpi.Target)-4, colorTargetLine, kpi.Target, html.EscapeString(kpi.Unit))
+	svg += "</svg>\n"
+
+	return svg
+}
+
+// chartID returns an element id prefix for a KPI's chart, derived from
+// its key or, failing that, its name.
+func chartID(kpi KPIData) string {
+	source := kpi.Key
+	if source == "" {
+		source = kpi.Name
+	}
+	id := "chart-"
+	for _, r := range strings.ToLower(source) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			id += string(r)
+		} else if !strings.HasSuffix(id, "-") {
+			id += "-"
+		}
+	}
+	return strings.TrimSuffix(id, "-")
+}
+
+// chartDescription describes a chart in words so it is not conveyed by
+// color alone: the value, target, status and band thresholds.
+func chartDescription(kpi KPIData) string {
+	desc := fmt.Sprintf("Value %g %s, target %g %s", kpi.Value, kpi.Unit, kpi.Target, kpi.Unit)
+	if kpi.Status != "" {
+		desc += ", status " + kpi.Status
+	}
+	if kpi.Bands != nil {
+		side := "below"
+		if kpi.Direction == "lower_is_better" {
+			side = "above"
+		}
+		desc += fmt.Sprintf(". Warning %s %g, critical %s %g", side, kpi.Bands.Warning, side, kpi.Bands.Critical)
+	}
+	if len(kpi.History) > 1 {
+		desc += fmt.Sprintf(". Trend over %d samples: %s", len(kpi.History), trendName(kpi.Trend))
+	}
+	return desc + "."
+}
+
+// bandRect renders a horizontal band between two y coordinates.
+func bandRect(top, bottom float64, color, class string) string {
+	if bottom < top {
+		top, bottom = bottom, top
+	}
+	return fmt.Sprintf("<rect class=\"band-%s\" x=\"%d\" y=\"%.1f\" width=\"%d\" height=\"%.1f\" fill=\"%s\"/>\n",
+		class, chartPadding, top, chartWidth-2*chartPadding, bottom-top, color)
+}
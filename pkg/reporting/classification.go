@@ -0,0 +1,85 @@
+package reporting
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Classification is a report's information classification label.
+type Classification string
+
+// Classification levels, from least to most sensitive.
+const (
+	ClassificationPublic       Classification = "Public"
+	ClassificationInternal     Classification = "Internal"
+	ClassificationConfidential Classification = "Confidential"
+	ClassificationRestricted   Classification = "Restricted"
+)
+
+// Classifications lists the classification levels from least to most
+// sensitive.
+var Classifications = []Classification{
+	ClassificationPublic,
+	ClassificationInternal,
+	ClassificationConfidential,
+	ClassificationRestricted,
+}
+
+// ParseClassification parses a classification level case-insensitively.
+// An empty string means the report is unlabelled.
+func ParseClassification(s string) (Classification, error) {
+	if s == "" {
+		return "", nil
+	}
+	for _, c := range Classifications {
+		if strings.EqualFold(s, string(c)) {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("unknown classification %q (want Public, Internal, Confidential or Restricted)", s)
+}
+
+// Label returns the classification as shown on reports, e.g. "CONFIDENTIAL".
+func (c Classification) Label() string {
+	return strings.ToUpper(string(c))
+}
+
+// color returns the #RRGGBB marking color for the classification.
+func (c Classification) color() string {
+	switch c {
+	case ClassificationPublic:
+		return "#2e7d32"
+	case ClassificationInternal:
+		return "#1565c0"
+	case ClassificationConfidential:
+		return "#bf360c"
+	default:
+		return "#b00020"
+	}
+}
+
+// classificationText returns the classification marking for text reports.
+func classificationText(c Classification) string {
+	if c == "" {
+		return ""
+	}
+	return "*** " + c.Label() + " ***\n\n"
+}
+
+// classificationMarkdown returns the classification marking for Markdown
+// reports.
+func classificationMarkdown(c Classification) string {
+	if c == "" {
+		return ""
+	}
+	return "**CLASSIFICATION: " + c.Label() + "**\n\n"
+}
+
+// classificationHTML returns the classification marking for HTML reports.
+func classificationHTML(c Classification) string {
+	if c == "" {
+		return ""
+	}
+	return "<div class=\"classification\" role=\"note\" style=\"background: " + c.color() + "; color: #ffffff; text-align: center; font-weight: bold; padding: 4px;\">" + html.EscapeString(c.Label()) + "</div>\n"
+}
@@ -0,0 +1,52 @@
+package reporting
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// Config configures report rendering.
+type Config struct {
+	// Locale is a BCP 47 language tag such as "de-DE" used to format
+	// numbers, percentages and dates. Empty keeps the default formatting.
+	Locale string `yaml:"locale"`
+	// TimeZone is an IANA time zone such as "Europe/Berlin" report
+	// timestamps are shown in. Empty shows them in the server's local
+	// zone.
+	TimeZone string   `yaml:"time_zone"`
+	Branding Branding `yaml:"branding"`
+	// Classification labels every report: Public, Internal, Confidential
+	// or Restricted. Empty leaves reports unlabelled.
+	Classification string `yaml:"classification"`
+}
+
+// Validate checks the locale, time zone, branding and classification.
+func (c Config) Validate() error {
+	if c.Locale != "" {
+		if _, err := language.Parse(c.Locale); err != nil {
+			return fmt.Errorf("report locale %q: %w", c.Locale, err)
+		}
+	}
+	if _, err := ParseTimeZone(c.TimeZone); err != nil {
+		return err
+	}
+	if _, err := ParseClassification(c.Classification); err != nil {
+		return err
+	}
+	return c.Branding.Validate()
+}
+
+// ParseTimeZone loads the IANA time zone name for Report.Location. An
+// empty name returns nil, the server's local zone.
+func ParseTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("report time zone %q: %w", name, err)
+	}
+	return location, nil
+}
@@ -0,0 +1,183 @@
+package reporting
+
+import (
+	"fmt"
+	"html"
+)
+
+// executiveList is a numbered list of the executive summary.
+type executiveList struct {
+	heading string
+	items   []string
+}
+
+// executiveLists returns the non-empty lists of the executive summary in
+// the order reports show them.
+func executiveLists(summary ExecutiveSummary) []executiveList {
+	var lists []executiveList
+	for _, list := range []executiveList{
+		{"Top Concerns", summary.TopConcerns},
+		{"Top Achievements", summary.TopAchievements},
+		{"Recommendations", summary.Recommendations},
+		{"Action Items", summary.ActionItems},
+	} {
+		if len(list.items) > 0 {
+			lists = append(lists, list)
+		}
+	}
+	return lists
+}
+
+// GenerateExecutiveMarkdown generates the executive report in Markdown.
+func GenerateExecutiveMarkdown(report *Report) string {
+	var reportStr string
+	f := report.formatter()
+
+	reportStr += classificationMarkdown(report.Classification)
+	reportStr += "# Executive Security Metrics Report\n\n"
+	reportStr += "**Report ID:** " + report.ID + "\n"
+	reportStr += "**Title:** " + report.Title + "\n"
+	reportStr += "**Created:** " + f.dateTime(report.CreatedAt) + "\n\n"
+
+	reportStr += "## Executive Summary\n\n"
+	reportStr += "| Metric | Value |\n"
+	reportStr += "|--------|-------|\n"
+	reportStr += "| Overall Health | " + report.Executive.OverallHealth + " |\n"
+	reportStr += "| Compliance Score | " + scoreOrNoData(f.percent(report.Executive.ComplianceScore, 1), report.Executive.NoComplianceData) + " |\n"
+	reportStr += "| Risk Score | " + scoreOrNoData(f.number(report.Executive.RiskScore, 1), report.Executive.NoRiskData) + " |\n\n"
+
+	if report.Narrative != nil {
+		reportStr += formatNarrativeMarkdown(report.Narrative)
+	}
+
+	if report.ZeroTrust != nil {
+		reportStr += formatZeroTrustMarkdown(f, report.ZeroTrust)
+	}
+
+	if len(report.Categories) > 0 {
+		reportStr += formatCategoriesMarkdown(f, report.Categories)
+	}
+
+	if len(report.Campaigns) > 0 {
+		reportStr += formatCampaignsMarkdown(f, report.Campaigns)
+	}
+
+	for _, list := range executiveLists(report.Executive) {
+		reportStr += "## " + list.heading + "\n\n"
+		for i, item := range list.items {
+			reportStr += fmt.Sprintf("%d. ", i+1) + item + "\n"
+		}
+		reportStr += "\n"
+	}
+
+	return reportStr
+}
+
+// GenerateExecutiveHTML generates the executive report as an HTML page.
+func GenerateExecutiveHTML(report *Report) string {
+	var reportStr string
+	f := report.formatter()
+
+	reportStr = htmlDocument(report.Locale, "Executive Security Metrics Report - "+report.Title)
+	reportStr += report.Brand.htmlStyle()
+	reportStr += "</head>\n<body>\n"
+	reportStr += classificationHTML(report.Classification)
+	reportStr += report.Brand.htmlHeader()
+	reportStr += "<main>\n"
+	reportStr += "<h1>Executive Security Metrics Report</h1>\n"
+	reportStr += "<p><strong>Report ID:</strong> " + report.ID + "</p>\n"
+	reportStr += "<p><strong>Title:</strong> " + html.EscapeString(report.Title) + "</p>\n"
+	reportStr += "<p><strong>Created:</strong> " + f.dateTime(report.CreatedAt) + "</p>\n"
+
+	reportStr += "<h2>Executive Summary</h2>\n"
+	reportStr += htmlTable("Executive summary", "Metric", "Value")
+	reportStr += "<tr>" + htmlRowHeader("Overall Health") + "<td>" + html.EscapeString(report.Executive.OverallHealth) + "</td></tr>\n"
+	reportStr += "<tr>" + htmlRowHeader("Compliance Score") + "<td>" + scoreOrNoData(f.percent(report.Executive.ComplianceScore, 1), report.Executive.NoComplianceData) + "</td></tr>\n"
+	reportStr += "<tr>" + htmlRowHeader("Risk Score") + "<td>" + scoreOrNoData(f.number(report.Executive.RiskScore, 1), report.Executive.NoRiskData) + "</td></tr>\n"
+	reportStr += htmlTableEnd
+
+	if report.Narrative != nil {
+		reportStr += formatNarrativeHTML(report.Narrative)
+	}
+
+	if report.ZeroTrust != nil {
+		reportStr += formatZeroTrustHTML(f, report.ZeroTrust)
+	}
+
+	if len(report.Categories) > 0 {
+		reportStr += formatCategoriesHTML(f, report.Categories)
+	}
+
+	if len(report.Campaigns) > 0 {
+		reportStr += formatCampaignsHTML(f, report.Campaigns)
+	}
+
+	for _, list := range executiveLists(report.Executive) {
+		reportStr += "<h2>" + list.heading + "</h2>\n<ol>\n"
+		for _, item := range list.items {
+			reportStr += "<li>" + html.EscapeString(item) + "</li>\n"
+		}
+		reportStr += "</ol>\n"
+	}
+
+	reportStr += "</main>\n"
+	reportStr += report.Brand.htmlFooter()
+	reportStr += classificationHTML(report.Classification)
+	reportStr += "</body>\n</html>\n"
+
+	return reportStr
+}
+
+// GenerateExecutivePDF generates the executive report as a single-page A4
+// PDF: the scores, the top-level categories and the summary lists.
+func GenerateExecutivePDF(report *Report) ([]byte, error) {
+	f := report.formatter()
+	const left = 50.0
+	y := pdfPageHeight - 70
+	page, text, heading := newReportPDFPage(report, left)
+
+	page.fill(heading)
+	page.text(left, y, 18, true, "Executive Security Metrics Report")
+	y -= 22
+	page.fill(text)
+	page.text(left, y, 10, false, truncateText(report.Title, OnePagerMaxTextLength)+" - "+f.dateTime(report.CreatedAt))
+	y -= 34
+
+	page.fill(heading)
+	page.text(left, y, 14, true, "Overall Health: "+report.Executive.OverallHealth)
+	y -= 20
+	page.fill(text)
+	page.text(left, y, 11, false, "Compliance Score: "+scoreOrNoData(f.percent(report.Executive.ComplianceScore, 1), report.Executive.NoComplianceData)+
+		"    Risk Score: "+scoreOrNoData(f.number(report.Executive.RiskScore, 1), report.Executive.NoRiskData))
+	y -= 36
+
+	if len(report.Categories) > 0 {
+		page.fill(heading)
+		page.text(left, y, 14, true, "Categories")
+		y -= 22
+		page.fill(text)
+		for _, category := range report.Categories {
+			page.text(left, y, 11, false, fmt.Sprintf("%s: %s, %s (%d/%d on target)", truncateText(category.Name, OnePagerMaxTextLength), category.Health,
+				scoreOrNoData(f.percent(category.Score, 1), category.NoData), category.OnTarget, category.KPIs))
+			y -= 18
+		}
+		y -= 20
+	}
+
+	for _, list := range executiveLists(report.Executive) {
+		page.fill(heading)
+		page.text(left, y, 14, true, list.heading)
+		y -= 22
+		page.fill(text)
+		for i, item := range list.items {
+			page.text(left, y, 11, false, fmt.Sprintf("%d. %s", i+1, truncateText(item, OnePagerMaxTextLength)))
+			y -= 18
+		}
+		y -= 20
+	}
+
+	if y < 50 {
+		return nil, fmt.Errorf("executive report overflows the page")
+	}
+	return page.bytes(), nil
+}
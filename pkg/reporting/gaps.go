@@ -0,0 +1,188 @@
+package reporting
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNoGaps is returned when a report carries no gap analysis.
+var ErrNoGaps = errors.New("report has no gap analysis")
+
+// GapData represents the coverage gap analysis of the asset inventory.
+type GapData struct {
+	Assets   int
+	Target   float64
+	Controls []ControlGapData
+}
+
+// ControlGapData represents the coverage of one control. Uncovered lists
+// the assets the control misses, most critical first.
+type ControlGapData struct {
+	Name       string
+	Applicable int
+	Covered    int
+	Coverage   float64
+	Uncovered  []GapAssetData
+}
+
+// GapAssetData represents an asset missing a control.
+type GapAssetData struct {
+	ID          string
+	Name        string
+	Type        string
+	Owner       string
+	Criticality string
+}
+
+// gapWeightingNote explains how coverage percentages are computed.
+const gapWeightingNote = "Coverage is weighted by asset criticality: a crown jewel counts for more than a standard asset."
+
+// gapStatus describes a control's coverage against the target.
+func gapStatus(coverage, target float64) string {
+	if coverage >= target {
+		return "ON_TARGET"
+	}
+	return "BELOW_TARGET"
+}
+
+// orNone returns s, or "-" for an empty field.
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// GenerateGapReport generates the coverage gap analysis as text.
+func GenerateGapReport(report *Report) (string, error) {
+	gaps := report.Gaps
+	if gaps == nil {
+		return "", ErrNoGaps
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr += classificationText(report.Classification)
+	reportStr += "=== Coverage Gap Analysis ===\n\n"
+	reportStr += "Report ID: " + report.ID + "\n"
+	reportStr += "Generated: " + f.dateTime(report.CreatedAt) + "\n"
+	reportStr += "Assets in Inventory: " + f.integer(gaps.Assets) + "\n"
+	reportStr += "Coverage Target: " + f.percent(gaps.Target, 1) + "\n\n"
+
+	reportStr += "Control Coverage\n"
+	reportStr += "================\n\n"
+	reportStr += gapWeightingNote + "\n\n"
+	for _, control := range gaps.Controls {
+		reportStr += "  " + control.Name + ": " + f.percent(control.Coverage, 1) + " (" + f.integer(control.Covered) + "/" + f.integer(control.Applicable) + " assets) " + gapStatus(control.Coverage, gaps.Target) + "\n"
+	}
+	reportStr += "\n"
+
+	for _, control := range gaps.Controls {
+		title := "Uncovered Assets: " + control.Name
+		reportStr += title + "\n"
+		reportStr += strings.Repeat("=", utf8.RuneCountInString(title)) + "\n\n"
+		if len(control.Uncovered) == 0 {
+			reportStr += "All applicable assets covered.\n\n"
+			continue
+		}
+		for _, asset := range control.Uncovered {
+			reportStr += "  [" + orNone(asset.Criticality) + "] " + asset.ID + " " + asset.Name + " (" + orNone(asset.Type) + ", owner " + orNone(asset.Owner) + ")\n"
+		}
+		reportStr += "\n"
+	}
+
+	return reportStr, nil
+}
+
+// GenerateGapMarkdown generates the coverage gap analysis in Markdown.
+func GenerateGapMarkdown(report *Report) (string, error) {
+	gaps := report.Gaps
+	if gaps == nil {
+		return "", ErrNoGaps
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr += classificationMarkdown(report.Classification)
+	reportStr += "# Coverage Gap Analysis\n\n"
+	reportStr += "**Report ID:** " + report.ID + "\n\n"
+	reportStr += "**Generated:** " + f.dateTime(report.CreatedAt) + "\n\n"
+	reportStr += "**Assets in Inventory:** " + f.integer(gaps.Assets) + "\n\n"
+	reportStr += "**Coverage Target:** " + f.percent(gaps.Target, 1) + "\n\n"
+
+	reportStr += "## Control Coverage\n\n"
+	reportStr += gapWeightingNote + "\n\n"
+	reportStr += "| Control | Coverage | Covered | Uncovered | Status |\n"
+	reportStr += "|---------|----------|---------|-----------|--------|\n"
+	for _, control := range gaps.Controls {
+		reportStr += "| " + control.Name + " | " + f.percent(control.Coverage, 1) + " | " + f.integer(control.Covered) + "/" + f.integer(control.Applicable) + " | " + f.integer(len(control.Uncovered)) + " | " + gapStatus(control.Coverage, gaps.Target) + " |\n"
+	}
+	reportStr += "\n"
+
+	for _, control := range gaps.Controls {
+		reportStr += "## Uncovered Assets: " + control.Name + "\n\n"
+		if len(control.Uncovered) == 0 {
+			reportStr += "All applicable assets covered.\n\n"
+			continue
+		}
+		reportStr += "| Asset | Name | Type | Owner | Criticality |\n"
+		reportStr += "|-------|------|------|-------|-------------|\n"
+		for _, asset := range control.Uncovered {
+			reportStr += "| " + asset.ID + " | " + orNone(asset.Name) + " | " + orNone(asset.Type) + " | " + orNone(asset.Owner) + " | " + orNone(asset.Criticality) + " |\n"
+		}
+		reportStr += "\n"
+	}
+
+	return reportStr, nil
+}
+
+// GenerateGapHTML generates the coverage gap analysis in HTML.
+func GenerateGapHTML(report *Report) (string, error) {
+	gaps := report.Gaps
+	if gaps == nil {
+		return "", ErrNoGaps
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr = htmlDocument(report.Locale, "Coverage Gap Analysis")
+	reportStr += report.Brand.htmlStyle()
+	reportStr += "</head>\n<body>\n"
+	reportStr += classificationHTML(report.Classification)
+	reportStr += report.Brand.htmlHeader()
+	reportStr += "<main>\n"
+	reportStr += "<h1>Coverage Gap Analysis</h1>\n"
+	reportStr += "<p><strong>Report ID:</strong> " + html.EscapeString(report.ID) + "</p>\n"
+	reportStr += "<p><strong>Generated:</strong> " + f.dateTime(report.CreatedAt) + "</p>\n"
+	reportStr += "<p><strong>Assets in Inventory:</strong> " + f.integer(gaps.Assets) + "</p>\n"
+	reportStr += "<p><strong>Coverage Target:</strong> " + f.percent(gaps.Target, 1) + "</p>\n"
+
+	reportStr += "<h2>Control Coverage</h2>\n"
+	reportStr += "<p>" + gapWeightingNote + "</p>\n"
+	reportStr += htmlTable("Coverage by control", "Control", "Coverage", "Covered", "Uncovered", "Status")
+	for _, control := range gaps.Controls {
+		reportStr += "<tr>" + htmlRowHeader(control.Name) + "<td>" + f.percent(control.Coverage, 1) + "</td><td>" + f.integer(control.Covered) + "/" + f.integer(control.Applicable) + "</td><td>" + f.integer(len(control.Uncovered)) + "</td><td>" + gapStatus(control.Coverage, gaps.Target) + "</td></tr>\n"
+	}
+	reportStr += htmlTableEnd
+
+	for _, control := range gaps.Controls {
+		reportStr += "<h2>Uncovered Assets: " + html.EscapeString(control.Name) + "</h2>\n"
+		if len(control.Uncovered) == 0 {
+			reportStr += "<p>All applicable assets covered.</p>\n"
+			continue
+		}
+		reportStr += htmlTable("Assets without "+control.Name+", most critical first", "Asset", "Name", "Type", "Owner", "Criticality")
+		for _, asset := range control.Uncovered {
+			reportStr += "<tr>" + htmlRowHeader(asset.ID) + "<td>" + html.EscapeString(orNone(asset.Name)) + "</td><td>" + html.EscapeString(orNone(asset.Type)) + "</td><td>" + html.EscapeString(orNone(asset.Owner)) + "</td><td>" + html.EscapeString(orNone(asset.Criticality)) + "</td></tr>\n"
+		}
+		reportStr += htmlTableEnd
+	}
+	reportStr += "</main>\n"
+	reportStr += report.Brand.htmlFooter()
+	reportStr += classificationHTML(report.Classification)
+	reportStr += "</body>\n</html>\n"
+
+	return reportStr, nil
+}
@@ -0,0 +1,205 @@
+package reporting
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// accessibilityReport returns a report exercising every HTML section.
+func accessibilityReport() *Report {
+	detected := time.Date(2026, 9, 2, 14, 5, 0, 0, time.UTC)
+	return &Report{
+		ID:             "rpt-test",
+		Title:          "Accessibility",
+		CreatedAt:      detected,
+		Classification: ClassificationConfidential,
+		KPIS: []KPIData{
+			{Key: "mttr", Name: "Mean Time to Respond", Value: 2.5, Target: 1, Unit: "hours", Status: "BELOW_TARGET", Trend: "IMPROVING", Direction: "lower_is_better", History: []float64{4, 3, 2.5}, Bands: &TargetBands{Warning: 2, Critical: 4}},
+			{Key: "coverage", Name: "Security Coverage", Value: 85, Target: 100, Unit: "%", Status: "BELOW_TARGET", Trend: "STABLE"},
+		},
+		ZeroTrust: &ZeroTrustData{Score: 60, Maturity: "ADVANCED", Pillars: []PillarData{
+			{Name: "Identity", KPIName: "MFA Adoption", Value: 90, Target: 100, Unit: "%", Progress: 90, Measured: true},
+			{Name: "Devices", KPIName: "Device Compliance"},
+		}},
+		Categories: []CategoryData{
+			{Name: "Response", KPIs: 1, Score: 40, Health: "POOR", Subcategories: []CategoryData{{Name: "Triage", KPIs: 1, Score: 40, Health: "POOR"}}},
+		},
+		OnePager: &OnePagerData{Score: 66.5, Health: "FAIR", Trends: []TrendData{
+			{Name: "MTTD", Value: 0.5, Unit: "hours", Trend: "IMPROVING"},
+			{Name: "MTTR", Value: 2.5, Unit: "hours", Trend: "DECLINING"},
+			{Name: "Coverage", Value: 85, Unit: "%", Trend: "STABLE"},
+		}},
+		Ops: &OpsData{
+			Month:     detected,
+			Incidents: []IncidentData{{ID: "INC-1", Title: "Phishing", Severity: "high", DetectedAt: detected}},
+			Alerts:    AlertStatsData{Total: 2, BySeverity: []CountData{{Label: "high", Count: 2}}},
+			Movements: []MovementData{{Name: "MTTR", Unit: "hours", Start: 4, End: 2.5, Delta: -1.5, Trend: "IMPROVING"}},
+		},
+		Campaigns: []CampaignData{{
+			Name: "Log4j cleanup", Owner: "appsec", Start: detected, Deadline: detected.AddDate(0, 0, 30), State: "AT_RISK",
+			Total: 10, Open: 6, Resolved: 4, Progress: 40, Expected: 50, BurnDown: []int{10, 8, 6},
+			Owners: []CampaignOwnerData{{Owner: "web", Open: 4, Resolved: 2}, {Open: 2, Resolved: 2}},
+		}},
+		Gaps: &GapData{Assets: 3, Target: 95, Controls: []ControlGapData{
+			{Name: "EDR", Applicable: 3, Covered: 2, Coverage: 66.7, Uncovered: []GapAssetData{{ID: "srv-1", Name: "db01", Type: "server", Criticality: "critical"}}},
+			{Name: "Backup", Applicable: 1, Covered: 1, Coverage: 100},
+		}},
+		Targets: &TargetsData{Rows: []TargetData{
+			{Name: "Mean Time to Respond", Owner: "SOC", Value: 2.5, Target: 1, Unit: "hours", Gap: 1.5, Progress: 40, Direction: "lower_is_better", Forecast: ForecastConverging, TimeToTarget: 90 * 24 * time.Hour},
+			{Name: "Security Coverage", Value: 85, Target: 100, Unit: "%", Gap: 15, Progress: 85, Direction: "higher_is_better", Forecast: ForecastNoHistory},
+		}},
+		Narrative: &NarrativeData{Quarter: "2026-Q3", Text: "Response times improved.\n\nCoverage <still> lags."},
+	}
+}
+
+// renderHTML renders every HTML report for the accessibility checks.
+func renderHTML(t *testing.T, report *Report) map[string]string {
+	t.Helper()
+	onePager, err := GenerateOnePagerHTML(report)
+	if err != nil {
+		t.Fatalf("GenerateOnePagerHTML: %v", err)
+	}
+	ops, err := GenerateOpsHTML(report)
+	if err != nil {
+		t.Fatalf("GenerateOpsHTML: %v", err)
+	}
+	gaps, err := GenerateGapHTML(report)
+	if err != nil {
+		t.Fatalf("GenerateGapHTML: %v", err)
+	}
+	targets, err := GenerateTargetsHTML(report)
+	if err != nil {
+		t.Fatalf("GenerateTargetsHTML: %v", err)
+	}
+	return map[string]string{
+		"html":      GenerateHTMLReport(report),
+		"executive": GenerateExecutiveHTML(report),
+		"onepager":  onePager,
+		"ops":       ops,
+		"gaps":      gaps,
+		"targets":   targets,
+	}
+}
+
+func TestHTMLDocumentLanguage(t *testing.T) {
+	report := accessibilityReport()
+	for name, out := range renderHTML(t, report) {
+		if !strings.Contains(out, `<html lang="en">`) {
+			t.Errorf("%s: missing default document language", name)
+		}
+	}
+
+	report.Locale = "de-DE"
+	for name, out := range renderHTML(t, report) {
+		if !strings.Contains(out, `<html lang="de">`) {
+			t.Errorf("%s: document language does not follow the locale", name)
+		}
+	}
+}
+
+func TestHTMLTablesHaveHeaders(t *testing.T) {
+	tablePattern := regexp.MustCompile(`(?s)<table>(.*?)</table>`)
+	bodyPattern := regexp.MustCompile(`(?s)<tbody>\n(.*?)</tbody>`)
+	for name, out := range renderHTML(t, accessibilityReport()) {
+		tables := tablePattern.FindAllStringSubmatch(out, -1)
+		if len(tables) == 0 {
+			t.Errorf("%s: no tables rendered", name)
+		}
+		for _, table := range tables {
+			body := table[1]
+			if !strings.HasPrefix(body, "\n<caption>") || strings.Contains(body, "<caption></caption>") {
+				t.Errorf("%s: table without a leading caption:\n%s", name, body)
+			}
+			if !strings.Contains(body, "<thead>") || !strings.Contains(body, `<th scope="col">`) {
+				t.Errorf("%s: table without column headers:\n%s", name, body)
+			}
+			if strings.Contains(body, "<th>") {
+				t.Errorf("%s: header cell without scope:\n%s", name, body)
+			}
+			rows := bodyPattern.FindStringSubmatch(body)
+			if rows == nil {
+				t.Errorf("%s: table without a body:\n%s", name, body)
+				continue
+			}
+			for _, row := range strings.Split(strings.TrimSpace(rows[1]), "\n") {
+				if row != "" && !strings.HasPrefix(row, `<tr><th scope="row"`) {
+					t.Errorf("%s: body row without a row header: %s", name, row)
+				}
+			}
+		}
+	}
+}
+
+func TestHTMLTrendIconsAreLabelled(t *testing.T) {
+	for name, out := range renderHTML(t, accessibilityReport()) {
+		for _, arrow := range []string{"↑", "↓", "→"} {
+			unlabelled := strings.Count(out, arrow) - strings.Count(out, `">`+arrow+`</span>`)
+			if unlabelled != 0 {
+				t.Errorf("%s: %d %s icons without an aria label", name, unlabelled, arrow)
+			}
+		}
+		if strings.Contains(out, `<span role="img">`) {
+			t.Errorf("%s: icon with role img but no label", name)
+		}
+	}
+
+	out := renderHTML(t, accessibilityReport())["onepager"]
+	for _, want := range []string{
+		`<span role="img" aria-label="improving">↑</span> IMPROVING`,
+		`<span role="img" aria-label="declining">↓</span> DECLINING`,
+		`<span role="img" aria-label="stable">→</span> STABLE`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("onepager: missing trend %q", want)
+		}
+	}
+}
+
+func TestHTMLStatusNotConveyedByColorAlone(t *testing.T) {
+	report := accessibilityReport()
+	out := GenerateHTMLReport(report)
+
+	for _, kpi := range report.KPIS {
+		if !strings.Contains(out, "Status: "+kpi.Status) {
+			t.Errorf("KPI %s status not shown as text", kpi.Key)
+		}
+	}
+	if !strings.Contains(out, `role="img" aria-labelledby="chart-mttr-title chart-mttr-desc"`) {
+		t.Error("chart is not labelled for screen readers")
+	}
+	if !strings.Contains(out, `<desc id="chart-mttr-desc">Value 2.5 hours, target 1 hours, status BELOW_TARGET. Warning above 2, critical above 4. Trend over 3 samples: IMPROVING.</desc>`) {
+		t.Error("chart description does not state value, target, status and bands")
+	}
+	if !strings.Contains(out, `<progress max="100" aria-label="Identity progress"`) {
+		t.Error("progress bar is not labelled")
+	}
+	if !strings.Contains(out, `<td>POOR</td>`) {
+		t.Error("category health not shown as text")
+	}
+	if got := strings.Count(out, `role="note"`); got != 2 {
+		t.Errorf("classification markings = %d, want 2 (top and bottom)", got)
+	}
+	if !strings.Contains(out, ">CONFIDENTIAL</div>") {
+		t.Error("classification not shown as text")
+	}
+}
+
+func TestHTMLLandmarksAndImages(t *testing.T) {
+	report := accessibilityReport()
+	report.Brand = &Brand{PrimaryColor: DefaultPrimaryColor, Theme: ThemeLight, Logo: []byte{0}, LogoType: "image/png"}
+	for name, out := range renderHTML(t, report) {
+		if strings.Count(out, "<main>") != 1 || strings.Count(out, "</main>") != 1 {
+			t.Errorf("%s: want exactly one main landmark", name)
+		}
+		if !strings.Contains(out, `<meta charset="utf-8">`) {
+			t.Errorf("%s: missing charset", name)
+		}
+		for _, img := range regexp.MustCompile(`<img [^>]*>`).FindAllString(out, -1) {
+			if !strings.Contains(img, ` alt="`) {
+				t.Errorf("%s: image without alt text: %s", name, img)
+			}
+		}
+	}
+}
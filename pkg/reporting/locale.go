@@ -0,0 +1,177 @@
+package reporting
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// dateLayouts holds the time layouts used for a locale.
+type dateLayouts struct {
+	date       string
+	clock      string
+	shortClock string
+	month      string
+}
+
+// defaultDateLayouts are used when no locale is set.
+var defaultDateLayouts = dateLayouts{date: "2006-01-02", clock: "15:04:05", shortClock: "15:04", month: "January 2006"}
+
+// localeTags lists the locales with date layouts, in the order of
+// localeDateLayouts. The first entry is the fallback.
+var localeTags = []language.Tag{
+	language.AmericanEnglish,
+	language.BritishEnglish,
+	language.German,
+	language.French,
+	language.Spanish,
+	language.Italian,
+	language.Dutch,
+	language.Portuguese,
+	language.Swedish,
+	language.Japanese,
+	language.Chinese,
+}
+
+var localeDateLayouts = []dateLayouts{
+	{date: "01/02/2006", clock: "3:04:05 PM", shortClock: "3:04 PM", month: "01/2006"},
+	{date: "02/01/2006", clock: "15:04:05", shortClock: "15:04", month: "01/2006"},
+	{date: "02.01.2006", clock: "15:04:05", shortClock: "15:04", month: "01.2006"},
+	{date: "02/01/2006", clock: "15:04:05", shortClock: "15:04", month: "01/2006"},
+	{date: "02/01/2006", clock: "15:04:05", shortClock: "15:04", month: "01/2006"},
+	{date: "02/01/2006", clock: "15:04:05", shortClock: "15:04", month: "01/2006"},
+	{date: "02-01-2006", clock: "15:04:05", shortClock: "15:04", month: "01-2006"},
+	{date: "02/01/2006", clock: "15:04:05", shortClock: "15:04", month: "01/2006"},
+	{date: "2006-01-02", clock: "15:04:05", shortClock: "15:04", month: "2006-01"},
+	{date: "2006/01/02", clock: "15:04:05", shortClock: "15:04", month: "2006/01"},
+	{date: "2006/01/02", clock: "15:04:05", shortClock: "15:04", month: "2006/01"},
+}
+
+var localeMatcher = language.NewMatcher(localeTags)
+
+// formatter formats numbers, percentages and dates for a report locale.
+// The zero value uses the default formatting.
+type formatter struct {
+	printer *message.Printer
+	dates   dateLayouts
+	// location is the time zone timestamps are shown in, with the zone
+	// name; nil shows them in the local zone without it.
+	location *time.Location
+	// local replaces the local zone when set.
+	local *time.Location
+}
+
+// formatter returns the formatter for the report's locale and time zone.
+func (r *Report) formatter() formatter {
+	f := newFormatter(r.Locale)
+	f.location, f.local = r.Location, r.localZone
+	return f
+}
+
+// newFormatter returns a formatter for locale. An empty or malformed
+// locale yields the default formatting.
+func newFormatter(locale string) formatter {
+	if locale == "" {
+		return formatter{dates: defaultDateLayouts}
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return formatter{dates: defaultDateLayouts}
+	}
+	_, index, _ := localeMatcher.Match(tag)
+	return formatter{printer: message.NewPrinter(tag), dates: localeDateLayouts[index]}
+}
+
+// number formats v with the given number of decimals, e.g. "1,234.5".
+func (f formatter) number(v float64, decimals int) string {
+	if f.printer == nil {
+		return fmt.Sprintf("%.*f", decimals, v)
+	}
+	return f.printer.Sprintf("%.*f", decimals, v)
+}
+
+// signed formats v like number with an explicit sign, e.g. "+1.5".
+func (f formatter) signed(v float64, decimals int) string {
+	if f.printer == nil {
+		return fmt.Sprintf("%+.*f", decimals, v)
+	}
+	return f.printer.Sprintf("%+.*f", decimals, v)
+}
+
+// value formats v compactly like %g, e.g. "0.25" or "1,234".
+func (f formatter) value(v float64) string {
+	if f.printer == nil {
+		return fmt.Sprintf("%g", v)
+	}
+	return f.printer.Sprint(number.Decimal(v))
+}
+
+// FormatValue formats v compactly for locale, as report values are
+// formatted. It is used for values embedded in report text.
+func FormatValue(locale string, v float64) string {
+	return newFormatter(locale).value(v)
+}
+
+// integer formats n, e.g. "1,234".
+func (f formatter) integer(n int) string {
+	if f.printer == nil {
+		return fmt.Sprintf("%d", n)
+	}
+	return f.printer.Sprintf("%d", n)
+}
+
+// percent formats a 0-100 value as a percentage, e.g. "92.5%".
+func (f formatter) percent(v float64, decimals int) string {
+	if f.printer == nil {
+		return fmt.Sprintf("%.*f%%", decimals, v)
+	}
+	return f.printer.Sprint(number.Percent(v/100, number.Scale(decimals)))
+}
+
+// scoreOrNoData returns the formatted score, or n/a when the score has no
+// data to compute it from.
+func scoreOrNoData(formatted string, noData bool) string {
+	if noData {
+		return "n/a"
+	}
+	return formatted
+}
+
+// signedPercent formats a 0-100 value as a percentage with an explicit
+// sign, e.g. "+2.5%".
+func (f formatter) signedPercent(v float64, decimals int) string {
+	if v >= 0 {
+		return "+" + f.percent(v, decimals)
+	}
+	return f.percent(v, decimals)
+}
+
+// dateTime formats t with seconds.
+func (f formatter) dateTime(t time.Time) string {
+	return f.timestamp(t, f.dates.date+" "+f.dates.clock)
+}
+
+// shortDateTime formats t to the minute.
+func (f formatter) shortDateTime(t time.Time) string {
+	return f.timestamp(t, f.dates.date+" "+f.dates.shortClock)
+}
+
+// timestamp formats t with layout in the formatter's time zone. Dates
+// without a time of day, such as campaign deadlines, are not converted.
+func (f formatter) timestamp(t time.Time, layout string) string {
+	if f.location == nil {
+		if f.local != nil {
+			return t.In(f.local).Format(layout)
+		}
+		return t.Local().Format(layout)
+	}
+	return t.In(f.location).Format(layout + " MST")
+}
+
+// month formats the month containing t, e.g. "January 2006".
+func (f formatter) month(t time.Time) string {
+	return t.Format(f.dates.month)
+}
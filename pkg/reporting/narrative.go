@@ -0,0 +1,63 @@
+package reporting
+
+import (
+	"html"
+	"strings"
+)
+
+// NarrativeData represents the reviewed narrative of a quarter.
+type NarrativeData struct {
+	// Quarter is e.g. 2026-Q3.
+	Quarter    string
+	Text       string
+	ApprovedBy string
+}
+
+// narrativeParagraphs splits a narrative into paragraphs at blank lines.
+func narrativeParagraphs(text string) []string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return paragraphs
+}
+
+// formatNarrative formats the narrative section of text reports.
+func formatNarrative(narrative *NarrativeData) string {
+	var reportStr string
+
+	reportStr += "Quarterly Narrative (" + narrative.Quarter + ")\n"
+	reportStr += strings.Repeat("=", len("Quarterly Narrative ()")+len(narrative.Quarter)) + "\n\n"
+	for _, paragraph := range narrativeParagraphs(narrative.Text) {
+		reportStr += paragraph + "\n\n"
+	}
+
+	return reportStr
+}
+
+// formatNarrativeMarkdown formats the narrative section of Markdown
+// reports.
+func formatNarrativeMarkdown(narrative *NarrativeData) string {
+	var reportStr string
+
+	reportStr += "## Quarterly Narrative (" + narrative.Quarter + ")\n\n"
+	for _, paragraph := range narrativeParagraphs(narrative.Text) {
+		reportStr += paragraph + "\n\n"
+	}
+
+	return reportStr
+}
+
+// formatNarrativeHTML formats the narrative section of HTML reports.
+func formatNarrativeHTML(narrative *NarrativeData) string {
+	var reportStr string
+
+	reportStr += "<h2>Quarterly Narrative (" + html.EscapeString(narrative.Quarter) + ")</h2>\n"
+	for _, paragraph := range narrativeParagraphs(narrative.Text) {
+		reportStr += "<p>" + strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n") + "</p>\n"
+	}
+
+	return reportStr
+}
@@ -0,0 +1,240 @@
+package reporting
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// One-pager length limits. Lists are cut to their limits and text is
+// truncated, so the rendered page always fits on one side of A4.
+const (
+	OnePagerMaxTrends     = 3
+	OnePagerMaxItems      = 3
+	OnePagerMaxTextLength = 80
+	OnePagerMaxLines      = 30
+)
+
+// ErrNoOnePager is returned when a report carries no one-pager data.
+var ErrNoOnePager = errors.New("report has no one-pager data")
+
+// OnePagerData represents the executive one-pager.
+type OnePagerData struct {
+	// Score is the overall posture score from 0 to 100.
+	Score  float64
+	Health string
+	// NoData marks a posture score without a KPI to score, shown as n/a.
+	NoData bool
+	Trends []TrendData
+	Risks  []string
+	Wins   []string
+}
+
+// TrendData represents a headline KPI and its trend. Trend is IMPROVING,
+// DECLINING or STABLE.
+type TrendData struct {
+	Name  string
+	Value float64
+	Unit  string
+	Trend string
+}
+
+// trendArrow returns the arrow shown for a trend.
+func trendArrow(trend string) string {
+	switch trend {
+	case "IMPROVING":
+		return "↑"
+	case "DECLINING":
+		return "↓"
+	}
+	return "→"
+}
+
+// limitedOnePager returns the report's one-pager cut to the length limits.
+func limitedOnePager(report *Report) (*OnePagerData, string, error) {
+	if report.OnePager == nil {
+		return nil, "", ErrNoOnePager
+	}
+	limited := *report.OnePager
+	limited.Trends = nil
+	for i, trend := range report.OnePager.Trends {
+		if i == OnePagerMaxTrends {
+			break
+		}
+		trend.Name = truncateText(trend.Name, OnePagerMaxTextLength)
+		limited.Trends = append(limited.Trends, trend)
+	}
+	limited.Risks = limitItems(report.OnePager.Risks)
+	limited.Wins = limitItems(report.OnePager.Wins)
+	return &limited, truncateText(report.Title, OnePagerMaxTextLength), nil
+}
+
+// limitItems returns at most OnePagerMaxItems items, each truncated.
+func limitItems(items []string) []string {
+	var limited []string
+	for i, item := range items {
+		if i == OnePagerMaxItems {
+			break
+		}
+		limited = append(limited, truncateText(item, OnePagerMaxTextLength))
+	}
+	return limited
+}
+
+// truncateText shortens s to at most max characters, ending in "...".
+func truncateText(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-3])) + "..."
+}
+
+// checkLines returns an error when Markdown content exceeds
+// OnePagerMaxLines.
+func checkLines(content string) error {
+	if lines := strings.Count(content, "\n"); lines > OnePagerMaxLines {
+		return fmt.Errorf("one-pager is %d lines, limit is %d", lines, OnePagerMaxLines)
+	}
+	return nil
+}
+
+// GenerateOnePagerMarkdown generates the one-pager in Markdown.
+func GenerateOnePagerMarkdown(report *Report) (string, error) {
+	onePager, title, err := limitedOnePager(report)
+	if err != nil {
+		return "", err
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr += classificationMarkdown(report.Classification)
+	reportStr += "# " + title + "\n\n"
+	reportStr += "**Posture Score:** " + scoreOrNoData(f.percent(onePager.Score, 1), onePager.NoData) + " (" + onePager.Health + ")\n\n"
+
+	if len(onePager.Trends) > 0 {
+		reportStr += "| KPI | Trend | Value |\n"
+		reportStr += "|-----|-------|-------|\n"
+		for _, trend := range onePager.Trends {
+			reportStr += "| " + trend.Name + " | " + trendArrow(trend.Trend) + " " + trend.Trend + " | " + f.value(trend.Value) + " " + trend.Unit + " |\n"
+		}
+		reportStr += "\n"
+	}
+
+	reportStr += "## Top Risks\n\n"
+	for i, risk := range onePager.Risks {
+		reportStr += fmt.Sprintf("%d. ", i+1) + risk + "\n"
+	}
+	reportStr += "\n## Top Wins\n\n"
+	for i, win := range onePager.Wins {
+		reportStr += fmt.Sprintf("%d. ", i+1) + win + "\n"
+	}
+
+	if err := checkLines(reportStr); err != nil {
+		return "", err
+	}
+	return reportStr, nil
+}
+
+// GenerateOnePagerHTML generates the one-pager as an HTML page sized for A4.
+func GenerateOnePagerHTML(report *Report) (string, error) {
+	onePager, title, err := limitedOnePager(report)
+	if err != nil {
+		return "", err
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr = htmlDocument(report.Locale, title)
+	reportStr += "<style>@page { size: A4; margin: 2cm; } body { font-family: sans-serif; max-width: 17cm; }</style>\n"
+	reportStr += report.Brand.htmlStyle()
+	reportStr += "</head>\n<body>\n"
+	reportStr += classificationHTML(report.Classification)
+	reportStr += report.Brand.htmlHeader()
+	reportStr += "<main>\n"
+	reportStr += "<h1>" + html.EscapeString(title) + "</h1>\n"
+	reportStr += "<p><strong>Posture Score:</strong> " + scoreOrNoData(f.percent(onePager.Score, 1), onePager.NoData) + " (" + html.EscapeString(onePager.Health) + ")</p>\n"
+
+	if len(onePager.Trends) > 0 {
+		reportStr += htmlTable("Headline KPI trends", "KPI", "Trend", "Value")
+		for _, trend := range onePager.Trends {
+			reportStr += "<tr>" + htmlRowHeader(trend.Name) + "<td>" + htmlTrend(trend.Trend) + "</td><td>" + f.value(trend.Value) + " " + html.EscapeString(trend.Unit) + "</td></tr>\n"
+		}
+		reportStr += htmlTableEnd
+	}
+
+	reportStr += "<h2>Top Risks</h2>\n<ol>\n"
+	for _, risk := range onePager.Risks {
+		reportStr += "<li>" + html.EscapeString(risk) + "</li>\n"
+	}
+	reportStr += "</ol>\n<h2>Top Wins</h2>\n<ol>\n"
+	for _, win := range onePager.Wins {
+		reportStr += "<li>" + html.EscapeString(win) + "</li>\n"
+	}
+	reportStr += "</ol>\n"
+	reportStr += "</main>\n"
+	reportStr += report.Brand.htmlFooter()
+	reportStr += classificationHTML(report.Classification)
+	reportStr += "</body>\n</html>\n"
+
+	return reportStr, nil
+}
+
+// GenerateOnePagerPDF generates the one-pager as a single-page A4 PDF.
+func GenerateOnePagerPDF(report *Report) ([]byte, error) {
+	onePager, title, err := limitedOnePager(report)
+	if err != nil {
+		return nil, err
+	}
+	f := report.formatter()
+	const left = 50.0
+	y := pdfPageHeight - 70
+	page, text, heading := newReportPDFPage(report, left)
+
+	page.fill(heading)
+	page.text(left, y, 18, true, title)
+	y -= 40
+	page.text(left, y, 14, true, "Posture Score: "+scoreOrNoData(f.percent(onePager.Score, 1), onePager.NoData)+" ("+onePager.Health+")")
+	y -= 45
+
+	// One trend arrow per headline KPI
+	for _, trend := range onePager.Trends {
+		switch trend.Trend {
+		case "IMPROVING":
+			page.color(0.15, 0.6, 0.25)
+			page.polygon(left, y, left+14, y, left+7, y+12)
+		case "DECLINING":
+			page.color(0.8, 0.15, 0.15)
+			page.polygon(left, y+12, left+14, y+12, left+7, y)
+		default:
+			page.color(0.45, 0.45, 0.45)
+			page.polygon(left, y, left, y+12, left+14, y+6)
+		}
+		page.fill(text)
+		page.text(left+24, y+1, 11, false, fmt.Sprintf("%s: %s %s, %s", trend.Name, f.value(trend.Value), trend.Unit, strings.ToLower(trend.Trend)))
+		y -= 22
+	}
+	y -= 20
+
+	for _, section := range []struct {
+		heading string
+		items   []string
+	}{{"Top Risks", onePager.Risks}, {"Top Wins", onePager.Wins}} {
+		page.fill(heading)
+		page.text(left, y, 14, true, section.heading)
+		y -= 22
+		page.fill(text)
+		for i, item := range section.items {
+			page.text(left, y, 11, false, fmt.Sprintf("%d. %s", i+1, item))
+			y -= 18
+		}
+		y -= 20
+	}
+
+	if y < 50 {
+		return nil, fmt.Errorf("one-pager overflows the page")
+	}
+	return page.bytes(), nil
+}
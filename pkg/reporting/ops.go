@@ -0,0 +1,266 @@
+package reporting
+
+import (
+	"errors"
+	"html"
+	"time"
+)
+
+// ErrNoOps is returned when a report carries no operations data.
+var ErrNoOps = errors.New("report has no operations data")
+
+// OpsData represents the monthly operations report. Month is any time in
+// the reported month.
+type OpsData struct {
+	Month     time.Time
+	Incidents []IncidentData
+	Alerts    AlertStatsData
+	Changes   []string
+	Movements []MovementData
+}
+
+// IncidentData represents an incident on the timeline. ContainedAt and
+// ResolvedAt are zero until reached.
+type IncidentData struct {
+	ID          string
+	Title       string
+	Severity    string
+	Status      string
+	DetectedAt  time.Time
+	ContainedAt time.Time
+	ResolvedAt  time.Time
+}
+
+// AlertStatsData represents alert statistics for reporting.
+type AlertStatsData struct {
+	Total        int
+	Acknowledged int
+	Resolved     int
+	Escalated    int
+	// MeanTimeToAcknowledge is in hours.
+	MeanTimeToAcknowledge float64
+	BySeverity            []CountData
+	BySource              []CountData
+}
+
+// CountData represents a labelled count.
+type CountData struct {
+	Label string
+	Count int
+}
+
+// MovementData represents how a KPI moved over the period.
+type MovementData struct {
+	Name  string
+	Unit  string
+	Start float64
+	End   float64
+	Delta float64
+	Trend string
+}
+
+// incidentProgress describes how far an incident got, e.g.
+// "contained +2.0h, resolved +6.5h".
+func incidentProgress(f formatter, incident IncidentData) string {
+	if incident.ContainedAt.IsZero() && incident.ResolvedAt.IsZero() {
+		return "open"
+	}
+	var progress string
+	if !incident.ContainedAt.IsZero() {
+		progress = "contained " + f.signed(incident.ContainedAt.Sub(incident.DetectedAt).Hours(), 1) + "h"
+	}
+	if !incident.ResolvedAt.IsZero() {
+		if progress != "" {
+			progress += ", "
+		}
+		progress += "resolved " + f.signed(incident.ResolvedAt.Sub(incident.DetectedAt).Hours(), 1) + "h"
+	}
+	return progress
+}
+
+// formatCounts formats counts as "high 3, low 1".
+func formatCounts(f formatter, counts []CountData) string {
+	var s string
+	for i, count := range counts {
+		if i > 0 {
+			s += ", "
+		}
+		s += count.Label + " " + f.integer(count.Count)
+	}
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// GenerateOpsReport generates the monthly operations report as text.
+func GenerateOpsReport(report *Report) (string, error) {
+	ops := report.Ops
+	if ops == nil {
+		return "", ErrNoOps
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr += classificationText(report.Classification)
+	reportStr += "=== Security Operations Report: " + f.month(ops.Month) + " ===\n\n"
+	reportStr += "Report ID: " + report.ID + "\n\n"
+
+	reportStr += "Incident Timeline\n"
+	reportStr += "=================\n\n"
+	if len(ops.Incidents) == 0 {
+		reportStr += "No incidents detected.\n"
+	}
+	for _, incident := range ops.Incidents {
+		reportStr += "  " + f.shortDateTime(incident.DetectedAt) + "  " + incident.ID + " [" + incident.Severity + "] " + incident.Title + " (" + incidentProgress(f, incident) + ")\n"
+	}
+	reportStr += "\n"
+
+	reportStr += "Alert Statistics\n"
+	reportStr += "================\n\n"
+	reportStr += "Total Alerts: " + f.integer(ops.Alerts.Total) + "\n"
+	reportStr += "Acknowledged: " + f.integer(ops.Alerts.Acknowledged) + "\n"
+	reportStr += "Resolved: " + f.integer(ops.Alerts.Resolved) + "\n"
+	reportStr += "Escalated to Incidents: " + f.integer(ops.Alerts.Escalated) + "\n"
+	reportStr += "Mean Time to Acknowledge: " + f.number(ops.Alerts.MeanTimeToAcknowledge, 1) + " hours" + "\n"
+	reportStr += "By Severity: " + formatCounts(f, ops.Alerts.BySeverity) + "\n"
+	reportStr += "By Source: " + formatCounts(f, ops.Alerts.BySource) + "\n\n"
+
+	reportStr += "Changes\n"
+	reportStr += "=======\n\n"
+	if len(ops.Changes) == 0 {
+		reportStr += "No KPI changes.\n"
+	}
+	for _, change := range ops.Changes {
+		reportStr += "  • " + change + "\n"
+	}
+	reportStr += "\n"
+
+	reportStr += "KPI Movements\n"
+	reportStr += "=============\n\n"
+	for _, movement := range ops.Movements {
+		reportStr += "  " + trendArrow(movement.Trend) + " " + movement.Name + ": " + f.number(movement.Start, 1) + " -> " + f.number(movement.End, 1) + " " + movement.Unit + " (" + f.signed(movement.Delta, 1) + ")\n"
+	}
+
+	return reportStr, nil
+}
+
+// GenerateOpsMarkdown generates the monthly operations report in Markdown.
+func GenerateOpsMarkdown(report *Report) (string, error) {
+	ops := report.Ops
+	if ops == nil {
+		return "", ErrNoOps
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr += classificationMarkdown(report.Classification)
+	reportStr += "# Security Operations Report: " + f.month(ops.Month) + "\n\n"
+	reportStr += "**Report ID:** " + report.ID + "\n\n"
+
+	reportStr += "## Incident Timeline\n\n"
+	if len(ops.Incidents) == 0 {
+		reportStr += "No incidents detected.\n\n"
+	} else {
+		reportStr += "| Detected | Incident | Severity | Title | Progress |\n"
+		reportStr += "|----------|----------|----------|-------|----------|\n"
+		for _, incident := range ops.Incidents {
+			reportStr += "| " + f.shortDateTime(incident.DetectedAt) + " | " + incident.ID + " | " + incident.Severity + " | " + incident.Title + " | " + incidentProgress(f, incident) + " |\n"
+		}
+		reportStr += "\n"
+	}
+
+	reportStr += "## Alert Statistics\n\n"
+	reportStr += "| Metric | Value |\n"
+	reportStr += "|--------|-------|\n"
+	reportStr += "| Total Alerts | " + f.integer(ops.Alerts.Total) + " |\n"
+	reportStr += "| Acknowledged | " + f.integer(ops.Alerts.Acknowledged) + " |\n"
+	reportStr += "| Resolved | " + f.integer(ops.Alerts.Resolved) + " |\n"
+	reportStr += "| Escalated to Incidents | " + f.integer(ops.Alerts.Escalated) + " |\n"
+	reportStr += "| Mean Time to Acknowledge | " + f.number(ops.Alerts.MeanTimeToAcknowledge, 1) + " hours" + " |\n"
+	reportStr += "| By Severity | " + formatCounts(f, ops.Alerts.BySeverity) + " |\n"
+	reportStr += "| By Source | " + formatCounts(f, ops.Alerts.BySource) + " |\n\n"
+
+	reportStr += "## Changes\n\n"
+	if len(ops.Changes) == 0 {
+		reportStr += "No KPI changes.\n"
+	}
+	for _, change := range ops.Changes {
+		reportStr += "- " + change + "\n"
+	}
+	reportStr += "\n"
+
+	reportStr += "## KPI Movements\n\n"
+	reportStr += "| KPI | Start | End | Change |\n"
+	reportStr += "|-----|-------|-----|--------|\n"
+	for _, movement := range ops.Movements {
+		reportStr += "| " + movement.Name + " | " + f.number(movement.Start, 1) + " " + movement.Unit + " | " + f.number(movement.End, 1) + " " + movement.Unit + " | " + trendArrow(movement.Trend) + " " + f.signed(movement.Delta, 1) + " |\n"
+	}
+
+	return reportStr, nil
+}
+
+// GenerateOpsHTML generates the monthly operations report in HTML.
+func GenerateOpsHTML(report *Report) (string, error) {
+	ops := report.Ops
+	if ops == nil {
+		return "", ErrNoOps
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr = htmlDocument(report.Locale, "Security Operations Report - "+f.month(ops.Month))
+	reportStr += report.Brand.htmlStyle()
+	reportStr += "</head>\n<body>\n"
+	reportStr += classificationHTML(report.Classification)
+	reportStr += report.Brand.htmlHeader()
+	reportStr += "<main>\n"
+	reportStr += "<h1>Security Operations Report: " + html.EscapeString(f.month(ops.Month)) + "</h1>\n"
+	reportStr += "<p><strong>Report ID:</strong> " + html.EscapeString(report.ID) + "</p>\n"
+
+	reportStr += "<h2>Incident Timeline</h2>\n"
+	if len(ops.Incidents) == 0 {
+		reportStr += "<p>No incidents detected.</p>\n"
+	} else {
+		reportStr += "<ol>\n"
+		for _, incident := range ops.Incidents {
+			reportStr += "<li><time datetime=\"" + incident.DetectedAt.Format(time.RFC3339) + "\">" + f.shortDateTime(incident.DetectedAt) + "</time> " + html.EscapeString(incident.ID) + " [" + html.EscapeString(incident.Severity) + "] " + html.EscapeString(incident.Title) + " (" + html.EscapeString(incidentProgress(f, incident)) + ")</li>\n"
+		}
+		reportStr += "</ol>\n"
+	}
+
+	reportStr += "<h2>Alert Statistics</h2>\n"
+	reportStr += htmlTable("Alert statistics", "Metric", "Value")
+	reportStr += "<tr>" + htmlRowHeader("Total Alerts") + "<td>" + f.integer(ops.Alerts.Total) + "</td></tr>\n"
+	reportStr += "<tr>" + htmlRowHeader("Acknowledged") + "<td>" + f.integer(ops.Alerts.Acknowledged) + "</td></tr>\n"
+	reportStr += "<tr>" + htmlRowHeader("Resolved") + "<td>" + f.integer(ops.Alerts.Resolved) + "</td></tr>\n"
+	reportStr += "<tr>" + htmlRowHeader("Escalated to Incidents") + "<td>" + f.integer(ops.Alerts.Escalated) + "</td></tr>\n"
+	reportStr += "<tr>" + htmlRowHeader("Mean Time to Acknowledge") + "<td>" + f.number(ops.Alerts.MeanTimeToAcknowledge, 1) + " hours" + "</td></tr>\n"
+	reportStr += "<tr>" + htmlRowHeader("By Severity") + "<td>" + html.EscapeString(formatCounts(f, ops.Alerts.BySeverity)) + "</td></tr>\n"
+	reportStr += "<tr>" + htmlRowHeader("By Source") + "<td>" + html.EscapeString(formatCounts(f, ops.Alerts.BySource)) + "</td></tr>\n"
+	reportStr += htmlTableEnd
+
+	reportStr += "<h2>Changes</h2>\n"
+	if len(ops.Changes) == 0 {
+		reportStr += "<p>No KPI changes.</p>\n"
+	} else {
+		reportStr += "<ul>\n"
+		for _, change := range ops.Changes {
+			reportStr += "<li>" + html.EscapeString(change) + "</li>\n"
+		}
+		reportStr += "</ul>\n"
+	}
+
+	reportStr += "<h2>KPI Movements</h2>\n"
+	reportStr += htmlTable("KPI movements", "KPI", "Start", "End", "Change")
+	for _, movement := range ops.Movements {
+		reportStr += "<tr>" + htmlRowHeader(movement.Name) + "<td>" + f.number(movement.Start, 1) + " " + html.EscapeString(movement.Unit) + "</td><td>" + f.number(movement.End, 1) + " " + html.EscapeString(movement.Unit) + "</td><td>" + htmlTrendIcon(movement.Trend) + " " + f.signed(movement.Delta, 1) + "</td></tr>\n"
+	}
+	reportStr += htmlTableEnd
+	reportStr += "</main>\n"
+	reportStr += report.Brand.htmlFooter()
+	reportStr += classificationHTML(report.Classification)
+	reportStr += "</body>\n</html>\n"
+
+	return reportStr, nil
+}
@@ -0,0 +1,129 @@
+package reporting
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// opsReport returns a report of an October with two incidents.
+func opsReport() *Report {
+	detected := time.Date(2026, 10, 3, 8, 15, 0, 0, time.UTC)
+	return &Report{
+		ID:       "rpt-20261101090000",
+		Location: time.UTC,
+		Ops: &OpsData{
+			Month: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+			Incidents: []IncidentData{
+				{ID: "INC-1", Title: "Phishing <campaign>", Severity: "high", Status: "RESOLVED", DetectedAt: detected, ContainedAt: detected.Add(2 * time.Hour), ResolvedAt: detected.Add(6*time.Hour + 30*time.Minute)},
+				{ID: "INC-2", Title: "Lost laptop", Severity: "low", Status: "OPEN", DetectedAt: time.Date(2026, 10, 20, 14, 0, 0, 0, time.UTC)},
+			},
+			Alerts: AlertStatsData{
+				Total: 1200, Acknowledged: 1180, Resolved: 1150, Escalated: 2, MeanTimeToAcknowledge: 0.75,
+				BySeverity: []CountData{{"low", 900}, {"high", 300}},
+				BySource:   []CountData{{"siem", 1000}, {"edr", 200}},
+			},
+			Changes: []string{"Security Coverage now meets its target", "Mean Time to Respond (MTTR) fell below its target"},
+			Movements: []MovementData{
+				{Name: "Security Coverage", Unit: "%", Start: 88, End: 96, Delta: 8, Trend: "IMPROVING"},
+				{Name: "Mean Time to Respond (MTTR)", Unit: "hours", Start: 4, End: 6.5, Delta: 2.5, Trend: "DECLINING"},
+				{Name: "Detection Rate", Unit: "%", Start: 92, End: 92, Delta: 0, Trend: "STABLE"},
+			},
+		},
+	}
+}
+
+func TestGenerateOpsReport(t *testing.T) {
+	text, err := GenerateOpsReport(opsReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `=== Security Operations Report: October 2026 ===
+
+Report ID: rpt-20261101090000
+
+Incident Timeline
+=================
+
+  2026-10-03 08:15 UTC  INC-1 [high] Phishing <campaign> (contained +2.0h, resolved +6.5h)
+  2026-10-20 14:00 UTC  INC-2 [low] Lost laptop (open)
+
+Alert Statistics
+================
+
+Total Alerts: 1200
+Acknowledged: 1180
+Resolved: 1150
+Escalated to Incidents: 2
+Mean Time to Acknowledge: 0.8 hours
+By Severity: low 900, high 300
+By Source: siem 1000, edr 200
+
+Changes
+=======
+
+  • Security Coverage now meets its target
+  • Mean Time to Respond (MTTR) fell below its target
+
+KPI Movements
+=============
+
+  ↑ Security Coverage: 88.0 -> 96.0 % (+8.0)
+  ↓ Mean Time to Respond (MTTR): 4.0 -> 6.5 hours (+2.5)
+  → Detection Rate: 92.0 -> 92.0 % (+0.0)
+`
+	if text != want {
+		t.Errorf("ops report:\n%s\nwant:\n%s", text, want)
+	}
+}
+
+func TestGenerateOpsSections(t *testing.T) {
+	report := opsReport()
+	markdown, err := GenerateOpsMarkdown(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Security Operations Report: October 2026\n",
+		"| 2026-10-03 08:15 UTC | INC-1 | high | Phishing <campaign> | contained +2.0h, resolved +6.5h |\n",
+		"| Escalated to Incidents | 2 |\n",
+		"- Mean Time to Respond (MTTR) fell below its target\n",
+		"| Mean Time to Respond (MTTR) | 4.0 hours | 6.5 hours | ↓ +2.5 |\n",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown ops report lacks %q:\n%s", want, markdown)
+		}
+	}
+
+	page, err := GenerateOpsHTML(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<time datetime="2026-10-03T08:15:00Z">2026-10-03 08:15 UTC</time> INC-1 [high] Phishing &lt;campaign&gt; (contained +2.0h, resolved +6.5h)`,
+		"<li>Security Coverage now meets its target</li>",
+		"<td>siem 1000, edr 200</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("HTML ops report lacks %q:\n%s", want, page)
+		}
+	}
+
+	// A quiet month says so in each section
+	report.Ops = &OpsData{Month: report.Ops.Month}
+	quiet, err := GenerateOpsMarkdown(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"No incidents detected.\n", "| By Source | none |\n", "No KPI changes.\n"} {
+		if !strings.Contains(quiet, want) {
+			t.Errorf("quiet ops report lacks %q:\n%s", want, quiet)
+		}
+	}
+
+	report.Ops = nil
+	if _, err := GenerateOpsReport(report); !errors.Is(err, ErrNoOps) {
+		t.Errorf("report without operations data: %v", err)
+	}
+}
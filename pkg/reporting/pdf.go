@@ -0,0 +1,199 @@
+package reporting
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// A4 page size in PDF points.
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+)
+
+// pdfPage builds the content stream of a single-page PDF using the
+// standard Helvetica fonts, which need no embedding.
+type pdfPage struct {
+	content bytes.Buffer
+	image   *pdfImage
+}
+
+// pdfImage is an RGB image XObject.
+type pdfImage struct {
+	width, height int
+	// data holds the zlib-compressed RGB samples.
+	data []byte
+}
+
+// newReportPDFPage returns a page with the report's theme background,
+// classification and banner markings, footer and logo drawn, and the text
+// and heading colors of its brand. Text starts at left.
+func newReportPDFPage(report *Report, left float64) (page *pdfPage, text, heading [3]float64) {
+	page = &pdfPage{}
+	brand := report.Brand
+	background, text, heading := brand.pdfColors()
+	if brand != nil && brand.Theme == ThemeDark {
+		page.fill(background)
+		page.rect(0, 0, pdfPageWidth, pdfPageHeight)
+	}
+
+	// Classification and banner markings share strips at the top and bottom
+	var markings []string
+	marking := heading
+	if report.Classification != "" {
+		markings = append(markings, report.Classification.Label())
+		marking = hexRGB(report.Classification.color())
+	}
+	if brand != nil && brand.Banner != "" {
+		markings = append(markings, brand.Banner)
+	}
+	if len(markings) > 0 {
+		page.fill(marking)
+		page.rect(0, pdfPageHeight-22, pdfPageWidth, 22)
+		page.rect(0, 0, pdfPageWidth, 22)
+		page.color(1, 1, 1)
+		page.text(left, pdfPageHeight-16, 10, true, strings.Join(markings, " - "))
+		page.text(left, 7, 10, true, strings.Join(markings, " - "))
+	}
+
+	if brand != nil {
+		if brand.Footer != "" {
+			page.fill(text)
+			page.text(left, 32, 9, false, brand.Footer)
+		}
+		if brand.logoImage != nil {
+			// Fit the logo into a 150x40 box in the top right corner
+			bounds := brand.logoImage.Bounds()
+			h := 40.0
+			w := h * float64(bounds.Dx()) / float64(bounds.Dy())
+			if w > 150 {
+				w, h = 150, 150*float64(bounds.Dy())/float64(bounds.Dx())
+			}
+			page.drawImage(brand.logoImage, pdfPageWidth-left-w, pdfPageHeight-80, w, h, background)
+		}
+	}
+	return page, text, heading
+}
+
+// text draws s with its baseline at (x, y), measured from the bottom left.
+func (p *pdfPage) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(s))
+}
+
+// color sets the fill color for subsequent text and shapes.
+func (p *pdfPage) color(r, g, b float64) {
+	fmt.Fprintf(&p.content, "%.2f %.2f %.2f rg\n", r, g, b)
+}
+
+// fill sets the fill color from RGB components.
+func (p *pdfPage) fill(c [3]float64) {
+	p.color(c[0], c[1], c[2])
+}
+
+// polygon fills the polygon through points, given as x, y pairs.
+func (p *pdfPage) polygon(points ...float64) {
+	for i := 0; i+1 < len(points); i += 2 {
+		op := "l"
+		if i == 0 {
+			op = "m"
+		}
+		fmt.Fprintf(&p.content, "%.1f %.1f %s ", points[i], points[i+1], op)
+	}
+	p.content.WriteString("f\n")
+}
+
+// rect fills the rectangle with its bottom left at (x, y).
+func (p *pdfPage) rect(x, y, w, h float64) {
+	p.polygon(x, y, x+w, y, x+w, y+h, x, y+h)
+}
+
+// drawImage draws img into the w x h box with its bottom left at (x, y).
+// Transparent pixels are blended onto the background color. A page holds
+// a single image.
+func (p *pdfPage) drawImage(img image.Image, x, y, w, h float64, background [3]float64) {
+	bounds := img.Bounds()
+	samples := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			c := color.NRGBAModel.Convert(img.At(px, py)).(color.NRGBA)
+			alpha := float64(c.A) / 255
+			for i, v := range []uint8{c.R, c.G, c.B} {
+				samples = append(samples, uint8(float64(v)*alpha+background[i]*255*(1-alpha)+0.5))
+			}
+		}
+	}
+	var data bytes.Buffer
+	zw := zlib.NewWriter(&data)
+	zw.Write(samples)
+	zw.Close()
+
+	p.image = &pdfImage{width: bounds.Dx(), height: bounds.Dy(), data: data.Bytes()}
+	fmt.Fprintf(&p.content, "q %.1f 0 0 %.1f %.1f %.1f cm /Im1 Do Q\n", w, h, x, y)
+}
+
+// bytes returns the complete PDF document.
+func (p *pdfPage) bytes() []byte {
+	resources := "/Font << /F1 4 0 R /F2 5 0 R >>"
+	if p.image != nil {
+		resources += " /XObject << /Im1 7 0 R >>"
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << %s >> /Contents 6 0 R >>", pdfPageWidth, pdfPageHeight, resources),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+	}
+	if p.image != nil {
+		objects = append(objects, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			p.image.width, p.image.height, len(p.image.data), p.image.data))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape escapes s for a PDF string literal, replacing characters
+// outside Latin-1 since the standard fonts cannot render them.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '•':
+			b.WriteString("\\225")
+		case r == '’':
+			b.WriteString("\\222")
+		case r < 32 || r > 255:
+			b.WriteByte('?')
+		case r > 126:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
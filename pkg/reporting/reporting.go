@@ -3,7 +3,11 @@ package reporting
 
 import (
 	"fmt"
+	"html"
+	"strings"
 	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
 )
 
 // ReportFormat represents a report format.
@@ -29,6 +33,33 @@ type Report struct {
 	Executive     ExecutiveSummary
 	Technical     TechnicalSummary
 	Recommendations []string
+	ZeroTrust     *ZeroTrustData
+	Categories    []CategoryData
+	OnePager      *OnePagerData
+	Ops           *OpsData
+	Gaps          *GapData
+	Targets       *TargetsData
+	Campaigns     []CampaignData
+	// Narrative is the approved narrative of the latest quarter; nil
+	// leaves it out.
+	Narrative     *NarrativeData
+	// Locale is the BCP 47 tag used to format numbers and dates; empty
+	// keeps the default formatting.
+	Locale        string
+	// Location is the time zone timestamps are shown in, with the zone
+	// name; nil shows them in the server's local zone.
+	Location      *time.Location
+	// Brand styles HTML and PDF output; nil renders unbranded.
+	Brand         *Brand
+	// Classification is marked on every format; empty leaves the report
+	// unlabelled.
+	Classification Classification
+	// ChartImages maps KPI keys to PNG chart paths, relative to the
+	// Markdown report, that are embedded as images.
+	ChartImages   map[string]string
+	// localZone replaces the local zone of a report without a Location
+	// restored from a snapshot taken on another server.
+	localZone     *time.Location
 }
 
 // MetricData represents metric data for reporting.
@@ -40,6 +71,23 @@ type MetricData struct {
 	Status   string
 	Trend    string
 	Timestamp time.Time
+	Evidence []EvidenceData
+}
+
+// EvidenceData references an artifact backing a metric value: a hash,
+// url or path.
+type EvidenceData struct {
+	Kind string
+	Ref  string
+	Note string
+}
+
+// formatEvidence formats evidence as "kind ref (note)".
+func formatEvidence(e EvidenceData) string {
+	if e.Note == "" {
+		return e.Kind + " " + e.Ref
+	}
+	return e.Kind + " " + e.Ref + " (" + e.Note + ")"
 }
 
 // KPIData represents KPI data for reporting.
@@ -52,6 +100,56 @@ type KPIData struct {
 	Trend      string
 	Unit       string
 	Category   string
+	Percentiles []PercentileData
+	Comparison *PeriodComparison
+	Direction  string
+	History    []float64
+	Bands      *TargetBands
+	RootCauses []RootCauseData
+}
+
+// RootCauseData represents a degraded upstream KPI that likely contributed
+// to a KPI's degradation.
+type RootCauseData struct {
+	Name    string
+	Path    []string
+	Reasons []string
+}
+
+// formatRootCause formats a root-cause hint as "Name: reasons (via a -> b)".
+func formatRootCause(rc RootCauseData) string {
+	return rc.Name + ": " + strings.Join(rc.Reasons, "; ") + " (via " + strings.Join(rc.Path, " -> ") + ")"
+}
+
+// PeriodComparison compares a KPI over the current and previous period.
+// Without PreviousPeriod the periods are rolling windows of Period, e.g.
+// "7 days"; with it they are named periods, e.g. the fiscal quarter
+// FY2027-Q2 to date against FY2027-Q1.
+type PeriodComparison struct {
+	Period         string
+	PreviousPeriod string
+	Current        float64
+	Previous       float64
+	Delta          float64
+	DeltaPercent   float64
+}
+
+// PercentileData represents a labelled KPI percentile for reporting.
+type PercentileData struct {
+	Label string
+	Value float64
+}
+
+// formatPercentiles formats percentiles as "P50 1.0, P90 2.0 unit".
+func formatPercentiles(f formatter, percentiles []PercentileData, unit string) string {
+	var s string
+	for i, p := range percentiles {
+		if i > 0 {
+			s += ", "
+		}
+		s += p.Label + " " + f.number(p.Value, 1)
+	}
+	return s + " " + unit
 }
 
 // ExecutiveSummary provides executive-level summary.
@@ -59,6 +157,10 @@ type ExecutiveSummary struct {
 	OverallHealth      string
 	ComplianceScore    float64
 	RiskScore          float64
+	// NoComplianceData and NoRiskData mark scores without data to compute
+	// them from, which are shown as n/a rather than 0.
+	NoComplianceData   bool
+	NoRiskData         bool
 	TopConcerns        []string
 	TopAchievements    []string
 	Recommendations    []string
@@ -79,31 +181,40 @@ type TechnicalSummary struct {
 
 // ReportGenerator generates security metrics reports.
 type ReportGenerator struct {
-	reports []Report
+	reports []*Report
+	clock   clock.Clock
 }
 
 // NewReportGenerator creates a new report generator.
 func NewReportGenerator() *ReportGenerator {
 	return &ReportGenerator{
-		reports: make([]Report, 0),
+		reports: make([]*Report, 0),
+		clock:   clock.System,
 	}
 }
 
+// SetClock sets the time source for report IDs and creation times; the
+// system clock is used by default.
+func (g *ReportGenerator) SetClock(clk clock.Clock) {
+	g.clock = clk
+}
+
 // GenerateReport generates a security metrics report.
 func (g *ReportGenerator) GenerateReport(title, description string, format ReportFormat) *Report {
+	now := g.clock.Now()
 	report := &Report{
-		ID:          "rpt-" + time.Now().Format("20060102150405"),
+		ID:          "rpt-" + now.Format("20060102150405"),
 		Title:       title,
 		Description: description,
 		Format:      format,
-		CreatedAt:   time.Now(),
+		CreatedAt:   now,
 		Metrics:     make([]MetricData, 0),
 		KPIS:        make([]KPIData, 0),
 		Executive:   ExecutiveSummary{},
 		Technical:   TechnicalSummary{},
 	}
 
-	g.reports = append(g.reports, *report)
+	g.reports = append(g.reports, report)
 	return report
 }
 
@@ -151,7 +262,7 @@ func (g *ReportGenerator) SetTechnicalSummary(reportID string, summary Technical
 func (g *ReportGenerator) GetReport(reportID string) *Report {
 	for i := range g.reports {
 		if g.reports[i].ID == reportID {
-			return &g.reports[i]
+			return g.reports[i]
 		}
 	}
 	return nil
@@ -159,24 +270,46 @@ func (g *ReportGenerator) GetReport(reportID string) *Report {
 
 // GetReports returns all reports.
 func (g *ReportGenerator) GetReports() []Report {
-	return g.reports
+	reports := make([]Report, 0, len(g.reports))
+	for _, report := range g.reports {
+		reports = append(reports, *report)
+	}
+	return reports
 }
 
 // GenerateExecutiveReport generates executive summary report.
 func GenerateExecutiveReport(report *Report) string {
 	var reportStr string
+	f := report.formatter()
 
+	reportStr += classificationText(report.Classification)
 	reportStr += "=== Executive Security Metrics Report ===\n\n"
 	reportStr += "Report ID: " + report.ID + "\n"
 	reportStr += "Title: " + report.Title + "\n"
-	reportStr += "Created: " + report.CreatedAt.Format("2006-01-02 15:04:05") + "\n\n"
+	reportStr += "Created: " + f.dateTime(report.CreatedAt) + "\n\n"
 
 	// Executive Summary
 	reportStr += "Executive Summary\n"
 	reportStr += "=================\n\n"
 	reportStr += "Overall Health: " + report.Executive.OverallHealth + "\n"
-	reportStr += "Compliance Score: " + fmt.Sprintf("%.1f%%", report.Executive.ComplianceScore) + "\n"
-	reportStr += "Risk Score: " + fmt.Sprintf("%.1f", report.Executive.RiskScore) + "\n\n"
+	reportStr += "Compliance Score: " + scoreOrNoData(f.percent(report.Executive.ComplianceScore, 1), report.Executive.NoComplianceData) + "\n"
+	reportStr += "Risk Score: " + scoreOrNoData(f.number(report.Executive.RiskScore, 1), report.Executive.NoRiskData) + "\n\n"
+
+	if report.Narrative != nil {
+		reportStr += formatNarrative(report.Narrative)
+	}
+
+	if report.ZeroTrust != nil {
+		reportStr += formatZeroTrust(f, report.ZeroTrust)
+	}
+
+	if len(report.Categories) > 0 {
+		reportStr += formatCategories(f, report.Categories)
+	}
+
+	if len(report.Campaigns) > 0 {
+		reportStr += formatCampaigns(f, report.Campaigns)
+	}
 
 	if len(report.Executive.TopConcerns) > 0 {
 		reportStr += "Top Concerns:\n"
@@ -215,31 +348,52 @@ func GenerateExecutiveReport(report *Report) string {
 // GenerateTechnicalReport generates technical detail report.
 func GenerateTechnicalReport(report *Report) string {
 	var reportStr string
+	f := report.formatter()
 
+	reportStr += classificationText(report.Classification)
 	reportStr += "=== Technical Security Metrics Report ===\n\n"
 	reportStr += "Report ID: " + report.ID + "\n\n"
 
 	// Technical Summary
 	reportStr += "Technical Summary\n"
 	reportStr += "=================\n\n"
-	reportStr += "Metrics Covered: " + fmt.Sprintf("%d", report.Technical.MetricsCovered) + "\n"
-	reportStr += "KPIs Tracked: " + fmt.Sprintf("%d", report.Technical.KPIsTracked) + "\n"
-	reportStr += "Active Alerts: " + fmt.Sprintf("%d", report.Technical.AlertsActive) + "\n"
-	reportStr += "Incidents (Last Month): " + fmt.Sprintf("%d", report.Technical.IncidentsLastMonth) + "\n"
-	reportStr += "Open Vulnerabilities: " + fmt.Sprintf("%d", report.Technical.VulnerabilitiesOpen) + "\n"
+	reportStr += "Metrics Covered: " + f.integer(report.Technical.MetricsCovered) + "\n"
+	reportStr += "KPIs Tracked: " + f.integer(report.Technical.KPIsTracked) + "\n"
+	reportStr += "Active Alerts: " + f.integer(report.Technical.AlertsActive) + "\n"
+	reportStr += "Incidents (Last Month): " + f.integer(report.Technical.IncidentsLastMonth) + "\n"
+	reportStr += "Open Vulnerabilities: " + f.integer(report.Technical.VulnerabilitiesOpen) + "\n"
 	reportStr += "Compliance Status: " + report.Technical.ComplianceStatus + "\n"
-	reportStr += "Detection Rate: " + fmt.Sprintf("%.1f%%", report.Technical.DetectionRate) + "\n"
-	reportStr += "Response Time: " + fmt.Sprintf("%.1f hours", report.Technical.ResponseTime) + "\n\n"
+	reportStr += "Detection Rate: " + f.percent(report.Technical.DetectionRate, 1) + "\n"
+	reportStr += "Response Time: " + f.number(report.Technical.ResponseTime, 1) + " hours" + "\n\n"
+
+	if report.ZeroTrust != nil {
+		reportStr += formatZeroTrust(f, report.ZeroTrust)
+	}
+
+	if len(report.Categories) > 0 {
+		reportStr += formatCategories(f, report.Categories)
+	}
+
+	if len(report.Campaigns) > 0 {
+		reportStr += formatCampaigns(f, report.Campaigns)
+	}
 
 	// Metrics
 	if len(report.Metrics) > 0 {
 		reportStr += "Security Metrics:\n"
 		for i, metric := range report.Metrics {
 			reportStr += "  [" + fmt.Sprintf("%d", i+1) + "] " + metric.Name + "\n"
-			reportStr += "      Value: " + fmt.Sprintf("%.1f", metric.Value) + " " + metric.Type + "\n"
-			reportStr += "      Target: " + fmt.Sprintf("%.1f", metric.Target) + " " + metric.Type + "\n"
+			reportStr += "      Value: " + f.number(metric.Value, 1) + " " + metric.Type + "\n"
+			reportStr += "      Target: " + f.number(metric.Target, 1) + " " + metric.Type + "\n"
 			reportStr += "      Status: " + metric.Status + "\n"
-			reportStr += "      Trend: " + metric.Trend + "\n\n"
+			reportStr += "      Trend: " + metric.Trend + "\n"
+			if len(metric.Evidence) > 0 {
+				reportStr += "      Evidence:\n"
+				for _, e := range metric.Evidence {
+					reportStr += "        - " + formatEvidence(e) + "\n"
+				}
+			}
+			reportStr += "\n"
 		}
 	}
 
@@ -248,11 +402,26 @@ func GenerateTechnicalReport(report *Report) string {
 		reportStr += "Key Performance Indicators:\n"
 		for i, kpi := range report.KPIS {
 			reportStr += "  [" + fmt.Sprintf("%d", i+1) + "] " + kpi.Name + "\n"
-			reportStr += "      Value: " + fmt.Sprintf("%.1f", kpi.Value) + " " + kpi.Unit + "\n"
-			reportStr += "      Target: " + fmt.Sprintf("%.1f", kpi.Target) + " " + kpi.Unit + "\n"
+			reportStr += "      Value: " + f.number(kpi.Value, 1) + " " + kpi.Unit + "\n"
+			if len(kpi.Percentiles) > 0 {
+				reportStr += "      Percentiles: " + formatPercentiles(f, kpi.Percentiles, kpi.Unit) + "\n"
+			}
+			if kpi.Comparison != nil && kpi.Comparison.PreviousPeriod != "" {
+				reportStr += "      " + kpi.Comparison.Period + " to date: " + f.number(kpi.Comparison.Current, 1) + " vs " + kpi.Comparison.PreviousPeriod + ": " + f.number(kpi.Comparison.Previous, 1) + " (" + f.signed(kpi.Comparison.Delta, 1) + ", " + f.signedPercent(kpi.Comparison.DeltaPercent, 1) + ")\n"
+			} else if kpi.Comparison != nil {
+				reportStr += "      Last " + kpi.Comparison.Period + ": " + f.number(kpi.Comparison.Current, 1) + " vs previous " + kpi.Comparison.Period + ": " + f.number(kpi.Comparison.Previous, 1) + " (" + f.signed(kpi.Comparison.Delta, 1) + ", " + f.signedPercent(kpi.Comparison.DeltaPercent, 1) + ")\n"
+			}
+			reportStr += "      Target: " + f.number(kpi.Target, 1) + " " + kpi.Unit + "\n"
 			reportStr += "      Status: " + kpi.Status + "\n"
 			reportStr += "      Trend: " + kpi.Trend + "\n"
-			reportStr += "      Category: " + kpi.Category + "\n\n"
+			reportStr += "      Category: " + kpi.Category + "\n"
+			if len(kpi.RootCauses) > 0 {
+				reportStr += "      Likely contributors:\n"
+				for _, rc := range kpi.RootCauses {
+					reportStr += "        - " + formatRootCause(rc) + "\n"
+				}
+			}
+			reportStr += "\n"
 		}
 	}
 
@@ -276,33 +445,111 @@ func GenerateReport(report *Report, format ReportFormat) string {
 // GenerateMarkdownReport generates Markdown format report.
 func GenerateMarkdownReport(report *Report) string {
 	var reportStr string
+	f := report.formatter()
 
+	reportStr += classificationMarkdown(report.Classification)
 	reportStr += "# Security Metrics Report\n\n"
 	reportStr += "**Report ID:** " + report.ID + "\n\n"
 	reportStr += "**Title:** " + report.Title + "\n"
-	reportStr += "**Created:** " + report.CreatedAt.Format("2006-01-02 15:04:05") + "\n\n"
+	reportStr += "**Created:** " + f.dateTime(report.CreatedAt) + "\n\n"
 
 	reportStr += "## Executive Summary\n\n"
 	reportStr += "| Metric | Value |\n"
 	reportStr += "|--------|-------|\n"
 	reportStr += "| Overall Health | " + report.Executive.OverallHealth + " |\n"
-	reportStr += "| Compliance Score | " + fmt.Sprintf("%.1f%%", report.Executive.ComplianceScore) + " |\n"
-	reportStr += "| Risk Score | " + fmt.Sprintf("%.1f", report.Executive.RiskScore) + " |\n\n"
+	reportStr += "| Compliance Score | " + scoreOrNoData(f.percent(report.Executive.ComplianceScore, 1), report.Executive.NoComplianceData) + " |\n"
+	reportStr += "| Risk Score | " + scoreOrNoData(f.number(report.Executive.RiskScore, 1), report.Executive.NoRiskData) + " |\n\n"
+
+	if report.Narrative != nil {
+		reportStr += formatNarrativeMarkdown(report.Narrative)
+	}
+
+	if report.ZeroTrust != nil {
+		reportStr += formatZeroTrustMarkdown(f, report.ZeroTrust)
+	}
+
+	if len(report.Categories) > 0 {
+		reportStr += formatCategoriesMarkdown(f, report.Categories)
+	}
 
+	if len(report.Campaigns) > 0 {
+		reportStr += formatCampaignsMarkdown(f, report.Campaigns)
+	}
+
+	if len(report.ChartImages) > 0 {
+		reportStr += formatChartImagesMarkdown(report)
+	}
+
+	return reportStr
+}
+
+// formatChartImagesMarkdown embeds the report's PNG KPI charts, with the
+// chart description as alt text.
+func formatChartImagesMarkdown(report *Report) string {
+	var reportStr string
+	reportStr += "## KPI Charts\n\n"
+	for _, kpi := range report.KPIS {
+		image, ok := report.ChartImages[kpi.Key]
+		if !ok {
+			continue
+		}
+		reportStr += "### " + kpi.Name + "\n\n"
+		reportStr += "![" + kpi.Name + ": " + chartDescription(kpi) + "](" + image + ")\n\n"
+	}
 	return reportStr
 }
 
 // GenerateHTMLReport generates HTML format report.
 func GenerateHTMLReport(report *Report) string {
 	var reportStr string
+	f := report.formatter()
 
-	reportStr = "<!DOCTYPE html>\n<html>\n<head>\n"
-	reportStr += "<title>Security Metrics Report - " + report.Title + "</title>\n"
+	reportStr = htmlDocument(report.Locale, "Security Metrics Report - "+report.Title)
+	reportStr += report.Brand.htmlStyle()
 	reportStr += "</head>\n<body>\n"
+	reportStr += classificationHTML(report.Classification)
+	reportStr += report.Brand.htmlHeader()
+	reportStr += "<main>\n"
 	reportStr += "<h1>Security Metrics Report</h1>\n"
 	reportStr += "<h2>" + report.Title + "</h2>\n"
 	reportStr += "<p><strong>Report ID:</strong> " + report.ID + "</p>\n"
-	reportStr += "<p><strong>Created:</strong> " + report.CreatedAt.Format("2006-01-02 15:04:05") + "</p>\n"
+	reportStr += "<p><strong>Created:</strong> " + f.dateTime(report.CreatedAt) + "</p>\n"
+
+	if report.Narrative != nil {
+		reportStr += formatNarrativeHTML(report.Narrative)
+	}
+
+	if report.ZeroTrust != nil {
+		reportStr += formatZeroTrustHTML(f, report.ZeroTrust)
+	}
+
+	if len(report.Categories) > 0 {
+		reportStr += formatCategoriesHTML(f, report.Categories)
+	}
+
+	if len(report.Campaigns) > 0 {
+		reportStr += formatCampaignsHTML(f, report.Campaigns)
+	}
+
+	if len(report.KPIS) > 0 {
+		reportStr += "<h2>Key Performance Indicators</h2>\n"
+		for _, kpi := range report.KPIS {
+			reportStr += "<h3>" + html.EscapeString(kpi.Name) + "</h3>\n"
+			reportStr += "<p>Value: " + f.number(kpi.Value, 1) + " " + html.EscapeString(kpi.Unit) + " &middot; Target: " + f.number(kpi.Target, 1) + " " + html.EscapeString(kpi.Unit) + " &middot; Status: " + html.EscapeString(kpi.Status) + "</p>\n"
+			if len(kpi.RootCauses) > 0 {
+				reportStr += "<p>Likely contributors:</p>\n<ul>\n"
+				for _, rc := range kpi.RootCauses {
+					reportStr += "<li>" + html.EscapeString(formatRootCause(rc)) + "</li>\n"
+				}
+				reportStr += "</ul>\n"
+			}
+			reportStr += RenderKPIChartSVG(kpi)
+		}
+	}
+
+	reportStr += "</main>\n"
+	reportStr += report.Brand.htmlFooter()
+	reportStr += classificationHTML(report.Classification)
 	reportStr += "</body>\n</html>\n"
 
 	return reportStr
@@ -312,6 +559,9 @@ func GenerateHTMLReport(report *Report) string {
 func GenerateCSVReport(report *Report) string {
 	var reportStr string
 
+	if report.Classification != "" {
+		reportStr += "Classification," + report.Classification.Label() + "\n"
+	}
 	reportStr += "Metric Name,Value,Target,Status,Trend\n"
 	for _, metric := range report.Metrics {
 		reportStr += metric.Name + "," + fmt.Sprintf("%.1f", metric.Value) + "," + fmt.Sprintf("%.1f", metric.Target) + "," + metric.Status + "," + metric.Trend + "\n"
@@ -321,6 +571,9 @@ func GenerateCSVReport(report *Report) string {
 }
 
 // GetCommonMetrics returns common security metrics.
+//
+// Deprecated: the values are fixed sample numbers, not measurements. Use
+// demo.Generate for sample data, or build reports from collected metrics.
 func GetCommonMetrics() []MetricData {
 	return []MetricData{
 		{
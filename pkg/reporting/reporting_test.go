@@ -0,0 +1,98 @@
+package reporting
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestGeneratorUsesClock(t *testing.T) {
+	now := time.Date(2026, 10, 1, 9, 30, 15, 0, time.UTC)
+	generator := NewReportGenerator()
+	generator.SetClock(clock.NewFake(now))
+
+	report := generator.GenerateReport("Weekly", "", FormatMarkdown)
+	if report.ID != "rpt-20261001093015" {
+		t.Errorf("ID = %q, want rpt-20261001093015", report.ID)
+	}
+	if !report.CreatedAt.Equal(now) {
+		t.Errorf("CreatedAt = %v, want %v", report.CreatedAt, now)
+	}
+}
+
+func TestScoresWithoutDataShownAsNotAvailable(t *testing.T) {
+	report := &Report{
+		Executive:  ExecutiveSummary{OverallHealth: "INSUFFICIENT_DATA", RiskScore: 35, NoComplianceData: true},
+		Categories: []CategoryData{{Name: "Prevention", KPIs: 1, Health: "INSUFFICIENT_DATA", NoData: true}},
+	}
+	text := GenerateExecutiveReport(report)
+	for _, line := range []string{"Compliance Score: n/a\n", "Risk Score: 35.0\n"} {
+		if !strings.Contains(text, line) {
+			t.Errorf("executive report lacks %q:\n%s", line, text)
+		}
+	}
+	markdown := GenerateMarkdownReport(report)
+	for _, row := range []string{"| Compliance Score | n/a |", "| Prevention | INSUFFICIENT_DATA | n/a | 0/1 |"} {
+		if !strings.Contains(markdown, row) {
+			t.Errorf("markdown report lacks %q:\n%s", row, markdown)
+		}
+	}
+}
+
+func TestExecutiveFormatsShowTheSameSummary(t *testing.T) {
+	report := &Report{
+		Title:     "Q3",
+		Executive: ExecutiveSummary{OverallHealth: "FAIR", ComplianceScore: 81.3, RiskScore: 42, TopConcerns: []string{"Patch latency"}, ActionItems: []string{"Resolve open incidents"}},
+	}
+	pdf, err := GenerateExecutivePDF(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, out := range map[string]string{
+		"text":     GenerateExecutiveReport(report),
+		"markdown": GenerateExecutiveMarkdown(report),
+		"html":     GenerateExecutiveHTML(report),
+		"pdf":      string(pdf),
+	} {
+		for _, want := range []string{"81.3%", "42.0", "Patch latency", "Resolve open incidents"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s executive report lacks %q", name, want)
+			}
+		}
+	}
+
+	for i := 0; i < 40; i++ {
+		report.Executive.Recommendations = append(report.Executive.Recommendations, "Review a category")
+	}
+	if _, err := GenerateExecutivePDF(report); err == nil {
+		t.Error("an overflowing executive PDF rendered")
+	}
+}
+
+func TestTimestampsInReportTimeZone(t *testing.T) {
+	berlin, err := ParseTimeZone("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := &Report{
+		CreatedAt: time.Date(2026, 10, 1, 9, 30, 15, 0, time.UTC),
+		Location:  berlin,
+		Campaigns: []CampaignData{{Name: "Patch Q4", Start: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), Deadline: time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)}},
+	}
+	markdown := GenerateExecutiveMarkdown(report)
+	// Timestamps move to the reader's zone; dates such as deadlines do not
+	for _, want := range []string{"**Created:** 2026-10-01 11:30:15 CEST", "2026-10-01 to 2026-10-31"} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("executive markdown lacks %q:\n%s", want, markdown)
+		}
+	}
+
+	if _, err := ParseTimeZone("Mars/Olympus_Mons"); err == nil {
+		t.Error("ParseTimeZone accepted an unknown zone")
+	}
+	if err := (Config{TimeZone: "Nowhere"}).Validate(); err == nil {
+		t.Error("Validate accepted an unknown time zone")
+	}
+}
@@ -0,0 +1,109 @@
+package reporting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// reportSnapshot is the JSON form of a Report. The time zone is kept by
+// IANA name, as a time.Location does not marshal.
+type reportSnapshot struct {
+	Report
+	Location string
+	// LocalZone is the local zone of the server that took the snapshot of
+	// a report without a Location, which shows timestamps in that zone.
+	LocalZone string `json:",omitempty"`
+}
+
+// MarshalReport encodes report with all the data it renders from, so
+// UnmarshalReport restores a report that renders byte-identically in every
+// format, also on a server in another time zone.
+func MarshalReport(report *Report) ([]byte, error) {
+	snapshot := reportSnapshot{Report: *report}
+	snapshot.Report.Location = nil
+	if report.Location != nil {
+		snapshot.Location = zoneName(report.Location)
+	} else if report.localZone != nil {
+		snapshot.LocalZone = zoneName(report.localZone)
+	} else {
+		snapshot.LocalZone = zoneName(time.Local)
+	}
+	return json.Marshal(snapshot)
+}
+
+// UnmarshalReport decodes a report encoded by MarshalReport, loading its
+// time zone and decoding its brand logo.
+func UnmarshalReport(data []byte) (*Report, error) {
+	var snapshot reportSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("parse report snapshot: %w", err)
+	}
+	report := snapshot.Report
+	var err error
+	if snapshot.Location != "" {
+		if report.Location, err = time.LoadLocation(snapshot.Location); err != nil {
+			return nil, fmt.Errorf("report snapshot time zone: %w", err)
+		}
+	}
+	if snapshot.LocalZone != "" {
+		if report.localZone, err = time.LoadLocation(snapshot.LocalZone); err != nil {
+			return nil, fmt.Errorf("report snapshot time zone: %w", err)
+		}
+	}
+	if brand := report.Brand; brand != nil && len(brand.Logo) > 0 {
+		img, _, err := image.Decode(bytes.NewReader(brand.Logo))
+		if err != nil {
+			return nil, fmt.Errorf("decode report snapshot logo: %w", err)
+		}
+		brand.logoImage = img
+	}
+	return &report, nil
+}
+
+// zoneName returns the IANA name of location that loads on any server.
+// The local zone, named "Local", is resolved to the zone in effect, read
+// from TZ or /etc/localtime. Zones that cannot be resolved to a name that
+// loads are recorded as UTC.
+func zoneName(location *time.Location) string {
+	name := location.String()
+	if location == time.Local {
+		name = localZoneName()
+	}
+	if name == "" {
+		return "UTC"
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return "UTC"
+	}
+	return name
+}
+
+// localZoneName returns the IANA name of the local zone as the time
+// package determines it, or "" if it has none.
+func localZoneName() string {
+	path := "/etc/localtime"
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		tz = strings.TrimPrefix(tz, ":")
+		if tz == "" {
+			return "UTC"
+		}
+		if !filepath.IsAbs(tz) {
+			return tz
+		}
+		path = tz
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ""
+	}
+	if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+		return name
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package reporting
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReportSnapshotRendersIdentically(t *testing.T) {
+	report := accessibilityReport()
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	report.Location = location
+	report.Locale = "de-DE"
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal(err)
+	}
+	report.Brand = &Brand{Logo: logo.Bytes(), LogoType: "image/png", PrimaryColor: DefaultPrimaryColor, Theme: ThemeDark, Footer: "ACME"}
+	report.Brand.logoImage, _, _ = image.Decode(bytes.NewReader(logo.Bytes()))
+
+	data, err := MarshalReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := UnmarshalReport(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Location.String() != "Europe/Berlin" || restored.Brand.logoImage == nil {
+		t.Fatalf("restored location %v, logo %v", restored.Location, restored.Brand.logoImage)
+	}
+
+	want, got := renderHTML(t, report), renderHTML(t, restored)
+	want["markdown"], got["markdown"] = GenerateMarkdownReport(report), GenerateMarkdownReport(restored)
+	want["executive text"], got["executive text"] = GenerateExecutiveReport(report), GenerateExecutiveReport(restored)
+	for _, r := range []struct {
+		name string
+		from *Report
+		into map[string]string
+	}{{"original", report, want}, {"restored", restored, got}} {
+		pdf, err := GenerateExecutivePDF(r.from)
+		if err != nil {
+			t.Fatalf("%s PDF: %v", r.name, err)
+		}
+		r.into["pdf"] = string(pdf)
+	}
+	for name := range want {
+		if got[name] != want[name] {
+			t.Errorf("%s differs after the snapshot round trip", name)
+		}
+	}
+}
+
+// setLocal makes name the local zone, like starting with TZ=name, until
+// the test ends. Like the time package, it names the zone "Local".
+func setLocal(t *testing.T, name string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("/usr/share/zoneinfo", name))
+	if err != nil {
+		t.Skip(err)
+	}
+	location, err := time.LoadLocationFromTZData("Local", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := time.Local
+	t.Cleanup(func() { time.Local = local })
+	time.Local = location
+	t.Setenv("TZ", name)
+}
+
+func TestReportSnapshotKeepsTheLocalZone(t *testing.T) {
+	setLocal(t, "America/New_York")
+	report := accessibilityReport()
+	report.Location = nil
+	explicit := accessibilityReport()
+	explicit.Location = time.Local
+
+	var snapshots [][]byte
+	var want []string
+	for _, r := range []*Report{report, explicit} {
+		data, err := MarshalReport(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(`"Local"`)) {
+			t.Errorf("snapshot names the zone Local: %s", data)
+		}
+		snapshots = append(snapshots, data)
+		want = append(want, GenerateMarkdownReport(r))
+	}
+
+	// Regenerated on a server in another zone
+	setLocal(t, "Asia/Tokyo")
+	for i, data := range snapshots {
+		restored, err := UnmarshalReport(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := GenerateMarkdownReport(restored); got != want[i] {
+			t.Errorf("report %d renders differently in another zone:\n%s\nwant:\n%s", i, got, want[i])
+		}
+		// and snapshotted again there
+		again, err := MarshalReport(restored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Errorf("report %d snapshot changed: %s, want %s", i, again, data)
+		}
+	}
+
+	if name := zoneName(time.FixedZone("custom", 3600)); name != "UTC" {
+		t.Errorf("zone name of an unnamed zone = %q, want UTC", name)
+	}
+}
@@ -0,0 +1,185 @@
+package reporting
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// ErrNoTargets is returned when a report carries no targets matrix.
+var ErrNoTargets = errors.New("report has no targets matrix")
+
+// Forecast states of a TargetData.
+const (
+	ForecastOnTarget   = "ON_TARGET"
+	ForecastConverging = "CONVERGING"
+	ForecastDiverging  = "DIVERGING"
+	// ForecastNoHistory marks KPIs with too little history to forecast.
+	ForecastNoHistory = "NO_HISTORY"
+)
+
+// TargetsData represents the targets matrix: every KPI against its
+// target, largest gap first.
+type TargetsData struct {
+	Rows []TargetData
+}
+
+// TargetData represents one KPI of the targets matrix.
+type TargetData struct {
+	Name   string
+	Owner  string
+	Value  float64
+	Target float64
+	Unit   string
+	// Gap is how far the value falls short of the target in the KPI's
+	// unit, zero when on target. Progress is the progress toward the
+	// target from 0 to 100.
+	Gap       float64
+	Progress  float64
+	Direction string
+	// Forecast is one of the Forecast states; TimeToTarget is the
+	// estimate of a CONVERGING KPI and TargetQuarter the fiscal quarter
+	// it falls in, e.g. FY2027-Q2.
+	Forecast      string
+	TimeToTarget  time.Duration
+	TargetQuarter string
+}
+
+// formatTimeToTarget describes the forecast of a KPI.
+func formatTimeToTarget(f formatter, row TargetData) string {
+	switch row.Forecast {
+	case ForecastOnTarget:
+		return "on target"
+	case ForecastDiverging:
+		return "not converging"
+	case ForecastNoHistory:
+		return "not enough history"
+	}
+	days := row.TimeToTarget.Hours() / 24
+	var estimate string
+	switch {
+	case days < 1:
+		estimate = "< 1 day"
+	case days < 60:
+		estimate = "~" + f.integer(int(days+0.5)) + " days"
+	case days < 730:
+		estimate = "~" + f.integer(int(days/30.4+0.5)) + " months"
+	default:
+		estimate = "~" + f.integer(int(days/365+0.5)) + " years"
+	}
+	if row.TargetQuarter != "" {
+		estimate += " (" + row.TargetQuarter + ")"
+	}
+	return estimate
+}
+
+// formatDirection describes a KPI direction, e.g. "lower is better".
+func formatDirection(direction string) string {
+	if direction == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(direction, "_", " ")
+}
+
+// withUnit appends unit to a formatted value.
+func withUnit(value, unit string) string {
+	if unit == "" {
+		return value
+	}
+	return value + " " + unit
+}
+
+// targetsNote explains the order and forecast of the targets matrix.
+const targetsNote = "KPIs furthest from target first. Time to target extrapolates the trend of the last 90 days."
+
+// GenerateTargetsReport generates the targets matrix as text.
+func GenerateTargetsReport(report *Report) (string, error) {
+	targets := report.Targets
+	if targets == nil {
+		return "", ErrNoTargets
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr += classificationText(report.Classification)
+	reportStr += "=== KPI Targets ===\n\n"
+	reportStr += "Report ID: " + report.ID + "\n"
+	reportStr += "Generated: " + f.dateTime(report.CreatedAt) + "\n\n"
+	reportStr += targetsNote + "\n\n"
+
+	if len(targets.Rows) == 0 {
+		reportStr += "No KPIs tracked.\n"
+		return reportStr, nil
+	}
+	for i, row := range targets.Rows {
+		reportStr += fmt.Sprintf("[%d] %s (owner %s)\n", i+1, row.Name, orNone(row.Owner))
+		reportStr += "    Value: " + withUnit(f.value(row.Value), row.Unit) + ", Target: " + withUnit(f.value(row.Target), row.Unit) + ", " + formatDirection(row.Direction) + "\n"
+		reportStr += "    Gap: " + withUnit(f.value(row.Gap), row.Unit) + " (" + f.percent(row.Progress, 1) + " progress), Time to Target: " + formatTimeToTarget(f, row) + "\n"
+	}
+
+	return reportStr, nil
+}
+
+// GenerateTargetsMarkdown generates the targets matrix in Markdown.
+func GenerateTargetsMarkdown(report *Report) (string, error) {
+	targets := report.Targets
+	if targets == nil {
+		return "", ErrNoTargets
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr += classificationMarkdown(report.Classification)
+	reportStr += "# KPI Targets\n\n"
+	reportStr += "**Report ID:** " + report.ID + "\n\n"
+	reportStr += "**Generated:** " + f.dateTime(report.CreatedAt) + "\n\n"
+	reportStr += targetsNote + "\n\n"
+
+	reportStr += "| KPI | Owner | Value | Target | Gap | Progress | Direction | Time to Target |\n"
+	reportStr += "|-----|-------|-------|--------|-----|----------|-----------|----------------|\n"
+	for _, row := range targets.Rows {
+		reportStr += "| " + row.Name + " | " + orNone(row.Owner) + " | " + withUnit(f.value(row.Value), row.Unit) + " | " + withUnit(f.value(row.Target), row.Unit) + " | " +
+			withUnit(f.value(row.Gap), row.Unit) + " | " + f.percent(row.Progress, 1) + " | " + formatDirection(row.Direction) + " | " + formatTimeToTarget(f, row) + " |\n"
+	}
+	reportStr += "\n"
+
+	return reportStr, nil
+}
+
+// GenerateTargetsHTML generates the targets matrix in HTML.
+func GenerateTargetsHTML(report *Report) (string, error) {
+	targets := report.Targets
+	if targets == nil {
+		return "", ErrNoTargets
+	}
+	f := report.formatter()
+	var reportStr string
+
+	reportStr = htmlDocument(report.Locale, "KPI Targets")
+	reportStr += report.Brand.htmlStyle()
+	reportStr += "</head>\n<body>\n"
+	reportStr += classificationHTML(report.Classification)
+	reportStr += report.Brand.htmlHeader()
+	reportStr += "<main>\n"
+	reportStr += "<h1>KPI Targets</h1>\n"
+	reportStr += "<p><strong>Report ID:</strong> " + html.EscapeString(report.ID) + "</p>\n"
+	reportStr += "<p><strong>Generated:</strong> " + f.dateTime(report.CreatedAt) + "</p>\n"
+	reportStr += "<p>" + targetsNote + "</p>\n"
+
+	reportStr += htmlTable("KPIs against their targets, largest gap first", "KPI", "Owner", "Value", "Target", "Gap", "Progress", "Direction", "Time to Target")
+	for _, row := range targets.Rows {
+		reportStr += "<tr>" + htmlRowHeader(row.Name) + "<td>" + html.EscapeString(orNone(row.Owner)) + "</td><td>" + html.EscapeString(withUnit(f.value(row.Value), row.Unit)) + "</td><td>" +
+			html.EscapeString(withUnit(f.value(row.Target), row.Unit)) + "</td><td>" + html.EscapeString(withUnit(f.value(row.Gap), row.Unit)) + "</td><td>" + f.percent(row.Progress, 1) + "</td><td>" +
+			formatDirection(row.Direction) + "</td><td>" + html.EscapeString(formatTimeToTarget(f, row)) + "</td></tr>\n"
+	}
+	reportStr += htmlTableEnd
+
+	reportStr += "</main>\n"
+	reportStr += report.Brand.htmlFooter()
+	reportStr += classificationHTML(report.Classification)
+	reportStr += "</body>\n</html>\n"
+
+	return reportStr, nil
+}
@@ -0,0 +1,99 @@
+package reporting
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// ZeroTrustData represents the zero trust adoption scorecard for reporting.
+type ZeroTrustData struct {
+	Score    float64
+	Maturity string
+	Pillars  []PillarData
+}
+
+// PillarData represents progress on one zero trust pillar. Pillars
+// without a collected KPI have Measured set to false.
+type PillarData struct {
+	Name     string
+	KPIName  string
+	Value    float64
+	Target   float64
+	Unit     string
+	Progress float64
+	Measured bool
+}
+
+// progressBar renders progress (0-100) as a fixed-width text bar.
+func progressBar(progress float64, width int) string {
+	filled := int(progress/100*float64(width) + 0.5)
+	if filled > width {
+		filled = width
+	}
+	if filled < 0 {
+		filled = 0
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+// formatZeroTrust formats the scorecard section of text reports.
+func formatZeroTrust(f formatter, zt *ZeroTrustData) string {
+	var reportStr string
+
+	reportStr += "Zero Trust Adoption\n"
+	reportStr += "===================\n\n"
+	reportStr += "Score: " + f.percent(zt.Score, 1) + " (" + zt.Maturity + ")\n"
+	for _, pillar := range zt.Pillars {
+		if !pillar.Measured {
+			reportStr += "  " + fmt.Sprintf("%-10s", pillar.Name) + " not measured\n"
+			continue
+		}
+		reportStr += "  " + fmt.Sprintf("%-10s", pillar.Name) + " " + progressBar(pillar.Progress, 20) + " " + fmt.Sprintf("%6s", f.percent(pillar.Progress, 1))
+		reportStr += "  " + pillar.KPIName + ": " + f.number(pillar.Value, 1) + " / " + f.number(pillar.Target, 1) + " " + pillar.Unit + "\n"
+	}
+	reportStr += "\n"
+
+	return reportStr
+}
+
+// formatZeroTrustMarkdown formats the scorecard section of Markdown reports.
+func formatZeroTrustMarkdown(f formatter, zt *ZeroTrustData) string {
+	var reportStr string
+
+	reportStr += "## Zero Trust Adoption\n\n"
+	reportStr += "**Score:** " + f.percent(zt.Score, 1) + " (" + zt.Maturity + ")\n\n"
+	reportStr += "| Pillar | KPI | Value | Target | Progress |\n"
+	reportStr += "|--------|-----|-------|--------|----------|\n"
+	for _, pillar := range zt.Pillars {
+		if !pillar.Measured {
+			reportStr += "| " + pillar.Name + " | " + pillar.KPIName + " | - | - | not measured |\n"
+			continue
+		}
+		reportStr += "| " + pillar.Name + " | " + pillar.KPIName + " | " + f.number(pillar.Value, 1) + " " + pillar.Unit + " | " + f.number(pillar.Target, 1) + " " + pillar.Unit + " | " + f.percent(pillar.Progress, 1) + " |\n"
+	}
+	reportStr += "\n"
+
+	return reportStr
+}
+
+// formatZeroTrustHTML formats the scorecard section of HTML reports.
+func formatZeroTrustHTML(f formatter, zt *ZeroTrustData) string {
+	var reportStr string
+
+	reportStr += "<h2>Zero Trust Adoption</h2>\n"
+	reportStr += "<p>Score: " + f.percent(zt.Score, 1) + " (" + html.EscapeString(zt.Maturity) + ")</p>\n"
+	reportStr += htmlTable("Zero Trust pillars", "Pillar", "KPI", "Progress")
+	for _, pillar := range zt.Pillars {
+		reportStr += "<tr>" + htmlRowHeader(pillar.Name) + "<td>" + html.EscapeString(pillar.KPIName) + "</td><td>"
+		if pillar.Measured {
+			reportStr += "<progress max=\"100\" aria-label=\"" + html.EscapeString(pillar.Name) + " progress\" value=\"" + fmt.Sprintf("%.1f", pillar.Progress) + "\">" + f.percent(pillar.Progress, 1) + "</progress> " + f.percent(pillar.Progress, 1)
+		} else {
+			reportStr += "not measured"
+		}
+		reportStr += "</td></tr>\n"
+	}
+	reportStr += htmlTableEnd
+
+	return reportStr
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/siem"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// alertSource is the source of the alerts secmetrics records for its own
+// notifications, as opposed to ingested alerts.
+const alertSource = "secmetrics"
+
+// alertMTTAWindow is how far back the alert_mtta KPI looks.
+const alertMTTAWindow = 30 * 24 * time.Hour
+
+// SilenceRequest is the body of POST /api/silences.
+type SilenceRequest struct {
+	// ID replaces the silence with this ID; a new ID is generated when
+	// empty.
+	ID         string           `json:"id"`
+	KPIs       []metrics.KPIKey `json:"kpis"`
+	Categories []string         `json:"categories"`
+	// StartsAt defaults to now.
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   time.Time  `json:"ends_at"`
+	Comment  string     `json:"comment"`
+	// CreatedBy names who created the silence, e.g. a change ticket owner.
+	CreatedBy string `json:"created_by"`
+}
+
+// AckRequest is the body of POST /api/alerts/ack.
+type AckRequest struct {
+	// Key is the dedup key of a firing alert, as listed by
+	// GET /api/alerts/firing.
+	Key     string `json:"key"`
+	By      string `json:"by"`
+	Comment string `json:"comment"`
+}
+
+// alertStore returns the store whose alerting state this server uses:
+// the shared store when sharded, so every shard honours the same
+// silences.
+func (s *Server) alertStore() *store.FileStore {
+	if s.shards != nil {
+		return s.shards.base
+	}
+	return s.store
+}
+
+// loadAlertState returns the silences and acknowledgments, which are kept
+// next to the store so the CLI can change them, or in memory without a
+// store.
+func (s *Server) loadAlertState() (*alerting.State, error) {
+	if s.alertStore() == nil {
+		s.alertMu.Lock()
+		defer s.alertMu.Unlock()
+		state := &alerting.State{
+			Silences:        append([]alerting.Silence(nil), s.alertState.Silences...),
+			Acknowledgments: append([]alerting.Acknowledgment(nil), s.alertState.Acknowledgments...),
+		}
+		return state, nil
+	}
+	return s.alertStore().LoadAlerting()
+}
+
+// updateAlertState applies update to the silences and acknowledgments and
+// saves them unless update fails.
+func (s *Server) updateAlertState(update func(*alerting.State) error) error {
+	s.alertMu.Lock()
+	defer s.alertMu.Unlock()
+	if s.alertStore() == nil {
+		return update(&s.alertState)
+	}
+	state, err := s.alertStore().LoadAlerting()
+	if err != nil {
+		return err
+	}
+	if err := update(state); err != nil {
+		return err
+	}
+	return s.alertStore().SaveAlerting(state)
+}
+
+// decideAlerts applies the alerting policy to alerts at now and returns
+// the events to send, dropping the acknowledgments of resolved alerts.
+func (s *Server) decideAlerts(alerts []alerting.Alert, now time.Time) []siem.Event {
+	state, err := s.loadAlertState()
+	if err != nil {
+		// Notifying without silences beats not notifying at all.
+		s.logger.Printf("alerting: %v", err)
+		state = &alerting.State{}
+	}
+	decision := s.alerts.Process(alerts, state, now)
+	s.recordAlerts(decision, now)
+	s.openTickets(decision.Fired, now)
+	for reason, n := range decision.Suppressed {
+		s.telemetry.ObserveAlertsSuppressed(reason, n)
+	}
+	// state is a copy, so resolving it only tells whether to save.
+	if state.Resolve(decision.Resolved) && !s.readOnly {
+		err := s.updateAlertState(func(state *alerting.State) error {
+			state.Resolve(decision.Resolved)
+			return nil
+		})
+		if err != nil {
+			s.logger.Printf("alerting: %v", err)
+		}
+	}
+	return decision.Events
+}
+
+// recordAlerts keeps the lifecycle of firing alerts with the collector's
+// alerts, so it is persisted with the store and reported like ingested
+// alerts. Each firing is a separate alert, identified by its key and
+// fired time.
+func (s *Server) recordAlerts(decision alerting.Decision, now time.Time) {
+	if s.readOnly || len(decision.Fired)+len(decision.Resolved) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, alert := range decision.Fired {
+		s.collector.AddAlert(metrics.Alert{
+			ID: recordedAlertID(alert.Key, now), Name: alert.Event.Name,
+			Severity: alert.Severity, Source: alertSource, FiredAt: now,
+		})
+	}
+	for _, key := range decision.Resolved {
+		s.updateOpenAlert(key, func(alert *metrics.Alert) { alert.ResolvedAt = now })
+	}
+}
+
+// recordedAlertID returns the ID of the alert recorded for the firing of
+// the alert with key at firedAt.
+func recordedAlertID(key string, firedAt time.Time) string {
+	return key + "@" + firedAt.UTC().Format(time.RFC3339)
+}
+
+// updateOpenAlert applies update to the unresolved recorded alert with
+// key. The caller must hold s.mu.
+func (s *Server) updateOpenAlert(key string, update func(*metrics.Alert)) {
+	for _, alert := range s.collector.GetAlerts() {
+		if alert.Source == alertSource && strings.HasPrefix(alert.ID, key+"@") && alert.ResolvedAt.IsZero() {
+			update(&alert)
+			s.collector.AddAlert(alert)
+		}
+	}
+}
+
+// addAlertMTTA feeds the mean time to acknowledge the alerts fired in the
+// last alertMTTAWindow back in as the alert_mtta KPI, once one was
+// acknowledged.
+func (s *Server) addAlertMTTA(collector *metrics.MetricsCollector) {
+	now := s.clock.Now()
+	mtta, acknowledged := collector.MeanTimeToAcknowledge(alertSource, now.Add(-alertMTTAWindow), now)
+	if acknowledged == 0 {
+		return
+	}
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_AlertMTTA, Value: mtta, Target: s.alerts.MTTATarget().Hours()})
+}
+
+// handleFiringAlerts lists the firing alerts with their acknowledgment and
+// silence.
+func (s *Server) handleFiringAlerts(w http.ResponseWriter, r *http.Request) {
+	state, err := s.loadAlertState()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.alerts.Firing(state, s.clock.Now()))
+}
+
+// handleAck acknowledges a firing alert, holding back its repeats until it
+// resolves.
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	if s.readOnly {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "server is read-only"})
+		return
+	}
+	var req AckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+	if !s.alerts.IsFiring(req.Key) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no firing alert with key %q", req.Key)})
+		return
+	}
+	ack := alerting.Acknowledgment{Key: req.Key, By: req.By, Comment: req.Comment, At: s.clock.Now()}
+	err := s.updateAlertState(func(state *alerting.State) error {
+		state.Acknowledge(ack)
+		return nil
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	s.updateOpenAlert(req.Key, func(alert *metrics.Alert) {
+		if alert.AcknowledgedAt.IsZero() {
+			alert.AcknowledgedAt = ack.At
+		}
+	})
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, ack)
+}
+
+// handleSilences lists the silences, including expired ones.
+func (s *Server) handleSilences(w http.ResponseWriter, r *http.Request) {
+	state, err := s.loadAlertState()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, state.Silences)
+}
+
+// handleCreateSilence creates or replaces a silence.
+func (s *Server) handleCreateSilence(w http.ResponseWriter, r *http.Request) {
+	if s.readOnly {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "server is read-only"})
+		return
+	}
+	var req SilenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+	silence := alerting.Silence{
+		ID: req.ID, KPIs: req.KPIs, Categories: req.Categories, StartsAt: s.clock.Now(), EndsAt: req.EndsAt,
+		Comment: req.Comment, CreatedBy: req.CreatedBy,
+	}
+	if silence.ID == "" {
+		silence.ID = alerting.NewSilenceID()
+	}
+	if req.StartsAt != nil {
+		silence.StartsAt = *req.StartsAt
+	}
+	if err := silence.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.updateAlertState(func(state *alerting.State) error { return state.AddSilence(silence) }); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, silence)
+}
+
+// handleExpireSilence ends a silence now, keeping it listed as a record.
+func (s *Server) handleExpireSilence(w http.ResponseWriter, r *http.Request) {
+	if s.readOnly {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "server is read-only"})
+		return
+	}
+	id := r.URL.Query().Get("id")
+	var found bool
+	err := s.updateAlertState(func(state *alerting.State) error {
+		found = state.ExpireSilence(id, s.clock.Now())
+		return nil
+	})
+	switch {
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	case !found:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no silence with id %q", id)})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"expired": id})
+	}
+}
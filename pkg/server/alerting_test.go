@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/siem"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+func TestSilencesAndAcknowledgments(t *testing.T) {
+	collector, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+	metricsStore := store.NewFileStore(filepath.Join(t.TempDir(), "store.json"), nil)
+	srv, err := New(Config{
+		SIEM:     siem.Config{Address: collector.LocalAddr().String()},
+		Alerting: alerting.Config{DedupWindow: "0s"},
+	}, nil, metricsStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := func() []string {
+		t.Helper()
+		var messages []string
+		buf := make([]byte, 4096)
+		for {
+			collector.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := collector.ReadFrom(buf)
+			if err != nil {
+				return messages
+			}
+			messages = append(messages, string(buf[:n]))
+		}
+	}
+	call := func(method, path, body string, want int) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if rec.Code != want {
+			t.Fatalf("%s %s = %d: %s", method, path, rec.Code, rec.Body)
+		}
+		return rec
+	}
+	ingest := func(value float64) {
+		srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Name: "MTTR", Value: value, Target: 2, Unit: "hours"}}})
+	}
+
+	ingest(1)
+	rec := call(http.MethodPost, "/api/silences", `{"kpis":["mttr"],"ends_at":"2099-01-01T00:00:00Z","comment":"CHG-1"}`, http.StatusCreated)
+	var silence alerting.Silence
+	json.NewDecoder(rec.Body).Decode(&silence)
+	if silence.ID == "" {
+		t.Fatalf("silence = %s", rec.Body)
+	}
+	ingest(5)
+	if messages := received(); len(messages) != 0 {
+		t.Fatalf("silenced breach sent %q", messages)
+	}
+	var telemetry strings.Builder
+	srv.telemetry.WritePrometheus(&telemetry)
+	if !strings.Contains(telemetry.String(), `secmetrics_alerts_suppressed_total{reason="silenced"} 1`) {
+		t.Error("suppression not counted")
+	}
+
+	// The silence is kept next to the store, where the CLI manages it.
+	state, err := metricsStore.LoadAlerting()
+	if err != nil || len(state.Silences) != 1 || state.Silences[0].Comment != "CHG-1" {
+		t.Fatalf("stored state = %+v, %v", state, err)
+	}
+	call(http.MethodDelete, "/api/silences?id="+silence.ID, "", http.StatusOK)
+	call(http.MethodDelete, "/api/silences?id=unknown", "", http.StatusNotFound)
+
+	var firing []alerting.FiringAlert
+	json.NewDecoder(call(http.MethodGet, "/api/alerts/firing", "", http.StatusOK).Body).Decode(&firing)
+	if len(firing) != 1 || firing[0].Key != "kpi_threshold/mttr/critical" || firing[0].SilencedBy != "" {
+		t.Fatalf("firing = %+v", firing)
+	}
+	call(http.MethodPost, "/api/alerts/ack", `{"key":"kpi_threshold/mttr/warning"}`, http.StatusNotFound)
+	call(http.MethodPost, "/api/alerts/ack", `{"key":"kpi_threshold/mttr/critical","by":"alice"}`, http.StatusOK)
+	if state, _ := metricsStore.LoadAlerting(); len(state.Acknowledgments) != 1 {
+		t.Fatalf("acknowledgments = %+v", state.Acknowledgments)
+	}
+
+	// Recovery is notified and drops the acknowledgment.
+	ingest(1.5)
+	if messages := received(); len(messages) != 1 || !strings.Contains(messages[0], "|kpi_recovered|") || !strings.Contains(messages[0], "dedupKey") {
+		t.Fatalf("recovery sent %q", messages)
+	}
+	if state, _ := metricsStore.LoadAlerting(); len(state.Acknowledgments) != 0 {
+		t.Errorf("acknowledgments after recovery = %+v", state.Acknowledgments)
+	}
+}
+
+func TestAlertHistoryAndMTTA(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	srv, err := New(Config{Alerting: alerting.Config{MTTATarget: "30m"}}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetClock(clk)
+	ingest := func(value float64) {
+		srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Name: "MTTR", Value: value, Target: 2, Unit: "hours"}}})
+	}
+
+	// Alerts are recorded without a SIEM.
+	ingest(1)
+	ingest(5)
+	clk.Advance(15 * time.Minute)
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/alerts/ack", strings.NewReader(`{"key":"kpi_threshold/mttr/critical"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ack = %d: %s", rec.Code, rec.Body)
+	}
+	clk.Advance(15 * time.Minute)
+	ingest(1.5)
+
+	alerts := srv.collector.GetAlerts()
+	if len(alerts) != 1 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	alert := alerts[0]
+	if alert.Source != "secmetrics" || alert.Severity != "critical" || !strings.HasPrefix(alert.ID, "kpi_threshold/mttr/critical@") ||
+		alert.AcknowledgedAt.Sub(alert.FiredAt) != 15*time.Minute || alert.ResolvedAt.Sub(alert.FiredAt) != 30*time.Minute {
+		t.Fatalf("alert = %+v", alert)
+	}
+
+	// The next collection carries the history over and reports MTTA.
+	srv.CollectOnce(context.Background())
+	if got := srv.collector.GetAlerts(); len(got) != 1 || got[0] != alert {
+		t.Fatalf("alerts after collection = %+v", got)
+	}
+	var mtta *metrics.KPI
+	for _, kpi := range srv.collector.GetKPIS() {
+		if kpi.Key == metrics.KPI_AlertMTTA {
+			mtta = &kpi
+		}
+	}
+	if mtta == nil || mtta.Value != 0.25 || mtta.Target != 0.5 || mtta.Status != "ON_TARGET" {
+		t.Fatalf("alert_mtta = %+v", mtta)
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/pkg/approval"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// ReviewRequest is the body of POST /api/reports/submit, /approve and
+// /reject.
+type ReviewRequest struct {
+	// ID is the report ID, as listed by GET /api/reports.
+	ID string `json:"id"`
+	// By names who submits, approves or rejects the report. With API keys
+	// or single sign-on, the caller's identity is recorded instead.
+	By      string `json:"by"`
+	Comment string `json:"comment"`
+}
+
+// approvalStore returns the store whose approval state this server uses:
+// the shared store when sharded, like the alerting state.
+func (s *Server) approvalStore() *store.FileStore {
+	if s.shards != nil {
+		return s.shards.base
+	}
+	return s.store
+}
+
+// loadApproval returns the reports in the approval workflow, which are kept
+// next to the store so the CLI can review them, or in memory without a
+// store.
+func (s *Server) loadApproval() (*approval.State, error) {
+	if s.approvalStore() == nil {
+		s.approvalMu.Lock()
+		defer s.approvalMu.Unlock()
+		state := &approval.State{Reports: make([]approval.Report, len(s.approvalState.Reports))}
+		for i, report := range s.approvalState.Reports {
+			report.Approvals = append([]approval.Approval(nil), report.Approvals...)
+			report.History = append([]approval.Transition(nil), report.History...)
+			state.Reports[i] = report
+		}
+		return state, nil
+	}
+	return s.approvalStore().LoadApproval()
+}
+
+// updateApproval applies update to the approval state and saves it unless
+// update fails.
+func (s *Server) updateApproval(update func(*approval.State) error) error {
+	s.approvalMu.Lock()
+	defer s.approvalMu.Unlock()
+	if s.approvalStore() == nil {
+		return update(&s.approvalState)
+	}
+	state, err := s.approvalStore().LoadApproval()
+	if err != nil {
+		return err
+	}
+	if err := update(state); err != nil {
+		return err
+	}
+	return s.approvalStore().SaveApproval(state)
+}
+
+// deliverApproved delivers the approved reports not yet delivered, whether
+// approved through the API or the approval command.
+func (s *Server) deliverApproved(ctx context.Context) {
+	if s.deliver == nil || s.readOnly {
+		return
+	}
+	s.deliverMu.Lock()
+	defer s.deliverMu.Unlock()
+	state, err := s.loadApproval()
+	if err != nil {
+		s.logger.Printf("approval: %v", err)
+		return
+	}
+	for _, id := range state.Undelivered() {
+		report := state.Find(id)
+		if err := s.deliver(ctx, report.Filename, report.Content); err != nil {
+			s.logger.Printf("approval: deliver %s: %v", id, err)
+			continue
+		}
+		now := s.clock.Now()
+		err := s.updateApproval(func(state *approval.State) error {
+			state.MarkDelivered(id, now)
+			return nil
+		})
+		if err != nil {
+			s.logger.Printf("approval: %v", err)
+			continue
+		}
+		s.logger.Printf("approval: delivered %s approved by %s", report.Filename, strings.Join(report.Approvers(), ", "))
+	}
+}
+
+// reviewer returns who makes a review request: the authenticated caller
+// when auth is configured, else the name given in the request.
+func (s *Server) reviewer(r *http.Request, by string) string {
+	if s.auth == nil {
+		return by
+	}
+	if id := s.auth.authenticate(r, s.clock.Now()); id != nil {
+		return id.Name
+	}
+	return ""
+}
+
+// handleApprovalReports lists the reports in the approval workflow, newest
+// first, without their content.
+func (s *Server) handleApprovalReports(w http.ResponseWriter, r *http.Request) {
+	state, err := s.loadApproval()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, state.Sorted())
+}
+
+// handleApprovalContent serves the rendered content of a report for review.
+func (s *Server) handleApprovalContent(w http.ResponseWriter, r *http.Request) {
+	state, err := s.loadApproval()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	id := r.URL.Query().Get("id")
+	report := state.Find(id)
+	if report == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("%v: %s", approval.ErrNotFound, id)})
+		return
+	}
+	if strings.HasSuffix(report.Filename, ".html") {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Write(report.Content)
+}
+
+// handleReview returns a handler moving a report through the workflow with
+// transition, then delivering it once approved.
+func (s *Server) handleReview(transition func(state *approval.State, req ReviewRequest) (*approval.Report, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "server is read-only"})
+			return
+		}
+		var req ReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+			return
+		}
+		req.By = s.reviewer(r, req.By)
+		var report approval.Report
+		err := s.updateApproval(func(state *approval.State) error {
+			changed, err := transition(state, req)
+			if err != nil {
+				return err
+			}
+			report = *changed
+			return nil
+		})
+		switch {
+		case errors.Is(err, approval.ErrNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+			return
+		}
+		if report.Status == approval.StatusApproved && s.runsReports() {
+			s.deliverApproved(r.Context())
+		}
+		report.Content = nil
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// handleSubmitReport submits a draft report for review.
+func (s *Server) handleSubmitReport(w http.ResponseWriter, r *http.Request) {
+	s.handleReview(func(state *approval.State, req ReviewRequest) (*approval.Report, error) {
+		return state.Submit(req.ID, req.By, req.Comment, s.clock.Now())
+	})(w, r)
+}
+
+// handleApproveReport records an approval of a report in review.
+func (s *Server) handleApproveReport(w http.ResponseWriter, r *http.Request) {
+	s.handleReview(func(state *approval.State, req ReviewRequest) (*approval.Report, error) {
+		return state.Approve(s.approval, req.ID, req.By, req.Comment, s.clock.Now())
+	})(w, r)
+}
+
+// handleRejectReport returns a report in review to draft.
+func (s *Server) handleRejectReport(w http.ResponseWriter, r *http.Request) {
+	s.handleReview(func(state *approval.State, req ReviewRequest) (*approval.Report, error) {
+		return state.Reject(req.ID, req.By, req.Comment, s.clock.Now())
+	})(w, r)
+}
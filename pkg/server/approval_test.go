@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/approval"
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+func TestScheduledReportsAwaitApproval(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mttr.json"), []byte(desiredMTTR), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	render := func(collector *metrics.MetricsCollector, reportType string, location *time.Location) (string, error) {
+		return reportType + " report", nil
+	}
+	metricsStore := store.NewFileStore(filepath.Join(t.TempDir(), "store.json"), nil)
+	cfg := Config{GitOps: GitOpsConfig{Dir: dir}, Approval: approval.Config{Required: true, Approvers: []string{"ciso"}}}
+	srv, err := New(cfg, nil, metricsStore, render)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	srv.SetClock(clk)
+	var delivered []string
+	srv.SetDeliver(func(ctx context.Context, filename string, content []byte) error {
+		delivered = append(delivered, filename+": "+string(content))
+		return nil
+	})
+
+	ctx := context.Background()
+	srv.syncGitOps(ctx)
+	srv.runReports(ctx)
+	clk.Advance(24 * time.Hour)
+	srv.runReports(ctx)
+	srv.deliverApproved(ctx)
+	if len(delivered) != 0 {
+		t.Fatalf("delivered %q before approval", delivered)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	var reports []approval.Report
+	if rec := do(http.MethodGet, "/api/reports", ""); json.Unmarshal(rec.Body.Bytes(), &reports) != nil || len(reports) != 1 {
+		t.Fatalf("GET /api/reports: %d %s", rec.Code, rec.Body)
+	}
+	id := reports[0].ID
+	if id != "daily-20261002-090000" || reports[0].Status != approval.StatusDraft {
+		t.Errorf("report = %+v", reports[0])
+	}
+	if rec := do(http.MethodGet, "/api/reports/content?id="+id, ""); rec.Body.String() != "executive report" {
+		t.Errorf("content = %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(http.MethodPost, "/api/reports/approve", `{"id": "`+id+`", "by": "ciso"}`); rec.Code != http.StatusConflict {
+		t.Errorf("approving a draft: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/reports/submit", `{"id": "`+id+`", "by": "analyst"}`); rec.Code != http.StatusOK {
+		t.Fatalf("submit: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/reports/approve", `{"id": "`+id+`", "by": "analyst"}`); rec.Code != http.StatusConflict {
+		t.Errorf("approval by a non-approver: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/reports/approve", `{"id": "nope", "by": "ciso"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("approving an unknown report: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPost, "/api/reports/approve", `{"id": "`+id+`", "by": "ciso", "comment": "release"}`); rec.Code != http.StatusOK {
+		t.Fatalf("approve: %d %s", rec.Code, rec.Body)
+	}
+	if len(delivered) != 1 || delivered[0] != "daily-20261002-090000.txt: executive report" {
+		t.Errorf("delivered %q after approval", delivered)
+	}
+
+	// The CLI shares the state kept next to the store
+	state, err := metricsStore.LoadApproval()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := state.Find(id)
+	if report == nil || report.DeliveredAt.IsZero() || len(report.Approvals) != 1 || report.Approvals[0].By != "ciso" {
+		t.Errorf("stored report = %+v", report)
+	}
+	srv.deliverApproved(ctx)
+	if len(delivered) != 1 {
+		t.Errorf("delivered an approved report twice: %q", delivered)
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+)
+
+// Role grants access to API endpoints. Each role includes the access of the
+// roles below it.
+type Role string
+
+// Roles, from least to most privileged.
+const (
+	// RoleViewer reads dashboards, KPIs and reports.
+	RoleViewer Role = "viewer"
+	// RoleAnalyst additionally pushes data through POST /ingest.
+	RoleAnalyst Role = "analyst"
+	// RoleAdmin additionally operates the server, e.g. triggers collection.
+	RoleAdmin Role = "admin"
+)
+
+// rank orders roles by privilege; unknown roles rank 0 and grant nothing.
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleAnalyst:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// Allows reports whether r includes the access of required.
+func (r Role) Allows(required Role) bool {
+	return r.rank() > 0 && r.rank() >= required.rank()
+}
+
+// AuthConfig configures access control for the API. Without keys or
+// single sign-on the API is open to anyone who can reach it.
+type AuthConfig struct {
+	Keys []APIKey   `yaml:"keys"`
+	OIDC OIDCConfig `yaml:"oidc"`
+}
+
+// APIKey assigns a role to a bearer token.
+type APIKey struct {
+	// Name identifies the key in error messages.
+	Name string `yaml:"name"`
+	// Key is the token; KeyEnv names an environment variable holding it
+	// instead, to keep it out of the config file.
+	Key    string `yaml:"key"`
+	KeyEnv string `yaml:"key_env"`
+	Role   Role   `yaml:"role"`
+	// Tenants limits the key to servers whose tenant is listed; empty
+	// allows every tenant.
+	Tenants []string `yaml:"tenants"`
+}
+
+// Identity is an authenticated API client.
+type Identity struct {
+	Name    string
+	Role    Role
+	Tenants []string
+}
+
+// allowsTenant reports whether the identity may access tenant.
+func (id *Identity) allowsTenant(tenant string) bool {
+	if len(id.Tenants) == 0 {
+		return true
+	}
+	for _, t := range id.Tenants {
+		if t == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizer authenticates API requests by API key or SSO session.
+type authorizer struct {
+	// keys maps the SHA-256 of each token to its identity, so lookups do
+	// not compare tokens byte by byte.
+	keys map[[sha256.Size]byte]*Identity
+	// sso is nil unless single sign-on is configured.
+	sso *sso
+}
+
+// newAuthorizer resolves the configured keys and single sign-on. It
+// returns nil when neither is configured.
+func newAuthorizer(cfg AuthConfig) (*authorizer, error) {
+	if len(cfg.Keys) == 0 && cfg.OIDC.Issuer == "" {
+		return nil, nil
+	}
+	a := &authorizer{keys: make(map[[sha256.Size]byte]*Identity)}
+	if cfg.OIDC.Issuer != "" {
+		var err error
+		if a.sso, err = newSSO(cfg.OIDC); err != nil {
+			return nil, err
+		}
+	}
+	for i, key := range cfg.Keys {
+		name := key.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if key.Role.rank() == 0 {
+			return nil, fmt.Errorf("server auth key %s: unknown role %q (want viewer, analyst or admin)", name, key.Role)
+		}
+		token := key.Key
+		if key.KeyEnv != "" {
+			token = secretenv.Get(key.KeyEnv)
+			if token == "" {
+				return nil, fmt.Errorf("server auth key %s: environment variable %s is not set", name, key.KeyEnv)
+			}
+		}
+		if token == "" {
+			return nil, fmt.Errorf("server auth key %s: key or key_env is required", name)
+		}
+		sum := sha256.Sum256([]byte(token))
+		if _, ok := a.keys[sum]; ok {
+			return nil, fmt.Errorf("server auth key %s: key is already assigned", name)
+		}
+		a.keys[sum] = &Identity{Name: name, Role: key.Role, Tenants: key.Tenants}
+	}
+	return a, nil
+}
+
+// authenticate returns the identity of the request's API key or session
+// token, given as a bearer token or, for sessions, the session cookie.
+func (a *authorizer) authenticate(r *http.Request, now time.Time) *Identity {
+	token := bearerToken(r)
+	if id := a.keys[sha256.Sum256([]byte(token))]; id != nil && token != "" {
+		return id
+	}
+	if a.sso == nil {
+		return nil
+	}
+	if token == "" {
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+	return a.sso.sessions.lookup(token, now)
+}
+
+// bearerToken returns the request's bearer token, or "".
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// require wraps handler so it only serves clients with at least role on
+// the server's tenant. It is a no-op when auth is not configured.
+func (s *Server) require(role Role, handler http.HandlerFunc) http.HandlerFunc {
+	if s.auth == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := s.auth.authenticate(r, s.clock.Now())
+		if id == nil {
+			// Send browsers to single sign-on rather than a JSON error.
+			if s.auth.sso != nil && r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				http.Redirect(w, r, "/auth/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="secmetrics"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid API key or session"})
+			return
+		}
+		if !id.allowsTenant(s.tenant) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("key %s has no access to tenant %s", id.Name, s.tenant)})
+			return
+		}
+		if !id.Role.Allows(role) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("%s requires the %s role", r.URL.Path, role)})
+			return
+		}
+		handler(w, r)
+	}
+}
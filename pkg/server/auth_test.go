@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthEnforcesRolesAndTenants(t *testing.T) {
+	t.Setenv("TEST_ADMIN_KEY", "admin-token")
+	srv, err := New(Config{
+		Tenant: "emea",
+		Auth: AuthConfig{Keys: []APIKey{
+			{Name: "wallboard", Key: "viewer-token", Role: RoleViewer},
+			{Name: "scanner", Key: "analyst-token", Role: RoleAnalyst, Tenants: []string{"emea"}},
+			{Name: "apac-scanner", Key: "apac-token", Role: RoleAnalyst, Tenants: []string{"apac"}},
+			{Name: "ops", KeyEnv: "TEST_ADMIN_KEY", Role: RoleAdmin},
+		}},
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := srv.Handler()
+
+	tests := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/api/kpis", "", http.StatusUnauthorized},
+		{"GET", "/api/kpis", "wrong-token", http.StatusUnauthorized},
+		{"GET", "/api/kpis", "viewer-token", http.StatusOK},
+		{"GET", "/api/summary", "analyst-token", http.StatusOK},
+		{"POST", "/ingest", "viewer-token", http.StatusForbidden},
+		{"POST", "/ingest", "analyst-token", http.StatusBadRequest},
+		{"POST", "/ingest", "apac-token", http.StatusForbidden},
+		{"GET", "/api/kpis", "apac-token", http.StatusForbidden},
+		{"POST", "/api/collect", "analyst-token", http.StatusForbidden},
+		{"POST", "/api/collect", "admin-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s with %q: status %d, want %d (%s)", tt.method, tt.path, tt.token, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestAuthRejectsInvalidKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []APIKey
+		want string
+	}{
+		{"unknown role", []APIKey{{Name: "a", Key: "k", Role: "owner"}}, "unknown role"},
+		{"missing key", []APIKey{{Name: "a", Role: RoleViewer}}, "key or key_env is required"},
+		{"unset env", []APIKey{{Name: "a", KeyEnv: "TEST_UNSET_KEY", Role: RoleViewer}}, "is not set"},
+		{"duplicate", []APIKey{{Name: "a", Key: "k", Role: RoleViewer}, {Name: "b", Key: "k", Role: RoleAdmin}}, "already assigned"},
+	}
+	for _, tt := range tests {
+		if _, err := New(Config{Auth: AuthConfig{Keys: tt.keys}}, nil, nil, nil); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: New error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
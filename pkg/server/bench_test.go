@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func BenchmarkCollectOnce(b *testing.B) {
+	types := []metrics.MetricType{metrics.TypeVulnerability, metrics.TypeCompliance, metrics.TypeDetection, metrics.TypeRisk}
+	batch := make([]metrics.SecurityMetric, 100000)
+	for i := range batch {
+		batch[i] = metrics.SecurityMetric{ID: fmt.Sprintf("m-%d", i), Type: types[i%len(types)], Value: float64(i % 100), Target: 90}
+	}
+	source := Source{Name: "bench", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		for _, metric := range batch {
+			collector.AddMetric(metric)
+		}
+		collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 3, Target: 2})
+		return nil
+	}}
+	srv, err := New(Config{Addr: "127.0.0.1:0", Interval: "1h"}, []Source{source}, nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		srv.CollectOnce(context.Background())
+	}
+}
@@ -0,0 +1,400 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/nats"
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// Event bus event types.
+const (
+	EventMetricIngested  = "metric.ingested"
+	EventAlertFired      = "alert.fired"
+	EventReportGenerated = "report.generated"
+)
+
+// BusEvent is a message published to the event bus. Its subject or topic
+// is the configured prefix followed by the event type.
+type BusEvent struct {
+	Type   string      `json:"type"`
+	Tenant string      `json:"tenant"`
+	Time   time.Time   `json:"time"`
+	Data   interface{} `json:"data"`
+}
+
+// MetricsIngested is the data of a metric.ingested event: the values one
+// ingestion batch or scheduled collection took in.
+type MetricsIngested struct {
+	// Source is "ingest" for POST /ingest and "collection" for a
+	// scheduled collection.
+	Source  string                   `json:"source"`
+	Metrics []metrics.SecurityMetric `json:"metrics,omitempty"`
+	KPIs    []metrics.KPI            `json:"kpis,omitempty"`
+}
+
+// ReportGenerated is the data of a report.generated event.
+type ReportGenerated struct {
+	ReportType string `json:"report_type"`
+	// Schedule and Filename are set for scheduled reports.
+	Schedule string `json:"schedule,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Bytes    int    `json:"bytes"`
+}
+
+// EventBusConfig publishes metric, alert and report events to NATS or
+// Kafka for downstream data platforms.
+type EventBusConfig struct {
+	NATS  NATSConfig  `yaml:"nats"`
+	Kafka KafkaConfig `yaml:"kafka"`
+	// Prefix starts every subject or topic name.
+	Prefix string `yaml:"prefix"`
+	// Events limits the published event types; empty publishes all.
+	Events []string `yaml:"events"`
+	// QueueSize bounds the events waiting to be published; further
+	// events are dropped.
+	QueueSize int `yaml:"queue_size"`
+	// Client makes requests to the Kafka REST proxy, and TLSConfig
+	// secures NATS connections; both are set by the caller rather than
+	// from the config file.
+	Client    *http.Client `yaml:"-"`
+	TLSConfig *tls.Config  `yaml:"-"`
+}
+
+// NATSConfig publishes to a NATS server.
+type NATSConfig struct {
+	// URL is nats://host:port or tls://host:port; setting it enables
+	// NATS.
+	URL  string `yaml:"url"`
+	User string `yaml:"user"`
+	// PasswordEnv and TokenEnv name the environment variables holding
+	// the user's password or an authentication token.
+	PasswordEnv string `yaml:"password_env"`
+	TokenEnv    string `yaml:"token_env"`
+}
+
+// KafkaConfig publishes to Kafka through a Confluent-compatible REST
+// proxy (API v2).
+type KafkaConfig struct {
+	// RESTProxy is the proxy's base URL; setting it enables Kafka.
+	RESTProxy string `yaml:"rest_proxy"`
+	Username  string `yaml:"username"`
+	// PasswordEnv names the environment variable holding the password
+	// for basic authentication.
+	PasswordEnv string `yaml:"password_env"`
+}
+
+// Event bus defaults applied when a setting is not configured.
+const (
+	DefaultEventBusPrefix    = "secmetrics"
+	DefaultEventBusQueueSize = 1000
+)
+
+// busBatchSize bounds the events published in one call to the broker.
+const busBatchSize = 100
+
+// busPublishTimeout bounds one publish to the broker.
+const busPublishTimeout = 10 * time.Second
+
+// busMessage is an encoded event with its subject or topic and its key.
+type busMessage struct {
+	subject string
+	key     string
+	data    []byte
+}
+
+// busPublisher sends messages to a broker.
+type busPublisher interface {
+	publish(ctx context.Context, messages []busMessage) error
+	close()
+}
+
+// eventBus queues events and publishes them in the background, so that
+// a slow or unavailable broker never delays ingestion or reports.
+type eventBus struct {
+	publisher busPublisher
+	prefix    string
+	types     map[string]bool
+	queue     chan busMessage
+	done      chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newEventBus validates cfg. It returns nil when no broker is configured.
+func newEventBus(cfg EventBusConfig) (*eventBus, error) {
+	var publisher busPublisher
+	switch {
+	case cfg.NATS.URL != "" && cfg.Kafka.RESTProxy != "":
+		return nil, fmt.Errorf("server event_bus: configure either nats or kafka, not both")
+	case cfg.NATS.URL != "":
+		opts := nats.Options{URL: cfg.NATS.URL, User: cfg.NATS.User, Name: "secmetrics", TLS: cfg.TLSConfig}
+		for _, secret := range []struct {
+			env   string
+			value *string
+		}{{cfg.NATS.PasswordEnv, &opts.Password}, {cfg.NATS.TokenEnv, &opts.Token}} {
+			if secret.env == "" {
+				continue
+			}
+			if *secret.value = secretenv.Get(secret.env); *secret.value == "" {
+				return nil, fmt.Errorf("server event_bus nats: environment variable %s is not set", secret.env)
+			}
+		}
+		if u, err := url.Parse(cfg.NATS.URL); err != nil || u.Host == "" || (u.Scheme != "nats" && u.Scheme != "tls") {
+			return nil, fmt.Errorf("server event_bus nats: url must be nats://host:port or tls://host:port")
+		}
+		publisher = &natsPublisher{client: nats.NewClient(opts)}
+	case cfg.Kafka.RESTProxy != "":
+		if u, err := url.Parse(cfg.Kafka.RESTProxy); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("server event_bus kafka: invalid rest_proxy %q", cfg.Kafka.RESTProxy)
+		}
+		kafka := &kafkaPublisher{baseURL: strings.TrimSuffix(cfg.Kafka.RESTProxy, "/"), client: cfg.Client, username: cfg.Kafka.Username}
+		if kafka.client == nil {
+			kafka.client = &http.Client{Timeout: busPublishTimeout}
+		}
+		if cfg.Kafka.PasswordEnv != "" {
+			if kafka.password = secretenv.Get(cfg.Kafka.PasswordEnv); kafka.password == "" {
+				return nil, fmt.Errorf("server event_bus kafka: environment variable %s is not set", cfg.Kafka.PasswordEnv)
+			}
+		}
+		publisher = kafka
+	default:
+		return nil, nil
+	}
+
+	types := make(map[string]bool)
+	for _, eventType := range cfg.Events {
+		switch eventType {
+		case EventMetricIngested, EventAlertFired, EventReportGenerated:
+			types[eventType] = true
+		default:
+			return nil, fmt.Errorf("server event_bus: unknown event type %q", eventType)
+		}
+	}
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = DefaultEventBusPrefix
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultEventBusQueueSize
+	}
+	return &eventBus{
+		publisher: publisher,
+		prefix:    prefix,
+		types:     types,
+		queue:     make(chan busMessage, queueSize),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// start hands queued messages to publish, in batches, until the bus is
+// closed.
+func (b *eventBus) start(publish func([]busMessage)) {
+	go func() {
+		defer close(b.done)
+		for message := range b.queue {
+			batch := []busMessage{message}
+		drain:
+			for len(batch) < busBatchSize {
+				select {
+				case message, ok := <-b.queue:
+					if !ok {
+						break drain
+					}
+					batch = append(batch, message)
+				default:
+					break drain
+				}
+			}
+			publish(batch)
+		}
+	}()
+}
+
+// offer queues message without blocking, reporting whether it was
+// accepted.
+func (b *eventBus) offer(message busMessage) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	select {
+	case b.queue <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops accepting events and waits until the queued ones are
+// published or ctx ends.
+func (b *eventBus) close(ctx context.Context) {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+	}
+	b.publisher.close()
+}
+
+// publishEvent hands an event to the event bus, if one is configured and
+// the event type is enabled. The event is encoded at once, since data may
+// share memory with the collector.
+func (s *Server) publishEvent(eventType string, data interface{}) {
+	if s.bus == nil || (len(s.bus.types) > 0 && !s.bus.types[eventType]) {
+		return
+	}
+	encoded, err := json.Marshal(BusEvent{Type: eventType, Tenant: s.tenant, Time: s.clock.Now(), Data: data})
+	if err != nil {
+		s.logger.Printf("event bus: encode %s event: %v", eventType, err)
+		return
+	}
+	if !s.bus.offer(busMessage{subject: s.bus.prefix + "." + eventType, key: s.tenant, data: encoded}) {
+		s.telemetry.ObserveBusEvents("dropped", 1)
+	}
+}
+
+// publishBatch publishes messages, retrying once so that a connection the
+// broker closed while idle does not lose them.
+func (s *Server) publishBatch(messages []busMessage) {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), busPublishTimeout)
+		err = s.bus.publisher.publish(ctx, messages)
+		cancel()
+		if err == nil {
+			s.telemetry.ObserveBusEvents("published", len(messages))
+			return
+		}
+	}
+	s.logger.Printf("event bus: publish %d events: %v", len(messages), err)
+	s.telemetry.ObserveBusEvents("failed", len(messages))
+}
+
+// firedAlerts returns the alerts in after whose IDs are not in before.
+func firedAlerts(before, after []metrics.Alert) []metrics.Alert {
+	known := make(map[string]bool, len(before))
+	for _, alert := range before {
+		known[alert.ID] = true
+	}
+	var fired []metrics.Alert
+	for _, alert := range after {
+		if !known[alert.ID] {
+			fired = append(fired, alert)
+		}
+	}
+	return fired
+}
+
+// natsPublisher publishes each event to its subject.
+type natsPublisher struct {
+	client *nats.Client
+}
+
+func (p *natsPublisher) publish(ctx context.Context, messages []busMessage) error {
+	natsMessages := make([]nats.Message, len(messages))
+	for i, message := range messages {
+		natsMessages[i] = nats.Message{Subject: message.subject, Data: message.data}
+	}
+	return p.client.Publish(ctx, natsMessages...)
+}
+
+func (p *natsPublisher) close() {
+	p.client.Close()
+}
+
+// kafkaPublisher produces events through a Kafka REST proxy, one request
+// per topic in each batch, keyed by tenant.
+type kafkaPublisher struct {
+	baseURL  string
+	client   *http.Client
+	username string
+	password string
+}
+
+// kafkaRecord is a record in a REST proxy produce request.
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (p *kafkaPublisher) publish(ctx context.Context, messages []busMessage) error {
+	var order []string
+	records := make(map[string][]kafkaRecord)
+	for _, message := range messages {
+		if _, ok := records[message.subject]; !ok {
+			order = append(order, message.subject)
+		}
+		records[message.subject] = append(records[message.subject], kafkaRecord{Key: message.key, Value: message.data})
+	}
+	for _, topic := range order {
+		if err := p.produce(ctx, topic, records[topic]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// produce sends records to topic and checks every record's result.
+func (p *kafkaPublisher) produce(ctx context.Context, topic string, records []kafkaRecord) error {
+	body, _ := json.Marshal(map[string][]kafkaRecord{"records": records})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &failure) == nil && failure.Message != "" {
+			return fmt.Errorf("kafka: produce to %s: %s: %s", topic, resp.Status, failure.Message)
+		}
+		return fmt.Errorf("kafka: produce to %s: %s", topic, resp.Status)
+	}
+	var result struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("kafka: produce to %s: invalid response: %w", topic, err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.Error != "" {
+			return fmt.Errorf("kafka: produce to %s: %s", topic, offset.Error)
+		}
+	}
+	return nil
+}
+
+func (p *kafkaPublisher) close() {}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// fakeKafkaProxy records the records produced through it. Topics ending
+// in ".rejected" fail per record, as the REST proxy reports broker errors.
+type fakeKafkaProxy struct {
+	mu      sync.Mutex
+	topics  []string
+	records []kafkaRecord
+}
+
+func (f *fakeKafkaProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		io.WriteString(w, `{"error_code":415,"message":"HTTP 415 Unsupported Media Type"}`)
+		return
+	}
+	if user, password, _ := r.BasicAuth(); user != "secmetrics" || password != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error_code":401,"message":"Unauthorized"}`)
+		return
+	}
+	topic := strings.TrimPrefix(r.URL.Path, "/topics/")
+	var body struct {
+		Records []kafkaRecord `json:"records"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	if strings.HasSuffix(topic, ".rejected") {
+		io.WriteString(w, `{"offsets":[{"partition":null,"offset":null,"error_code":1,"error":"Topic authorization failed"}]}`)
+		return
+	}
+	f.mu.Lock()
+	for _, record := range body.Records {
+		f.topics = append(f.topics, topic)
+		f.records = append(f.records, record)
+	}
+	f.mu.Unlock()
+	io.WriteString(w, `{"offsets":[{"partition":0,"offset":0,"error_code":null,"error":null}]}`)
+}
+
+func TestEventBusPublishesToKafka(t *testing.T) {
+	proxy := &fakeKafkaProxy{}
+	api := httptest.NewServer(proxy)
+	defer api.Close()
+	t.Setenv("KAFKA_PASSWORD", "secret")
+
+	render := func(collector *metrics.MetricsCollector, reportType string, location *time.Location) (string, error) {
+		return reportType + " report", nil
+	}
+	srv, err := New(Config{Tenant: "acme", EventBus: EventBusConfig{
+		Kafka: KafkaConfig{RESTProxy: api.URL + "/", Username: "secmetrics", PasswordEnv: "KAFKA_PASSWORD"},
+	}}, nil, nil, render)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+
+	firedAt := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	alert := metrics.Alert{ID: "a-1", Name: "Privilege escalation", Severity: "high", FiredAt: firedAt}
+	srv.applyIngest(IngestBatch{
+		Metrics: []metrics.SecurityMetric{{ID: "vulns", Name: "Open vulnerabilities", Value: 12}},
+		Alerts:  []metrics.Alert{alert},
+	})
+	// An update to a known alert does not fire it again.
+	alert.AcknowledgedAt = firedAt.Add(time.Hour)
+	srv.applyIngest(IngestBatch{Alerts: []metrics.Alert{alert}})
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?type=executive", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("report status = %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.bus.close(ctx)
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	want := []string{"secmetrics.metric.ingested", "secmetrics.alert.fired", "secmetrics.report.generated"}
+	if strings.Join(proxy.topics, " ") != strings.Join(want, " ") {
+		t.Fatalf("topics = %v, want %v", proxy.topics, want)
+	}
+	var events []BusEvent
+	for _, record := range proxy.records {
+		var event BusEvent
+		if err := json.Unmarshal(record.Value, &event); err != nil {
+			t.Fatal(err)
+		}
+		if record.Key != "acme" || event.Tenant != "acme" {
+			t.Errorf("record key %q, tenant %q, want acme", record.Key, event.Tenant)
+		}
+		events = append(events, event)
+	}
+	if data, _ := json.Marshal(events[0].Data); !strings.Contains(string(data), `"source":"ingest"`) || !strings.Contains(string(data), `"ID":"vulns"`) {
+		t.Errorf("metric.ingested data = %s", data)
+	}
+	if data, _ := json.Marshal(events[1].Data); !strings.Contains(string(data), `"ID":"a-1"`) {
+		t.Errorf("alert.fired data = %s", data)
+	}
+	if data, _ := json.Marshal(events[2].Data); string(data) != `{"bytes":16,"report_type":"executive"}` {
+		t.Errorf("report.generated data = %s", data)
+	}
+
+	var b strings.Builder
+	srv.telemetry.WritePrometheus(&b)
+	if !strings.Contains(b.String(), `secmetrics_event_bus_events_total{result="published"} 3`) {
+		t.Errorf("published events not counted:\n%s", b.String())
+	}
+}
+
+func TestEventBusFailures(t *testing.T) {
+	proxy := &fakeKafkaProxy{}
+	api := httptest.NewServer(proxy)
+	defer api.Close()
+	t.Setenv("KAFKA_PASSWORD", "secret")
+
+	srv, err := New(Config{EventBus: EventBusConfig{
+		Kafka:  KafkaConfig{RESTProxy: api.URL, Username: "secmetrics", PasswordEnv: "KAFKA_PASSWORD"},
+		Events: []string{EventAlertFired},
+	}}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+
+	// Disabled event types are not queued.
+	srv.publishEvent(EventMetricIngested, MetricsIngested{Source: "ingest"})
+	srv.publishBatch([]busMessage{{subject: "secmetrics.alert.rejected", data: []byte("{}")}})
+
+	var b strings.Builder
+	srv.telemetry.WritePrometheus(&b)
+	if !strings.Contains(b.String(), `secmetrics_event_bus_events_total{result="failed"} 1`) {
+		t.Errorf("failed publish not counted:\n%s", b.String())
+	}
+	srv.bus.close(context.Background())
+	if len(proxy.records) != 0 {
+		t.Errorf("published %d records, want none", len(proxy.records))
+	}
+
+	for _, cfg := range []EventBusConfig{
+		{NATS: NATSConfig{URL: "nats://localhost:4222"}, Kafka: KafkaConfig{RESTProxy: "http://localhost:8082"}},
+		{NATS: NATSConfig{URL: "localhost:4222"}},
+		{NATS: NATSConfig{URL: "nats://localhost:4222"}, Events: []string{"kpi.changed"}},
+		{NATS: NATSConfig{URL: "nats://localhost:4222", TokenEnv: "SECMETRICS_UNSET_NATS_TOKEN"}},
+	} {
+		if _, err := newEventBus(cfg); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+}
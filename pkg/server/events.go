@@ -0,0 +1,359 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/redis"
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// KPIEvent is a change to a KPI, streamed to dashboards by GET /api/events.
+type KPIEvent struct {
+	// Action is "add", "change" or "remove".
+	Action   string         `json:"action"`
+	Key      metrics.KPIKey `json:"key"`
+	Name     string         `json:"name,omitempty"`
+	Category string         `json:"category,omitempty"`
+	Value    float64        `json:"value"`
+	// Previous is the value before a change.
+	Previous *float64  `json:"previous,omitempty"`
+	Target   float64   `json:"target"`
+	Unit     string    `json:"unit,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// eventBuffer is how many events a slow stream may fall behind before it
+// is disconnected; its client reconnects and reloads.
+const eventBuffer = 64
+
+// eventKeepAlive is the interval between comments that keep idle streams
+// open through proxies.
+const eventKeepAlive = 30 * time.Second
+
+// eventHub detects KPI changes and fans them out to event streams.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan KPIEvent]struct{}
+	closed      bool
+	// last holds the KPIs as of the last detection; nil until the first
+	// state is known, which is the baseline rather than a change.
+	last map[metrics.KPIKey]metrics.KPI
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan KPIEvent]struct{})}
+}
+
+// subscribe returns a channel receiving events until cancel is called,
+// the subscriber falls behind or the hub closes.
+func (h *eventHub) subscribe() (events chan KPIEvent, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events = make(chan KPIEvent, eventBuffer)
+	if h.closed {
+		close(events)
+		return events, func() {}
+	}
+	h.subscribers[events] = struct{}{}
+	return events, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[events]; ok {
+			delete(h.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// broadcast sends events to every subscriber, dropping subscribers that
+// cannot keep up.
+func (h *eventHub) broadcast(events []KPIEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for subscriber := range h.subscribers {
+		for _, event := range events {
+			select {
+			case subscriber <- event:
+				continue
+			default:
+			}
+			delete(h.subscribers, subscriber)
+			close(subscriber)
+			break
+		}
+	}
+}
+
+// close ends every stream so that graceful shutdown does not wait for
+// them.
+func (h *eventHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for subscriber := range h.subscribers {
+		delete(h.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// detect returns the changes from the last detected KPIs to kpis. When a
+// key occurs more than once, as after repeated ingestion, its last
+// occurrence is current.
+func (h *eventHub) detect(kpis []metrics.KPI, now time.Time) []KPIEvent {
+	current := make(map[metrics.KPIKey]metrics.KPI, len(kpis))
+	var keys []metrics.KPIKey
+	for _, kpi := range kpis {
+		if _, ok := current[kpi.Key]; !ok {
+			keys = append(keys, kpi.Key)
+		}
+		current[kpi.Key] = kpi
+	}
+	h.mu.Lock()
+	last := h.last
+	h.last = current
+	h.mu.Unlock()
+	if last == nil {
+		return nil
+	}
+
+	var events []KPIEvent
+	for _, key := range keys {
+		kpi := current[key]
+		previous, ok := last[key]
+		switch {
+		case !ok:
+			events = append(events, eventOf("add", kpi, now))
+		case previous.Value != kpi.Value || previous.Target != kpi.Target || previous.Status != kpi.Status:
+			event := eventOf("change", kpi, now)
+			event.Previous = &previous.Value
+			events = append(events, event)
+		}
+	}
+	for key, kpi := range last {
+		if _, ok := current[key]; !ok {
+			events = append(events, eventOf("remove", kpi, now))
+		}
+	}
+	return events
+}
+
+func eventOf(action string, kpi metrics.KPI, now time.Time) KPIEvent {
+	return KPIEvent{Action: action, Key: kpi.Key, Name: kpi.Name, Category: kpi.Category, Value: kpi.Value,
+		Target: kpi.Target, Unit: kpi.Unit, Status: kpi.Status, At: now}
+}
+
+// stateChanged runs after every change to the served state: it evaluates
+// alerts, drops the cached summary and publishes the KPI changes.
+// With Redis, only the instance delivering scheduled reports publishes,
+// and every instance relays what arrives on the channel, so viewers see
+// each change once.
+func (s *Server) stateChanged() {
+	s.evaluateAlerts()
+	s.mu.RLock()
+	kpis := s.served().GetKPIS()
+	s.mu.RUnlock()
+	events := s.events.detect(kpis, s.clock.Now())
+	if s.redis == nil {
+		s.events.broadcast(events)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redis.DefaultTimeout)
+	defer cancel()
+	if err := s.redis.client.Del(ctx, s.redis.summaryKey); err != nil {
+		s.logger.Printf("redis: drop cached summary: %v", err)
+	}
+	if len(events) == 0 || !s.runsReports() {
+		return
+	}
+	for _, event := range events {
+		data, _ := json.Marshal(event)
+		if err := s.redis.client.Publish(ctx, s.redis.channel, data); err != nil {
+			s.logger.Printf("redis: publish KPI change: %v", err)
+			return
+		}
+	}
+}
+
+// handleEvents streams KPI changes as server-sent events.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming is not supported"})
+		return
+	}
+	events, cancel := s.events.subscribe()
+	defer cancel()
+	s.telemetry.AddEventSubscribers(1)
+	defer s.telemetry.AddEventSubscribers(-1)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: kpi\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// RedisConfig connects the server to Redis, which caches the summary and
+// carries KPI changes between instances serving the same store.
+type RedisConfig struct {
+	// Addr is host:port; setting it enables Redis.
+	Addr     string `yaml:"addr"`
+	Username string `yaml:"username"`
+	// PasswordEnv names the environment variable holding the password.
+	PasswordEnv string `yaml:"password_env"`
+	DB          int    `yaml:"db"`
+	TLS         bool   `yaml:"tls"`
+	// Prefix starts every key and channel name; the tenant follows it.
+	Prefix string `yaml:"prefix"`
+	// CacheTTL bounds how long a cached summary is served, e.g. "1m".
+	CacheTTL string `yaml:"cache_ttl"`
+	// TLSConfig carries the trusted CAs and minimum version of the
+	// top-level http section.
+	TLSConfig *tls.Config `yaml:"-"`
+}
+
+// Defaults applied when a Redis setting is not configured.
+const (
+	DefaultRedisPrefix   = "secmetrics:"
+	DefaultRedisCacheTTL = time.Minute
+)
+
+// redisLink is the server's Redis client with its key names.
+type redisLink struct {
+	client     *redis.Client
+	summaryKey string
+	channel    string
+	cacheTTL   time.Duration
+}
+
+// newRedisLink validates cfg. It returns nil when Redis is not
+// configured.
+func newRedisLink(cfg RedisConfig, tenant string) (*redisLink, error) {
+	if cfg.Addr == "" {
+		return nil, nil
+	}
+	cacheTTL := DefaultRedisCacheTTL
+	if cfg.CacheTTL != "" {
+		var err error
+		if cacheTTL, err = time.ParseDuration(cfg.CacheTTL); err != nil || cacheTTL <= 0 {
+			return nil, fmt.Errorf("server redis cache_ttl: invalid duration %q", cfg.CacheTTL)
+		}
+	}
+	opts := redis.Options{Addr: cfg.Addr, Username: cfg.Username, DB: cfg.DB}
+	if cfg.PasswordEnv != "" {
+		opts.Password = secretenv.Get(cfg.PasswordEnv)
+		if opts.Password == "" {
+			return nil, fmt.Errorf("server redis: environment variable %s is not set", cfg.PasswordEnv)
+		}
+	}
+	if cfg.TLS {
+		opts.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.TLSConfig != nil {
+			opts.TLS = cfg.TLSConfig.Clone()
+		}
+	}
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	prefix += tenant + ":"
+	return &redisLink{client: redis.NewClient(opts), summaryKey: prefix + "summary", channel: prefix + "kpi-events", cacheTTL: cacheTTL}, nil
+}
+
+// cachedSummary returns the cached summary response, if any.
+func (s *Server) cachedSummary(ctx context.Context) ([]byte, bool) {
+	if s.redis == nil {
+		return nil, false
+	}
+	data, err := s.redis.client.Get(ctx, s.redis.summaryKey)
+	if err != nil && !errors.Is(err, redis.ErrNil) {
+		s.logger.Printf("redis: read cached summary: %v", err)
+	}
+	s.telemetry.ObserveSummaryCache(err == nil)
+	return data, err == nil
+}
+
+// cacheSummary caches a summary response.
+func (s *Server) cacheSummary(ctx context.Context, data []byte) {
+	if s.redis == nil {
+		return
+	}
+	if err := s.redis.client.Set(ctx, s.redis.summaryKey, data, s.redis.cacheTTL); err != nil {
+		s.logger.Printf("redis: cache summary: %v", err)
+	}
+}
+
+// relayEvents broadcasts the KPI changes published on Redis until ctx is
+// cancelled, resubscribing with backoff when the connection breaks.
+func (s *Server) relayEvents(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		subscription, err := s.redis.client.Subscribe(ctx, s.redis.channel)
+		if err == nil {
+			backoff = time.Second
+			stop := context.AfterFunc(ctx, func() { subscription.Close() })
+			err = s.receiveEvents(subscription)
+			stop()
+			subscription.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Printf("redis: KPI event subscription: %v; retrying in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// receiveEvents broadcasts the events arriving on subscription until it
+// fails.
+func (s *Server) receiveEvents(subscription *redis.Subscription) error {
+	for {
+		_, message, err := subscription.Receive()
+		if err != nil {
+			return err
+		}
+		var event KPIEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			s.logger.Printf("redis: invalid KPI event: %v", err)
+			continue
+		}
+		s.events.broadcast([]KPIEvent{event})
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestEventStream(t *testing.T) {
+	srv, err := New(Config{}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Value: 4, Target: 2}}})
+
+	api := httptest.NewServer(srv.Handler())
+	defer api.Close()
+	resp, err := http.Get(api.URL + "/api/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Content-Type = %s", resp.Header.Get("Content-Type"))
+	}
+	stream := bufio.NewReader(resp.Body)
+	next := func() KPIEvent {
+		t.Helper()
+		var event KPIEvent
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatal(err)
+				}
+				return event
+			}
+		}
+	}
+	if line, _ := stream.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("first line = %q", line)
+	}
+
+	// The state before the stream opened is the baseline, not a change.
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Value: 3, Target: 2}}})
+	if event := next(); event.Action != "change" || event.Key != metrics.KPI_MTTR || event.Value != 3 ||
+		event.Previous == nil || *event.Previous != 4 {
+		t.Errorf("event = %+v", event)
+	}
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTD, Value: 1, Target: 1}}})
+	if event := next(); event.Action != "add" || event.Key != metrics.KPI_MTTD {
+		t.Errorf("event = %+v", event)
+	}
+
+	// Shutdown ends the stream.
+	srv.events.close()
+	if _, err := io.ReadAll(stream); err != nil {
+		t.Errorf("stream did not end cleanly: %v", err)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := newEventHub()
+	events, cancel := hub.subscribe()
+	defer cancel()
+	burst := make([]KPIEvent, eventBuffer+1)
+	hub.broadcast(burst)
+	n := 0
+	for range events {
+		n++
+	}
+	if n != eventBuffer {
+		t.Errorf("received %d events before the stream closed, want %d", n, eventBuffer)
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// extractColumns are the columns of GET /api/extract: one row per KPI
+// sample, with the dimensions BI tools slice by.
+var extractColumns = []string{
+	"timestamp", "date", "tenant", "kpi_key", "kpi_name", "category", "subcategory",
+	"unit", "direction", "value", "target", "on_target", "archived",
+}
+
+// parseExtractTime parses an RFC 3339 time or a YYYY-MM-DD date.
+func parseExtractTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// handleExtract writes the KPI history as a flat CSV table for BI refresh
+// jobs. Samples older than the in-memory window come from the history
+// database when one is configured.
+func (s *Server) handleExtract(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to := time.Unix(0, 0), s.clock.Now().Add(time.Second)
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseExtractTime(value)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("%s: want an RFC 3339 time or YYYY-MM-DD date, got %q", bound.name, value)})
+			return
+		}
+		*bound.t = t
+	}
+	var keys map[metrics.KPIKey]bool
+	if value := query.Get("kpi"); value != "" {
+		keys = make(map[metrics.KPIKey]bool)
+		for _, key := range strings.Split(value, ",") {
+			keys[metrics.KPIKey(strings.TrimSpace(key))] = true
+		}
+	}
+
+	s.mu.RLock()
+	collector := s.served()
+	samples := append([]metrics.KPISample(nil), collector.GetHistory()...)
+	dims := collector.HistoryDimensions()
+	s.mu.RUnlock()
+
+	if s.store != nil && s.store.History() != nil {
+		older, err := s.store.History().Query(r.Context(), from, to)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("history database: %v", err)})
+			return
+		}
+		// Samples saved to the history database are also still in memory;
+		// Rows drops the duplicates.
+		samples = append(older, samples...)
+	}
+	selected := samples[:0]
+	for _, sample := range samples {
+		if !sample.Timestamp.Before(from) && sample.Timestamp.Before(to) && (keys == nil || keys[sample.Key]) {
+			selected = append(selected, sample)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="secmetrics-kpi-history.csv"`)
+	out := csv.NewWriter(w)
+	out.Write(extractColumns)
+	for _, row := range dims.Rows(selected) {
+		target, onTarget := "", ""
+		if row.HasTarget {
+			target = strconv.FormatFloat(row.Target, 'f', -1, 64)
+			onTarget = strconv.FormatBool(row.OnTarget)
+		}
+		out.Write([]string{
+			row.Timestamp.Format(time.RFC3339), row.Timestamp.Format(time.DateOnly), s.tenant, string(row.Key), row.Name,
+			row.Category, row.Subcategory, row.Unit, string(row.Direction), strconv.FormatFloat(row.Value, 'f', -1, 64),
+			target, onTarget, strconv.FormatBool(row.Archived),
+		})
+	}
+	out.Flush()
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestExtract(t *testing.T) {
+	srv, err := New(Config{Tenant: "acme"}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 9, 1, 8, 0, 0, 0, time.UTC)
+	srv.collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 3, Target: 2})
+	srv.collector.AddKPI(metrics.KPI{Key: "phish_rate", Name: "Phish Click Rate", Category: "Awareness", Unit: "%", Value: 4, Target: 5})
+	srv.collector.AddKPISample(metrics.KPISample{Key: metrics.KPI_MTTR, Value: 1.5, Timestamp: day})
+	srv.collector.AddKPISample(metrics.KPISample{Key: metrics.KPI_MTTR, Value: 3, Timestamp: day.AddDate(0, 0, 1)})
+	srv.collector.AddKPISample(metrics.KPISample{Key: "phish_rate", Value: 4, Timestamp: day})
+
+	extract := func(query string) [][]string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/extract"+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/extract%s = %d: %s", query, rec.Code, rec.Body)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("content type = %q", ct)
+		}
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	// Adding a KPI also samples it, so the range ends before now.
+	if rows := extract(""); len(rows) != 6 {
+		t.Errorf("all history = %d rows", len(rows))
+	}
+	rows := extract("?to=2026-10-01")
+	if strings.Join(rows[0], ",") != strings.Join(extractColumns, ",") || len(rows) != 4 {
+		t.Fatalf("rows = %q", rows)
+	}
+	want := "2026-09-01T08:00:00Z,2026-09-01,acme,mttr,Mean Time to Respond (MTTR),Response,,hours,lower_is_better,1.5,2,true,false"
+	if got := strings.Join(rows[1], ","); got != want {
+		t.Errorf("first row = %s, want %s", got, want)
+	}
+	if got := strings.Join(rows[2], ","); got != "2026-09-01T08:00:00Z,2026-09-01,acme,phish_rate,Phish Click Rate,Awareness,,%,higher_is_better,4,5,false,false" {
+		t.Errorf("second row = %s", got)
+	}
+
+	rows = extract("?from=2026-09-02&to=2026-10-01T00:00:00Z&kpi=mttr,coverage")
+	if len(rows) != 2 || rows[1][3] != "mttr" || rows[1][9] != "3" || rows[1][11] != "false" {
+		t.Errorf("filtered rows = %q", rows)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/extract?to=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid range status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,424 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/approval"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/state"
+)
+
+// GitOpsConfig configures reconciliation of KPI definitions, alert rules
+// and report schedules with desired-state documents kept in a directory or
+// a Git repository.
+type GitOpsConfig struct {
+	// Dir holds the documents, or the checkout of Repo.
+	Dir string `yaml:"dir"`
+	// Repo is cloned into Dir and pulled on every sync; without it Dir is
+	// read as is.
+	Repo string `yaml:"repo"`
+	// Branch of Repo to follow; empty follows the remote's default branch.
+	Branch string `yaml:"branch"`
+	// Path is the subdirectory of Dir holding the documents.
+	Path string `yaml:"path"`
+	// Interval between syncs, e.g. "1m".
+	Interval string `yaml:"interval"`
+}
+
+// DefaultGitOpsInterval is the sync interval when none is configured.
+const DefaultGitOpsInterval = time.Minute
+
+// DeliverFunc hands a rendered report to the configured delivery targets.
+type DeliverFunc func(ctx context.Context, filename string, content []byte) error
+
+// ArchiveFunc renders a scheduled report like a ReportFunc and archives the
+// data it was rendered from under id, so the report can be regenerated or
+// corrected later.
+type ArchiveFunc func(collector *metrics.MetricsCollector, reportType string, location *time.Location, id string) (string, error)
+
+// GitOpsStatus is the body of GET /api/gitops.
+type GitOpsStatus struct {
+	// Source is the repository or directory the documents are read from.
+	Source   string `json:"source"`
+	Revision string `json:"revision"`
+	// SyncedAt is the time of the last successful sync.
+	SyncedAt time.Time `json:"synced_at"`
+	// Error is the reason the last sync failed; the previous revision
+	// stays applied.
+	Error string `json:"error,omitempty"`
+	// Drift lists the differences from the desired state to the runtime
+	// state that reconciliation cannot remove, such as KPIs declared
+	// nowhere in the documents.
+	Drift []state.Change `json:"drift"`
+}
+
+// gitops tracks the desired state and the report schedules derived from it.
+type gitops struct {
+	dir, repo, branch, path string
+	interval                time.Duration
+
+	mu       sync.Mutex
+	desired  *state.State
+	revision string
+	syncedAt time.Time
+	err      error
+	lastRun  map[string]time.Time
+}
+
+// newGitOps validates cfg. It returns nil when no directory is configured.
+func newGitOps(cfg GitOpsConfig) (*gitops, error) {
+	if cfg.Dir == "" {
+		if cfg.Repo != "" {
+			return nil, fmt.Errorf("server gitops: repo requires dir for the checkout")
+		}
+		return nil, nil
+	}
+	interval := DefaultGitOpsInterval
+	if cfg.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(cfg.Interval); err != nil || interval <= 0 {
+			return nil, fmt.Errorf("server gitops interval: invalid duration %q", cfg.Interval)
+		}
+	}
+	return &gitops{
+		dir:      cfg.Dir,
+		repo:     cfg.Repo,
+		branch:   cfg.Branch,
+		path:     cfg.Path,
+		interval: interval,
+		lastRun:  make(map[string]time.Time),
+	}, nil
+}
+
+// source describes where the documents come from.
+func (g *gitops) source() string {
+	if g.repo != "" {
+		return g.repo
+	}
+	return g.dir
+}
+
+// fetch updates the checkout, if any, and loads the desired state and its
+// revision: the commit for a repository, a content digest otherwise.
+func (g *gitops) fetch(ctx context.Context) (*state.State, string, error) {
+	if g.repo != "" {
+		if err := g.pull(ctx); err != nil {
+			return nil, "", err
+		}
+	}
+	desired, err := state.LoadDir(filepath.Join(g.dir, g.path))
+	if err != nil {
+		return nil, "", err
+	}
+	if g.repo != "" {
+		revision, err := git(ctx, g.dir, "rev-parse", "HEAD")
+		return desired, revision, err
+	}
+	data, err := json.Marshal(desired)
+	if err != nil {
+		return nil, "", err
+	}
+	return desired, fmt.Sprintf("sha256:%x", sha256.Sum256(data)), nil
+}
+
+// pull clones the repository into the checkout directory, or moves an
+// existing checkout to the latest commit of the branch. Local changes to
+// the checkout are discarded.
+func (g *gitops) pull(ctx context.Context) error {
+	ref := g.branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := os.Stat(filepath.Join(g.dir, ".git")); err != nil {
+		args := []string{"clone", "--depth", "1"}
+		if g.branch != "" {
+			args = append(args, "--branch", g.branch)
+		}
+		_, err := git(ctx, "", append(args, "--", g.repo, g.dir)...)
+		return err
+	}
+	if _, err := git(ctx, g.dir, "fetch", "--depth", "1", "origin", ref); err != nil {
+		return err
+	}
+	_, err := git(ctx, g.dir, "reset", "--hard", "FETCH_HEAD")
+	return err
+}
+
+// git runs a git command in dir and returns its trimmed output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// SetDeliver sets how scheduled reports are delivered. Call it before Run.
+func (s *Server) SetDeliver(deliver DeliverFunc) {
+	s.deliver = deliver
+}
+
+// SetArchive sets how scheduled reports are rendered and archived, instead
+// of only rendered. Call it before Run.
+func (s *Server) SetArchive(archive ArchiveFunc) {
+	s.archive = archive
+}
+
+// syncGitOps loads the desired state and reports whether its revision
+// changed, in which case the caller refreshes the runtime state to apply
+// it. A failed sync keeps the previous revision applied.
+func (s *Server) syncGitOps(ctx context.Context) bool {
+	g := s.gitops
+	desired, revision, err := g.fetch(ctx)
+	s.telemetry.ObserveGitOpsSync(err)
+
+	g.mu.Lock()
+	if err != nil {
+		g.err = err
+		g.mu.Unlock()
+		s.logger.Printf("gitops sync %s: %v", g.source(), err)
+		return false
+	}
+	g.err = nil
+	g.syncedAt = s.clock.Now()
+	previous, unchanged := g.desired, revision == g.revision
+	g.mu.Unlock()
+	if unchanged {
+		return false
+	}
+
+	current := s.runtimeState(desired)
+	current.ReportSchedules = nil
+	if previous != nil {
+		current.ReportSchedules = previous.ReportSchedules
+	}
+	s.logger.Printf("gitops: applying %s from %s", revision, g.source())
+	for _, change := range state.Diff(current, desired) {
+		// KPIs missing from the documents are left alone; they are drift.
+		if change.Kind == "kpi" && change.Action == "remove" {
+			continue
+		}
+		if len(change.Fields) > 0 {
+			s.logger.Printf("gitops: %s %s %s (%s)", change.Action, change.Kind, change.Key, strings.Join(change.Fields, ", "))
+		} else {
+			s.logger.Printf("gitops: %s %s %s", change.Action, change.Kind, change.Key)
+		}
+	}
+
+	g.mu.Lock()
+	g.desired, g.revision = desired, revision
+	g.mu.Unlock()
+	return true
+}
+
+// applyDesiredDefinitions registers the desired KPI definitions with
+// collector, replacing built-in and source definitions of the same keys.
+func (s *Server) applyDesiredDefinitions(collector *metrics.MetricsCollector) {
+	desired := s.desiredState()
+	if desired == nil {
+		return
+	}
+	for _, def := range desired.Definitions() {
+		// Lifecycles change through audited transitions instead, see
+		// reconcileLifecycles.
+		def.Lifecycle = ""
+		if err := collector.SetKPIDefinition(def); err != nil {
+			s.logger.Printf("gitops: %v", err)
+		}
+	}
+}
+
+// reconcileArchived archives the KPIs the desired state marks archived.
+// Unarchiving is not supported, so the opposite remains drift.
+func (s *Server) reconcileArchived(collector *metrics.MetricsCollector) {
+	desired := s.desiredState()
+	if desired == nil {
+		return
+	}
+	for key, kpi := range desired.KPIs {
+		if kpi.Archived {
+			collector.ArchiveKPI(metrics.KPIKey(key))
+		}
+	}
+}
+
+// reconcileLifecycles moves the declared KPIs to the lifecycle the desired
+// state gives them, active by default, recording each transition in the
+// audit trail as made by GitOps.
+func (s *Server) reconcileLifecycles(collector *metrics.MetricsCollector) {
+	desired := s.desiredState()
+	if desired == nil {
+		return
+	}
+	for _, def := range desired.Definitions() {
+		lifecycle, _ := metrics.ParseLifecycle(string(def.Lifecycle))
+		if err := collector.TransitionKPI(def.Key, lifecycle, "gitops"); err != nil {
+			s.logger.Printf("gitops: %v", err)
+		}
+	}
+}
+
+// desiredState returns the applied desired state, or nil without GitOps
+// or before the first successful sync.
+func (s *Server) desiredState() *state.State {
+	if s.gitops == nil {
+		return nil
+	}
+	s.gitops.mu.Lock()
+	defer s.gitops.mu.Unlock()
+	return s.gitops.desired
+}
+
+// gitOpsStatus describes the last sync and the current drift.
+func (s *Server) gitOpsStatus() GitOpsStatus {
+	g := s.gitops
+	g.mu.Lock()
+	status := GitOpsStatus{Source: g.source(), Revision: g.revision, SyncedAt: g.syncedAt, Drift: []state.Change{}}
+	if g.err != nil {
+		status.Error = g.err.Error()
+	}
+	desired := g.desired
+	g.mu.Unlock()
+	if desired != nil {
+		if drift := state.Diff(desired, s.runtimeState(desired)); drift != nil {
+			status.Drift = drift
+		}
+	}
+	return status
+}
+
+// runtimeState returns the runtime state in terms comparable to desired:
+// report schedules always follow desired, KPIs without a desired target
+// keep whatever target their source sets, and a declared KPI that has not
+// been collected yet has no runtime target or archive status to compare.
+func (s *Server) runtimeState(desired *state.State) *state.State {
+	s.mu.RLock()
+	current := state.Build(s.served())
+	s.mu.RUnlock()
+	current.ReportSchedules = desired.ReportSchedules
+	for key, kpi := range current.KPIs {
+		want, ok := desired.KPIs[key]
+		switch {
+		case !ok:
+			continue
+		case kpi.Target == nil:
+			kpi.Target, kpi.Archived = want.Target, want.Archived
+		case want.Target == nil:
+			kpi.Target = nil
+		}
+		current.KPIs[key] = kpi
+	}
+	return current
+}
+
+// updateDrift refreshes the drift gauge.
+func (s *Server) updateDrift() {
+	s.telemetry.SetGitOpsDrift(len(s.gitOpsStatus().Drift))
+}
+
+// runReports renders and delivers the scheduled reports that are due. A
+// schedule first becomes due one interval after it is applied, so restarts
+// do not deliver every report again.
+func (s *Server) runReports(ctx context.Context) {
+	desired := s.desiredState()
+	if desired == nil {
+		return
+	}
+	now := s.clock.Now()
+	g := s.gitops
+	var due []string
+	g.mu.Lock()
+	for name := range g.lastRun {
+		if _, ok := desired.ReportSchedules[name]; !ok {
+			delete(g.lastRun, name)
+		}
+	}
+	for name, schedule := range desired.ReportSchedules {
+		interval, _ := time.ParseDuration(schedule.Interval)
+		last, ok := g.lastRun[name]
+		switch {
+		case !ok:
+			g.lastRun[name] = now
+		case now.Sub(last) >= interval:
+			g.lastRun[name] = now
+			due = append(due, name)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, name := range due {
+		if err := s.runReport(ctx, name, desired.ReportSchedules[name], now); err != nil {
+			s.logger.Printf("report schedule %s: %v", name, err)
+		}
+	}
+}
+
+// runReport renders a scheduled report and delivers it, or drafts it for
+// approval when approval is required.
+func (s *Server) runReport(ctx context.Context, name string, schedule state.ReportSchedule, now time.Time) error {
+	if s.deliver == nil {
+		return fmt.Errorf("no delivery targets configured")
+	}
+	// The desired state was validated, so the time zone loads
+	var location *time.Location
+	if schedule.TimeZone != "" {
+		location, _ = time.LoadLocation(schedule.TimeZone)
+	}
+	reportType := schedule.Type
+	id := fmt.Sprintf("%s-%s", name, now.UTC().Format("20060102-150405"))
+	start := time.Now()
+	s.mu.RLock()
+	var content string
+	var err error
+	if s.archive != nil {
+		content, err = s.archive(s.served(), reportType, location, id)
+	} else {
+		content, err = s.render(s.served(), reportType, location)
+	}
+	s.mu.RUnlock()
+	s.telemetry.ObserveReport(reportType, time.Since(start))
+	if err != nil {
+		return err
+	}
+	ext := "txt"
+	switch reportType {
+	case "html":
+		ext = "html"
+	case "markdown", "onepager", "targets":
+		ext = "md"
+	}
+	filename := id + "." + ext
+	if s.approval.Required {
+		report := approval.Report{ID: id, Type: reportType, Schedule: name, Filename: filename, Content: []byte(content), CreatedAt: now}
+		if err := s.updateApproval(func(state *approval.State) error { return state.Draft(report) }); err != nil {
+			return err
+		}
+		s.logger.Printf("report schedule %s: drafted %s for approval", name, id)
+	} else {
+		if err := s.deliver(ctx, filename, []byte(content)); err != nil {
+			return err
+		}
+		s.logger.Printf("report schedule %s: delivered %s", name, filename)
+	}
+	s.publishEvent(EventReportGenerated, ReportGenerated{ReportType: reportType, Schedule: name, Filename: filename, Bytes: len(content)})
+	return nil
+}
+
+func (s *Server) handleGitOps(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.gitOpsStatus())
+}
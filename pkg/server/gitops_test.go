@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+const desiredMTTR = `{"format_version": 1,
+	"kpis": {"mttr": {"name": "Mean Time to Respond", "unit": "hours", "category": "Response",
+		"direction": "lower_is_better", "target": 2, "percentiles": []}},
+	"alert_rules": {"mttr_critical": {"kpi": "mttr", "severity": "critical", "operator": ">", "threshold": 6}},
+	"report_schedules": {"daily": {"type": "executive", "interval": "24h", "time_zone": "Europe/Berlin"}}}`
+
+func newGitOpsServer(t *testing.T, cfg GitOpsConfig) *Server {
+	t.Helper()
+	source := Source{Name: "test", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 3, Target: 4})
+		collector.AddKPI(metrics.KPI{Key: "unmanaged", Value: 1, Target: 1})
+		return nil
+	}}
+	render := func(collector *metrics.MetricsCollector, reportType string, location *time.Location) (string, error) {
+		if location != nil {
+			return reportType + " report in " + location.String(), nil
+		}
+		return reportType + " report", nil
+	}
+	srv, err := New(Config{GitOps: cfg}, []Source{source}, nil, render)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+	return srv
+}
+
+func TestGitOpsReconcilesDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mttr.json"), []byte(desiredMTTR), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := newGitOpsServer(t, GitOpsConfig{Dir: dir})
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	srv.SetClock(clk)
+	var delivered []string
+	srv.SetDeliver(func(ctx context.Context, filename string, content []byte) error {
+		delivered = append(delivered, filename+": "+string(content))
+		return nil
+	})
+
+	ctx := context.Background()
+	if !srv.syncGitOps(ctx) {
+		t.Fatal("first sync did not apply the desired state")
+	}
+	srv.CollectOnce(ctx)
+
+	def, _ := srv.collector.GetKPIDefinition(metrics.KPI_MTTR)
+	if def.Name != "Mean Time to Respond" || def.CriticalThreshold == nil || *def.CriticalThreshold != 6 || def.WarningThreshold != nil {
+		t.Errorf("definition not reconciled: %+v", def)
+	}
+	kpi := srv.collector.GetKPI(metrics.KPI_MTTR)
+	if kpi.Target != 2 || kpi.Status != "BELOW_TARGET" {
+		t.Errorf("KPI target %v status %s, want the desired target 2", kpi.Target, kpi.Status)
+	}
+	if srv.syncGitOps(ctx) {
+		t.Error("unchanged documents reported as a new revision")
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/gitops", nil))
+	var status GitOpsStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatalf("GET /api/gitops: %d %s", rec.Code, rec.Body)
+	}
+	if !strings.HasPrefix(status.Revision, "sha256:") || status.Error != "" {
+		t.Errorf("status = %+v", status)
+	}
+	drifted := make(map[string]string)
+	for _, change := range status.Drift {
+		drifted[change.Key] = change.Action
+	}
+	if drifted["unmanaged"] != "add" || drifted["mttr"] != "" {
+		t.Errorf("drift = %+v, want only undeclared KPIs", status.Drift)
+	}
+
+	srv.runReports(ctx)
+	clk.Advance(24 * time.Hour)
+	srv.runReports(ctx)
+	if len(delivered) != 1 || delivered[0] != "daily-20261002-090000.txt: executive report in Europe/Berlin" {
+		t.Errorf("delivered %q", delivered)
+	}
+
+	// Readers pick their own time zone; unknown zones are rejected
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?type=executive&tz=America/New_York", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "executive report in America/New_York" {
+		t.Errorf("GET /report with tz: %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/report?type=executive&tz=Nowhere", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /report with an unknown tz: %d, want 400", rec.Code)
+	}
+
+	// A broken document keeps the previous revision applied.
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if srv.syncGitOps(ctx) {
+		t.Error("broken documents applied")
+	}
+	if status := srv.gitOpsStatus(); status.Error == "" || status.Revision == "" {
+		t.Errorf("status after failed sync = %+v", status)
+	}
+}
+
+func TestGitOpsPullsRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	run("init", "--quiet", "--initial-branch=main")
+	if err := os.MkdirAll(filepath.Join(repo, "secmetrics"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "secmetrics", "mttr.json"), []byte(desiredMTTR), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", ".")
+	run("commit", "--quiet", "-m", "Add MTTR")
+
+	checkout := filepath.Join(t.TempDir(), "checkout")
+	srv := newGitOpsServer(t, GitOpsConfig{Dir: checkout, Repo: "file://" + repo, Branch: "main", Path: "secmetrics"})
+	ctx := context.Background()
+	if !srv.syncGitOps(ctx) {
+		t.Fatalf("clone not applied: %+v", srv.gitOpsStatus())
+	}
+	first := srv.gitOpsStatus().Revision
+
+	updated := strings.Replace(desiredMTTR, `"target": 2`, `"target": 1`, 1)
+	if err := os.WriteFile(filepath.Join(repo, "secmetrics", "mttr.json"), []byte(updated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("commit", "--quiet", "-am", "Tighten MTTR")
+	if !srv.syncGitOps(ctx) {
+		t.Fatalf("new commit not applied: %+v", srv.gitOpsStatus())
+	}
+	if srv.gitOpsStatus().Revision == first {
+		t.Error("revision did not change")
+	}
+	if target := *srv.desiredState().KPIs["mttr"].Target; target != 1 {
+		t.Errorf("desired target %v, want 1", target)
+	}
+}
+
+func TestGitOpsKPILifecycle(t *testing.T) {
+	dir := t.TempDir()
+	write := func(lifecycle string) {
+		t.Helper()
+		doc := strings.Replace(desiredMTTR, `"percentiles": []`, `"percentiles": [], "lifecycle": "`+lifecycle+`"`, 1)
+		if err := os.WriteFile(filepath.Join(dir, "mttr.json"), []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("draft")
+	srv := newGitOpsServer(t, GitOpsConfig{Dir: dir})
+	ctx := context.Background()
+	srv.syncGitOps(ctx)
+	srv.CollectOnce(ctx)
+
+	if lifecycle := srv.collector.KPILifecycle(metrics.KPI_MTTR); lifecycle != metrics.LifecycleDraft {
+		t.Fatalf("lifecycle = %s, want draft", lifecycle)
+	}
+	for _, change := range srv.gitOpsStatus().Drift {
+		if change.Key == "mttr" {
+			t.Errorf("drift on a reconciled lifecycle: %+v", change)
+		}
+	}
+
+	write("deprecated")
+	srv.syncGitOps(ctx)
+	srv.CollectOnce(ctx)
+	if srv.collector.GetKPI(metrics.KPI_MTTR) != nil {
+		t.Error("deprecated KPI still served")
+	}
+	audit := srv.collector.GetAuditTrail()
+	if len(audit) != 2 || audit[0].To != "draft" || audit[1].From != "draft" || audit[1].To != "deprecated" || audit[1].Actor != "gitops" {
+		t.Errorf("audit trail = %+v", audit)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/audit", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"To":"deprecated"`) {
+		t.Errorf("GET /api/audit: %d %s", rec.Code, rec.Body)
+	}
+}
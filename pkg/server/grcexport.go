@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+
+	"github.com/hallucinaut/secmetrics/pkg/grc"
+)
+
+// grcSystems are the GRC systems of record, in telemetry order.
+var grcSystems = []string{"servicenow", "archer"}
+
+// pushGRCRecords pushes the KPI values and risk scores being served to the
+// GRC systems of record. Only the instance delivering scheduled reports
+// pushes, so each system receives one set of records per interval.
+func (s *Server) pushGRCRecords(ctx context.Context) {
+	if s.grcExporter == nil || !s.runsReports() {
+		return
+	}
+	now := s.clock.Now()
+	s.mu.RLock()
+	records := grc.Records(s.served(), now)
+	s.mu.RUnlock()
+
+	for _, result := range s.grcExporter.Push(ctx, records) {
+		s.telemetry.ObserveGRCExport(result.System, result.Records, result.Err != nil)
+		if result.Err != nil {
+			s.logger.Printf("grc export: %s: %v", result.System, result.Err)
+			continue
+		}
+		s.logger.Printf("grc export: %s accepted %d records", result.System, result.Records)
+	}
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// IngestConfig configures the POST /ingest queue.
+type IngestConfig struct {
+	// QueueSize bounds the number of pending batches.
+	QueueSize int `yaml:"queue_size"`
+	// Workers is the number of goroutines applying batches.
+	Workers int `yaml:"workers"`
+	// RetryAfter is the Retry-After value, in seconds, sent with 429.
+	RetryAfter int `yaml:"retry_after"`
+	// MaxBodyBytes limits the size of a request body.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+}
+
+// Ingest defaults applied when a setting is not configured.
+const (
+	DefaultIngestQueueSize    = 100
+	DefaultIngestWorkers      = 2
+	DefaultIngestRetryAfter   = 5
+	DefaultIngestMaxBodyBytes = 1 << 20
+)
+
+// IngestBatch is the body accepted by POST /ingest.
+type IngestBatch struct {
+	Metrics   []metrics.SecurityMetric `json:"metrics"`
+	KPIs      []metrics.KPI            `json:"kpis"`
+	Incidents []metrics.Incident       `json:"incidents"`
+	Alerts    []metrics.Alert          `json:"alerts"`
+}
+
+// ingestQueue is a bounded queue drained by a fixed worker pool.
+type ingestQueue struct {
+	config IngestConfig
+	queue  chan IngestBatch
+	apply  func(IngestBatch)
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newIngestQueue creates a queue and starts its workers.
+func newIngestQueue(cfg IngestConfig, apply func(IngestBatch)) *ingestQueue {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultIngestQueueSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultIngestWorkers
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = DefaultIngestRetryAfter
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultIngestMaxBodyBytes
+	}
+
+	q := &ingestQueue{
+		config: cfg,
+		queue:  make(chan IngestBatch, cfg.QueueSize),
+		apply:  apply,
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for batch := range q.queue {
+				q.apply(batch)
+			}
+		}()
+	}
+	return q
+}
+
+// offer enqueues batch without blocking, reporting whether it was accepted.
+func (q *ingestQueue) offer(batch IngestBatch) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.queue <- batch:
+		return true
+	default:
+		return false
+	}
+}
+
+// depth returns the number of pending batches.
+func (q *ingestQueue) depth() int {
+	return len(q.queue)
+}
+
+// close stops accepting batches and waits for queued ones to be applied.
+func (q *ingestQueue) close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if s.readOnly {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "server is read-only"})
+		return
+	}
+	if s.following() {
+		s.refuseFollower(w)
+		return
+	}
+
+	var batch IngestBatch
+	body := http.MaxBytesReader(w, r.Body, s.ingest.config.MaxBodyBytes)
+	if err := json.NewDecoder(body).Decode(&batch); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+	if len(batch.Metrics) == 0 && len(batch.KPIs) == 0 && len(batch.Incidents) == 0 && len(batch.Alerts) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "batch contains no metrics, kpis, incidents or alerts"})
+		return
+	}
+	if err := validateEvents(batch); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	s.mu.RLock()
+	err := s.validateBatch(batch)
+	s.mu.RUnlock()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if !s.ingest.offer(batch) {
+		s.telemetry.ObserveIngestRejected()
+		w.Header().Set("Retry-After", strconv.Itoa(s.ingest.config.RetryAfter))
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "ingest queue is full"})
+		return
+	}
+	s.telemetry.SetIngestQueueDepth(s.ingest.depth())
+	writeJSON(w, http.StatusAccepted, map[string]int{
+		"metrics":   len(batch.Metrics),
+		"kpis":      len(batch.KPIs),
+		"incidents": len(batch.Incidents),
+		"alerts":    len(batch.Alerts),
+	})
+}
+
+// validateBatch checks the metrics and KPIs in batch against the
+// collector's API version and taxonomy. The caller holds s.mu.
+func (s *Server) validateBatch(batch IngestBatch) error {
+	for _, metric := range batch.Metrics {
+		if err := s.collector.CheckMetric(metric); err != nil {
+			return err
+		}
+	}
+	for _, kpi := range batch.KPIs {
+		if err := s.collector.CheckKPI(kpi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ingestedMetricRetention is how long a pushed metric is carried over
+// between collections after its timestamp. Pushers that still report the
+// metric push it again well within it.
+const ingestedMetricRetention = 7 * 24 * time.Hour
+
+// ingestedMetricKey identifies a pushed metric, so a later push of the same
+// metric replaces the earlier one.
+func ingestedMetricKey(metric metrics.SecurityMetric) string {
+	if metric.ID != "" {
+		return metric.ID
+	}
+	return metric.Name
+}
+
+// trimIngested drops the pushed metrics older than ingestedMetricRetention.
+// The caller holds s.mu for writing.
+func (s *Server) trimIngested(now time.Time) {
+	cutoff := now.Add(-ingestedMetricRetention)
+	for key, metric := range s.ingestedMetrics {
+		if metric.Timestamp.Before(cutoff) {
+			delete(s.ingestedMetrics, key)
+		}
+	}
+}
+
+// carriedIngested returns the pushed metrics within the retention at now,
+// oldest first. The caller holds s.mu.
+func (s *Server) carriedIngested(now time.Time) []metrics.SecurityMetric {
+	cutoff := now.Add(-ingestedMetricRetention)
+	carried := make([]metrics.SecurityMetric, 0, len(s.ingestedMetrics))
+	for _, metric := range s.ingestedMetrics {
+		if !metric.Timestamp.Before(cutoff) {
+			carried = append(carried, metric)
+		}
+	}
+	sort.Slice(carried, func(i, j int) bool {
+		if !carried[i].Timestamp.Equal(carried[j].Timestamp) {
+			return carried[i].Timestamp.Before(carried[j].Timestamp)
+		}
+		return ingestedMetricKey(carried[i]) < ingestedMetricKey(carried[j])
+	})
+	return carried
+}
+
+// validateEvents checks the incidents and alerts in batch up front, since
+// batches are applied asynchronously.
+func validateEvents(batch IngestBatch) error {
+	scratch := metrics.NewMetricsCollector()
+	for _, incident := range batch.Incidents {
+		if err := scratch.AddIncident(incident); err != nil {
+			return err
+		}
+	}
+	for _, alert := range batch.Alerts {
+		if err := scratch.AddAlert(alert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyIngest adds an ingested batch to the current state and remembers it
+// so it survives the next scheduled collection.
+func (s *Server) applyIngest(batch IngestBatch) {
+	// Each batch is a run of its own, traced to the ingest endpoint
+	// unless the pusher names its own source.
+	provenance := metrics.Provenance{Source: "ingest", RunID: metrics.NewRunID(s.clock.Now())}
+	for i := range batch.Metrics {
+		batch.Metrics[i].Provenance = batch.Metrics[i].Provenance.WithDefaults(provenance)
+	}
+
+	now := s.clock.Now().UTC()
+	for i := range batch.Metrics {
+		if batch.Metrics[i].Timestamp.IsZero() {
+			batch.Metrics[i].Timestamp = now
+		}
+	}
+
+	s.mu.Lock()
+	previousAlerts := s.collector.GetAlerts()
+	for _, metric := range batch.Metrics {
+		s.collector.AddMetric(metric)
+		s.ingestedMetrics[ingestedMetricKey(metric)] = metric
+	}
+	s.trimIngested(now)
+	for _, kpi := range batch.KPIs {
+		s.collector.AddKPI(kpi)
+		s.ingestedKPIs[kpi.Key] = kpi
+	}
+	// Incidents and alerts carry over between collections with the rest
+	// of the collector's event state.
+	for _, incident := range batch.Incidents {
+		s.collector.AddIncident(incident)
+	}
+	for _, alert := range batch.Alerts {
+		s.collector.AddAlert(alert)
+	}
+	fired := firedAlerts(previousAlerts, s.collector.GetAlerts())
+	s.mu.Unlock()
+	if len(batch.Metrics) > 0 || len(batch.KPIs) > 0 {
+		s.publishEvent(EventMetricIngested, MetricsIngested{Source: "ingest", Metrics: batch.Metrics, KPIs: batch.KPIs})
+	}
+	for _, alert := range fired {
+		s.publishEvent(EventAlertFired, alert)
+	}
+	if s.shards != nil {
+		s.rebuildView()
+	} else {
+		s.stateChanged()
+	}
+
+	s.telemetry.ObserveIngested(len(batch.Metrics) + len(batch.KPIs) + len(batch.Incidents) + len(batch.Alerts))
+	s.telemetry.SetIngestQueueDepth(s.ingest.depth())
+}
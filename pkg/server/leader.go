@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/kube"
+)
+
+// LeaderElectionConfig makes replicas sharing a store elect a leader
+// through a Kubernetes Lease. Only the leader collects, accepts ingestion,
+// delivers scheduled reports and writes the store; the others reload the
+// store on every interval and serve it.
+type LeaderElectionConfig struct {
+	// Lease names the Lease object; setting it enables leader election.
+	Lease string `yaml:"lease"`
+	// Namespace of the Lease; defaults to the pod's namespace.
+	Namespace string `yaml:"namespace"`
+	// Identity of this replica; defaults to the host name, which is the
+	// pod name on Kubernetes.
+	Identity string `yaml:"identity"`
+	// LeaseDuration is how long a claim lasts without renewal, e.g.
+	// "15s". Leaders renew every third of it.
+	LeaseDuration string `yaml:"lease_duration"`
+}
+
+// DefaultLeaseDuration is the lease duration when none is configured.
+const DefaultLeaseDuration = 15 * time.Second
+
+// leaseClient is the part of the Kubernetes API the elector uses.
+type leaseClient interface {
+	GetLease(ctx context.Context, namespace, name string) (*kube.Lease, error)
+	CreateLease(ctx context.Context, lease *kube.Lease) (*kube.Lease, error)
+	UpdateLease(ctx context.Context, lease *kube.Lease) (*kube.Lease, error)
+}
+
+// elector holds or waits for a Lease.
+type elector struct {
+	client    leaseClient
+	namespace string
+	name      string
+	identity  string
+	duration  time.Duration
+	logger    *log.Logger
+
+	leader atomic.Bool
+	// renewed is when the leader last renewed; only the Run loop uses it.
+	renewed time.Time
+}
+
+// newElector validates cfg and connects to the cluster's API server. It
+// returns nil when leader election is not configured.
+func newElector(cfg LeaderElectionConfig) (*elector, error) {
+	if cfg.Lease == "" {
+		return nil, nil
+	}
+	duration := DefaultLeaseDuration
+	if cfg.LeaseDuration != "" {
+		var err error
+		if duration, err = time.ParseDuration(cfg.LeaseDuration); err != nil || duration < time.Second {
+			return nil, fmt.Errorf("server leader_election lease_duration: invalid duration %q (minimum 1s)", cfg.LeaseDuration)
+		}
+	}
+	identity := cfg.Identity
+	if identity == "" {
+		var err error
+		if identity, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("server leader_election: %w", err)
+		}
+	}
+	client, namespace, err := kube.InCluster()
+	if err != nil {
+		return nil, fmt.Errorf("server leader_election: %w", err)
+	}
+	if cfg.Namespace != "" {
+		namespace = cfg.Namespace
+	}
+	return &elector{client: client, namespace: namespace, name: cfg.Lease, identity: identity, duration: duration}, nil
+}
+
+// retryPeriod is the interval between attempts to acquire or renew.
+func (e *elector) retryPeriod() time.Duration {
+	return e.duration / 3
+}
+
+// isLeader reports whether this replica holds the lease.
+func (e *elector) isLeader() bool {
+	return e.leader.Load()
+}
+
+// tryAcquire acquires the lease if it is free or expired, or renews it if
+// held, and reports whether this replica became leader. A leader that
+// cannot renew steps down before its claim could lapse, so two replicas
+// never both act as leader.
+func (e *elector) tryAcquire(ctx context.Context, now time.Time) (acquired bool) {
+	held, err := e.acquire(ctx, now)
+	if err != nil && !errors.Is(err, kube.ErrConflict) {
+		e.logger.Printf("leader election: %v", err)
+	}
+	wasLeader := e.isLeader()
+	switch {
+	case held:
+		e.renewed = now
+	case wasLeader && err != nil && now.Sub(e.renewed) < e.duration*2/3:
+		// Keep leading through transient errors while the claim is fresh.
+		held = true
+	}
+	e.leader.Store(held)
+	if held && !wasLeader {
+		e.logger.Printf("leader election: %s acquired lease %s/%s", e.identity, e.namespace, e.name)
+	}
+	if !held && wasLeader {
+		e.logger.Printf("leader election: %s lost lease %s/%s", e.identity, e.namespace, e.name)
+	}
+	return held && !wasLeader
+}
+
+// acquire reports whether this replica holds the lease after one attempt.
+func (e *elector) acquire(ctx context.Context, now time.Time) (bool, error) {
+	lease, err := e.client.GetLease(ctx, e.namespace, e.name)
+	if errors.Is(err, kube.ErrNotFound) {
+		_, err = e.client.CreateLease(ctx, &kube.Lease{
+			Name: e.name, Namespace: e.namespace, Holder: e.identity,
+			Duration: e.duration, AcquireTime: now, RenewTime: now,
+		})
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case lease.Holder == e.identity:
+	case lease.Expired(now):
+		lease.Holder = e.identity
+		lease.AcquireTime = now
+		lease.Transitions++
+	default:
+		return false, nil
+	}
+	lease.Duration = e.duration
+	lease.RenewTime = now
+	_, err = e.client.UpdateLease(ctx, lease)
+	return err == nil, err
+}
+
+// release gives up the lease on shutdown so another replica can take over
+// without waiting for it to expire.
+func (e *elector) release(ctx context.Context) {
+	if !e.isLeader() {
+		return
+	}
+	e.leader.Store(false)
+	lease, err := e.client.GetLease(ctx, e.namespace, e.name)
+	if err == nil && lease.Holder == e.identity {
+		lease.Holder = ""
+		_, err = e.client.UpdateLease(ctx, lease)
+	}
+	if err != nil {
+		e.logger.Printf("leader election: release lease: %v", err)
+	}
+}
+
+// following reports whether another replica leads, so this one must not
+// collect, accept ingestion or write the store.
+func (s *Server) following() bool {
+	return s.elector != nil && !s.elector.isLeader()
+}
+
+// refuseFollower answers requests that only the leader can serve.
+func (s *Server) refuseFollower(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(s.elector.retryPeriod()/time.Second)+1))
+	writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "this replica is not the leader"})
+}
+
+// handleHealthz reports that the process is alive.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// handleReadyz reports whether the server has loaded its state and is not
+// shutting down. Followers are ready: they serve the shared store.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	body := map[string]interface{}{"status": "ready"}
+	if s.elector != nil {
+		body["leader"] = s.elector.isLeader()
+	}
+	if !s.ready.Load() {
+		body["status"] = "not ready"
+		writeJSON(w, http.StatusServiceUnavailable, body)
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
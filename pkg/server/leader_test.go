@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/kube"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// fakeLeases is an in-memory lease API with optimistic concurrency.
+type fakeLeases struct {
+	mu      sync.Mutex
+	lease   *kube.Lease
+	version int
+	fail    bool
+}
+
+func (f *fakeLeases) GetLease(ctx context.Context, namespace, name string) (*kube.Lease, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if f.lease == nil {
+		return nil, kube.ErrNotFound
+	}
+	lease := *f.lease
+	return &lease, nil
+}
+
+func (f *fakeLeases) CreateLease(ctx context.Context, lease *kube.Lease) (*kube.Lease, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lease != nil {
+		return nil, kube.ErrConflict
+	}
+	return f.store(lease), nil
+}
+
+func (f *fakeLeases) UpdateLease(ctx context.Context, lease *kube.Lease) (*kube.Lease, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if f.lease == nil || lease.ResourceVersion != f.lease.ResourceVersion {
+		return nil, kube.ErrConflict
+	}
+	return f.store(lease), nil
+}
+
+func (f *fakeLeases) store(lease *kube.Lease) *kube.Lease {
+	f.version++
+	stored := *lease
+	stored.ResourceVersion = strconv.Itoa(f.version)
+	f.lease = &stored
+	return &stored
+}
+
+func newTestElector(client leaseClient, identity string) *elector {
+	return &elector{client: client, namespace: "secmetrics", name: "secmetrics", identity: identity,
+		duration: 15 * time.Second, logger: log.New(io.Discard, "", 0)}
+}
+
+func TestLeaderElection(t *testing.T) {
+	leases := &fakeLeases{}
+	a, b := newTestElector(leases, "a"), newTestElector(leases, "b")
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	if !a.tryAcquire(context.Background(), now) || !a.isLeader() {
+		t.Fatal("a did not acquire the free lease")
+	}
+	if b.tryAcquire(context.Background(), now) || b.isLeader() {
+		t.Fatal("b acquired a lease held by a")
+	}
+
+	// a renews; b waits.
+	now = now.Add(10 * time.Second)
+	a.tryAcquire(context.Background(), now)
+	b.tryAcquire(context.Background(), now)
+	if !a.isLeader() || b.isLeader() || leases.lease.Holder != "a" {
+		t.Fatalf("after renewal: a %v, b %v, holder %s", a.isLeader(), b.isLeader(), leases.lease.Holder)
+	}
+
+	// The API fails: a keeps leading while its claim is fresh, then steps
+	// down before the claim could lapse.
+	leases.fail = true
+	a.tryAcquire(context.Background(), now.Add(5*time.Second))
+	if !a.isLeader() {
+		t.Error("a stepped down on a transient error")
+	}
+	a.tryAcquire(context.Background(), now.Add(11*time.Second))
+	if a.isLeader() {
+		t.Error("a still leads although it could not renew")
+	}
+	leases.fail = false
+
+	// a's claim expires and b takes over.
+	now = now.Add(16 * time.Second)
+	if !b.tryAcquire(context.Background(), now) {
+		t.Fatal("b did not take over the expired lease")
+	}
+	if leases.lease.Holder != "b" || leases.lease.Transitions != 1 {
+		t.Errorf("lease = %+v", leases.lease)
+	}
+
+	b.release(context.Background())
+	if b.isLeader() || leases.lease.Holder != "" {
+		t.Errorf("after release: b %v, holder %q", b.isLeader(), leases.lease.Holder)
+	}
+	if !a.tryAcquire(context.Background(), now) {
+		t.Error("a did not acquire the released lease")
+	}
+}
+
+func TestFollowerServesProbesButRefusesWrites(t *testing.T) {
+	metricsStore := store.NewFileStore(filepath.Join(t.TempDir(), "store.json"), nil)
+	srv, err := New(Config{}, nil, metricsStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leases := &fakeLeases{}
+	newTestElector(leases, "other").tryAcquire(context.Background(), time.Now())
+	srv.elector = newTestElector(leases, "self")
+	handler := srv.Handler()
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"GET", "/healthz", "", http.StatusOK},
+		{"GET", "/readyz", "", http.StatusServiceUnavailable},
+		{"POST", "/ingest", `{"kpis":[{"key":"mttr","value":2}]}`, http.StatusServiceUnavailable},
+		{"POST", "/api/collect", "", http.StatusServiceUnavailable},
+		{"GET", "/api/kpis", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: %d %s, want %d", tt.method, tt.path, rec.Code, rec.Body, tt.want)
+		}
+	}
+
+	srv.ready.Store(true)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"leader":false`) {
+		t.Errorf("GET /readyz when ready: %d %s", rec.Code, rec.Body)
+	}
+}
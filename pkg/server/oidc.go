@@ -0,0 +1,352 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/oidc"
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+)
+
+// OIDCConfig configures single sign-on through an OpenID Connect provider
+// such as Okta, Azure AD or Keycloak.
+type OIDCConfig struct {
+	// Issuer is the provider's issuer URL; setting it enables single
+	// sign-on.
+	Issuer   string `yaml:"issuer"`
+	ClientID string `yaml:"client_id"`
+	// ClientSecret is the client secret; ClientSecretEnv names an
+	// environment variable holding it instead. Public clients using only
+	// PKCE leave both empty.
+	ClientSecret    string `yaml:"client_secret"`
+	ClientSecretEnv string `yaml:"client_secret_env"`
+	// RedirectURL is this server's /auth/callback URL as registered with
+	// the provider.
+	RedirectURL string   `yaml:"redirect_url"`
+	Scopes      []string `yaml:"scopes"`
+	// GroupsClaim names the ID token claim listing the user's groups.
+	GroupsClaim string `yaml:"groups_claim"`
+	// Roles maps groups to roles. A user in several groups gets the most
+	// privileged role; a user in none is refused.
+	Roles map[string]Role `yaml:"roles"`
+	// Tenants maps groups to the tenants their members may access. Users
+	// in no group listed here may access every tenant.
+	Tenants map[string][]string `yaml:"tenants"`
+	// SessionTTL is how long a login lasts, e.g. "8h".
+	SessionTTL string `yaml:"session_ttl"`
+	// Client makes requests to the provider; it is set by the caller
+	// rather than from the config file.
+	Client *http.Client `yaml:"-"`
+}
+
+// Single sign-on defaults applied when a setting is not configured.
+const (
+	DefaultOIDCGroupsClaim = "groups"
+	DefaultSessionTTL      = 8 * time.Hour
+)
+
+// DefaultOIDCScopes are requested when no scopes are configured.
+var DefaultOIDCScopes = []string{"openid", "profile", "email", "groups"}
+
+// sessionCookie names the cookie holding a browser's session token.
+const sessionCookie = "secmetrics_session"
+
+// loginTimeout bounds how long a login may take at the provider.
+const loginTimeout = 10 * time.Minute
+
+// maxPendingLogins bounds the logins awaiting a callback, so unauthenticated
+// clients cannot grow the server's memory.
+const maxPendingLogins = 10000
+
+// sso runs the login flow and maps provider groups to identities.
+type sso struct {
+	provider    *oidc.Provider
+	groupsClaim string
+	roles       map[string]Role
+	tenants     map[string][]string
+	secure      bool
+	sessions    *sessionStore
+}
+
+// newSSO validates cfg and creates the login flow.
+func newSSO(cfg OIDCConfig) (*sso, error) {
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("server auth oidc: client_id and redirect_url are required")
+	}
+	if len(cfg.Roles) == 0 {
+		return nil, errors.New("server auth oidc: roles must map at least one group to a role")
+	}
+	for group, role := range cfg.Roles {
+		if role.rank() == 0 {
+			return nil, fmt.Errorf("server auth oidc: group %s: unknown role %q (want viewer, analyst or admin)", group, role)
+		}
+	}
+	secret := cfg.ClientSecret
+	if cfg.ClientSecretEnv != "" {
+		if secret = secretenv.Get(cfg.ClientSecretEnv); secret == "" {
+			return nil, fmt.Errorf("server auth oidc: environment variable %s is not set", cfg.ClientSecretEnv)
+		}
+	}
+	ttl := DefaultSessionTTL
+	if cfg.SessionTTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(cfg.SessionTTL); err != nil {
+			return nil, fmt.Errorf("server auth oidc session_ttl: %w", err)
+		}
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultOIDCScopes
+	}
+	groupsClaim := cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = DefaultOIDCGroupsClaim
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &sso{
+		provider:    oidc.NewProvider(client, cfg.Issuer, cfg.ClientID, secret, cfg.RedirectURL, scopes),
+		groupsClaim: groupsClaim,
+		roles:       cfg.Roles,
+		tenants:     cfg.Tenants,
+		secure:      strings.HasPrefix(cfg.RedirectURL, "https://"),
+		sessions:    newSessionStore(ttl),
+	}, nil
+}
+
+// identity maps verified claims to an identity, or returns nil when none
+// of the user's groups has a role.
+func (a *sso) identity(claims oidc.Claims) *Identity {
+	id := &Identity{}
+	for _, claim := range []string{"email", "preferred_username", "sub"} {
+		if id.Name = claims.String(claim); id.Name != "" {
+			break
+		}
+	}
+	for _, group := range claims.Strings(a.groupsClaim) {
+		if role := a.roles[group]; role.rank() > id.Role.rank() {
+			id.Role = role
+		}
+		id.Tenants = append(id.Tenants, a.tenants[group]...)
+	}
+	if id.Role == "" {
+		return nil
+	}
+	return id
+}
+
+// sessionStore holds logins in progress and sessions in memory, so a
+// restart signs everyone out.
+type sessionStore struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	logins   map[string]*pendingLogin
+	sessions map[[sha256.Size]byte]*session
+}
+
+// pendingLogin is a login awaiting the provider's callback.
+type pendingLogin struct {
+	nonce, verifier, next string
+	expires               time.Time
+}
+
+// session is a signed-in user.
+type session struct {
+	identity *Identity
+	expires  time.Time
+}
+
+func newSessionStore(ttl time.Duration) *sessionStore {
+	return &sessionStore{
+		ttl:      ttl,
+		logins:   make(map[string]*pendingLogin),
+		sessions: make(map[[sha256.Size]byte]*session),
+	}
+}
+
+// begin records a login and returns its state parameter, or "" when too
+// many logins are pending.
+func (st *sessionStore) begin(login *pendingLogin, now time.Time) string {
+	state := randomToken()
+	login.expires = now.Add(loginTimeout)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.prune(now)
+	if len(st.logins) >= maxPendingLogins {
+		return ""
+	}
+	st.logins[state] = login
+	return state
+}
+
+// finish removes and returns the login for state, or nil.
+func (st *sessionStore) finish(state string, now time.Time) *pendingLogin {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	login := st.logins[state]
+	delete(st.logins, state)
+	if login == nil || now.After(login.expires) {
+		return nil
+	}
+	return login
+}
+
+// create starts a session for id and returns its token.
+func (st *sessionStore) create(id *Identity, now time.Time) (string, time.Time) {
+	token := randomToken()
+	expires := now.Add(st.ttl)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.prune(now)
+	st.sessions[sha256.Sum256([]byte(token))] = &session{identity: id, expires: expires}
+	return token, expires
+}
+
+// lookup returns the identity of a live session token, or nil.
+func (st *sessionStore) lookup(token string, now time.Time) *Identity {
+	if token == "" {
+		return nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if s := st.sessions[sha256.Sum256([]byte(token))]; s != nil && now.Before(s.expires) {
+		return s.identity
+	}
+	return nil
+}
+
+// remove ends the session of token.
+func (st *sessionStore) remove(token string) {
+	st.mu.Lock()
+	delete(st.sessions, sha256.Sum256([]byte(token)))
+	st.mu.Unlock()
+}
+
+// prune drops expired logins and sessions. Callers hold st.mu.
+func (st *sessionStore) prune(now time.Time) {
+	for state, login := range st.logins {
+		if now.After(login.expires) {
+			delete(st.logins, state)
+		}
+	}
+	for key, s := range st.sessions {
+		if now.After(s.expires) {
+			delete(st.sessions, key)
+		}
+	}
+}
+
+// randomToken returns 32 random bytes, base64url-encoded.
+func randomToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// handleLogin sends the browser to the provider.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	next := r.URL.Query().Get("next")
+	// Only redirect within this server after login.
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		next = "/report?type=html"
+	}
+	login := &pendingLogin{nonce: randomToken(), verifier: randomToken(), next: next}
+	state := s.auth.sso.sessions.begin(login, s.clock.Now())
+	if state == "" {
+		w.Header().Set("Retry-After", "60")
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "too many logins in progress"})
+		return
+	}
+	target, err := s.auth.sso.provider.AuthCodeURL(r.Context(), state, login.nonce, login.verifier)
+	if err != nil {
+		s.logger.Printf("sso login: %v", err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "identity provider is unavailable"})
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleCallback completes a login and starts a session.
+func (s *Server) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "login failed: " + errCode + " " + query.Get("error_description")})
+		return
+	}
+	flow := s.auth.sso
+	login := flow.sessions.finish(query.Get("state"), s.clock.Now())
+	if login == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown or expired login, please sign in again"})
+		return
+	}
+	rawIDToken, err := flow.provider.Exchange(r.Context(), query.Get("code"), login.verifier)
+	if err != nil {
+		s.logger.Printf("sso callback: %v", err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "could not complete login with the identity provider"})
+		return
+	}
+	claims, err := flow.provider.Verify(r.Context(), rawIDToken, login.nonce, s.clock.Now())
+	if err != nil {
+		s.logger.Printf("sso callback: %v", err)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid id token"})
+		return
+	}
+	id := flow.identity(claims)
+	if id == nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "none of your groups has access to secmetrics"})
+		return
+	}
+
+	token, expires := flow.sessions.create(id, s.clock.Now())
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   flow.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	s.logger.Printf("sso: %s signed in as %s", id.Name, id.Role)
+	http.Redirect(w, r, login.next, http.StatusFound)
+}
+
+// handleSession describes the caller's session and, on POST, issues a new
+// session token for API clients such as scripts run by the signed-in user.
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	id := s.auth.authenticate(r, s.clock.Now())
+	if id == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "not signed in"})
+		return
+	}
+	response := map[string]interface{}{"name": id.Name, "role": id.Role, "tenants": id.Tenants}
+	if r.Method == http.MethodPost {
+		token, expires := s.auth.sso.sessions.create(id, s.clock.Now())
+		response["token"] = token
+		response["expires"] = expires
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleLogout ends the caller's session.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	token := bearerToken(r)
+	if cookie, err := r.Cookie(sessionCookie); err == nil && token == "" {
+		token = cookie.Value
+	}
+	s.auth.sso.sessions.remove(token)
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: s.auth.sso.secure})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "signed out"})
+}
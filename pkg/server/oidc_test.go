@@ -0,0 +1,170 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider is an OpenID Connect provider that signs in a user with
+// the given groups.
+func fakeProvider(t *testing.T, groups []string) *httptest.Server {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv *httptest.Server
+	nonces := make(map[string]string)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"jwks_uri":               srv.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		nonces["code-1"] = q.Get("nonce")
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code=code-1&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code_verifier") == "" {
+			http.Error(w, "missing PKCE verifier", http.StatusBadRequest)
+			return
+		}
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+		claims, _ := json.Marshal(map[string]interface{}{
+			"iss": srv.URL, "aud": "secmetrics", "sub": "u1", "email": "ana@example.com",
+			"exp": time.Now().Add(time.Hour).Unix(), "nonce": nonces[r.Form.Get("code")], "groups": groups,
+		})
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+		digest := sha256.Sum256([]byte(signed))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed + "." + base64.RawURLEncoding.EncodeToString(signature)})
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// ssoServer starts secmetrics with single sign-on through provider.
+func ssoServer(t *testing.T, provider *httptest.Server) *httptest.Server {
+	t.Helper()
+	app := httptest.NewUnstartedServer(nil)
+	srv, err := New(Config{Auth: AuthConfig{OIDC: OIDCConfig{
+		Issuer:      provider.URL,
+		ClientID:    "secmetrics",
+		RedirectURL: "http://" + app.Listener.Addr().String() + "/auth/callback",
+		Roles:       map[string]Role{"secops": RoleAnalyst, "staff": RoleViewer},
+	}}}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+	app.Config.Handler = srv.Handler()
+	app.Start()
+	t.Cleanup(app.Close)
+	return app
+}
+
+func signIn(t *testing.T, app *httptest.Server) (*http.Client, *http.Response) {
+	t.Helper()
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	req, _ := http.NewRequest(http.MethodGet, app.URL+"/api/kpis", nil)
+	req.Header.Set("Accept", "text/html")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, resp
+}
+
+func TestSSOLoginMapsGroupsToRoles(t *testing.T) {
+	app := ssoServer(t, fakeProvider(t, []string{"staff", "secops"}))
+
+	client, resp := signIn(t, app)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/api/kpis" {
+		t.Fatalf("after login: %s %s, want 200 /api/kpis", resp.Status, resp.Request.URL)
+	}
+
+	resp, err := client.Get(app.URL + "/auth/session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var session map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&session)
+	resp.Body.Close()
+	if session["name"] != "ana@example.com" || session["role"] != string(RoleAnalyst) {
+		t.Errorf("session = %v, want ana@example.com as analyst", session)
+	}
+
+	// A session token issued for scripts works as a bearer token.
+	resp, err = client.Post(app.URL+"/auth/session", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issued struct{ Token string }
+	json.NewDecoder(resp.Body).Decode(&issued)
+	resp.Body.Close()
+	req, _ := http.NewRequest(http.MethodPost, app.URL+"/api/collect", nil)
+	req.Header.Set("Authorization", "Bearer "+issued.Token)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("analyst collect: %s, want 403", resp.Status)
+	}
+
+	resp, err = client.Post(app.URL+"/auth/logout", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get(app.URL + "/api/summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("after logout: %s, want 401", resp.Status)
+	}
+}
+
+func TestSSORefusesUnmappedGroups(t *testing.T) {
+	app := ssoServer(t, fakeProvider(t, []string{"contractors"}))
+
+	_, resp := signIn(t, app)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "none of your groups") {
+		t.Fatalf("login with unmapped groups: %s %s", resp.Status, body)
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/state"
+)
+
+// RunOnce restores state, runs one collection, delivers a report of each of
+// reportTypes, or drafts it when approval is required, delivers the reports
+// approved since the last run and pushes GRC records, then flushes the store
+// and returns the collection run. It suits schedulers that start the daemon
+// for each cycle, such as a Kubernetes CronJob; report schedules need the
+// long-running daemon. With leader election, an instance that cannot
+// acquire the lease returns a nil run without collecting, as another
+// instance does.
+func (s *Server) RunOnce(ctx context.Context, reportTypes []string) (*metrics.CollectionRun, error) {
+	if s.readOnly {
+		return nil, fmt.Errorf("a read-only server does not collect")
+	}
+	if err := s.restore(); err != nil {
+		return nil, err
+	}
+	if s.elector != nil {
+		s.elector.tryAcquire(ctx, s.clock.Now())
+		if s.following() {
+			s.logger.Printf("another instance leads; not collecting")
+			return nil, s.release()
+		}
+	}
+	if s.gitops != nil {
+		s.syncGitOps(ctx)
+	}
+
+	s.CollectOnce(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Join(err, s.release())
+	}
+	s.refreshShards()
+	s.mu.RLock()
+	runs := s.collector.GetRuns()
+	run := runs[len(runs)-1]
+	s.mu.RUnlock()
+
+	var errs []error
+	if s.runsReports() {
+		now := s.clock.Now()
+		for _, reportType := range reportTypes {
+			if err := s.runReport(ctx, reportType, state.ReportSchedule{Type: reportType}, now); err != nil {
+				errs = append(errs, fmt.Errorf("%s report: %w", reportType, err))
+			}
+		}
+		s.deliverApproved(ctx)
+	}
+	s.pushGRCRecords(ctx)
+	return &run, errors.Join(append(errs, s.release())...)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+func TestRunOnce(t *testing.T) {
+	var down bool
+	sources := []Source{
+		{Name: "scanner", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 3, Target: 4})
+			return nil
+		}},
+		{Name: "fleetdm", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			if down {
+				return errors.New("503 Service Unavailable")
+			}
+			return nil
+		}},
+	}
+	render := func(collector *metrics.MetricsCollector, reportType string, location *time.Location) (string, error) {
+		return reportType + " report", nil
+	}
+	metricsStore := store.NewFileStore(filepath.Join(t.TempDir(), "store.json"), nil)
+	var delivered []string
+	newServer := func() *Server {
+		srv, err := New(Config{}, sources, metricsStore, render)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.logger = log.New(io.Discard, "", 0)
+		srv.SetClock(clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)))
+		srv.SetDeliver(func(ctx context.Context, filename string, content []byte) error {
+			delivered = append(delivered, filename+": "+string(content))
+			return nil
+		})
+		return srv
+	}
+
+	run, err := newServer().RunOnce(context.Background(), []string{"executive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status() != "ok" || len(run.Sources) != 2 {
+		t.Errorf("run = %+v", run)
+	}
+	if len(delivered) != 1 || delivered[0] != "executive-20261001-090000.txt: executive report" {
+		t.Errorf("delivered = %q", delivered)
+	}
+
+	// Each run starts from the store the previous one saved
+	down = true
+	run, err = newServer().RunOnce(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status() != "partial" {
+		t.Errorf("status = %s, want partial", run.Status())
+	}
+	snapshot, err := metricsStore.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Runs) != 2 || len(snapshot.KPIs) == 0 {
+		t.Errorf("store has %d runs and %d KPIs, want both runs and the KPIs", len(snapshot.Runs), len(snapshot.KPIs))
+	}
+
+	srv := newServer()
+	srv.SetDeliver(nil)
+	if _, err := srv.RunOnce(context.Background(), []string{"executive"}); err == nil {
+		t.Error("report without delivery targets succeeded")
+	}
+}
+
+func TestRunOnceArchivesReports(t *testing.T) {
+	render := func(collector *metrics.MetricsCollector, reportType string, location *time.Location) (string, error) {
+		return "", errors.New("rendered without archiving")
+	}
+	srv, err := New(Config{}, nil, nil, render)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+	srv.SetClock(clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)))
+	var delivered, archived []string
+	srv.SetDeliver(func(ctx context.Context, filename string, content []byte) error {
+		delivered = append(delivered, filename+": "+string(content))
+		return nil
+	})
+	srv.SetArchive(func(collector *metrics.MetricsCollector, reportType string, location *time.Location, id string) (string, error) {
+		archived = append(archived, id)
+		return reportType + " report", nil
+	})
+
+	if _, err := srv.RunOnce(context.Background(), []string{"executive"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 1 || archived[0] != "executive-20261001-090000" {
+		t.Errorf("archived = %q", archived)
+	}
+	if len(delivered) != 1 || delivered[0] != "executive-20261001-090000.txt: executive report" {
+		t.Errorf("delivered = %q", delivered)
+	}
+}
@@ -0,0 +1,541 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/approval"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// route is an API operation. The route table drives request dispatch, the
+// OpenAPI document and request validation, so they cannot drift apart.
+type route struct {
+	method  string
+	path    string
+	summary string
+	// role is required to call the operation; "" leaves it public.
+	role  Role
+	query []queryParam
+	// body is a value of the JSON request body type, or nil.
+	body interface{}
+	// status and response describe the success response: a value of the
+	// JSON body type, or nil with contentType for other media types.
+	status      int
+	response    interface{}
+	contentType string
+	handler     http.HandlerFunc
+}
+
+// queryParam is a query string parameter.
+type queryParam struct {
+	name, description string
+	required          bool
+}
+
+// routeOptions selects the optional API operations.
+type routeOptions struct {
+	// sso adds the single sign-on endpoints.
+	sso bool
+	// gitops adds the GitOps status endpoint.
+	gitops bool
+	// slack adds the Slack slash command endpoint.
+	slack bool
+	// approval adds the report approval endpoints.
+	approval bool
+}
+
+// routeOptionsOf returns the optional operations a server configured with
+// cfg serves.
+func routeOptionsOf(cfg Config) routeOptions {
+	return routeOptions{sso: cfg.Auth.OIDC.Issuer != "", gitops: cfg.GitOps.Dir != "", slack: cfg.Slack.SigningSecretEnv != "", approval: cfg.Approval.Required}
+}
+
+// apiRoutes returns the API operations. s may be nil when only the
+// descriptions are needed.
+func apiRoutes(s *Server, opts routeOptions) []route {
+	routes := []route{
+		{method: http.MethodGet, path: "/healthz", summary: "Liveness probe",
+			status: http.StatusOK, response: map[string]interface{}{}, handler: s.handleHealthz},
+		{method: http.MethodGet, path: "/readyz", summary: "Readiness probe: 503 until state is loaded and during shutdown",
+			status: http.StatusOK, response: map[string]interface{}{}, handler: s.handleReadyz},
+		{method: http.MethodGet, path: "/metrics", summary: "Prometheus metrics: KPI values and targets plus self-monitoring", role: RoleViewer,
+			status: http.StatusOK, contentType: "text/plain", handler: s.handleMetrics},
+		{method: http.MethodGet, path: "/api/summary", summary: "Current summary", role: RoleViewer,
+			status: http.StatusOK, response: metrics.MetricsSummary{}, handler: s.handleSummary},
+		{method: http.MethodGet, path: "/api/kpis", summary: "Current KPIs", role: RoleViewer,
+			status: http.StatusOK, response: []metrics.KPI{}, handler: s.handleKPIs},
+		{method: http.MethodGet, path: "/api/audit", summary: "Audit trail of KPI lifecycle transitions and attached metric evidence, oldest first", role: RoleViewer,
+			status: http.StatusOK, response: []metrics.AuditEntry{}, handler: s.handleAudit},
+		{method: http.MethodGet, path: "/api/runs", summary: "Collection runs with each source's duration, records and error, oldest first", role: RoleViewer,
+			status: http.StatusOK, response: []metrics.CollectionRun{}, handler: s.handleRuns},
+		{method: http.MethodGet, path: "/api/events", summary: "Stream KPI changes as server-sent events", role: RoleViewer,
+			status: http.StatusOK, contentType: "text/event-stream", handler: s.handleEvents},
+		{method: http.MethodGet, path: "/report", summary: "Rendered report", role: RoleViewer,
+			query:  []queryParam{{name: "type", description: "Report type, e.g. executive, technical, markdown, html, onepager, ops or targets (default technical)"}},
+			status: http.StatusOK, contentType: "text/plain", handler: s.handleReport},
+		{method: http.MethodGet, path: "/api/extract", summary: "KPI history as a flat CSV table with dimensions, for Power BI and Tableau refreshes", role: RoleViewer,
+			query: []queryParam{{name: "from", description: "Start of the range, RFC 3339 time or YYYY-MM-DD date (default: all history)"},
+				{name: "to", description: "End of the range, exclusive (default: now)"},
+				{name: "kpi", description: "Comma-separated KPI keys to include (default: all)"}},
+			status: http.StatusOK, contentType: "text/csv", handler: s.handleExtract},
+		{method: http.MethodGet, path: "/api/alerts/firing", summary: "Firing KPI threshold alerts with their acknowledgment and silence", role: RoleViewer,
+			status: http.StatusOK, response: []alerting.FiringAlert{}, handler: s.handleFiringAlerts},
+		{method: http.MethodPost, path: "/api/alerts/ack", summary: "Acknowledge a firing alert, holding back its repeats until it resolves", role: RoleAnalyst,
+			body: AckRequest{}, status: http.StatusOK, response: alerting.Acknowledgment{}, handler: s.handleAck},
+		{method: http.MethodGet, path: "/api/silences", summary: "Alert silences, including expired ones", role: RoleViewer,
+			status: http.StatusOK, response: []alerting.Silence{}, handler: s.handleSilences},
+		{method: http.MethodPost, path: "/api/silences", summary: "Create or replace a silence muting alert notifications, e.g. for a maintenance window", role: RoleAnalyst,
+			body: SilenceRequest{}, status: http.StatusCreated, response: alerting.Silence{}, handler: s.handleCreateSilence},
+		{method: http.MethodDelete, path: "/api/silences", summary: "Expire a silence now", role: RoleAnalyst,
+			query:  []queryParam{{name: "id", description: "Silence ID", required: true}},
+			status: http.StatusOK, response: map[string]string{}, handler: s.handleExpireSilence},
+		{method: http.MethodPost, path: "/ingest", summary: "Push metrics, KPIs, incidents and alerts", role: RoleAnalyst,
+			body: IngestBatch{}, status: http.StatusAccepted, response: map[string]int{}, handler: s.handleIngest},
+		{method: http.MethodPost, path: "/api/collect", summary: "Collect now and return the new summary", role: RoleAdmin,
+			status: http.StatusOK, response: metrics.MetricsSummary{}, handler: s.handleCollect},
+	}
+	if opts.gitops {
+		routes = append(routes,
+			route{method: http.MethodGet, path: "/api/gitops", summary: "Desired-state revision, last sync and drift", role: RoleViewer,
+				status: http.StatusOK, response: GitOpsStatus{}, handler: s.handleGitOps},
+		)
+	}
+	if opts.approval {
+		routes = append(routes,
+			route{method: http.MethodGet, path: "/api/reports", summary: "Scheduled reports in the approval workflow with their approvals and history, newest first", role: RoleViewer,
+				status: http.StatusOK, response: []approval.Report{}, handler: s.handleApprovalReports},
+			route{method: http.MethodGet, path: "/api/reports/content", summary: "Rendered content of a report for review", role: RoleViewer,
+				query:  []queryParam{{name: "id", description: "Report ID", required: true}},
+				status: http.StatusOK, contentType: "text/plain", handler: s.handleApprovalContent},
+			route{method: http.MethodPost, path: "/api/reports/submit", summary: "Submit a draft report for review", role: RoleAnalyst,
+				body: ReviewRequest{}, status: http.StatusOK, response: approval.Report{}, handler: s.handleSubmitReport},
+			route{method: http.MethodPost, path: "/api/reports/approve", summary: "Approve a report in review; approved reports are delivered", role: RoleAnalyst,
+				body: ReviewRequest{}, status: http.StatusOK, response: approval.Report{}, handler: s.handleApproveReport},
+			route{method: http.MethodPost, path: "/api/reports/reject", summary: "Return a report in review to draft", role: RoleAnalyst,
+				body: ReviewRequest{}, status: http.StatusOK, response: approval.Report{}, handler: s.handleRejectReport},
+		)
+	}
+	if opts.slack {
+		// Slack signs its requests rather than presenting an API key.
+		routes = append(routes,
+			route{method: http.MethodPost, path: "/slack/commands", summary: "Answer a Slack slash command, e.g. \"kpi mttr\" or \"health\"",
+				status: http.StatusOK, response: slackMessage{}, handler: s.handleSlackCommand},
+		)
+	}
+	if opts.sso {
+		routes = append(routes,
+			route{method: http.MethodGet, path: "/auth/login", summary: "Start a single sign-on login",
+				query:  []queryParam{{name: "next", description: "Path to return to after login"}},
+				status: http.StatusFound, handler: s.handleLogin},
+			route{method: http.MethodGet, path: "/auth/callback", summary: "Complete a login; the redirect URL registered with the provider",
+				query: []queryParam{{name: "code", description: "Authorization code"}, {name: "state", description: "Login state"},
+					{name: "error", description: "Provider error code"}, {name: "error_description", description: "Provider error description"}},
+				status: http.StatusFound, handler: s.handleCallback},
+			route{method: http.MethodGet, path: "/auth/session", summary: "Describe the caller's session",
+				status: http.StatusOK, response: map[string]interface{}{}, handler: s.handleSession},
+			route{method: http.MethodPost, path: "/auth/session", summary: "Issue a session token for API clients",
+				status: http.StatusOK, response: map[string]interface{}{}, handler: s.handleSession},
+			route{method: http.MethodPost, path: "/auth/logout", summary: "End the caller's session",
+				status: http.StatusOK, response: map[string]string{}, handler: s.handleLogout},
+		)
+	}
+	return routes
+}
+
+// dispatch serves the routes sharing a path, selecting by method, enforcing
+// their role and validating the request.
+func (s *Server) dispatch(routes []route) http.HandlerFunc {
+	handlers := make(map[string]http.HandlerFunc)
+	var allow []string
+	for _, rt := range routes {
+		rt := rt
+		handler := s.validate(rt)
+		if rt.role != "" {
+			handler = s.require(rt.role, handler)
+		}
+		handlers[rt.method] = handler
+		allow = append(allow, rt.method)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		method := r.Method
+		if method == http.MethodHead {
+			method = http.MethodGet
+		}
+		handler, ok := handlers[method]
+		if !ok {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// validate checks a request's query and body against the route before
+// calling its handler.
+func (s *Server) validate(rt route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		for _, param := range rt.query {
+			if param.required && query.Get(param.name) == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("query parameter %s is required", param.name)})
+				return
+			}
+		}
+		if rt.body != nil {
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.ingest.config.MaxBodyBytes))
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+				return
+			}
+			var body interface{}
+			if err := json.Unmarshal(data, &body); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+				return
+			}
+			if err := validateValue(body, reflect.TypeOf(rt.body), ""); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(data))
+		}
+		rt.handler(w, r)
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// validateValue checks a decoded JSON value against the schema of Go type
+// t, matching object keys case-insensitively as encoding/json does.
+func validateValue(v interface{}, t reflect.Type, path string) error {
+	if t.Kind() == reflect.Pointer {
+		if v == nil {
+			return nil
+		}
+		t = t.Elem()
+	}
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Interface:
+			return nil
+		}
+		return fmt.Errorf("%s: must not be null", where(path))
+	}
+	if t == timeType {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: want an RFC 3339 date-time string", where(path))
+		}
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return fmt.Errorf("%s: want an RFC 3339 date-time, got %q", where(path), s)
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return nil
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: want string, got %s", where(path), jsonType(v))
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want boolean, got %s", where(path), jsonType(v))
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: want number, got %s", where(path), jsonType(v))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: want integer, got %s", where(path), jsonType(v))
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want array, got %s", where(path), jsonType(v))
+		}
+		for i, item := range items {
+			if err := validateValue(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want object, got %s", where(path), jsonType(v))
+		}
+		for _, key := range sortedObjectKeys(object) {
+			if err := validateValue(object[key], t.Elem(), joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: want object, got %s", where(path), jsonType(v))
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedObjectKeys(object) {
+			field, ok := lookupField(fields, key)
+			if !ok {
+				return fmt.Errorf("%s: unknown field %q", where(path), key)
+			}
+			if err := validateValue(object[key], field.Type, joinPath(path, field.name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// joinPath appends an object key to a value path for error messages; the
+// empty path is the body itself.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// where names a value path in error messages.
+func where(path string) string {
+	if path == "" {
+		return "body"
+	}
+	return path
+}
+
+// jsonField is a struct field as encoding/json names it.
+type jsonField struct {
+	name string
+	reflect.StructField
+}
+
+// jsonFields returns the exported fields of struct type t.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name: name, StructField: f})
+	}
+	return fields
+}
+
+// lookupField finds the field for key, preferring an exact match.
+func lookupField(fields []jsonField, key string) (jsonField, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return jsonField{}, false
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
+
+func sortedObjectKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// OpenAPI returns the OpenAPI 3 document describing the API of a server
+// running version with cfg.
+func OpenAPI(version string, cfg Config) map[string]interface{} {
+	return openAPI(version, routeOptionsOf(cfg))
+}
+
+func openAPI(version string, opts routeOptions) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	schemas["Error"] = map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}},
+	}
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}}},
+		}
+	}
+
+	securitySchemes := map[string]interface{}{
+		"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "API key or session token"},
+	}
+	security := []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	if opts.sso {
+		securitySchemes["sessionCookie"] = map[string]interface{}{"type": "apiKey", "in": "cookie", "name": sessionCookie}
+		security = append(security, map[string]interface{}{"sessionCookie": []string{}})
+	}
+
+	paths := make(map[string]interface{})
+	for _, rt := range apiRoutes(nil, opts) {
+		op := map[string]interface{}{
+			"summary":     rt.summary,
+			"operationId": operationID(rt),
+		}
+		if len(rt.query) > 0 {
+			var params []interface{}
+			for _, param := range rt.query {
+				params = append(params, map[string]interface{}{
+					"name": param.name, "in": "query", "required": param.required,
+					"description": param.description, "schema": map[string]interface{}{"type": "string"},
+				})
+			}
+			op["parameters"] = params
+		}
+		if rt.body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(rt.body), schemas)}},
+			}
+		}
+
+		success := map[string]interface{}{"description": http.StatusText(rt.status)}
+		switch {
+		case rt.response != nil:
+			success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(rt.response), schemas)}}
+		case rt.contentType != "":
+			success["content"] = map[string]interface{}{rt.contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+		}
+		responses := map[string]interface{}{
+			fmt.Sprint(rt.status): success,
+			"400":                 errorResponse("Invalid request"),
+			"405":                 errorResponse("Method not allowed"),
+			"429":                 errorResponse("Rate limit exceeded"),
+		}
+		if rt.role != "" {
+			op["security"] = security
+			op["x-required-role"] = string(rt.role)
+			op["description"] = fmt.Sprintf("Requires the %s role when access control is configured.", rt.role)
+			responses["401"] = errorResponse("Missing or invalid API key or session")
+			responses["403"] = errorResponse("Insufficient role or tenant")
+		}
+		op["responses"] = responses
+
+		item, _ := paths[rt.path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "secmetrics API",
+			"version":     version,
+			"description": "Security metrics and KPIs served by secmetrics serve.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas":         schemas,
+			"securitySchemes": securitySchemes,
+		},
+	}
+}
+
+// operationID names an operation for generated clients, e.g. getApiKpis.
+func operationID(rt route) string {
+	id := strings.ToLower(rt.method)
+	for _, part := range strings.FieldsFunc(rt.path, func(r rune) bool { return r == '/' || r == '_' || r == '.' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// schemaOf returns the JSON schema of Go type t, adding named structs to
+// schemas and referring to them.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		schema := schemaOf(t.Elem(), schemas)
+		schema["nullable"] = true
+		return schema
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if _, ok := schemas[name]; ok {
+			return ref
+		}
+		// Register before recursing so self-referencing types terminate.
+		schemas[name] = nil
+		properties := make(map[string]interface{})
+		for _, f := range jsonFields(t) {
+			properties[f.name] = schemaOf(f.Type, schemas)
+		}
+		schemas[name] = map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// handleOpenAPI serves the OpenAPI document.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPI(s.version, s.routes))
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocumentDescribesRoutes(t *testing.T) {
+	srv, err := New(Config{Version: "1.2.3"}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: %d", rec.Code)
+	}
+	var doc struct {
+		OpenAPI    string
+		Info       struct{ Version string }
+		Paths      map[string]map[string]json.RawMessage
+		Components struct{ Schemas map[string]json.RawMessage }
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" || doc.Info.Version != "1.2.3" {
+		t.Errorf("openapi %q, version %q", doc.OpenAPI, doc.Info.Version)
+	}
+	for _, rt := range apiRoutes(nil, routeOptions{}) {
+		if _, ok := doc.Paths[rt.path][strings.ToLower(rt.method)]; !ok {
+			t.Errorf("%s %s is not documented", rt.method, rt.path)
+		}
+	}
+	if _, ok := doc.Paths["/auth/login"]; ok {
+		t.Error("single sign-on endpoints documented without single sign-on")
+	}
+	for _, ref := range strings.Split(rec.Body.String(), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("unresolved schema reference %s", name)
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	srv, err := New(Config{}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := srv.Handler()
+
+	tests := []struct {
+		method, path, body string
+		want               int
+		wantErr            string
+	}{
+		{"POST", "/ingest", `{"kpis":[{"Key":"mttr","Value":"high"}]}`, http.StatusBadRequest, "kpis[0].Value: want number, got string"},
+		{"POST", "/ingest", `{"kpis":[{"key":"mttr","valeu":2}]}`, http.StatusBadRequest, `kpis[0]: unknown field \"valeu\"`},
+		{"POST", "/ingest", `{"incidents":[{"ID":"i1","DetectedAt":"yesterday"}]}`, http.StatusBadRequest, "want an RFC 3339 date-time"},
+		{"POST", "/ingest", `{"kpis":[{"key":"mttr","value":2}]}`, http.StatusAccepted, ""},
+		{"GET", "/ingest", ``, http.StatusMethodNotAllowed, "method not allowed"},
+		{"DELETE", "/api/kpis", ``, http.StatusMethodNotAllowed, "method not allowed"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want || !strings.Contains(rec.Body.String(), tt.wantErr) {
+			t.Errorf("%s %s %s: %d %s, want %d %q", tt.method, tt.path, tt.body, rec.Code, rec.Body, tt.want, tt.wantErr)
+		}
+	}
+}
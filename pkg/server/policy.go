@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// SourcePolicy sets how a collection runs a source: how often a failed
+// source is retried, how long each attempt may take and after how many
+// failed runs its circuit breaker opens. The zero value tries the source
+// once, without a time limit or breaker.
+type SourcePolicy struct {
+	// Retries is the number of retries after a failed attempt.
+	Retries int
+	// Backoff is the delay before the first retry, doubled before each
+	// further retry; DefaultRetryBackoff when zero.
+	Backoff time.Duration
+	// Timeout bounds each attempt; zero leaves attempts unbounded.
+	Timeout time.Duration
+	// BreakerFailures is the number of consecutive failed runs that opens
+	// the source's circuit breaker; zero disables the breaker.
+	BreakerFailures int
+	// BreakerCooldown is how long an open breaker skips the source before
+	// a run tries it once more; DefaultBreakerCooldown when zero.
+	BreakerCooldown time.Duration
+}
+
+// Source policy defaults applied when a setting is not configured.
+const (
+	DefaultRetryBackoff    = time.Second
+	DefaultBreakerCooldown = time.Hour
+)
+
+// CollectSource runs source as part of run following its policy. An open
+// circuit breaker skips the source, recording it as failed; once the
+// cooldown has passed, a single attempt without retries closes the breaker
+// again or reopens it. Failed attempts are retried, after a backoff on
+// the collector's clock, unless they already added metrics or KPI values,
+// which a retry would record twice. It returns the error of the last
+// attempt.
+func CollectSource(ctx context.Context, collector *metrics.MetricsCollector, run *metrics.CollectionRun, source Source) error {
+	policy := source.Policy
+	retries, breaker := policy.Retries, ""
+	failures, last := collector.SourceFailures(source.Name)
+	if policy.BreakerFailures > 0 {
+		breaker = metrics.BreakerClosed
+		if failures >= policy.BreakerFailures {
+			cooldown := policy.BreakerCooldown
+			if cooldown <= 0 {
+				cooldown = DefaultBreakerCooldown
+			}
+			if until := last.Add(cooldown); run.Start.Before(until) {
+				err := fmt.Errorf("circuit breaker open after %d failed runs until %s", failures, until.Format(time.RFC3339))
+				run.Sources = append(run.Sources, metrics.SourceRun{Name: source.Name, Error: err.Error(), Breaker: metrics.BreakerOpen})
+				return err
+			}
+			retries, breaker = 0, metrics.BreakerHalfOpen
+		}
+	}
+
+	attempts := 0
+	err := collector.CollectSource(run, source.Name, func() error {
+		backoff := policy.Backoff
+		if backoff <= 0 {
+			backoff = DefaultRetryBackoff
+		}
+		for {
+			attempts++
+			before := len(collector.GetMetrics()) + len(collector.GetHistory())
+			err := collectAttempt(ctx, collector, source, policy.Timeout)
+			added := len(collector.GetMetrics())+len(collector.GetHistory()) != before
+			if err == nil || attempts > retries || added {
+				return err
+			}
+			if !wait(ctx, collector.Clock(), backoff) {
+				return err
+			}
+			backoff *= 2
+		}
+	})
+
+	result := &run.Sources[len(run.Sources)-1]
+	result.Attempts = attempts
+	if breaker != "" {
+		switch {
+		case err == nil:
+			breaker = metrics.BreakerClosed
+		case breaker == metrics.BreakerHalfOpen || failures+1 >= policy.BreakerFailures:
+			breaker = metrics.BreakerOpen
+		}
+		result.Breaker = breaker
+	}
+	return err
+}
+
+// wait waits for d on clk and reports whether it passed before ctx was
+// done.
+func wait(ctx context.Context, clk clock.Clock, d time.Duration) bool {
+	ticker := clk.NewTicker(d)
+	defer ticker.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-ticker.C():
+		return true
+	}
+}
+
+// collectAttempt runs source once, within timeout unless it is zero.
+func collectAttempt(ctx context.Context, collector *metrics.MetricsCollector, source Source, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return source.Collect(ctx, collector)
+}
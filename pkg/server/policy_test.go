@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestCollectSourceRetries(t *testing.T) {
+	collector := metrics.NewMetricsCollector()
+	calls := 0
+	flaky := Source{Name: "scanner", Policy: SourcePolicy{Retries: 3, Backoff: time.Millisecond}, Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		if calls++; calls < 3 {
+			return errors.New("429 Too Many Requests")
+		}
+		collector.AddMetric(metrics.SecurityMetric{ID: "vulns", Name: "Open Vulnerabilities", Value: 3})
+		return nil
+	}}
+	run := collector.StartRun()
+	if err := CollectSource(context.Background(), collector, run, flaky); err != nil {
+		t.Fatal(err)
+	}
+	if source := run.Sources[0]; source.Attempts != 3 || source.Error != "" || source.Records != 1 {
+		t.Errorf("source run = %+v, want success on the third attempt", source)
+	}
+
+	// An attempt that added records before failing is not retried
+	calls = 0
+	partial := Source{Name: "partial", Policy: SourcePolicy{Retries: 3, Backoff: time.Millisecond}, Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		calls++
+		collector.AddMetric(metrics.SecurityMetric{ID: "half", Name: "Half", Value: 1})
+		return errors.New("connection reset")
+	}}
+	if err := CollectSource(context.Background(), collector, run, partial); err == nil || calls != 1 {
+		t.Errorf("partial source: err %v after %d attempts, want one failed attempt", err, calls)
+	}
+
+	// Each attempt is bounded by the timeout
+	hanging := Source{Name: "hanging", Policy: SourcePolicy{Timeout: 10 * time.Millisecond}, Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	if err := CollectSource(context.Background(), collector, run, hanging); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("hanging source: err = %v", err)
+	}
+}
+
+// advanceWhileWaiting runs f, advancing clk by step whenever f waits on
+// one of its tickers.
+func advanceWhileWaiting(t *testing.T, clk *clock.Fake, step time.Duration, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Millisecond):
+		}
+		if clk.Tickers() > 0 {
+			clk.Advance(step)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for f")
+		}
+	}
+}
+
+func TestCollectSourceBacksOffOnTheCollectorClock(t *testing.T) {
+	start := time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	collector := metrics.NewMetricsCollector()
+	collector.SetClock(clk)
+	var attempts []time.Duration
+	source := Source{Name: "scanner", Policy: SourcePolicy{Retries: 2, Backoff: time.Minute}, Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		attempts = append(attempts, clk.Now().Sub(start))
+		return errors.New("429 Too Many Requests")
+	}}
+
+	run := collector.StartRun()
+	advanceWhileWaiting(t, clk, time.Minute, func() {
+		CollectSource(context.Background(), collector, run, source)
+	})
+	// Retried after one and then two minutes of the fake clock
+	if len(attempts) != 3 || attempts[0] != 0 || attempts[1] != time.Minute || attempts[2] != 3*time.Minute {
+		t.Errorf("attempts at %v, want 0s, 1m and 3m", attempts)
+	}
+
+	// Cancelling the context ends the backoff
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- CollectSource(ctx, collector, collector.StartRun(), source) }()
+	for clk.Tickers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("cancelled collection succeeded")
+	}
+}
+
+func TestCollectSourceCircuitBreaker(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 2, 0, 0, 0, time.UTC))
+	collector := metrics.NewMetricsCollector()
+	collector.SetClock(clk)
+	down, calls := true, 0
+	source := Source{Name: "fleetdm", Policy: SourcePolicy{Retries: 1, Backoff: time.Millisecond, BreakerFailures: 2, BreakerCooldown: time.Hour},
+		Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			calls++
+			if down {
+				return errors.New("503 Service Unavailable")
+			}
+			return nil
+		}}
+	collect := func() metrics.SourceRun {
+		run := collector.StartRun()
+		advanceWhileWaiting(t, clk, time.Millisecond, func() {
+			CollectSource(context.Background(), collector, run, source)
+		})
+		collector.FinishRun(run)
+		clk.Advance(30 * time.Minute)
+		return run.Sources[0]
+	}
+
+	for i, want := range []struct {
+		breaker  string
+		attempts int
+	}{
+		{metrics.BreakerClosed, 2},
+		{metrics.BreakerOpen, 2},
+		// Skipped while the breaker is open
+		{metrics.BreakerOpen, 0},
+		// One trial attempt once the cooldown has passed, which fails
+		{metrics.BreakerOpen, 1},
+		{metrics.BreakerOpen, 0},
+	} {
+		calls = 0
+		got := collect()
+		if got.Breaker != want.breaker || got.Attempts != want.attempts || calls != want.attempts || got.Error == "" {
+			t.Errorf("run %d: %+v after %d calls, want breaker %s after %d attempts", i+1, got, calls, want.breaker, want.attempts)
+		}
+	}
+	if got := collector.GetRuns()[2].Sources[0]; !got.Skipped() {
+		t.Errorf("source run %+v not skipped", got)
+	}
+
+	down = false
+	if got := collect(); got.Breaker != metrics.BreakerClosed || got.Attempts != 1 || got.Error != "" {
+		t.Errorf("trial run = %+v, want the breaker closed", got)
+	}
+	if failures, _ := collector.SourceFailures("fleetdm"); failures != 0 {
+		t.Errorf("failures after recovery = %d", failures)
+	}
+}
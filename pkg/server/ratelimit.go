@@ -0,0 +1,207 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig configures per-client request quotas. Limits count
+// requests per window; 0 disables a limit.
+type RateLimitConfig struct {
+	// Window is the quota period, e.g. "1m".
+	Window string `yaml:"window"`
+	// PerIP limits requests from each client address.
+	PerIP int `yaml:"per_ip"`
+	// PerKey limits requests from each API key or signed-in user.
+	PerKey int `yaml:"per_key"`
+	// Quotas overrides PerKey for API keys or users by name.
+	Quotas map[string]int `yaml:"quotas"`
+}
+
+// DefaultRateLimitWindow is the quota period when none is configured.
+const DefaultRateLimitWindow = time.Minute
+
+// pruneRateWindows is the number of tracked clients above which elapsed
+// windows are dropped.
+const pruneRateWindows = 10000
+
+// rateLimiter counts requests per client in fixed windows.
+type rateLimiter struct {
+	window time.Duration
+	perIP  int
+	perKey int
+	quotas map[string]int
+
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+// rateWindow counts one client's requests in the current window.
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+// rateStatus is a client's quota after a request.
+type rateStatus struct {
+	scope     string
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+// newRateLimiter validates cfg. It returns nil when no limit is set.
+func newRateLimiter(cfg RateLimitConfig) (*rateLimiter, error) {
+	if cfg.PerIP < 0 || cfg.PerKey < 0 {
+		return nil, fmt.Errorf("server rate_limit: limits must not be negative")
+	}
+	for name, quota := range cfg.Quotas {
+		if quota < 0 {
+			return nil, fmt.Errorf("server rate_limit: quota for %s must not be negative", name)
+		}
+	}
+	if cfg.PerIP == 0 && cfg.PerKey == 0 && len(cfg.Quotas) == 0 {
+		return nil, nil
+	}
+	window := DefaultRateLimitWindow
+	if cfg.Window != "" {
+		var err error
+		if window, err = time.ParseDuration(cfg.Window); err != nil || window <= 0 {
+			return nil, fmt.Errorf("server rate_limit window: invalid duration %q", cfg.Window)
+		}
+	}
+	return &rateLimiter{
+		window:  window,
+		perIP:   cfg.PerIP,
+		perKey:  cfg.PerKey,
+		quotas:  cfg.Quotas,
+		windows: make(map[string]*rateWindow),
+	}, nil
+}
+
+// rateQuota is a limit a request counts against.
+type rateQuota struct {
+	scope  string
+	client string
+	limit  int
+}
+
+// take counts a request against every quota if each of them allows it,
+// and reports the resulting statuses and whether the request is allowed.
+// A rejected request counts against none, so hitting one limit does not
+// use up another.
+func (l *rateLimiter) take(quotas []rateQuota, now time.Time) ([]rateStatus, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	windows := make([]*rateWindow, len(quotas))
+	allowed := true
+	for i, quota := range quotas {
+		windows[i] = l.current(quota.scope+":"+quota.client, now)
+		allowed = allowed && windows[i].count < quota.limit
+	}
+	statuses := make([]rateStatus, len(quotas))
+	for i, quota := range quotas {
+		if allowed {
+			windows[i].count++
+		}
+		statuses[i] = rateStatus{scope: quota.scope, limit: quota.limit, remaining: quota.limit - windows[i].count, reset: windows[i].reset}
+	}
+	return statuses, allowed
+}
+
+// current returns the window of key at now, starting a new one when the
+// last has elapsed. Callers hold l.mu.
+func (l *rateLimiter) current(key string, now time.Time) *rateWindow {
+	w := l.windows[key]
+	if w == nil || !now.Before(w.reset) {
+		if len(l.windows) > pruneRateWindows {
+			l.prune(now)
+		}
+		w = &rateWindow{reset: now.Add(l.window)}
+		l.windows[key] = w
+	}
+	return w
+}
+
+// prune drops elapsed windows. Callers hold l.mu.
+func (l *rateLimiter) prune(now time.Time) {
+	for key, w := range l.windows {
+		if !now.Before(w.reset) {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// keyLimit returns the quota of the API key or user name.
+func (l *rateLimiter) keyLimit(name string) int {
+	if quota, ok := l.quotas[name]; ok {
+		return quota
+	}
+	return l.perKey
+}
+
+// limit wraps handler with the configured quotas, reporting the tightest
+// one in RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+// It is a no-op when rate limiting is not configured.
+func (s *Server) limit(handler http.Handler) http.Handler {
+	if s.rateLimiter == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Probes come from the kubelet at a fixed rate and must not fail
+		// because of someone else's traffic from the same node.
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		now := s.clock.Now()
+		var quotas []rateQuota
+		if s.rateLimiter.perIP > 0 {
+			quotas = append(quotas, rateQuota{scope: "ip", client: clientIP(r), limit: s.rateLimiter.perIP})
+		}
+		if s.auth != nil {
+			if id := s.auth.authenticate(r, now); id != nil {
+				if limit := s.rateLimiter.keyLimit(id.Name); limit > 0 {
+					quotas = append(quotas, rateQuota{scope: "key", client: id.Name, limit: limit})
+				}
+			}
+		}
+		if len(quotas) == 0 {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		statuses, allowed := s.rateLimiter.take(quotas, now)
+
+		tightest := statuses[0]
+		for _, status := range statuses[1:] {
+			if status.remaining < tightest.remaining {
+				tightest = status
+			}
+		}
+		resetSeconds := int(tightest.reset.Sub(now).Round(time.Second) / time.Second)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(tightest.limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(tightest.remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(resetSeconds))
+		if !allowed {
+			s.telemetry.ObserveRateLimited(tightest.scope)
+			w.Header().Set("Retry-After", strconv.Itoa(resetSeconds))
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address of the connecting client. Forwarding
+// headers are ignored since any client can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+)
+
+func TestRateLimitPerIPAndKey(t *testing.T) {
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	srv, err := New(Config{
+		Auth: AuthConfig{Keys: []APIKey{
+			{Name: "script", Key: "script-token", Role: RoleViewer},
+			{Name: "scanner", Key: "scanner-token", Role: RoleViewer},
+			{Name: "viewer", Key: "viewer-token", Role: RoleViewer},
+		}},
+		RateLimit: RateLimitConfig{Window: "1m", PerIP: 3, PerKey: 1, Quotas: map[string]int{"scanner": 2, "viewer": 5}},
+	}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetClock(clk)
+	handler := srv.Handler()
+
+	get := func(ip, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/kpis", nil)
+		req.RemoteAddr = ip + ":40000"
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("10.0.0.1", "script-token"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first script request: %d, remaining %q", rec.Code, rec.Header().Get("RateLimit-Remaining"))
+	}
+	rec := get("10.0.0.2", "script-token")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second script request from another address: %d, want 429", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("429 headers: %v", rec.Header())
+	}
+
+	// The scanner's quota overrides per_key, but its address is also
+	// limited.
+	for i := 0; i < 2; i++ {
+		if rec := get("10.0.0.1", "scanner-token"); rec.Code != http.StatusOK {
+			t.Fatalf("scanner request %d: %d", i+1, rec.Code)
+		}
+	}
+	if rec := get("10.0.0.1", "scanner-token"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("scanner over quota: %d, want 429", rec.Code)
+	}
+	// Requests rejected by the key's quota do not use up the address's:
+	// 10.0.0.3 has made none of the three it may make.
+	for i := 0; i < 3; i++ {
+		if rec := get("10.0.0.3", "script-token"); rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("script request %d over quota: %d, want 429", i+1, rec.Code)
+		}
+	}
+	if rec := get("10.0.0.3", "scanner-token"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("RateLimit-Limit") != "2" {
+		t.Fatalf("scanner over quota from another address: %d, limit %q", rec.Code, rec.Header().Get("RateLimit-Limit"))
+	}
+	if rec := get("10.0.0.3", "viewer-token"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "2" {
+		t.Fatalf("first accepted request from 10.0.0.3: %d, remaining %q", rec.Code, rec.Header().Get("RateLimit-Remaining"))
+	}
+
+	clk.Advance(time.Minute)
+	if rec := get("10.0.0.1", "script-token"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Reset") != "60" {
+		t.Fatalf("after the window: %d, reset %q", rec.Code, rec.Header().Get("RateLimit-Reset"))
+	}
+}
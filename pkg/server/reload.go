@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+)
+
+// Reload applies the collection interval, the sources and the alerting
+// policy of cfg while the server runs. A collection in progress finishes
+// with the sources it started with, and the next one uses the new ones;
+// the interval restarts from the reload. Firing alerts and their
+// acknowledgments are kept, while the alerts of removed composite rules
+// resolve. When cfg is invalid, Reload changes nothing and returns the
+// error. Other settings, such as the listen address or the store, apply
+// on restart.
+func (s *Server) Reload(cfg Config, sources []Source) error {
+	interval := DefaultInterval
+	if cfg.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(cfg.Interval); err != nil {
+			return fmt.Errorf("server interval: %w", err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("server interval: must be positive")
+		}
+	}
+	resolved, err := s.alerts.Reconfigure(cfg.Alerting)
+	if err != nil {
+		return err
+	}
+	if s.shards != nil {
+		sources = s.shards.config.ownSources(sources, s.shards.index)
+	}
+
+	s.mu.Lock()
+	changed := interval != s.interval
+	s.interval, s.sources = interval, sources
+	s.mu.Unlock()
+	if len(resolved) > 0 {
+		s.recordAlerts(alerting.Decision{Resolved: resolved}, s.clock.Now())
+	}
+	if changed {
+		select {
+		case s.reloaded <- struct{}{}:
+		default:
+		}
+	}
+	names := make([]string, 0, len(sources))
+	for _, source := range sources {
+		names = append(names, source.Name)
+	}
+	s.logger.Printf("reloaded configuration: collecting %s every %s", strings.Join(names, ", "), interval)
+	return nil
+}
+
+// collectionInterval returns the interval between scheduled collections.
+func (s *Server) collectionInterval() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.interval
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestReloadAppliesIntervalAndSources(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	collected := make(chan string, 10)
+	source := func(name string) Source {
+		return Source{Name: name, Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			collected <- name
+			return nil
+		}}
+	}
+
+	srv, err := New(Config{Addr: "127.0.0.1:0", Interval: "24h"}, []Source{source("scanner")}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+	srv.SetClock(clk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+	if name := <-collected; name != "scanner" {
+		t.Fatalf("initial collection from %s", name)
+	}
+
+	invalid := []Config{
+		{Interval: "often"},
+		{Interval: "1h", Alerting: alerting.Config{GroupBy: "team"}},
+	}
+	for _, cfg := range invalid {
+		if err := srv.Reload(cfg, []Source{source("fleetdm")}); err == nil {
+			t.Errorf("reload of %+v succeeded", cfg)
+		}
+	}
+	if interval := srv.collectionInterval(); interval != 24*time.Hour {
+		t.Fatalf("interval after invalid reloads = %s", interval)
+	}
+
+	if err := srv.Reload(Config{Interval: "1h"}, []Source{source("fleetdm")}); err != nil {
+		t.Fatal(err)
+	}
+	// The next collection runs one new interval later with the new
+	// sources; Run resets its ticker asynchronously, so keep advancing.
+	var name string
+	deadline := time.Now().Add(5 * time.Second)
+	for name == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("no collection at the reloaded interval")
+		}
+		clk.Advance(time.Hour)
+		select {
+		case name = <-collected:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	if name != "fleetdm" || clk.Now().Sub(start) >= 24*time.Hour {
+		t.Errorf("collected %s after %s, want fleetdm within the new interval", name, clk.Now().Sub(start))
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
@@ -0,0 +1,838 @@
+// Package server provides the long-running secmetrics daemon and its HTTP API.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/approval"
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/grc"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/siem"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+	"github.com/hallucinaut/secmetrics/pkg/ticketing"
+)
+
+// Config configures serve mode.
+type Config struct {
+	Addr string `yaml:"addr"`
+	// Interval between scheduled collections, e.g. "1h".
+	Interval string `yaml:"interval"`
+	// ShutdownTimeout bounds how long shutdown waits for in-flight
+	// requests such as report generations, e.g. "30s".
+	ShutdownTimeout string       `yaml:"shutdown_timeout"`
+	Ingest          IngestConfig `yaml:"ingest"`
+	// Tenant names what this server serves; API keys may be limited to
+	// specific tenants.
+	Tenant    string          `yaml:"tenant"`
+	Auth      AuthConfig      `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	GitOps    GitOpsConfig    `yaml:"gitops"`
+	// LeaderElection lets replicas sharing a store avoid collecting twice.
+	LeaderElection LeaderElectionConfig `yaml:"leader_election"`
+	// Sharding splits the sources between instances sharing a store.
+	Sharding ShardingConfig `yaml:"sharding"`
+	// Redis caches the summary and carries KPI change events between
+	// instances.
+	Redis RedisConfig `yaml:"redis"`
+	// EventBus publishes metric, alert and report events to NATS or
+	// Kafka.
+	EventBus EventBusConfig `yaml:"event_bus"`
+	// SIEM sends KPI threshold crossings and health changes to a SIEM
+	// collector as CEF or syslog messages.
+	SIEM siem.Config `yaml:"siem"`
+	// Alerting deduplicates, groups and repeats the alerts sent to the
+	// SIEM; silences and acknowledgments are managed through the API and
+	// the silence command.
+	Alerting alerting.Config `yaml:"alerting"`
+	// GRCExport pushes KPI values and risk scores to ServiceNow GRC or
+	// RSA Archer on a schedule.
+	GRCExport grc.ExportConfig `yaml:"grc_export"`
+	// Ticketing opens a Jira or ServiceNow ticket for each KPI threshold
+	// breach and tracks its closure on the recorded alert.
+	Ticketing ticketing.Config `yaml:"ticketing"`
+	// Slack answers slash commands such as "/secmetrics kpi mttr".
+	Slack SlackConfig `yaml:"slack"`
+	// Approval holds scheduled reports as drafts until named approvers
+	// approve them, through the API or the approval command.
+	Approval approval.Config `yaml:"approval"`
+	// Taxonomy groups KPI categories; it is set from the top-level
+	// taxonomy section rather than under server.
+	Taxonomy metrics.Taxonomy `yaml:"-"`
+	// Fiscal is the fiscal calendar; it is set from the top-level fiscal
+	// section.
+	Fiscal metrics.FiscalCalendar `yaml:"-"`
+	// Scoring is the score model; it is set from the top-level scoring
+	// section.
+	Scoring metrics.ScoreModel `yaml:"-"`
+	// APIVersion is the collector API version ingestion follows; it is
+	// set from the top-level api_version setting.
+	APIVersion metrics.APIVersion `yaml:"-"`
+	// ReadOnly serves the store without changing it: ingestion is
+	// refused and the store is reloaded instead of collected and saved.
+	// It is set from the top-level read_only setting.
+	ReadOnly bool `yaml:"-"`
+	// Version is the secmetrics version reported in the OpenAPI document.
+	Version string `yaml:"-"`
+}
+
+// Defaults applied when a setting is not configured.
+const (
+	DefaultAddr            = ":9090"
+	DefaultInterval        = time.Hour
+	DefaultShutdownTimeout = 30 * time.Second
+	DefaultTenant          = "default"
+)
+
+// Source collects metrics into a collector.
+type Source struct {
+	Name    string
+	Collect func(ctx context.Context, collector *metrics.MetricsCollector) error
+	// Policy sets the retries, attempt timeout and circuit breaker of
+	// the source; see CollectSource.
+	Policy SourcePolicy
+}
+
+// ReportFunc renders a report of the given type from a collector, with
+// timestamps in location; nil keeps the configured report time zone.
+type ReportFunc func(collector *metrics.MetricsCollector, reportType string, location *time.Location) (string, error)
+
+// Server runs scheduled collection and serves the HTTP API.
+type Server struct {
+	addr            string
+	shutdownTimeout time.Duration
+	store           *store.FileStore
+	render          ReportFunc
+	taxonomy        metrics.Taxonomy
+	fiscal          metrics.FiscalCalendar
+	scoring         metrics.ScoreModel
+	apiVersion      metrics.APIVersion
+	telemetry       *Telemetry
+	logger          *log.Logger
+	clock           clock.Clock
+	readOnly        bool
+	tenant          string
+	auth            *authorizer
+	rateLimiter     *rateLimiter
+	gitops          *gitops
+	elector         *elector
+	shards          *sharding
+	redis           *redisLink
+	events          *eventHub
+	bus             *eventBus
+	siem            *siem.Writer
+	siemDetector    siemDetector
+	alerts          *alerting.Engine
+	// alertMu serializes changes to the alerting state, which is kept in
+	// alertState when there is no store.
+	alertMu    sync.Mutex
+	alertState alerting.State
+	approval   approval.Config
+	// approvalMu serializes changes to the approval state, which is kept
+	// in approvalState when there is no store; deliverMu keeps approved
+	// reports from being delivered twice.
+	approvalMu    sync.Mutex
+	approvalState approval.State
+	deliverMu     sync.Mutex
+	grcExporter   *grc.Exporter
+	tickets       *ticketing.Tracker
+	slack         *slackBot
+	deliver       DeliverFunc
+	archive       ArchiveFunc
+	routes        routeOptions
+	version       string
+
+	ingest *ingestQueue
+	// ready is set once state is loaded and cleared on shutdown.
+	ready atomic.Bool
+	// reloaded tells Run that Reload changed the collection interval.
+	reloaded chan struct{}
+
+	mu sync.RWMutex
+	// interval and sources change on Reload.
+	interval  time.Duration
+	sources   []Source
+	collector *metrics.MetricsCollector
+	// view is the merge of all shards' state when sharded.
+	view *metrics.MetricsCollector
+	// ingestedMetrics are the pushed metrics by ingestedMetricKey.
+	ingestedMetrics map[string]metrics.SecurityMetric
+	ingestedKPIs    map[metrics.KPIKey]metrics.KPI
+}
+
+// New creates a server. The store may be nil to keep state in memory only.
+func New(cfg Config, sources []Source, metricsStore *store.FileStore, render ReportFunc) (*Server, error) {
+	addr := cfg.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	interval := DefaultInterval
+	if cfg.Interval != "" {
+		var err error
+		interval, err = time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("server interval: %w", err)
+		}
+	}
+	shutdownTimeout := DefaultShutdownTimeout
+	if cfg.ShutdownTimeout != "" {
+		var err error
+		shutdownTimeout, err = time.ParseDuration(cfg.ShutdownTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("server shutdown_timeout: %w", err)
+		}
+	}
+
+	if err := cfg.Taxonomy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Fiscal.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Scoring.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.ReadOnly && metricsStore == nil {
+		return nil, fmt.Errorf("read-only mode requires a store to serve")
+	}
+	tenant := cfg.Tenant
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	auth, err := newAuthorizer(cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+	rateLimiter, err := newRateLimiter(cfg.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	gitops, err := newGitOps(cfg.GitOps)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := newSharding(cfg.Sharding, metricsStore)
+	if err != nil {
+		return nil, err
+	}
+	if shards != nil {
+		if cfg.ReadOnly {
+			return nil, fmt.Errorf("sharding cannot be combined with read-only mode; read-only instances serve the shared store as is")
+		}
+		// Each shard collects its own sources into its own partition,
+		// and the replicas of a shard elect a leader among themselves.
+		sources = shards.config.ownSources(sources, shards.index)
+		metricsStore = shards.partitions[shards.index]
+		if cfg.LeaderElection.Lease != "" {
+			cfg.LeaderElection.Lease = fmt.Sprintf("%s-%d", cfg.LeaderElection.Lease, shards.index)
+		}
+	}
+	redis, err := newRedisLink(cfg.Redis, tenant)
+	if err != nil {
+		return nil, err
+	}
+	bus, err := newEventBus(cfg.EventBus)
+	if err != nil {
+		return nil, err
+	}
+	siemWriter, err := siem.New(cfg.SIEM, cfg.Version)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := alerting.NewEngine(cfg.Alerting)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Approval.Validate(); err != nil {
+		return nil, fmt.Errorf("server %w", err)
+	}
+	grcExporter, err := grc.NewExporter(cfg.GRCExport)
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := ticketing.New(cfg.Ticketing)
+	if err != nil {
+		return nil, err
+	}
+	slack, err := newSlackBot(cfg.Slack)
+	if err != nil {
+		return nil, err
+	}
+	elector, err := newElector(cfg.LeaderElection)
+	if err != nil {
+		return nil, err
+	}
+	if elector != nil && metricsStore == nil {
+		return nil, fmt.Errorf("leader election requires a store shared by the replicas")
+	}
+
+	s := &Server{
+		addr:            addr,
+		interval:        interval,
+		shutdownTimeout: shutdownTimeout,
+		sources:         sources,
+		store:           metricsStore,
+		render:          render,
+		taxonomy:        cfg.Taxonomy,
+		fiscal:          cfg.Fiscal,
+		scoring:         cfg.Scoring,
+		apiVersion:      cfg.APIVersion,
+		telemetry:       NewTelemetry(),
+		logger:          log.New(os.Stderr, "secmetrics: ", log.LstdFlags),
+		clock:           clock.System,
+		readOnly:        cfg.ReadOnly,
+		tenant:          tenant,
+		auth:            auth,
+		rateLimiter:     rateLimiter,
+		gitops:          gitops,
+		elector:         elector,
+		shards:          shards,
+		redis:           redis,
+		events:          newEventHub(),
+		bus:             bus,
+		siem:            siemWriter,
+		alerts:          alerts,
+		approval:        cfg.Approval,
+		grcExporter:     grcExporter,
+		tickets:         tickets,
+		slack:           slack,
+		routes:          routeOptionsOf(cfg),
+		version:         cfg.Version,
+		ingestedMetrics: make(map[string]metrics.SecurityMetric),
+		ingestedKPIs:    make(map[metrics.KPIKey]metrics.KPI),
+		reloaded:        make(chan struct{}, 1),
+	}
+	s.collector = s.newCollector()
+	s.ingest = newIngestQueue(cfg.Ingest, s.applyIngest)
+	if bus != nil {
+		bus.start(s.publishBatch)
+	}
+	if elector != nil {
+		elector.logger = s.logger
+	}
+	if shards != nil {
+		names := make([]string, 0, len(sources))
+		for _, source := range sources {
+			names = append(names, source.Name)
+		}
+		s.logger.Printf("shard %d of %d: collecting %s", shards.index, shards.count, strings.Join(names, ", "))
+	}
+	return s, nil
+}
+
+// SetClock sets the time source for scheduled collection and collected
+// timestamps; the system clock is used by default. Call it before Run.
+func (s *Server) SetClock(clk clock.Clock) {
+	s.clock = clk
+	s.mu.Lock()
+	s.collector.SetClock(clk)
+	s.mu.Unlock()
+}
+
+// Clock returns the server's time source.
+func (s *Server) Clock() clock.Clock {
+	return s.clock
+}
+
+// newCollector creates a collector using the server's taxonomy, fiscal
+// calendar and score model, which New has already validated, API version,
+// clock and desired KPI definitions.
+func (s *Server) newCollector() *metrics.MetricsCollector {
+	collector := metrics.NewMetricsCollector()
+	collector.SetTaxonomy(s.taxonomy)
+	collector.SetFiscalCalendar(s.fiscal)
+	collector.SetScoreModel(s.scoring)
+	collector.SetAPIVersion(s.apiVersion)
+	collector.SetClock(s.clock)
+	s.applyDesiredDefinitions(collector)
+	return collector
+}
+
+// Telemetry returns the server's self-monitoring registry.
+func (s *Server) Telemetry() *Telemetry {
+	return s.telemetry
+}
+
+// Handler returns the HTTP handler for the API.
+func (s *Server) Handler() http.Handler {
+	byPath := make(map[string][]route)
+	var paths []string
+	for _, rt := range apiRoutes(s, s.routes) {
+		if _, ok := byPath[rt.path]; !ok {
+			paths = append(paths, rt.path)
+		}
+		byPath[rt.path] = append(byPath[rt.path], rt)
+	}
+	mux := http.NewServeMux()
+	for _, path := range paths {
+		mux.HandleFunc(path, s.dispatch(byPath[path]))
+	}
+	mux.HandleFunc("/openapi.json", s.handleOpenAPI)
+	return s.limit(mux)
+}
+
+// Run restores state, then serves HTTP and collects on the configured
+// interval until ctx is cancelled. On cancellation it stops scheduling,
+// lets in-flight requests finish within the shutdown timeout, flushes the
+// store and returns nil.
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.restore(); err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{Addr: s.addr, Handler: s.Handler()}
+	// Event streams never end by themselves.
+	httpServer.RegisterOnShutdown(s.events.close)
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Printf("listening on %s", s.addr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	// A read-only server shows what other instances collect into the
+	// store, so it reloads the store instead of collecting.
+	refresh := s.CollectOnce
+	if s.readOnly {
+		s.logger.Printf("read-only mode: serving %s without changes", s.store.Path())
+		refresh = func(context.Context) { s.reloadStore() }
+	}
+
+	// With leader election, followers serve what the leader collects into
+	// the shared store; a new leader starts from the store's latest state.
+	var leaseC <-chan time.Time
+	if s.elector != nil {
+		s.elector.tryAcquire(ctx, s.clock.Now())
+		s.telemetry.SetLeader(s.elector.isLeader())
+		collect := refresh
+		refresh = func(ctx context.Context) {
+			if s.following() {
+				s.reloadStore()
+				return
+			}
+			collect(ctx)
+		}
+		leaseTicker := s.clock.NewTicker(s.elector.retryPeriod())
+		defer leaseTicker.Stop()
+		leaseC = leaseTicker.C()
+	}
+
+	// Shards serve the merge of every shard's partition.
+	if s.shards != nil {
+		collect := refresh
+		refresh = func(ctx context.Context) {
+			collect(ctx)
+			s.refreshShards()
+		}
+	}
+
+	// Desired definitions apply from the first collection on; a change
+	// of revision applies at once.
+	var syncC <-chan time.Time
+	if s.gitops != nil {
+		s.syncGitOps(ctx)
+		syncTicker := s.clock.NewTicker(s.gitops.interval)
+		defer syncTicker.Stop()
+		syncC = syncTicker.C()
+	}
+
+	if s.redis != nil {
+		go s.relayEvents(ctx)
+	}
+
+	// Records are pushed one interval after start, from collected state.
+	var grcC <-chan time.Time
+	if s.grcExporter != nil {
+		grcTicker := s.clock.NewTicker(s.grcExporter.Interval())
+		defer grcTicker.Stop()
+		grcC = grcTicker.C()
+	}
+
+	refresh(ctx)
+	s.ready.Store(true)
+	ticker := s.clock.NewTicker(s.collectionInterval())
+	defer func() { ticker.Stop() }()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return s.shutdown(httpServer)
+		case err := <-errCh:
+			return err
+		case <-ticker.C():
+			refresh(ctx)
+		case <-s.reloaded:
+			ticker.Stop()
+			ticker = s.clock.NewTicker(s.collectionInterval())
+		case <-syncC:
+			if s.syncGitOps(ctx) {
+				refresh(ctx)
+			}
+			s.updateDrift()
+			if s.runsReports() {
+				s.runReports(ctx)
+				s.deliverApproved(ctx)
+			}
+		case <-grcC:
+			s.pushGRCRecords(ctx)
+		case <-leaseC:
+			if s.elector.tryAcquire(ctx, s.clock.Now()) {
+				s.reloadStore()
+			}
+			s.telemetry.SetLeader(s.elector.isLeader())
+		}
+	}
+}
+
+// restore replaces the collector with one holding the store's state. The
+// state is loaded into a fresh collector, so handlers reading the current
+// one are not raced.
+func (s *Server) restore() error {
+	if s.store == nil {
+		return nil
+	}
+	snapshot, err := s.store.Load()
+	if err != nil {
+		return err
+	}
+	// Shard 0 starts from the unsharded store when sharding is first
+	// enabled, keeping history and archived KPIs.
+	if s.shards != nil && s.shards.index == 0 && snapshot.SavedAt.IsZero() {
+		if snapshot, err = s.shards.base.Load(); err != nil {
+			return err
+		}
+	}
+	collector := s.newCollector()
+	collector.RestoreLifecycles(snapshot.Lifecycles, snapshot.Audit)
+	collector.RestoreScores(snapshot.Scores)
+	collector.RestoreRuns(snapshot.Runs)
+	collector.Restore(snapshot.Metrics, snapshot.KPIs, snapshot.History)
+	collector.RestoreEvents(snapshot.Incidents, snapshot.Alerts)
+	s.mu.Lock()
+	s.collector = collector
+	s.mu.Unlock()
+	s.updateStoreSize()
+	return nil
+}
+
+// reloadStore replaces the current state with the store's content.
+func (s *Server) reloadStore() {
+	collector := s.newCollector()
+	if err := s.store.LoadInto(collector); err != nil {
+		s.logger.Printf("reload store: %v", err)
+		return
+	}
+	s.mu.Lock()
+	s.collector = collector
+	s.mu.Unlock()
+	s.updateStoreSize()
+	s.stateChanged()
+}
+
+// shutdown stops the HTTP server gracefully and flushes the store.
+func (s *Server) shutdown(httpServer *http.Server) error {
+	s.logger.Printf("shutting down (timeout %s)", s.shutdownTimeout)
+	s.ready.Store(false)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	err := httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		s.logger.Printf("in-flight requests did not finish: %v", err)
+		httpServer.Close()
+	}
+
+	if err := s.release(); err != nil {
+		return err
+	}
+	s.logger.Printf("shutdown complete")
+	return nil
+}
+
+// release applies pending ingestion, flushes the store and closes the
+// connections to the lease, Redis, the SIEM and the event bus.
+func (s *Server) release() error {
+	// Apply everything already accepted for ingestion before flushing.
+	s.ingest.close()
+
+	if s.store != nil && !s.readOnly && !s.following() {
+		s.mu.RLock()
+		saveErr := s.store.SaveFrom(s.collector)
+		s.mu.RUnlock()
+		if saveErr != nil {
+			return fmt.Errorf("flush store: %w", saveErr)
+		}
+	}
+	if s.elector != nil {
+		s.elector.release(context.Background())
+	}
+	if s.redis != nil {
+		s.redis.client.Close()
+	}
+	if s.siem != nil {
+		s.siem.Close()
+	}
+	if s.bus != nil {
+		// Publish the events of the final ingestion and collection.
+		busCtx, cancel := context.WithTimeout(context.Background(), busPublishTimeout)
+		s.bus.close(busCtx)
+		cancel()
+	}
+	return nil
+}
+
+// CollectOnce runs every source once and persists the result.
+func (s *Server) CollectOnce(ctx context.Context) {
+	collector := s.newCollector()
+
+	s.mu.RLock()
+	previousAlerts := s.collector.GetAlerts()
+	previousKPIs := append([]metrics.KPI(nil), s.collector.GetKPIS()...)
+	history := s.collector.GetHistory()
+	if s.store != nil {
+		// History older than the window lives in the history database.
+		history = s.store.TrimHistory(history, s.clock.Now())
+	}
+	collector.RestoreLifecycles(s.collector.GetKPILifecycles(), s.collector.GetAuditTrail())
+	collector.RestoreScores(s.collector.GetScoreHistory())
+	collector.RestoreRuns(s.collector.GetRuns())
+	collector.Restore(nil, s.collector.GetArchivedKPIs(), history)
+	collector.RestoreEvents(s.collector.GetIncidents(), previousAlerts)
+	// Reload may replace the sources during the collection.
+	sources := s.sources
+	ingestedMetrics := s.carriedIngested(s.clock.Now())
+	ingestedKPIs := make([]metrics.KPI, 0, len(s.ingestedKPIs))
+	for _, kpi := range s.ingestedKPIs {
+		ingestedKPIs = append(ingestedKPIs, kpi)
+	}
+	s.mu.RUnlock()
+
+	run := collector.StartRun()
+	for _, source := range sources {
+		err := CollectSource(ctx, collector, run, source)
+		s.telemetry.ObserveCollection(source.Name, run.Sources[len(run.Sources)-1].Duration, err)
+		if err != nil {
+			s.logger.Printf("collect %s: %v", source.Name, err)
+		}
+	}
+
+	// A collection interrupted by shutdown is incomplete; keep the
+	// previous state rather than persisting a partial result.
+	if ctx.Err() != nil {
+		s.logger.Printf("collection interrupted, discarding partial results")
+		return
+	}
+	if collected := collector.GetMetrics(); len(collected) > 0 || len(collector.GetKPIS()) > 0 {
+		s.publishEvent(EventMetricIngested, MetricsIngested{Source: "collection", Metrics: collected, KPIs: collector.GetKPIS()})
+	}
+	for _, alert := range firedAlerts(previousAlerts, collector.GetAlerts()) {
+		s.publishEvent(EventAlertFired, alert)
+	}
+
+	// Keep the values of failed sources, marked stale, rather than
+	// dropping their KPIs until the next successful collection.
+	collector.RetainStaleKPIs(previousKPIs, run)
+	if stale := collector.GetStaleKPIs(); len(stale) > 0 {
+		s.logger.Printf("collection %s: keeping %d stale KPIs of failed sources", run.Status(), len(stale))
+	}
+
+	// Ingested values are pushed, not collected, so carry them over.
+	for _, metric := range ingestedMetrics {
+		collector.AddMetric(metric)
+	}
+	for _, kpi := range ingestedKPIs {
+		collector.AddKPI(kpi)
+	}
+	s.addAlertMTTA(collector)
+	s.reconcileArchived(collector)
+	s.reconcileLifecycles(collector)
+	collector.RecordScores()
+	collector.FinishRun(run)
+
+	s.mu.Lock()
+	// Alerts recorded or acknowledged while collecting would be lost.
+	for _, alert := range s.collector.GetAlerts() {
+		if alert.Source == alertSource {
+			collector.AddAlert(alert)
+		}
+	}
+	s.collector = collector
+	s.mu.Unlock()
+
+	if s.store != nil {
+		if err := s.store.SaveFrom(collector); err != nil {
+			s.logger.Printf("save store: %v", err)
+		}
+		s.updateStoreSize()
+	}
+	s.stateChanged()
+}
+
+// updateStoreSize refreshes the store size gauge.
+func (s *Server) updateStoreSize() {
+	if info, err := os.Stat(s.store.Path()); err == nil {
+		s.telemetry.SetStoreSize(info.Size())
+	}
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+
+	s.mu.RLock()
+	summary := *s.served().GetSummary()
+	kpis := s.served().GetKPIS()
+	s.mu.RUnlock()
+
+	b.WriteString("# HELP secmetrics_kpi_value Current KPI value.\n")
+	b.WriteString("# TYPE secmetrics_kpi_value gauge\n")
+	for _, kpi := range kpis {
+		fmt.Fprintf(&b, "secmetrics_kpi_value{key=%q,category=%q} %g\n", kpi.Key, kpi.Category, kpi.Value)
+	}
+	b.WriteString("# HELP secmetrics_kpi_target KPI target value.\n")
+	b.WriteString("# TYPE secmetrics_kpi_target gauge\n")
+	for _, kpi := range kpis {
+		fmt.Fprintf(&b, "secmetrics_kpi_target{key=%q,category=%q} %g\n", kpi.Key, kpi.Category, kpi.Target)
+	}
+	b.WriteString("# HELP secmetrics_kpi_stale Whether a KPI is kept from an earlier collection because its source failed (1) or current (0).\n")
+	b.WriteString("# TYPE secmetrics_kpi_stale gauge\n")
+	for _, kpi := range kpis {
+		value := 0
+		if kpi.Stale {
+			value = 1
+		}
+		fmt.Fprintf(&b, "secmetrics_kpi_stale{key=%q,category=%q} %d\n", kpi.Key, kpi.Category, value)
+	}
+	b.WriteString("# HELP secmetrics_compliance_score Overall compliance score.\n")
+	b.WriteString("# TYPE secmetrics_compliance_score gauge\n")
+	fmt.Fprintf(&b, "secmetrics_compliance_score %g\n", summary.ComplianceScore)
+	b.WriteString("# HELP secmetrics_risk_score Overall risk score.\n")
+	b.WriteString("# TYPE secmetrics_risk_score gauge\n")
+	fmt.Fprintf(&b, "secmetrics_risk_score %g\n", summary.RiskScore)
+	b.WriteString("# HELP secmetrics_vulnerability_score Overall vulnerability score.\n")
+	b.WriteString("# TYPE secmetrics_vulnerability_score gauge\n")
+	fmt.Fprintf(&b, "secmetrics_vulnerability_score %g\n", summary.VulnerabilityScore)
+	b.WriteString("# HELP secmetrics_score_has_data Whether a score was computed from data (1) or is 0 for lack of it (0).\n")
+	b.WriteString("# TYPE secmetrics_score_has_data gauge\n")
+	for _, score := range []struct {
+		name    string
+		hasData bool
+	}{
+		{"compliance", summary.HasData.Compliance},
+		{"risk", summary.HasData.Risk},
+		{"vulnerability", summary.HasData.Vulnerability},
+		{"posture", summary.HasData.Posture},
+	} {
+		value := 0
+		if score.hasData {
+			value = 1
+		}
+		fmt.Fprintf(&b, "secmetrics_score_has_data{score=%q} %d\n", score.name, value)
+	}
+
+	s.telemetry.WritePrometheus(&b)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
+	if data, ok := s.cachedSummary(r.Context()); ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+		return
+	}
+	s.mu.RLock()
+	summary := *s.served().GetSummary()
+	s.mu.RUnlock()
+	if data, err := json.Marshal(summary); err == nil {
+		s.cacheSummary(r.Context(), append(data, '\n'))
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) handleKPIs(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	kpis := s.served().GetKPIS()
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, kpis)
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	audit := append([]metrics.AuditEntry{}, s.served().GetAuditTrail()...)
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, audit)
+}
+
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	runs := append([]metrics.CollectionRun{}, s.served().GetRuns()...)
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// handleCollect runs a collection immediately and returns the new summary.
+func (s *Server) handleCollect(w http.ResponseWriter, r *http.Request) {
+	if s.readOnly {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "server is read-only"})
+		return
+	}
+	if s.following() {
+		s.refuseFollower(w)
+		return
+	}
+	s.CollectOnce(r.Context())
+	s.refreshShards()
+	s.handleSummary(w, r)
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	reportType := r.URL.Query().Get("type")
+	if reportType == "" {
+		reportType = "technical"
+	}
+
+	// tz lets each reader see timestamps in their own zone
+	var location *time.Location
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		if location, err = time.LoadLocation(tz); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid time zone %q", tz)})
+			return
+		}
+	}
+
+	start := time.Now()
+	s.mu.RLock()
+	content, err := s.render(s.served(), reportType, location)
+	s.mu.RUnlock()
+	s.telemetry.ObserveReport(reportType, time.Since(start))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	s.publishEvent(EventReportGenerated, ReportGenerated{ReportType: reportType, Bytes: len(content)})
+
+	if reportType == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Write([]byte(content))
+}
+
+// writeJSON writes v as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,383 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+func TestSchedulerUsesClock(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	collected := make(chan time.Time, 10)
+	source := Source{Name: "test", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 2, Target: 1})
+		collected <- clk.Now()
+		return nil
+	}}
+
+	srv, err := New(Config{Addr: "127.0.0.1:0", Interval: "1h"}, []Source{source}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+	srv.SetClock(clk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+
+	if at := waitCollection(t, collected); !at.Equal(start) {
+		t.Fatalf("initial collection at %v, want %v", at, start)
+	}
+	select {
+	case at := <-collected:
+		t.Fatalf("collected at %v before the interval elapsed", at)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Run creates its ticker after the initial collection, so keep
+	// advancing until the scheduled collection happens.
+	var at time.Time
+	deadline := time.Now().Add(5 * time.Second)
+	for at.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("advancing the clock did not trigger a scheduled collection")
+		}
+		clk.Advance(time.Hour)
+		select {
+		case at = <-collected:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	if at.Sub(start) < time.Hour {
+		t.Fatalf("scheduled collection at %v, want at least an hour after %v", at, start)
+	}
+
+	srv.mu.RLock()
+	kpi := srv.collector.GetKPI(metrics.KPI_MTTR)
+	srv.mu.RUnlock()
+	if kpi == nil || !kpi.LastUpdated.Equal(at) {
+		t.Errorf("collected KPI = %+v, want LastUpdated %v", kpi, at)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+// waitCollection waits for the next collection.
+func waitCollection(t *testing.T, collected <-chan time.Time) time.Time {
+	t.Helper()
+	select {
+	case at := <-collected:
+		return at
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for collection")
+		return time.Time{}
+	}
+}
+
+func TestReadOnlyServesStoreWithoutChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	metricsStore := store.NewFileStore(path, nil)
+	if err := metricsStore.Save(&store.Snapshot{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Value: 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := Source{Name: "test", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		t.Error("read-only server ran a collection")
+		return nil
+	}}
+	srv, err := New(Config{Addr: "127.0.0.1:0", ReadOnly: true}, []Source{source}, metricsStore, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{"kpis":[{"Key":"mttr","Value":9}]}`)))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("ingest status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		srv.mu.RLock()
+		kpi := srv.collector.GetKPI(metrics.KPI_MTTR)
+		srv.mu.RUnlock()
+		if kpi != nil && kpi.Value == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("read-only server did not serve the stored KPI")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("read-only server rewrote the store")
+	}
+}
+
+func TestIngestFollowsAPIVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version metrics.APIVersion
+		want    int
+	}{
+		{"", http.StatusAccepted},
+		{metrics.APIv1, http.StatusAccepted},
+		{metrics.APIv2, http.StatusBadRequest},
+	} {
+		// Valid under APIv1, which accepts percentages above 100
+		srv, err := New(Config{APIVersion: tc.version}, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		body := `{"metrics":[{"ID":"m1","Name":"Open findings","Value":3}],"kpis":[{"Key":"coverage","Value":140}]}`
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		if rec.Code != tc.want {
+			t.Errorf("api version %q: ingest status = %d, want %d: %s", tc.version, rec.Code, tc.want, rec.Body)
+		}
+		// Invalid under either version
+		rec = httptest.NewRecorder()
+		body = `{"metrics":[{"ID":"m1","Value":-3,"Unit":"findings"}]}`
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		srv.ingest.close()
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("api version %q: invalid metric ingest status = %d, want %d", tc.version, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestRepeatedCollectionKeepsOneKPIPerKey(t *testing.T) {
+	source := Source{Name: "test", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 5, Target: 4})
+		collector.AddKPI(metrics.KPI{Key: metrics.KPI_Coverage, Value: 80, Target: 95})
+		return nil
+	}}
+	srv, err := New(Config{}, []Source{source}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+
+	ctx := context.Background()
+	srv.CollectOnce(ctx)
+	// A pushed value overrides the collected one in every later run
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_Coverage, Value: 96, Target: 95}}})
+	srv.CollectOnce(ctx)
+	srv.CollectOnce(ctx)
+
+	kpis := srv.collector.GetKPIS()
+	if len(kpis) != 2 {
+		t.Fatalf("KPIs after three runs = %+v, want mttr and coverage once each", kpis)
+	}
+	if coverage := srv.collector.GetKPI(metrics.KPI_Coverage); coverage.Value != 96 {
+		t.Errorf("coverage = %v, want the ingested 96", coverage.Value)
+	}
+}
+
+func TestCollectionRecordsProvenance(t *testing.T) {
+	source := Source{Name: "scanner", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		collector.AddMetric(metrics.SecurityMetric{ID: "vulns", Name: "Open Vulnerabilities", Value: 3,
+			Provenance: metrics.Provenance{Record: "scan-42"}})
+		return nil
+	}}
+	srv, err := New(Config{}, []Source{source}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+
+	ctx := context.Background()
+	srv.applyIngest(IngestBatch{Metrics: []metrics.SecurityMetric{{ID: "pushed", Name: "Pushed", Value: 1}}})
+	srv.CollectOnce(ctx)
+
+	provenance := make(map[string]metrics.Provenance)
+	for _, metric := range srv.collector.GetMetrics() {
+		provenance[metric.ID] = metric.Provenance
+	}
+	collected, pushed := provenance["vulns"], provenance["pushed"]
+	if collected.Source != "scanner" || collected.Record != "scan-42" || collected.RunID == "" {
+		t.Errorf("collected provenance = %+v", collected)
+	}
+	// Carried-over ingested metrics keep the ingest batch as their run
+	if pushed.Source != "ingest" || pushed.RunID == "" || pushed.RunID == collected.RunID {
+		t.Errorf("ingested provenance = %+v", pushed)
+	}
+}
+
+func TestIngestedMetricsCarryOver(t *testing.T) {
+	srv, err := New(Config{}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+	srv.SetClock(clk)
+
+	ctx := context.Background()
+	srv.applyIngest(IngestBatch{Metrics: []metrics.SecurityMetric{{ID: "pushed", Name: "Pushed", Value: 1}, {ID: "once", Name: "Once", Value: 5}}})
+	clk.Advance(time.Hour)
+	srv.applyIngest(IngestBatch{Metrics: []metrics.SecurityMetric{{ID: "pushed", Name: "Pushed", Value: 2}}})
+	clk.Advance(time.Hour)
+	srv.CollectOnce(ctx)
+	srv.CollectOnce(ctx)
+
+	carried := make(map[string][]metrics.SecurityMetric)
+	for _, metric := range srv.collector.GetMetrics() {
+		carried[metric.ID] = append(carried[metric.ID], metric)
+	}
+	// A later push replaces the earlier one, and each keeps its push time
+	if pushed := carried["pushed"]; len(pushed) != 1 || pushed[0].Value != 2 || !pushed[0].Timestamp.Equal(start.Add(time.Hour)) {
+		t.Errorf("pushed = %+v", pushed)
+	}
+	if once := carried["once"]; len(once) != 1 || !once[0].Timestamp.Equal(start) {
+		t.Errorf("once = %+v", once)
+	}
+
+	clk.Advance(ingestedMetricRetention - time.Hour - time.Minute)
+	srv.applyIngest(IngestBatch{Metrics: []metrics.SecurityMetric{{ID: "other", Name: "Other", Value: 3}}})
+	srv.CollectOnce(ctx)
+	ids := make(map[string]bool)
+	for _, metric := range srv.collector.GetMetrics() {
+		ids[metric.ID] = true
+	}
+	if ids["once"] || !ids["pushed"] || !ids["other"] {
+		t.Errorf("metrics after the retention of once = %v", ids)
+	}
+	if len(srv.ingestedMetrics) != 2 {
+		t.Errorf("ingested metrics kept = %d, want 2", len(srv.ingestedMetrics))
+	}
+}
+
+func TestKPIReadsDoNotRaceIngest(t *testing.T) {
+	srv, err := New(Config{}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Value: 5}}})
+
+	// Run with -race: responses are encoded after the lock is released
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Value: float64(i)}}})
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		for _, path := range []string{"/api/kpis", "/metrics"} {
+			rec := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET %s: %d", path, rec.Code)
+			}
+		}
+	}
+	<-done
+}
+
+func TestCollectionRecordsRuns(t *testing.T) {
+	sources := []Source{
+		{Name: "scanner", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			collector.AddMetric(metrics.SecurityMetric{ID: "vulns", Name: "Open Vulnerabilities", Value: 3})
+			return nil
+		}},
+		{Name: "fleetdm", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			return errors.New("503 Service Unavailable")
+		}},
+	}
+	srv, err := New(Config{}, sources, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+
+	ctx := context.Background()
+	srv.CollectOnce(ctx)
+	srv.CollectOnce(ctx)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/runs", nil))
+	var runs []metrics.CollectionRun
+	if err := json.Unmarshal(rec.Body.Bytes(), &runs); err != nil {
+		t.Fatalf("GET /api/runs: %d %s", rec.Code, rec.Body)
+	}
+	if len(runs) != 2 || runs[0].ID == runs[1].ID {
+		t.Fatalf("runs = %+v, want one per collection", runs)
+	}
+	if run := runs[1]; run.Status() != "partial" || run.Records() != 1 || run.Failed()[0].Error != "503 Service Unavailable" {
+		t.Errorf("run = %+v", run)
+	}
+}
+
+func TestFailedSourceKeepsStaleKPIs(t *testing.T) {
+	var down bool
+	source := Source{Name: "fleetdm", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		if down {
+			return errors.New("503 Service Unavailable")
+		}
+		collector.AddKPI(metrics.KPI{Key: "endpoint_coverage", Value: 40, Target: 95})
+		return nil
+	}}
+	srv, err := New(Config{}, []Source{source}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.logger = log.New(io.Discard, "", 0)
+
+	ctx := context.Background()
+	srv.CollectOnce(ctx)
+	down = true
+	srv.CollectOnce(ctx)
+
+	kpi := srv.collector.GetKPI("endpoint_coverage")
+	if kpi == nil || !kpi.Stale || kpi.Value != 40 {
+		t.Fatalf("kpi after failed collection = %+v, want the stale previous value", kpi)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `secmetrics_kpi_stale{key="endpoint_coverage",category=""} 1`) {
+		t.Errorf("/metrics lacks the stale KPI:\n%s", rec.Body)
+	}
+
+	down = false
+	srv.CollectOnce(ctx)
+	if kpi := srv.collector.GetKPI("endpoint_coverage"); kpi == nil || kpi.Stale {
+		t.Errorf("kpi after recovery = %+v", kpi)
+	}
+}
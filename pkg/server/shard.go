@@ -0,0 +1,194 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+// ShardingConfig splits the configured sources between several instances
+// sharing a store, e.g. the pods of a StatefulSet. Each source is
+// collected by exactly one shard, which saves it to its own partition of
+// the store; every shard serves the merge of all partitions.
+type ShardingConfig struct {
+	// Shards is the number of instances; 0 or 1 disables sharding.
+	Shards int `yaml:"shards"`
+	// Shard is this instance's index from 0. It defaults to the ordinal
+	// at the end of the host name, e.g. 2 for pod secmetrics-2.
+	Shard *int `yaml:"shard"`
+	// Pins assign sources to shards by name, overriding the hash, e.g. to
+	// keep the derived source with the sources providing its inputs.
+	Pins map[string]int `yaml:"pins"`
+}
+
+// sharding is this instance's share of the sources and the partitions of
+// the shared store.
+type sharding struct {
+	index int
+	count int
+	// config assigns the sources to shards; it is fixed for the life of
+	// the server, as every shard must agree on it.
+	config ShardingConfig
+	// base is the shared store, which holds the merged state for the CLI
+	// and for read-only instances.
+	base       *store.FileStore
+	partitions []*store.FileStore
+	// others are the partitions of the other shards as last loaded,
+	// guarded by the server's mu.
+	others []*store.Snapshot
+}
+
+// newSharding validates cfg. It returns nil when sharding is not
+// configured.
+func newSharding(cfg ShardingConfig, base *store.FileStore) (*sharding, error) {
+	if cfg.Shards <= 1 {
+		return nil, nil
+	}
+	if base == nil {
+		return nil, fmt.Errorf("sharding requires a store shared by the shards")
+	}
+	index, err := shardIndex(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= cfg.Shards {
+		return nil, fmt.Errorf("server sharding: shard %d is out of range for %d shards", index, cfg.Shards)
+	}
+	for name, pin := range cfg.Pins {
+		if pin < 0 || pin >= cfg.Shards {
+			return nil, fmt.Errorf("server sharding: source %s is pinned to shard %d, out of range for %d shards", name, pin, cfg.Shards)
+		}
+	}
+	sh := &sharding{index: index, count: cfg.Shards, config: cfg, base: base}
+	for i := 0; i < cfg.Shards; i++ {
+		sh.partitions = append(sh.partitions, base.Partition(i))
+	}
+	return sh, nil
+}
+
+// shardIndex returns the configured shard index or the host name's
+// ordinal.
+func shardIndex(cfg ShardingConfig) (int, error) {
+	if cfg.Shard != nil {
+		return *cfg.Shard, nil
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return 0, fmt.Errorf("server sharding: %w", err)
+	}
+	_, ordinal, found := cutLast(host, "-")
+	index, err := strconv.Atoi(ordinal)
+	if !found || err != nil {
+		return 0, fmt.Errorf("server sharding: host name %q has no ordinal; set shard", host)
+	}
+	return index, nil
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// shardOf returns the shard collecting the named source: its pin, or the
+// shard with the highest rendezvous hash, so changing the number of
+// shards moves as few sources as possible.
+func (cfg ShardingConfig) shardOf(name string) int {
+	if pin, ok := cfg.Pins[name]; ok {
+		return pin
+	}
+	best, bestHash := 0, uint64(0)
+	for i := 0; i < cfg.Shards; i++ {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s/%d", name, i)
+		if sum := h.Sum64(); i == 0 || sum > bestHash {
+			best, bestHash = i, sum
+		}
+	}
+	return best
+}
+
+// ownSources returns the sources assigned to shard index.
+func (cfg ShardingConfig) ownSources(sources []Source, index int) []Source {
+	var own []Source
+	for _, source := range sources {
+		if cfg.shardOf(source.Name) == index {
+			own = append(own, source)
+		}
+	}
+	return own
+}
+
+// served returns the state the API serves: the merge of all shards when
+// sharded, the collector otherwise. Callers hold s.mu.
+func (s *Server) served() *metrics.MetricsCollector {
+	if s.view != nil {
+		return s.view
+	}
+	return s.collector
+}
+
+// refreshShards reloads the other shards' partitions and rebuilds the
+// merged view. Shard 0 also writes the merge to the shared store, unless
+// another replica of it leads.
+func (s *Server) refreshShards() {
+	if s.shards == nil {
+		return
+	}
+	var others []*store.Snapshot
+	for i, partition := range s.shards.partitions {
+		if i == s.shards.index {
+			continue
+		}
+		snapshot, err := partition.Load()
+		if err != nil {
+			s.logger.Printf("load shard %d: %v", i, err)
+			continue
+		}
+		others = append(others, snapshot)
+	}
+	s.mu.Lock()
+	s.shards.others = others
+	s.mu.Unlock()
+	merged := s.rebuildView()
+
+	if s.shards.index == 0 && !s.following() {
+		if err := s.shards.base.Save(merged); err != nil {
+			s.logger.Printf("save merged store: %v", err)
+		}
+	}
+}
+
+// rebuildView merges this shard's state with the other shards' last
+// loaded partitions into the served view and returns the merge.
+func (s *Server) rebuildView() *store.Snapshot {
+	s.mu.RLock()
+	snapshots := append([]*store.Snapshot{store.SnapshotOf(s.collector)}, s.shards.others...)
+	s.mu.RUnlock()
+	merged := store.Merge(snapshots...)
+
+	view := s.newCollector()
+	view.RestoreLifecycles(merged.Lifecycles, merged.Audit)
+	view.RestoreScores(merged.Scores)
+	view.RestoreRuns(merged.Runs)
+	view.Restore(merged.Metrics, merged.KPIs, merged.History)
+	view.RestoreEvents(merged.Incidents, merged.Alerts)
+	s.mu.Lock()
+	s.view = view
+	s.mu.Unlock()
+	s.stateChanged()
+	return merged
+}
+
+// runsReports reports whether this instance delivers scheduled reports:
+// the leader of shard 0, or the leader when not sharded.
+func (s *Server) runsReports() bool {
+	return !s.following() && (s.shards == nil || s.shards.index == 0)
+}
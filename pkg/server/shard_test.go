@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/store"
+)
+
+func TestShardAssignment(t *testing.T) {
+	cfg := ShardingConfig{Shards: 3, Pins: map[string]int{"derived": 1}}
+	counts := make([]int, cfg.Shards)
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("plugin:%d", i)
+		shard := cfg.shardOf(name)
+		if shard != cfg.shardOf(name) {
+			t.Fatalf("%s is assigned inconsistently", name)
+		}
+		counts[shard]++
+	}
+	for shard, n := range counts {
+		if n < 50 {
+			t.Errorf("shard %d collects %d of 300 sources", shard, n)
+		}
+	}
+	if cfg.shardOf("derived") != 1 {
+		t.Error("pin ignored")
+	}
+
+	// Adding a shard only moves sources to the new shard.
+	grown := ShardingConfig{Shards: 4}
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("plugin:%d", i)
+		if before, after := cfg.shardOf(name), grown.shardOf(name); after != before && after != 3 {
+			t.Errorf("%s moved from shard %d to %d", name, before, after)
+		}
+	}
+}
+
+func TestShardsServeMergedState(t *testing.T) {
+	base := store.NewFileStore(filepath.Join(t.TempDir(), "store.json"), nil)
+	collected := make(map[string]int)
+	sources := []Source{
+		{Name: "edr", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			collected["edr"]++
+			collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 4, Target: 2})
+			return nil
+		}},
+		{Name: "scanner", Collect: func(ctx context.Context, collector *metrics.MetricsCollector) error {
+			collected["scanner"]++
+			collector.AddKPI(metrics.KPI{Key: metrics.KPI_Compliance, Value: 90, Target: 95})
+			return nil
+		}},
+	}
+
+	var shards []*Server
+	for i := 0; i < 2; i++ {
+		index := i
+		cfg := Config{Sharding: ShardingConfig{Shards: 2, Shard: &index, Pins: map[string]int{"edr": 0, "scanner": 1}}}
+		srv, err := New(cfg, sources, base, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv.logger = log.New(io.Discard, "", 0)
+		shards = append(shards, srv)
+	}
+	for _, srv := range shards {
+		srv.CollectOnce(context.Background())
+	}
+	for _, srv := range shards {
+		srv.refreshShards()
+	}
+	if collected["edr"] != 1 || collected["scanner"] != 1 {
+		t.Errorf("collections = %v, want each source once", collected)
+	}
+
+	for i, srv := range shards {
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/kpis", nil))
+		body := rec.Body.String()
+		if !strings.Contains(body, string(metrics.KPI_MTTR)) || !strings.Contains(body, string(metrics.KPI_Compliance)) {
+			t.Errorf("shard %d serves %s, want both KPIs", i, body)
+		}
+	}
+
+	merged, err := base.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.KPIs) != 2 || len(merged.History) != 2 {
+		t.Errorf("shared store holds %d KPIs and %d samples, want 2 of each", len(merged.KPIs), len(merged.History))
+	}
+	own, err := base.Partition(1).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(own.KPIs) != 1 || own.KPIs[0].Key != metrics.KPI_Compliance {
+		t.Errorf("shard 1 partition = %+v", own.KPIs)
+	}
+
+	index := 2
+	if _, err := New(Config{Sharding: ShardingConfig{Shards: 2, Shard: &index}}, sources, base, nil); err == nil {
+		t.Error("shard index out of range accepted")
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/siem"
+)
+
+// siemDetector turns KPI threshold crossings and overall health changes
+// into SIEM events.
+type siemDetector struct {
+	mu sync.Mutex
+	// bands holds each KPI's threshold band ("", "warning" or
+	// "critical") as of the last detection; nil until the first state is
+	// known, which is the baseline rather than a change.
+	bands  map[metrics.KPIKey]string
+	health string
+	// rules holds whether each composite rule held as of the last
+	// detection.
+	rules map[string]bool
+}
+
+// thresholdBand returns the band of the KPI's alert rules that value
+// falls in: "critical", "warning" or "" within bounds. As with the alert
+// rules, lower-is-better KPIs breach above a threshold and the rest below.
+func thresholdBand(def metrics.KPIDefinition, value float64) string {
+	breaches := func(threshold *float64) bool {
+		if threshold == nil {
+			return false
+		}
+		if def.Direction == metrics.LowerIsBetter {
+			return value > *threshold
+		}
+		return value < *threshold
+	}
+	switch {
+	case breaches(def.CriticalThreshold):
+		return "critical"
+	case breaches(def.WarningThreshold):
+		return "warning"
+	}
+	return ""
+}
+
+// healthSeverity is the CEF severity of each overall health.
+var healthSeverity = map[string]int{"HEALTHY": 1, "GOOD": 3, "FAIR": 5, "POOR": 8, metrics.InsufficientData: 3}
+
+// detect returns the threshold crossings, composite rule changes and
+// health change from the last detected state to collector as alerts. When
+// a KPI key occurs more than once, its last occurrence is current.
+func (d *siemDetector) detect(collector *metrics.MetricsCollector, composites []*alerting.Composite, now time.Time) []alerting.Alert {
+	current := make(map[metrics.KPIKey]metrics.KPI)
+	var keys []metrics.KPIKey
+	for _, kpi := range collector.GetKPIS() {
+		if _, ok := current[kpi.Key]; !ok {
+			keys = append(keys, kpi.Key)
+		}
+		current[kpi.Key] = kpi
+	}
+	bands := make(map[metrics.KPIKey]string, len(keys))
+	for _, key := range keys {
+		if def, ok := collector.GetKPIDefinition(key); ok {
+			bands[key] = thresholdBand(def, current[key].Value)
+		}
+	}
+	health := collector.GetSummary().OverallHealth
+	rules := make(map[string]bool, len(composites))
+	for _, composite := range composites {
+		rules[composite.Name] = composite.Holds(collector, now)
+	}
+
+	d.mu.Lock()
+	lastBands, lastHealth, lastRules := d.bands, d.health, d.rules
+	d.bands, d.health, d.rules = bands, health, rules
+	d.mu.Unlock()
+	if lastBands == nil {
+		return nil
+	}
+
+	var alerts []alerting.Alert
+	for _, key := range keys {
+		band, ok := bands[key]
+		if !ok || band == lastBands[key] {
+			continue
+		}
+		kpi := current[key]
+		def, _ := collector.GetKPIDefinition(key)
+		name := kpi.Name
+		if name == "" {
+			name = def.Name
+		}
+		if name == "" {
+			name = string(key)
+		}
+		fields := []siem.Field{
+			{Name: "kpi", Value: string(key)},
+			{Name: "value", Value: strconv.FormatFloat(kpi.Value, 'g', -1, 64)},
+			{Name: "unit", Value: kpi.Unit},
+			{Name: "previousBand", Value: bandName(lastBands[key])},
+		}
+		event := siem.Event{Time: now, Category: kpi.Category, Fields: fields}
+		category, _ := collector.CategoryPath(kpi)
+		alert := alerting.Alert{Key: "kpi_recovered/" + string(key), KPI: key, Category: category, Severity: band}
+		switch band {
+		case "":
+			event.Type, event.Severity = "kpi_recovered", 3
+			event.Name = name + " recovered"
+			event.Message = fmt.Sprintf("%s is back within its thresholds at %g %s", name, kpi.Value, kpi.Unit)
+		default:
+			threshold := def.WarningThreshold
+			event.Type, event.Severity = "kpi_threshold", 5
+			if band == "critical" {
+				threshold, event.Severity = def.CriticalThreshold, 8
+			}
+			event.Name = fmt.Sprintf("%s breached %s threshold", name, band)
+			event.Message = fmt.Sprintf("%s is %g %s, beyond the %s threshold of %g", name, kpi.Value, kpi.Unit, band, *threshold)
+			event.Fields = append(event.Fields, siem.Field{Name: "threshold", Value: strconv.FormatFloat(*threshold, 'g', -1, 64)})
+			alert.Key, alert.Firing = "kpi_threshold/"+string(key)+"/"+band, true
+		}
+		event.Fields = append(event.Fields, siem.Field{Name: "dedupKey", Value: alert.Key})
+		alert.Event = event
+		alerts = append(alerts, alert)
+	}
+	for _, composite := range composites {
+		if holds := rules[composite.Name]; holds != lastRules[composite.Name] {
+			alerts = append(alerts, compositeAlert(composite, holds, now))
+		}
+	}
+	if lastHealth != "" && health != lastHealth {
+		summary := collector.GetSummary()
+		key := "health_change/" + health
+		alerts = append(alerts, alerting.Alert{Key: key, Event: siem.Event{
+			Time: now, Type: "health_change", Severity: healthSeverity[health],
+			Name: "Overall health changed to " + health,
+			Message: fmt.Sprintf("Overall security health changed from %s to %s (compliance %s, risk %s)", lastHealth, health,
+				metrics.FormatScore("%.1f%%", summary.ComplianceScore, summary.HasData.Compliance),
+				metrics.FormatScore("%.1f", summary.RiskScore, summary.HasData.Risk)),
+			Fields: []siem.Field{
+				{Name: "health", Value: health},
+				{Name: "previousHealth", Value: lastHealth},
+				{Name: "dedupKey", Value: key},
+			},
+		}})
+	}
+	return alerts
+}
+
+// compositeAlert returns the alert of a composite rule that started or
+// stopped holding.
+func compositeAlert(composite *alerting.Composite, holds bool, now time.Time) alerting.Alert {
+	alert := alerting.Alert{Key: "composite_recovered/" + composite.Name, Rule: composite.Name, Category: composite.Category}
+	event := siem.Event{Time: now, Type: "composite_recovered", Severity: 3, Category: composite.Category,
+		Name: composite.Name + " recovered", Message: fmt.Sprintf("%s no longer holds", composite.Condition)}
+	if holds {
+		alert.Key, alert.Firing, alert.Severity = "composite/"+composite.Name, true, composite.Severity
+		event.Type, event.Severity, event.Name = "composite_alert", 5, composite.Name+" fired"
+		if composite.Severity == "critical" {
+			event.Severity = 8
+		}
+		event.Message = fmt.Sprintf("%s has held", composite.Condition)
+		if composite.Hold() > 0 {
+			event.Message += " for " + composite.For
+		}
+		if composite.Description != "" {
+			event.Message = composite.Description + ": " + event.Message
+		}
+	}
+	event.Fields = []siem.Field{
+		{Name: "rule", Value: composite.Name},
+		{Name: "condition", Value: composite.Condition},
+		{Name: "dedupKey", Value: alert.Key},
+	}
+	alert.Event = event
+	return alert
+}
+
+// bandName names a threshold band for messages.
+func bandName(band string) string {
+	if band == "" {
+		return "normal"
+	}
+	return band
+}
+
+// evaluateAlerts detects the threshold crossings and health change since
+// the last state change, records the alerts' lifecycle and sends them to
+// the SIEM as the alerting policy allows, repeating alerts still firing.
+// Breaches open tracking tickets, whose closure is checked each time.
+// Only the instance delivering scheduled reports evaluates, so each alert
+// is recorded and sent once.
+func (s *Server) evaluateAlerts() {
+	now := s.clock.Now()
+	s.mu.RLock()
+	alerts := s.siemDetector.detect(s.served(), s.alerts.Composites(), now)
+	s.mu.RUnlock()
+	if !s.runsReports() {
+		return
+	}
+	events := s.decideAlerts(alerts, now)
+	s.trackTickets()
+	if s.siem == nil || len(events) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), siem.DefaultTimeout)
+	defer cancel()
+	if err := s.siem.Send(ctx, events...); err != nil {
+		s.logger.Printf("siem: send %d events: %v", len(events), err)
+		s.telemetry.ObserveSIEMEvents(false, len(events))
+		return
+	}
+	s.telemetry.ObserveSIEMEvents(true, len(events))
+}
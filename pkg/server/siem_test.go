@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/siem"
+)
+
+func TestSIEMReceivesThresholdCrossings(t *testing.T) {
+	collector, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+	srv, err := New(Config{SIEM: siem.Config{Address: collector.LocalAddr().String()}}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := func() []string {
+		t.Helper()
+		var messages []string
+		buf := make([]byte, 4096)
+		for {
+			collector.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := collector.ReadFrom(buf)
+			if err != nil {
+				return messages
+			}
+			messages = append(messages, string(buf[:n]))
+		}
+	}
+
+	// The first state is the baseline.
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Name: "MTTR", Value: 1, Target: 2, Unit: "hours"}}})
+	if messages := received(); len(messages) != 0 {
+		t.Fatalf("baseline sent %q", messages)
+	}
+
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Name: "MTTR", Value: 5, Target: 2, Unit: "hours"}}})
+	messages := received()
+	if len(messages) != 1 || !strings.Contains(messages[0], "|kpi_threshold|MTTR breached critical threshold|8|") ||
+		!strings.Contains(messages[0], "cs4Label=previousBand cs4=normal cs5Label=threshold cs5=4") {
+		t.Fatalf("breach sent %q", messages)
+	}
+
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Name: "MTTR", Value: 3, Target: 2, Unit: "hours"}}})
+	if messages := received(); len(messages) != 1 || !strings.Contains(messages[0], "|MTTR breached warning threshold|5|") {
+		t.Fatalf("de-escalation sent %q", messages)
+	}
+	srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Name: "MTTR", Value: 1.5, Target: 2, Unit: "hours"}}})
+	if messages := received(); len(messages) != 1 || !strings.Contains(messages[0], "|kpi_recovered|MTTR recovered|3|") {
+		t.Fatalf("recovery sent %q", messages)
+	}
+}
+
+func TestSIEMHealthChange(t *testing.T) {
+	var d siemDetector
+	collector := metrics.NewMetricsCollector()
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	collector.AddMetric(metrics.SecurityMetric{ID: "c1", Type: metrics.TypeCompliance, Value: 40, Target: 100})
+	d.detect(collector, nil, now)
+	before := collector.GetSummary().OverallHealth
+	collector.AddMetric(metrics.SecurityMetric{ID: "c2", Type: metrics.TypeCompliance, Value: 100, Target: 100})
+	alerts := d.detect(collector, nil, now)
+	after := collector.GetSummary().OverallHealth
+	if before == after {
+		t.Fatalf("health did not change from %s", before)
+	}
+	if len(alerts) == 0 {
+		t.Fatalf("no events for the change from %s to %s", before, after)
+	}
+	last := alerts[len(alerts)-1].Event
+	if last.Type != "health_change" || last.Name != "Overall health changed to "+after {
+		t.Errorf("event = %+v", last)
+	}
+}
+
+func TestSIEMCompositeRule(t *testing.T) {
+	engine, err := alerting.NewEngine(alerting.Config{Composite: []alerting.CompositeRule{{
+		Name: "remediation_stalled", Condition: "remediation_rate < 80 and critical_open > 5", Severity: "critical", Category: "Remediation",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d siemDetector
+	collector := metrics.NewMetricsCollector()
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_RemediationRate, Value: 75, Target: 90})
+	d.detect(collector, engine.Composites(), now)
+
+	collector.AddKPI(metrics.KPI{Key: "critical_open", Value: 7})
+	alerts := d.detect(collector, engine.Composites(), now)
+	if len(alerts) != 1 || alerts[0].Key != "composite/remediation_stalled" || !alerts[0].Firing || alerts[0].Rule != "remediation_stalled" ||
+		alerts[0].Event.Type != "composite_alert" || alerts[0].Event.Severity != 8 {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	collector.RemoveKPI("critical_open")
+	alerts = d.detect(collector, engine.Composites(), now)
+	if len(alerts) != 1 || alerts[0].Key != "composite_recovered/remediation_stalled" || alerts[0].Firing {
+		t.Fatalf("recovery alerts = %+v", alerts)
+	}
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// SlackConfig configures answering Slack slash commands such as
+// "/secmetrics kpi mttr" at POST /slack/commands.
+type SlackConfig struct {
+	// SigningSecretEnv names the environment variable holding the Slack
+	// app's signing secret; setting it enables the endpoint.
+	SigningSecretEnv string `yaml:"signing_secret_env"`
+	// InChannel posts answers to the channel rather than only to the
+	// analyst who asked.
+	InChannel bool `yaml:"in_channel"`
+}
+
+// slackMaxSkew is how old a request's timestamp may be, so a captured
+// request cannot be replayed later.
+const slackMaxSkew = 5 * time.Minute
+
+// slackSparklineSamples is the number of latest samples a sparkline shows.
+const slackSparklineSamples = 24
+
+// slackBelowTargetLimit bounds the KPIs below target a health card lists.
+const slackBelowTargetLimit = 5
+
+// sparkBars are the bars of a sparkline, lowest first.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// slackBot verifies and answers slash commands.
+type slackBot struct {
+	secret       []byte
+	responseType string
+}
+
+// newSlackBot creates the slash command handler configured in cfg. It
+// returns nil when Slack is not configured.
+func newSlackBot(cfg SlackConfig) (*slackBot, error) {
+	if cfg.SigningSecretEnv == "" {
+		return nil, nil
+	}
+	secret := secretenv.Get(cfg.SigningSecretEnv)
+	if secret == "" {
+		return nil, fmt.Errorf("server slack: environment variable %s is not set", cfg.SigningSecretEnv)
+	}
+	bot := &slackBot{secret: []byte(secret), responseType: "ephemeral"}
+	if cfg.InChannel {
+		bot.responseType = "in_channel"
+	}
+	return bot, nil
+}
+
+// verify checks the request signature Slack computes over the timestamp
+// and body with the signing secret.
+func (b *slackBot) verify(header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid request timestamp")
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return errors.New("request timestamp is too old")
+	}
+	mac := hmac.New(sha256.New, b.secret)
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(want)) {
+		return errors.New("invalid request signature")
+	}
+	return nil
+}
+
+// slackMessage is a slash command response in Slack's Block Kit format.
+type slackMessage struct {
+	ResponseType string       `json:"response_type"`
+	Text         string       `json:"text"`
+	Blocks       []slackBlock `json:"blocks,omitempty"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func slackMarkdown(text string) slackText {
+	return slackText{Type: "mrkdwn", Text: text}
+}
+
+// slackEscape escapes the characters Slack treats as markup.
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// handleSlackCommand answers a Slack slash command: "kpi <key>" with a
+// card of the KPI, "health" with the overall health, anything else with
+// usage.
+func (s *Server) handleSlackCommand(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.ingest.config.MaxBodyBytes))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+	if err := s.slack.verify(r.Header, body, s.clock.Now()); err != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+
+	command := form.Get("command")
+	if command == "" {
+		command = "/secmetrics"
+	}
+	args := strings.Fields(strings.ToLower(form.Get("text")))
+	var message slackMessage
+	switch {
+	case len(args) == 2 && args[0] == "kpi":
+		message = s.slackKPICard(metrics.KPIKey(args[1]), command)
+	case len(args) == 1 && args[0] == "health":
+		message = s.slackHealthCard()
+	default:
+		message = slackMessage{Text: fmt.Sprintf("Usage: `%s kpi <key>` for a KPI, e.g. `%s kpi mttr`, or `%s health` for the overall health.", command, command, command)}
+	}
+	message.ResponseType = s.slack.responseType
+	writeJSON(w, http.StatusOK, message)
+}
+
+// slackKPICard returns the card of the KPI with key: its value, target,
+// status, trend over the last 7 days and a sparkline of its history.
+func (s *Server) slackKPICard(key metrics.KPIKey, command string) slackMessage {
+	now := s.clock.Now()
+	s.mu.RLock()
+	collector := s.served()
+	kpi := collector.GetKPI(key)
+	var (
+		def        metrics.KPIDefinition
+		comparison metrics.WindowComparison
+		history    []metrics.KPISample
+	)
+	if kpi != nil {
+		def, _ = collector.GetKPIDefinition(key)
+		comparison = collector.CompareWindows(key, metrics.Window7Days, now)
+		history = collector.GetKPIHistory(key)
+	}
+	s.mu.RUnlock()
+	if kpi == nil {
+		return slackMessage{Text: fmt.Sprintf("No KPI %s has been collected. Keys are listed by `secmetrics kpis`; try `%s kpi mttr`.", slackEscape(string(key)), command)}
+	}
+
+	name := kpi.Name
+	if name == "" {
+		name = string(key)
+	}
+	trend := "not enough history"
+	if comparison.Current.Count > 0 && comparison.HasPrevious() {
+		trend = fmt.Sprintf("%s (%+.1f %s vs previous 7 days)", kpiTrend(def, comparison.Delta), comparison.Delta, kpi.Unit)
+	}
+	var values []float64
+	for _, sample := range history {
+		values = append(values, sample.Value)
+	}
+	if len(values) > slackSparklineSamples {
+		values = values[len(values)-slackSparklineSamples:]
+	}
+
+	value := fmt.Sprintf("%.1f %s", kpi.Value, kpi.Unit)
+	target := fmt.Sprintf("%.1f %s", kpi.Target, kpi.Unit)
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: name}},
+		{Type: "section", Fields: []slackText{
+			slackMarkdown("*Value*\n" + slackEscape(value)),
+			slackMarkdown("*Target*\n" + slackEscape(target)),
+			slackMarkdown("*Status*\n" + kpi.Status),
+			slackMarkdown("*Trend*\n" + slackEscape(trend)),
+		}},
+	}
+	if len(values) > 1 {
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn",
+			Text: fmt.Sprintf("*Last %d samples*\n`%s`", len(values), sparkline(values))}})
+	}
+	footer := "`" + string(key) + "`"
+	if kpi.Category != "" {
+		footer += " · " + slackEscape(kpi.Category)
+	}
+	if !kpi.LastUpdated.IsZero() {
+		footer += " · updated " + kpi.LastUpdated.UTC().Format(time.RFC3339)
+	}
+	blocks = append(blocks, slackBlock{Type: "context", Elements: []slackText{slackMarkdown(footer)}})
+	return slackMessage{Text: fmt.Sprintf("%s: %s (target %s, %s)", name, value, target, kpi.Status), Blocks: blocks}
+}
+
+// slackHealthCard returns the card of the overall health: the scores and
+// the KPIs furthest below target.
+func (s *Server) slackHealthCard() slackMessage {
+	s.mu.RLock()
+	collector := s.served()
+	summary := *collector.GetSummary()
+	kpis := collector.GetKPIS()
+	s.mu.RUnlock()
+
+	var below []metrics.KPI
+	for _, kpi := range kpis {
+		if kpi.Status == "BELOW_TARGET" {
+			below = append(below, kpi)
+		}
+	}
+	// Furthest from target first, relative to the target.
+	gap := func(kpi metrics.KPI) float64 {
+		return math.Abs(kpi.Value-kpi.Target) / math.Max(math.Abs(kpi.Target), 1)
+	}
+	sort.SliceStable(below, func(i, j int) bool { return gap(below[i]) > gap(below[j]) })
+
+	blocks := []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: "Security health: " + summary.OverallHealth}},
+		{Type: "section", Fields: []slackText{
+			slackMarkdown("*Compliance*\n" + metrics.FormatScore("%.1f%%", summary.ComplianceScore, summary.HasData.Compliance)),
+			slackMarkdown("*Risk*\n" + metrics.FormatScore("%.1f", summary.RiskScore, summary.HasData.Risk)),
+			slackMarkdown("*Vulnerability*\n" + metrics.FormatScore("%.1f", summary.VulnerabilityScore, summary.HasData.Vulnerability)),
+			slackMarkdown(fmt.Sprintf("*KPIs on target*\n%d of %d", len(kpis)-len(below), len(kpis))),
+		}},
+	}
+	if len(below) > 0 {
+		lines := []string{"*Below target*"}
+		for i, kpi := range below {
+			if i == slackBelowTargetLimit {
+				lines = append(lines, fmt.Sprintf("…and %d more", len(below)-i))
+				break
+			}
+			lines = append(lines, slackEscape(fmt.Sprintf("• %s: %.1f %s (target %.1f)", kpi.Name, kpi.Value, kpi.Unit, kpi.Target)))
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: strings.Join(lines, "\n")}})
+	}
+	text := fmt.Sprintf("Security health: %s (compliance %s, risk %s)", summary.OverallHealth,
+		metrics.FormatScore("%.1f%%", summary.ComplianceScore, summary.HasData.Compliance),
+		metrics.FormatScore("%.1f", summary.RiskScore, summary.HasData.Risk))
+	return slackMessage{Text: text, Blocks: blocks}
+}
+
+// kpiTrend names the direction of a change in a KPI's value.
+func kpiTrend(def metrics.KPIDefinition, delta float64) string {
+	if def.Direction == metrics.LowerIsBetter {
+		delta = -delta
+	}
+	switch {
+	case delta > 0:
+		return "IMPROVING"
+	case delta < 0:
+		return "DECLINING"
+	}
+	return "STABLE"
+}
+
+// sparkline draws values as bars scaled between their minimum and
+// maximum, e.g. "▁▃▅█".
+func sparkline(values []float64) string {
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+	bars := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if high > low {
+			level = int((v - low) / (high - low) * float64(len(sparkBars)-1))
+		}
+		bars[i] = sparkBars[level]
+	}
+	return string(bars)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestSlackCommands(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", "shh")
+	clk := clock.NewFake(time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC))
+	srv, err := New(Config{Slack: SlackConfig{SigningSecretEnv: "SLACK_SIGNING_SECRET"}}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetClock(clk)
+	for i, value := range []float64{3, 2.5, 6, 4, 8} {
+		at := clk.Now().Add(time.Duration(i-5) * 48 * time.Hour)
+		srv.collector.AddKPISample(metrics.KPISample{Key: metrics.KPI_MTTR, Value: value, Timestamp: at})
+	}
+	srv.collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Name: "MTTR", Value: 8, Target: 2, Unit: "hours"})
+
+	command := func(text string, sign func(timestamp, body string) string) (int, slackMessage) {
+		t.Helper()
+		body := url.Values{"command": {"/secmetrics"}, "text": {text}, "user_id": {"U1"}}.Encode()
+		timestamp := strconv.FormatInt(clk.Now().Unix(), 10)
+		req := httptest.NewRequest(http.MethodPost, "/slack/commands", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", sign(timestamp, body))
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		var message slackMessage
+		json.NewDecoder(rec.Body).Decode(&message)
+		return rec.Code, message
+	}
+	signed := func(timestamp, body string) string {
+		mac := hmac.New(sha256.New, []byte("shh"))
+		fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	code, message := command("kpi MTTR", signed)
+	if code != http.StatusOK || message.ResponseType != "ephemeral" || len(message.Blocks) != 4 {
+		t.Fatalf("kpi mttr = %d %+v", code, message)
+	}
+	card, _ := json.Marshal(message.Blocks)
+	for _, want := range []string{"8.0 hours", "2.0 hours", "BELOW_TARGET", "DECLINING", "▁▁▅▂██"} {
+		if !strings.Contains(string(card), want) {
+			t.Errorf("card lacks %q: %s", want, card)
+		}
+	}
+
+	if _, message := command("health", signed); !strings.HasPrefix(message.Text, "Security health: ") || !strings.Contains(mustJSON(t, message.Blocks), "MTTR: 8.0 hours") {
+		t.Errorf("health = %+v", message)
+	}
+	if _, message := command("kpi nope", signed); !strings.Contains(message.Text, "No KPI nope") {
+		t.Errorf("unknown KPI = %+v", message)
+	}
+	if _, message := command("", signed); !strings.HasPrefix(message.Text, "Usage:") {
+		t.Errorf("help = %+v", message)
+	}
+	if code, _ := command("health", func(string, string) string { return "v0=forged" }); code != http.StatusUnauthorized {
+		t.Errorf("forged signature = %d, want 401", code)
+	}
+	replayed := func(timestamp, body string) string {
+		clk.Advance(10 * time.Minute)
+		return signed(timestamp, body)
+	}
+	if code, _ := command("health", replayed); code != http.StatusUnauthorized {
+		t.Errorf("replayed request = %d, want 401", code)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
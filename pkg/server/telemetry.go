@@ -0,0 +1,378 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+)
+
+// Telemetry records operational metrics about secmetrics itself.
+type Telemetry struct {
+	mu                  sync.Mutex
+	collectionDurations map[string]*durationStat
+	collectionFailures  map[string]int
+	lastSuccess         map[string]time.Time
+	reportDurations     map[string]*durationStat
+	storeSizeBytes      int64
+	ingestedTotal       int
+	ingestRejected      int
+	ingestQueueDepth    int
+	rateLimited         map[string]int
+	gitOpsSyncFailures  int
+	gitOpsDrift         int
+	leaderElection      bool
+	leader              bool
+	eventSubscribers    int
+	summaryCache        map[string]int
+	busEvents           map[string]int
+	siemEvents          map[string]int
+	alertsSuppressed    map[string]int
+	grcRecords          map[string]int
+	grcFailures         map[string]int
+	tickets             map[string]int
+	startTime           time.Time
+}
+
+// durationStat accumulates observations like a Prometheus summary.
+type durationStat struct {
+	count int
+	sum   float64
+	last  float64
+}
+
+// NewTelemetry creates an empty telemetry registry.
+func NewTelemetry() *Telemetry {
+	return &Telemetry{
+		collectionDurations: make(map[string]*durationStat),
+		collectionFailures:  make(map[string]int),
+		lastSuccess:         make(map[string]time.Time),
+		reportDurations:     make(map[string]*durationStat),
+		rateLimited:         make(map[string]int),
+		summaryCache:        make(map[string]int),
+		busEvents:           make(map[string]int),
+		siemEvents:          make(map[string]int),
+		alertsSuppressed:    make(map[string]int),
+		grcRecords:          make(map[string]int),
+		grcFailures:         make(map[string]int),
+		tickets:             make(map[string]int),
+		startTime:           time.Now(),
+	}
+}
+
+// ObserveCollection records one collection of source.
+func (t *Telemetry) ObserveCollection(source string, duration time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	observe(t.collectionDurations, source, duration)
+	if err != nil {
+		t.collectionFailures[source]++
+	} else {
+		t.lastSuccess[source] = time.Now()
+	}
+}
+
+// ObserveReport records one report generation of reportType.
+func (t *Telemetry) ObserveReport(reportType string, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	observe(t.reportDurations, reportType, duration)
+}
+
+// SetStoreSize records the current store size in bytes.
+func (t *Telemetry) SetStoreSize(bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.storeSizeBytes = bytes
+}
+
+// ObserveIngested records n ingested metrics and KPIs.
+func (t *Telemetry) ObserveIngested(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ingestedTotal += n
+}
+
+// ObserveIngestRejected records a batch rejected because the queue was full.
+func (t *Telemetry) ObserveIngestRejected() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ingestRejected++
+}
+
+// ObserveRateLimited records a request refused by the rate limit of scope,
+// "ip" or "key".
+func (t *Telemetry) ObserveRateLimited(scope string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rateLimited[scope]++
+}
+
+// ObserveGitOpsSync records one sync of the desired state.
+func (t *Telemetry) ObserveGitOpsSync(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.gitOpsSyncFailures++
+	}
+}
+
+// SetGitOpsDrift records the number of differences between the desired
+// and the runtime state.
+func (t *Telemetry) SetGitOpsDrift(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gitOpsDrift = n
+}
+
+// SetLeader records whether this replica holds the leader lease.
+func (t *Telemetry) SetLeader(leader bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leaderElection = true
+	t.leader = leader
+}
+
+// AddEventSubscribers adjusts the number of connected event streams by
+// delta.
+func (t *Telemetry) AddEventSubscribers(delta int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.eventSubscribers += delta
+}
+
+// ObserveSummaryCache counts a summary cache lookup.
+func (t *Telemetry) ObserveSummaryCache(hit bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if hit {
+		t.summaryCache["hit"]++
+	} else {
+		t.summaryCache["miss"]++
+	}
+}
+
+// ObserveBusEvents counts n events handed to the event bus with result
+// "published", "failed" or "dropped".
+func (t *Telemetry) ObserveBusEvents(result string, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.busEvents[result] += n
+}
+
+// ObserveSIEMEvents counts n events sent, or failed to send, to the SIEM.
+func (t *Telemetry) ObserveSIEMEvents(sent bool, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if sent {
+		t.siemEvents["sent"] += n
+	} else {
+		t.siemEvents["failed"] += n
+	}
+}
+
+// ObserveAlertsSuppressed counts n alert notifications held back for
+// reason: duplicate, silenced or acknowledged.
+func (t *Telemetry) ObserveAlertsSuppressed(reason string, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.alertsSuppressed[reason] += n
+}
+
+// ObserveGRCExport records one push of records to a GRC system: the
+// number it accepted and whether the push failed.
+func (t *Telemetry) ObserveGRCExport(system string, records int, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.grcRecords[system] += records
+	t.grcFailures[system] += 0
+	if failed {
+		t.grcFailures[system]++
+	}
+}
+
+// ObserveTicket records one attempt to open a tracking ticket for a
+// breached KPI.
+func (t *Telemetry) ObserveTicket(created bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if created {
+		t.tickets["created"]++
+	} else {
+		t.tickets["failed"]++
+	}
+}
+
+// SetIngestQueueDepth records the number of pending ingest batches.
+func (t *Telemetry) SetIngestQueueDepth(depth int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ingestQueueDepth = depth
+}
+
+func observe(stats map[string]*durationStat, label string, duration time.Duration) {
+	stat, ok := stats[label]
+	if !ok {
+		stat = &durationStat{}
+		stats[label] = stat
+	}
+	stat.count++
+	stat.sum += duration.Seconds()
+	stat.last = duration.Seconds()
+}
+
+// WritePrometheus renders the telemetry in Prometheus text format.
+func (t *Telemetry) WritePrometheus(b *strings.Builder) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b.WriteString("# HELP secmetrics_collection_duration_seconds Time spent collecting from each source.\n")
+	b.WriteString("# TYPE secmetrics_collection_duration_seconds summary\n")
+	for _, source := range sortedKeys(t.collectionDurations) {
+		stat := t.collectionDurations[source]
+		fmt.Fprintf(b, "secmetrics_collection_duration_seconds_sum{source=%q} %g\n", source, stat.sum)
+		fmt.Fprintf(b, "secmetrics_collection_duration_seconds_count{source=%q} %d\n", source, stat.count)
+	}
+
+	b.WriteString("# HELP secmetrics_collection_last_duration_seconds Duration of the most recent collection per source.\n")
+	b.WriteString("# TYPE secmetrics_collection_last_duration_seconds gauge\n")
+	for _, source := range sortedKeys(t.collectionDurations) {
+		fmt.Fprintf(b, "secmetrics_collection_last_duration_seconds{source=%q} %g\n", source, t.collectionDurations[source].last)
+	}
+
+	b.WriteString("# HELP secmetrics_collection_failures_total Failed collections per source.\n")
+	b.WriteString("# TYPE secmetrics_collection_failures_total counter\n")
+	for _, source := range sortedKeys(t.collectionDurations) {
+		fmt.Fprintf(b, "secmetrics_collection_failures_total{source=%q} %d\n", source, t.collectionFailures[source])
+	}
+
+	b.WriteString("# HELP secmetrics_collection_last_success_timestamp_seconds Unix time of the last successful collection per source.\n")
+	b.WriteString("# TYPE secmetrics_collection_last_success_timestamp_seconds gauge\n")
+	for _, source := range sortedKeys(t.collectionDurations) {
+		if ts, ok := t.lastSuccess[source]; ok {
+			fmt.Fprintf(b, "secmetrics_collection_last_success_timestamp_seconds{source=%q} %d\n", source, ts.Unix())
+		}
+	}
+
+	b.WriteString("# HELP secmetrics_report_generation_duration_seconds Time spent generating reports per type.\n")
+	b.WriteString("# TYPE secmetrics_report_generation_duration_seconds summary\n")
+	for _, reportType := range sortedKeys(t.reportDurations) {
+		stat := t.reportDurations[reportType]
+		fmt.Fprintf(b, "secmetrics_report_generation_duration_seconds_sum{type=%q} %g\n", reportType, stat.sum)
+		fmt.Fprintf(b, "secmetrics_report_generation_duration_seconds_count{type=%q} %d\n", reportType, stat.count)
+	}
+
+	b.WriteString("# HELP secmetrics_store_size_bytes Size of the local metrics store.\n")
+	b.WriteString("# TYPE secmetrics_store_size_bytes gauge\n")
+	fmt.Fprintf(b, "secmetrics_store_size_bytes %d\n", t.storeSizeBytes)
+
+	b.WriteString("# HELP secmetrics_ingested_total Metrics and KPIs applied from POST /ingest.\n")
+	b.WriteString("# TYPE secmetrics_ingested_total counter\n")
+	fmt.Fprintf(b, "secmetrics_ingested_total %d\n", t.ingestedTotal)
+	b.WriteString("# HELP secmetrics_ingest_rejected_total Ingest batches rejected with 429 because the queue was full.\n")
+	b.WriteString("# TYPE secmetrics_ingest_rejected_total counter\n")
+	fmt.Fprintf(b, "secmetrics_ingest_rejected_total %d\n", t.ingestRejected)
+	b.WriteString("# HELP secmetrics_ingest_queue_depth Ingest batches waiting to be applied.\n")
+	b.WriteString("# TYPE secmetrics_ingest_queue_depth gauge\n")
+	fmt.Fprintf(b, "secmetrics_ingest_queue_depth %d\n", t.ingestQueueDepth)
+
+	b.WriteString("# HELP secmetrics_rate_limited_total Requests refused with 429 by a rate limit, per scope.\n")
+	b.WriteString("# TYPE secmetrics_rate_limited_total counter\n")
+	for _, scope := range []string{"ip", "key"} {
+		fmt.Fprintf(b, "secmetrics_rate_limited_total{scope=%q} %d\n", scope, t.rateLimited[scope])
+	}
+
+	b.WriteString("# HELP secmetrics_gitops_sync_failures_total Failed syncs of the desired state.\n")
+	b.WriteString("# TYPE secmetrics_gitops_sync_failures_total counter\n")
+	fmt.Fprintf(b, "secmetrics_gitops_sync_failures_total %d\n", t.gitOpsSyncFailures)
+	b.WriteString("# HELP secmetrics_gitops_drift Differences between the desired and the runtime state.\n")
+	b.WriteString("# TYPE secmetrics_gitops_drift gauge\n")
+	fmt.Fprintf(b, "secmetrics_gitops_drift %d\n", t.gitOpsDrift)
+
+	b.WriteString("# HELP secmetrics_event_subscribers Connected KPI event streams.\n")
+	b.WriteString("# TYPE secmetrics_event_subscribers gauge\n")
+	fmt.Fprintf(b, "secmetrics_event_subscribers %d\n", t.eventSubscribers)
+
+	if len(t.summaryCache) > 0 {
+		b.WriteString("# HELP secmetrics_summary_cache_total Summary lookups in the Redis cache, per result.\n")
+		b.WriteString("# TYPE secmetrics_summary_cache_total counter\n")
+		for _, result := range []string{"hit", "miss"} {
+			fmt.Fprintf(b, "secmetrics_summary_cache_total{result=%q} %d\n", result, t.summaryCache[result])
+		}
+	}
+
+	if len(t.busEvents) > 0 {
+		b.WriteString("# HELP secmetrics_event_bus_events_total Events handed to the event bus, per result.\n")
+		b.WriteString("# TYPE secmetrics_event_bus_events_total counter\n")
+		for _, result := range []string{"published", "failed", "dropped"} {
+			fmt.Fprintf(b, "secmetrics_event_bus_events_total{result=%q} %d\n", result, t.busEvents[result])
+		}
+	}
+
+	if len(t.siemEvents) > 0 {
+		b.WriteString("# HELP secmetrics_siem_events_total Events sent to the SIEM collector, per result.\n")
+		b.WriteString("# TYPE secmetrics_siem_events_total counter\n")
+		for _, result := range []string{"sent", "failed"} {
+			fmt.Fprintf(b, "secmetrics_siem_events_total{result=%q} %d\n", result, t.siemEvents[result])
+		}
+	}
+
+	if len(t.alertsSuppressed) > 0 {
+		b.WriteString("# HELP secmetrics_alerts_suppressed_total Alert notifications not sent, per reason.\n")
+		b.WriteString("# TYPE secmetrics_alerts_suppressed_total counter\n")
+		for _, reason := range []string{alerting.ReasonDuplicate, alerting.ReasonSilenced, alerting.ReasonAcknowledged} {
+			fmt.Fprintf(b, "secmetrics_alerts_suppressed_total{reason=%q} %d\n", reason, t.alertsSuppressed[reason])
+		}
+	}
+
+	if len(t.grcRecords) > 0 {
+		b.WriteString("# HELP secmetrics_grc_export_records_total Records accepted by GRC systems of record, per system.\n")
+		b.WriteString("# TYPE secmetrics_grc_export_records_total counter\n")
+		for _, system := range grcSystems {
+			if records, ok := t.grcRecords[system]; ok {
+				fmt.Fprintf(b, "secmetrics_grc_export_records_total{system=%q} %d\n", system, records)
+			}
+		}
+		b.WriteString("# HELP secmetrics_grc_export_failures_total Failed pushes to GRC systems of record, per system.\n")
+		b.WriteString("# TYPE secmetrics_grc_export_failures_total counter\n")
+		for _, system := range grcSystems {
+			if failures, ok := t.grcFailures[system]; ok {
+				fmt.Fprintf(b, "secmetrics_grc_export_failures_total{system=%q} %d\n", system, failures)
+			}
+		}
+	}
+
+	if len(t.tickets) > 0 {
+		b.WriteString("# HELP secmetrics_tickets_total Tracking tickets opened for breached KPIs, per result.\n")
+		b.WriteString("# TYPE secmetrics_tickets_total counter\n")
+		for _, result := range []string{"created", "failed"} {
+			fmt.Fprintf(b, "secmetrics_tickets_total{result=%q} %d\n", result, t.tickets[result])
+		}
+	}
+
+	if t.leaderElection {
+		leader := 0
+		if t.leader {
+			leader = 1
+		}
+		b.WriteString("# HELP secmetrics_leader Whether this replica holds the leader lease.\n")
+		b.WriteString("# TYPE secmetrics_leader gauge\n")
+		fmt.Fprintf(b, "secmetrics_leader %d\n", leader)
+	}
+
+	b.WriteString("# HELP secmetrics_uptime_seconds Time since the server started.\n")
+	b.WriteString("# TYPE secmetrics_uptime_seconds gauge\n")
+	fmt.Fprintf(b, "secmetrics_uptime_seconds %g\n", time.Since(t.startTime).Seconds())
+}
+
+func sortedKeys(m map[string]*durationStat) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
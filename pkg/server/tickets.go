@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/ticketing"
+)
+
+// thresholdAlertPrefix prefixes the keys of KPI threshold breach alerts.
+const thresholdAlertPrefix = "kpi_threshold/"
+
+// openTickets opens a tracking ticket for each KPI threshold breach that
+// started firing at now, with the KPI's context and explanation, and links
+// the ticket to the recorded alert.
+func (s *Server) openTickets(fired []alerting.Alert, now time.Time) {
+	if s.tickets == nil || s.readOnly {
+		return
+	}
+	for _, alert := range fired {
+		if !strings.HasPrefix(alert.Key, thresholdAlertPrefix) || !s.tickets.Accepts(alert.Severity) {
+			continue
+		}
+		s.mu.RLock()
+		id := s.openTicketOf(alert.KPI, recordedAlertID(alert.Key, now))
+		ticket := s.ticketOf(alert, now)
+		s.mu.RUnlock()
+		if id != "" {
+			// An escalation from warning to critical stays on the
+			// breach's ticket.
+			s.mu.Lock()
+			s.updateOpenAlert(alert.Key, func(recorded *metrics.Alert) { recorded.TicketID = id })
+			s.mu.Unlock()
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), ticketing.DefaultTimeout)
+		id, err := s.tickets.Create(ctx, ticket)
+		cancel()
+		s.telemetry.ObserveTicket(err == nil)
+		if err != nil {
+			s.logger.Printf("ticketing: %s: open ticket for %s: %v", s.tickets.System(), alert.KPI, err)
+			continue
+		}
+		s.logger.Printf("ticketing: opened %s %s for %s", s.tickets.System(), id, alert.KPI)
+		s.mu.Lock()
+		s.updateOpenAlert(alert.Key, func(recorded *metrics.Alert) { recorded.TicketID = id })
+		s.mu.Unlock()
+	}
+}
+
+// openTicketOf returns the ticket, not yet closed, of the latest threshold
+// breach of key recorded before the alert with id, or "" if there is none.
+// The caller must hold s.mu.
+func (s *Server) openTicketOf(key metrics.KPIKey, id string) string {
+	var latest metrics.Alert
+	for _, alert := range s.collector.GetAlerts() {
+		if alert.Source == alertSource && alert.ID != id && strings.HasPrefix(alert.ID, thresholdAlertPrefix+string(key)+"/") && !alert.FiredAt.Before(latest.FiredAt) {
+			latest = alert
+		}
+	}
+	if !latest.TicketClosedAt.IsZero() {
+		return ""
+	}
+	return latest.TicketID
+}
+
+// ticketOf returns the ticket of a KPI threshold breach. The caller must
+// hold s.mu.
+func (s *Server) ticketOf(alert alerting.Alert, now time.Time) ticketing.Ticket {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", alert.Event.Message)
+	for _, field := range alert.Event.Fields {
+		fmt.Fprintf(&b, "%s: %s\n", field.Name, field.Value)
+	}
+	if alert.Category != "" {
+		fmt.Fprintf(&b, "category: %s\n", alert.Category)
+	}
+	fmt.Fprintf(&b, "tenant: %s\nfired: %s\n", s.tenant, now.UTC().Format(time.RFC3339))
+
+	collector := s.served()
+	if explanation, err := collector.ExplainKPI(alert.KPI, now); err == nil {
+		fmt.Fprintf(&b, "\n%s", collector.FormatExplanation(explanation))
+	}
+	return ticketing.Ticket{Summary: alert.Event.Name, Description: b.String(), Severity: alert.Severity}
+}
+
+// trackTickets checks the tickets of recorded alerts that are not yet
+// closed, and records when each was found closed.
+func (s *Server) trackTickets() {
+	if s.tickets == nil || s.readOnly {
+		return
+	}
+	s.mu.RLock()
+	var open []metrics.Alert
+	for _, alert := range s.collector.GetAlerts() {
+		if alert.Source == alertSource && alert.TicketID != "" && alert.TicketClosedAt.IsZero() {
+			open = append(open, alert)
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, alert := range open {
+		ctx, cancel := context.WithTimeout(context.Background(), ticketing.DefaultTimeout)
+		closed, err := s.tickets.Closed(ctx, alert.TicketID)
+		cancel()
+		if err != nil {
+			s.logger.Printf("ticketing: %s: check %s: %v", s.tickets.System(), alert.TicketID, err)
+			continue
+		}
+		if !closed {
+			continue
+		}
+		// The alert may have resolved meanwhile, so update it as it is now.
+		closedAt := s.clock.Now()
+		s.mu.Lock()
+		for _, recorded := range s.collector.GetAlerts() {
+			if recorded.ID == alert.ID {
+				recorded.TicketClosedAt = closedAt
+				s.collector.AddAlert(recorded)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
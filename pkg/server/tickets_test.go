@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/clock"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/ticketing"
+)
+
+func TestTicketsForBreaches(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		descriptions []string
+		done         bool
+	)
+	jira := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPost {
+			var body struct {
+				Fields struct {
+					Description string `json:"description"`
+				} `json:"fields"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			descriptions = append(descriptions, body.Fields.Description)
+			w.Write([]byte(`{"key":"SEC-7"}`))
+			return
+		}
+		category := "indeterminate"
+		if done {
+			category = "done"
+		}
+		w.Write([]byte(`{"fields":{"status":{"statusCategory":{"key":"` + category + `"}}}}`))
+	}))
+	defer jira.Close()
+	t.Setenv("JIRA_TOKEN", "tok")
+
+	clk := clock.NewFake(time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC))
+	srv, err := New(Config{Ticketing: ticketing.Config{
+		Client: jira.Client(),
+		Jira:   &ticketing.JiraConfig{URL: jira.URL, TokenEnv: "JIRA_TOKEN", Project: "SEC"},
+	}}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetClock(clk)
+	ingest := func(value float64) {
+		srv.applyIngest(IngestBatch{KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Name: "MTTR", Value: value, Target: 2, Unit: "hours"}}})
+	}
+
+	ingest(1)
+	ingest(3)
+	clk.Advance(time.Minute)
+	ingest(5)
+	// The escalation to critical stays on the warning's ticket.
+	alerts := srv.collector.GetAlerts()
+	if len(alerts) != 2 || alerts[0].TicketID != "SEC-7" || alerts[1].TicketID != "SEC-7" {
+		t.Fatalf("alerts = %+v, want two linked to SEC-7", alerts)
+	}
+	mu.Lock()
+	if len(descriptions) != 1 || !strings.Contains(descriptions[0], "kpi: mttr") || !strings.Contains(descriptions[0], "KPI Explanation: MTTR") {
+		t.Errorf("descriptions = %q, want the KPI context and explanation", descriptions)
+	}
+	done = true
+	mu.Unlock()
+
+	clk.Advance(time.Hour)
+	ingest(1.5)
+	for _, alert := range srv.collector.GetAlerts() {
+		if alert.ResolvedAt.IsZero() || !alert.TicketClosedAt.Equal(clk.Now()) {
+			t.Errorf("alert = %+v, want resolved with its ticket closed", alert)
+		}
+	}
+	var telemetry strings.Builder
+	srv.telemetry.WritePrometheus(&telemetry)
+	if !strings.Contains(telemetry.String(), `secmetrics_tickets_total{result="created"} 1`) {
+		t.Error("ticket not counted")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// InstallWindows fails on platforms other than Windows.
+func InstallWindows(cfg Config, start bool) error {
+	return errors.New("service: Windows services can only be installed on Windows")
+}
+
+// Run runs run with ctx; only Windows services need more.
+func Run(ctx context.Context, run func(ctx context.Context) error) error {
+	return run(ctx)
+}
@@ -0,0 +1,145 @@
+// Package service installs the secmetrics daemon as a systemd unit or a
+// Windows service and runs it under the Windows service control manager.
+//
+// The installed service runs "secmetrics serve --config <path>", which
+// shuts down gracefully on SIGTERM from systemd or a stop request from the
+// service control manager and reloads its configuration on systemctl reload.
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultName is the name of the unit or service when none is given.
+const DefaultName = "secmetrics"
+
+// DefaultUnitDir is where systemd units are installed.
+const DefaultUnitDir = "/etc/systemd/system"
+
+// Restart policies.
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNo        = "no"
+)
+
+// Config describes the service to install.
+type Config struct {
+	// Name of the systemd unit or Windows service.
+	Name string
+	// Executable is the absolute path of the secmetrics binary.
+	Executable string
+	// ConfigPath is the absolute path of the configuration file serve
+	// reads.
+	ConfigPath string
+	// Profile is the configuration profile serve applies, if any.
+	Profile string
+	// User runs the service; empty runs it as root under systemd and as
+	// LocalSystem on Windows.
+	User string
+	// Password of User on Windows; systemd ignores it.
+	Password string
+	// Restart is the restart policy: always, on-failure or no. The
+	// Windows service control manager restarts on failure only, so
+	// always acts as on-failure there.
+	Restart string
+}
+
+// Validate checks that cfg names the service, absolute paths and a known
+// restart policy.
+func (cfg Config) Validate() error {
+	if cfg.Name == "" || strings.ContainsAny(cfg.Name, `/\ `) {
+		return fmt.Errorf("service: invalid name %q", cfg.Name)
+	}
+	if !filepath.IsAbs(cfg.Executable) || !filepath.IsAbs(cfg.ConfigPath) {
+		return fmt.Errorf("service: executable and config paths must be absolute")
+	}
+	switch cfg.Restart {
+	case RestartAlways, RestartOnFailure, RestartNo:
+	default:
+		return fmt.Errorf("service: unknown restart policy %q (always, on-failure, no)", cfg.Restart)
+	}
+	return nil
+}
+
+// Args returns the command line arguments the service runs the executable
+// with.
+func (cfg Config) Args() []string {
+	args := []string{"serve", "--config", cfg.ConfigPath}
+	if cfg.Profile != "" {
+		args = append([]string{"--profile", cfg.Profile}, args...)
+	}
+	return args
+}
+
+// SystemdUnit returns the systemd unit file of cfg. Source response caches
+// go to /var/cache/<name>, which systemd creates for the service user.
+func SystemdUnit(cfg Config) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=secmetrics security metrics daemon\n")
+	fmt.Fprintf(&b, "Documentation=https://github.com/hallucinaut/secmetrics\n")
+	fmt.Fprintf(&b, "Wants=network-online.target\n")
+	fmt.Fprintf(&b, "After=network-online.target\n")
+	fmt.Fprintf(&b, "\n[Service]\n")
+	fmt.Fprintf(&b, "Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdCommand(append([]string{cfg.Executable}, cfg.Args()...)))
+	// systemctl reload makes serve reload its configuration
+	fmt.Fprintf(&b, "ExecReload=/bin/kill -HUP $MAINPID\n")
+	if cfg.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", cfg.User)
+	}
+	fmt.Fprintf(&b, "Restart=%s\n", cfg.Restart)
+	fmt.Fprintf(&b, "RestartSec=5s\n")
+	// Leave time for the default 30s graceful shutdown
+	fmt.Fprintf(&b, "TimeoutStopSec=45s\n")
+	fmt.Fprintf(&b, "CacheDirectory=%s\n", cfg.Name)
+	fmt.Fprintf(&b, "Environment=XDG_CACHE_HOME=/var/cache\n")
+	fmt.Fprintf(&b, "NoNewPrivileges=true\n")
+	fmt.Fprintf(&b, "PrivateTmp=true\n")
+	fmt.Fprintf(&b, "ProtectSystem=full\n")
+	fmt.Fprintf(&b, "\n[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// systemdCommand joins args into an ExecStart command line, quoting
+// arguments with spaces and escaping specifiers.
+func systemdCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, "%", "%%")
+		if strings.ContainsAny(arg, " \t\"\\") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// InstallSystemd writes the unit of cfg to dir and enables it with
+// systemctl, starting it too when start is set. It returns the path of the
+// unit file.
+func InstallSystemd(cfg Config, dir string, start bool, systemctl func(args ...string) error) (string, error) {
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, cfg.Name+".service")
+	if err := os.WriteFile(path, []byte(SystemdUnit(cfg)), 0o644); err != nil {
+		return "", fmt.Errorf("service: write unit: %w", err)
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return path, fmt.Errorf("service: systemctl daemon-reload: %w", err)
+	}
+	enable := []string{"enable", cfg.Name}
+	if start {
+		enable = []string{"enable", "--now", cfg.Name}
+	}
+	if err := systemctl(enable...); err != nil {
+		return path, fmt.Errorf("service: systemctl %s: %w", strings.Join(enable, " "), err)
+	}
+	return path, nil
+}
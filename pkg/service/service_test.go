@@ -0,0 +1,68 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInstallSystemd(t *testing.T) {
+	cfg := Config{Name: "secmetrics", Executable: "/opt/sec metrics/secmetrics", ConfigPath: "/etc/secmetrics/secmetrics.yaml",
+		User: "secmetrics", Restart: RestartOnFailure}
+	dir := t.TempDir()
+	var calls [][]string
+	path, err := InstallSystemd(cfg, dir, true, func(args ...string) error {
+		calls = append(calls, args)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "secmetrics.service") {
+		t.Errorf("unit path = %s", path)
+	}
+	unit, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"ExecStart=\"/opt/sec metrics/secmetrics\" serve --config /etc/secmetrics/secmetrics.yaml\n",
+		"ExecReload=/bin/kill -HUP $MAINPID\n",
+		"User=secmetrics\n",
+		"Restart=on-failure\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(string(unit), want) {
+			t.Errorf("unit lacks %q:\n%s", want, unit)
+		}
+	}
+	if want := [][]string{{"daemon-reload"}, {"enable", "--now", "secmetrics"}}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("systemctl calls = %v, want %v", calls, want)
+	}
+}
+
+func TestArgsWithProfile(t *testing.T) {
+	cfg := Config{Name: "secmetrics-acme", ConfigPath: "/etc/secmetrics/secmetrics.yaml", Profile: "acme"}
+	want := []string{"--profile", "acme", "serve", "--config", "/etc/secmetrics/secmetrics.yaml"}
+	if args := cfg.Args(); !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{Name: "secmetrics", Executable: "/usr/local/bin/secmetrics", ConfigPath: "/etc/secmetrics/secmetrics.yaml", Restart: RestartAlways}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, cfg := range []Config{
+		{Name: "sec metrics", Executable: valid.Executable, ConfigPath: valid.ConfigPath, Restart: RestartAlways},
+		{Name: "secmetrics", Executable: valid.Executable, ConfigPath: "secmetrics.yaml", Restart: RestartAlways},
+		{Name: "secmetrics", Executable: valid.Executable, ConfigPath: valid.ConfigPath, Restart: "sometimes"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+//go:build windows
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// InstallWindows registers cfg as an automatically started Windows service
+// that the service control manager restarts after failures unless its
+// restart policy is no, and starts it when start is set.
+func InstallWindows(cfg Config, start bool) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("service: connect to the service control manager: %w", err)
+	}
+	defer m.Disconnect()
+	if existing, err := m.OpenService(cfg.Name); err == nil {
+		existing.Close()
+		return fmt.Errorf("service: %s is already installed", cfg.Name)
+	}
+
+	s, err := m.CreateService(cfg.Name, cfg.Executable, mgr.Config{
+		DisplayName:      "secmetrics",
+		Description:      "secmetrics security metrics daemon",
+		StartType:        mgr.StartAutomatic,
+		ServiceStartName: cfg.User,
+		Password:         cfg.Password,
+	}, cfg.Args()...)
+	if err != nil {
+		return fmt.Errorf("service: create %s: %w", cfg.Name, err)
+	}
+	defer s.Close()
+	if cfg.Restart != RestartNo {
+		restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 5 * time.Second}
+		// The failure count resets after a day without failures
+		if err := s.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, 24*60*60); err != nil {
+			return fmt.Errorf("service: set recovery actions: %w", err)
+		}
+		// Also restart when serve stops with an error rather than crashing
+		if err := s.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+			return fmt.Errorf("service: set recovery actions: %w", err)
+		}
+	}
+	if start {
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("service: start %s: %w", cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+// Run runs run with ctx, under the service control manager when the
+// process was started as a Windows service: a stop or shutdown request
+// cancels the context run gets, and an error from run is reported as a
+// failure, which triggers the recovery actions.
+func Run(ctx context.Context, run func(ctx context.Context) error) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return run(ctx)
+	}
+	h := &handler{ctx: ctx, run: run}
+	if err := svc.Run("", h); err != nil {
+		return err
+	}
+	return h.err
+}
+
+// handler runs the daemon for the service control manager.
+type handler struct {
+	ctx context.Context
+	run func(ctx context.Context) error
+	err error
+}
+
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- h.run(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case h.err = <-done:
+			return h.exit()
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				h.err = <-done
+				return h.exit()
+			}
+		}
+	}
+}
+
+// exit returns the exit code of the service: 1 when run failed.
+func (h *handler) exit() (bool, uint32) {
+	if h.err != nil {
+		return false, 1
+	}
+	return false, 0
+}
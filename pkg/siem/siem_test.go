@@ -0,0 +1,115 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEvent = Event{
+	Time:     time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC),
+	Type:     "kpi_threshold",
+	Name:     "MTTR breached critical threshold",
+	Severity: 8,
+	Category: "Response",
+	Message:  "MTTR is 5 hours, beyond the critical threshold of 4",
+	Fields:   []Field{{Name: "kpi", Value: "mttr"}, {Name: "note", Value: "a=b|c\\d"}},
+}
+
+func TestFormat(t *testing.T) {
+	w, err := New(Config{Address: "siem.internal:514", Hostname: "metrics-1", Facility: "auth"}, "1.4.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := strconv.Itoa(os.Getpid())
+	// auth (4) * 8 + error (3)
+	want := "<35>1 2026-10-01T09:30:00Z metrics-1 secmetrics " + pid + " kpi_threshold - " +
+		"CEF:0|secmetrics|secmetrics|1.4.0|kpi_threshold|MTTR breached critical threshold|8|rt=1790847000000 cat=Response " +
+		"msg=MTTR is 5 hours, beyond the critical threshold of 4 dvchost=metrics-1 cs1Label=kpi cs1=mttr cs2Label=note cs2=a\\=b|c\\\\d"
+	if got := w.Format(testEvent); got != want {
+		t.Errorf("CEF message:\n got %s\nwant %s", got, want)
+	}
+
+	w, err = New(Config{Address: "siem.internal:514", Hostname: "metrics-1", Format: "rfc5424"}, "1.4.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := testEvent
+	event.Fields = []Field{{Name: "kpi", Value: "mttr"}, {Name: "note", Value: `say "hi" ]`}}
+	want = "<131>1 2026-10-01T09:30:00Z metrics-1 secmetrics " + pid + " kpi_threshold " +
+		`[secmetrics@32473 severity="8" category="Response" kpi="mttr" note="say \"hi\" \]"] ` +
+		"MTTR breached critical threshold: MTTR is 5 hours, beyond the critical threshold of 4"
+	if got := w.Format(event); got != want {
+		t.Errorf("RFC 5424 message:\n got %s\nwant %s", got, want)
+	}
+
+	for _, cfg := range []Config{
+		{Address: "siem.internal"},
+		{Address: "siem.internal:514", Protocol: "http"},
+		{Address: "siem.internal:514", Format: "leef"},
+		{Address: "siem.internal:514", Facility: "local9"},
+	} {
+		if _, err := New(cfg, ""); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+}
+
+func TestSendOverTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			var n int
+			if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+				return
+			}
+			message := make([]byte, n)
+			if _, err := io.ReadFull(r, message); err != nil {
+				return
+			}
+			received <- string(message)
+		}
+	}()
+
+	w, err := New(Config{Address: listener.Addr().String(), Protocol: "tcp", Hostname: "metrics-1"}, "1.4.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	multiline := testEvent
+	multiline.Message = "line one\nline two"
+	if err := w.Send(context.Background(), testEvent, multiline); err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []Event{testEvent, multiline} {
+		select {
+		case message := <-received:
+			if message != w.Format(event) {
+				t.Errorf("received %q, want %q", message, w.Format(event))
+			}
+			if strings.Contains(message, "\n") {
+				t.Errorf("CEF message contains a raw newline: %q", message)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not received")
+		}
+	}
+}
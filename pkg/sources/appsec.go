@@ -0,0 +1,390 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// AppSec pipeline KPI keys.
+const (
+	KPI_SASTAdoption    metrics.KPIKey = "sast_adoption"
+	KPI_DASTAdoption    metrics.KPIKey = "dast_adoption"
+	KPI_PipelineFixTime metrics.KPIKey = "pipeline_fix_time"
+	KPI_BuildsBlocked   metrics.KPIKey = "builds_blocked"
+)
+
+// AppSecConfig configures shift-left KPIs from CI security scan output.
+//
+// ResultsDir holds one directory per repository and, inside it, one
+// directory per build containing any of:
+//
+//	semgrep.json          output of `semgrep ci --json`
+//	sonarqube-issues.json response of /api/issues/search
+//	sonarqube-gate.json   response of /api/qualitygates/project_status
+//	dast*.json, zap*.json DAST reports (presence marks DAST adoption)
+//	build.json            optional {"time": RFC 3339, "blocked": bool}
+//
+// Build directories are ordered by name, so CI should name them with a
+// sortable build number or timestamp.
+type AppSecConfig struct {
+	ResultsDir string `yaml:"results_dir"`
+	// Repos lists the repositories expected to run scans; defaults to the
+	// repository directories present.
+	Repos []string `yaml:"repos"`
+	// WindowDays limits builds to the last N days (default 30).
+	WindowDays int `yaml:"window_days"`
+	// FixTimeTarget is the mean fix time target in hours (default 168).
+	FixTimeTarget float64 `yaml:"fix_time_target"`
+	// BlockedTarget is the acceptable percentage of blocked builds (default 10).
+	BlockedTarget float64 `yaml:"blocked_target"`
+}
+
+// appsecBuild is the scan output of one CI build.
+type appsecBuild struct {
+	time     time.Time
+	sast     bool
+	dast     bool
+	blocked  bool
+	findings map[string]bool
+	fixes    map[string]float64
+}
+
+func appsecDefinitions() []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_SASTAdoption, Name: "SAST Adoption", Unit: "%", Category: "AppSec", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+		{Key: KPI_DASTAdoption, Name: "DAST Adoption", Unit: "%", Category: "AppSec", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+		{Key: KPI_PipelineFixTime, Name: "Mean Fix Time (Pipeline Findings)", Unit: "hours", Category: "AppSec", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_BuildsBlocked, Name: "Builds Blocked by Security Gates", Unit: "%", Category: "AppSec", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+	}
+}
+
+// NewAppSecSource creates a source computing SAST/DAST adoption per
+// repository, mean fix time for pipeline findings and the share of builds
+// blocked by security gates.
+func NewAppSecSource(cfg AppSecConfig) (server.Source, error) {
+	if cfg.ResultsDir == "" {
+		return server.Source{}, fmt.Errorf("appsec: results_dir is required")
+	}
+	if cfg.WindowDays == 0 {
+		cfg.WindowDays = 30
+	}
+	if cfg.FixTimeTarget == 0 {
+		cfg.FixTimeTarget = 168
+	}
+	if cfg.BlockedTarget == 0 {
+		cfg.BlockedTarget = 10
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		repos := cfg.Repos
+		if len(repos) == 0 {
+			entries, err := os.ReadDir(cfg.ResultsDir)
+			if err != nil {
+				return fmt.Errorf("appsec: %w", err)
+			}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					repos = append(repos, entry.Name())
+				}
+			}
+		}
+
+		since := time.Now().AddDate(0, 0, -cfg.WindowDays)
+		var sastRepos, dastRepos, builds, blocked int
+		fixTimes := make(map[string]float64)
+
+		for _, repo := range repos {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			repoDir := filepath.Join(cfg.ResultsDir, repo)
+			repoBuilds, err := readAppSecBuilds(repoDir)
+			if err != nil {
+				return fmt.Errorf("appsec: %s: %w", repo, err)
+			}
+
+			var sast, dast bool
+			for i, build := range repoBuilds {
+				// Semgrep findings present in one build and gone in the
+				// next were fixed in between.
+				if i > 0 {
+					prev := repoBuilds[i-1]
+					for id := range prev.findings {
+						if !build.findings[id] && !build.time.Before(since) {
+							if first, ok := firstSeen(repoBuilds[:i], id); ok {
+								fixTimes[repo+"|"+id] = build.time.Sub(first).Hours()
+							}
+						}
+					}
+				}
+				for id, hours := range build.fixes {
+					fixTimes[repo+"|"+id] = hours
+				}
+
+				if build.time.Before(since) {
+					continue
+				}
+				builds++
+				if build.blocked {
+					blocked++
+				}
+				sast = sast || build.sast
+				dast = dast || build.dast
+			}
+
+			if sast {
+				sastRepos++
+			}
+			if dast {
+				dastRepos++
+			}
+			for _, adoption := range []struct {
+				id, name string
+				adopted  bool
+			}{{"sast", "SAST", sast}, {"dast", "DAST", dast}} {
+				value := 0.0
+				if adoption.adopted {
+					value = 100
+				}
+				collector.AddMetric(metrics.SecurityMetric{
+					ID:         "appsec-" + adoption.id + "-" + repo,
+					Name:       adoption.name + " Adoption (" + repo + ")",
+					Type:       metrics.TypePrevention,
+					Value:      value,
+					Unit:       "%",
+					Target:     100,
+					Status:     targetStatus(value, 100),
+					Category:   "AppSec",
+					Provenance: metrics.Provenance{Record: repoDir},
+				})
+			}
+		}
+
+		times := make([]float64, 0, len(fixTimes))
+		for _, hours := range fixTimes {
+			times = append(times, hours)
+		}
+
+		registerDefinitions(collector, appsecDefinitions())
+		collector.AddKPI(metrics.KPI{Key: KPI_SASTAdoption, Value: percent(sastRepos, len(repos)), Target: 100})
+		collector.AddKPI(metrics.KPI{Key: KPI_DASTAdoption, Value: percent(dastRepos, len(repos)), Target: 100})
+		collector.AddDurationKPI(KPI_PipelineFixTime, times, cfg.FixTimeTarget)
+		collector.AddKPI(metrics.KPI{Key: KPI_BuildsBlocked, Value: percent(blocked, builds), Target: cfg.BlockedTarget})
+		return nil
+	}
+
+	return server.Source{Name: "appsec", Collect: collect}, nil
+}
+
+// firstSeen returns the time of the first build in builds reporting id.
+func firstSeen(builds []appsecBuild, id string) (time.Time, bool) {
+	for _, build := range builds {
+		if build.findings[id] {
+			return build.time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// readAppSecBuilds reads the builds of one repository in name order.
+func readAppSecBuilds(dir string) ([]appsecBuild, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var builds []appsecBuild
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		build, err := readAppSecBuild(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		builds = append(builds, build)
+	}
+	return builds, nil
+}
+
+// readAppSecBuild reads the scan output of one build directory.
+func readAppSecBuild(dir string) (appsecBuild, error) {
+	build := appsecBuild{findings: make(map[string]bool), fixes: make(map[string]float64)}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return build, err
+	}
+	build.time = info.ModTime()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return build, err
+	}
+	var meta *struct {
+		Time    time.Time `json:"time"`
+		Blocked *bool     `json:"blocked"`
+	}
+
+	for _, entry := range entries {
+		name := strings.ToLower(entry.Name())
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case name == "semgrep.json":
+			build.sast = true
+			blocked, err := readSemgrep(path, build.findings)
+			if err != nil {
+				return build, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			build.blocked = build.blocked || blocked
+		case name == "sonarqube-issues.json":
+			build.sast = true
+			if err := readSonarQubeIssues(path, build.fixes); err != nil {
+				return build, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+		case name == "sonarqube-gate.json":
+			build.sast = true
+			var gate struct {
+				ProjectStatus struct {
+					Status string `json:"status"`
+				} `json:"projectStatus"`
+			}
+			if err := readJSONFile(path, &gate); err != nil {
+				return build, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			build.blocked = build.blocked || gate.ProjectStatus.Status == "ERROR"
+		case strings.HasSuffix(name, ".json") && (strings.HasPrefix(name, "dast") || strings.HasPrefix(name, "zap")):
+			build.dast = true
+		case name == "build.json":
+			if err := readJSONFile(path, &meta); err != nil {
+				return build, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+		}
+	}
+
+	if meta != nil {
+		if !meta.Time.IsZero() {
+			build.time = meta.Time
+		}
+		if meta.Blocked != nil {
+			build.blocked = *meta.Blocked
+		}
+	}
+	return build, nil
+}
+
+// readSemgrep records the findings of `semgrep ci --json` output and
+// reports whether any finding comes from a blocking rule.
+func readSemgrep(path string, findings map[string]bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return parseSemgrep(data, findings)
+}
+
+// parseSemgrep records the findings in semgrep JSON output and reports
+// whether any finding comes from a blocking rule.
+func parseSemgrep(data []byte, findings map[string]bool) (bool, error) {
+	var output struct {
+		Results []struct {
+			CheckID string `json:"check_id"`
+			Path    string `json:"path"`
+			Extra   struct {
+				Fingerprint string `json:"fingerprint"`
+				Lines       string `json:"lines"`
+				Severity    string `json:"severity"`
+				Metadata    struct {
+					Actions []string `json:"dev.semgrep.actions"`
+				} `json:"metadata"`
+			} `json:"extra"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return false, err
+	}
+
+	blocked := false
+	for _, result := range output.Results {
+		id := result.Extra.Fingerprint
+		// Unauthenticated scans redact the fingerprint.
+		if id == "" || id == "requires login" {
+			id = result.CheckID + "|" + result.Path + "|" + strings.TrimSpace(result.Extra.Lines)
+		}
+		findings[id] = true
+
+		if len(result.Extra.Metadata.Actions) > 0 {
+			for _, action := range result.Extra.Metadata.Actions {
+				blocked = blocked || action == "block"
+			}
+		} else if result.Extra.Severity == "ERROR" {
+			blocked = true
+		}
+	}
+	return blocked, nil
+}
+
+// sonarTimeLayout is the timestamp format used by the SonarQube Web API.
+const sonarTimeLayout = "2006-01-02T15:04:05-0700"
+
+// readSonarQubeIssues records fix times in hours of issues SonarQube
+// closed as fixed.
+func readSonarQubeIssues(path string, fixes map[string]float64) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return parseSonarQubeIssues(data, fixes)
+}
+
+// parseSonarQubeIssues records fix times in hours of the fixed issues in a
+// SonarQube api/issues/search response.
+func parseSonarQubeIssues(data []byte, fixes map[string]float64) error {
+	var output struct {
+		Issues []struct {
+			Key          string `json:"key"`
+			Resolution   string `json:"resolution"`
+			CreationDate string `json:"creationDate"`
+			CloseDate    string `json:"closeDate"`
+		} `json:"issues"`
+	}
+	if err := json.Unmarshal(data, &output); err != nil {
+		return err
+	}
+
+	for _, issue := range output.Issues {
+		if issue.Resolution != "FIXED" || issue.CloseDate == "" {
+			continue
+		}
+		created, err := time.Parse(sonarTimeLayout, issue.CreationDate)
+		if err != nil {
+			return fmt.Errorf("issue %s: %w", issue.Key, err)
+		}
+		closed, err := time.Parse(sonarTimeLayout, issue.CloseDate)
+		if err != nil {
+			return fmt.Errorf("issue %s: %w", issue.Key, err)
+		}
+		fixes["sonarqube:"+issue.Key] = closed.Sub(created).Hours()
+	}
+	return nil
+}
+
+// readJSONFile decodes the JSON file at path into v.
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,252 @@
+package sources
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+)
+
+// cacheableSources are the sources that fetch from HTTP APIs and may set a
+// cache_ttl.
+var cacheableSources = map[string]bool{
+	"fleetdm": true, "identity": true, "code_review": true, "scorecard": true, "grc": true, "secrets": true,
+}
+
+// sourceClients hands out the HTTP client of each source: the shared
+// client, or a caching one for sources with a cache_ttl.
+type sourceClients struct {
+	shared *http.Client
+	cached map[string]*http.Client
+}
+
+// newSourceClients creates the caching clients of the sources whose policy
+// sets a cache_ttl, each caching in its own directory under cfg.CacheDir
+// and encrypting its entries with cfg.Cipher.
+func newSourceClients(cfg Config, shared *http.Client) (*sourceClients, error) {
+	clients := &sourceClients{shared: shared, cached: make(map[string]*http.Client)}
+	for name, policy := range cfg.Policies {
+		if policy.CacheTTL == "" {
+			continue
+		}
+		if !cacheableSources[name] {
+			return nil, fmt.Errorf("policies: %s: cache_ttl applies only to sources fetching from HTTP APIs", name)
+		}
+		ttl, err := time.ParseDuration(policy.CacheTTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("policies: %s: cache_ttl: invalid duration %q", name, policy.CacheTTL)
+		}
+		dir := cfg.CacheDir
+		if dir == "" {
+			if dir, err = os.UserCacheDir(); err != nil {
+				return nil, fmt.Errorf("cache_dir: %w", err)
+			}
+			dir = filepath.Join(dir, "secmetrics")
+		}
+		dir = filepath.Join(dir, name)
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("cache_dir: %w", err)
+		}
+
+		next := shared.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		client := *shared
+		client.Transport = &cachingTransport{next: next, dir: dir, ttl: ttl, cipher: cfg.Cipher, now: time.Now}
+		clients.cached[name] = &client
+	}
+	return clients, nil
+}
+
+// get returns the client of the named source.
+func (c *sourceClients) get(name string) *http.Client {
+	if client, ok := c.cached[name]; ok {
+		return client
+	}
+	return c.shared
+}
+
+// cachingTransport serves successful GET responses from a disk cache for
+// ttl. Once an entry has expired it revalidates it with If-None-Match and
+// If-Modified-Since, so an unchanged resource costs the upstream API a 304
+// rather than a full response. Entries are named by a hash of the URL and
+// the credential headers, so a response is only served to requests made
+// with the same credentials, and responses are matched on the request
+// headers they Vary on; each source caches in its own directory.
+// With a cipher, entries are encrypted at rest like the store.
+type cachingTransport struct {
+	next   http.RoundTripper
+	dir    string
+	ttl    time.Duration
+	cipher *encryption.Cipher
+	now    func() time.Time
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Fetched time.Time   `json:"fetched"`
+	// Vary is a hash of the request headers the response varies on.
+	Vary string `json:"vary,omitempty"`
+}
+
+// credentialHeaders are the request headers the cacheable sources send
+// their credentials in.
+var credentialHeaders = []string{"Authorization", "Private-Token", "X-Vault-Token"}
+
+// cacheKey returns the name of the cache entry of req, a hash of its URL
+// and credential headers.
+func cacheKey(req *http.Request) string {
+	h := sha256.New()
+	io.WriteString(h, req.URL.String())
+	for _, name := range credentialHeaders {
+		h.Write([]byte{0})
+		io.WriteString(h, req.Header.Get(name))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// varyKey returns a hash of the values in req of the headers that the
+// response header lists in Vary, or "" if it lists none. A response that
+// varies on everything, with "Vary: *", is never cached.
+func varyKey(req *http.Request, header http.Header) (key string, cacheable bool) {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return "", false
+			}
+			if name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	if len(names) == 0 {
+		return "", true
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		io.WriteString(h, name+":"+strings.Join(req.Header.Values(name), ",")+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+	path := filepath.Join(t.dir, cacheKey(req)+".json")
+
+	entry := t.load(path)
+	if entry != nil {
+		// Another variant of the resource is fetched again and replaces it.
+		if vary, _ := varyKey(req, entry.Header); vary != entry.Vary {
+			entry = nil
+		}
+	}
+	if entry != nil && t.now().Sub(entry.Fetched) < t.ttl {
+		return entry.response(req), nil
+	}
+	original := req
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if modified := entry.Header.Get("Last-Modified"); modified != "" {
+			req.Header.Set("If-Modified-Since", modified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		entry.Fetched = t.now()
+		t.save(path, entry)
+		return entry.response(req), nil
+	}
+	vary, cacheable := varyKey(original, resp.Header)
+	if !cacheable || resp.StatusCode != http.StatusOK || strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	t.save(path, &cacheEntry{Header: header, Body: body, Fetched: t.now(), Vary: vary})
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// load returns the cache entry at path, or nil when there is none. With a
+// cipher, plaintext entries written before encryption was enabled are
+// ignored, so they are fetched again and replaced.
+func (t *cachingTransport) load(path string) *cacheEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	if t.cipher != nil {
+		if data, err = t.cipher.Decrypt(data); err != nil {
+			return nil
+		}
+	}
+	var entry cacheEntry
+	if json.Unmarshal(data, &entry) != nil {
+		return nil
+	}
+	return &entry
+}
+
+// save writes entry to path. A cache that cannot be written only costs
+// the next collection a full fetch, so errors are ignored.
+func (t *cachingTransport) save(path string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if t.cipher != nil {
+		if data, err = t.cipher.Encrypt(data); err != nil {
+			return
+		}
+	}
+	tmp := path + ".tmp"
+	if os.WriteFile(tmp, data, 0o600) == nil {
+		os.Rename(tmp, path)
+	}
+}
+
+// response returns the cached response to req.
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
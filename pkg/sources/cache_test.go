@@ -0,0 +1,163 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+)
+
+func TestCachingTransport(t *testing.T) {
+	var requests, notModified int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"hosts": 42}`))
+	}))
+	defer upstream.Close()
+
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	client := &http.Client{Transport: &cachingTransport{next: http.DefaultTransport, dir: t.TempDir(), ttl: 15 * time.Minute,
+		now: func() time.Time { return now }}}
+	fetch := func() int {
+		var body struct{ Hosts int }
+		if err := getJSON(context.Background(), client, upstream.URL+"/hosts", nil, &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Hosts
+	}
+
+	for _, step := range []struct {
+		advance               time.Duration
+		requests, notModified int
+	}{
+		{0, 1, 0},
+		// Served from the cache within the TTL
+		{10 * time.Minute, 1, 0},
+		// Revalidated once expired; the 304 renews the entry
+		{10 * time.Minute, 2, 1},
+		{10 * time.Minute, 2, 1},
+	} {
+		now = now.Add(step.advance)
+		if hosts := fetch(); hosts != 42 {
+			t.Errorf("hosts = %d, want 42", hosts)
+		}
+		if requests != step.requests || notModified != step.notModified {
+			t.Errorf("after %s: %d requests, %d not modified; want %d, %d", step.advance, requests, notModified, step.requests, step.notModified)
+		}
+	}
+}
+
+func TestCacheTTLPolicy(t *testing.T) {
+	cfg := Config{CacheDir: t.TempDir(), Policies: map[string]PolicyConfig{"fleetdm": {CacheTTL: "15m"}}}
+	clients, err := newSourceClients(cfg, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := clients.get("fleetdm").Transport.(*cachingTransport); !ok {
+		t.Error("fleetdm client does not cache")
+	}
+	if clients.get("scorecard").Transport != nil {
+		t.Error("scorecard client caches without a cache_ttl")
+	}
+
+	for _, policies := range []map[string]PolicyConfig{
+		{"tls": {CacheTTL: "15m"}},
+		{"fleetdm": {CacheTTL: "soon"}},
+	} {
+		if _, err := newSourceClients(Config{CacheDir: t.TempDir(), Policies: policies}, &http.Client{}); err == nil {
+			t.Errorf("policies %+v accepted", policies)
+		}
+	}
+}
+
+func TestCachingTransportKeysOnCredentialsAndVary(t *testing.T) {
+	var requests int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Vary", "Accept-Language")
+		fmt.Fprintf(w, `{"user": %q, "language": %q}`, r.Header.Get("Authorization"), r.Header.Get("Accept-Language"))
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &cachingTransport{next: http.DefaultTransport, dir: t.TempDir(), ttl: 15 * time.Minute, now: time.Now}}
+	fetch := func(token, language string) string {
+		var body struct{ User, Language string }
+		header := http.Header{"Authorization": {"Bearer " + token}, "Accept-Language": {language}}
+		if err := getJSON(context.Background(), client, upstream.URL+"/me", header, &body); err != nil {
+			t.Fatal(err)
+		}
+		return body.User + " " + body.Language
+	}
+
+	for _, step := range []struct {
+		token, language, want string
+		requests              int
+	}{
+		{"alice", "en", "Bearer alice en", 1},
+		{"alice", "en", "Bearer alice en", 1},
+		// Another token never reads what alice fetched
+		{"bob", "en", "Bearer bob en", 2},
+		{"alice", "en", "Bearer alice en", 2},
+		// Another variant of the response is fetched
+		{"alice", "de", "Bearer alice de", 3},
+	} {
+		if got := fetch(step.token, step.language); got != step.want {
+			t.Errorf("%s, %s: got %q, want %q", step.token, step.language, got, step.want)
+		}
+		if requests != step.requests {
+			t.Errorf("%s, %s: %d requests, want %d", step.token, step.language, requests, step.requests)
+		}
+	}
+}
+
+func TestCachingTransportEncryptsEntries(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"hosts": 42}`))
+	}))
+	defer upstream.Close()
+
+	key, err := encryption.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(key)
+	cipher, err := encryption.NewCipher(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	transport := &cachingTransport{next: http.DefaultTransport, dir: dir, ttl: 15 * time.Minute, cipher: cipher, now: time.Now}
+	var body struct{ Hosts int }
+	if err := getJSON(context.Background(), &http.Client{Transport: transport}, upstream.URL+"/hosts", nil, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("cache entries %v, %v", entries, err)
+	}
+	data, err := os.ReadFile(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !encryption.IsEncrypted(data) || bytes.Contains(data, []byte("hosts")) {
+		t.Errorf("cache entry is not encrypted: %q", data)
+	}
+	if entry := transport.load(entries[0]); entry == nil || string(entry.Body) != `{"hosts": 42}` {
+		t.Errorf("encrypted entry loads as %+v", entry)
+	}
+}
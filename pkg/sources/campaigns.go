@@ -0,0 +1,126 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/campaigns"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// CampaignKPIPrefix prefixes the progress KPI of each remediation
+// campaign, e.g. campaign_log4j_cleanup for a campaign named "Log4j
+// cleanup".
+const CampaignKPIPrefix = "campaign_"
+
+// campaignKey returns the progress KPI key of a campaign.
+func campaignKey(name string) metrics.KPIKey {
+	return metrics.KPIKey(CampaignKPIPrefix + slug(name))
+}
+
+// newCampaigns validates cfgs.
+func newCampaigns(cfgs []campaigns.Config) ([]*campaigns.Campaign, error) {
+	keys := make(map[metrics.KPIKey]string)
+	var list []*campaigns.Campaign
+	for _, cfg := range cfgs {
+		c, err := campaigns.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if !isRecordFile(cfg.Findings) {
+			return nil, fmt.Errorf("campaign %s: findings %s is not a .csv, .json or .jsonl file", cfg.Name, cfg.Findings)
+		}
+		key := campaignKey(cfg.Name)
+		if key == CampaignKPIPrefix {
+			return nil, fmt.Errorf("campaign: name %q has no letters or digits", cfg.Name)
+		}
+		if previous, ok := keys[key]; ok {
+			return nil, fmt.Errorf("campaign: campaigns %q and %q share the KPI key %s", previous, cfg.Name, key)
+		}
+		keys[key] = cfg.Name
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// readFindings reads the findings export of a campaign.
+func readFindings(path string) ([]campaigns.Finding, error) {
+	records, err := readRecords(path)
+	if err != nil {
+		return nil, fmt.Errorf("campaign: %s: %w", path, err)
+	}
+	var findings []campaigns.Finding
+	for _, record := range records {
+		id := strings.TrimSpace(record["finding_id"])
+		if id == "" {
+			continue
+		}
+		finding := campaigns.Finding{ID: id, Title: record["title"], Asset: record["asset"], Owner: record["owner"]}
+		finding.OpenedAt, _ = parseTimestamp(record["opened_at"])
+		finding.ResolvedAt, _ = parseTimestamp(record["resolved_at"])
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// CampaignStatuses evaluates the remediation campaigns of cfgs at now, in
+// configured order.
+func CampaignStatuses(cfgs []campaigns.Config, now time.Time) ([]campaigns.Status, error) {
+	list, err := newCampaigns(cfgs)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]campaigns.Status, 0, len(list))
+	for _, c := range list {
+		findings, err := readFindings(c.Findings)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, c.Evaluate(findings, now))
+	}
+	return statuses, nil
+}
+
+// NewCampaignsSource creates a source reporting the progress of each
+// remediation campaign against its ideal burn-down: the share of scoped
+// findings resolved, with the share the burn-down expects by now as the
+// target.
+func NewCampaignsSource(cfgs []campaigns.Config) (server.Source, error) {
+	list, err := newCampaigns(cfgs)
+	if err != nil {
+		return server.Source{}, err
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		now := time.Now()
+		for _, c := range list {
+			findings, err := readFindings(c.Findings)
+			if err != nil {
+				return err
+			}
+			status := c.Evaluate(findings, now)
+			key := campaignKey(c.Name)
+			registerDefinitions(collector, []metrics.KPIDefinition{{
+				Key: key, Name: c.Name + " Campaign Progress", Unit: "%", Category: "Remediation",
+				Description: c.Description, Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100),
+			}})
+			collector.AddKPI(metrics.KPI{Key: key, Value: status.Progress(), Target: status.Expected})
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "campaign-" + slug(c.Name),
+				Name:        "Open Findings (" + c.Name + ")",
+				Type:        metrics.TypeResponse,
+				Value:       float64(status.Open),
+				Unit:        "findings",
+				Status:      status.State,
+				Description: fmt.Sprintf("%d of %d findings resolved, %d by the deadline", status.Resolved, status.Total, status.ResolvedOnTime),
+				Category:    "Remediation",
+			})
+		}
+		return nil
+	}
+
+	return server.Source{Name: "campaigns", Collect: collect}, nil
+}
@@ -0,0 +1,530 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// Code review KPI keys.
+const (
+	KPI_SecurityReviewCoverage   metrics.KPIKey = "security_review_coverage"
+	KPI_UnreviewedDirectPushes   metrics.KPIKey = "unreviewed_direct_pushes"
+	KPI_BranchProtectionCoverage metrics.KPIKey = "branch_protection_coverage"
+)
+
+// CodeReviewConfig configures code review security coverage from GitHub
+// or GitLab.
+type CodeReviewConfig struct {
+	GitHub *GitHubConfig `yaml:"github"`
+	GitLab *GitLabConfig `yaml:"gitlab"`
+	// SensitivePaths are glob patterns of files requiring security review;
+	// a trailing "/**" matches everything below a directory.
+	SensitivePaths []string `yaml:"sensitive_paths"`
+	// WindowDays limits merged changes and pushes to the last N days (default 30).
+	WindowDays int `yaml:"window_days"`
+}
+
+// GitHubConfig configures access to a GitHub organization.
+type GitHubConfig struct {
+	Org   string `yaml:"org"`
+	Token string `yaml:"token"`
+	// APIURL overrides the API endpoint for GitHub Enterprise Server.
+	APIURL string `yaml:"api_url"`
+	// SecurityTeam is the slug of the team whose approval counts as a
+	// security review.
+	SecurityTeam string `yaml:"security_team"`
+}
+
+// GitLabConfig configures access to a GitLab group.
+type GitLabConfig struct {
+	Group string `yaml:"group"`
+	Token string `yaml:"token"`
+	// URL overrides https://gitlab.com for self-managed instances.
+	URL string `yaml:"url"`
+	// SecurityTeam lists the usernames whose approval counts as a
+	// security review.
+	SecurityTeam []string `yaml:"security_team"`
+}
+
+// reviewRepo is a repository and the protection of its default branch.
+type reviewRepo struct {
+	ID            string
+	Name          string
+	DefaultBranch string
+	Protected     bool
+}
+
+// reviewChange is a merged pull or merge request.
+type reviewChange struct {
+	Files     []string
+	Approvers []string
+}
+
+// codeHost abstracts the GitHub and GitLab APIs used for code review metrics.
+type codeHost interface {
+	Name() string
+	SecurityTeam(ctx context.Context) (map[string]bool, error)
+	Repos(ctx context.Context) ([]reviewRepo, error)
+	MergedChanges(ctx context.Context, repo reviewRepo, since time.Time) ([]reviewChange, error)
+	DirectPushes(ctx context.Context, repo reviewRepo, since time.Time) (int, error)
+}
+
+func codeReviewDefinitions() []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_SecurityReviewCoverage, Name: "Security Review Coverage (Sensitive Paths)", Unit: "%", Category: "AppSec", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+		{Key: KPI_UnreviewedDirectPushes, Name: "Unreviewed Direct Pushes to Protected Branches", Unit: "commits", Category: "AppSec", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_BranchProtectionCoverage, Name: "Branch Protection Coverage", Unit: "%", Category: "AppSec", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+	}
+}
+
+// NewCodeReviewSource creates a source computing the share of merged
+// changes to sensitive paths approved by the security team, direct pushes
+// to protected default branches, and branch protection coverage.
+func NewCodeReviewSource(cfg CodeReviewConfig, client *http.Client) (server.Source, error) {
+	var hosts []codeHost
+	if cfg.GitHub != nil {
+		if cfg.GitHub.Org == "" || cfg.GitHub.Token == "" {
+			return server.Source{}, fmt.Errorf("code_review: github org and token are required")
+		}
+		hosts = append(hosts, newGitHubHost(*cfg.GitHub, client))
+	}
+	if cfg.GitLab != nil {
+		if cfg.GitLab.Group == "" || cfg.GitLab.Token == "" {
+			return server.Source{}, fmt.Errorf("code_review: gitlab group and token are required")
+		}
+		hosts = append(hosts, newGitLabHost(*cfg.GitLab, client))
+	}
+	if len(hosts) == 0 {
+		return server.Source{}, fmt.Errorf("code_review: github or gitlab is required")
+	}
+	if len(cfg.SensitivePaths) == 0 {
+		return server.Source{}, fmt.Errorf("code_review: at least one sensitive path is required")
+	}
+	if cfg.WindowDays == 0 {
+		cfg.WindowDays = 30
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		since := time.Now().AddDate(0, 0, -cfg.WindowDays)
+		var repos, protected, sensitive, reviewed, pushes int
+
+		for _, host := range hosts {
+			team, err := host.SecurityTeam(ctx)
+			if err != nil {
+				return fmt.Errorf("code_review: %s: security team: %w", host.Name(), err)
+			}
+			hostRepos, err := host.Repos(ctx)
+			if err != nil {
+				return fmt.Errorf("code_review: %s: %w", host.Name(), err)
+			}
+
+			for _, repo := range hostRepos {
+				repos++
+				changes, err := host.MergedChanges(ctx, repo, since)
+				if err != nil {
+					return fmt.Errorf("code_review: %s: %s: %w", host.Name(), repo.Name, err)
+				}
+				for _, change := range changes {
+					if !touchesSensitivePath(change.Files, cfg.SensitivePaths) {
+						continue
+					}
+					sensitive++
+					for _, approver := range change.Approvers {
+						if team[strings.ToLower(approver)] {
+							reviewed++
+							break
+						}
+					}
+				}
+
+				if !repo.Protected {
+					continue
+				}
+				protected++
+				n, err := host.DirectPushes(ctx, repo, since)
+				if err != nil {
+					return fmt.Errorf("code_review: %s: %s: %w", host.Name(), repo.Name, err)
+				}
+				pushes += n
+			}
+		}
+
+		coverage := 100.0
+		if sensitive > 0 {
+			coverage = percent(reviewed, sensitive)
+		}
+		registerDefinitions(collector, codeReviewDefinitions())
+		collector.AddKPI(metrics.KPI{Key: KPI_SecurityReviewCoverage, Value: coverage, Target: 100})
+		collector.AddKPI(metrics.KPI{Key: KPI_UnreviewedDirectPushes, Value: float64(pushes), Target: 0})
+		collector.AddKPI(metrics.KPI{Key: KPI_BranchProtectionCoverage, Value: percent(protected, repos), Target: 100})
+		return nil
+	}
+
+	return server.Source{Name: "code_review", Collect: collect}, nil
+}
+
+// touchesSensitivePath reports whether any file matches a sensitive pattern.
+func touchesSensitivePath(files, patterns []string) bool {
+	for _, file := range files {
+		for _, pattern := range patterns {
+			if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+				if strings.HasPrefix(file, prefix+"/") {
+					return true
+				}
+				continue
+			}
+			if ok, _ := path.Match(pattern, file); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, path.Base(file)); ok && !strings.Contains(pattern, "/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gitHubHost implements codeHost for a GitHub organization.
+type gitHubHost struct {
+	config GitHubConfig
+	client *http.Client
+	apiURL string
+	header http.Header
+}
+
+func newGitHubHost(cfg GitHubConfig, client *http.Client) *gitHubHost {
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	return &gitHubHost{
+		config: cfg,
+		client: client,
+		apiURL: strings.TrimRight(apiURL, "/"),
+		header: http.Header{
+			"Authorization":        {"Bearer " + cfg.Token},
+			"X-Github-Api-Version": {"2022-11-28"},
+		},
+	}
+}
+
+func (h *gitHubHost) Name() string { return "github" }
+
+func (h *gitHubHost) SecurityTeam(ctx context.Context) (map[string]bool, error) {
+	team := make(map[string]bool)
+	if h.config.SecurityTeam == "" {
+		return team, nil
+	}
+	next := h.apiURL + "/orgs/" + url.PathEscape(h.config.Org) + "/teams/" + url.PathEscape(h.config.SecurityTeam) + "/members?per_page=100"
+	for next != "" {
+		var page []struct {
+			Login string `json:"login"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &page); err != nil {
+			return nil, err
+		}
+		for _, member := range page {
+			team[strings.ToLower(member.Login)] = true
+		}
+	}
+	return team, nil
+}
+
+func (h *gitHubHost) Repos(ctx context.Context) ([]reviewRepo, error) {
+	var repos []reviewRepo
+	next := h.apiURL + "/orgs/" + url.PathEscape(h.config.Org) + "/repos?type=all&per_page=100"
+	for next != "" {
+		var page []struct {
+			Name          string `json:"name"`
+			DefaultBranch string `json:"default_branch"`
+			Archived      bool   `json:"archived"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page {
+			if r.Archived {
+				continue
+			}
+			var branch struct {
+				Protected bool `json:"protected"`
+			}
+			if err := getJSON(ctx, h.client, h.repoURL(r.Name)+"/branches/"+url.PathEscape(r.DefaultBranch), h.header, &branch); err != nil {
+				return nil, fmt.Errorf("%s: %w", r.Name, err)
+			}
+			repos = append(repos, reviewRepo{ID: r.Name, Name: r.Name, DefaultBranch: r.DefaultBranch, Protected: branch.Protected})
+		}
+	}
+	return repos, nil
+}
+
+func (h *gitHubHost) MergedChanges(ctx context.Context, repo reviewRepo, since time.Time) ([]reviewChange, error) {
+	var changes []reviewChange
+	next := h.repoURL(repo.ID) + "/pulls?state=closed&sort=updated&direction=desc&per_page=100"
+	for next != "" {
+		var page []struct {
+			Number    int        `json:"number"`
+			MergedAt  *time.Time `json:"merged_at"`
+			UpdatedAt time.Time  `json:"updated_at"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &page); err != nil {
+			return nil, err
+		}
+		for _, pr := range page {
+			// Pulls are sorted by update time, so older pages are skipped.
+			if pr.UpdatedAt.Before(since) {
+				return changes, nil
+			}
+			if pr.MergedAt == nil || pr.MergedAt.Before(since) {
+				continue
+			}
+			change, err := h.pullChange(ctx, repo, pr.Number)
+			if err != nil {
+				return nil, fmt.Errorf("pull %d: %w", pr.Number, err)
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// pullChange fetches the files and approvers of a pull request.
+func (h *gitHubHost) pullChange(ctx context.Context, repo reviewRepo, number int) (reviewChange, error) {
+	var change reviewChange
+	pullURL := h.repoURL(repo.ID) + "/pulls/" + strconv.Itoa(number)
+
+	for next := pullURL + "/files?per_page=100"; next != ""; {
+		var files []struct {
+			Filename string `json:"filename"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &files); err != nil {
+			return change, err
+		}
+		for _, f := range files {
+			change.Files = append(change.Files, f.Filename)
+		}
+	}
+	for next := pullURL + "/reviews?per_page=100"; next != ""; {
+		var reviews []struct {
+			State string `json:"state"`
+			User  struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &reviews); err != nil {
+			return change, err
+		}
+		for _, r := range reviews {
+			if r.State == "APPROVED" {
+				change.Approvers = append(change.Approvers, r.User.Login)
+			}
+		}
+	}
+	return change, nil
+}
+
+func (h *gitHubHost) DirectPushes(ctx context.Context, repo reviewRepo, since time.Time) (int, error) {
+	pushes := 0
+	query := url.Values{"sha": {repo.DefaultBranch}, "since": {since.UTC().Format(time.RFC3339)}, "per_page": {"100"}}
+	for next := h.repoURL(repo.ID) + "/commits?" + query.Encode(); next != ""; {
+		var commits []struct {
+			SHA string `json:"sha"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &commits); err != nil {
+			return 0, err
+		}
+		for _, commit := range commits {
+			var pulls []struct {
+				Number int `json:"number"`
+			}
+			if err := getJSON(ctx, h.client, h.repoURL(repo.ID)+"/commits/"+commit.SHA+"/pulls", h.header, &pulls); err != nil {
+				return 0, fmt.Errorf("commit %s: %w", commit.SHA, err)
+			}
+			if len(pulls) == 0 {
+				pushes++
+			}
+		}
+	}
+	return pushes, nil
+}
+
+func (h *gitHubHost) repoURL(name string) string {
+	return h.apiURL + "/repos/" + url.PathEscape(h.config.Org) + "/" + url.PathEscape(name)
+}
+
+// gitLabHost implements codeHost for a GitLab group.
+type gitLabHost struct {
+	config GitLabConfig
+	client *http.Client
+	apiURL string
+	header http.Header
+}
+
+func newGitLabHost(cfg GitLabConfig, client *http.Client) *gitLabHost {
+	baseURL := cfg.URL
+	if baseURL == "" {
+		baseURL = "https://gitlab.com"
+	}
+	return &gitLabHost{
+		config: cfg,
+		client: client,
+		apiURL: strings.TrimRight(baseURL, "/") + "/api/v4",
+		header: http.Header{"Private-Token": {cfg.Token}},
+	}
+}
+
+func (h *gitLabHost) Name() string { return "gitlab" }
+
+func (h *gitLabHost) SecurityTeam(ctx context.Context) (map[string]bool, error) {
+	team := make(map[string]bool)
+	for _, username := range h.config.SecurityTeam {
+		team[strings.ToLower(username)] = true
+	}
+	return team, nil
+}
+
+func (h *gitLabHost) Repos(ctx context.Context) ([]reviewRepo, error) {
+	var repos []reviewRepo
+	next := h.apiURL + "/groups/" + url.PathEscape(h.config.Group) + "/projects?include_subgroups=true&archived=false&per_page=100"
+	for next != "" {
+		var page []struct {
+			ID                int    `json:"id"`
+			PathWithNamespace string `json:"path_with_namespace"`
+			DefaultBranch     string `json:"default_branch"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &page); err != nil {
+			return nil, err
+		}
+		for _, p := range page {
+			repo := reviewRepo{ID: strconv.Itoa(p.ID), Name: p.PathWithNamespace, DefaultBranch: p.DefaultBranch}
+			if p.DefaultBranch != "" {
+				protected, err := h.isProtected(ctx, repo)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", p.PathWithNamespace, err)
+				}
+				repo.Protected = protected
+			}
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
+
+// isProtected reports whether the project's default branch is protected.
+func (h *gitLabHost) isProtected(ctx context.Context, repo reviewRepo) (bool, error) {
+	var branch struct {
+		Protected bool `json:"protected"`
+	}
+	err := getJSON(ctx, h.client, h.projectURL(repo)+"/repository/branches/"+url.PathEscape(repo.DefaultBranch), h.header, &branch)
+	return branch.Protected, err
+}
+
+func (h *gitLabHost) MergedChanges(ctx context.Context, repo reviewRepo, since time.Time) ([]reviewChange, error) {
+	var changes []reviewChange
+	query := url.Values{"state": {"merged"}, "updated_after": {since.UTC().Format(time.RFC3339)}, "per_page": {"100"}}
+	for next := h.projectURL(repo) + "/merge_requests?" + query.Encode(); next != ""; {
+		var page []struct {
+			IID      int        `json:"iid"`
+			MergedAt *time.Time `json:"merged_at"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &page); err != nil {
+			return nil, err
+		}
+		for _, mr := range page {
+			if mr.MergedAt == nil || mr.MergedAt.Before(since) {
+				continue
+			}
+			change, err := h.mergeRequestChange(ctx, repo, mr.IID)
+			if err != nil {
+				return nil, fmt.Errorf("merge request !%d: %w", mr.IID, err)
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// mergeRequestChange fetches the files and approvers of a merge request.
+func (h *gitLabHost) mergeRequestChange(ctx context.Context, repo reviewRepo, iid int) (reviewChange, error) {
+	var change reviewChange
+	mrURL := h.projectURL(repo) + "/merge_requests/" + strconv.Itoa(iid)
+
+	for next := mrURL + "/diffs?per_page=100"; next != ""; {
+		var diffs []struct {
+			OldPath string `json:"old_path"`
+			NewPath string `json:"new_path"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &diffs); err != nil {
+			return change, err
+		}
+		for _, d := range diffs {
+			change.Files = append(change.Files, d.NewPath)
+			if d.OldPath != d.NewPath {
+				change.Files = append(change.Files, d.OldPath)
+			}
+		}
+	}
+
+	var approvals struct {
+		ApprovedBy []struct {
+			User struct {
+				Username string `json:"username"`
+			} `json:"user"`
+		} `json:"approved_by"`
+	}
+	if err := getJSON(ctx, h.client, mrURL+"/approvals", h.header, &approvals); err != nil {
+		return change, err
+	}
+	for _, a := range approvals.ApprovedBy {
+		change.Approvers = append(change.Approvers, a.User.Username)
+	}
+	return change, nil
+}
+
+func (h *gitLabHost) DirectPushes(ctx context.Context, repo reviewRepo, since time.Time) (int, error) {
+	pushes := 0
+	query := url.Values{"ref_name": {repo.DefaultBranch}, "since": {since.UTC().Format(time.RFC3339)}, "per_page": {"100"}}
+	for next := h.projectURL(repo) + "/repository/commits?" + query.Encode(); next != ""; {
+		var commits []struct {
+			ID string `json:"id"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, h.client, next, h.header, &commits); err != nil {
+			return 0, err
+		}
+		for _, commit := range commits {
+			var mrs []struct {
+				IID int `json:"iid"`
+			}
+			if err := getJSON(ctx, h.client, h.projectURL(repo)+"/repository/commits/"+commit.ID+"/merge_requests", h.header, &mrs); err != nil {
+				return 0, fmt.Errorf("commit %s: %w", commit.ID, err)
+			}
+			if len(mrs) == 0 {
+				pushes++
+			}
+		}
+	}
+	return pushes, nil
+}
+
+func (h *gitLabHost) projectURL(repo reviewRepo) string {
+	return h.apiURL + "/projects/" + url.PathEscape(repo.ID)
+}
@@ -0,0 +1,125 @@
+package sources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pagedAPI serves JSON pages by path, authenticated by the header name and
+// value: page n of a path answers ?page=n, with a Link to the next page as
+// GitHub and GitLab send.
+func pagedAPI(t *testing.T, name, value string, pages map[string][]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(name) != value {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		bodies, ok := pages[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request for %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+		if page < len(bodies) {
+			query := r.URL.Query()
+			query.Set("page", strconv.Itoa(page+1))
+			w.Header().Set("Link", `<http://`+r.Host+r.URL.Path+"?"+query.Encode()+`>; rel="next"`)
+		}
+		w.Write([]byte(bodies[page-1]))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// codeReviewKPIs returns the coverage, direct push and branch protection
+// KPIs collected by a code review source.
+func codeReviewKPIs(t *testing.T, cfg CodeReviewConfig) [3]float64 {
+	t.Helper()
+	source, err := NewCodeReviewSource(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpis := kpiValues(collectSource(t, source))
+	return [3]float64{kpis[KPI_SecurityReviewCoverage], kpis[KPI_UnreviewedDirectPushes], kpis[KPI_BranchProtectionCoverage]}
+}
+
+func TestCodeReviewGitHub(t *testing.T) {
+	recent := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	old := time.Now().AddDate(0, 0, -60).UTC().Format(time.RFC3339)
+	r := strings.NewReplacer("RECENT", recent, "OLD", old)
+	api := pagedAPI(t, "Authorization", "Bearer token", map[string][]string{
+		"/orgs/acme/teams/security/members": {`[{"login": "Sec-Alice"}]`, `[{"login": "sec-bob"}]`},
+		"/orgs/acme/repos": {
+			`[{"name": "api", "default_branch": "main"}, {"name": "legacy", "default_branch": "master", "archived": true}]`,
+			`[{"name": "web", "default_branch": "main"}]`,
+		},
+		"/repos/acme/api/branches/main": {`{"protected": true}`},
+		"/repos/acme/web/branches/main": {`{"protected": false}`},
+		"/repos/acme/api/pulls": {
+			r.Replace(`[{"number": 1, "merged_at": "RECENT", "updated_at": "RECENT"}, {"number": 2, "merged_at": null, "updated_at": "RECENT"}]`),
+			// Pulls are sorted by update, so the first old one ends the listing
+			r.Replace(`[{"number": 3, "merged_at": "RECENT", "updated_at": "RECENT"}, {"number": 4, "merged_at": "OLD", "updated_at": "OLD"}]`),
+		},
+		"/repos/acme/api/pulls/1/files":   {`[{"filename": "auth/login.go"}]`},
+		"/repos/acme/api/pulls/1/reviews": {`[{"state": "APPROVED", "user": {"login": "sec-alice"}}]`},
+		"/repos/acme/api/pulls/3/files":   {`[{"filename": "README.md"}]`, `[{"filename": "crypto/keys.go"}]`},
+		"/repos/acme/api/pulls/3/reviews": {`[{"state": "APPROVED", "user": {"login": "dev-carol"}}, {"state": "COMMENTED", "user": {"login": "sec-bob"}}]`},
+		"/repos/acme/web/pulls":           {r.Replace(`[{"number": 5, "merged_at": "RECENT", "updated_at": "RECENT"}]`)},
+		"/repos/acme/web/pulls/5/files":   {`[{"filename": "docs/index.md"}]`},
+		"/repos/acme/web/pulls/5/reviews": {`[]`},
+		// Only the protected branch is checked for direct pushes
+		"/repos/acme/api/commits":          {`[{"sha": "c1"}, {"sha": "c2"}]`, `[{"sha": "c3"}]`},
+		"/repos/acme/api/commits/c1/pulls": {`[{"number": 1}]`},
+		"/repos/acme/api/commits/c2/pulls": {`[]`},
+		"/repos/acme/api/commits/c3/pulls": {`[]`},
+	})
+
+	got := codeReviewKPIs(t, CodeReviewConfig{
+		GitHub:         &GitHubConfig{Org: "acme", Token: "token", APIURL: api.URL, SecurityTeam: "security"},
+		SensitivePaths: []string{"auth/**", "crypto/*.go"},
+	})
+	// Of the pulls touching sensitive paths, #1 is approved by the
+	// security team and #3 only by a developer; c2 and c3 were pushed
+	// without a pull; api of the two active repos is protected.
+	if want := [3]float64{50, 2, 50}; got != want {
+		t.Errorf("review coverage, direct pushes, protection = %v, want %v", got, want)
+	}
+}
+
+func TestCodeReviewGitLab(t *testing.T) {
+	recent := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	old := time.Now().AddDate(0, 0, -60).UTC().Format(time.RFC3339)
+	r := strings.NewReplacer("RECENT", recent, "OLD", old)
+	api := pagedAPI(t, "Private-Token", "token", map[string][]string{
+		"/api/v4/groups/platform/projects": {
+			`[{"id": 7, "path_with_namespace": "platform/api", "default_branch": "main"}]`,
+			`[{"id": 8, "path_with_namespace": "platform/empty", "default_branch": ""}]`,
+		},
+		"/api/v4/projects/7/repository/branches/main": {`{"protected": true}`},
+		"/api/v4/projects/7/merge_requests":           {r.Replace(`[{"iid": 1, "merged_at": "RECENT"}, {"iid": 2, "merged_at": "OLD"}]`)},
+		// A file moved out of a sensitive path still needs review
+		"/api/v4/projects/7/merge_requests/1/diffs":               {`[{"old_path": "auth/session.go", "new_path": "lib/session.go"}]`},
+		"/api/v4/projects/7/merge_requests/1/approvals":           {`{"approved_by": [{"user": {"username": "SecCarol"}}]}`},
+		"/api/v4/projects/7/repository/commits":                   {`[{"id": "a1"}]`, `[{"id": "b2"}]`},
+		"/api/v4/projects/7/repository/commits/a1/merge_requests": {`[]`},
+		"/api/v4/projects/7/repository/commits/b2/merge_requests": {`[{"iid": 1}]`},
+		"/api/v4/projects/8/merge_requests":                       {`[]`},
+	})
+
+	got := codeReviewKPIs(t, CodeReviewConfig{
+		GitLab:         &GitLabConfig{Group: "platform", Token: "token", URL: api.URL + "/", SecurityTeam: []string{"seccarol"}},
+		SensitivePaths: []string{"auth/**"},
+	})
+	if want := [3]float64{100, 1, 50}; got != want {
+		t.Errorf("review coverage, direct pushes, protection = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/expr"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+	"github.com/hallucinaut/secmetrics/pkg/wasm"
+)
+
+// DerivedConfig configures a KPI computed from other KPIs by an expression
+// or a sandboxed WebAssembly function, e.g. a custom formula or score.
+type DerivedConfig struct {
+	Key       metrics.KPIKey    `yaml:"key"`
+	Name      string            `yaml:"name"`
+	Unit      string            `yaml:"unit"`
+	Category  string            `yaml:"category"`
+	Direction metrics.Direction `yaml:"direction"`
+	Target    float64           `yaml:"target"`
+	// Min and Max bound accepted results, e.g. 0 and 100 for a score.
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+	// Expression computes the KPI from the KPIs it names, e.g.
+	// "(mttd + mttc) / 2"; see package expr. Set either Expression or
+	// Module.
+	Expression string `yaml:"expression"`
+	// Inputs are the KPIs passed to the function, in order.
+	Inputs []metrics.KPIKey `yaml:"inputs"`
+	// Module is the .wasm file implementing the function.
+	Module string `yaml:"module"`
+	// Function is the exported function name (default "compute").
+	Function string `yaml:"function"`
+	// Timeout bounds each evaluation, e.g. "500ms" (default 1s).
+	Timeout string `yaml:"timeout"`
+}
+
+// derivedKPI is a derived KPI with its parsed expression or compiled
+// function.
+type derivedKPI struct {
+	config     DerivedConfig
+	expression *expr.Expr
+	function   *wasm.Function
+}
+
+// NewDerivedSource creates a source computing derived KPIs from the KPIs
+// collected so far. Derived KPIs are evaluated in order, so later ones may
+// use earlier ones as inputs.
+func NewDerivedSource(cfgs []DerivedConfig) (server.Source, error) {
+	derived := make([]derivedKPI, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Key == "" || (cfg.Module == "") == (cfg.Expression == "") {
+			return server.Source{}, fmt.Errorf("derived: key and one of module or expression are required")
+		}
+		if cfg.Expression != "" {
+			expression, err := expr.Parse(cfg.Expression)
+			if err != nil {
+				return server.Source{}, fmt.Errorf("derived %s: %w", cfg.Key, err)
+			}
+			derived = append(derived, derivedKPI{config: cfg, expression: expression})
+			continue
+		}
+		if cfg.Function == "" {
+			cfg.Function = "compute"
+		}
+		var timeout time.Duration
+		if cfg.Timeout != "" {
+			var err error
+			timeout, err = time.ParseDuration(cfg.Timeout)
+			if err != nil {
+				return server.Source{}, fmt.Errorf("derived %s timeout: %w", cfg.Key, err)
+			}
+		}
+		function, err := wasm.Load(context.Background(), cfg.Module, cfg.Function, timeout)
+		if err != nil {
+			return server.Source{}, fmt.Errorf("derived %s: %w", cfg.Key, err)
+		}
+		derived = append(derived, derivedKPI{config: cfg, function: function})
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		var firstErr error
+		for _, d := range derived {
+			if err := d.evaluate(ctx, collector); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	return server.Source{Name: "derived", Collect: collect}, nil
+}
+
+// evaluate computes the derived KPI and adds it to collector.
+func (d derivedKPI) evaluate(ctx context.Context, collector *metrics.MetricsCollector) error {
+	cfg := d.config
+	if _, ok := collector.GetKPIDefinition(cfg.Key); !ok {
+		err := collector.RegisterKPIDefinition(metrics.KPIDefinition{
+			Key:       cfg.Key,
+			Name:      cfg.Name,
+			Unit:      cfg.Unit,
+			Category:  cfg.Category,
+			Direction: cfg.Direction,
+			Min:       cfg.Min,
+			Max:       cfg.Max,
+		})
+		if err != nil {
+			return fmt.Errorf("derived %s: %w", cfg.Key, err)
+		}
+	}
+
+	value, err := d.compute(ctx, collector)
+	if err != nil {
+		return fmt.Errorf("derived %s: %w", cfg.Key, err)
+	}
+
+	def, _ := collector.GetKPIDefinition(cfg.Key)
+	if err := def.Validate(value); err != nil {
+		return err
+	}
+	collector.AddKPI(metrics.KPI{Key: cfg.Key, Value: value, Target: cfg.Target})
+	return nil
+}
+
+// compute evaluates the expression or calls the function on the current
+// KPIs.
+func (d derivedKPI) compute(ctx context.Context, collector *metrics.MetricsCollector) (float64, error) {
+	if d.expression != nil {
+		return d.expression.Eval(expr.KPIs(collector)), nil
+	}
+	inputs := make([]wasm.Input, len(d.config.Inputs))
+	for i, key := range d.config.Inputs {
+		kpi := collector.GetKPI(key)
+		if kpi == nil {
+			inputs[i].Missing = true
+			continue
+		}
+		inputs[i] = wasm.Input{Value: kpi.Value, Target: kpi.Target}
+	}
+	return d.function.Call(ctx, inputs)
+}
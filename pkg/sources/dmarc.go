@@ -0,0 +1,231 @@
+package sources
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// Email security KPI keys.
+const (
+	KPI_DMARCPassRate            metrics.KPIKey = "dmarc_pass_rate"
+	KPI_DMARCSpoofingAttempts    metrics.KPIKey = "dmarc_spoofing_attempts"
+	KPI_DMARCEnforcementCoverage metrics.KPIKey = "dmarc_enforcement_coverage"
+)
+
+// DMARCConfig configures email security KPIs computed from DMARC
+// aggregate (RUA) reports saved to a directory.
+type DMARCConfig struct {
+	// ReportsDir holds aggregate reports as .xml, .xml.gz or .zip files.
+	ReportsDir string `yaml:"reports_dir"`
+	// Domains lists the owned domains; defaults to the domains seen in reports.
+	Domains []string `yaml:"domains"`
+	// WindowDays limits reports to those ending in the last N days (default 7).
+	WindowDays int `yaml:"window_days"`
+	// PassRateTarget is the DMARC pass rate target in percent (default 98).
+	PassRateTarget float64 `yaml:"pass_rate_target"`
+}
+
+// dmarcFeedback is the subset of the RFC 7489 aggregate report schema used.
+type dmarcFeedback struct {
+	DateRange struct {
+		End int64 `xml:"end"`
+	} `xml:"report_metadata>date_range"`
+	Policy struct {
+		Domain string `xml:"domain"`
+		P      string `xml:"p"`
+	} `xml:"policy_published"`
+	Records []struct {
+		Count int    `xml:"row>count"`
+		DKIM  string `xml:"row>policy_evaluated>dkim"`
+		SPF   string `xml:"row>policy_evaluated>spf"`
+	} `xml:"record"`
+}
+
+func dmarcDefinitions() []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_DMARCPassRate, Name: "DMARC Pass Rate", Unit: "%", Category: "Prevention", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+		{Key: KPI_DMARCSpoofingAttempts, Name: "Spoofing Attempts (DMARC failures)", Unit: "messages", Category: "Detection", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_DMARCEnforcementCoverage, Name: "DMARC Enforcement Coverage", Unit: "%", Category: "Prevention", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+	}
+}
+
+// NewDMARCSource creates a source computing DMARC pass rate, spoofing
+// attempt volume and enforcement (p=quarantine or p=reject) coverage.
+func NewDMARCSource(cfg DMARCConfig) (server.Source, error) {
+	if cfg.ReportsDir == "" {
+		return server.Source{}, fmt.Errorf("dmarc: reports_dir is required")
+	}
+	if cfg.WindowDays == 0 {
+		cfg.WindowDays = 7
+	}
+	if cfg.PassRateTarget == 0 {
+		cfg.PassRateTarget = 98
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		reports, err := readDMARCReports(cfg.ReportsDir)
+		if err != nil {
+			return fmt.Errorf("dmarc: %w", err)
+		}
+
+		cutoff := time.Now().AddDate(0, 0, -cfg.WindowDays).Unix()
+		owned := make(map[string]bool)
+		for _, domain := range cfg.Domains {
+			owned[strings.ToLower(domain)] = true
+		}
+
+		var total, passed int
+		policies := make(map[string]string)
+		for _, report := range reports {
+			if report.DateRange.End < cutoff {
+				continue
+			}
+			domain := strings.ToLower(report.Policy.Domain)
+			if len(owned) > 0 && !owned[domain] {
+				continue
+			}
+			policies[domain] = strings.ToLower(report.Policy.P)
+			for _, record := range report.Records {
+				total += record.Count
+				if record.DKIM == "pass" || record.SPF == "pass" {
+					passed += record.Count
+				}
+			}
+		}
+
+		domains := cfg.Domains
+		if len(domains) == 0 {
+			for domain := range policies {
+				domains = append(domains, domain)
+			}
+		}
+		enforced := 0
+		for _, domain := range domains {
+			if p := policies[strings.ToLower(domain)]; p == "quarantine" || p == "reject" {
+				enforced++
+			}
+		}
+
+		registerDefinitions(collector, dmarcDefinitions())
+		collector.AddKPI(metrics.KPI{Key: KPI_DMARCPassRate, Value: percent(passed, total), Target: cfg.PassRateTarget})
+		collector.AddKPI(metrics.KPI{Key: KPI_DMARCSpoofingAttempts, Value: float64(total - passed), Target: 0})
+		collector.AddKPI(metrics.KPI{Key: KPI_DMARCEnforcementCoverage, Value: percent(enforced, len(domains)), Target: 100})
+		return nil
+	}
+
+	return server.Source{Name: "dmarc", Collect: collect}, nil
+}
+
+// readDMARCReports parses every aggregate report in dir.
+func readDMARCReports(dir string) ([]dmarcFeedback, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []dmarcFeedback
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		docs, err := readDMARCFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		for _, doc := range docs {
+			var report dmarcFeedback
+			if err := xml.Unmarshal(doc, &report); err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// maxDMARCReportSize bounds a decompressed aggregate report so a
+// compression bomb cannot exhaust memory.
+const maxDMARCReportSize = 32 << 20
+
+// readDMARCFile returns the XML documents in a report file. Files with
+// extensions other than .xml, .gz and .zip are skipped.
+func readDMARCFile(path string) ([][]byte, error) {
+	name := strings.ToLower(path)
+	if !strings.HasSuffix(name, ".xml") && !strings.HasSuffix(name, ".gz") && !strings.HasSuffix(name, ".zip") {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return dmarcDocuments(name, data)
+}
+
+// dmarcDocuments returns the XML documents in the report file data named
+// name, decompressing gzip and zip archives.
+func dmarcDocuments(name string, data []byte) ([][]byte, error) {
+	switch {
+	case strings.HasSuffix(name, ".xml"):
+		return [][]byte{data}, nil
+	case strings.HasSuffix(name, ".gz"):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		doc, err := readDMARCDocument(gz)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{doc}, nil
+	case strings.HasSuffix(name, ".zip"):
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		var docs [][]byte
+		for _, file := range archive.File {
+			if !strings.HasSuffix(strings.ToLower(file.Name), ".xml") {
+				continue
+			}
+			rc, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			doc, err := readDMARCDocument(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+		return docs, nil
+	}
+	return nil, nil
+}
+
+// readDMARCDocument reads a decompressed report of at most
+// maxDMARCReportSize bytes.
+func readDMARCDocument(r io.Reader) ([]byte, error) {
+	doc, err := io.ReadAll(io.LimitReader(r, maxDMARCReportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(doc) > maxDMARCReportSize {
+		return nil, fmt.Errorf("decompressed report exceeds %d MiB", maxDMARCReportSize>>20)
+	}
+	return doc, nil
+}
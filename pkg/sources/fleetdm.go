@@ -0,0 +1,147 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// FleetDMConfig configures endpoint control coverage collection from a
+// FleetDM (osquery fleet manager) server. Each control is measured by a
+// Fleet policy that passes on hosts where the control is in place.
+type FleetDMConfig struct {
+	URL      string `yaml:"url"`
+	APIToken string `yaml:"api_token"`
+	// Policies maps control names (edr, disk_encryption, firewall) to the
+	// Fleet policy IDs that check them.
+	Policies map[string]uint `yaml:"policies"`
+	// Target is the coverage percentage considered on target (default 100).
+	Target float64 `yaml:"target"`
+}
+
+// fleetPageSize is the number of hosts requested per page.
+const fleetPageSize = 500
+
+// fleetControlNames are display names for well-known controls.
+var fleetControlNames = map[string]string{
+	"edr":             "EDR Installed",
+	"disk_encryption": "Disk Encryption Enabled",
+	"firewall":        "Firewall Enabled",
+}
+
+type fleetHost struct {
+	ID       uint   `json:"id"`
+	Platform string `json:"platform"`
+}
+
+// NewFleetDMSource creates a source reporting endpoint control coverage
+// per platform as prevention metrics.
+func NewFleetDMSource(cfg FleetDMConfig, client *http.Client) (server.Source, error) {
+	if cfg.URL == "" || cfg.APIToken == "" {
+		return server.Source{}, fmt.Errorf("fleetdm: url and api_token are required")
+	}
+	if len(cfg.Policies) == 0 {
+		return server.Source{}, fmt.Errorf("fleetdm: at least one policy is required")
+	}
+	if cfg.Target == 0 {
+		cfg.Target = 100
+	}
+	baseURL := strings.TrimRight(cfg.URL, "/")
+	header := http.Header{"Authorization": {"Bearer " + cfg.APIToken}}
+
+	listHosts := func(ctx context.Context, query url.Values) ([]fleetHost, error) {
+		var hosts []fleetHost
+		for page := 0; ; page++ {
+			query.Set("page", strconv.Itoa(page))
+			query.Set("per_page", strconv.Itoa(fleetPageSize))
+			var resp struct {
+				Hosts []fleetHost `json:"hosts"`
+			}
+			if err := getJSON(ctx, client, baseURL+"/api/v1/fleet/hosts?"+query.Encode(), header, &resp); err != nil {
+				return nil, err
+			}
+			hosts = append(hosts, resp.Hosts...)
+			if len(resp.Hosts) < fleetPageSize {
+				return hosts, nil
+			}
+		}
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		hosts, err := listHosts(ctx, url.Values{})
+		if err != nil {
+			return fmt.Errorf("fleetdm: list hosts: %w", err)
+		}
+		totals := countByPlatform(hosts)
+
+		controls := make([]string, 0, len(cfg.Policies))
+		for control := range cfg.Policies {
+			controls = append(controls, control)
+		}
+		sort.Strings(controls)
+
+		for _, control := range controls {
+			query := url.Values{
+				"policy_id":       {strconv.FormatUint(uint64(cfg.Policies[control]), 10)},
+				"policy_response": {"passing"},
+			}
+			passing, err := listHosts(ctx, query)
+			if err != nil {
+				return fmt.Errorf("fleetdm: policy %s: %w", control, err)
+			}
+			covered := countByPlatform(passing)
+
+			name := fleetControlNames[control]
+			if name == "" {
+				name = control
+			}
+			for _, platform := range sortedKeys(totals) {
+				value := percent(covered[platform], totals[platform])
+				collector.AddMetric(metrics.SecurityMetric{
+					ID:          "fleetdm-" + control + "-" + platform,
+					Name:        name + " (" + platform + ")",
+					Type:        metrics.TypePrevention,
+					Value:       value,
+					Unit:        "%",
+					Target:      cfg.Target,
+					Status:      targetStatus(value, cfg.Target),
+					Description: fmt.Sprintf("%d of %d %s hosts", covered[platform], totals[platform], platform),
+					Category:    "Endpoint",
+				})
+			}
+		}
+		return nil
+	}
+
+	return server.Source{Name: "fleetdm", Collect: collect}, nil
+}
+
+// countByPlatform counts hosts per platform.
+func countByPlatform(hosts []fleetHost) map[string]int {
+	counts := make(map[string]int)
+	for _, host := range hosts {
+		platform := host.Platform
+		if platform == "" {
+			platform = "unknown"
+		}
+		counts[platform]++
+	}
+	return counts
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,81 @@
+package sources
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFleetDMCoverage(t *testing.T) {
+	// Hosts by ID, and the hosts passing each policy
+	platforms := map[int]string{1: "darwin", 2: "darwin", 3: "darwin", 4: "darwin", 5: "windows", 6: "windows", 7: "ubuntu"}
+	passing := map[string][]int{
+		"1": {1, 2, 3, 5, 6, 7}, // EDR
+		"2": {1, 2, 5},          // disk encryption
+		"3": {},                 // firewall
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/fleet/hosts" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		ids := []int{1, 2, 3, 4, 5, 6, 7}
+		if policy := r.URL.Query().Get("policy_id"); policy != "" {
+			if r.URL.Query().Get("policy_response") != "passing" {
+				http.Error(w, "policy_response missing", http.StatusBadRequest)
+				return
+			}
+			ids = passing[policy]
+		}
+		hosts := make([]string, 0, len(ids))
+		for _, id := range ids {
+			hosts = append(hosts, fmt.Sprintf(`{"id": %d, "platform": %q}`, id, platforms[id]))
+		}
+		fmt.Fprintf(w, `{"hosts": [%s]}`, strings.Join(hosts, ","))
+	}))
+	defer upstream.Close()
+
+	source, err := NewFleetDMSource(FleetDMConfig{
+		URL:      upstream.URL + "/",
+		APIToken: "token",
+		Policies: map[string]uint{"edr": 1, "disk_encryption": 2, "firewall": 3},
+		Target:   95,
+	}, upstream.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := metricsByID(collectSource(t, source))
+
+	want := map[string]struct {
+		value       float64
+		description string
+	}{
+		"fleetdm-edr-darwin":              {75, "3 of 4 darwin hosts"},
+		"fleetdm-edr-windows":             {100, "2 of 2 windows hosts"},
+		"fleetdm-edr-ubuntu":              {100, "1 of 1 ubuntu hosts"},
+		"fleetdm-disk_encryption-darwin":  {50, "2 of 4 darwin hosts"},
+		"fleetdm-disk_encryption-windows": {50, "1 of 2 windows hosts"},
+		"fleetdm-disk_encryption-ubuntu":  {0, "0 of 1 ubuntu hosts"},
+		"fleetdm-firewall-darwin":         {0, "0 of 4 darwin hosts"},
+		"fleetdm-firewall-windows":        {0, "0 of 2 windows hosts"},
+		"fleetdm-firewall-ubuntu":         {0, "0 of 1 ubuntu hosts"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("collected %d metrics, want %d", len(got), len(want))
+	}
+	for id, w := range want {
+		metric, ok := got[id]
+		if !ok {
+			t.Errorf("%s not collected", id)
+			continue
+		}
+		if metric.Value != w.value || metric.Description != w.description || metric.Target != 95 || metric.Unit != "%" {
+			t.Errorf("%s = %v%% (%s), target %v; want %v%% (%s)", id, metric.Value, metric.Description, metric.Target, w.value, w.description)
+		}
+	}
+	if name := got["fleetdm-disk_encryption-darwin"].Name; name != "Disk Encryption Enabled (darwin)" {
+		t.Errorf("name %q", name)
+	}
+}
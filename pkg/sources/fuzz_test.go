@@ -0,0 +1,121 @@
+package sources
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// seedFile returns a seed corpus file from testdata.
+func seedFile(f *testing.F, name string) []byte {
+	f.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		f.Fatal(err)
+	}
+	return data
+}
+
+// seedLines returns the lines of a JSON lines seed corpus file.
+func seedLines(f *testing.F, name string) [][]byte {
+	f.Helper()
+	return bytes.Split(bytes.TrimSpace(seedFile(f, name)), []byte("\n"))
+}
+
+func FuzzParseRecords(f *testing.F) {
+	f.Add(seedFile(f, "badge-events.csv"), true)
+	f.Add(seedFile(f, "insider-alerts.json"), false)
+	f.Add([]byte(`{"a":1}`+"\n"+`{"a":null,"b":[1,2]}`), false)
+	f.Fuzz(func(t *testing.T, data []byte, isCSV bool) {
+		records, err := parseRecords(bytes.NewReader(data), isCSV)
+		if err != nil {
+			return
+		}
+		for _, record := range records {
+			for _, value := range record {
+				parseTimestamp(value)
+			}
+		}
+	})
+}
+
+// dmarcNames are the report file names tried for fuzzed DMARC input.
+var dmarcNames = []string{"report.xml", "report.xml.gz", "report.zip"}
+
+func FuzzDMARCDocuments(f *testing.F) {
+	f.Add(seedFile(f, "dmarc-aggregate.xml"), uint8(0))
+	f.Add(seedFile(f, "dmarc-aggregate.xml.gz"), uint8(1))
+	f.Add(seedFile(f, "dmarc-aggregate.zip"), uint8(2))
+	f.Fuzz(func(t *testing.T, data []byte, kind uint8) {
+		docs, err := dmarcDocuments(dmarcNames[int(kind)%len(dmarcNames)], data)
+		if err != nil {
+			return
+		}
+		for _, doc := range docs {
+			if len(doc) > maxDMARCReportSize {
+				t.Fatalf("document of %d bytes exceeds the size limit", len(doc))
+			}
+			var report dmarcFeedback
+			xml.Unmarshal(doc, &report)
+		}
+	})
+}
+
+func FuzzWAFEvent(f *testing.F) {
+	for _, line := range seedLines(f, "aws-waf.jsonl") {
+		f.Add(line)
+	}
+	for _, line := range seedLines(f, "cloudflare-firewall.jsonl") {
+		f.Add(line)
+	}
+	f.Fuzz(func(t *testing.T, line []byte) {
+		parseAWSWAFEvent(line)
+		parseCloudflareEvent(line)
+	})
+}
+
+func FuzzSemgrep(f *testing.F) {
+	f.Add(seedFile(f, "semgrep.json"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseSemgrep(data, make(map[string]bool))
+	})
+}
+
+func FuzzSonarQubeIssues(f *testing.F) {
+	f.Add(seedFile(f, "sonarqube-issues.json"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parseSonarQubeIssues(data, make(map[string]float64))
+	})
+}
+
+func FuzzCredentialReport(f *testing.F) {
+	f.Add(seedFile(f, "aws-iam-credential-report.csv"))
+	f.Add([]byte("user,password_enabled\nbob,true\n"))
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	f.Fuzz(func(t *testing.T, report []byte) {
+		credentials, err := parseCredentialReport(report, now)
+		if err != nil {
+			return
+		}
+		for _, c := range credentials {
+			if c.Account == "<root_account>" {
+				t.Fatalf("root account credential %+v", c)
+			}
+			if c.OwnerKnown {
+				t.Fatalf("owner of %s known without a lookup", c.Name)
+			}
+		}
+	})
+}
+
+func FuzzParseTimestamp(f *testing.F) {
+	for _, seed := range []string{"2026-10-01T08:02:11Z", "2026-10-01 18:45:00", "09/29/2026 23:10:00", "1790683200", "1790683200123", "1e308", "-9e18"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		parseTimestamp(value)
+	})
+}
@@ -0,0 +1,79 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/pkg/grc"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// GRC platform KPI keys.
+const (
+	KPI_GRCControlPassRate metrics.KPIKey = "grc_control_pass_rate"
+	KPI_GRCFailingControls metrics.KPIKey = "grc_failing_controls"
+)
+
+func grcDefinitions() []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_GRCControlPassRate, Name: "GRC Control Pass Rate", Unit: "%", Category: "Compliance", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+		{Key: KPI_GRCFailingControls, Name: "Failing GRC Controls", Unit: "controls", Category: "Compliance", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+	}
+}
+
+// NewGRCSource creates a source reporting the status of each monitored
+// Vanta or Drata control as a compliance metric, the share of monitored
+// controls passing and the number failing.
+func NewGRCSource(cfg grc.Config, client *http.Client) (server.Source, error) {
+	platforms, err := grc.New(cfg, client)
+	if err != nil {
+		return server.Source{}, err
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		var monitored, passing int
+		for _, platform := range platforms {
+			controls, err := platform.Controls(ctx)
+			if err != nil {
+				return fmt.Errorf("grc: %s: %w", platform.Name(), err)
+			}
+			for _, control := range controls {
+				if !control.Monitored {
+					continue
+				}
+				monitored++
+				value := 0.0
+				if control.Passing {
+					passing++
+					value = 100
+				}
+				code := control.Code
+				if code == "" {
+					code = control.ID
+				}
+				collector.AddMetric(metrics.SecurityMetric{
+					ID:          "grc-" + platform.Name() + "-" + strings.ToLower(code),
+					Name:        control.Name + " (" + code + ")",
+					Type:        metrics.TypeCompliance,
+					Value:       value,
+					Unit:        "%",
+					Target:      100,
+					Status:      targetStatus(value, 100),
+					Description: "Control status in " + platform.Name(),
+					Category:    "Compliance",
+					Provenance:  metrics.Provenance{Record: platform.Name() + " control " + control.ID},
+				})
+			}
+		}
+
+		registerDefinitions(collector, grcDefinitions())
+		collector.AddKPI(metrics.KPI{Key: KPI_GRCControlPassRate, Value: percent(passing, monitored), Target: 100})
+		collector.AddKPI(metrics.KPI{Key: KPI_GRCFailingControls, Value: float64(monitored - passing), Target: 0})
+		return nil
+	}
+
+	return server.Source{Name: "grc", Collect: collect}, nil
+}
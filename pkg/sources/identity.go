@@ -0,0 +1,349 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/oauth"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// IdentityConfig configures authentication anomaly metrics from Okta and
+// Azure AD (Entra ID) sign-in logs.
+type IdentityConfig struct {
+	Okta    *OktaConfig    `yaml:"okta"`
+	AzureAD *AzureADConfig `yaml:"azure_ad"`
+	// WindowHours limits sign-ins to the last N hours (default 24).
+	WindowHours int `yaml:"window_hours"`
+	// SpikeThreshold is the number of failed logins for one user within
+	// an hour that counts as a spike (default 10).
+	SpikeThreshold int `yaml:"spike_threshold"`
+	// MaxTravelSpeed is the fastest plausible travel speed in km/h between
+	// two successful sign-ins (default 900).
+	MaxTravelSpeed float64 `yaml:"max_travel_speed"`
+}
+
+// OktaConfig configures access to the Okta System Log API.
+type OktaConfig struct {
+	// Domain is the Okta org domain, e.g. example.okta.com.
+	Domain   string `yaml:"domain"`
+	APIToken string `yaml:"api_token"`
+}
+
+// AzureADConfig configures access to Microsoft Graph sign-in logs using
+// client credentials with AuditLog.Read.All.
+type AzureADConfig struct {
+	TenantID     string `yaml:"tenant_id"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+}
+
+// Microsoft endpoints, overridable for sovereign clouds and tests.
+var (
+	microsoftLoginURL = "https://login.microsoftonline.com"
+	microsoftGraphURL = "https://graph.microsoft.com/v1.0"
+)
+
+// signIn is a provider-neutral sign-in event.
+type signIn struct {
+	User    string
+	Time    time.Time
+	Success bool
+	// MFABypass marks an attempt that passed the first factor but failed
+	// or tried to skip the second.
+	MFABypass bool
+	HasGeo    bool
+	Lat, Lon  float64
+}
+
+// signInFetcher fetches sign-ins since a point in time.
+type signInFetcher func(ctx context.Context, since time.Time) ([]signIn, error)
+
+// NewIdentitySource creates a source reporting failed-login spikes,
+// impossible-travel events and MFA bypass attempts as detection metrics.
+func NewIdentitySource(cfg IdentityConfig, client *http.Client) (server.Source, error) {
+	var fetchers []signInFetcher
+	if cfg.Okta != nil {
+		if cfg.Okta.Domain == "" || cfg.Okta.APIToken == "" {
+			return server.Source{}, fmt.Errorf("identity: okta domain and api_token are required")
+		}
+		fetchers = append(fetchers, oktaSignIns(*cfg.Okta, client))
+	}
+	if cfg.AzureAD != nil {
+		if cfg.AzureAD.TenantID == "" || cfg.AzureAD.ClientID == "" || cfg.AzureAD.ClientSecret == "" {
+			return server.Source{}, fmt.Errorf("identity: azure_ad tenant_id, client_id and client_secret are required")
+		}
+		fetchers = append(fetchers, azureADSignIns(*cfg.AzureAD, client))
+	}
+	if len(fetchers) == 0 {
+		return server.Source{}, fmt.Errorf("identity: okta or azure_ad is required")
+	}
+	if cfg.WindowHours == 0 {
+		cfg.WindowHours = 24
+	}
+	if cfg.SpikeThreshold == 0 {
+		cfg.SpikeThreshold = 10
+	}
+	if cfg.MaxTravelSpeed == 0 {
+		cfg.MaxTravelSpeed = 900
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		since := time.Now().Add(-time.Duration(cfg.WindowHours) * time.Hour)
+
+		var events []signIn
+		for _, fetch := range fetchers {
+			fetched, err := fetch(ctx, since)
+			if err != nil {
+				return fmt.Errorf("identity: %w", err)
+			}
+			events = append(events, fetched...)
+		}
+
+		window := fmt.Sprintf("last %dh", cfg.WindowHours)
+		counts := []struct {
+			id, name, description string
+			value                 int
+		}{
+			{"identity-failed-login-spikes", "Failed Login Spikes", fmt.Sprintf("Users with %d+ failed logins within an hour, %s", cfg.SpikeThreshold, window), countFailedLoginSpikes(events, cfg.SpikeThreshold)},
+			{"identity-impossible-travel", "Impossible Travel Events", fmt.Sprintf("Consecutive sign-ins implying travel above %.0f km/h, %s", cfg.MaxTravelSpeed, window), countImpossibleTravel(events, cfg.MaxTravelSpeed)},
+			{"identity-mfa-bypass-attempts", "MFA Bypass Attempts", "Sign-ins that passed the first factor but failed or attempted to bypass MFA, " + window, countMFABypass(events)},
+		}
+		for _, c := range counts {
+			status := "ON_TARGET"
+			if c.value > 0 {
+				status = "BELOW_TARGET"
+			}
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          c.id,
+				Name:        c.name,
+				Type:        metrics.TypeDetection,
+				Value:       float64(c.value),
+				Unit:        "events",
+				Status:      status,
+				Description: c.description,
+				Category:    "Identity",
+			})
+		}
+		return nil
+	}
+
+	return server.Source{Name: "identity", Collect: collect}, nil
+}
+
+// oktaSignIns fetches authentication events from the Okta System Log.
+func oktaSignIns(cfg OktaConfig, client *http.Client) signInFetcher {
+	header := http.Header{"Authorization": {"SSWS " + cfg.APIToken}}
+	baseURL := cfg.Domain
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "https://" + baseURL
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	return func(ctx context.Context, since time.Time) ([]signIn, error) {
+		query := url.Values{
+			"since":  {since.UTC().Format(time.RFC3339)},
+			"until":  {time.Now().UTC().Format(time.RFC3339)},
+			"limit":  {"1000"},
+			"filter": {`eventType eq "user.session.start" or eventType eq "user.authentication.auth_via_mfa" or eventType eq "user.mfa.attempt_bypass"`},
+		}
+		next := baseURL + "/api/v1/logs?" + query.Encode()
+
+		var events []signIn
+		for next != "" {
+			var page []struct {
+				EventType string    `json:"eventType"`
+				Published time.Time `json:"published"`
+				Actor     struct {
+					AlternateID string `json:"alternateId"`
+				} `json:"actor"`
+				Outcome struct {
+					Result string `json:"result"`
+				} `json:"outcome"`
+				Client struct {
+					Geo struct {
+						Geolocation *struct {
+							Lat float64 `json:"lat"`
+							Lon float64 `json:"lon"`
+						} `json:"geolocation"`
+					} `json:"geographicalContext"`
+				} `json:"client"`
+			}
+			link, err := getJSONPage(ctx, client, next, header, &page)
+			if err != nil {
+				return nil, fmt.Errorf("okta: %w", err)
+			}
+			for _, e := range page {
+				event := signIn{
+					User:    e.Actor.AlternateID,
+					Time:    e.Published,
+					Success: e.Outcome.Result == "SUCCESS",
+				}
+				switch e.EventType {
+				case "user.mfa.attempt_bypass":
+					event.MFABypass = true
+					event.Success = false
+				case "user.authentication.auth_via_mfa":
+					if !event.Success {
+						event.MFABypass = true
+					} else {
+						continue
+					}
+				}
+				if geo := e.Client.Geo.Geolocation; geo != nil {
+					event.HasGeo, event.Lat, event.Lon = true, geo.Lat, geo.Lon
+				}
+				events = append(events, event)
+			}
+			// An empty page ends polling even if Okta returns a next link.
+			if len(page) == 0 {
+				break
+			}
+			next = link
+		}
+		return events, nil
+	}
+}
+
+// azureMFAFailedCode is the Entra ID error for a failed strong
+// authentication after a valid first factor.
+const azureMFAFailedCode = 500121
+
+// azureADSignIns fetches sign-ins from Microsoft Graph.
+func azureADSignIns(cfg AzureADConfig, client *http.Client) signInFetcher {
+	tokens := oauth.NewTokenSource(client, microsoftLoginURL+"/"+url.PathEscape(cfg.TenantID)+"/oauth2/v2.0/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {cfg.ClientID},
+		"client_secret": {cfg.ClientSecret},
+		"scope":         {"https://graph.microsoft.com/.default"},
+	})
+
+	return func(ctx context.Context, since time.Time) ([]signIn, error) {
+		token, err := tokens.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("azure_ad: %w", err)
+		}
+		header := http.Header{"Authorization": {"Bearer " + token}}
+
+		query := url.Values{
+			"$filter": {"createdDateTime ge " + since.UTC().Format(time.RFC3339)},
+			"$top":    {"999"},
+		}
+		next := microsoftGraphURL + "/auditLogs/signIns?" + query.Encode()
+
+		var events []signIn
+		for next != "" {
+			var page struct {
+				Value []struct {
+					UserPrincipalName string    `json:"userPrincipalName"`
+					CreatedDateTime   time.Time `json:"createdDateTime"`
+					Status            struct {
+						ErrorCode int `json:"errorCode"`
+					} `json:"status"`
+					Location struct {
+						GeoCoordinates *struct {
+							Latitude  *float64 `json:"latitude"`
+							Longitude *float64 `json:"longitude"`
+						} `json:"geoCoordinates"`
+					} `json:"location"`
+				} `json:"value"`
+				NextLink string `json:"@odata.nextLink"`
+			}
+			if err := getJSON(ctx, client, next, header, &page); err != nil {
+				return nil, fmt.Errorf("azure_ad: %w", err)
+			}
+			for _, e := range page.Value {
+				event := signIn{
+					User:      e.UserPrincipalName,
+					Time:      e.CreatedDateTime,
+					Success:   e.Status.ErrorCode == 0,
+					MFABypass: e.Status.ErrorCode == azureMFAFailedCode,
+				}
+				if geo := e.Location.GeoCoordinates; geo != nil && geo.Latitude != nil && geo.Longitude != nil {
+					event.HasGeo, event.Lat, event.Lon = true, *geo.Latitude, *geo.Longitude
+				}
+				events = append(events, event)
+			}
+			next = page.NextLink
+		}
+		return events, nil
+	}
+}
+
+// countFailedLoginSpikes counts user-hours with at least threshold failed
+// logins.
+func countFailedLoginSpikes(events []signIn, threshold int) int {
+	failures := make(map[string]int)
+	for _, e := range events {
+		if e.Success || e.MFABypass {
+			continue
+		}
+		failures[e.User+"|"+e.Time.UTC().Truncate(time.Hour).Format(time.RFC3339)]++
+	}
+
+	spikes := 0
+	for _, n := range failures {
+		if n >= threshold {
+			spikes++
+		}
+	}
+	return spikes
+}
+
+// countImpossibleTravel counts consecutive successful sign-ins by the same
+// user whose implied travel speed exceeds maxSpeed km/h.
+func countImpossibleTravel(events []signIn, maxSpeed float64) int {
+	byUser := make(map[string][]signIn)
+	for _, e := range events {
+		if e.Success && e.HasGeo {
+			byUser[e.User] = append(byUser[e.User], e)
+		}
+	}
+
+	count := 0
+	for _, userEvents := range byUser {
+		sort.Slice(userEvents, func(i, j int) bool { return userEvents[i].Time.Before(userEvents[j].Time) })
+		for i := 1; i < len(userEvents); i++ {
+			prev, cur := userEvents[i-1], userEvents[i]
+			distance := haversineKm(prev.Lat, prev.Lon, cur.Lat, cur.Lon)
+			// Ignore nearby sign-ins, which geolocation cannot resolve.
+			if distance < 100 {
+				continue
+			}
+			hours := cur.Time.Sub(prev.Time).Hours()
+			if hours <= 0 || distance/hours > maxSpeed {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// countMFABypass counts MFA bypass attempts.
+func countMFABypass(events []signIn) int {
+	count := 0
+	for _, e := range events {
+		if e.MFABypass {
+			count++
+		}
+	}
+	return count
+}
+
+// haversineKm returns the great-circle distance between two points in km.
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,100 @@
+package sources
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// identityCounts returns the values of the identity metrics.
+func identityCounts(t *testing.T, cfg IdentityConfig, client *http.Client) [3]float64 {
+	t.Helper()
+	source, err := NewIdentitySource(cfg, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := metricsByID(collectSource(t, source))
+	return [3]float64{
+		byID["identity-failed-login-spikes"].Value,
+		byID["identity-impossible-travel"].Value,
+		byID["identity-mfa-bypass-attempts"].Value,
+	}
+}
+
+func TestIdentityOktaAnomalies(t *testing.T) {
+	first, second := fixture(t, "okta-system-log.json"), fixture(t, "okta-system-log-2.json")
+	var pages int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/logs" || r.Header.Get("Authorization") != "SSWS token" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		pages++
+		// System Log pages link to the next page, and polling ends at an
+		// empty page
+		switch r.URL.Query().Get("after") {
+		case "":
+			w.Header().Set("Link", `<http://`+r.Host+`/api/v1/logs?after=2>; rel="next"`)
+			w.Write(first)
+		case "2":
+			w.Header().Set("Link", `<http://`+r.Host+`/api/v1/logs?after=3>; rel="next"`)
+			w.Write(second)
+		default:
+			w.Header().Set("Link", `<http://`+r.Host+`/api/v1/logs?after=4>; rel="next"`)
+			w.Write([]byte("[]"))
+		}
+	}))
+	defer upstream.Close()
+
+	got := identityCounts(t, IdentityConfig{Okta: &OktaConfig{Domain: upstream.URL, APIToken: "token"}, SpikeThreshold: 3}, upstream.Client())
+	// alice's three failures within 09:00; bob's straddle two hours.
+	// carol flies New York to London in an hour; dave drives to Boston.
+	// erin attempts a bypass and frank fails MFA; grace passes it.
+	if want := [3]float64{1, 1, 2}; got != want {
+		t.Errorf("spikes, travel, MFA bypass = %v, want %v", got, want)
+	}
+	if pages != 3 {
+		t.Errorf("fetched %d pages, want 3", pages)
+	}
+}
+
+func TestIdentityAzureADAnomalies(t *testing.T) {
+	first, second := fixture(t, "azure-ad-signins.json"), fixture(t, "azure-ad-signins-2.json")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tenant/oauth2/v2.0/token":
+			if r.FormValue("client_secret") != "secret" {
+				http.Error(w, "bad credentials", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token": "graph-token", "expires_in": 3600}`))
+		case r.Header.Get("Authorization") != "Bearer graph-token":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		case r.URL.Path == "/auditLogs/signIns" && r.URL.Query().Get("page") == "2":
+			w.Write(second)
+		case r.URL.Path == "/auditLogs/signIns":
+			if !strings.HasPrefix(r.URL.Query().Get("$filter"), "createdDateTime ge ") {
+				http.Error(w, "filter missing", http.StatusBadRequest)
+				return
+			}
+			next := "http://" + r.Host + "/auditLogs/signIns?page=2"
+			w.Write(bytes.Replace(first, []byte("NEXT"), []byte(next), 1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+	defer func(login, graph string) { microsoftLoginURL, microsoftGraphURL = login, graph }(microsoftLoginURL, microsoftGraphURL)
+	microsoftLoginURL, microsoftGraphURL = upstream.URL, upstream.URL
+
+	cfg := IdentityConfig{AzureAD: &AzureADConfig{TenantID: "tenant", ClientID: "client", ClientSecret: "secret"}, SpikeThreshold: 3}
+	got := identityCounts(t, cfg, upstream.Client())
+	// henry fails three times within 14:00; ivan's MFA failures are not
+	// failed logins. judy signs in in Sydney and two hours later in
+	// Paris; her sign-in without coordinates is ignored.
+	if want := [3]float64{1, 1, 3}; got != want {
+		t.Errorf("spikes, travel, MFA bypass = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,333 @@
+package sources
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// Insider risk KPI keys.
+const (
+	KPI_InsiderRiskAlerts        metrics.KPIKey = "insider_risk_alerts"
+	KPI_InsiderRiskClosureTime   metrics.KPIKey = "insider_risk_closure_time"
+	KPI_InsiderRiskConfirmedRate metrics.KPIKey = "insider_risk_confirmed_rate"
+)
+
+// InsiderRiskConfig configures insider-risk KPIs from DLP and UEBA alert
+// exports (.csv, .json or .jsonl files) in a directory.
+type InsiderRiskConfig struct {
+	ExportsDir string `yaml:"exports_dir"`
+	// Fields maps alert attributes to export column names.
+	Fields InsiderRiskFields `yaml:"fields"`
+	// ConfirmedValues are dispositions marking a confirmed incident
+	// (default "confirmed", "true_positive").
+	ConfirmedValues []string `yaml:"confirmed_values"`
+	// WindowDays limits alerts to those created in the last N days (default 30).
+	WindowDays int `yaml:"window_days"`
+	// AlertTarget is the acceptable alert volume for the window (default 0).
+	AlertTarget float64 `yaml:"alert_target"`
+	// ClosureTimeTarget is the investigation closure target in hours (default 72).
+	ClosureTimeTarget float64 `yaml:"closure_time_target"`
+	// ConfirmedRateTarget is the expected confirmed-incident rate in percent
+	// (default 10).
+	ConfirmedRateTarget float64 `yaml:"confirmed_rate_target"`
+}
+
+// InsiderRiskFields names the export columns holding each alert attribute.
+type InsiderRiskFields struct {
+	ID          string `yaml:"id"`
+	Created     string `yaml:"created"`
+	Closed      string `yaml:"closed"`
+	Disposition string `yaml:"disposition"`
+	Severity    string `yaml:"severity"`
+}
+
+// insiderAlert is one DLP or UEBA alert.
+type insiderAlert struct {
+	ID          string
+	Created     time.Time
+	Closed      time.Time
+	Disposition string
+	Severity    string
+}
+
+func insiderRiskDefinitions() []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_InsiderRiskAlerts, Name: "Insider Risk Alerts", Unit: "alerts", Category: "Insider Risk", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_InsiderRiskClosureTime, Name: "Insider Risk Investigation Closure Time", Unit: "hours", Category: "Insider Risk", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_InsiderRiskConfirmedRate, Name: "Insider Risk Confirmed-Incident Rate", Unit: "%", Category: "Insider Risk", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+	}
+}
+
+// NewInsiderRiskSource creates a source tracking insider-risk alert volume,
+// investigation closure time and confirmed-incident rate. Its metrics use
+// the insider_risk type so they are reported apart from external threats.
+func NewInsiderRiskSource(cfg InsiderRiskConfig) (server.Source, error) {
+	if cfg.ExportsDir == "" {
+		return server.Source{}, fmt.Errorf("insider_risk: exports_dir is required")
+	}
+	defaults := InsiderRiskFields{ID: "id", Created: "created_at", Closed: "closed_at", Disposition: "disposition", Severity: "severity"}
+	if cfg.Fields.ID == "" {
+		cfg.Fields.ID = defaults.ID
+	}
+	if cfg.Fields.Created == "" {
+		cfg.Fields.Created = defaults.Created
+	}
+	if cfg.Fields.Closed == "" {
+		cfg.Fields.Closed = defaults.Closed
+	}
+	if cfg.Fields.Disposition == "" {
+		cfg.Fields.Disposition = defaults.Disposition
+	}
+	if cfg.Fields.Severity == "" {
+		cfg.Fields.Severity = defaults.Severity
+	}
+	if len(cfg.ConfirmedValues) == 0 {
+		cfg.ConfirmedValues = []string{"confirmed", "true_positive"}
+	}
+	if cfg.WindowDays == 0 {
+		cfg.WindowDays = 30
+	}
+	if cfg.ClosureTimeTarget == 0 {
+		cfg.ClosureTimeTarget = 72
+	}
+	if cfg.ConfirmedRateTarget == 0 {
+		cfg.ConfirmedRateTarget = 10
+	}
+	confirmed := make(map[string]bool)
+	for _, value := range cfg.ConfirmedValues {
+		confirmed[strings.ToLower(value)] = true
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		alerts, err := readInsiderAlerts(cfg.ExportsDir, cfg.Fields)
+		if err != nil {
+			return fmt.Errorf("insider_risk: %w", err)
+		}
+
+		since := time.Now().AddDate(0, 0, -cfg.WindowDays)
+		var total, closed, confirmedCount int
+		var closureTimes []float64
+		bySeverity := make(map[string]int)
+		for _, alert := range alerts {
+			if alert.Created.Before(since) {
+				continue
+			}
+			total++
+			severity := strings.ToLower(alert.Severity)
+			if severity == "" {
+				severity = "unknown"
+			}
+			bySeverity[severity]++
+			if alert.Closed.IsZero() {
+				continue
+			}
+			closed++
+			closureTimes = append(closureTimes, alert.Closed.Sub(alert.Created).Hours())
+			if confirmed[strings.ToLower(alert.Disposition)] {
+				confirmedCount++
+			}
+		}
+
+		window := fmt.Sprintf("last %d days", cfg.WindowDays)
+		severities := make([]string, 0, len(bySeverity))
+		for severity := range bySeverity {
+			severities = append(severities, severity)
+		}
+		sort.Strings(severities)
+		for _, severity := range severities {
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "insider-risk-alerts-" + severity,
+				Name:        "Insider Risk Alerts (" + severity + ")",
+				Type:        metrics.TypeInsiderRisk,
+				Value:       float64(bySeverity[severity]),
+				Unit:        "alerts",
+				Description: "DLP/UEBA alerts, " + window,
+				Category:    "Insider Risk",
+			})
+		}
+		collector.AddMetric(metrics.SecurityMetric{
+			ID:          "insider-risk-open",
+			Name:        "Insider Risk Investigations Open",
+			Type:        metrics.TypeInsiderRisk,
+			Value:       float64(total - closed),
+			Unit:        "alerts",
+			Description: "Alerts created in the " + window + " not yet closed",
+			Category:    "Insider Risk",
+		})
+
+		registerDefinitions(collector, insiderRiskDefinitions())
+		collector.AddKPI(metrics.KPI{Key: KPI_InsiderRiskAlerts, Value: float64(total), Target: cfg.AlertTarget})
+		collector.AddDurationKPI(KPI_InsiderRiskClosureTime, closureTimes, cfg.ClosureTimeTarget)
+		collector.AddKPI(metrics.KPI{Key: KPI_InsiderRiskConfirmedRate, Value: percent(confirmedCount, closed), Target: cfg.ConfirmedRateTarget})
+		return nil
+	}
+
+	return server.Source{Name: "insider_risk", Collect: collect}, nil
+}
+
+// readInsiderAlerts reads every export in dir, de-duplicating alerts that
+// appear in more than one export by ID (the last export wins).
+func readInsiderAlerts(dir string, fields InsiderRiskFields) ([]insiderAlert, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]insiderAlert)
+	var order []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		records, err := readRecords(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		for i, record := range records {
+			alert := insiderAlert{
+				ID:          record[fields.ID],
+				Disposition: record[fields.Disposition],
+				Severity:    record[fields.Severity],
+			}
+			var ok bool
+			if alert.Created, ok = parseTimestamp(record[fields.Created]); !ok {
+				return nil, fmt.Errorf("%s: record %d: invalid %s %q", entry.Name(), i+1, fields.Created, record[fields.Created])
+			}
+			alert.Closed, _ = parseTimestamp(record[fields.Closed])
+			if alert.ID == "" {
+				alert.ID = entry.Name() + "#" + strconv.Itoa(i)
+			}
+			if _, seen := byID[alert.ID]; !seen {
+				order = append(order, alert.ID)
+			}
+			byID[alert.ID] = alert
+		}
+	}
+
+	alerts := make([]insiderAlert, 0, len(order))
+	for _, id := range order {
+		alerts = append(alerts, byID[id])
+	}
+	return alerts, nil
+}
+
+// isRecordFile reports whether readRecords can read path.
+func isRecordFile(path string) bool {
+	name := strings.ToLower(strings.TrimSuffix(path, ".gz"))
+	return strings.HasSuffix(name, ".csv") || strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".jsonl")
+}
+
+// readRecords reads a CSV file with a header row, a JSON array of objects
+// or JSON lines into string-valued records. Other files are skipped.
+func readRecords(path string) ([]map[string]string, error) {
+	if !isRecordFile(path) {
+		return nil, nil
+	}
+	rc, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return parseRecords(rc, strings.HasSuffix(strings.ToLower(strings.TrimSuffix(path, ".gz")), ".csv"))
+}
+
+// parseRecords decodes CSV with a header row (when isCSV is set), or a
+// JSON array of objects or JSON lines, into string-valued records.
+func parseRecords(r io.Reader, isCSV bool) ([]map[string]string, error) {
+	var records []map[string]string
+	if isCSV {
+		rows, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, nil
+		}
+		for _, row := range rows[1:] {
+			record := make(map[string]string, len(row))
+			for i, column := range rows[0] {
+				if i < len(row) {
+					record[strings.TrimSpace(column)] = row[i]
+				}
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	// A JSON array or a stream of objects (JSON lines) decode alike.
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		objects, ok := value.([]interface{})
+		if !ok {
+			objects = []interface{}{value}
+		}
+		for _, object := range objects {
+			fields, ok := object.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected JSON objects")
+			}
+			record := make(map[string]string, len(fields))
+			for key, v := range fields {
+				switch v := v.(type) {
+				case nil:
+				case string:
+					record[key] = v
+				default:
+					record[key] = fmt.Sprint(v)
+				}
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// timestampLayouts are the layouts accepted by parseTimestamp.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02",
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+}
+
+// parseTimestamp parses common export timestamp formats and Unix seconds
+// or milliseconds. Empty values are reported as not ok.
+func parseTimestamp(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		// Values beyond year 2286 in seconds are taken as milliseconds.
+		if n > 1e10 {
+			return time.UnixMilli(int64(n)), true
+		}
+		return time.Unix(int64(n), 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,68 @@
+package sources
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestInsiderRiskDLPAndUEBA(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	at := func(days int, hours time.Duration) string {
+		return now.AddDate(0, 0, -days).Add(hours * time.Hour).Format(time.RFC3339)
+	}
+	exports := map[string]string{
+		"dlp.csv": "id,created_at,closed_at,disposition,severity\n" +
+			"dlp-1," + at(2, 0) + "," + at(2, 24) + ",false_positive,High\n" +
+			"dlp-2," + at(5, 0) + "," + at(5, 48) + ",benign,medium\n" +
+			// Outside the 30 day window
+			"dlp-3," + at(40, 0) + "," + at(40, 1) + ",confirmed,high\n",
+		// The UEBA export is read after the DLP one and its update of
+		// dlp-2 wins
+		"ueba.jsonl": `{"id": "dlp-2", "created_at": "` + at(5, 0) + `", "closed_at": "` + at(5, 96) + `", "disposition": "TRUE_POSITIVE", "severity": "Medium"}` + "\n" +
+			`{"id": "ueba-1", "created_at": "` + at(1, 0) + `", "risk_score": 72.5}` + "\n",
+		"README.txt": "not an export",
+	}
+	source, err := NewInsiderRiskSource(InsiderRiskConfig{ExportsDir: writeExports(t, exports)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := collectSource(t, source)
+
+	kpis := kpiValues(collector)
+	if kpis[KPI_InsiderRiskAlerts] != 3 {
+		t.Errorf("alerts = %v, want 3", kpis[KPI_InsiderRiskAlerts])
+	}
+	if kpis[KPI_InsiderRiskClosureTime] != 60 {
+		t.Errorf("mean closure time = %v hours, want 60", kpis[KPI_InsiderRiskClosureTime])
+	}
+	if kpis[KPI_InsiderRiskConfirmedRate] != 50 {
+		t.Errorf("confirmed rate = %v%%, want 50%%", kpis[KPI_InsiderRiskConfirmedRate])
+	}
+
+	byID := metricsByID(collector)
+	for id, want := range map[string]float64{
+		"insider-risk-alerts-high":    1,
+		"insider-risk-alerts-medium":  1,
+		"insider-risk-alerts-unknown": 1,
+		"insider-risk-open":           1,
+	} {
+		if got := byID[id]; got.Value != want || got.Type != metrics.TypeInsiderRisk {
+			t.Errorf("%s = %v (%s), want %v insider risk", id, got.Value, got.Type, want)
+		}
+	}
+}
+
+func TestInsiderRiskRejectsInvalidCreationTimes(t *testing.T) {
+	dir := writeExports(t, map[string]string{"dlp.csv": "id,created_at\ndlp-1,yesterday\n"})
+	source, err := NewInsiderRiskSource(InsiderRiskConfig{ExportsDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Collect(context.Background(), metrics.NewMetricsCollector()); err == nil || !strings.Contains(err.Error(), `dlp.csv: record 1: invalid created_at "yesterday"`) {
+		t.Errorf("collect with an invalid creation time: %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// KPI_AssetCoverage is the share of inventory assets covered by every
+// control that applies to them, weighted by asset criticality.
+const KPI_AssetCoverage metrics.KPIKey = "asset_coverage"
+
+// ControlKPIPrefix prefixes the coverage KPI of each control, e.g.
+// control_coverage_edr for a control named "EDR".
+const ControlKPIPrefix = "control_coverage_"
+
+// DefaultCoverageTarget is the coverage target of controls in percent.
+const DefaultCoverageTarget = 95
+
+// InventoryConfig cross-references an asset inventory with the assets each
+// control covers, e.g. EDR agent or vulnerability scanner exports.
+type InventoryConfig struct {
+	// Assets is the inventory export (.csv, .json or .jsonl) with asset_id,
+	// name, type, owner and criticality columns.
+	Assets   string                  `yaml:"assets"`
+	Controls []ControlCoverageConfig `yaml:"controls"`
+	// Target is the coverage target of each control in percent (default
+	// DefaultCoverageTarget).
+	Target float64 `yaml:"target"`
+}
+
+// ControlCoverageConfig configures the coverage data of one control.
+type ControlCoverageConfig struct {
+	Name string `yaml:"name"`
+	// Coverage is an export (.csv, .json or .jsonl) with the asset_id of
+	// every covered asset.
+	Coverage string `yaml:"coverage"`
+	// AssetTypes limits the control to assets of these types, e.g.
+	// [server, workstation]; it applies to every asset by default.
+	AssetTypes []string `yaml:"asset_types"`
+}
+
+// Asset is an inventory entry. Criticality is as exported and maps to a
+// tier with metrics.ParseCriticality.
+type Asset struct {
+	ID          string
+	Name        string
+	Type        string
+	Owner       string
+	Criticality string
+}
+
+// weight returns the score weight of the asset's criticality tier.
+func (a Asset) weight() float64 {
+	return metrics.ParseCriticality(a.Criticality).Weight()
+}
+
+// ControlGaps is the coverage of one control over the assets it applies
+// to, with the uncovered assets most critical first. The weights sum the
+// criticality weights of the assets counted.
+type ControlGaps struct {
+	Control          string
+	Key              metrics.KPIKey
+	Applicable       int
+	Covered          int
+	ApplicableWeight float64
+	CoveredWeight    float64
+	Uncovered        []Asset
+}
+
+// Coverage returns the percentage of applicable assets covered, weighted
+// by asset criticality, and 100 when the control applies to no asset.
+func (g ControlGaps) Coverage() float64 {
+	if g.ApplicableWeight == 0 {
+		return 100
+	}
+	return metrics.CalculateWeightedCoverage(g.CoveredWeight, g.ApplicableWeight)
+}
+
+// controlKey returns the coverage KPI key of a control.
+func controlKey(name string) metrics.KPIKey {
+	return metrics.KPIKey(ControlKPIPrefix + slug(name))
+}
+
+// validateInventory checks that cfg names an inventory and distinct,
+// named controls with coverage data.
+func validateInventory(cfg InventoryConfig) error {
+	if cfg.Assets == "" || len(cfg.Controls) == 0 {
+		return fmt.Errorf("inventory: assets and at least one control are required")
+	}
+	if !isRecordFile(cfg.Assets) {
+		return fmt.Errorf("inventory: assets %s is not a .csv, .json or .jsonl file", cfg.Assets)
+	}
+	keys := make(map[metrics.KPIKey]string)
+	for _, control := range cfg.Controls {
+		if control.Name == "" || control.Coverage == "" {
+			return fmt.Errorf("inventory: each control needs a name and coverage")
+		}
+		if !isRecordFile(control.Coverage) {
+			return fmt.Errorf("inventory: %s coverage %s is not a .csv, .json or .jsonl file", control.Name, control.Coverage)
+		}
+		key := controlKey(control.Name)
+		if key == ControlKPIPrefix {
+			return fmt.Errorf("inventory: control name %q has no letters or digits", control.Name)
+		}
+		if previous, ok := keys[key]; ok {
+			return fmt.Errorf("inventory: controls %q and %q share the KPI key %s", previous, control.Name, key)
+		}
+		keys[key] = control.Name
+	}
+	return nil
+}
+
+// GapAnalysis is the coverage of the asset inventory by each control.
+type GapAnalysis struct {
+	Assets   []Asset
+	Controls []ControlGaps
+}
+
+// AnalyzeGaps reads the inventory and coverage exports of cfg and returns
+// the gaps of each control, in configured order.
+func AnalyzeGaps(cfg InventoryConfig) (*GapAnalysis, error) {
+	if err := validateInventory(cfg); err != nil {
+		return nil, err
+	}
+	assets, err := readInventory(cfg.Assets)
+	if err != nil {
+		return nil, err
+	}
+	controls, err := analyzeGaps(cfg, assets)
+	if err != nil {
+		return nil, err
+	}
+	return &GapAnalysis{Assets: assets, Controls: controls}, nil
+}
+
+// readInventory reads the assets of an inventory export, most critical
+// first and then by ID, so every control lists its gaps in priority order.
+func readInventory(path string) ([]Asset, error) {
+	records, err := readRecords(path)
+	if err != nil {
+		return nil, fmt.Errorf("inventory: %s: %w", path, err)
+	}
+	var assets []Asset
+	seen := make(map[string]bool)
+	for _, record := range records {
+		asset := Asset{ID: strings.TrimSpace(record["asset_id"]), Name: record["name"], Type: record["type"],
+			Owner: record["owner"], Criticality: record["criticality"]}
+		if asset.ID == "" || seen[asset.ID] {
+			continue
+		}
+		seen[asset.ID] = true
+		assets = append(assets, asset)
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("inventory: %s lists no assets with an asset_id", path)
+	}
+	sort.SliceStable(assets, func(i, j int) bool {
+		wi, wj := assets[i].weight(), assets[j].weight()
+		if wi != wj {
+			return wi > wj
+		}
+		return assets[i].ID < assets[j].ID
+	})
+	return assets, nil
+}
+
+// analyzeGaps matches assets against the coverage export of each control.
+func analyzeGaps(cfg InventoryConfig, assets []Asset) ([]ControlGaps, error) {
+	gaps := make([]ControlGaps, 0, len(cfg.Controls))
+	for _, control := range cfg.Controls {
+		records, err := readRecords(control.Coverage)
+		if err != nil {
+			return nil, fmt.Errorf("inventory: %s: %w", control.Coverage, err)
+		}
+		covered := make(map[string]bool, len(records))
+		for _, record := range records {
+			covered[strings.TrimSpace(record["asset_id"])] = true
+		}
+		applies := make(map[string]bool, len(control.AssetTypes))
+		for _, assetType := range control.AssetTypes {
+			applies[strings.ToLower(assetType)] = true
+		}
+
+		gap := ControlGaps{Control: control.Name, Key: controlKey(control.Name)}
+		for _, asset := range assets {
+			if len(applies) > 0 && !applies[strings.ToLower(asset.Type)] {
+				continue
+			}
+			gap.Applicable++
+			gap.ApplicableWeight += asset.weight()
+			if covered[asset.ID] {
+				gap.Covered++
+				gap.CoveredWeight += asset.weight()
+			} else {
+				gap.Uncovered = append(gap.Uncovered, asset)
+			}
+		}
+		gaps = append(gaps, gap)
+	}
+	return gaps, nil
+}
+
+// NewInventorySource creates a source reporting the coverage of each
+// control over the asset inventory, and the share of assets without gaps.
+func NewInventorySource(cfg InventoryConfig) (server.Source, error) {
+	if err := validateInventory(cfg); err != nil {
+		return server.Source{}, err
+	}
+	if cfg.Target == 0 {
+		cfg.Target = DefaultCoverageTarget
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		analysis, err := AnalyzeGaps(cfg)
+		if err != nil {
+			return err
+		}
+		registerDefinitions(collector, []metrics.KPIDefinition{
+			{Key: KPI_AssetCoverage, Name: "Asset Control Coverage", Unit: "%", Category: "Prevention", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+		})
+		exposed := make(map[string]bool)
+		for _, gap := range analysis.Controls {
+			registerDefinitions(collector, []metrics.KPIDefinition{{
+				Key: gap.Key, Name: gap.Control + " Coverage", Unit: "%", Category: "Prevention",
+				Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100),
+			}})
+			collector.AddKPI(metrics.KPI{Key: gap.Key, Value: gap.Coverage(), Target: cfg.Target})
+			for _, asset := range gap.Uncovered {
+				exposed[asset.ID] = true
+			}
+		}
+		var covered, total float64
+		for _, asset := range analysis.Assets {
+			total += asset.weight()
+			if !exposed[asset.ID] {
+				covered += asset.weight()
+			}
+		}
+		collector.AddKPI(metrics.KPI{Key: KPI_AssetCoverage, Value: metrics.CalculateWeightedCoverage(covered, total), Target: cfg.Target})
+		return nil
+	}
+
+	return server.Source{Name: "inventory", Collect: collect}, nil
+}
@@ -0,0 +1,176 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// Physical security KPI keys.
+const (
+	KPI_TailgatingIncidents  metrics.KPIKey = "tailgating_incidents"
+	KPI_BadgeAuditCompletion metrics.KPIKey = "badge_audit_completion"
+	KPI_CameraUptime         metrics.KPIKey = "camera_uptime"
+)
+
+// PhysicalConfig configures physical security metrics from badge system,
+// camera and visitor log exports (.csv, .json or .jsonl). Each file is
+// optional.
+type PhysicalConfig struct {
+	// BadgeEvents has "timestamp" and "event_type" columns.
+	BadgeEvents string `yaml:"badge_events"`
+	// TailgatingTypes are event types counted as tailgating incidents
+	// (default "tailgating", "anti_passback_violation", "door_held_open").
+	TailgatingTypes []string `yaml:"tailgating_types"`
+	// BadgeAudits has "badge_id" and "last_audited" columns.
+	BadgeAudits string `yaml:"badge_audits"`
+	// AuditPeriodDays is how recently a badge must have been audited (default 90).
+	AuditPeriodDays int `yaml:"audit_period_days"`
+	// Cameras has "camera_id" and "uptime_percent" columns.
+	Cameras string `yaml:"cameras"`
+	// UptimeTarget is the camera uptime target in percent (default 99.5).
+	UptimeTarget float64 `yaml:"uptime_target"`
+	// VisitorLog has "checked_in" and "checked_out" columns.
+	VisitorLog string `yaml:"visitor_log"`
+	// WindowDays limits badge events and visits to the last N days (default 30).
+	WindowDays int `yaml:"window_days"`
+}
+
+func physicalDefinitions() []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_TailgatingIncidents, Name: "Tailgating Incidents", Unit: "incidents", Category: "Physical", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_BadgeAuditCompletion, Name: "Badge Audit Completion", Unit: "%", Category: "Physical", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+		{Key: KPI_CameraUptime, Name: "Camera Uptime", Unit: "%", Category: "Physical", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+	}
+}
+
+// NewPhysicalSource creates a source reporting tailgating incidents, badge
+// audit completion, camera uptime and visitor log metrics.
+func NewPhysicalSource(cfg PhysicalConfig) (server.Source, error) {
+	if cfg.BadgeEvents == "" && cfg.BadgeAudits == "" && cfg.Cameras == "" && cfg.VisitorLog == "" {
+		return server.Source{}, fmt.Errorf("physical: at least one of badge_events, badge_audits, cameras or visitor_log is required")
+	}
+	if len(cfg.TailgatingTypes) == 0 {
+		cfg.TailgatingTypes = []string{"tailgating", "anti_passback_violation", "door_held_open"}
+	}
+	if cfg.AuditPeriodDays == 0 {
+		cfg.AuditPeriodDays = 90
+	}
+	if cfg.UptimeTarget == 0 {
+		cfg.UptimeTarget = 99.5
+	}
+	if cfg.WindowDays == 0 {
+		cfg.WindowDays = 30
+	}
+	tailgating := make(map[string]bool)
+	for _, t := range cfg.TailgatingTypes {
+		tailgating[strings.ToLower(t)] = true
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		now := time.Now()
+		since := now.AddDate(0, 0, -cfg.WindowDays)
+		registerDefinitions(collector, physicalDefinitions())
+
+		if cfg.BadgeEvents != "" {
+			records, err := readRecords(cfg.BadgeEvents)
+			if err != nil {
+				return fmt.Errorf("physical: badge_events: %w", err)
+			}
+			incidents := 0
+			for _, record := range records {
+				t, ok := parseTimestamp(record["timestamp"])
+				if ok && !t.Before(since) && tailgating[strings.ToLower(record["event_type"])] {
+					incidents++
+				}
+			}
+			collector.AddKPI(metrics.KPI{Key: KPI_TailgatingIncidents, Value: float64(incidents), Target: 0})
+		}
+
+		if cfg.BadgeAudits != "" {
+			records, err := readRecords(cfg.BadgeAudits)
+			if err != nil {
+				return fmt.Errorf("physical: badge_audits: %w", err)
+			}
+			auditedSince := now.AddDate(0, 0, -cfg.AuditPeriodDays)
+			audited := 0
+			for _, record := range records {
+				if t, ok := parseTimestamp(record["last_audited"]); ok && !t.Before(auditedSince) {
+					audited++
+				}
+			}
+			collector.AddKPI(metrics.KPI{Key: KPI_BadgeAuditCompletion, Value: percent(audited, len(records)), Target: 100})
+		}
+
+		if cfg.Cameras != "" {
+			records, err := readRecords(cfg.Cameras)
+			if err != nil {
+				return fmt.Errorf("physical: cameras: %w", err)
+			}
+			var total float64
+			for _, record := range records {
+				uptime, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(record["uptime_percent"]), "%"), 64)
+				if err != nil {
+					return fmt.Errorf("physical: cameras: %s: invalid uptime_percent %q", record["camera_id"], record["uptime_percent"])
+				}
+				total += uptime
+			}
+			mean := 0.0
+			if len(records) > 0 {
+				mean = total / float64(len(records))
+			}
+			collector.AddKPI(metrics.KPI{Key: KPI_CameraUptime, Value: mean, Target: cfg.UptimeTarget})
+		}
+
+		if cfg.VisitorLog != "" {
+			records, err := readRecords(cfg.VisitorLog)
+			if err != nil {
+				return fmt.Errorf("physical: visitor_log: %w", err)
+			}
+			var visits, notSignedOut int
+			for _, record := range records {
+				checkedIn, ok := parseTimestamp(record["checked_in"])
+				if !ok || checkedIn.Before(since) {
+					continue
+				}
+				visits++
+				// Visits still open after a day were not signed out.
+				if _, ok := parseTimestamp(record["checked_out"]); !ok && now.Sub(checkedIn) > 24*time.Hour {
+					notSignedOut++
+				}
+			}
+			window := fmt.Sprintf("last %d days", cfg.WindowDays)
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "physical-visitors",
+				Name:        "Visitors",
+				Type:        metrics.TypePrevention,
+				Value:       float64(visits),
+				Unit:        "visits",
+				Description: "Visitor log entries, " + window,
+				Category:    "Physical",
+			})
+			status := "ON_TARGET"
+			if notSignedOut > 0 {
+				status = "BELOW_TARGET"
+			}
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "physical-visitors-not-signed-out",
+				Name:        "Visitors Not Signed Out",
+				Type:        metrics.TypePrevention,
+				Value:       float64(notSignedOut),
+				Unit:        "visits",
+				Status:      status,
+				Description: "Visits without a check-out after 24 hours, " + window,
+				Category:    "Physical",
+			})
+		}
+		return nil
+	}
+
+	return server.Source{Name: "physical", Collect: collect}, nil
+}
@@ -0,0 +1,89 @@
+package sources
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestPhysicalBadgeCameraAndVisitorKPIs(t *testing.T) {
+	now := time.Now().UTC()
+	ago := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+	day := 24 * time.Hour
+	dir := writeExports(t, map[string]string{
+		"badges.csv": "timestamp,badge_id,door,event_type\n" +
+			ago(2*day) + ",B-1,HQ-Main,access_granted\n" +
+			ago(2*day) + ",,HQ-Main,tailgating\n" +
+			ago(3*day) + ",B-2,DC-Cage-3,DOOR_HELD_OPEN\n" +
+			// Outside the window, and without a time
+			ago(40*day) + ",,HQ-Main,tailgating\n" +
+			"unknown,,HQ-Main,tailgating\n",
+		"audits.csv": "badge_id,last_audited\n" +
+			"B-1," + ago(10*day) + "\n" +
+			"B-2," + ago(89*day) + "\n" +
+			"B-3," + ago(100*day) + "\n" +
+			"B-4,\n",
+		"cameras.json": `[{"camera_id": "lobby", "uptime_percent": "99.9%"}, {"camera_id": "dock", "uptime_percent": 98.1}, {"camera_id": "cage", "uptime_percent": 100}]`,
+		"visitors.csv": "visitor,checked_in,checked_out\n" +
+			// Still on site, and signed out
+			"a," + ago(2*time.Hour) + ",\n" +
+			"b," + ago(5*day) + "," + ago(5*day-3*time.Hour) + "\n" +
+			// Never signed out
+			"c," + ago(3*day) + ",\n" +
+			"d," + ago(40*day) + ",\n",
+	})
+
+	source, err := NewPhysicalSource(PhysicalConfig{
+		BadgeEvents: filepath.Join(dir, "badges.csv"),
+		BadgeAudits: filepath.Join(dir, "audits.csv"),
+		Cameras:     filepath.Join(dir, "cameras.json"),
+		VisitorLog:  filepath.Join(dir, "visitors.csv"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := collectSource(t, source)
+
+	kpis := kpiValues(collector)
+	if kpis[KPI_TailgatingIncidents] != 2 {
+		t.Errorf("tailgating incidents = %v, want 2", kpis[KPI_TailgatingIncidents])
+	}
+	if kpis[KPI_BadgeAuditCompletion] != 50 {
+		t.Errorf("badge audit completion = %v%%, want 50%%", kpis[KPI_BadgeAuditCompletion])
+	}
+	if uptime := kpis[KPI_CameraUptime]; math.Abs(uptime-99.3333) > 0.001 {
+		t.Errorf("camera uptime = %v%%, want 99.33%%", uptime)
+	}
+
+	byID := metricsByID(collector)
+	if visits := byID["physical-visitors"]; visits.Value != 3 {
+		t.Errorf("visits = %v, want 3", visits.Value)
+	}
+	if open := byID["physical-visitors-not-signed-out"]; open.Value != 1 || open.Status != "BELOW_TARGET" {
+		t.Errorf("visitors not signed out = %v, %s", open.Value, open.Status)
+	}
+}
+
+func TestPhysicalRejectsInvalidUptime(t *testing.T) {
+	dir := writeExports(t, map[string]string{"cameras.csv": "camera_id,uptime_percent\nlobby,99.9\ndock,offline\n"})
+	source, err := NewPhysicalSource(PhysicalConfig{Cameras: filepath.Join(dir, "cameras.csv")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := metrics.NewMetricsCollector()
+	if err := source.Collect(context.Background(), collector); err == nil || !strings.Contains(err.Error(), `dock: invalid uptime_percent "offline"`) {
+		t.Errorf("collect with an invalid uptime: %v", err)
+	}
+	if kpis := kpiValues(collector); len(kpis) != 0 {
+		t.Errorf("reported %v from an invalid export", kpis)
+	}
+
+	if _, err := NewPhysicalSource(PhysicalConfig{}); err == nil {
+		t.Error("created a source without exports")
+	}
+}
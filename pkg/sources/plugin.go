@@ -0,0 +1,57 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/plugin"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// NewPluginSource creates a source that runs an external plugin executable
+// and adds the metrics, KPIs, incidents and alerts it returns. A batch is
+// checked like one pushed to the ingest endpoint: one invalid metric or
+// KPI fails the collection before any of the batch is added.
+func NewPluginSource(cfg plugin.Config) (server.Source, error) {
+	p, err := plugin.New(cfg)
+	if err != nil {
+		return server.Source{}, err
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		var batch server.IngestBatch
+		if err := p.Call(ctx, plugin.Request{Action: plugin.ActionCollect}, &batch); err != nil {
+			return err
+		}
+		for _, metric := range batch.Metrics {
+			if err := collector.CheckMetric(metric); err != nil {
+				return fmt.Errorf("plugin %s: %w", p.Name(), err)
+			}
+		}
+		for _, kpi := range batch.KPIs {
+			if err := collector.CheckKPI(kpi); err != nil {
+				return fmt.Errorf("plugin %s: %w", p.Name(), err)
+			}
+		}
+		for _, metric := range batch.Metrics {
+			collector.AddMetric(metric)
+		}
+		for _, kpi := range batch.KPIs {
+			collector.AddKPI(kpi)
+		}
+		for _, incident := range batch.Incidents {
+			if err := collector.AddIncident(incident); err != nil {
+				return fmt.Errorf("plugin %s: %w", p.Name(), err)
+			}
+		}
+		for _, alert := range batch.Alerts {
+			if err := collector.AddAlert(alert); err != nil {
+				return fmt.Errorf("plugin %s: %w", p.Name(), err)
+			}
+		}
+		return nil
+	}
+
+	return server.Source{Name: "plugin:" + p.Name(), Collect: collect}, nil
+}
@@ -0,0 +1,72 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/plugin"
+)
+
+// TestPluginHelperProcess is the plugin run by the plugin source tests,
+// not a test. It answers every request with SECMETRICS_PLUGIN_OUTPUT.
+func TestPluginHelperProcess(t *testing.T) {
+	output, ok := os.LookupEnv("SECMETRICS_PLUGIN_OUTPUT")
+	if !ok {
+		return
+	}
+	io.Copy(io.Discard, os.Stdin)
+	fmt.Println(output)
+	os.Exit(0)
+}
+
+// collectPlugin runs a plugin that answers with output into collector.
+func collectPlugin(t *testing.T, collector *metrics.MetricsCollector, output string) error {
+	t.Helper()
+	source, err := NewPluginSource(plugin.Config{
+		Name:    "helper",
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestPluginHelperProcess"},
+		Env:     []string{"SECMETRICS_PLUGIN_OUTPUT=" + output},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return source.Collect(context.Background(), collector)
+}
+
+func TestPluginSourceRejectsInvalidBatches(t *testing.T) {
+	valid := `{"ID": "edr", "Name": "EDR coverage", "Value": 97, "Unit": "%"}`
+	tests := []struct {
+		name, output, want string
+	}{
+		// JSON has no NaN, so a plugin printing one sends an invalid response
+		{"NaN value", `{"metrics": [` + valid + `, {"Name": "Open findings", "Value": NaN}]}`, "invalid response"},
+		{"negative count", `{"metrics": [` + valid + `, {"Name": "Open findings", "Value": -3, "Unit": "findings"}]}`, "is negative"},
+		{"unnamed metric", `{"metrics": [` + valid + `, {"ID": "findings", "Value": 3}]}`, "requires a name"},
+		{"unknown unit", `{"kpis": [{"Key": "clicks", "Name": "Clicks", "Value": 3, "Unit": "clicks"}]}`, "unknown unit"},
+	}
+	for _, tc := range tests {
+		collector := metrics.NewMetricsCollector()
+		err := collectPlugin(t, collector, tc.output)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: collect error %v, want %q", tc.name, err, tc.want)
+		}
+		// The valid part of a rejected batch is not added either
+		if got := collector.GetMetrics(); len(got) != 0 {
+			t.Errorf("%s: added %+v", tc.name, got)
+		}
+	}
+
+	collector := metrics.NewMetricsCollector()
+	if err := collectPlugin(t, collector, `{"metrics": [`+valid+`]}`); err != nil {
+		t.Fatal(err)
+	}
+	if got := collector.GetMetrics(); len(got) != 1 || got[0].Value != 97 {
+		t.Errorf("valid batch added %+v", got)
+	}
+}
@@ -0,0 +1,83 @@
+package sources
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// PolicyConfig configures retries, the attempt timeout, the circuit
+// breaker and response caching of one source.
+type PolicyConfig struct {
+	// Retries is the number of retries after a failed attempt.
+	Retries int `yaml:"retries"`
+	// Backoff is the delay before the first retry, doubled before each
+	// further retry, e.g. "2s" (default 1s).
+	Backoff string `yaml:"backoff"`
+	// Timeout bounds each attempt, e.g. "30s" (default none).
+	Timeout string        `yaml:"timeout"`
+	Breaker BreakerConfig `yaml:"breaker"`
+	// CacheTTL caches the API responses of the source for this long,
+	// e.g. "15m", and revalidates them with conditional requests after;
+	// only sources fetching from HTTP APIs support it (default off).
+	CacheTTL string `yaml:"cache_ttl"`
+}
+
+// BreakerConfig configures a source's circuit breaker.
+type BreakerConfig struct {
+	// Failures is the number of consecutive failed runs that opens the
+	// breaker; 0 disables it.
+	Failures int `yaml:"failures"`
+	// Cooldown is how long the open breaker skips the source, e.g. "6h"
+	// (default 1h).
+	Cooldown string `yaml:"cooldown"`
+}
+
+// applyPolicies sets the configured policies on sources, failing for a
+// policy that names no configured source.
+func applyPolicies(sources []server.Source, policies map[string]PolicyConfig) error {
+	for name, cfg := range policies {
+		policy, err := cfg.policy()
+		if err != nil {
+			return fmt.Errorf("policies: %s: %w", name, err)
+		}
+		found := false
+		for i := range sources {
+			if sources[i].Name == name {
+				sources[i].Policy, found = policy, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("policies: %s: no source with this name is configured", name)
+		}
+	}
+	return nil
+}
+
+// policy validates cfg and converts it to a source policy.
+func (cfg PolicyConfig) policy() (server.SourcePolicy, error) {
+	if cfg.Retries < 0 || cfg.Breaker.Failures < 0 {
+		return server.SourcePolicy{}, fmt.Errorf("retries and breaker failures must not be negative")
+	}
+	policy := server.SourcePolicy{Retries: cfg.Retries, BreakerFailures: cfg.Breaker.Failures}
+	for _, d := range []struct {
+		name  string
+		value string
+		to    *time.Duration
+	}{
+		{"backoff", cfg.Backoff, &policy.Backoff},
+		{"timeout", cfg.Timeout, &policy.Timeout},
+		{"breaker cooldown", cfg.Breaker.Cooldown, &policy.BreakerCooldown},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration <= 0 {
+			return server.SourcePolicy{}, fmt.Errorf("%s: invalid duration %q", d.name, d.value)
+		}
+		*d.to = duration
+	}
+	return policy, nil
+}
@@ -0,0 +1,221 @@
+package sources
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// KPI_ToolAvailability is the share of probed security tools that are up.
+const KPI_ToolAvailability metrics.KPIKey = "tool_availability"
+
+// ToolKPIPrefix prefixes the availability KPI of each probed tool, e.g.
+// tool_up_splunk for a tool named "Splunk".
+const ToolKPIPrefix = "tool_up_"
+
+// ProbesConfig configures health probes of security tools such as the
+// SIEM, the EDR console or the vulnerability scanner.
+type ProbesConfig struct {
+	Tools []ProbeTarget `yaml:"tools"`
+	// Timeout bounds each probe, e.g. "5s" (default 10s).
+	Timeout string `yaml:"timeout"`
+}
+
+// ProbeTarget is a security tool probed over HTTP(S) or TCP.
+type ProbeTarget struct {
+	// Name names the tool, e.g. "CrowdStrike Falcon"; its KPI key is
+	// derived from it.
+	Name string `yaml:"name"`
+	// URL is probed with an HTTP request; Address (host:port) with a TCP
+	// connection. Set one of them.
+	URL     string `yaml:"url"`
+	Address string `yaml:"address"`
+	// Method is the HTTP method (default GET).
+	Method string `yaml:"method"`
+	// BearerToken, or Username and Password, authenticate HTTP probes.
+	BearerToken string            `yaml:"bearer_token"`
+	Username    string            `yaml:"username"`
+	Password    string            `yaml:"password"`
+	Headers     map[string]string `yaml:"headers"`
+	// ExpectStatus is the status of a healthy response; any 2xx or 3xx
+	// status is healthy by default.
+	ExpectStatus int `yaml:"expect_status"`
+	// InsecureSkipVerify accepts self-signed certificates, common on
+	// on-premises consoles.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+}
+
+// probeResult is the outcome of probing one tool.
+type probeResult struct {
+	up      bool
+	latency time.Duration
+	err     error
+}
+
+// toolKey returns the availability KPI key of a tool.
+func toolKey(name string) metrics.KPIKey {
+	return metrics.KPIKey(ToolKPIPrefix + slug(name))
+}
+
+// slug reduces a display name to lowercase letters, digits and
+// underscores for use in KPI keys.
+func slug(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+func probeDefinitions(tools []ProbeTarget) []metrics.KPIDefinition {
+	// Any tool down breaches the warning threshold of the overall KPI and
+	// the critical threshold of its own.
+	defs := []metrics.KPIDefinition{
+		{Key: KPI_ToolAvailability, Name: "Security Tool Availability", Unit: "%", Category: "Tooling", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100), WarningThreshold: metrics.Bound(100)},
+	}
+	for _, tool := range tools {
+		defs = append(defs, metrics.KPIDefinition{
+			Key: toolKey(tool.Name), Name: tool.Name + " Availability", Unit: "%", Category: "Tooling",
+			Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100), CriticalThreshold: metrics.Bound(100),
+		})
+	}
+	return defs
+}
+
+// NewProbesSource creates a source probing the availability of security
+// tools, so an outage of a control shows up as a KPI and alerts.
+func NewProbesSource(cfg ProbesConfig, client *http.Client) (server.Source, error) {
+	if len(cfg.Tools) == 0 {
+		return server.Source{}, fmt.Errorf("probes: at least one tool is required")
+	}
+	timeout := 10 * time.Second
+	if cfg.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return server.Source{}, fmt.Errorf("probes: timeout: %w", err)
+		}
+	}
+	keys := make(map[metrics.KPIKey]string)
+	for _, tool := range cfg.Tools {
+		if tool.Name == "" || (tool.URL == "") == (tool.Address == "") {
+			return server.Source{}, fmt.Errorf("probes: each tool needs a name and one of url or address")
+		}
+		key := toolKey(tool.Name)
+		if key == ToolKPIPrefix {
+			return server.Source{}, fmt.Errorf("probes: tool name %q has no letters or digits", tool.Name)
+		}
+		if previous, ok := keys[key]; ok {
+			return server.Source{}, fmt.Errorf("probes: tools %q and %q share the KPI key %s", previous, tool.Name, key)
+		}
+		keys[key] = tool.Name
+	}
+	// Tools skipping verification keep the proxy settings of client.
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	insecure := &http.Client{Timeout: client.Timeout, Transport: transport}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		registerDefinitions(collector, probeDefinitions(cfg.Tools))
+		var up int
+		for _, tool := range cfg.Tools {
+			probeClient := client
+			if tool.InsecureSkipVerify {
+				probeClient = insecure
+			}
+			result := probe(ctx, probeClient, tool, timeout)
+			availability := 0.0
+			status, description := "BELOW_TARGET", "Down"
+			if result.up {
+				up++
+				availability = 100
+				status, description = "ON_TARGET", "Up"
+			}
+			if result.err != nil {
+				description += ": " + result.err.Error()
+			}
+			collector.AddKPI(metrics.KPI{Key: toolKey(tool.Name), Value: availability, Target: 100})
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "probe-" + string(toolKey(tool.Name)),
+				Name:        "Probe Latency (" + tool.Name + ")",
+				Type:        metrics.TypeDetection,
+				Value:       float64(result.latency.Microseconds()) / 1000,
+				Unit:        "ms",
+				Status:      status,
+				Description: description,
+				Category:    "Tooling",
+			})
+		}
+		collector.AddKPI(metrics.KPI{Key: KPI_ToolAvailability, Value: percent(up, len(cfg.Tools)), Target: 100})
+		return nil
+	}
+
+	return server.Source{Name: "probes", Collect: collect}, nil
+}
+
+// probe checks whether a tool is up. A failed probe is a result, not an
+// error of the source.
+func probe(ctx context.Context, client *http.Client, tool ProbeTarget, timeout time.Duration) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+
+	if tool.Address != "" {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", tool.Address)
+		if err != nil {
+			return probeResult{latency: time.Since(start), err: err}
+		}
+		conn.Close()
+		return probeResult{up: true, latency: time.Since(start)}
+	}
+
+	method := tool.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, tool.URL, nil)
+	if err != nil {
+		return probeResult{err: err}
+	}
+	for name, value := range tool.Headers {
+		req.Header.Set(name, value)
+	}
+	switch {
+	case tool.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+tool.BearerToken)
+	case tool.Username != "":
+		req.SetBasicAuth(tool.Username, tool.Password)
+	}
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return probeResult{latency: latency, err: err}
+	}
+	resp.Body.Close()
+	healthy := resp.StatusCode >= 200 && resp.StatusCode < 400
+	if tool.ExpectStatus != 0 {
+		healthy = resp.StatusCode == tool.ExpectStatus
+	}
+	if !healthy {
+		return probeResult{latency: latency, err: fmt.Errorf("unexpected status %s", resp.Status)}
+	}
+	return probeResult{up: true, latency: latency}
+}
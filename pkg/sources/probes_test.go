@@ -0,0 +1,107 @@
+package sources
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestProbesAvailability(t *testing.T) {
+	// An on-premises console with a self-signed certificate
+	console := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	console.Config.ErrorLog = log.New(io.Discard, "", 0)
+	console.StartTLS()
+	defer console.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/down":
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		case r.Header.Get("Authorization") != "Bearer token":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer api.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	client := &http.Client{Transport: transport}
+	source, err := NewProbesSource(ProbesConfig{Timeout: "5s", Tools: []ProbeTarget{
+		{Name: "SIEM Console", URL: console.URL, InsecureSkipVerify: true},
+		// The same console without opting in must verify its certificate
+		{Name: "EDR Console", URL: console.URL},
+		{Name: "Scanner", URL: api.URL + "/health", Method: http.MethodHead, BearerToken: "token", ExpectStatus: http.StatusNoContent},
+		{Name: "Ticketing", URL: api.URL + "/down"},
+		{Name: "Vault", Address: listener.Addr().String()},
+	}}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := collectSource(t, source)
+
+	kpis := kpiValues(collector)
+	for key, want := range map[metrics.KPIKey]float64{
+		"tool_availability":    60,
+		"tool_up_siem_console": 100,
+		"tool_up_edr_console":  0,
+		"tool_up_scanner":      100,
+		"tool_up_ticketing":    0,
+		"tool_up_vault":        100,
+	} {
+		if got := kpis[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+
+	byID := metricsByID(collector)
+	if edr := byID["probe-tool_up_edr_console"]; edr.Status != "BELOW_TARGET" || !strings.Contains(edr.Description, "certificate") {
+		t.Errorf("EDR console probe: %s, %s", edr.Status, edr.Description)
+	}
+	if ticketing := byID["probe-tool_up_ticketing"]; !strings.Contains(ticketing.Description, "unexpected status 503") {
+		t.Errorf("ticketing probe: %s", ticketing.Description)
+	}
+
+	// Opting one tool in leaves the configured client verifying
+	if transport.TLSClientConfig != nil && transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("probes disabled verification on the shared client")
+	}
+	if _, err := client.Get(console.URL); err == nil {
+		t.Error("shared client accepted a self-signed certificate")
+	}
+}
+
+func TestProbesRejectsInvalidTools(t *testing.T) {
+	for _, tools := range [][]ProbeTarget{
+		nil,
+		{{Name: "SIEM"}},
+		{{Name: "SIEM", URL: "https://siem", Address: "siem:443"}},
+		{{Name: "---", URL: "https://siem"}},
+		{{Name: "EDR Console", URL: "https://a"}, {Name: "edr-console", URL: "https://b"}},
+	} {
+		if _, err := NewProbesSource(ProbesConfig{Tools: tools}, http.DefaultClient); err == nil {
+			t.Errorf("accepted tools %+v", tools)
+		}
+	}
+}
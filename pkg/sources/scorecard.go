@@ -0,0 +1,249 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// OpenSSF Scorecard KPI keys.
+const (
+	KPI_ScorecardScore      metrics.KPIKey = "scorecard_score"
+	KPI_ScorecardCoverage   metrics.KPIKey = "scorecard_coverage"
+	KPI_ScorecardReposBelow metrics.KPIKey = "scorecard_repos_below_target"
+)
+
+// ScorecardConfig configures secure-development KPIs from OpenSSF
+// Scorecard results.
+type ScorecardConfig struct {
+	// Org lists the repositories of a GitHub organization; archived
+	// repositories and forks are skipped.
+	Org string `yaml:"org"`
+	// Token authenticates the repository listing; optional for public
+	// organizations.
+	Token string `yaml:"token"`
+	// APIURL overrides the GitHub API endpoint for GitHub Enterprise Server.
+	APIURL string `yaml:"api_url"`
+	// Repos lists repositories as "owner/name", or "name" within Org, in
+	// addition to those of Org.
+	Repos []string `yaml:"repos"`
+	// ScorecardURL overrides https://api.securityscorecards.dev, e.g. for
+	// a self-hosted Scorecard API.
+	ScorecardURL string `yaml:"scorecard_url"`
+	// Platform is the host prefix of Scorecard project names (default
+	// github.com).
+	Platform string `yaml:"platform"`
+	// Target is the aggregate score, out of 10, considered on target
+	// (default 7).
+	Target float64 `yaml:"target"`
+}
+
+// scorecardResult is the Scorecard API result of one repository.
+type scorecardResult struct {
+	Date   string  `json:"date"`
+	Score  float64 `json:"score"`
+	Checks []struct {
+		Name  string  `json:"name"`
+		Score float64 `json:"score"`
+	} `json:"checks"`
+}
+
+func scorecardDefinitions() []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_ScorecardScore, Name: "OpenSSF Scorecard Score", Unit: "score", Category: "AppSec", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(10)},
+		{Key: KPI_ScorecardCoverage, Name: "OpenSSF Scorecard Coverage", Unit: "%", Category: "AppSec", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+		{Key: KPI_ScorecardReposBelow, Name: "Repositories Below Scorecard Target", Unit: "repos", Category: "AppSec", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+	}
+}
+
+// NewScorecardSource creates a source reporting the OpenSSF Scorecard
+// score of each repository, the organization-wide mean score and mean
+// score per check, the share of repositories with Scorecard results and the
+// number of repositories below target.
+func NewScorecardSource(cfg ScorecardConfig, client *http.Client) (server.Source, error) {
+	if cfg.Org == "" && len(cfg.Repos) == 0 {
+		return server.Source{}, fmt.Errorf("scorecard: org or repos is required")
+	}
+	for _, repo := range cfg.Repos {
+		if !strings.Contains(repo, "/") && cfg.Org == "" {
+			return server.Source{}, fmt.Errorf("scorecard: repo %q needs an owner or org", repo)
+		}
+	}
+	if cfg.ScorecardURL == "" {
+		cfg.ScorecardURL = "https://api.securityscorecards.dev"
+	}
+	cfg.ScorecardURL = strings.TrimRight(cfg.ScorecardURL, "/")
+	if cfg.Platform == "" {
+		cfg.Platform = "github.com"
+	}
+	if cfg.Target == 0 {
+		cfg.Target = 7
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		repos, err := scorecardRepos(ctx, cfg, client)
+		if err != nil {
+			return fmt.Errorf("scorecard: %w", err)
+		}
+
+		var scored, below int
+		var total float64
+		checkSums := make(map[string]float64)
+		checkCounts := make(map[string]int)
+		for _, repo := range repos {
+			resultURL := cfg.ScorecardURL + "/projects/" + cfg.Platform + "/" + repo
+			result, found, err := fetchScorecard(ctx, client, resultURL)
+			if err != nil {
+				return fmt.Errorf("scorecard: %s: %w", repo, err)
+			}
+			if !found {
+				continue
+			}
+			scored++
+			total += result.Score
+			if result.Score < cfg.Target {
+				below++
+			}
+			// Checks scored -1 were inconclusive and are left out.
+			for _, check := range result.Checks {
+				if check.Score < 0 {
+					continue
+				}
+				checkSums[check.Name] += check.Score
+				checkCounts[check.Name]++
+			}
+			at, err := time.Parse(time.RFC3339, result.Date)
+			if err != nil {
+				at, _ = time.Parse(time.DateOnly, result.Date)
+			}
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "scorecard-" + repo,
+				Name:        "Scorecard Score (" + repo + ")",
+				Type:        metrics.TypePrevention,
+				Value:       result.Score,
+				Unit:        "score",
+				Target:      cfg.Target,
+				Status:      targetStatus(result.Score, cfg.Target),
+				Timestamp:   at,
+				Description: fmt.Sprintf("%d checks", len(result.Checks)),
+				Category:    "AppSec",
+				Provenance:  metrics.Provenance{Record: resultURL},
+			})
+		}
+
+		for _, name := range sortedKeys(checkCounts) {
+			value := round1(checkSums[name] / float64(checkCounts[name]))
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "scorecard-check-" + strings.ToLower(name),
+				Name:        "Scorecard " + name,
+				Type:        metrics.TypePrevention,
+				Value:       value,
+				Unit:        "score",
+				Target:      cfg.Target,
+				Status:      targetStatus(value, cfg.Target),
+				Description: fmt.Sprintf("Mean over %d repositories", checkCounts[name]),
+				Category:    "AppSec",
+			})
+		}
+
+		mean := 0.0
+		if scored > 0 {
+			mean = round1(total / float64(scored))
+		}
+		registerDefinitions(collector, scorecardDefinitions())
+		collector.AddKPI(metrics.KPI{Key: KPI_ScorecardScore, Value: mean, Target: cfg.Target})
+		collector.AddKPI(metrics.KPI{Key: KPI_ScorecardCoverage, Value: percent(scored, len(repos)), Target: 100})
+		collector.AddKPI(metrics.KPI{Key: KPI_ScorecardReposBelow, Value: float64(below), Target: 0})
+		return nil
+	}
+
+	return server.Source{Name: "scorecard", Collect: collect}, nil
+}
+
+// scorecardRepos returns the configured repositories as "owner/name",
+// followed by the active repositories of the organization.
+func scorecardRepos(ctx context.Context, cfg ScorecardConfig, client *http.Client) ([]string, error) {
+	var repos []string
+	seen := make(map[string]bool)
+	add := func(repo string) {
+		if !seen[strings.ToLower(repo)] {
+			seen[strings.ToLower(repo)] = true
+			repos = append(repos, repo)
+		}
+	}
+	for _, repo := range cfg.Repos {
+		if !strings.Contains(repo, "/") {
+			repo = cfg.Org + "/" + repo
+		}
+		add(repo)
+	}
+	if cfg.Org == "" {
+		return repos, nil
+	}
+
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = "https://api.github.com"
+	}
+	header := http.Header{"X-Github-Api-Version": {"2022-11-28"}}
+	if cfg.Token != "" {
+		header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	next := strings.TrimRight(apiURL, "/") + "/orgs/" + url.PathEscape(cfg.Org) + "/repos?type=all&per_page=100"
+	for next != "" {
+		var page []struct {
+			FullName string `json:"full_name"`
+			Archived bool   `json:"archived"`
+			Fork     bool   `json:"fork"`
+		}
+		var err error
+		if next, err = getJSONPage(ctx, client, next, header, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page {
+			if !r.Archived && !r.Fork {
+				add(r.FullName)
+			}
+		}
+	}
+	return repos, nil
+}
+
+// fetchScorecard fetches the Scorecard result at url. Repositories Scorecard
+// has not scanned are not found rather than an error.
+func fetchScorecard(ctx context.Context, client *http.Client, url string) (scorecardResult, bool, error) {
+	var result scorecardResult
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return result, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return result, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return result, false, nil
+	}
+	if err := checkResponse(resp); err != nil {
+		return result, false, err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, false, err
+	}
+	return result, true, nil
+}
+
+// round1 rounds value to one decimal place.
+func round1(value float64) float64 {
+	return math.Round(value*10) / 10
+}
@@ -0,0 +1,80 @@
+package sources
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScorecardMeanCoverageAndBelowTarget(t *testing.T) {
+	github := pagedAPI(t, "Authorization", "Bearer token", map[string][]string{
+		"/orgs/acme/repos": {
+			// acme/API is already configured; forks and archived
+			// repositories are skipped
+			`[{"full_name": "acme/API"}, {"full_name": "acme/fork", "fork": true}, {"full_name": "acme/old", "archived": true}]`,
+			`[{"full_name": "acme/web"}]`,
+		},
+	})
+	results := map[string]string{
+		"/projects/github.com/acme/api":   `{"date": "2026-10-12", "score": 8.2, "checks": [{"name": "Code-Review", "score": 10}, {"name": "Fuzzing", "score": -1}]}`,
+		"/projects/github.com/acme/tools": `{"date": "2026-10-12T06:00:00Z", "score": 5.5, "checks": [{"name": "Code-Review", "score": 4}, {"name": "Fuzzing", "score": 0}]}`,
+		"/projects/github.com/acme/web":   `{"date": "2026-10-12", "score": 6.9, "checks": [{"name": "Code-Review", "score": 7}, {"name": "Fuzzing", "score": 6}]}`,
+	}
+	scorecard := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, ok := results[r.URL.Path]
+		if !ok {
+			// Not scanned by Scorecard
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(result))
+	}))
+	defer scorecard.Close()
+
+	source, err := NewScorecardSource(ScorecardConfig{
+		Org:          "acme",
+		Token:        "token",
+		APIURL:       github.URL,
+		Repos:        []string{"acme/api", "tools", "other/lib"},
+		ScorecardURL: scorecard.URL + "/",
+	}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := collectSource(t, source)
+
+	kpis := kpiValues(collector)
+	// (8.2 + 5.5 + 6.9) / 3 rounded to one decimal
+	if kpis[KPI_ScorecardScore] != 6.9 {
+		t.Errorf("mean score = %v, want 6.9", kpis[KPI_ScorecardScore])
+	}
+	// other/lib has no results
+	if kpis[KPI_ScorecardCoverage] != 75 {
+		t.Errorf("coverage = %v%%, want 75%%", kpis[KPI_ScorecardCoverage])
+	}
+	if kpis[KPI_ScorecardReposBelow] != 2 {
+		t.Errorf("repositories below target = %v, want 2", kpis[KPI_ScorecardReposBelow])
+	}
+
+	byID := metricsByID(collector)
+	for id, want := range map[string]struct {
+		value  float64
+		status string
+	}{
+		"scorecard-acme/api":          {8.2, "ON_TARGET"},
+		"scorecard-acme/tools":        {5.5, "BELOW_TARGET"},
+		"scorecard-check-code-review": {7, "ON_TARGET"},
+		// The inconclusive -1 is left out of the mean
+		"scorecard-check-fuzzing": {3, "BELOW_TARGET"},
+	} {
+		if got := byID[id]; got.Value != want.value || got.Status != want.status {
+			t.Errorf("%s = %v %s, want %v %s", id, got.Value, got.Status, want.value, want.status)
+		}
+	}
+	if _, ok := byID["scorecard-other/lib"]; ok {
+		t.Error("reported a score for a repository without results")
+	}
+	if checks := byID["scorecard-check-fuzzing"].Description; checks != "Mean over 2 repositories" {
+		t.Errorf("fuzzing check: %s", checks)
+	}
+}
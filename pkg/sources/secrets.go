@@ -0,0 +1,431 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/awsv4"
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// Secrets and key management KPI keys.
+const (
+	KPI_CredentialAge          metrics.KPIKey = "credential_age"
+	KPI_CredentialsOverdue     metrics.KPIKey = "credentials_overdue"
+	KPI_UnownedServiceAccounts metrics.KPIKey = "unowned_service_accounts"
+)
+
+// SecretsConfig configures credential rotation KPIs from HashiCorp Vault
+// and AWS IAM credential reports.
+type SecretsConfig struct {
+	Vault  *VaultConfig  `yaml:"vault"`
+	AWSIAM *AWSIAMConfig `yaml:"aws_iam"`
+	// MaxAgeDays is the rotation period; older credentials are overdue
+	// (default 90).
+	MaxAgeDays int `yaml:"max_age_days"`
+}
+
+// VaultConfig configures access to KV version 2 secret metadata. A secret's
+// owner is read from its custom metadata.
+type VaultConfig struct {
+	Address string `yaml:"address"`
+	Token   string `yaml:"token"`
+	// Mounts lists the KV v2 mount paths to scan, e.g. "secret".
+	Mounts []string `yaml:"mounts"`
+	// OwnerKey is the custom metadata key naming the owner (default "owner").
+	OwnerKey string `yaml:"owner_key"`
+}
+
+// AWSIAMConfig configures the AWS IAM credential report. The report is
+// read from ReportPath if set, otherwise fetched from IAM using
+// credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN. Service account owners are read from user tags,
+// which requires those credentials in either case.
+type AWSIAMConfig struct {
+	ReportPath string `yaml:"report_path"`
+	// OwnerTag is the IAM user tag naming the owner (default "owner").
+	OwnerTag string `yaml:"owner_tag"`
+	// Endpoint overrides the global IAM endpoint.
+	Endpoint string `yaml:"endpoint"`
+}
+
+// credential is a secret or key and its rotation state.
+type credential struct {
+	Name string
+	// Account identifies the user or service account holding the credential.
+	Account        string
+	Age            time.Duration
+	ServiceAccount bool
+	Owner          string
+	// OwnerKnown is false when ownership could not be determined.
+	OwnerKnown bool
+}
+
+func secretsDefinitions() []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_CredentialAge, Name: "Credential Age", Unit: "days", Category: "Secrets", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_CredentialsOverdue, Name: "Credentials Overdue for Rotation", Unit: "credentials", Category: "Secrets", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_UnownedServiceAccounts, Name: "Service Accounts Without Owners", Unit: "accounts", Category: "Secrets", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+	}
+}
+
+// credentialAgeBuckets are the age distribution buckets in days.
+var credentialAgeBuckets = []struct {
+	Name     string
+	Min, Max int
+}{
+	{"0-30 days", 0, 30},
+	{"31-90 days", 31, 90},
+	{"91-180 days", 91, 180},
+	{"181-365 days", 181, 365},
+	{"over 365 days", 366, -1},
+}
+
+// NewSecretsSource creates a source tracking credential age distribution,
+// credentials overdue for rotation and service accounts without owners.
+func NewSecretsSource(cfg SecretsConfig, client *http.Client) (server.Source, error) {
+	var fetchers []func(ctx context.Context, now time.Time) ([]credential, error)
+	if cfg.Vault != nil {
+		if cfg.Vault.Address == "" || cfg.Vault.Token == "" || len(cfg.Vault.Mounts) == 0 {
+			return server.Source{}, fmt.Errorf("secrets: vault address, token and mounts are required")
+		}
+		vault := *cfg.Vault
+		if vault.OwnerKey == "" {
+			vault.OwnerKey = "owner"
+		}
+		fetchers = append(fetchers, func(ctx context.Context, now time.Time) ([]credential, error) {
+			return vaultCredentials(ctx, client, vault, now)
+		})
+	}
+	if cfg.AWSIAM != nil {
+		iam := *cfg.AWSIAM
+		if iam.OwnerTag == "" {
+			iam.OwnerTag = "owner"
+		}
+		if iam.Endpoint == "" {
+			iam.Endpoint = "https://iam.amazonaws.com/"
+		}
+		fetchers = append(fetchers, func(ctx context.Context, now time.Time) ([]credential, error) {
+			return iamCredentials(ctx, client, iam, now)
+		})
+	}
+	if len(fetchers) == 0 {
+		return server.Source{}, fmt.Errorf("secrets: vault or aws_iam is required")
+	}
+	if cfg.MaxAgeDays == 0 {
+		cfg.MaxAgeDays = 90
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		now := time.Now()
+		var credentials []credential
+		for _, fetch := range fetchers {
+			fetched, err := fetch(ctx, now)
+			if err != nil {
+				return fmt.Errorf("secrets: %w", err)
+			}
+			credentials = append(credentials, fetched...)
+		}
+
+		maxAge := time.Duration(cfg.MaxAgeDays) * 24 * time.Hour
+		ages := make([]float64, 0, len(credentials))
+		buckets := make([]int, len(credentialAgeBuckets))
+		var overdue int
+		unowned := make(map[string]bool)
+		for _, c := range credentials {
+			days := c.Age.Hours() / 24
+			ages = append(ages, days)
+			for i, bucket := range credentialAgeBuckets {
+				if int(days) >= bucket.Min && (bucket.Max < 0 || int(days) <= bucket.Max) {
+					buckets[i]++
+					break
+				}
+			}
+			if c.Age > maxAge {
+				overdue++
+			}
+			if c.ServiceAccount && c.OwnerKnown && c.Owner == "" {
+				unowned[c.Account] = true
+			}
+		}
+
+		for i, bucket := range credentialAgeBuckets {
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "secrets-age-" + fmt.Sprint(i+1),
+				Name:        "Credentials Aged " + bucket.Name,
+				Type:        metrics.TypePrevention,
+				Value:       float64(buckets[i]),
+				Unit:        "credentials",
+				Description: "Credential age distribution",
+				Category:    "Secrets",
+			})
+		}
+
+		registerDefinitions(collector, secretsDefinitions())
+		collector.AddDurationKPI(KPI_CredentialAge, ages, float64(cfg.MaxAgeDays))
+		collector.AddKPI(metrics.KPI{Key: KPI_CredentialsOverdue, Value: float64(overdue), Target: 0})
+		collector.AddKPI(metrics.KPI{Key: KPI_UnownedServiceAccounts, Value: float64(len(unowned)), Target: 0})
+		return nil
+	}
+
+	return server.Source{Name: "secrets", Collect: collect}, nil
+}
+
+// vaultCredentials lists every secret in the configured KV v2 mounts.
+// Each secret is treated as a service credential aged from its last update.
+func vaultCredentials(ctx context.Context, client *http.Client, cfg VaultConfig, now time.Time) ([]credential, error) {
+	baseURL := strings.TrimRight(cfg.Address, "/") + "/v1/"
+	header := http.Header{"X-Vault-Token": {cfg.Token}}
+
+	var credentials []credential
+	var walk func(mount, dir string) error
+	walk = func(mount, dir string) error {
+		var list struct {
+			Data struct {
+				Keys []string `json:"keys"`
+			} `json:"data"`
+		}
+		if err := getJSON(ctx, client, baseURL+mount+"/metadata/"+dir+"?list=true", header, &list); err != nil {
+			return fmt.Errorf("vault: list %s/%s: %w", mount, dir, err)
+		}
+		for _, key := range list.Data.Keys {
+			if strings.HasSuffix(key, "/") {
+				if err := walk(mount, dir+key); err != nil {
+					return err
+				}
+				continue
+			}
+			var meta struct {
+				Data struct {
+					UpdatedTime    time.Time         `json:"updated_time"`
+					CustomMetadata map[string]string `json:"custom_metadata"`
+				} `json:"data"`
+			}
+			if err := getJSON(ctx, client, baseURL+mount+"/metadata/"+dir+url.PathEscape(key), header, &meta); err != nil {
+				return fmt.Errorf("vault: metadata %s/%s%s: %w", mount, dir, key, err)
+			}
+			credentials = append(credentials, credential{
+				Name:           mount + "/" + dir + key,
+				Account:        mount + "/" + dir + key,
+				Age:            now.Sub(meta.Data.UpdatedTime),
+				ServiceAccount: true,
+				Owner:          meta.Data.CustomMetadata[cfg.OwnerKey],
+				OwnerKnown:     true,
+			})
+		}
+		return nil
+	}
+
+	for _, mount := range cfg.Mounts {
+		if err := walk(strings.Trim(mount, "/"), ""); err != nil {
+			return nil, err
+		}
+	}
+	return credentials, nil
+}
+
+// iamCredentials reads the AWS IAM credential report. Programmatic-only
+// users with an active access key are treated as service accounts.
+func iamCredentials(ctx context.Context, client *http.Client, cfg AWSIAMConfig, now time.Time) ([]credential, error) {
+	iam := &iamClient{client: client, endpoint: cfg.Endpoint}
+
+	var report []byte
+	var err error
+	if cfg.ReportPath != "" {
+		// Owner tags are still looked up when credentials are available.
+		if creds, credsErr := awsEnvCredentials(); credsErr == nil {
+			iam.creds = creds
+		}
+		report, err = os.ReadFile(cfg.ReportPath)
+	} else {
+		if iam.creds, err = awsEnvCredentials(); err != nil {
+			return nil, err
+		}
+		report, err = iam.credentialReport(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("aws_iam: %w", err)
+	}
+
+	credentials, err := parseCredentialReport(report, now)
+	if err != nil {
+		return nil, fmt.Errorf("aws_iam: %w", err)
+	}
+
+	// Only look up owners of service accounts holding active keys.
+	if iam.creds.AccessKeyID == "" {
+		return credentials, nil
+	}
+	owners := make(map[string]string)
+	for i := range credentials {
+		user := credentials[i].Account
+		if !credentials[i].ServiceAccount {
+			continue
+		}
+		owner, ok := owners[user]
+		if !ok {
+			if owner, err = iam.userTag(ctx, user, cfg.OwnerTag); err != nil {
+				return nil, fmt.Errorf("aws_iam: %s: %w", user, err)
+			}
+			owners[user] = owner
+		}
+		credentials[i].Owner, credentials[i].OwnerKnown = owner, true
+	}
+	return credentials, nil
+}
+
+// parseCredentialReport returns the passwords and active access keys in an
+// IAM credential report, aged at now, without their owners.
+func parseCredentialReport(report []byte, now time.Time) ([]credential, error) {
+	rows, err := csv.NewReader(bytes.NewReader(report)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse credential report: %w", err)
+	}
+	if len(rows) < 1 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[name] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var credentials []credential
+	for _, row := range rows[1:] {
+		user := field(row, "user")
+		if user == "<root_account>" {
+			continue
+		}
+		serviceAccount := field(row, "password_enabled") != "true"
+
+		if field(row, "password_enabled") == "true" {
+			if changed, ok := parseIAMTime(field(row, "password_last_changed")); ok {
+				credentials = append(credentials, credential{Name: user + " (password)", Account: user, Age: now.Sub(changed)})
+			}
+		}
+		for _, n := range []string{"1", "2"} {
+			if field(row, "access_key_"+n+"_active") != "true" {
+				continue
+			}
+			if rotated, ok := parseIAMTime(field(row, "access_key_"+n+"_last_rotated")); ok {
+				credentials = append(credentials, credential{Name: user + " (access key " + n + ")", Account: user, Age: now.Sub(rotated), ServiceAccount: serviceAccount})
+			}
+		}
+	}
+	return credentials, nil
+}
+
+// parseIAMTime parses a credential report timestamp, which is "N/A" or
+// similar when not applicable.
+func parseIAMTime(value string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, value)
+	return t, err == nil
+}
+
+// awsEnvCredentials reads AWS credentials from the environment.
+func awsEnvCredentials() (awsv4.Credentials, error) {
+	creds := awsv4.Credentials{
+		AccessKeyID:     secretenv.Get("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: secretenv.Get("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    secretenv.Get("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("aws_iam: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return creds, nil
+}
+
+// iamClient calls the AWS IAM query API.
+type iamClient struct {
+	client   *http.Client
+	endpoint string
+	creds    awsv4.Credentials
+}
+
+// call performs an IAM action and decodes the XML response into v.
+func (c *iamClient) call(ctx context.Context, params url.Values, v interface{}) error {
+	params.Set("Version", "2010-05-08")
+	body := []byte(params.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	awsv4.Sign(req, body, c.creds, "us-east-1", "iam", time.Now())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("%s: %w", params.Get("Action"), err)
+	}
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+// credentialReport generates the credential report and returns its CSV.
+func (c *iamClient) credentialReport(ctx context.Context) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		var generated struct {
+			State string `xml:"GenerateCredentialReportResult>State"`
+		}
+		if err := c.call(ctx, url.Values{"Action": {"GenerateCredentialReport"}}, &generated); err != nil {
+			return nil, err
+		}
+		if generated.State == "COMPLETE" {
+			break
+		}
+		if attempt == 10 {
+			return nil, fmt.Errorf("credential report not ready (state %s)", generated.State)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+
+	var result struct {
+		Content string `xml:"GetCredentialReportResult>Content"`
+	}
+	if err := c.call(ctx, url.Values{"Action": {"GetCredentialReport"}}, &result); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(result.Content))
+}
+
+// userTag returns the value of an IAM user tag, or "" if unset.
+func (c *iamClient) userTag(ctx context.Context, user, key string) (string, error) {
+	var result struct {
+		Tags []struct {
+			Key   string `xml:"Key"`
+			Value string `xml:"Value"`
+		} `xml:"ListUserTagsResult>Tags>member"`
+	}
+	if err := c.call(ctx, url.Values{"Action": {"ListUserTags"}, "UserName": {user}}, &result); err != nil {
+		return "", err
+	}
+	for _, tag := range result.Tags {
+		if tag.Key == key {
+			return tag.Value, nil
+		}
+	}
+	return "", nil
+}
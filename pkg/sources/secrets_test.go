@@ -0,0 +1,130 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// daysAgo returns the time the given number of days before now.
+func daysAgo(days int) time.Time {
+	return time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+}
+
+func TestSecretsVaultRotationAndOwners(t *testing.T) {
+	type meta struct {
+		updated int
+		custom  map[string]string
+	}
+	secrets := map[string]meta{
+		"/v1/secret/metadata/db":       {100, map[string]string{"owner": "dba"}},
+		"/v1/secret/metadata/apps/api": {10, nil},
+		// Owned under another key than the configured one
+		"/v1/kv/metadata/legacy": {400, map[string]string{"team": "payments"}},
+	}
+	lists := map[string][]string{
+		"/v1/secret/metadata/":      {"db", "apps/"},
+		"/v1/secret/metadata/apps/": {"api"},
+		"/v1/kv/metadata/":          {"legacy"},
+	}
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		var body interface{}
+		if r.URL.Query().Get("list") == "true" {
+			body = map[string]interface{}{"data": map[string]interface{}{"keys": lists[r.URL.Path]}}
+		} else if secret, ok := secrets[r.URL.Path]; ok {
+			body = map[string]interface{}{"data": map[string]interface{}{"updated_time": daysAgo(secret.updated), "custom_metadata": secret.custom}}
+		} else {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer vault.Close()
+
+	source, err := NewSecretsSource(SecretsConfig{Vault: &VaultConfig{Address: vault.URL + "/", Token: "s.token", Mounts: []string{"secret", "/kv/"}}}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kpis := kpiValues(collectSource(t, source))
+	// db and legacy are past the 90 day default; apps/api and legacy have
+	// no owner
+	if kpis[KPI_CredentialsOverdue] != 2 {
+		t.Errorf("overdue credentials = %v, want 2", kpis[KPI_CredentialsOverdue])
+	}
+	if kpis[KPI_UnownedServiceAccounts] != 2 {
+		t.Errorf("unowned service accounts = %v, want 2", kpis[KPI_UnownedServiceAccounts])
+	}
+	if age := kpis[KPI_CredentialAge]; math.Abs(age-170) > 0.01 {
+		t.Errorf("mean credential age = %v days, want 170", age)
+	}
+}
+
+func TestSecretsAWSIAMRotationAndOwners(t *testing.T) {
+	day := func(days int) string { return daysAgo(days).UTC().Format(time.RFC3339) }
+	report := strings.Join([]string{
+		"user,arn,password_enabled,password_last_changed,access_key_1_active,access_key_1_last_rotated,access_key_2_active,access_key_2_last_rotated",
+		"<root_account>,arn:aws:iam::123456789012:root,not_supported,not_supported,true," + day(900) + ",false,N/A",
+		// A person's keys are not a service account, but are still overdue
+		"alice,arn:aws:iam::123456789012:user/alice,true," + day(20) + ",true," + day(200) + ",false,N/A",
+		"ci-deploy,arn:aws:iam::123456789012:user/ci-deploy,false,N/A,true," + day(120) + ",true," + day(5),
+		"backup-bot,arn:aws:iam::123456789012:user/backup-bot,false,N/A,true," + day(30) + ",false,N/A",
+		"departed,arn:aws:iam::123456789012:user/departed,false,N/A,false," + day(500) + ",false,N/A",
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, []byte(report), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	owners := map[string]string{"backup-bot": "ops", "ci-deploy": ""}
+	var lookups []string
+	iam := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		user := r.PostForm.Get("UserName")
+		lookups = append(lookups, user)
+		if r.PostForm.Get("Action") != "ListUserTags" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			http.Error(w, "<Error/>", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "<ListUserTagsResponse><ListUserTagsResult><Tags><member><Key>cost-center</Key><Value>42</Value></member>"+
+			"<member><Key>owner</Key><Value>%s</Value></member></Tags></ListUserTagsResult></ListUserTagsResponse>", owners[user])
+	}))
+	defer iam.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	source, err := NewSecretsSource(SecretsConfig{AWSIAM: &AWSIAMConfig{ReportPath: path, Endpoint: iam.URL}, MaxAgeDays: 100}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := collectSource(t, source)
+	kpis := kpiValues(collector)
+	if kpis[KPI_CredentialsOverdue] != 2 {
+		t.Errorf("overdue credentials = %v, want 2 (alice and ci-deploy key 1)", kpis[KPI_CredentialsOverdue])
+	}
+	// ci-deploy holds two keys but is one account
+	if kpis[KPI_UnownedServiceAccounts] != 1 {
+		t.Errorf("unowned service accounts = %v, want 1", kpis[KPI_UnownedServiceAccounts])
+	}
+	if strings.Join(lookups, ",") != "ci-deploy,backup-bot" {
+		t.Errorf("owner lookups %v, want each service account once", lookups)
+	}
+
+	byID := metricsByID(collector)
+	for i, want := range []float64{3, 0, 1, 1, 0} {
+		if got := byID[fmt.Sprintf("secrets-age-%d", i+1)].Value; got != want {
+			t.Errorf("%s: %v credentials, want %v", credentialAgeBuckets[i].Name, got, want)
+		}
+	}
+}
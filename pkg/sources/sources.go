@@ -0,0 +1,306 @@
+// Package sources provides collection sources that pull security metrics
+// from external systems.
+package sources
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/campaigns"
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/grc"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/plugin"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// Config configures the external collection sources.
+type Config struct {
+	FleetDM     *FleetDMConfig     `yaml:"fleetdm"`
+	DMARC       *DMARCConfig       `yaml:"dmarc"`
+	TLS         *TLSConfig         `yaml:"tls"`
+	WAF         *WAFConfig         `yaml:"waf"`
+	Identity    *IdentityConfig    `yaml:"identity"`
+	AppSec      *AppSecConfig      `yaml:"appsec"`
+	CodeReview  *CodeReviewConfig  `yaml:"code_review"`
+	Scorecard   *ScorecardConfig   `yaml:"scorecard"`
+	GRC         *grc.Config        `yaml:"grc"`
+	Secrets     *SecretsConfig     `yaml:"secrets"`
+	InsiderRisk *InsiderRiskConfig `yaml:"insider_risk"`
+	Physical    *PhysicalConfig    `yaml:"physical"`
+	Probes      *ProbesConfig      `yaml:"probes"`
+	Inventory   *InventoryConfig   `yaml:"inventory"`
+	// Campaigns are time-boxed remediation pushes tracked through the
+	// findings in their scope.
+	Campaigns []campaigns.Config `yaml:"campaigns"`
+	// Plugins are external executables speaking the plugin protocol.
+	Plugins []plugin.Config `yaml:"plugins"`
+	// Derived KPIs are computed after all other sources have run.
+	Derived []DerivedConfig `yaml:"derived"`
+	// Policies set retries, attempt timeouts, circuit breakers and cache
+	// TTLs, keyed by source name as collect shows it, e.g. fleetdm or
+	// plugin:tickets.
+	Policies map[string]PolicyConfig `yaml:"policies"`
+	// CacheDir holds the cached API responses of sources with a cache
+	// TTL; defaults to secmetrics under the user cache directory.
+	CacheDir string `yaml:"cache_dir"`
+	// Cipher encrypts the cached API responses when encryption at rest
+	// is enabled. It is set from the encryption settings, not read from
+	// the sources section.
+	Cipher *encryption.Cipher `yaml:"-"`
+}
+
+// New creates the collection sources enabled in cfg.
+func New(cfg Config, client *http.Client) ([]server.Source, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	clients, err := newSourceClients(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []server.Source
+	if cfg.FleetDM != nil {
+		source, err := NewFleetDMSource(*cfg.FleetDM, clients.get("fleetdm"))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.DMARC != nil {
+		source, err := NewDMARCSource(*cfg.DMARC)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.TLS != nil {
+		source, err := NewTLSSource(*cfg.TLS, client)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.WAF != nil {
+		source, err := NewWAFSource(*cfg.WAF)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.Identity != nil {
+		source, err := NewIdentitySource(*cfg.Identity, clients.get("identity"))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.AppSec != nil {
+		source, err := NewAppSecSource(*cfg.AppSec)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.CodeReview != nil {
+		source, err := NewCodeReviewSource(*cfg.CodeReview, clients.get("code_review"))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.Scorecard != nil {
+		source, err := NewScorecardSource(*cfg.Scorecard, clients.get("scorecard"))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.GRC != nil {
+		source, err := NewGRCSource(*cfg.GRC, clients.get("grc"))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.Secrets != nil {
+		source, err := NewSecretsSource(*cfg.Secrets, clients.get("secrets"))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.InsiderRisk != nil {
+		source, err := NewInsiderRiskSource(*cfg.InsiderRisk)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.Physical != nil {
+		source, err := NewPhysicalSource(*cfg.Physical)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.Probes != nil {
+		source, err := NewProbesSource(*cfg.Probes, client)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if cfg.Inventory != nil {
+		source, err := NewInventorySource(*cfg.Inventory)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if len(cfg.Campaigns) > 0 {
+		source, err := NewCampaignsSource(cfg.Campaigns)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	for _, pluginCfg := range cfg.Plugins {
+		source, err := NewPluginSource(pluginCfg)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if len(cfg.Derived) > 0 {
+		source, err := NewDerivedSource(cfg.Derived)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	if err := applyPolicies(sources, cfg.Policies); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// getJSON performs an authenticated GET and decodes the JSON response into v.
+func getJSON(ctx context.Context, client *http.Client, url string, header http.Header, v interface{}) error {
+	_, err := getJSONPage(ctx, client, url, header, v)
+	return err
+}
+
+// getJSONPage performs an authenticated GET, decodes the JSON response into
+// v and returns the rel="next" Link header URL, or "" on the last page.
+func getJSONPage(ctx context.Context, client *http.Client, url string, header http.Header, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	return nextLink(resp.Header.Values("Link")), nil
+}
+
+// nextLink returns the rel="next" URL from Link headers.
+func nextLink(links []string) string {
+	for _, header := range links {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			if len(parts) < 2 {
+				continue
+			}
+			for _, param := range parts[1:] {
+				if strings.TrimSpace(param) == `rel="next"` {
+					return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, string(body))
+}
+
+// openFile opens path for reading, transparently decompressing .gz files.
+func openFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(strings.ToLower(path), ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: gz, file: f}, nil
+}
+
+// gzipFile closes both the gzip stream and the underlying file.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// registerDefinitions registers KPI definitions not yet known to collector.
+func registerDefinitions(collector *metrics.MetricsCollector, defs []metrics.KPIDefinition) {
+	for _, def := range defs {
+		if _, ok := collector.GetKPIDefinition(def.Key); !ok {
+			collector.RegisterKPIDefinition(def)
+		}
+	}
+}
+
+// percent returns n as a percentage of total, or 0 when total is 0.
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// targetStatus returns the metric status for value against a
+// higher-is-better target.
+func targetStatus(value, target float64) string {
+	if value >= target {
+		return "ON_TARGET"
+	}
+	return "BELOW_TARGET"
+}
@@ -0,0 +1,62 @@
+package sources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// fixture returns the contents of a file in testdata.
+func fixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// writeExports writes each export into a new directory and returns the
+// directory.
+func writeExports(t *testing.T, exports map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range exports {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// collectSource runs source into a new collector and returns it.
+func collectSource(t *testing.T, source server.Source) *metrics.MetricsCollector {
+	t.Helper()
+	collector := metrics.NewMetricsCollector()
+	if err := source.Collect(context.Background(), collector); err != nil {
+		t.Fatal(err)
+	}
+	return collector
+}
+
+// metricsByID returns the collected metrics by ID.
+func metricsByID(collector *metrics.MetricsCollector) map[string]metrics.SecurityMetric {
+	byID := make(map[string]metrics.SecurityMetric)
+	for _, metric := range collector.GetMetrics() {
+		byID[metric.ID] = metric
+	}
+	return byID
+}
+
+// kpiValues returns the values of the collected KPIs by key.
+func kpiValues(collector *metrics.MetricsCollector) map[metrics.KPIKey]float64 {
+	values := make(map[metrics.KPIKey]float64)
+	for _, kpi := range collector.GetKPIS() {
+		values[kpi.Key] = kpi.Value
+	}
+	return values
+}
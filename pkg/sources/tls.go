@@ -0,0 +1,186 @@
+package sources
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// TLS hygiene KPI keys.
+const (
+	KPI_TLSCertsExpiring metrics.KPIKey = "tls_certs_expiring"
+	KPI_TLSWeakProtocols metrics.KPIKey = "tls_weak_protocols"
+	KPI_TLSHSTSCoverage  metrics.KPIKey = "tls_hsts_coverage"
+)
+
+// TLSConfig configures certificate and TLS hygiene scanning.
+type TLSConfig struct {
+	// Endpoints lists host or host:port entries; the port defaults to 443.
+	Endpoints []string `yaml:"endpoints"`
+	// ExpiryDays is the window for counting expiring certificates (default 30).
+	ExpiryDays int `yaml:"expiry_days"`
+	// Timeout bounds each connection attempt, e.g. "10s" (default 10s).
+	Timeout string `yaml:"timeout"`
+}
+
+// tlsScan is the result of scanning one endpoint.
+type tlsScan struct {
+	expires time.Time
+	weak    bool
+	hsts    bool
+}
+
+func tlsDefinitions(expiryDays int) []metrics.KPIDefinition {
+	return []metrics.KPIDefinition{
+		{Key: KPI_TLSCertsExpiring, Name: fmt.Sprintf("Certificates Expiring <%d Days", expiryDays), Unit: "certs", Category: "Prevention", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_TLSWeakProtocols, Name: "Endpoints Accepting TLS<1.2", Unit: "endpoints", Category: "Prevention", Direction: metrics.LowerIsBetter, Min: metrics.Bound(0)},
+		{Key: KPI_TLSHSTSCoverage, Name: "HSTS Coverage", Unit: "%", Category: "Prevention", Direction: metrics.HigherIsBetter, Min: metrics.Bound(0), Max: metrics.Bound(100)},
+	}
+}
+
+// NewTLSSource creates a source scanning endpoints for certificate
+// expiry, legacy protocol support and HSTS.
+func NewTLSSource(cfg TLSConfig, client *http.Client) (server.Source, error) {
+	if len(cfg.Endpoints) == 0 {
+		return server.Source{}, fmt.Errorf("tls: at least one endpoint is required")
+	}
+	if cfg.ExpiryDays == 0 {
+		cfg.ExpiryDays = 30
+	}
+	timeout := 10 * time.Second
+	if cfg.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return server.Source{}, fmt.Errorf("tls: timeout: %w", err)
+		}
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		now := time.Now()
+		cutoff := now.AddDate(0, 0, cfg.ExpiryDays)
+
+		var expiring, weak, hsts, scanned int
+		var firstErr error
+		for _, endpoint := range cfg.Endpoints {
+			addr := endpoint
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				addr = net.JoinHostPort(addr, "443")
+			}
+
+			scan, err := scanTLS(ctx, client, addr, timeout)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("tls: %s: %w", endpoint, err)
+				}
+				continue
+			}
+			scanned++
+			if scan.expires.Before(cutoff) {
+				expiring++
+			}
+			if scan.weak {
+				weak++
+			}
+			if scan.hsts {
+				hsts++
+			}
+
+			days := scan.expires.Sub(now).Hours() / 24
+			status := "ON_TARGET"
+			if scan.expires.Before(cutoff) {
+				status = "BELOW_TARGET"
+			}
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "tls-expiry-" + endpoint,
+				Name:        "Certificate Expiry (" + endpoint + ")",
+				Type:        metrics.TypePrevention,
+				Value:       days,
+				Unit:        "days",
+				Target:      float64(cfg.ExpiryDays),
+				Status:      status,
+				Description: "Expires " + scan.expires.UTC().Format(time.RFC3339),
+				Category:    "TLS",
+				Provenance:  metrics.Provenance{Record: addr},
+			})
+		}
+		if scanned == 0 {
+			return firstErr
+		}
+
+		registerDefinitions(collector, tlsDefinitions(cfg.ExpiryDays))
+		collector.AddKPI(metrics.KPI{Key: KPI_TLSCertsExpiring, Value: float64(expiring), Target: 0})
+		collector.AddKPI(metrics.KPI{Key: KPI_TLSWeakProtocols, Value: float64(weak), Target: 0})
+		collector.AddKPI(metrics.KPI{Key: KPI_TLSHSTSCoverage, Value: percent(hsts, scanned), Target: 100})
+		return firstErr
+	}
+
+	return server.Source{Name: "tls", Collect: collect}, nil
+}
+
+// scanTLS inspects the certificate, legacy protocol support and HSTS
+// header of a single endpoint.
+func scanTLS(ctx context.Context, client *http.Client, addr string, timeout time.Duration) (tlsScan, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		// Verification is skipped so that expired or misconfigured
+		// certificates can still be measured.
+		Config: &tls.Config{ServerName: host, InsecureSkipVerify: true},
+	}
+
+	var scan tlsScan
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return scan, err
+	}
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	conn.Close()
+	if len(certs) == 0 {
+		return scan, fmt.Errorf("no certificate presented")
+	}
+	scan.expires = certs[0].NotAfter
+
+	// A handshake limited to TLS 1.0/1.1 succeeds only if the endpoint
+	// still accepts a legacy protocol.
+	dialer.Config = &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		MaxVersion:         tls.VersionTLS11,
+	}
+	if legacy, err := dialer.DialContext(ctx, "tcp", addr); err == nil {
+		scan.weak = true
+		legacy.Close()
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodHead, "https://"+addr+"/", nil)
+	if err != nil {
+		return scan, err
+	}
+	if resp, err := client.Do(req); err == nil {
+		scan.hsts = hasHSTS(resp.Header.Get("Strict-Transport-Security"))
+		resp.Body.Close()
+	}
+	return scan, nil
+}
+
+// hasHSTS reports whether a Strict-Transport-Security header enables HSTS.
+func hasHSTS(header string) bool {
+	for _, directive := range strings.Split(header, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			return strings.Trim(value, `"`) != "0"
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package sources
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// expiringCertificate returns a self-signed certificate for 127.0.0.1
+// that expires after validity.
+func expiringCertificate(t *testing.T, validity time.Duration) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "legacy.corp.example"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestTLSSourceExpiryAndWeakProtocols(t *testing.T) {
+	// A modern endpoint with HSTS and a long-lived certificate, which
+	// logs the refused legacy handshake
+	modern := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+	}))
+	modern.Config.ErrorLog = log.New(io.Discard, "", 0)
+	modern.StartTLS()
+	defer modern.Close()
+
+	// A legacy endpoint accepting TLS 1.0 with a certificate expiring in
+	// ten days, which disables HSTS
+	cert := expiringCertificate(t, 10*24*time.Hour)
+	legacy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=0")
+	}))
+	legacy.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS10}
+	legacy.StartTLS()
+	defer legacy.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(modern.Certificate())
+	roots.AddCert(cert.Leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+
+	modernAddr := strings.TrimPrefix(modern.URL, "https://")
+	legacyAddr := strings.TrimPrefix(legacy.URL, "https://")
+	source, err := NewTLSSource(TLSConfig{Endpoints: []string{modernAddr, legacyAddr}, Timeout: "5s"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := collectSource(t, source)
+
+	kpis := kpiValues(collector)
+	if kpis[KPI_TLSCertsExpiring] != 1 {
+		t.Errorf("expiring certificates = %v, want 1", kpis[KPI_TLSCertsExpiring])
+	}
+	if kpis[KPI_TLSWeakProtocols] != 1 {
+		t.Errorf("endpoints accepting TLS<1.2 = %v, want 1", kpis[KPI_TLSWeakProtocols])
+	}
+	if kpis[KPI_TLSHSTSCoverage] != 50 {
+		t.Errorf("HSTS coverage = %v, want 50", kpis[KPI_TLSHSTSCoverage])
+	}
+
+	byID := metricsByID(collector)
+	expiring := byID["tls-expiry-"+legacyAddr]
+	if expiring.Status != "BELOW_TARGET" || expiring.Value < 9.9 || expiring.Value > 10 {
+		t.Errorf("legacy endpoint expiry = %.2f days, %s", expiring.Value, expiring.Status)
+	}
+	if valid := byID["tls-expiry-"+modernAddr]; valid.Status != "ON_TARGET" || valid.Value < 30 {
+		t.Errorf("modern endpoint expiry = %.2f days, %s", valid.Value, valid.Status)
+	}
+}
+
+func TestTLSSourceFailsWhenNoEndpointAnswers(t *testing.T) {
+	source, err := NewTLSSource(TLSConfig{Endpoints: []string{"127.0.0.1:1"}, Timeout: "1s"}, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Collect(context.Background(), metrics.NewMetricsCollector()); err == nil || !strings.Contains(err.Error(), "127.0.0.1:1") {
+		t.Errorf("collect from an unreachable endpoint: %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package sources
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+	"github.com/hallucinaut/secmetrics/pkg/server"
+)
+
+// WAFConfig configures WAF event metrics from exported log files.
+type WAFConfig struct {
+	Logs []WAFLogConfig `yaml:"logs"`
+	// WindowHours limits events to the last N hours (default 24).
+	WindowHours int `yaml:"window_hours"`
+	// SampleRate is the fraction of log lines parsed, between 0 and 1
+	// (default 1). Counts are scaled back up by the sampling rate.
+	SampleRate float64 `yaml:"sample_rate"`
+	// TopCategories is the number of attack categories reported (default 5).
+	TopCategories int `yaml:"top_categories"`
+}
+
+// WAFLogConfig identifies a directory of JSON-lines WAF logs.
+type WAFLogConfig struct {
+	// Provider is "aws" for AWS WAF logs or "cloudflare" for Cloudflare
+	// firewall events from Logpush.
+	Provider string `yaml:"provider"`
+	// Path is a directory of .json, .log or .gz files.
+	Path string `yaml:"path"`
+}
+
+// wafEvent is a provider-neutral WAF log event.
+type wafEvent struct {
+	Time     time.Time
+	Blocked  bool
+	Matched  bool
+	Rule     string
+	Category string
+}
+
+// wafParsers decode one log line per provider.
+var wafParsers = map[string]func(line []byte) (wafEvent, error){
+	"aws":        parseAWSWAFEvent,
+	"cloudflare": parseCloudflareEvent,
+}
+
+// NewWAFSource creates a source summarizing blocked attack volume, top
+// attack categories and rule efficacy as detection metrics.
+func NewWAFSource(cfg WAFConfig) (server.Source, error) {
+	if len(cfg.Logs) == 0 {
+		return server.Source{}, fmt.Errorf("waf: at least one log is required")
+	}
+	for _, log := range cfg.Logs {
+		if _, ok := wafParsers[log.Provider]; !ok {
+			return server.Source{}, fmt.Errorf("waf: unknown provider %q", log.Provider)
+		}
+		if log.Path == "" {
+			return server.Source{}, fmt.Errorf("waf: %s log path is required", log.Provider)
+		}
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return server.Source{}, fmt.Errorf("waf: sample_rate must be between 0 and 1")
+	}
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = 1
+	}
+	if cfg.WindowHours == 0 {
+		cfg.WindowHours = 24
+	}
+	if cfg.TopCategories == 0 {
+		cfg.TopCategories = 5
+	}
+
+	collect := func(ctx context.Context, collector *metrics.MetricsCollector) error {
+		since := time.Now().Add(-time.Duration(cfg.WindowHours) * time.Hour)
+
+		var blocked, matched int
+		categories := make(map[string]int)
+		for _, log := range cfg.Logs {
+			err := readWAFLogs(ctx, log, cfg.SampleRate, func(event wafEvent) {
+				if event.Time.Before(since) || !event.Matched {
+					return
+				}
+				matched++
+				if event.Blocked {
+					blocked++
+					categories[event.Category]++
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("waf: %s: %w", log.Provider, err)
+			}
+		}
+
+		scale := 1 / cfg.SampleRate
+		window := fmt.Sprintf("last %dh", cfg.WindowHours)
+		collector.AddMetric(metrics.SecurityMetric{
+			ID:          "waf-blocked",
+			Name:        "WAF Blocked Requests",
+			Type:        metrics.TypeDetection,
+			Value:       float64(blocked) * scale,
+			Unit:        "requests",
+			Description: "Estimated blocked attack volume, " + window,
+			Category:    "WAF",
+		})
+		collector.AddMetric(metrics.SecurityMetric{
+			ID:          "waf-rule-efficacy",
+			Name:        "WAF Rule Efficacy",
+			Type:        metrics.TypeDetection,
+			Value:       percent(blocked, matched),
+			Unit:        "%",
+			Description: "Rule matches that blocked the request rather than only logging it, " + window,
+			Category:    "WAF",
+		})
+
+		for i, category := range topCounts(categories, cfg.TopCategories) {
+			collector.AddMetric(metrics.SecurityMetric{
+				ID:          "waf-category-" + strconv.Itoa(i+1),
+				Name:        "WAF Top Attack Category #" + strconv.Itoa(i+1) + ": " + category,
+				Type:        metrics.TypeDetection,
+				Value:       float64(categories[category]) * scale,
+				Unit:        "requests",
+				Description: "Estimated blocked requests, " + window,
+				Category:    "WAF",
+			})
+		}
+		return nil
+	}
+
+	return server.Source{Name: "waf", Collect: collect}, nil
+}
+
+// readWAFLogs parses a sample of the lines in every log file under the
+// configured directory.
+func readWAFLogs(ctx context.Context, log WAFLogConfig, sampleRate float64, fn func(wafEvent)) error {
+	entries, err := os.ReadDir(log.Path)
+	if err != nil {
+		return err
+	}
+	parse := wafParsers[log.Provider]
+	// A fixed seed keeps the sample reproducible between collections.
+	sampler := rand.New(rand.NewSource(1))
+
+	for _, entry := range entries {
+		name := strings.ToLower(entry.Name())
+		if entry.IsDir() || !(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".gz")) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rc, err := openFile(filepath.Join(log.Path, entry.Name()))
+		if err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(rc)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			// Sampling keeps collection cheap on large logs: skipped lines
+			// are not decoded.
+			if len(scanner.Bytes()) == 0 || (sampleRate < 1 && sampler.Float64() >= sampleRate) {
+				continue
+			}
+			event, err := parse(scanner.Bytes())
+			if err != nil {
+				rc.Close()
+				return fmt.Errorf("%s:%d: %w", entry.Name(), line, err)
+			}
+			fn(event)
+		}
+		err = scanner.Err()
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// parseAWSWAFEvent decodes an AWS WAF log record.
+func parseAWSWAFEvent(line []byte) (wafEvent, error) {
+	var record struct {
+		Timestamp         int64  `json:"timestamp"`
+		Action            string `json:"action"`
+		TerminatingRuleID string `json:"terminatingRuleId"`
+		MatchDetails      []struct {
+			ConditionType string `json:"conditionType"`
+		} `json:"terminatingRuleMatchDetails"`
+		NonTerminatingMatchingRules []struct {
+			RuleID string `json:"ruleId"`
+		} `json:"nonTerminatingMatchingRules"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return wafEvent{}, err
+	}
+
+	event := wafEvent{
+		Time:    time.UnixMilli(record.Timestamp),
+		Blocked: record.Action == "BLOCK",
+		Rule:    record.TerminatingRuleID,
+	}
+	// Allowed requests only count as a rule match when a rule in count
+	// mode fired on them.
+	event.Matched = event.Blocked || len(record.NonTerminatingMatchingRules) > 0
+	event.Category = event.Rule
+	if len(record.MatchDetails) > 0 && record.MatchDetails[0].ConditionType != "" {
+		event.Category = record.MatchDetails[0].ConditionType
+	}
+	return event, nil
+}
+
+// parseCloudflareEvent decodes a Cloudflare firewall_events Logpush record.
+func parseCloudflareEvent(line []byte) (wafEvent, error) {
+	var record struct {
+		Datetime    json.RawMessage `json:"Datetime"`
+		Action      string          `json:"Action"`
+		Source      string          `json:"Source"`
+		RuleID      string          `json:"RuleID"`
+		Description string          `json:"Description"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return wafEvent{}, err
+	}
+
+	event := wafEvent{
+		Matched: true,
+		Rule:    record.RuleID,
+	}
+	switch strings.ToLower(record.Action) {
+	case "block", "drop", "challenge", "managed_challenge", "jschallenge":
+		event.Blocked = true
+	}
+	event.Category = record.Description
+	if event.Category == "" {
+		event.Category = record.Source
+	}
+
+	// Datetime is RFC 3339 or Unix nanoseconds depending on the job's
+	// timestamp format.
+	var text string
+	if err := json.Unmarshal(record.Datetime, &text); err == nil {
+		t, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return wafEvent{}, fmt.Errorf("invalid Datetime %q", text)
+		}
+		event.Time = t
+	} else {
+		var ns int64
+		if err := json.Unmarshal(record.Datetime, &ns); err != nil {
+			return wafEvent{}, fmt.Errorf("invalid Datetime")
+		}
+		event.Time = time.Unix(0, ns)
+	}
+	return event, nil
+}
+
+// topCounts returns up to n keys of counts ordered by descending count.
+func topCounts(counts map[string]int, n int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
@@ -0,0 +1,217 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// ReportSchedule renders a report of Type every Interval, e.g. "24h",
+// with timestamps in the IANA TimeZone if set. It appears only in
+// desired-state documents; exports omit it.
+type ReportSchedule struct {
+	Type     string `json:"type"`
+	Interval string `json:"interval"`
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// Parse reads a desired-state document in the export format. Unknown
+// fields are rejected so typos fail review rather than being ignored.
+func Parse(data []byte) (*State, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	s := &State{}
+	if err := decoder.Decode(s); err != nil {
+		return nil, err
+	}
+	if s.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("format_version %d is not supported (want %d)", s.FormatVersion, FormatVersion)
+	}
+	for key, kpi := range s.KPIs {
+		if kpi.Percentiles == nil {
+			kpi.Percentiles = []float64{}
+		}
+		// Active is the default, which exports leave out.
+		if kpi.Lifecycle == string(metrics.LifecycleActive) {
+			kpi.Lifecycle = ""
+		}
+		s.KPIs[key] = kpi
+	}
+	return s, nil
+}
+
+// LoadDir reads and merges every .json document under dir, skipping
+// hidden files and directories such as .git. A KPI, alert rule or report
+// schedule may be declared in only one document.
+func LoadDir(dir string) (*State, error) {
+	merged := &State{
+		FormatVersion:   FormatVersion,
+		KPIs:            make(map[string]KPI),
+		AlertRules:      make(map[string]AlertRule),
+		ReportSchedules: make(map[string]ReportSchedule),
+	}
+	origin := make(map[string]string)
+	declare := func(kind, key, file string) error {
+		id := kind + " " + key
+		if previous, ok := origin[id]; ok {
+			return fmt.Errorf("%s is declared in both %s and %s", id, previous, file)
+		}
+		origin[id] = file
+		return nil
+	}
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		doc, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		for key, kpi := range doc.KPIs {
+			if err := declare("kpi", key, rel); err != nil {
+				return err
+			}
+			merged.KPIs[key] = kpi
+		}
+		for key, rule := range doc.AlertRules {
+			if err := declare("alert rule", key, rel); err != nil {
+				return err
+			}
+			merged.AlertRules[key] = rule
+		}
+		for key, schedule := range doc.ReportSchedules {
+			if err := declare("report schedule", key, rel); err != nil {
+				return err
+			}
+			merged.ReportSchedules[key] = schedule
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := merged.Validate(); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// Validate checks that alert rules refer to declared KPIs and agree with
+// their direction, and that report schedules have a type and a valid
+// interval.
+func (s *State) Validate() error {
+	seen := make(map[string]string)
+	for _, key := range sortedKeys(s.AlertRules) {
+		rule := s.AlertRules[key]
+		kpi, ok := s.KPIs[rule.KPI]
+		if !ok {
+			return fmt.Errorf("alert rule %s: kpi %q is not declared", key, rule.KPI)
+		}
+		if rule.Severity != "warning" && rule.Severity != "critical" {
+			return fmt.Errorf("alert rule %s: severity must be warning or critical, got %q", key, rule.Severity)
+		}
+		if want := operatorFor(metrics.Direction(kpi.Direction)); rule.Operator != want {
+			return fmt.Errorf("alert rule %s: operator must be %q for a %s kpi", key, want, kpi.Direction)
+		}
+		id := rule.KPI + " " + rule.Severity
+		if previous, ok := seen[id]; ok {
+			return fmt.Errorf("alert rules %s and %s both set the %s threshold of %s", previous, key, rule.Severity, rule.KPI)
+		}
+		seen[id] = key
+	}
+	for _, key := range sortedKeys(s.ReportSchedules) {
+		schedule := s.ReportSchedules[key]
+		if schedule.Type == "" {
+			return fmt.Errorf("report schedule %s: type is required", key)
+		}
+		if interval, err := time.ParseDuration(schedule.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("report schedule %s: invalid interval %q", key, schedule.Interval)
+		}
+		if schedule.TimeZone != "" {
+			if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+				return fmt.Errorf("report schedule %s: invalid time zone %q", key, schedule.TimeZone)
+			}
+		}
+	}
+	for _, def := range s.Definitions() {
+		if err := metrics.NewMetricsCollector().SetKPIDefinition(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Definitions returns the KPI definitions the state declares, sorted by
+// key, with the thresholds of their alert rules.
+func (s *State) Definitions() []metrics.KPIDefinition {
+	defs := make([]metrics.KPIDefinition, 0, len(s.KPIs))
+	for _, key := range sortedKeys(s.KPIs) {
+		kpi := s.KPIs[key]
+		def := metrics.KPIDefinition{
+			Key:         metrics.KPIKey(key),
+			Name:        kpi.Name,
+			Description: kpi.Description,
+			Unit:        kpi.Unit,
+			Category:    kpi.Category,
+			Direction:   metrics.Direction(kpi.Direction),
+			Min:         kpi.Min,
+			Max:         kpi.Max,
+			Target:      kpi.Target,
+			Percentiles: append([]float64{}, kpi.Percentiles...),
+			Lifecycle:   metrics.Lifecycle(kpi.Lifecycle),
+		}
+		for _, rule := range s.AlertRules {
+			if rule.KPI != key {
+				continue
+			}
+			threshold := rule.Threshold
+			if rule.Severity == "critical" {
+				def.CriticalThreshold = &threshold
+			} else {
+				def.WarningThreshold = &threshold
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// operatorFor returns the alert rule operator of a KPI direction.
+func operatorFor(direction metrics.Direction) string {
+	if direction == metrics.LowerIsBetter {
+		return ">"
+	}
+	return "<"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
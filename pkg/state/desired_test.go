@@ -0,0 +1,112 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func writeDoc(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeDoc(t, dir, "kpis.json", `{"format_version": 1,
+		"kpis": {"mttr": {"name": "MTTR", "unit": "hours", "category": "Response", "direction": "lower_is_better", "target": 2}},
+		"alert_rules": {"mttr_critical": {"kpi": "mttr", "severity": "critical", "operator": ">", "threshold": 6}}}`)
+	writeDoc(t, dir, "reports/weekly.json", `{"format_version": 1,
+		"report_schedules": {"weekly": {"type": "executive", "interval": "168h"}}}`)
+	writeDoc(t, dir, ".git/config.json", `not json`)
+	writeDoc(t, dir, "README.md", `ignored`)
+
+	s, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ReportSchedules["weekly"].Type != "executive" {
+		t.Errorf("report schedules = %+v", s.ReportSchedules)
+	}
+	defs := s.Definitions()
+	if len(defs) != 1 {
+		t.Fatalf("definitions = %+v", defs)
+	}
+	def := defs[0]
+	if def.Key != metrics.KPI_MTTR || def.Direction != metrics.LowerIsBetter || *def.Target != 2 || *def.CriticalThreshold != 6 || def.WarningThreshold != nil {
+		t.Errorf("definition = %+v", def)
+	}
+}
+
+func TestLoadDirRejectsInvalidDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		docs    map[string]string
+		wantErr string
+	}{
+		{"unknown field", map[string]string{"a.json": `{"format_version": 1, "kpis": {"mttr": {"traget": 2}}}`}, `unknown field "traget"`},
+		{"format version", map[string]string{"a.json": `{"format_version": 2}`}, "format_version 2"},
+		{"duplicate", map[string]string{
+			"a.json": `{"format_version": 1, "kpis": {"mttr": {}}}`,
+			"b.json": `{"format_version": 1, "kpis": {"mttr": {}}}`,
+		}, "kpi mttr is declared in both a.json and b.json"},
+		{"undeclared kpi", map[string]string{"a.json": `{"format_version": 1,
+			"alert_rules": {"x": {"kpi": "mttr", "severity": "warning", "operator": ">", "threshold": 1}}}`}, `kpi "mttr" is not declared`},
+		{"operator", map[string]string{"a.json": `{"format_version": 1, "kpis": {"mttr": {"direction": "lower_is_better"}},
+			"alert_rules": {"x": {"kpi": "mttr", "severity": "warning", "operator": "<", "threshold": 1}}}`}, `operator must be ">"`},
+		{"bounds", map[string]string{"a.json": `{"format_version": 1, "kpis": {"mttr": {"min": 5, "max": 1}}}`}, "minimum exceeds maximum"},
+		{"interval", map[string]string{"a.json": `{"format_version": 1,
+			"report_schedules": {"daily": {"type": "executive", "interval": "daily"}}}`}, `invalid interval "daily"`},
+		{"time zone", map[string]string{"a.json": `{"format_version": 1,
+			"report_schedules": {"daily": {"type": "executive", "interval": "24h", "time_zone": "Mars/Olympus"}}}`}, `invalid time zone "Mars/Olympus"`},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, content := range tt.docs {
+			writeDoc(t, dir, name, content)
+		}
+		if _, err := LoadDir(dir); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := &State{
+		KPIs:       map[string]KPI{"mttr": {Name: "MTTR", Target: metrics.Bound(2)}, "old": {}},
+		AlertRules: map[string]AlertRule{"mttr_warning": {KPI: "mttr", Threshold: 3}},
+	}
+	to := &State{
+		KPIs:            map[string]KPI{"mttr": {Name: "Mean Time to Respond", Target: metrics.Bound(2)}, "new": {}},
+		AlertRules:      map[string]AlertRule{"mttr_warning": {KPI: "mttr", Threshold: 3}},
+		ReportSchedules: map[string]ReportSchedule{"weekly": {Type: "executive", Interval: "168h"}},
+	}
+	want := []Change{
+		{Kind: "kpi", Key: "mttr", Action: "change", Fields: []string{"name"}},
+		{Kind: "kpi", Key: "new", Action: "add"},
+		{Kind: "kpi", Key: "old", Action: "remove"},
+		{Kind: "report_schedule", Key: "weekly", Action: "add"},
+	}
+	got := Diff(from, to)
+	if len(got) != len(want) {
+		t.Fatalf("Diff = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Kind != want[i].Kind || got[i].Key != want[i].Key || got[i].Action != want[i].Action ||
+			strings.Join(got[i].Fields, ",") != strings.Join(want[i].Fields, ",") {
+			t.Errorf("change %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if changes := Diff(to, to); len(changes) != 0 {
+		t.Errorf("Diff of equal states = %+v", changes)
+	}
+}
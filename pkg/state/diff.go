@@ -0,0 +1,66 @@
+package state
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Change is a difference between two states.
+type Change struct {
+	// Kind is "kpi", "alert_rule" or "report_schedule".
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+	// Action is "add", "change" or "remove".
+	Action string `json:"action"`
+	// Fields lists the changed fields of a "change".
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Diff returns the changes that turn from into to, ordered by kind and key.
+func Diff(from, to *State) []Change {
+	var changes []Change
+	changes = append(changes, diffMap("kpi", from.KPIs, to.KPIs)...)
+	changes = append(changes, diffMap("alert_rule", from.AlertRules, to.AlertRules)...)
+	changes = append(changes, diffMap("report_schedule", from.ReportSchedules, to.ReportSchedules)...)
+	return changes
+}
+
+func diffMap[V any](kind string, from, to map[string]V) []Change {
+	keys := make(map[string]bool)
+	for key := range from {
+		keys[key] = true
+	}
+	for key := range to {
+		keys[key] = true
+	}
+	var changes []Change
+	for _, key := range sortedKeys(keys) {
+		before, inFrom := from[key]
+		after, inTo := to[key]
+		switch {
+		case !inFrom:
+			changes = append(changes, Change{Kind: kind, Key: key, Action: "add"})
+		case !inTo:
+			changes = append(changes, Change{Kind: kind, Key: key, Action: "remove"})
+		default:
+			if fields := changedFields(before, after); len(fields) > 0 {
+				changes = append(changes, Change{Kind: kind, Key: key, Action: "change", Fields: fields})
+			}
+		}
+	}
+	return changes
+}
+
+// changedFields returns the JSON names of the fields that differ between
+// structs a and b.
+func changedFields(a, b interface{}) []string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	var fields []string
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			name, _, _ := strings.Cut(va.Type().Field(i).Tag.Get("json"), ",")
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
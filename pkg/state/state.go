@@ -0,0 +1,121 @@
+// Package state exports KPI definitions, targets and alert rules as a
+// stable JSON document for posture-as-code repositories, e.g. to drive
+// Terraform resources with for_each over its maps.
+//
+// Objects are keyed by stable identifiers and encoded with sorted keys, so
+// exports of unchanged configuration are byte-identical and diffs show only
+// what changed.
+//
+// LoadDir reads documents in the same format as the desired state that
+// serve mode reconciles to, and Diff compares two states.
+package state
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// FormatVersion is the schema version of the document. It changes only
+// when a field is removed or changes meaning.
+const FormatVersion = 1
+
+// State is the exported document.
+type State struct {
+	FormatVersion int                  `json:"format_version"`
+	KPIs          map[string]KPI       `json:"kpis"`
+	AlertRules    map[string]AlertRule `json:"alert_rules"`
+	// ReportSchedules is read from desired-state documents; see LoadDir.
+	ReportSchedules map[string]ReportSchedule `json:"report_schedules,omitempty"`
+}
+
+// KPI is a KPI definition and its target. Optional values are null when
+// not set.
+type KPI struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Unit        string    `json:"unit"`
+	Category    string    `json:"category"`
+	Direction   string    `json:"direction"`
+	Min         *float64  `json:"min"`
+	Max         *float64  `json:"max"`
+	Target      *float64  `json:"target"`
+	Percentiles []float64 `json:"percentiles"`
+	Archived    bool      `json:"archived"`
+	// Lifecycle is "draft" or "deprecated"; empty means active.
+	Lifecycle string `json:"lifecycle,omitempty"`
+}
+
+// AlertRule fires when a KPI crosses a threshold: for higher-is-better
+// KPIs when the value drops below it ("<"), otherwise when it rises above
+// it (">").
+type AlertRule struct {
+	KPI       string  `json:"kpi"`
+	Severity  string  `json:"severity"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Build collects the state of collector: every defined KPI plus stored
+// KPIs without a definition, with the targets of stored KPIs.
+func Build(collector *metrics.MetricsCollector) *State {
+	s := &State{
+		FormatVersion: FormatVersion,
+		KPIs:          make(map[string]KPI),
+		AlertRules:    make(map[string]AlertRule),
+	}
+	for _, def := range collector.GetKPIDefinitions() {
+		key := string(def.Key)
+		s.KPIs[key] = KPI{
+			Name:        def.Name,
+			Description: def.Description,
+			Unit:        def.Unit,
+			Category:    def.Category,
+			Direction:   string(def.Direction),
+			Min:         def.Min,
+			Max:         def.Max,
+			Percentiles: append([]float64{}, def.Percentiles...),
+		}
+		if lifecycle := collector.KPILifecycle(def.Key); lifecycle != metrics.LifecycleActive {
+			entry := s.KPIs[key]
+			entry.Lifecycle = string(lifecycle)
+			s.KPIs[key] = entry
+		}
+		operator := operatorFor(def.Direction)
+		if def.WarningThreshold != nil {
+			s.AlertRules[key+"_warning"] = AlertRule{KPI: key, Severity: "warning", Operator: operator, Threshold: *def.WarningThreshold}
+		}
+		if def.CriticalThreshold != nil {
+			s.AlertRules[key+"_critical"] = AlertRule{KPI: key, Severity: "critical", Operator: operator, Threshold: *def.CriticalThreshold}
+		}
+	}
+
+	stored := append(append([]metrics.KPI{}, collector.GetKPIS()...), collector.GetArchivedKPIs()...)
+	for _, kpi := range stored {
+		key := string(kpi.Key)
+		entry, ok := s.KPIs[key]
+		if !ok {
+			entry = KPI{Name: kpi.Name, Description: kpi.Description, Unit: kpi.Unit, Category: kpi.Category,
+				Direction: string(metrics.HigherIsBetter), Percentiles: []float64{}}
+		}
+		target := kpi.Target
+		entry.Target = &target
+		entry.Archived = kpi.IsArchived()
+		if entry.Category == "" {
+			entry.Category = kpi.Category
+		}
+		s.KPIs[key] = entry
+	}
+	return s
+}
+
+// Export writes the state of collector as indented JSON.
+func Export(w io.Writer, collector *metrics.MetricsCollector) error {
+	data, err := json.MarshalIndent(Build(collector), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
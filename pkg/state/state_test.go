@@ -0,0 +1,61 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestBuild(t *testing.T) {
+	collector := metrics.NewMetricsCollector()
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 3, Target: 1.5})
+	collector.AddKPI(metrics.KPI{Key: "phishing_clicks", Name: "Phishing Clicks", Unit: "%", Category: "Awareness", Target: 5})
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_Coverage, Value: 80, Target: 95})
+	if !collector.ArchiveKPI(metrics.KPI_Coverage) {
+		t.Fatal("coverage was not archived")
+	}
+
+	s := Build(collector)
+	mttr := s.KPIs["mttr"]
+	if mttr.Target == nil || *mttr.Target != 1.5 || mttr.Direction != "lower_is_better" || mttr.Archived {
+		t.Errorf("mttr = %+v", mttr)
+	}
+	if s.KPIs["mttd"].Target != nil {
+		t.Errorf("mttd has no stored KPI but target %v", *s.KPIs["mttd"].Target)
+	}
+	if custom := s.KPIs["phishing_clicks"]; custom.Name != "Phishing Clicks" || custom.Category != "Awareness" || *custom.Target != 5 {
+		t.Errorf("undefined stored KPI = %+v", custom)
+	}
+	if !s.KPIs["coverage"].Archived {
+		t.Error("archived KPI not marked archived")
+	}
+	if rule := s.AlertRules["mttr_critical"]; rule.Operator != ">" || rule.Threshold != 4 || rule.Severity != "critical" {
+		t.Errorf("mttr_critical = %+v", rule)
+	}
+	if rule := s.AlertRules["coverage_warning"]; rule.Operator != "<" || rule.Threshold != 90 {
+		t.Errorf("coverage_warning = %+v", rule)
+	}
+}
+
+func TestExportIsStable(t *testing.T) {
+	collector := metrics.NewMetricsCollector()
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 3, Target: 1.5})
+
+	var first, second bytes.Buffer
+	if err := Export(&first, collector); err != nil {
+		t.Fatal(err)
+	}
+	collector.AddKPI(metrics.KPI{Key: metrics.KPI_MTTR, Value: 2.5, Target: 1.5})
+	if err := Export(&second, collector); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Error("a changed KPI value, which is not state, changed the export")
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(first.Bytes(), &decoded); err != nil || decoded["format_version"] != float64(FormatVersion) {
+		t.Errorf("export is not a versioned JSON document: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/pkg/alerting"
+	"github.com/hallucinaut/secmetrics/pkg/approval"
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+)
+
+// AlertingPath returns the file holding alert silences and
+// acknowledgments next to the store, e.g. store.alerting.json for
+// store.json. It is separate from the store file so the CLI and a running
+// server can both change it without overwriting each other's state.
+func (s *FileStore) AlertingPath() string {
+	ext := filepath.Ext(s.path)
+	return strings.TrimSuffix(s.path, ext) + ".alerting" + ext
+}
+
+// ApprovalPath returns the file holding the reports awaiting or past
+// approval next to the store, e.g. store.approval.json for store.json. Like
+// the alerting state, the CLI and a running server both change it.
+func (s *FileStore) ApprovalPath() string {
+	ext := filepath.Ext(s.path)
+	return strings.TrimSuffix(s.path, ext) + ".approval" + ext
+}
+
+// NarrativesPath returns the file holding the drafted report narratives
+// next to the store, e.g. store.narratives.yaml for store.json. It is kept
+// as plain YAML for analysts to edit.
+func (s *FileStore) NarrativesPath() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".narratives.yaml"
+}
+
+// LoadAlerting reads the alert silences and acknowledgments. A missing
+// file yields an empty state.
+func (s *FileStore) LoadAlerting() (*alerting.State, error) {
+	state := &alerting.State{}
+	if err := s.loadSide(s.AlertingPath(), "alerting state", state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// SaveAlerting writes the alert silences and acknowledgments atomically,
+// encrypted like the store.
+func (s *FileStore) SaveAlerting(state *alerting.State) error {
+	return s.saveSide(s.AlertingPath(), state)
+}
+
+// LoadApproval reads the reports in the approval workflow. A missing file
+// yields an empty state.
+func (s *FileStore) LoadApproval() (*approval.State, error) {
+	state := &approval.State{}
+	if err := s.loadSide(s.ApprovalPath(), "approval state", state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// SaveApproval writes the reports in the approval workflow atomically,
+// encrypted like the store.
+func (s *FileStore) SaveApproval(state *approval.State) error {
+	return s.saveSide(s.ApprovalPath(), state)
+}
+
+// loadSide reads the JSON state kept at path next to the store into v,
+// leaving v alone when the file is missing.
+func (s *FileStore) loadSide(path, what string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", what, err)
+	}
+	if encryption.IsEncrypted(data) {
+		if s.cipher == nil {
+			return fmt.Errorf("%s %s is encrypted but no encryption key is configured", what, path)
+		}
+		if data, err = s.cipher.Decrypt(data); err != nil {
+			return fmt.Errorf("read %s: %w", what, err)
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parse %s: %w", what, err)
+	}
+	return nil
+}
+
+// saveSide writes v as JSON to path atomically, encrypted like the store.
+func (s *FileStore) saveSide(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if s.cipher != nil {
+		if data, err = s.cipher.Encrypt(data); err != nil {
+			return err
+		}
+	}
+	return WriteFileAtomic(path, data, 0o600)
+}
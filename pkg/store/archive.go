@@ -0,0 +1,83 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hallucinaut/secmetrics/pkg/archive"
+)
+
+// ReportsDir returns the directory holding the archived report versions
+// next to the store, e.g. store.reports for store.json. Each version is a
+// file of its own, so archiving a report never rewrites the others.
+func (s *FileStore) ReportsDir() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".reports"
+}
+
+// reportPath returns the file of the archived version with id.
+func (s *FileStore) reportPath(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid report id %q", id)
+	}
+	return filepath.Join(s.ReportsDir(), id+".json"), nil
+}
+
+// SaveReport archives a report version, encrypted like the store. Archived
+// versions are never replaced; a correction is saved as a new version.
+func (s *FileStore) SaveReport(version *archive.Version) error {
+	path, err := s.reportPath(version.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("report %s is already archived", version.ID)
+	}
+	return s.saveSide(path, version)
+}
+
+// LoadReport reads the archived version with id, with its snapshot.
+func (s *FileStore) LoadReport(id string) (*archive.Version, error) {
+	path, err := s.reportPath(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", archive.ErrNotFound, id)
+	}
+	version := &archive.Version{}
+	if err := s.loadSide(path, "archived report", version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// ListReports reads the archived versions, oldest first, without their
+// snapshots. A missing directory yields none.
+func (s *FileStore) ListReports() ([]archive.Version, error) {
+	entries, err := os.ReadDir(s.ReportsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read archived reports: %w", err)
+	}
+	var versions []archive.Version
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		var version archive.Version
+		if err := s.loadSide(filepath.Join(s.ReportsDir(), name), "archived report", &version); err != nil {
+			return nil, err
+		}
+		version.Snapshot = nil
+		versions = append(versions, version)
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].CreatedAt.Before(versions[j].CreatedAt) })
+	return versions, nil
+}
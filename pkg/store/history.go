@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// HistoryConfig moves KPI history out of the store file into a time-series
+// database, for years of frequent samples. KPI definitions, metrics and
+// events stay in the store file.
+type HistoryConfig struct {
+	InfluxDB *InfluxDBConfig `yaml:"influxdb"`
+	// Window is how much recent history is loaded into memory, e.g.
+	// "4368h"; older samples stay in the database only.
+	Window string `yaml:"window"`
+}
+
+// DefaultHistoryWindow covers two quarters, so quarter-over-quarter
+// comparisons have both windows in memory.
+const DefaultHistoryWindow = 2 * 91 * 24 * time.Hour
+
+// historyBackend stores KPI samples outside the store file.
+type historyBackend interface {
+	writeSamples(ctx context.Context, samples []metrics.KPISample) error
+	querySamples(ctx context.Context, start, end time.Time) ([]metrics.KPISample, error)
+	deleteSamples(ctx context.Context, key metrics.KPIKey) error
+}
+
+// History is a time-series database holding KPI history.
+type History struct {
+	backend historyBackend
+	window  time.Duration
+}
+
+// NewHistory validates cfg and returns the configured history database,
+// or nil when history stays in the store file.
+func NewHistory(cfg HistoryConfig, client *http.Client) (*History, error) {
+	if cfg.InfluxDB == nil {
+		return nil, nil
+	}
+	window := DefaultHistoryWindow
+	if cfg.Window != "" {
+		var err error
+		if window, err = time.ParseDuration(cfg.Window); err != nil || window <= 0 {
+			return nil, fmt.Errorf("store history window: invalid duration %q", cfg.Window)
+		}
+	}
+	backend, err := newInfluxDB(*cfg.InfluxDB, client)
+	if err != nil {
+		return nil, err
+	}
+	return &History{backend: backend, window: window}, nil
+}
+
+// Query returns the samples with timestamps in [start, end), including
+// those older than the window.
+func (h *History) Query(ctx context.Context, start, end time.Time) ([]metrics.KPISample, error) {
+	return h.backend.querySamples(ctx, start, end)
+}
+
+// sampleID identifies a sample independently of its time zone.
+type sampleID struct {
+	key   metrics.KPIKey
+	value float64
+	at    int64
+}
+
+func idOf(sample metrics.KPISample) sampleID {
+	return sampleID{key: sample.Key, value: sample.Value, at: sample.Timestamp.UnixNano()}
+}
+
+// SetHistory keeps KPI history in h instead of the store file. Samples
+// already in the file are written to h on the next save.
+func (s *FileStore) SetHistory(h *History) {
+	s.history = h
+}
+
+// History returns the history database, or nil when history is kept in
+// the store file.
+func (s *FileStore) History() *History {
+	return s.history
+}
+
+// TrimHistory returns the samples within the history window ending at
+// now. Without a history database every sample is kept in the store file,
+// so samples is returned unchanged.
+func (s *FileStore) TrimHistory(samples []metrics.KPISample, now time.Time) []metrics.KPISample {
+	if s.history == nil {
+		return samples
+	}
+	cutoff := now.Add(-s.history.window)
+	var recent []metrics.KPISample
+	for _, sample := range samples {
+		if !sample.Timestamp.Before(cutoff) {
+			recent = append(recent, sample)
+		}
+	}
+	return recent
+}
+
+// DeleteHistory deletes a KPI's samples from the history database, if
+// one is configured.
+func (s *FileStore) DeleteHistory(key metrics.KPIKey) error {
+	if s.history == nil {
+		return nil
+	}
+	if err := s.history.backend.deleteSamples(context.Background(), key); err != nil {
+		return fmt.Errorf("delete history of %s: %w", key, err)
+	}
+	return nil
+}
+
+// loadHistory adds the samples within the window to snapshot and
+// remembers them as written. Samples read from the store file are not
+// marked, so the next save moves them to the database.
+func (s *FileStore) loadHistory(snapshot *Snapshot) error {
+	now := time.Now()
+	samples, err := s.history.Query(context.Background(), now.Add(-s.history.window), now.Add(time.Minute))
+	if err != nil {
+		return fmt.Errorf("load history: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written = make(map[sampleID]bool, len(samples))
+	for _, sample := range samples {
+		s.written[idOf(sample)] = true
+	}
+	for _, sample := range snapshot.History {
+		if !s.written[idOf(sample)] {
+			samples = append(samples, sample)
+		}
+	}
+	snapshot.History = samples
+	return nil
+}
+
+// saveHistory writes the samples not yet in the database and returns a
+// copy of snapshot without history, for the store file.
+func (s *FileStore) saveHistory(snapshot *Snapshot) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pending []metrics.KPISample
+	for _, sample := range snapshot.History {
+		if !s.written[idOf(sample)] {
+			pending = append(pending, sample)
+		}
+	}
+	if len(pending) > 0 {
+		if err := s.history.backend.writeSamples(context.Background(), pending); err != nil {
+			return nil, fmt.Errorf("write history: %w", err)
+		}
+	}
+	// Only samples still in memory can be saved again.
+	s.written = make(map[sampleID]bool, len(snapshot.History))
+	for _, sample := range snapshot.History {
+		s.written[idOf(sample)] = true
+	}
+	withoutHistory := *snapshot
+	withoutHistory.History = nil
+	return &withoutHistory, nil
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// InfluxDBConfig stores KPI history in an InfluxDB 2 bucket, one point
+// per sample with the KPI key as a tag.
+type InfluxDBConfig struct {
+	// URL is e.g. https://influxdb.example.com:8086.
+	URL    string `yaml:"url"`
+	Org    string `yaml:"org"`
+	Bucket string `yaml:"bucket"`
+	// TokenEnv names the environment variable holding an API token with
+	// read and write access to the bucket.
+	TokenEnv string `yaml:"token_env"`
+	// Measurement defaults to DefaultInfluxDBMeasurement.
+	Measurement string `yaml:"measurement"`
+}
+
+// DefaultInfluxDBMeasurement is the measurement used when none is
+// configured.
+const DefaultInfluxDBMeasurement = "secmetrics_kpi"
+
+// influxDBBatchSize bounds the points sent in one write request.
+const influxDBBatchSize = 5000
+
+// influxDB reads and writes KPI samples through the InfluxDB 2 HTTP API.
+type influxDB struct {
+	config InfluxDBConfig
+	token  string
+	client *http.Client
+}
+
+func newInfluxDB(cfg InfluxDBConfig, client *http.Client) (*influxDB, error) {
+	if cfg.URL == "" || cfg.Org == "" || cfg.Bucket == "" || cfg.TokenEnv == "" {
+		return nil, fmt.Errorf("store history influxdb: url, org, bucket and token_env are required")
+	}
+	token := secretenv.Get(cfg.TokenEnv)
+	if token == "" {
+		return nil, fmt.Errorf("store history influxdb: environment variable %s is not set", cfg.TokenEnv)
+	}
+	if cfg.Measurement == "" {
+		cfg.Measurement = DefaultInfluxDBMeasurement
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &influxDB{config: cfg, token: token, client: client}, nil
+}
+
+// writeSamples writes samples as line protocol with nanosecond precision.
+// A point with the same key and timestamp overwrites an earlier one, so
+// writes are idempotent.
+func (db *influxDB) writeSamples(ctx context.Context, samples []metrics.KPISample) error {
+	for start := 0; start < len(samples); start += influxDBBatchSize {
+		end := start + influxDBBatchSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		var body bytes.Buffer
+		for _, sample := range samples[start:end] {
+			// Line protocol has no representation of NaN or infinity.
+			if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+				continue
+			}
+			fmt.Fprintf(&body, "%s,key=%s value=%s %d\n", escapeLineProtocol(db.config.Measurement, ", "),
+				escapeLineProtocol(string(sample.Key), ",= "), strconv.FormatFloat(sample.Value, 'g', -1, 64), sample.Timestamp.UnixNano())
+		}
+		query := url.Values{"org": {db.config.Org}, "bucket": {db.config.Bucket}, "precision": {"ns"}}
+		if err := db.do(ctx, "/api/v2/write?"+query.Encode(), "text/plain; charset=utf-8", &body, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// querySamples runs a Flux query for the samples in [start, end).
+func (db *influxDB) querySamples(ctx context.Context, start, end time.Time) ([]metrics.KPISample, error) {
+	flux := fmt.Sprintf(`from(bucket: %s)
+  |> range(start: %s, stop: %s)
+  |> filter(fn: (r) => r._measurement == %s and r._field == "value")
+  |> keep(columns: ["_time", "_value", "key"])
+  |> group()
+  |> sort(columns: ["_time"])`,
+		strconv.Quote(db.config.Bucket), start.UTC().Format(time.RFC3339Nano), end.UTC().Format(time.RFC3339Nano),
+		strconv.Quote(db.config.Measurement))
+	body, err := json.Marshal(map[string]interface{}{
+		"query":   flux,
+		"type":    "flux",
+		"dialect": map[string]interface{}{"header": true, "annotations": []string{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var samples []metrics.KPISample
+	parse := func(r io.Reader) error {
+		samples, err = parseInfluxCSV(r)
+		return err
+	}
+	query := url.Values{"org": {db.config.Org}}
+	if err := db.do(ctx, "/api/v2/query?"+query.Encode(), "application/json", bytes.NewReader(body), parse); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// deleteSamples deletes every sample of key.
+func (db *influxDB) deleteSamples(ctx context.Context, key metrics.KPIKey) error {
+	body, err := json.Marshal(map[string]string{
+		"start":     time.Unix(0, 0).UTC().Format(time.RFC3339),
+		"stop":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		"predicate": fmt.Sprintf("_measurement=%s AND key=%s", strconv.Quote(db.config.Measurement), strconv.Quote(string(key))),
+	})
+	if err != nil {
+		return err
+	}
+	query := url.Values{"org": {db.config.Org}, "bucket": {db.config.Bucket}}
+	return db.do(ctx, "/api/v2/delete?"+query.Encode(), "application/json", bytes.NewReader(body), nil)
+}
+
+// do sends a request to the API and passes a successful response body to
+// read, if given.
+func (db *influxDB) do(ctx context.Context, path, contentType string, body io.Reader, read func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, db.config.URL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+db.token)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/csv, application/json")
+	resp, err := db.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("influxdb: %s: %s", resp.Status, apiErr.Message)
+		}
+		return fmt.Errorf("influxdb: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if read == nil {
+		return nil
+	}
+	return read(resp.Body)
+}
+
+// parseInfluxCSV parses a Flux query result without annotations. Each
+// table in the result starts with its own header row.
+func parseInfluxCSV(r io.Reader) ([]metrics.KPISample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var samples []metrics.KPISample
+	columns := map[string]int{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("influxdb: parse query result: %w", err)
+		}
+		if indexOf(record, "_time") >= 0 {
+			columns = map[string]int{"_time": indexOf(record, "_time"), "_value": indexOf(record, "_value"), "key": indexOf(record, "key")}
+			continue
+		}
+		timeCol, valueCol, keyCol := columns["_time"], columns["_value"], columns["key"]
+		if len(columns) == 0 || timeCol < 0 || valueCol < 0 || keyCol < 0 ||
+			timeCol >= len(record) || valueCol >= len(record) || keyCol >= len(record) {
+			return nil, fmt.Errorf("influxdb: query result lacks _time, _value or key columns")
+		}
+		at, err := time.Parse(time.RFC3339Nano, record[timeCol])
+		if err != nil {
+			return nil, fmt.Errorf("influxdb: parse query result: %w", err)
+		}
+		value, err := strconv.ParseFloat(record[valueCol], 64)
+		if err != nil {
+			return nil, fmt.Errorf("influxdb: parse query result: %w", err)
+		}
+		samples = append(samples, metrics.KPISample{Key: metrics.KPIKey(record[keyCol]), Value: value, Timestamp: at})
+	}
+}
+
+// indexOf returns the index of column in header, or -1.
+func indexOf(header []string, column string) int {
+	for i, name := range header {
+		if name == column {
+			return i
+		}
+	}
+	return -1
+}
+
+// escapeLineProtocol escapes the characters special in a line protocol
+// element.
+func escapeLineProtocol(s, special string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
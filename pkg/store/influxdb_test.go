@@ -0,0 +1,159 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// fakeInfluxDB stores written line protocol points and answers queries
+// with every point, ordered by time, as an unannotated CSV table.
+type fakeInfluxDB struct {
+	mu     sync.Mutex
+	points map[string]string // "key ns" -> value
+	writes int
+}
+
+func (f *fakeInfluxDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Token secret" || r.URL.Query().Get("org") != "sec" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"code":"unauthorized","message":"unauthorized access"}`)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	switch r.URL.Path {
+	case "/api/v2/write":
+		f.writes++
+		for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+			var key, value, ns string
+			fmt.Sscanf(strings.NewReplacer(",key=", " ", " value=", " ").Replace(strings.TrimPrefix(line, "secmetrics_kpi")), "%s %s %s", &key, &value, &ns)
+			f.points[key+" "+ns] = value
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "/api/v2/query":
+		var ids []string
+		for id := range f.points {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return strings.Fields(ids[i])[1] < strings.Fields(ids[j])[1] })
+		fmt.Fprint(w, ",result,table,_time,_value,key\r\n")
+		for _, id := range ids {
+			key, ns, _ := strings.Cut(id, " ")
+			n, _ := strconv.ParseInt(ns, 10, 64)
+			fmt.Fprintf(w, ",_result,0,%s,%s,%s\r\n", time.Unix(0, n).UTC().Format(time.RFC3339Nano), f.points[id], key)
+		}
+	case "/api/v2/delete":
+		var req struct{ Predicate string }
+		json.Unmarshal(body, &req)
+		for id := range f.points {
+			if strings.HasSuffix(req.Predicate, strconv.Quote(strings.Fields(id)[0])) {
+				delete(f.points, id)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestHistoryInInfluxDB(t *testing.T) {
+	db := &fakeInfluxDB{points: make(map[string]string)}
+	api := httptest.NewServer(db)
+	defer api.Close()
+	t.Setenv("INFLUX_TOKEN", "secret")
+
+	now := time.Now().Truncate(time.Second)
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	// A store written before the history database was configured.
+	legacy := NewFileStore(path, nil)
+	if err := legacy.Save(&Snapshot{
+		KPIs: []metrics.KPI{{Key: metrics.KPI_MTTR, Value: 3}},
+		History: []metrics.KPISample{
+			{Key: metrics.KPI_MTTR, Value: 4, Timestamp: now.Add(-2 * time.Hour)},
+			{Key: metrics.KPI_MTTR, Value: 3, Timestamp: now.Add(-time.Hour)},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := NewHistory(HistoryConfig{InfluxDB: &InfluxDBConfig{URL: api.URL, Org: "sec", Bucket: "metrics", TokenEnv: "INFLUX_TOKEN"}}, api.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewFileStore(path, nil)
+	s.SetHistory(history)
+	snapshot, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.History) != 2 {
+		t.Fatalf("loaded %d samples, want the 2 in the file", len(snapshot.History))
+	}
+
+	// The next save moves the file's samples to the database.
+	snapshot.History = append(snapshot.History, metrics.KPISample{Key: metrics.KPI_MTTD, Value: 1.5, Timestamp: now})
+	if err := s.Save(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.points) != 3 {
+		t.Errorf("database holds %d points, want 3", len(db.points))
+	}
+	if file, err := s.loadFile(); err != nil || len(file.History) != 0 || len(file.KPIs) != 1 {
+		t.Errorf("store file = %+v, %v; want the KPI without history", file, err)
+	}
+
+	// Saving again writes nothing new.
+	if err := s.Save(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if db.writes != 1 {
+		t.Errorf("%d writes, want 1", db.writes)
+	}
+
+	reopened := NewFileStore(path, nil)
+	reopened.SetHistory(history)
+	snapshot, err = reopened.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.History) != 3 || !snapshot.History[2].Timestamp.Equal(now) || snapshot.History[2].Value != 1.5 {
+		t.Errorf("reloaded history = %+v", snapshot.History)
+	}
+	if trimmed := reopened.TrimHistory(snapshot.History, now.Add(DefaultHistoryWindow).Add(-90*time.Minute)); len(trimmed) != 2 {
+		t.Errorf("trimmed to %d samples, want 2 within the window", len(trimmed))
+	}
+
+	if err := reopened.DeleteHistory(metrics.KPI_MTTR); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.points) != 1 {
+		t.Errorf("after delete the database holds %d points, want 1", len(db.points))
+	}
+
+	t.Setenv("INFLUX_TOKEN", "wrong")
+	history, _ = NewHistory(HistoryConfig{InfluxDB: &InfluxDBConfig{URL: api.URL, Org: "sec", Bucket: "metrics", TokenEnv: "INFLUX_TOKEN"}}, api.Client())
+	reopened.SetHistory(history)
+	if _, err := reopened.Load(); err == nil || !strings.Contains(err.Error(), "unauthorized access") {
+		t.Errorf("load with a wrong token: %v", err)
+	}
+}
+
+func TestEscapeLineProtocol(t *testing.T) {
+	if got := escapeLineProtocol("vuln age,p95=x y", ",= "); got != `vuln\ age\,p95\=x\ y` {
+		t.Errorf("escaped = %s", got)
+	}
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// Partition returns the store holding one shard's state next to s, e.g.
+// store.shard-2.json for store.json, encrypted like s and sharing its
+// history database.
+func (s *FileStore) Partition(shard int) *FileStore {
+	ext := filepath.Ext(s.path)
+	return &FileStore{path: fmt.Sprintf("%s.shard-%d%s", strings.TrimSuffix(s.path, ext), shard, ext), cipher: s.cipher, history: s.history}
+}
+
+// SnapshotOf returns the state of collector as a snapshot.
+func SnapshotOf(collector *metrics.MetricsCollector) *Snapshot {
+	return &Snapshot{
+		SchemaVersion: CurrentSchemaVersion,
+		Metrics:       collector.GetMetrics(),
+		KPIs:          append(append([]metrics.KPI(nil), collector.GetKPIS()...), collector.GetArchivedKPIs()...),
+		History:       collector.GetHistory(),
+		Incidents:     collector.GetIncidents(),
+		Alerts:        collector.GetAlerts(),
+		Lifecycles:    collector.GetKPILifecycles(),
+		Audit:         collector.GetAuditTrail(),
+		Scores:        collector.GetScoreHistory(),
+		Runs:          collector.GetRuns(),
+	}
+}
+
+// Merge combines snapshots written by different shards. Where snapshots
+// hold the same metric, KPI, incident or alert, the most recently updated
+// one wins; identical history samples and audit entries, score samples of
+// the same time and collection runs of the same ID are kept once, and a
+// KPI's lifecycle is its latest audited transition.
+func Merge(snapshots ...*Snapshot) *Snapshot {
+	merged := &Snapshot{SchemaVersion: CurrentSchemaVersion, Lifecycles: make(map[metrics.KPIKey]metrics.Lifecycle)}
+	metricIndex := make(map[string]int)
+	kpiIndex := make(map[metrics.KPIKey]int)
+	incidentIndex := make(map[string]int)
+	alertIndex := make(map[string]int)
+	samples := make(map[metrics.KPISample]bool)
+	audited := make(map[metrics.AuditEntry]bool)
+	scored := make(map[time.Time]bool)
+	runs := make(map[string]bool)
+
+	for _, snapshot := range snapshots {
+		if snapshot.SavedAt.After(merged.SavedAt) {
+			merged.SavedAt = snapshot.SavedAt
+		}
+		for _, metric := range snapshot.Metrics {
+			if i, ok := metricIndex[metric.ID]; ok && metric.ID != "" {
+				if metric.Timestamp.After(merged.Metrics[i].Timestamp) {
+					merged.Metrics[i] = metric
+				}
+				continue
+			}
+			metricIndex[metric.ID] = len(merged.Metrics)
+			merged.Metrics = append(merged.Metrics, metric)
+		}
+		for _, kpi := range snapshot.KPIs {
+			if i, ok := kpiIndex[kpi.Key]; ok {
+				if kpi.LastUpdated.After(merged.KPIs[i].LastUpdated) {
+					merged.KPIs[i] = kpi
+				}
+				continue
+			}
+			kpiIndex[kpi.Key] = len(merged.KPIs)
+			merged.KPIs = append(merged.KPIs, kpi)
+		}
+		for _, sample := range snapshot.History {
+			if !samples[sample] {
+				samples[sample] = true
+				merged.History = append(merged.History, sample)
+			}
+		}
+		for _, incident := range snapshot.Incidents {
+			if i, ok := incidentIndex[incident.ID]; ok {
+				if latest(incident.DetectedAt, incident.ContainedAt, incident.ResolvedAt).After(
+					latest(merged.Incidents[i].DetectedAt, merged.Incidents[i].ContainedAt, merged.Incidents[i].ResolvedAt)) {
+					merged.Incidents[i] = incident
+				}
+				continue
+			}
+			incidentIndex[incident.ID] = len(merged.Incidents)
+			merged.Incidents = append(merged.Incidents, incident)
+		}
+		for _, alert := range snapshot.Alerts {
+			if i, ok := alertIndex[alert.ID]; ok {
+				if latest(alert.FiredAt, alert.AcknowledgedAt, alert.ResolvedAt, alert.TicketClosedAt).After(
+					latest(merged.Alerts[i].FiredAt, merged.Alerts[i].AcknowledgedAt, merged.Alerts[i].ResolvedAt, merged.Alerts[i].TicketClosedAt)) {
+					merged.Alerts[i] = alert
+				}
+				continue
+			}
+			alertIndex[alert.ID] = len(merged.Alerts)
+			merged.Alerts = append(merged.Alerts, alert)
+		}
+		for key, lifecycle := range snapshot.Lifecycles {
+			merged.Lifecycles[key] = lifecycle
+		}
+		for _, sample := range snapshot.Scores {
+			if !scored[sample.Timestamp] {
+				scored[sample.Timestamp] = true
+				merged.Scores = append(merged.Scores, sample)
+			}
+		}
+		for _, run := range snapshot.Runs {
+			if !runs[run.ID] {
+				runs[run.ID] = true
+				merged.Runs = append(merged.Runs, run)
+			}
+		}
+		for _, entry := range snapshot.Audit {
+			if !audited[entry] {
+				audited[entry] = true
+				merged.Audit = append(merged.Audit, entry)
+			}
+		}
+	}
+	sort.SliceStable(merged.History, func(i, j int) bool {
+		return merged.History[i].Timestamp.Before(merged.History[j].Timestamp)
+	})
+	sort.SliceStable(merged.Scores, func(i, j int) bool {
+		return merged.Scores[i].Timestamp.Before(merged.Scores[j].Timestamp)
+	})
+	sort.SliceStable(merged.Runs, func(i, j int) bool {
+		return merged.Runs[i].Start.Before(merged.Runs[j].Start)
+	})
+	sort.SliceStable(merged.Audit, func(i, j int) bool {
+		return merged.Audit[i].Time.Before(merged.Audit[j].Time)
+	})
+	for _, entry := range merged.Audit {
+		if entry.Action == metrics.AuditKPILifecycle {
+			merged.Lifecycles[metrics.KPIKey(entry.Subject)] = metrics.Lifecycle(entry.To)
+		}
+	}
+	return merged
+}
+
+// latest returns the latest of times, which is when an event last changed.
+func latest(times ...time.Time) time.Time {
+	var t time.Time
+	for _, candidate := range times {
+		if candidate.After(t) {
+			t = candidate
+		}
+	}
+	return t
+}
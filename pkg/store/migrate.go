@@ -0,0 +1,147 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Migration upgrades a stored document by one schema version.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(doc map[string]interface{}) error
+}
+
+// migrations lists every schema migration in order. Version N upgrades a
+// document from schema N-1 to N. Append new migrations; never edit or
+// reorder existing ones.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Add schema version and normalize empty collections",
+		Up: func(doc map[string]interface{}) error {
+			for _, key := range []string{"metrics", "kpis", "history"} {
+				if doc[key] == nil {
+					doc[key] = []interface{}{}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "Add incident and alert collections",
+		Up: func(doc map[string]interface{}) error {
+			for _, key := range []string{"incidents", "alerts"} {
+				if doc[key] == nil {
+					doc[key] = []interface{}{}
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// CurrentSchemaVersion is the schema version written by this build.
+var CurrentSchemaVersion = migrations[len(migrations)-1].Version
+
+// Migrations returns all known migrations.
+func Migrations() []Migration {
+	return migrations
+}
+
+// schemaVersion returns the schema version recorded in doc; documents
+// written before versioning are version 0.
+func schemaVersion(doc map[string]interface{}) int {
+	if v, ok := doc["schema_version"].(float64); ok {
+		return int(v)
+	}
+	return 0
+}
+
+// migrate applies pending migrations to doc in order and returns them.
+func migrate(doc map[string]interface{}) ([]Migration, error) {
+	version := schemaVersion(doc)
+	if version > CurrentSchemaVersion {
+		return nil, fmt.Errorf("store schema version %d is newer than supported version %d; upgrade secmetrics", version, CurrentSchemaVersion)
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := m.Up(doc); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		doc["schema_version"] = m.Version
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// MigrationStatus describes the schema state of a store.
+type MigrationStatus struct {
+	Exists         bool
+	Version        int
+	CurrentVersion int
+	Pending        []Migration
+}
+
+// Status reports the store's schema version and pending migrations.
+func (s *FileStore) Status() (*MigrationStatus, error) {
+	status := &MigrationStatus{CurrentVersion: CurrentSchemaVersion}
+	doc, err := s.readDocument()
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return status, nil
+	}
+
+	status.Exists = true
+	status.Version = schemaVersion(doc)
+	for _, m := range migrations {
+		if m.Version > status.Version {
+			status.Pending = append(status.Pending, m)
+		}
+	}
+	return status, nil
+}
+
+// Migrate applies pending migrations and rewrites the store, keeping a
+// backup of the previous file alongside it. It returns the applied
+// migrations; none are applied to a missing store.
+func (s *FileStore) Migrate() ([]Migration, error) {
+	raw, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read store: %w", err)
+	}
+
+	doc, err := s.decodeDocument(raw)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := migrate(doc)
+	if err != nil || len(applied) == 0 {
+		return applied, err
+	}
+
+	backup := fmt.Sprintf("%s.bak-v%d", s.path, applied[0].Version-1)
+	if err := os.WriteFile(backup, raw, 0o600); err != nil {
+		return nil, fmt.Errorf("backup store: %w", err)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.write(data); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
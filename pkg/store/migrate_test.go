@@ -0,0 +1,161 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+)
+
+// versionZero is a store document written before schema versioning.
+const versionZero = `{"metrics": null, "kpis": [{"key": "mttr", "value": 3}]}`
+
+// readStoreDocument reads and decodes the store file at path.
+func readStoreDocument(t *testing.T, path string, cipher *encryption.Cipher) map[string]interface{} {
+	t.Helper()
+	doc, err := NewFileStore(path, cipher).readDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestMigrateVersionZero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	if err := os.WriteFile(path, []byte(versionZero), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFileStore(path, nil)
+
+	status, err := s.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Exists || status.Version != 0 || status.CurrentVersion != CurrentSchemaVersion || len(status.Pending) != len(migrations) {
+		t.Errorf("status of a version 0 store: %+v", status)
+	}
+
+	applied, err := s.Migrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations) || applied[0].Version != 1 {
+		t.Errorf("applied %+v", applied)
+	}
+	doc := readStoreDocument(t, path, nil)
+	if schemaVersion(doc) != CurrentSchemaVersion {
+		t.Errorf("migrated to version %d, want %d", schemaVersion(doc), CurrentSchemaVersion)
+	}
+	for _, key := range []string{"metrics", "history", "incidents", "alerts"} {
+		if list, ok := doc[key].([]interface{}); !ok || len(list) != 0 {
+			t.Errorf("%s = %#v, want an empty list", key, doc[key])
+		}
+	}
+	if kpis, _ := doc["kpis"].([]interface{}); len(kpis) != 1 {
+		t.Errorf("kpis = %#v", doc["kpis"])
+	}
+
+	// The previous file is kept as it was
+	backup, err := os.ReadFile(path + ".bak-v0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != versionZero {
+		t.Errorf("backup = %q", backup)
+	}
+
+	// A second run finds nothing to do and leaves the store alone
+	migrated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := s.Migrate(); err != nil || len(applied) != 0 {
+		t.Errorf("second run applied %+v, %v", applied, err)
+	}
+	if again, err := os.ReadFile(path); err != nil || !bytes.Equal(again, migrated) {
+		t.Errorf("second run rewrote the store: %q, %v", again, err)
+	}
+	if backups, _ := filepath.Glob(path + ".bak-*"); len(backups) != 1 {
+		t.Errorf("backups after two runs: %v", backups)
+	}
+	if status, err := s.Status(); err != nil || status.Version != CurrentSchemaVersion || len(status.Pending) != 0 {
+		t.Errorf("status after migrating: %+v, %v", status, err)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	newer, _ := json.Marshal(map[string]interface{}{"schema_version": CurrentSchemaVersion + 1, "metrics": []interface{}{}})
+	if err := os.WriteFile(path, newer, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFileStore(path, nil)
+
+	if _, err := s.Migrate(); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("migrating a newer store: %v", err)
+	}
+	if _, err := s.Load(); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("loading a newer store: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, newer) {
+		t.Errorf("newer store changed: %q, %v", data, err)
+	}
+	if backups, _ := filepath.Glob(path + ".bak-*"); len(backups) != 0 {
+		t.Errorf("backed up a store that was not migrated: %v", backups)
+	}
+	if status, err := s.Status(); err != nil || status.Version != CurrentSchemaVersion+1 || len(status.Pending) != 0 {
+		t.Errorf("status of a newer store: %+v, %v", status, err)
+	}
+}
+
+func TestMigrateEncryptedStore(t *testing.T) {
+	cipher, err := encryption.NewCipher([]byte(strings.Repeat("k", encryption.KeySize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "store.json")
+	raw, err := cipher.Encrypt([]byte(versionZero))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileStore(path, nil).Migrate(); err == nil {
+		t.Error("migrated an encrypted store without a key")
+	}
+	if applied, err := NewFileStore(path, cipher).Migrate(); err != nil || len(applied) != len(migrations) {
+		t.Fatalf("applied %+v, %v", applied, err)
+	}
+
+	// The store is rewritten encrypted, and the backup is the encrypted
+	// file as it was
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !encryption.IsEncrypted(data) || strings.Contains(string(data), "mttr") {
+		t.Errorf("migrated store written in plaintext: %q", data)
+	}
+	if doc := readStoreDocument(t, path, cipher); schemaVersion(doc) != CurrentSchemaVersion {
+		t.Errorf("migrated to version %d", schemaVersion(doc))
+	}
+	if backup, err := os.ReadFile(path + ".bak-v0"); err != nil || !bytes.Equal(backup, raw) {
+		t.Errorf("backup = %q, %v", backup, err)
+	}
+}
+
+func TestMigrateMissingStore(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "store.json"), nil)
+	if applied, err := s.Migrate(); err != nil || applied != nil {
+		t.Errorf("migrating a missing store: %+v, %v", applied, err)
+	}
+	if status, err := s.Status(); err != nil || status.Exists || len(status.Pending) != 0 {
+		t.Errorf("status of a missing store: %+v, %v", status, err)
+	}
+}
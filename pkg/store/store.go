@@ -0,0 +1,279 @@
+// Package store provides persistent storage of collected metrics.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+// Config configures the local metrics store.
+type Config struct {
+	Path    string        `yaml:"path"`
+	History HistoryConfig `yaml:"history"`
+}
+
+// DefaultPath is the store file used when none is configured.
+const DefaultPath = "secmetrics-store.json"
+
+// Snapshot represents the persisted collector state.
+type Snapshot struct {
+	SchemaVersion int                                  `json:"schema_version"`
+	SavedAt       time.Time                            `json:"saved_at"`
+	Metrics       []metrics.SecurityMetric             `json:"metrics"`
+	KPIs          []metrics.KPI                        `json:"kpis"`
+	History       []metrics.KPISample                  `json:"history"`
+	Incidents     []metrics.Incident                   `json:"incidents"`
+	Alerts        []metrics.Alert                      `json:"alerts"`
+	Lifecycles    map[metrics.KPIKey]metrics.Lifecycle `json:"lifecycles,omitempty"`
+	Audit         []metrics.AuditEntry                 `json:"audit,omitempty"`
+	Scores        []metrics.ScoreSample                `json:"scores,omitempty"`
+	Runs          []metrics.CollectionRun              `json:"runs,omitempty"`
+}
+
+// ArchivedKPIs returns the archived KPIs in the snapshot.
+func (s *Snapshot) ArchivedKPIs() []metrics.KPI {
+	var archived []metrics.KPI
+	for _, kpi := range s.KPIs {
+		if kpi.IsArchived() {
+			archived = append(archived, kpi)
+		}
+	}
+	return archived
+}
+
+// inUTC returns a copy of the snapshot with every timestamp in UTC, so a
+// store reads the same whichever zone the instance writing it runs in.
+func (s *Snapshot) inUTC() *Snapshot {
+	utc := *s
+	utc.SavedAt = s.SavedAt.UTC()
+	utc.Metrics = mapItems(s.Metrics, func(metric metrics.SecurityMetric) metrics.SecurityMetric {
+		metric.Timestamp = metric.Timestamp.UTC()
+		return metric
+	})
+	utc.KPIs = mapItems(s.KPIs, func(kpi metrics.KPI) metrics.KPI {
+		kpi.LastUpdated, kpi.ArchivedAt = kpi.LastUpdated.UTC(), kpi.ArchivedAt.UTC()
+		return kpi
+	})
+	utc.History = mapItems(s.History, func(sample metrics.KPISample) metrics.KPISample {
+		sample.Timestamp = sample.Timestamp.UTC()
+		return sample
+	})
+	utc.Incidents = mapItems(s.Incidents, func(incident metrics.Incident) metrics.Incident {
+		incident.DetectedAt, incident.ContainedAt, incident.ResolvedAt = incident.DetectedAt.UTC(), incident.ContainedAt.UTC(), incident.ResolvedAt.UTC()
+		return incident
+	})
+	utc.Alerts = mapItems(s.Alerts, func(alert metrics.Alert) metrics.Alert {
+		alert.FiredAt, alert.AcknowledgedAt, alert.ResolvedAt = alert.FiredAt.UTC(), alert.AcknowledgedAt.UTC(), alert.ResolvedAt.UTC()
+		alert.TicketClosedAt = alert.TicketClosedAt.UTC()
+		return alert
+	})
+	utc.Audit = mapItems(s.Audit, func(entry metrics.AuditEntry) metrics.AuditEntry {
+		entry.Time = entry.Time.UTC()
+		return entry
+	})
+	utc.Scores = mapItems(s.Scores, func(sample metrics.ScoreSample) metrics.ScoreSample {
+		sample.Timestamp = sample.Timestamp.UTC()
+		return sample
+	})
+	utc.Runs = mapItems(s.Runs, func(run metrics.CollectionRun) metrics.CollectionRun {
+		run.Start = run.Start.UTC()
+		return run
+	})
+	return &utc
+}
+
+// mapItems returns a copy of items with f applied to each, nil for nil.
+func mapItems[T any](items []T, f func(T) T) []T {
+	if items == nil {
+		return nil
+	}
+	result := make([]T, len(items))
+	for i, item := range items {
+		result[i] = f(item)
+	}
+	return result
+}
+
+// FileStore stores snapshots in a single JSON file, optionally encrypted,
+// and KPI history in a time-series database if one is set.
+type FileStore struct {
+	path    string
+	cipher  *encryption.Cipher
+	history *History
+
+	mu sync.Mutex
+	// written holds the in-memory samples already in the history
+	// database.
+	written map[sampleID]bool
+}
+
+// NewFileStore creates a file store. When cipher is nil the file is
+// written in plaintext.
+func NewFileStore(path string, cipher *encryption.Cipher) *FileStore {
+	if path == "" {
+		path = DefaultPath
+	}
+	return &FileStore{path: path, cipher: cipher}
+}
+
+// Path returns the store file path.
+func (s *FileStore) Path() string {
+	return s.path
+}
+
+// Load reads the stored snapshot. A missing file yields an empty snapshot.
+// Stores written with an older schema are migrated in memory; call
+// Migrate to persist the upgrade. With a history database, the snapshot
+// holds the samples within its window.
+func (s *FileStore) Load() (*Snapshot, error) {
+	snapshot, err := s.loadFile()
+	if err != nil || s.history == nil {
+		return snapshot, err
+	}
+	if err := s.loadHistory(snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// loadFile reads the snapshot in the store file.
+func (s *FileStore) loadFile() (*Snapshot, error) {
+	doc, err := s.readDocument()
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return &Snapshot{SchemaVersion: CurrentSchemaVersion}, nil
+	}
+	if _, err := migrate(doc); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("parse store: %w", err)
+	}
+	return snapshot, nil
+}
+
+// readDocument reads the store as a generic JSON document, returning nil
+// when the store does not exist.
+func (s *FileStore) readDocument() (map[string]interface{}, error) {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read store: %w", err)
+	}
+	return s.decodeDocument(raw)
+}
+
+// decodeDocument decrypts raw if needed and parses it as JSON.
+func (s *FileStore) decodeDocument(raw []byte) (map[string]interface{}, error) {
+	data := raw
+	if encryption.IsEncrypted(raw) {
+		if s.cipher == nil {
+			return nil, fmt.Errorf("store %s is encrypted but no encryption key is configured", s.path)
+		}
+		var err error
+		data, err = s.cipher.Decrypt(raw)
+		if err != nil {
+			return nil, fmt.Errorf("read store: %w", err)
+		}
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse store: %w", err)
+	}
+	return doc, nil
+}
+
+// Save writes the snapshot atomically with owner-only permissions. With a
+// history database, new samples are written to it instead of the file.
+func (s *FileStore) Save(snapshot *Snapshot) error {
+	snapshot = snapshot.inUTC()
+	snapshot.SchemaVersion = CurrentSchemaVersion
+	snapshot.SavedAt = time.Now().UTC()
+	if s.history != nil {
+		var err error
+		if snapshot, err = s.saveHistory(snapshot); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.write(data)
+}
+
+// write encrypts data if configured and writes it atomically.
+func (s *FileStore) write(data []byte) error {
+	if s.cipher != nil {
+		var err error
+		data, err = s.cipher.Encrypt(data)
+		if err != nil {
+			return err
+		}
+	}
+	return WriteFileAtomic(s.path, data, 0o600)
+}
+
+// LoadInto restores the stored state into collector.
+func (s *FileStore) LoadInto(collector *metrics.MetricsCollector) error {
+	snapshot, err := s.Load()
+	if err != nil {
+		return err
+	}
+	collector.RestoreLifecycles(snapshot.Lifecycles, snapshot.Audit)
+	collector.RestoreScores(snapshot.Scores)
+	collector.RestoreRuns(snapshot.Runs)
+	collector.Restore(snapshot.Metrics, snapshot.KPIs, snapshot.History)
+	collector.RestoreEvents(snapshot.Incidents, snapshot.Alerts)
+	return nil
+}
+
+// SaveFrom persists the collector state.
+func (s *FileStore) SaveFrom(collector *metrics.MetricsCollector) error {
+	return s.Save(SnapshotOf(collector))
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it over path.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/pkg/archive"
+	"github.com/hallucinaut/secmetrics/pkg/encryption"
+	"github.com/hallucinaut/secmetrics/pkg/metrics"
+)
+
+func TestSaveWritesUTC(t *testing.T) {
+	berlin := time.FixedZone("CEST", 2*60*60)
+	detected := time.Date(2026, 10, 1, 11, 30, 0, 0, berlin)
+	snapshot := &Snapshot{
+		History:   []metrics.KPISample{{Key: metrics.KPI_MTTR, Value: 4, Timestamp: detected}},
+		Incidents: []metrics.Incident{{ID: "INC-1", DetectedAt: detected}},
+	}
+	path := filepath.Join(t.TempDir(), "store.json")
+	if err := NewFileStore(path, nil).Save(snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"2026-10-01T09:30:00Z"`) || strings.Contains(string(data), "+02:00") {
+		t.Errorf("store not written in UTC:\n%s", data)
+	}
+	if snapshot.Incidents[0].DetectedAt.Location() != berlin {
+		t.Error("Save changed the caller's snapshot")
+	}
+	loaded, err := NewFileStore(path, nil).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Incidents[0].DetectedAt.Equal(detected) || loaded.Metrics != nil {
+		t.Errorf("loaded %+v", loaded)
+	}
+}
+
+func TestArchivedReports(t *testing.T) {
+	metricsStore := NewFileStore(filepath.Join(t.TempDir(), "store.json"), nil)
+	if versions, err := metricsStore.ListReports(); err != nil || len(versions) != 0 {
+		t.Fatalf("empty archive: %v, %v", versions, err)
+	}
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	version := &archive.Version{ID: "rpt-20261001090000-executive", Type: "executive", Version: 1, CreatedAt: at, Snapshot: []byte(`{"ID":"rpt-20261001090000"}`)}
+	if err := metricsStore.SaveReport(version); err != nil {
+		t.Fatal(err)
+	}
+	if err := metricsStore.SaveReport(version); err == nil {
+		t.Error("replaced an archived version")
+	}
+	if err := metricsStore.SaveReport(&archive.Version{ID: "../escape"}); err == nil {
+		t.Error("archived a version outside the reports directory")
+	}
+	if _, err := metricsStore.LoadReport("missing"); !errors.Is(err, archive.ErrNotFound) {
+		t.Errorf("load missing version: %v", err)
+	}
+	loaded, err := metricsStore.LoadReport(version.ID)
+	if err != nil || !strings.Contains(string(loaded.Snapshot), `"rpt-20261001090000"`) {
+		t.Fatalf("loaded %+v, %v", loaded, err)
+	}
+	versions, err := metricsStore.ListReports()
+	if err != nil || len(versions) != 1 || versions[0].Snapshot != nil {
+		t.Errorf("listed %+v, %v", versions, err)
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	cipher, err := encryption.NewCipher([]byte(strings.Repeat("k", encryption.KeySize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "store.json")
+	snapshot := &Snapshot{Incidents: []metrics.Incident{{ID: "INC-1"}}}
+
+	// A plaintext store loads without a key, and with one, so encryption
+	// can be turned on for an existing store
+	if err := NewFileStore(path, nil).Save(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*encryption.Cipher{nil, cipher} {
+		if loaded, err := NewFileStore(path, c).Load(); err != nil || len(loaded.Incidents) != 1 {
+			t.Fatalf("plaintext store with cipher %v: %+v, %v", c != nil, loaded, err)
+		}
+	}
+
+	if err := NewFileStore(path, cipher).Save(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !encryption.IsEncrypted(data) || strings.Contains(string(data), "INC-1") {
+		t.Errorf("store written in plaintext: %q", data)
+	}
+	if loaded, err := NewFileStore(path, cipher).Load(); err != nil || len(loaded.Incidents) != 1 {
+		t.Errorf("encrypted store: %+v, %v", loaded, err)
+	}
+	if _, err := NewFileStore(path, nil).Load(); err == nil {
+		t.Error("loaded an encrypted store without a key")
+	}
+}
@@ -0,0 +1,310 @@
+// Package ticketing opens tracking tickets in Jira or ServiceNow for KPIs
+// that breach their thresholds, and reports when those tickets are closed.
+package ticketing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/secmetrics/internal/secretenv"
+)
+
+// DefaultTimeout bounds creating a ticket or checking whether it is
+// closed.
+const DefaultTimeout = 30 * time.Second
+
+// Config configures the ticketing system breached KPIs are tracked in.
+// Only one of Jira and ServiceNow may be configured.
+type Config struct {
+	Jira       *JiraConfig       `yaml:"jira"`
+	ServiceNow *ServiceNowConfig `yaml:"servicenow"`
+	// Severity is the lowest threshold band opening a ticket: "warning"
+	// (default) or "critical".
+	Severity string `yaml:"severity"`
+	// Client carries the proxy and trusted CAs of the top-level http
+	// section; it is set by the caller rather than from the config file.
+	Client *http.Client `yaml:"-"`
+}
+
+// JiraConfig configures creating issues through the Jira REST API.
+type JiraConfig struct {
+	// URL is the Jira base URL, e.g. https://acme.atlassian.net.
+	URL string `yaml:"url"`
+	// Username authenticates with the API token in TokenEnv; without a
+	// username the token is sent as a bearer token, as for Jira Data
+	// Center personal access tokens.
+	Username string `yaml:"username"`
+	// TokenEnv names the environment variable holding the API token.
+	TokenEnv string `yaml:"token_env"`
+	// Project is the key of the project issues are created in.
+	Project string `yaml:"project"`
+	// IssueType defaults to Task.
+	IssueType string   `yaml:"issue_type"`
+	Labels    []string `yaml:"labels"`
+}
+
+// ServiceNowConfig configures creating records through the ServiceNow
+// Table API.
+type ServiceNowConfig struct {
+	// URL is the instance URL, e.g. https://acme.service-now.com.
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	// PasswordEnv names the environment variable holding the password.
+	PasswordEnv string `yaml:"password_env"`
+	// Table receives one record per ticket (default incident).
+	Table string `yaml:"table"`
+	// AssignmentGroup is the sys_id or name of the group records are
+	// assigned to.
+	AssignmentGroup string `yaml:"assignment_group"`
+}
+
+// Ticket is a tracking ticket for a breached KPI.
+type Ticket struct {
+	Summary     string
+	Description string
+	// Severity is the breached band, "warning" or "critical".
+	Severity string
+}
+
+// Tracker creates tickets in the configured system and checks whether
+// they are closed.
+type Tracker struct {
+	system   string
+	critical bool
+	client   *http.Client
+	jira     *jira
+	sn       *serviceNow
+}
+
+// New creates the tracker configured in cfg. It returns nil when no
+// ticketing system is configured.
+func New(cfg Config) (*Tracker, error) {
+	if cfg.Jira == nil && cfg.ServiceNow == nil {
+		return nil, nil
+	}
+	if cfg.Jira != nil && cfg.ServiceNow != nil {
+		return nil, fmt.Errorf("ticketing: configure either jira or servicenow, not both")
+	}
+	t := &Tracker{client: cfg.Client}
+	switch cfg.Severity {
+	case "", "warning":
+	case "critical":
+		t.critical = true
+	default:
+		return nil, fmt.Errorf("ticketing: invalid severity %q (want warning or critical)", cfg.Severity)
+	}
+	if t.client == nil {
+		t.client = &http.Client{Timeout: DefaultTimeout}
+	}
+	if j := cfg.Jira; j != nil {
+		if j.URL == "" || j.TokenEnv == "" || j.Project == "" {
+			return nil, fmt.Errorf("ticketing jira: url, token_env and project are required")
+		}
+		token := secretenv.Get(j.TokenEnv)
+		if token == "" {
+			return nil, fmt.Errorf("ticketing jira: environment variable %s is not set", j.TokenEnv)
+		}
+		issueType := j.IssueType
+		if issueType == "" {
+			issueType = "Task"
+		}
+		t.system = "jira"
+		t.jira = &jira{config: *j, issueType: issueType, token: token, apiURL: strings.TrimRight(j.URL, "/") + "/rest/api/2/issue"}
+	}
+	if sn := cfg.ServiceNow; sn != nil {
+		if sn.URL == "" || sn.Username == "" || sn.PasswordEnv == "" {
+			return nil, fmt.Errorf("ticketing servicenow: url, username and password_env are required")
+		}
+		password := secretenv.Get(sn.PasswordEnv)
+		if password == "" {
+			return nil, fmt.Errorf("ticketing servicenow: environment variable %s is not set", sn.PasswordEnv)
+		}
+		table := sn.Table
+		if table == "" {
+			table = "incident"
+		}
+		t.system = "servicenow"
+		t.sn = &serviceNow{config: *sn, password: password,
+			tableURL: strings.TrimRight(sn.URL, "/") + "/api/now/table/" + url.PathEscape(table)}
+	}
+	return t, nil
+}
+
+// System returns "jira" or "servicenow".
+func (t *Tracker) System() string {
+	return t.system
+}
+
+// Accepts reports whether a breach of the severity band opens a ticket.
+func (t *Tracker) Accepts(severity string) bool {
+	return severity == "critical" || (severity == "warning" && !t.critical)
+}
+
+// Create opens a ticket and returns its ID: the issue key in Jira, e.g.
+// SEC-42, or the record number in ServiceNow, e.g. INC0010023.
+func (t *Tracker) Create(ctx context.Context, ticket Ticket) (string, error) {
+	if t.jira != nil {
+		return t.jira.create(ctx, t.client, ticket)
+	}
+	return t.sn.create(ctx, t.client, ticket)
+}
+
+// Closed reports whether the ticket with id is done in Jira, or resolved
+// or closed in ServiceNow.
+func (t *Tracker) Closed(ctx context.Context, id string) (bool, error) {
+	if t.jira != nil {
+		return t.jira.closed(ctx, t.client, id)
+	}
+	return t.sn.closed(ctx, t.client, id)
+}
+
+// jira creates issues through the Jira REST API.
+type jira struct {
+	config    JiraConfig
+	issueType string
+	token     string
+	apiURL    string
+}
+
+func (j *jira) authorize(req *http.Request) {
+	if j.config.Username != "" {
+		req.SetBasicAuth(j.config.Username, j.token)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+j.token)
+}
+
+func (j *jira) create(ctx context.Context, client *http.Client, ticket Ticket) (string, error) {
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": j.config.Project},
+		"issuetype":   map[string]string{"name": j.issueType},
+		"summary":     ticket.Summary,
+		"description": ticket.Description,
+		"labels":      append(append([]string(nil), j.config.Labels...), "secmetrics", ticket.Severity),
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := call(ctx, client, http.MethodPost, j.apiURL, j.authorize, map[string]interface{}{"fields": fields}, &created); err != nil {
+		return "", err
+	}
+	if created.Key == "" {
+		return "", fmt.Errorf("jira returned no issue key")
+	}
+	return created.Key, nil
+}
+
+func (j *jira) closed(ctx context.Context, client *http.Client, id string) (bool, error) {
+	var issue struct {
+		Fields struct {
+			Status struct {
+				StatusCategory struct {
+					Key string `json:"key"`
+				} `json:"statusCategory"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := call(ctx, client, http.MethodGet, j.apiURL+"/"+url.PathEscape(id)+"?fields=status", j.authorize, nil, &issue); err != nil {
+		return false, err
+	}
+	return issue.Fields.Status.StatusCategory.Key == "done", nil
+}
+
+// serviceNow creates records through the Table API.
+type serviceNow struct {
+	config   ServiceNowConfig
+	password string
+	tableURL string
+}
+
+// ServiceNow incident states of tickets no longer worked on: resolved,
+// closed and canceled.
+var serviceNowClosedStates = map[string]bool{"6": true, "7": true, "8": true}
+
+func (s *serviceNow) authorize(req *http.Request) {
+	req.SetBasicAuth(s.config.Username, s.password)
+}
+
+func (s *serviceNow) create(ctx context.Context, client *http.Client, ticket Ticket) (string, error) {
+	// Impact and urgency of 2 make a high priority incident, 3 a moderate
+	// one.
+	level := "3"
+	if ticket.Severity == "critical" {
+		level = "2"
+	}
+	row := map[string]string{
+		"short_description": ticket.Summary,
+		"description":       ticket.Description,
+		"impact":            level,
+		"urgency":           level,
+	}
+	if s.config.AssignmentGroup != "" {
+		row["assignment_group"] = s.config.AssignmentGroup
+	}
+	var created struct {
+		Result struct {
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+	if err := call(ctx, client, http.MethodPost, s.tableURL, s.authorize, row, &created); err != nil {
+		return "", err
+	}
+	if created.Result.Number == "" {
+		return "", fmt.Errorf("servicenow returned no record number")
+	}
+	return created.Result.Number, nil
+}
+
+func (s *serviceNow) closed(ctx context.Context, client *http.Client, id string) (bool, error) {
+	query := url.Values{"sysparm_query": {"number=" + id}, "sysparm_fields": {"state"}, "sysparm_limit": {"1"}}
+	var found struct {
+		Result []struct {
+			State string `json:"state"`
+		} `json:"result"`
+	}
+	if err := call(ctx, client, http.MethodGet, s.tableURL+"?"+query.Encode(), s.authorize, nil, &found); err != nil {
+		return false, err
+	}
+	if len(found.Result) == 0 {
+		return false, fmt.Errorf("servicenow has no record %s", id)
+	}
+	return serviceNowClosedStates[found.Result[0].State], nil
+}
+
+// call sends in as JSON, when not nil, and decodes the response into out.
+func call(ctx context.Context, client *http.Client, method, target string, authorize func(*http.Request), in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	authorize(req)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, string(data))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
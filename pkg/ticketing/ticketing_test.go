@@ -0,0 +1,120 @@
+package ticketing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJira(t *testing.T) {
+	var fields map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, token, _ := r.BasicAuth(); user != "bot@acme.example" || token != "tok" {
+			http.Error(w, "denied", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			var body struct {
+				Fields map[string]interface{} `json:"fields"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			fields = body.Fields
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"SEC-42"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/SEC-42":
+			w.Write([]byte(`{"fields":{"status":{"statusCategory":{"key":"done"}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("JIRA_TOKEN", "tok")
+
+	tracker, err := New(Config{Client: srv.Client(), Jira: &JiraConfig{
+		URL: srv.URL, Username: "bot@acme.example", TokenEnv: "JIRA_TOKEN", Project: "SEC", Labels: []string{"kpi"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := tracker.Create(context.Background(), Ticket{Summary: "MTTR breached critical threshold", Description: "MTTR is 30 hours", Severity: "critical"})
+	if err != nil || id != "SEC-42" {
+		t.Fatalf("Create = %q, %v; want SEC-42", id, err)
+	}
+	if fields["summary"] != "MTTR breached critical threshold" || fields["issuetype"].(map[string]interface{})["name"] != "Task" {
+		t.Errorf("fields = %v", fields)
+	}
+	if labels, _ := json.Marshal(fields["labels"]); string(labels) != `["kpi","secmetrics","critical"]` {
+		t.Errorf("labels = %s", labels)
+	}
+	if closed, err := tracker.Closed(context.Background(), "SEC-42"); err != nil || !closed {
+		t.Errorf("Closed = %v, %v; want true", closed, err)
+	}
+}
+
+func TestServiceNow(t *testing.T) {
+	var row map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/now/table/incident" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&row)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"result":{"number":"INC0010023","sys_id":"abc"}}`))
+			return
+		}
+		if r.URL.Query().Get("sysparm_query") != "number=INC0010023" {
+			w.Write([]byte(`{"result":[]}`))
+			return
+		}
+		w.Write([]byte(`{"result":[{"state":"2"}]}`))
+	}))
+	defer srv.Close()
+	t.Setenv("SN_PASSWORD", "pw")
+
+	tracker, err := New(Config{Client: srv.Client(), Severity: "critical", ServiceNow: &ServiceNowConfig{
+		URL: srv.URL, Username: "secmetrics", PasswordEnv: "SN_PASSWORD", AssignmentGroup: "secops",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracker.Accepts("warning") || !tracker.Accepts("critical") {
+		t.Error("severity critical should only accept critical breaches")
+	}
+	id, err := tracker.Create(context.Background(), Ticket{Summary: "Coverage breached critical threshold", Severity: "critical"})
+	if err != nil || id != "INC0010023" {
+		t.Fatalf("Create = %q, %v; want INC0010023", id, err)
+	}
+	if row["impact"] != "2" || row["assignment_group"] != "secops" {
+		t.Errorf("row = %v", row)
+	}
+	if closed, err := tracker.Closed(context.Background(), id); err != nil || closed {
+		t.Errorf("Closed = %v, %v; want an open incident", closed, err)
+	}
+	if _, err := tracker.Closed(context.Background(), "INC0000001"); err == nil {
+		t.Error("Closed of an unknown record should fail")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if tracker, err := New(Config{}); tracker != nil || err != nil {
+		t.Errorf("New without a system = %v, %v; want nil", tracker, err)
+	}
+	t.Setenv("TOKEN", "tok")
+	tests := map[string]Config{
+		"not both":             {Jira: &JiraConfig{}, ServiceNow: &ServiceNowConfig{}},
+		"invalid severity":     {Severity: "high", Jira: &JiraConfig{URL: "https://jira", TokenEnv: "TOKEN", Project: "SEC"}},
+		"project are required": {Jira: &JiraConfig{URL: "https://jira", TokenEnv: "TOKEN"}},
+		"is not set":           {ServiceNow: &ServiceNowConfig{URL: "https://sn", Username: "u", PasswordEnv: "UNSET_PASSWORD"}},
+	}
+	for want, cfg := range tests {
+		if _, err := New(cfg); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("New(%s) error = %v, want %q", want, err, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package update checks a release endpoint for new secmetrics builds and
+// installs them in place after verifying their signature and checksum.
+//
+// The endpoint serves a JSON release manifest and, at the manifest URL plus
+// ".sig", the base64 Ed25519 signature of the manifest bytes. The manifest
+// lists a binary per platform with its SHA-256 checksum, so a verified
+// manifest vouches for every binary it names.
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBinarySize bounds the size of a downloaded binary.
+const MaxBinarySize = 256 << 20
+
+// maxManifestSize bounds the size of the manifest and its signature.
+const maxManifestSize = 1 << 20
+
+// Config configures self-update.
+type Config struct {
+	// URL is the release manifest, e.g.
+	// https://releases.example.com/secmetrics/latest.json.
+	URL string `yaml:"url"`
+	// PublicKey is the base64 Ed25519 public key manifests are signed with.
+	PublicKey string `yaml:"public_key"`
+}
+
+// Release is a release manifest.
+type Release struct {
+	Version  string   `json:"version"`
+	Binaries []Binary `json:"binaries"`
+}
+
+// Binary is a build for one platform. URL may be relative to the manifest.
+type Binary struct {
+	OS     string `json:"os"`
+	Arch   string `json:"arch"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// Binary returns the build for goos and goarch.
+func (r *Release) Binary(goos, goarch string) (Binary, bool) {
+	for _, b := range r.Binaries {
+		if b.OS == goos && b.Arch == goarch {
+			return b, true
+		}
+	}
+	return Binary{}, false
+}
+
+// Updater fetches and verifies releases.
+type Updater struct {
+	manifest *url.URL
+	key      ed25519.PublicKey
+	client   *http.Client
+}
+
+// New creates an updater from cfg. Both the manifest URL and the public
+// key are required: unsigned releases are never installed.
+func New(cfg Config, client *http.Client) (*Updater, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("update: url is required")
+	}
+	manifest, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("update url: %w", err)
+	}
+	if cfg.PublicKey == "" {
+		return nil, errors.New("update: public_key is required")
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("update: public_key must be a base64 %d-byte Ed25519 key", ed25519.PublicKeySize)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	return &Updater{manifest: manifest, key: key, client: client}, nil
+}
+
+// Check fetches the release manifest and verifies its signature.
+func (u *Updater) Check(ctx context.Context) (*Release, error) {
+	manifest, err := u.get(ctx, u.manifest.String(), maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("update: fetch manifest: %w", err)
+	}
+	encoded, err := u.get(ctx, u.manifest.String()+".sig", maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("update: fetch signature: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return nil, fmt.Errorf("update: decode signature: %w", err)
+	}
+	if !ed25519.Verify(u.key, manifest, signature) {
+		return nil, errors.New("update: manifest signature is invalid")
+	}
+
+	var release Release
+	if err := json.Unmarshal(manifest, &release); err != nil {
+		return nil, fmt.Errorf("update: parse manifest: %w", err)
+	}
+	if release.Version == "" {
+		return nil, errors.New("update: manifest has no version")
+	}
+	return &release, nil
+}
+
+// Download fetches a binary and verifies its checksum.
+func (u *Updater) Download(ctx context.Context, b Binary) ([]byte, error) {
+	ref, err := url.Parse(b.URL)
+	if err != nil {
+		return nil, fmt.Errorf("update: binary url: %w", err)
+	}
+	want, err := hex.DecodeString(b.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return nil, fmt.Errorf("update: invalid sha256 %q", b.SHA256)
+	}
+	data, err := u.get(ctx, u.manifest.ResolveReference(ref).String(), MaxBinarySize)
+	if err != nil {
+		return nil, fmt.Errorf("update: download: %w", err)
+	}
+	if sum := sha256.Sum256(data); string(sum[:]) != string(want) {
+		return nil, fmt.Errorf("update: checksum mismatch: got %x, want %s", sum, b.SHA256)
+	}
+	return data, nil
+}
+
+// get fetches url, failing if the body exceeds limit bytes.
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, string(msg))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// Install replaces the executable at path with data, keeping its
+// permissions. The new binary is written next to the old one and renamed
+// into place, so an interrupted update leaves the old binary working.
+func Install(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// A running executable can be renamed but not overwritten on Windows,
+	// so move the old binary aside first.
+	old := path + ".old"
+	os.Remove(old)
+	if err := os.Rename(path, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Rename(old, path)
+		return err
+	}
+	// Removing fails on Windows while the old binary runs; the next update
+	// cleans it up.
+	os.Remove(old)
+	return nil
+}
+
+// Newer reports whether version candidate is newer than current. Versions
+// are dot-separated numbers with an optional "v" prefix and "-" pre-release
+// suffix; a pre-release is older than its release.
+func Newer(candidate, current string) bool {
+	a, aPre := parseVersion(candidate)
+	b, bPre := parseVersion(current)
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	if aPre == bPre {
+		return false
+	}
+	if aPre == "" || bPre == "" {
+		return aPre == ""
+	}
+	return aPre > bPre
+}
+
+// parseVersion splits a version into numeric parts and a pre-release
+// suffix. Non-numeric parts count as 0.
+func parseVersion(version string) ([]int, string) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	version, pre, _ := strings.Cut(version, "-")
+	var parts []int
+	for _, field := range strings.Split(version, ".") {
+		n, _ := strconv.Atoi(field)
+		parts = append(parts, n)
+	}
+	return parts, pre
+}
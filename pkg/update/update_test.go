@@ -0,0 +1,143 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// releaseServer serves a signed manifest for a release of binary.
+func releaseServer(t *testing.T, priv ed25519.PrivateKey, binary []byte, checksum string) *httptest.Server {
+	t.Helper()
+	if checksum == "" {
+		sum := sha256.Sum256(binary)
+		checksum = hex.EncodeToString(sum[:])
+	}
+	manifest, err := json.Marshal(Release{Version: "1.2.0", Binaries: []Binary{
+		{OS: "linux", Arch: "amd64", URL: "secmetrics-linux-amd64", SHA256: checksum},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/latest.json", func(w http.ResponseWriter, r *http.Request) { w.Write(manifest) })
+	mux.HandleFunc("/latest.json.sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, manifest)) + "\n"))
+	})
+	mux.HandleFunc("/secmetrics-linux-amd64", func(w http.ResponseWriter, r *http.Request) { w.Write(binary) })
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newUpdater(t *testing.T, srv *httptest.Server, pub ed25519.PublicKey) *Updater {
+	t.Helper()
+	u, err := New(Config{URL: srv.URL + "/latest.json", PublicKey: base64.StdEncoding.EncodeToString(pub)}, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestCheckAndDownload(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	binary := []byte("new secmetrics build")
+	u := newUpdater(t, releaseServer(t, priv, binary, ""), pub)
+
+	release, err := u.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := release.Binary("linux", "amd64")
+	if !ok {
+		t.Fatal("no linux/amd64 binary")
+	}
+	data, err := u.Download(context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(binary) {
+		t.Errorf("downloaded %q", data)
+	}
+}
+
+func TestCheckRejectsWrongKey(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	u := newUpdater(t, releaseServer(t, priv, []byte("build"), ""), other)
+
+	if _, err := u.Check(context.Background()); err == nil || !strings.Contains(err.Error(), "signature is invalid") {
+		t.Fatalf("Check with the wrong key: %v", err)
+	}
+}
+
+func TestDownloadRejectsChecksumMismatch(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	sum := sha256.Sum256([]byte("the signed build"))
+	u := newUpdater(t, releaseServer(t, priv, []byte("a tampered build"), hex.EncodeToString(sum[:])), pub)
+
+	release, err := u.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := release.Binary("linux", "amd64")
+	if _, err := u.Download(context.Background(), b); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Download of a tampered build: %v", err)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secmetrics")
+	if err := os.WriteFile(path, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Errorf("binary = %q, want new", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o755 {
+		t.Errorf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("leftover files after install: %v", entries)
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		candidate, current string
+		want               bool
+	}{
+		{"1.0.1", "1.0.0", true},
+		{"v1.10.0", "1.9.9", true},
+		{"1.0.0", "1.0.0", false},
+		{"1.0", "1.0.0", false},
+		{"0.9.0", "1.0.0", false},
+		{"1.1.0-rc1", "1.0.0", true},
+		{"1.1.0-rc1", "1.1.0", false},
+		{"1.1.0", "1.1.0-rc1", true},
+		{"1.1.0-rc2", "1.1.0-rc1", true},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.candidate, tt.current); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.candidate, tt.current, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Package wasm runs untrusted WebAssembly functions, such as derived-metric
+// formulas, in a sandbox.
+//
+// Modules get no WASI or other system access: the only imports they may use
+// are the functions of the "secmetrics" host module, which expose the
+// function's inputs. Memory is capped at MaxMemoryPages, every call runs in
+// a fresh instance and calls are aborted when their timeout expires.
+//
+// A function takes no parameters and returns one f64. It reads its inputs
+// through the host module:
+//
+//	input_count() -> i32           number of inputs
+//	input_value(i32) -> f64        value of input i (NaN if not collected)
+//	input_target(i32) -> f64       target of input i (NaN if not collected)
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// HostModule is the name of the module providing the host API.
+const HostModule = "secmetrics"
+
+// MaxMemoryPages caps a module's linear memory at 1 MiB (64 KiB pages).
+const MaxMemoryPages = 16
+
+// DefaultTimeout bounds a call when no timeout is given.
+const DefaultTimeout = time.Second
+
+// Input is a value passed to a function.
+type Input struct {
+	Value  float64
+	Target float64
+	// Missing marks inputs that were not collected; the host API returns
+	// NaN for them.
+	Missing bool
+}
+
+// inputsKey carries the inputs of the current call to the host functions.
+type inputsKey struct{}
+
+// Function is a compiled WebAssembly function.
+type Function struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	name     string
+	timeout  time.Duration
+}
+
+// Load compiles the module at path and checks that it exports the named
+// function with the expected signature and imports nothing but the host
+// API.
+func Load(ctx context.Context, path, name string, timeout time.Duration) (*Function, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read wasm module: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(MaxMemoryPages).
+		WithCloseOnContextDone(true))
+	if err := instantiateHost(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("compile %s: %w", path, err)
+	}
+	if err := checkModule(compiled, name); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Function{runtime: runtime, compiled: compiled, name: name, timeout: timeout}, nil
+}
+
+// checkModule verifies the module's imports and the function's signature.
+func checkModule(compiled wazero.CompiledModule, name string) error {
+	for _, imported := range compiled.ImportedFunctions() {
+		module, function, _ := imported.Import()
+		if module != HostModule {
+			return fmt.Errorf("import %s.%s is not allowed (only %s functions are available)", module, function, HostModule)
+		}
+	}
+	fn, ok := compiled.ExportedFunctions()[name]
+	if !ok {
+		return fmt.Errorf("function %q is not exported", name)
+	}
+	if len(fn.ParamTypes()) != 0 || len(fn.ResultTypes()) != 1 || fn.ResultTypes()[0] != api.ValueTypeF64 {
+		return fmt.Errorf("function %q must take no parameters and return one f64", name)
+	}
+	return nil
+}
+
+// instantiateHost registers the host API with the runtime.
+func instantiateHost(ctx context.Context, runtime wazero.Runtime) error {
+	input := func(ctx context.Context, i int32) (Input, bool) {
+		inputs, _ := ctx.Value(inputsKey{}).([]Input)
+		if i < 0 || int(i) >= len(inputs) || inputs[i].Missing {
+			return Input{}, false
+		}
+		return inputs[i], true
+	}
+	_, err := runtime.NewHostModuleBuilder(HostModule).
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context) int32 {
+			inputs, _ := ctx.Value(inputsKey{}).([]Input)
+			return int32(len(inputs))
+		}).
+		Export("input_count").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, i int32) float64 {
+			if in, ok := input(ctx, i); ok {
+				return in.Value
+			}
+			return math.NaN()
+		}).
+		Export("input_value").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, i int32) float64 {
+			if in, ok := input(ctx, i); ok {
+				return in.Target
+			}
+			return math.NaN()
+		}).
+		Export("input_target").
+		Instantiate(ctx)
+	if err != nil {
+		return fmt.Errorf("wasm host module: %w", err)
+	}
+	return nil
+}
+
+// Call runs the function in a fresh instance with inputs and returns its
+// result, which must be a finite number.
+func (f *Function) Call(ctx context.Context, inputs []Input) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, inputsKey{}, inputs)
+
+	// Anonymous instances may coexist, and no start function runs.
+	module, err := f.runtime.InstantiateModule(ctx, f.compiled, wazero.NewModuleConfig().WithName("").WithStartFunctions())
+	if err != nil {
+		return 0, fmt.Errorf("instantiate: %w", err)
+	}
+	defer module.Close(ctx)
+
+	results, err := module.ExportedFunction(f.name).Call(ctx)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return 0, fmt.Errorf("%s: timed out after %s", f.name, f.timeout)
+		}
+		return 0, fmt.Errorf("%s: %w", f.name, err)
+	}
+	value := api.DecodeF64(results[0])
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%s: result must be a finite number", f.name)
+	}
+	return value, nil
+}
+
+// Close releases the compiled module and its runtime.
+func (f *Function) Close(ctx context.Context) error {
+	return f.runtime.Close(ctx)
+}
@@ -0,0 +1,162 @@
+package wasm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The modules below are assembled by hand from the binary format; every
+// section, vector and body is shorter than 128 bytes, so each length fits
+// in a single LEB128 byte.
+
+var (
+	typeI32F64 = []byte{0x60, 0x01, 0x7f, 0x01, 0x7c} // (i32) -> f64
+	typeF64    = []byte{0x60, 0x00, 0x01, 0x7c}       // () -> f64
+)
+
+// module returns a module of sections.
+func module(sections ...[]byte) []byte {
+	code := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	for _, section := range sections {
+		code = append(code, section...)
+	}
+	return code
+}
+
+// section returns section id holding the vector of items.
+func section(id byte, items ...[]byte) []byte {
+	contents := []byte{byte(len(items))}
+	for _, item := range items {
+		contents = append(contents, item...)
+	}
+	return append([]byte{id, byte(len(contents))}, contents...)
+}
+
+// name returns an encoded name.
+func name(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+// importFunc returns a function import of module.field with type typeIndex.
+func importFunc(module, field string, typeIndex byte) []byte {
+	return append(append(name(module), name(field)...), 0x00, typeIndex)
+}
+
+// exportFunc returns the export of function index as field.
+func exportFunc(field string, index byte) []byte {
+	return append(name(field), 0x00, index)
+}
+
+// body returns a function body without locals running instructions.
+func body(instructions ...byte) []byte {
+	code := append(append([]byte{0x00}, instructions...), 0x0b)
+	return append([]byte{byte(len(code))}, code...)
+}
+
+// load writes code to a file and loads function name from it.
+func load(t *testing.T, code []byte, function string, timeout time.Duration) (*Function, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "module.wasm")
+	if err := os.WriteFile(path, code, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Load(context.Background(), path, function, timeout)
+	if err == nil {
+		t.Cleanup(func() { f.Close(context.Background()) })
+	}
+	return f, err
+}
+
+func TestCallReadsInputs(t *testing.T) {
+	// score() = input_value(0) + input_target(0)
+	code := module(
+		section(1, typeI32F64, typeF64),
+		section(2, importFunc(HostModule, "input_value", 0), importFunc(HostModule, "input_target", 0)),
+		section(3, []byte{1}),
+		section(7, exportFunc("score", 2)),
+		section(10, body(0x41, 0x00, 0x10, 0x00, 0x41, 0x00, 0x10, 0x01, 0xa0)),
+	)
+	f, err := load(t, code, "score", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := f.Call(context.Background(), []Input{{Value: 40, Target: 2}}); err != nil || value != 42 {
+		t.Errorf("score = %v, %v; want 42", value, err)
+	}
+	// Missing inputs read as NaN, which is not a valid result
+	if _, err := f.Call(context.Background(), []Input{{Missing: true}}); err == nil || !strings.Contains(err.Error(), "finite") {
+		t.Errorf("score of a missing input: %v", err)
+	}
+	if _, err := load(t, code, "other", 0); err == nil {
+		t.Error("loaded a function the module does not export")
+	}
+}
+
+func TestLoadRejectsForeignImports(t *testing.T) {
+	// score() = env.now()
+	code := module(
+		section(1, typeF64),
+		section(2, importFunc("env", "now", 0)),
+		section(3, []byte{0}),
+		section(7, exportFunc("score", 1)),
+		section(10, body(0x10, 0x00)),
+	)
+	_, err := load(t, code, "score", 0)
+	if err == nil || !strings.Contains(err.Error(), "import env.now is not allowed") {
+		t.Errorf("load with a foreign import: %v", err)
+	}
+}
+
+func TestMemoryIsCapped(t *testing.T) {
+	// A one-page memory; each function returns memory.grow(n), the
+	// previous size in pages or -1 when the memory cannot grow.
+	code := module(
+		section(1, typeF64),
+		section(3, []byte{0}, []byte{0}),
+		section(5, []byte{0x00, 0x01}),
+		section(7, exportFunc("grow_within", 0), exportFunc("grow_past", 1)),
+		section(10,
+			body(0x41, MaxMemoryPages-1, 0x40, 0x00, 0xb7),
+			body(0x41, MaxMemoryPages, 0x40, 0x00, 0xb7)),
+	)
+	within, err := load(t, code, "grow_within", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := within.Call(context.Background(), nil); err != nil || value != 1 {
+		t.Errorf("growing to %d pages = %v, %v; want 1", MaxMemoryPages, value, err)
+	}
+	past, err := load(t, code, "grow_past", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := past.Call(context.Background(), nil); err != nil || value != -1 {
+		t.Errorf("growing to %d pages = %v, %v; want -1", MaxMemoryPages+1, value, err)
+	}
+}
+
+func TestCallTimesOut(t *testing.T) {
+	// spin() loops forever: loop br 0 end, then an unreachable f64.const 0
+	code := module(
+		section(1, typeF64),
+		section(3, []byte{0}),
+		section(7, exportFunc("spin", 0)),
+		section(10, body(0x03, 0x40, 0x0c, 0x00, 0x0b, 0x44, 0, 0, 0, 0, 0, 0, 0, 0)),
+	)
+	f, err := load(t, code, "spin", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = f.Call(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("infinite loop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("infinite loop stopped after %s", elapsed)
+	}
+}